@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/loykin/freader"
+	"github.com/spf13/cobra"
+)
+
+// benchOptions configure a synthetic load run.
+type benchOptions struct {
+	Dir          string // directory for generated files; a temp dir when empty
+	Files        int
+	Rate         int // lines per second across all files
+	LineSize     int // bytes per line including the newline
+	Duration     time.Duration
+	RotateEvery  time.Duration // rename and recreate each file at this cadence; 0 disables
+	DrainTimeout time.Duration // how long to wait for the collector to catch up after writing stops
+	Workers      int
+	PollInterval time.Duration
+	Strategy     string
+}
+
+// benchResult summarizes a bench run.
+type benchResult struct {
+	Written    int64
+	Received   int64
+	Duplicates int64
+	Bytes      int64
+	Elapsed    time.Duration // from the first write until the collector caught up or gave up
+	Rotations  int64
+	Latencies  []time.Duration // write-to-callback latency of each received line, sorted
+}
+
+// Lost is the number of written lines never delivered.
+func (r benchResult) Lost() int64 { return r.Written - (r.Received - r.Duplicates) }
+
+// Percentile returns the latency at percentile p (0-100).
+func (r benchResult) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.Latencies)-1) * p / 100)
+	return r.Latencies[i]
+}
+
+func newBenchCmd() *cobra.Command {
+	o := benchOptions{
+		Files:        4,
+		Rate:         10000,
+		LineSize:     200,
+		Duration:     30 * time.Second,
+		RotateEvery:  10 * time.Second,
+		DrainTimeout: 30 * time.Second,
+		Workers:      4,
+		PollInterval: 250 * time.Millisecond,
+		Strategy:     freader.FingerprintStrategyChecksum,
+	}
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Measure collector throughput, latency and loss on synthetic log files",
+		Long: `Generate log files at a fixed rate with periodic rotation, tail them with the
+collector and report throughput, write-to-delivery latency percentiles and loss.
+Use it to size workers and poll intervals for a host before deploying.
+
+Example:
+  freader bench --files 8 --rate 50000 --line-size 512 --duration 1m --rotate-every 15s`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			res, err := runBench(ctx, o)
+			if err != nil {
+				return err
+			}
+			printBench(cmd.OutOrStdout(), o, res)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&o.Dir, "dir", o.Dir, "Directory for generated files (default: a temporary directory, removed afterwards)")
+	cmd.Flags().IntVar(&o.Files, "files", o.Files, "Number of files written concurrently")
+	cmd.Flags().IntVar(&o.Rate, "rate", o.Rate, "Lines per second across all files")
+	cmd.Flags().IntVar(&o.LineSize, "line-size", o.LineSize, "Bytes per line including the newline (min 64)")
+	cmd.Flags().DurationVar(&o.Duration, "duration", o.Duration, "How long to write")
+	cmd.Flags().DurationVar(&o.RotateEvery, "rotate-every", o.RotateEvery, "Rotate each file (rename to .1, recreate) at this interval; 0 disables")
+	cmd.Flags().DurationVar(&o.DrainTimeout, "drain-timeout", o.DrainTimeout, "How long to wait for the collector to catch up after writing stops")
+	cmd.Flags().IntVar(&o.Workers, "workers", o.Workers, "Collector worker goroutines")
+	cmd.Flags().DurationVar(&o.PollInterval, "poll-interval", o.PollInterval, "Collector poll interval")
+	cmd.Flags().StringVar(&o.Strategy, "fingerprint-strategy", o.Strategy, "Fingerprint strategy (checksum or deviceAndInode)")
+	return cmd
+}
+
+// benchLineFormat is the fixed-width prefix of every generated line: writer, sequence number
+// and write time, followed by padding up to the line size.
+const benchLineFormat = "w=%04d seq=%012d ts=%019d "
+
+func (o benchOptions) validate() error {
+	if o.Files <= 0 || o.Rate <= 0 || o.Workers <= 0 {
+		return fmt.Errorf("files, rate and workers must be > 0")
+	}
+	if o.LineSize < 64 {
+		return fmt.Errorf("line-size must be at least 64")
+	}
+	if o.Duration <= 0 || o.PollInterval <= 0 {
+		return fmt.Errorf("duration and poll-interval must be > 0")
+	}
+	if o.RotateEvery < 0 || o.DrainTimeout < 0 {
+		return fmt.Errorf("rotate-every and drain-timeout must not be negative")
+	}
+	return nil
+}
+
+// parseBenchLine extracts the writer, sequence number and write time from a line
+// produced with benchLineFormat.
+func parseBenchLine(b []byte) (w, seq int, ts int64, ok bool) {
+	fields := bytes.Fields(b)
+	if len(fields) < 3 {
+		return 0, 0, 0, false
+	}
+	var err error
+	if w, err = strconv.Atoi(string(bytes.TrimPrefix(fields[0], []byte("w=")))); err != nil {
+		return 0, 0, 0, false
+	}
+	if seq, err = strconv.Atoi(string(bytes.TrimPrefix(fields[1], []byte("seq=")))); err != nil {
+		return 0, 0, 0, false
+	}
+	if ts, err = strconv.ParseInt(string(bytes.TrimPrefix(fields[2], []byte("ts="))), 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	return w, seq, ts, true
+}
+
+// runBench writes synthetic lines, collects them and measures delivery. It stops
+// writing early when ctx is cancelled.
+func runBench(ctx context.Context, o benchOptions) (benchResult, error) {
+	if err := o.validate(); err != nil {
+		return benchResult{}, err
+	}
+	dir := o.Dir
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "freader-bench-")
+		if err != nil {
+			return benchResult{}, err
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		dir = tmp
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return benchResult{}, err
+	}
+
+	var (
+		mu        sync.Mutex
+		seen      = make([][]bool, o.Files)
+		latencies []time.Duration
+		res       benchResult
+		received  atomic.Int64
+	)
+	cfg := freader.Config{}
+	cfg.Default()
+	// Rotated files keep their fingerprint, so they are read to the end under the new name
+	cfg.Include = []string{filepath.Join(dir, "bench-*.log*")}
+	cfg.WorkerCount = o.Workers
+	cfg.PollInterval = o.PollInterval
+	cfg.FingerprintStrategy = o.Strategy
+	cfg.FingerprintSize = 64
+	cfg.StoreOffsets = false
+	cfg.OnLineBytesFunc = func(b []byte) {
+		now := time.Now()
+		w, seq, ts, ok := parseBenchLine(b)
+		if !ok || w < 0 || w >= o.Files || seq < 0 {
+			return
+		}
+		mu.Lock()
+		if seq >= len(seen[w]) {
+			seen[w] = append(seen[w], make([]bool, seq+1-len(seen[w])+1024)...)
+		}
+		if seen[w][seq] {
+			res.Duplicates++
+		}
+		seen[w][seq] = true
+		res.Bytes += int64(len(b)) + 1
+		latencies = append(latencies, now.Sub(time.Unix(0, ts)))
+		mu.Unlock()
+		received.Add(1)
+	}
+	c, err := freader.NewCollector(cfg)
+	if err != nil {
+		return benchResult{}, err
+	}
+	c.Start()
+	defer c.Stop()
+
+	writeCtx, cancel := context.WithTimeout(ctx, o.Duration)
+	defer cancel()
+	var (
+		written   atomic.Int64
+		rotations atomic.Int64
+		wg        sync.WaitGroup
+		errMu     sync.Mutex
+		writeErr  error
+	)
+	start := time.Now()
+	for i := 0; i < o.Files; i++ {
+		// Spread the rate over the files; the first ones take the remainder
+		rate := o.Rate / o.Files
+		if i < o.Rate%o.Files {
+			rate++
+		}
+		wg.Add(1)
+		go func(id, rate int) {
+			defer wg.Done()
+			if err := benchWriter(writeCtx, filepath.Join(dir, fmt.Sprintf("bench-%d.log", id)), id, rate, o, &written, &rotations); err != nil {
+				errMu.Lock()
+				writeErr = err
+				errMu.Unlock()
+			}
+		}(i, rate)
+	}
+	wg.Wait()
+	if writeErr != nil {
+		return benchResult{}, writeErr
+	}
+
+	// Let the collector catch up
+	deadline := time.Now().Add(o.DrainTimeout)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		mu.Lock()
+		caughtUp := received.Load()-res.Duplicates >= written.Load()
+		mu.Unlock()
+		if caughtUp {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	res.Elapsed = time.Since(start)
+	res.Written = written.Load()
+	res.Received = received.Load()
+	res.Rotations = rotations.Load()
+	res.Latencies = latencies
+	slices.Sort(res.Latencies)
+	return res, nil
+}
+
+// benchWriter appends rate lines per second to path until ctx is done, rotating the
+// file every o.RotateEvery.
+func benchWriter(ctx context.Context, path string, id, rate int, o benchOptions, written, rotations *atomic.Int64) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	padding := bytes.Repeat([]byte{'x'}, o.LineSize-len(fmt.Sprintf(benchLineFormat, 0, 0, 0))-1)
+	const tick = 10 * time.Millisecond
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	start := time.Now()
+	lastRotate := start
+	seq := 0
+	var buf []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if o.RotateEvery > 0 && now.Sub(lastRotate) >= o.RotateEvery {
+				if err := f.Close(); err != nil {
+					return err
+				}
+				if err := os.Rename(path, path+".1"); err != nil {
+					return err
+				}
+				if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+					return err
+				}
+				lastRotate = now
+				rotations.Add(1)
+			}
+			// Catch up to the number of lines due by now, so ticker jitter does not lower the rate
+			due := int(now.Sub(start).Seconds() * float64(rate))
+			buf = buf[:0]
+			n := 0
+			for ; seq < due; seq++ {
+				buf = fmt.Appendf(buf, benchLineFormat, id, seq, time.Now().UnixNano())
+				buf = append(buf, padding...)
+				buf = append(buf, '\n')
+				n++
+			}
+			if n == 0 {
+				continue
+			}
+			if _, err := f.Write(buf); err != nil {
+				return err
+			}
+			written.Add(int64(n))
+		}
+	}
+}
+
+func printBench(w io.Writer, o benchOptions, r benchResult) {
+	secs := r.Elapsed.Seconds()
+	_, _ = fmt.Fprintf(w, "files %d, rate %d lines/s, line size %d B, duration %s, rotate every %s, workers %d\n",
+		o.Files, o.Rate, o.LineSize, o.Duration, o.RotateEvery, o.Workers)
+	_, _ = fmt.Fprintf(w, "written     %d lines (%d rotations)\n", r.Written, r.Rotations)
+	_, _ = fmt.Fprintf(w, "received    %d lines, %d duplicates\n", r.Received, r.Duplicates)
+	_, _ = fmt.Fprintf(w, "lost        %d lines (%.3f%%)\n", r.Lost(), 100*float64(r.Lost())/float64(max(r.Written, 1)))
+	_, _ = fmt.Fprintf(w, "throughput  %.0f lines/s, %.2f MB/s\n", float64(r.Received)/secs, float64(r.Bytes)/secs/1e6)
+	_, _ = fmt.Fprintf(w, "latency     p50 %s  p90 %s  p99 %s  max %s\n",
+		r.Percentile(50).Round(time.Microsecond), r.Percentile(90).Round(time.Microsecond),
+		r.Percentile(99).Round(time.Microsecond), r.Percentile(100).Round(time.Microsecond))
+}
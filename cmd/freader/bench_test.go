@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBench(t *testing.T) {
+	o := benchOptions{
+		Dir:          t.TempDir(),
+		Files:        2,
+		Rate:         2000,
+		LineSize:     100,
+		Duration:     600 * time.Millisecond,
+		DrainTimeout: 5 * time.Second,
+		Workers:      2,
+		PollInterval: 20 * time.Millisecond,
+		Strategy:     "checksum",
+	}
+	res, err := runBench(context.Background(), o)
+	if err != nil {
+		t.Fatalf("runBench: %v", err)
+	}
+	if res.Written == 0 {
+		t.Fatal("nothing written")
+	}
+	if res.Lost() != 0 || res.Duplicates != 0 {
+		t.Fatalf("written %d, received %d, duplicates %d", res.Written, res.Received, res.Duplicates)
+	}
+	if int64(len(res.Latencies)) != res.Received || res.Percentile(50) > res.Percentile(99) {
+		t.Fatalf("bad latencies: %d samples, p50 %s p99 %s", len(res.Latencies), res.Percentile(50), res.Percentile(99))
+	}
+
+	var out bytes.Buffer
+	printBench(&out, o, res)
+	for _, want := range []string{"written", "lost        0 lines", "throughput", "p99"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in report:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunBench_Rotation(t *testing.T) {
+	res, err := runBench(context.Background(), benchOptions{
+		Dir:          t.TempDir(),
+		Files:        1,
+		Rate:         1000,
+		LineSize:     100,
+		Duration:     500 * time.Millisecond,
+		RotateEvery:  200 * time.Millisecond,
+		DrainTimeout: time.Second,
+		Workers:      1,
+		PollInterval: 20 * time.Millisecond,
+		Strategy:     "checksum",
+	})
+	if err != nil {
+		t.Fatalf("runBench: %v", err)
+	}
+	if res.Rotations == 0 || res.Received == 0 {
+		t.Fatalf("expected rotations and received lines: %+v", res)
+	}
+}
+
+func TestBenchOptionsValidate(t *testing.T) {
+	if _, err := runBench(context.Background(), benchOptions{Files: 1, Rate: 1, Workers: 1, LineSize: 10, Duration: time.Second, PollInterval: time.Second}); err == nil {
+		t.Fatal("expected error for a line size below the prefix length")
+	}
+}
+
+func TestParseBenchLine(t *testing.T) {
+	w, seq, ts, ok := parseBenchLine([]byte("w=0003 seq=000000000042 ts=1700000000000000000 xxxx"))
+	if !ok || w != 3 || seq != 42 || ts != 1700000000000000000 {
+		t.Fatalf("got %d %d %d %v", w, seq, ts, ok)
+	}
+	if _, _, _, ok := parseBenchLine([]byte("unrelated line")); ok {
+		t.Fatal("expected failure")
+	}
+}
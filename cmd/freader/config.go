@@ -1,41 +1,84 @@
 package main
 
 import (
+	"encoding/binary"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/loykin/freader"
+	cmddocker "github.com/loykin/freader/cmd/freader/discovery/docker"
+	"github.com/loykin/freader/cmd/freader/live"
 	"github.com/loykin/freader/cmd/freader/metrics"
 	cmdclick "github.com/loykin/freader/cmd/freader/sink/clickhouse"
+	"github.com/loykin/freader/cmd/freader/sink/common"
 	cmdconsole "github.com/loykin/freader/cmd/freader/sink/console"
+	cmdexec "github.com/loykin/freader/cmd/freader/sink/exec"
 	cmdfile "github.com/loykin/freader/cmd/freader/sink/file"
+	cmdgrpc "github.com/loykin/freader/cmd/freader/sink/grpc"
+	cmdinflux "github.com/loykin/freader/cmd/freader/sink/influxdb"
 	cmdos "github.com/loykin/freader/cmd/freader/sink/opensearch"
+	cmdsql "github.com/loykin/freader/cmd/freader/sink/sqldb"
+	cmdunix "github.com/loykin/freader/cmd/freader/sink/unix"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 type SinkConfig struct {
-	Type          string            `mapstructure:"type"` // "" (disabled), "console", "stdout", "stderr", "file", "clickhouse", "opensearch"
+	Type          string            `mapstructure:"type"` // "" (disabled), "console", "stdout", "stderr", "file", "exec", "unix", "grpc", "clickhouse", "opensearch", "influxdb", "sql"
 	Include       []string          `mapstructure:"include"`
 	Exclude       []string          `mapstructure:"exclude"`
+	Filter        string            `mapstructure:"filter"` // expression over the record envelope and parsed fields, see filter.go
 	BatchSize     int               `mapstructure:"batch-size"`
+	BatchBytes    int               `mapstructure:"batch-bytes"` // optional request-size cap (clickhouse/opensearch); 0 disables
 	BatchInterval time.Duration     `mapstructure:"batch-interval"`
-	Host          string            `mapstructure:"host"`   // override host; default os.Hostname()
-	Labels        map[string]string `mapstructure:"labels"` // optional key-value labels
+	Concurrency   int               `mapstructure:"concurrency"`   // parallel in-flight flushes (clickhouse/opensearch); default 1
+	Ordered       bool              `mapstructure:"ordered"`       // commit batches in dispatch order while up to concurrency are in flight
+	Retries       int               `mapstructure:"retries"`       // extra attempts for a failed flush (clickhouse/opensearch)
+	RetryBackoff  time.Duration     `mapstructure:"retry-backoff"` // wait before the first retry, doubled per retry
+	Host          string            `mapstructure:"host"`          // override host; default os.Hostname()
+	Labels        map[string]string `mapstructure:"labels"`        // optional key-value labels
+	Headers       map[string]string `mapstructure:"headers"`       // per-record header templates (grpc), see headers.go
+	// Distinct indices or tables a templated opensearch.index or clickhouse.table may
+	// render to, and where records rendering to further ones go; see route.go
+	RouteLimit    int               `mapstructure:"route-limit"`
+	RouteFallback string            `mapstructure:"route-fallback"`
 	Console       cmdconsole.Config `mapstructure:"console"`
 	ClickHouse    cmdclick.Config   `mapstructure:"clickhouse"`
 	OpenSearch    cmdos.Config      `mapstructure:"opensearch"`
+	InfluxDB      cmdinflux.Config  `mapstructure:"influxdb"`
+	SQL           cmdsql.Config     `mapstructure:"sql"`
 	File          cmdfile.Config    `mapstructure:"file"`
+	Exec          cmdexec.Config    `mapstructure:"exec"`
+	Unix          cmdunix.Config    `mapstructure:"unix"`
+	Grpc          cmdgrpc.Config    `mapstructure:"grpc"`
 }
 
 // Config holds all configuration options for the freader application
 // It now uses a nested Collector config for the reader options.
 type ParserConfig struct {
-	Type            string `mapstructure:"type"`              // "" or "auditd"
+	Type            string `mapstructure:"type"`              // "", "auditd", "cri" or "docker-json"
 	Format          string `mapstructure:"format"`            // "raw" or "json"
 	DropNonMatching bool   `mapstructure:"drop-non-matching"` // if true, drop lines that don't match parser
+	// Record timestamp extraction for --start-from-time and the sinks' event time: a regexp
+	// whose first capture group (or whole match) is parsed with TimestampLayout (Go layout
+	// or "unix"; default RFC3339), or a dot path to a field of JSON records.
+	TimestampPattern string `mapstructure:"timestamp-pattern"`
+	TimestampField   string `mapstructure:"timestamp-field"`
+	TimestampLayout  string `mapstructure:"timestamp-layout"`
+	// Rules copying or moving fields of JSON records, applied in order after parsing
+	Fields []FieldRule `mapstructure:"fields"`
+	// WebAssembly modules transforming records, applied in order after the field rules
+	Plugins []PluginConfig `mapstructure:"plugins"`
+	// File receiving the records parsers (and plugins) failed on, one JSON line each with
+	// the error; failures are also counted in freader_parser_failures_total
+	ErrorFile string `mapstructure:"error-file"`
+}
+
+// DiscoveryConfig holds container discovery sources that add files to tail at runtime.
+type DiscoveryConfig struct {
+	Docker cmddocker.Config `mapstructure:"docker"`
 }
 
 type Config struct {
@@ -49,6 +92,29 @@ type Config struct {
 	Parser ParserConfig `mapstructure:"parser"`
 	// Metrics/Prometheus options
 	Prometheus metrics.Config `mapstructure:"prometheus"`
+	// Live stream of records to WebSocket/SSE clients
+	Live live.Config `mapstructure:"live"`
+	// Container discovery (Docker Engine API)
+	Discovery DiscoveryConfig `mapstructure:"discovery"`
+	// Only emit records at or after this RFC3339 time from files read from the beginning
+	StartFromTime string `mapstructure:"start-from-time"`
+	// Node agent preset ("kubernetes-node" or "docker-node") providing include patterns
+	// and the container log parser; explicit settings override it
+	Preset string `mapstructure:"preset"`
+	// Log output format: auto (journal under journald, else text), text, json or journal
+	LogFormat string `mapstructure:"log-format"`
+	// Read all matching files to their end once, flush the sink, store offsets and exit
+	Once bool `mapstructure:"once"`
+	// Exit cleanly once no new data has arrived from any tracked file for this long; 0 disables
+	ExitAfterIdle time.Duration `mapstructure:"exit-after-idle"`
+	// How often backfills (--once, --from-beginning, --start-from-time) log progress; 0 disables
+	ProgressInterval time.Duration `mapstructure:"progress-interval"`
+	// Hold the lease in this Kubernetes Lease object, "[namespace/]name" (default the pod's
+	// namespace), instead of the offsets store
+	KubernetesLease string `mapstructure:"kubernetes-lease"`
+	// Read and parse as configured but count the records instead of delivering them to
+	// the sink, without storing offsets, reporting every progress-interval and on exit
+	DryRun bool `mapstructure:"dry-run"`
 }
 
 // LoadFromViper binds flags to viper, reads file/env, and populates the Config fields via mapstructure.
@@ -75,61 +141,155 @@ func (c *Config) LoadFromViper(cmd *cobra.Command) error {
 		}
 	}
 
+	if preset := v.GetString("preset"); preset != "" {
+		if err := applyPreset(v, preset); err != nil {
+			return err
+		}
+	}
+
+	// Discovered containers write Docker json-file logs
+	dockerDiscovery := v.GetBool("discovery.docker.enable")
+	if dockerDiscovery {
+		v.SetDefault("parser.type", parserTypeDockerJSON)
+	}
+
 	// Map select top-level flags to nested collector keys before unmarshal so flags override file
 	if f := cmd.Flags().Lookup("include"); f != nil && f.Changed {
 		v.Set("collector.include", v.GetStringSlice("include"))
 	}
+	if f := cmd.Flags().Lookup("separator"); f != nil && f.Changed {
+		v.Set("collector.separator", v.GetString("separator"))
+	}
 
 	// Unmarshal into this Config using mapstructure with proper tagname and duration hooks
 	if err := v.Unmarshal(c); err != nil {
 		return err
 	}
+	if dockerDiscovery && !v.IsSet("collector.include") {
+		// Only tail discovered containers, not the quick-start example logs
+		c.Collector.Include = nil
+	}
+
+	// collector.separator-rules is a list of tables with kebab-case keys
+	var rules []struct {
+		Pattern    string   `mapstructure:"pattern"`
+		Separators []string `mapstructure:"separators"`
+	}
+	if err := v.UnmarshalKey("collector.separator-rules", &rules); err != nil {
+		return err
+	}
+	for _, r := range rules {
+		c.Collector.SeparatorRules = append(c.Collector.SeparatorRules, freader.SeparatorRule{Pattern: r.Pattern, Separators: r.Separators})
+	}
+
+	// --separator may be written with escapes such as \0 or \r\n; TOML strings already
+	// decode their own, so file values are taken as they are
+	if cmd.Flags().Changed("separator") {
+		var err error
+		if c.Collector.Separator, err = unescapeSeparator(c.Collector.Separator); err != nil {
+			return err
+		}
+	}
+
+	if sub := v.Sub("collector.length-prefix"); sub != nil {
+		var raw struct {
+			Width         int    `mapstructure:"width"`
+			ByteOrder     string `mapstructure:"byte-order"`
+			MaxRecordSize int    `mapstructure:"max-record-size"`
+		}
+		if err := sub.Unmarshal(&raw); err != nil {
+			return err
+		}
+		lp := &freader.LengthPrefix{Width: raw.Width, MaxRecordSize: raw.MaxRecordSize}
+		switch strings.ToLower(raw.ByteOrder) {
+		case "", "big":
+			lp.ByteOrder = binary.BigEndian
+		case "little":
+			lp.ByteOrder = binary.LittleEndian
+		default:
+			return fmt.Errorf("collector.length-prefix.byte-order must be big or little, got %q", raw.ByteOrder)
+		}
+		c.Collector.LengthPrefix = lp
+	}
 
 	// Backward/explicit parsing for collector.multiline into a proper MultilineReader
 	// This ensures kebab-case keys like start-pattern map correctly.
 	if sub := v.Sub("collector"); sub != nil {
 		if ml := sub.Sub("multiline"); ml != nil {
-			var raw struct {
-				Mode             string        `mapstructure:"mode"`
-				StartPattern     string        `mapstructure:"start-pattern"`
-				ConditionPattern string        `mapstructure:"condition-pattern"`
-				Timeout          time.Duration `mapstructure:"timeout"`
-				Java             bool          `mapstructure:"java"`
-			}
+			var raw multilineConfig
 			if err := ml.Unmarshal(&raw); err != nil {
 				return err
 			}
-			// If any field is provided (or java preset), build the reader
-			if raw.Mode != "" || raw.StartPattern != "" || raw.ConditionPattern != "" || raw.Timeout > 0 || raw.Java {
-				mr := &freader.MultilineReader{}
-				if raw.Java {
-					// Apply Java-style presets if not explicitly set
-					if raw.Mode == "" {
-						raw.Mode = freader.MultilineReaderModeContinueThrough
-					}
-					if raw.StartPattern == "" {
-						raw.StartPattern = "^(ERROR|WARN|INFO|Exception)"
-					}
-					if raw.ConditionPattern == "" {
-						raw.ConditionPattern = "^(\\s|at\\s|Caused by:)"
-					}
-					if raw.Timeout <= 0 {
-						raw.Timeout = 500 * time.Millisecond
-					}
-				}
-				// Assign from raw
-				mr.Mode = raw.Mode
-				mr.StartPattern = raw.StartPattern
-				mr.ConditionPattern = raw.ConditionPattern
-				mr.Timeout = raw.Timeout
-				c.Collector.Multiline = mr
-			}
+			c.Collector.Multiline = raw.reader()
 		}
 	}
 
+	// collector.multiline-rules gives files matching a pattern their own grouping; a
+	// rule without any multiline setting disables grouping for them
+	var mlRules []struct {
+		Pattern         string `mapstructure:"pattern"`
+		multilineConfig `mapstructure:",squash"`
+	}
+	if err := v.UnmarshalKey("collector.multiline-rules", &mlRules); err != nil {
+		return err
+	}
+	for _, r := range mlRules {
+		c.Collector.MultilineRules = append(c.Collector.MultilineRules, freader.MultilineRule{Pattern: r.Pattern, Multiline: r.reader()})
+	}
+
+	// collector.priority-rules weighs matching files in scheduling
+	var prRules []struct {
+		Pattern string `mapstructure:"pattern"`
+		Weight  int    `mapstructure:"weight"`
+	}
+	if err := v.UnmarshalKey("collector.priority-rules", &prRules); err != nil {
+		return err
+	}
+	for _, r := range prRules {
+		c.Collector.PriorityRules = append(c.Collector.PriorityRules, freader.PriorityRule{Pattern: r.Pattern, Weight: r.Weight})
+	}
+
 	return nil
 }
 
+// multilineConfig is the kebab-case form of a MultilineReader in the config file.
+type multilineConfig struct {
+	Mode             string        `mapstructure:"mode"`
+	StartPattern     string        `mapstructure:"start-pattern"`
+	ConditionPattern string        `mapstructure:"condition-pattern"`
+	Timeout          time.Duration `mapstructure:"timeout"`
+	Java             bool          `mapstructure:"java"`
+}
+
+// reader builds the MultilineReader, or returns nil if no field is set.
+func (raw multilineConfig) reader() *freader.MultilineReader {
+	// If any field is provided (or java preset), build the reader
+	if raw.Mode == "" && raw.StartPattern == "" && raw.ConditionPattern == "" && raw.Timeout <= 0 && !raw.Java {
+		return nil
+	}
+	if raw.Java {
+		// Apply Java-style presets if not explicitly set
+		if raw.Mode == "" {
+			raw.Mode = freader.MultilineReaderModeContinueThrough
+		}
+		if raw.StartPattern == "" {
+			raw.StartPattern = "^(ERROR|WARN|INFO|Exception)"
+		}
+		if raw.ConditionPattern == "" {
+			raw.ConditionPattern = "^(\\s|at\\s|Caused by:)"
+		}
+		if raw.Timeout <= 0 {
+			raw.Timeout = 500 * time.Millisecond
+		}
+	}
+	return &freader.MultilineReader{
+		Mode:             raw.Mode,
+		StartPattern:     raw.StartPattern,
+		ConditionPattern: raw.ConditionPattern,
+		Timeout:          raw.Timeout,
+	}
+}
+
 // DefaultConfig returns a Config with default values
 func DefaultConfig() *Config {
 	cfg := &Config{
@@ -139,10 +299,17 @@ func DefaultConfig() *Config {
 			Exclude:       []string{},
 			BatchSize:     200,
 			BatchInterval: 2 * time.Second,
+			Concurrency:   1,
+			RetryBackoff:  time.Second,
 			Labels:        map[string]string{},
 			Console:       cmdconsole.Config{Stream: "stdout"},
+			Exec:          cmdexec.Config{RestartBackoff: time.Second},
 		},
 		Prometheus: metrics.Config{Enable: false, Addr: ":2112"},
+		Live:       live.Config{Addr: "127.0.0.1:8081"},
+		LogFormat:  logFormatAuto,
+
+		ProgressInterval: 10 * time.Second,
 	}
 	// Initialize nested collector defaults
 	cfg.Collector.Default()
@@ -151,6 +318,7 @@ func DefaultConfig() *Config {
 	cfg.Collector.Include = []string{"./examples/embedded/log", "./examples/embedded/log/*.log"}
 	cfg.Collector.Exclude = []string{}
 	cfg.Collector.PollInterval = 2 * time.Second
+	cfg.Collector.IgnoreFile = ".freaderignore"
 	cfg.Collector.FingerprintStrategy = freader.FingerprintStrategyChecksum
 	cfg.Collector.FingerprintSize = 64
 	cfg.Collector.WorkerCount = 1
@@ -163,18 +331,71 @@ func (c *Config) SetupFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&c.ConfigFile, "config", c.ConfigFile, "Path to config file (yaml/json/toml)")
 
 	// Collector flags (write directly into nested struct)
-	cmd.Flags().StringSliceVarP(&c.Collector.Include, "include", "I", c.Collector.Include, "Include patterns or directories to monitor (e.g., ./log, /var/log/*.log)")
+	cmd.Flags().StringSliceVarP(&c.Collector.Include, "include", "I", c.Collector.Include, "Include patterns or directories to monitor (e.g., ./log, /var/log/*.log); !pattern narrows earlier entries")
 	cmd.Flags().StringSliceVarP(&c.Collector.Exclude, "exclude", "E", c.Collector.Exclude, "Exclude patterns (e.g., *.tmp, *.log)")
+	cmd.Flags().StringVar(&c.Collector.IgnoreFile, "ignore-file", c.Collector.IgnoreFile, "Name of gitignore-style files in the include roots whose patterns are excluded too; empty disables")
+	cmd.Flags().StringSliceVar(&c.Collector.ExcludeDirs, "exclude-dirs", c.Collector.ExcludeDirs, "Directories not to descend into while scanning (e.g., node_modules, .git, archived)")
 	cmd.Flags().DurationVarP(&c.Collector.PollInterval, "poll-interval", "i", c.Collector.PollInterval, "Interval to poll for file changes")
-	cmd.Flags().StringVar(&c.Collector.Separator, "separator", c.Collector.Separator, "Record separator (string, supports multi-byte like \\\"\\r\\n\\\" or tokens like <END>)")
+	cmd.Flags().DurationVar(&c.Collector.ScanBudget, "scan-budget", c.Collector.ScanBudget, "Time a scan may spend per poll interval before resuming on the next one; 0 = no limit")
+	cmd.Flags().IntVar(&c.Collector.ScanMaxFiles, "scan-max-files", c.Collector.ScanMaxFiles, "Files a scan may examine per poll interval before resuming on the next one; 0 = no limit")
+	cmd.Flags().StringVar(&c.Collector.Separator, "separator", c.Collector.Separator, "Record separator (string, supports multi-byte like \\\"\\r\\n\\\" or tokens like <END>; escapes such as \\0 for NUL are interpreted)")
+	cmd.Flags().StringVar(&c.Collector.SeparatorRegex, "separator-regex", c.Collector.SeparatorRegex, "Record separator as a regular expression (e.g. \\r?\\n); overrides --separator for splitting")
+	cmd.Flags().BoolVar(&c.Collector.SeparatorAutoDetect, "separator-auto-detect", c.Collector.SeparatorAutoDetect, "Choose \\n or \\r\\n per file from the line endings at its head")
 	cmd.Flags().IntVarP(&c.Collector.FingerprintSize, "fingerprint-size", "s", c.Collector.FingerprintSize, "Size of fingerprint for checksum strategy (or N separators for checksumSeparator)")
+	cmd.Flags().BoolVar(&c.Collector.UpgradeFingerprints, "upgrade-fingerprints", c.Collector.UpgradeFingerprints, "Read files too small for a checksum fingerprint right away and switch to the checksum once they have grown")
+	cmd.Flags().Int64Var(&c.Collector.FingerprintOffset, "fingerprint-offset", c.Collector.FingerprintOffset, "Byte offset the checksum fingerprint starts at, to tell apart files sharing a header")
 	cmd.Flags().StringVarP(&c.Collector.FingerprintStrategy, "fingerprint-strategy", "f", c.Collector.FingerprintStrategy,
 		fmt.Sprintf("Fingerprint strategy (%s or %s)",
 			freader.FingerprintStrategyChecksum,
 			freader.FingerprintStrategyDeviceAndInode))
+	cmd.Flags().BoolVar(&c.Collector.NetworkFS, "network-fs", c.Collector.NetworkFS, "NFS/SMB safety mode: force checksum fingerprints and retry files that briefly look missing or changed")
+	cmd.Flags().IntVar(&c.Collector.NetworkFSRetries, "network-fs-retries", c.Collector.NetworkFSRetries, "Consecutive failed scans/reads before a file is dropped in --network-fs mode (0 = 3)")
+	cmd.Flags().DurationVar(&c.Collector.HoldDeleted, "hold-deleted", c.Collector.HoldDeleted, "Keep files open and read files deleted while their writer still has them open to their end, releasing them after this long without new data; 0 disables")
+	cmd.Flags().BoolVar(&c.Collector.KeepDuplicates, "keep-duplicates", c.Collector.KeepDuplicates, "Read a file reachable at several watched paths (hard links, bind mounts, symlinks, identical checksums) once per path instead of once")
+	cmd.Flags().BoolVar(&c.Collector.SkipLocked, "skip-locked", c.Collector.SkipLocked, "Quietly skip files another process holds locked (Windows) until it releases them, instead of reporting them as read errors")
 	cmd.Flags().IntVarP(&c.Collector.WorkerCount, "workers", "w", c.Collector.WorkerCount, "Number of worker goroutines")
+	cmd.Flags().IntVar(&c.Collector.ReadBufferSize, "read-buffer-size", c.Collector.ReadBufferSize, "Bytes read per syscall from each file (0 = 4KB); raise for very long records")
+	cmd.Flags().IntVar(&c.Collector.ChunkBufferSize, "chunk-buffer-size", c.Collector.ChunkBufferSize, "Initial capacity of the per-file record buffer (0 = 4KB)")
+	cmd.Flags().Int64Var(&c.Collector.CatchUpChunkSize, "catch-up-chunk-size", c.Collector.CatchUpChunkSize, "Read files at least two chunks behind on their first read in chunks of this many bytes in parallel; 0 disables")
+	cmd.Flags().IntVar(&c.Collector.CatchUpReaders, "catch-up-readers", c.Collector.CatchUpReaders, "Parallel chunk readers per file with --catch-up-chunk-size (0 = 4)")
+	cmd.Flags().Int64Var(&c.Collector.StartupReadRateLimit, "startup-read-rate-limit", c.Collector.StartupReadRateLimit, "Cap the combined read rate, in bytes per second, of the first pass over files present at startup; 0 disables")
+	cmd.Flags().DurationVar(&c.Collector.StartupStagger, "startup-stagger", c.Collector.StartupStagger, "Start the first pass over files present at startup one at a time, this far apart; 0 disables")
+	cmd.Flags().DurationVar(&c.Collector.IdleAfter, "idle-after", c.Collector.IdleAfter, "Read files without new data for this long only every --idle-recheck-interval; 0 disables")
+	cmd.Flags().DurationVar(&c.Collector.IdleRecheckInterval, "idle-recheck-interval", c.Collector.IdleRecheckInterval, "How often files idle for --idle-after are read (0 = 1s)")
 	cmd.Flags().StringVar(&c.Collector.DBPath, "db-path", c.Collector.DBPath, "Path to offsets SQLite DB (when --store-offsets)")
 	cmd.Flags().BoolVar(&c.Collector.StoreOffsets, "store-offsets", c.Collector.StoreOffsets, "Store and restore offsets across restarts")
+	cmd.Flags().DurationVar(&c.Collector.StoreMaintenanceInterval, "store-maintenance-interval", c.Collector.StoreMaintenanceInterval, "How often to checkpoint the offsets DB write-ahead log and vacuum the DB; 0 disables")
+	cmd.Flags().StringVar(&c.Collector.PostgresDSN, "postgres-dsn", c.Collector.PostgresDSN, "Keep offsets in this PostgreSQL database instead of --db-path")
+	cmd.Flags().StringVar(&c.Collector.PostgresDriver, "postgres-driver", c.Collector.PostgresDriver, "database/sql driver name for --postgres-dsn (default pgx)")
+	cmd.Flags().StringVar(&c.Collector.PostgresNamespace, "postgres-namespace", c.Collector.PostgresNamespace, "Namespace of this collector's offsets and lease in --postgres-dsn, e.g. the host name; collectors reading the same files share one")
+	cmd.Flags().DurationVar(&c.Collector.LeaseTTL, "lease-ttl", c.Collector.LeaseTTL, "Hold an exclusive lease on the offsets DB, renewed every third of this; another instance is refused until it expires. 0 disables")
+	cmd.Flags().BoolVar(&c.Collector.Standby, "standby", c.Collector.Standby, "Run as a redundant instance: stand by while another instance holds the lease and take over reading when it expires")
+	cmd.Flags().StringVar(&c.KubernetesLease, "kubernetes-lease", c.KubernetesLease, "Hold the lease in this Kubernetes Lease, [namespace/]name, instead of the offsets DB (for --standby across pods)")
+	cmd.Flags().IntVar(&c.Collector.ShardIndex, "shard-index", c.Collector.ShardIndex, "This instance's shard, from 0 to --shard-count - 1")
+	cmd.Flags().IntVar(&c.Collector.ShardCount, "shard-count", c.Collector.ShardCount, "Split the matched files between this many instances by consistent hashing of their identity. 0 disables")
+	cmd.Flags().BoolVar(&c.Collector.ShardDiscovery, "shard-discovery", c.Collector.ShardDiscovery, "Split the matched files between the instances registered in the shared offsets store instead of a fixed --shard-count")
+	cmd.Flags().DurationVar(&c.Collector.ShardMemberTTL, "shard-member-ttl", c.Collector.ShardMemberTTL, "Drop a --shard-discovery member that has not heartbeated for this long (default 15s)")
+	cmd.Flags().StringVar(&c.Collector.InstanceID, "instance-id", c.Collector.InstanceID, "Name of this instance in the offsets DB lease (default <hostname>-<pid>-<random>)")
+	cmd.Flags().BoolVar(&c.Collector.RebuildCorruptStore, "rebuild-corrupt-store", c.Collector.RebuildCorruptStore, "If the offsets DB fails its integrity check on startup, move it aside and rebuild it from the readable offsets instead of exiting")
+	cmd.Flags().StringVar(&c.Collector.OffsetMismatch, "offset-mismatch", c.Collector.OffsetMismatch, "For stored offsets past the end of their file or whose fingerprint changed: start (read from the start), end (skip to the end) or quarantine (leave unread)")
+	cmd.Flags().DurationVar(&c.Collector.MergeWindow, "merge-window", c.Collector.MergeWindow, "Deliver the records of all files ordered by event time, holding each this long for later-read earlier records (needs a parser timestamp source); 0 disables")
+	cmd.Flags().DurationVar(&c.Collector.CallbackTimeout, "callback-timeout", c.Collector.CallbackTimeout, "Log, count and report a record callback (sink enqueue) blocked for this long as stalled; 0 disables")
+	cmd.Flags().BoolVar(&c.Collector.SkipStalledCallbacks, "skip-stalled-callbacks", c.Collector.SkipStalledCallbacks, "Drop records instead of waiting while a callback is stalled; needs --callback-timeout")
+	cmd.Flags().DurationVar(&c.Collector.RepeatWindow, "repeat-window", c.Collector.RepeatWindow, "Collapse identical consecutive records of a file within this window into one \"message repeated N times\" record; 0 disables")
+	cmd.Flags().BoolVar(&c.Collector.TraceScans, "trace-scans", c.Collector.TraceScans, "Record why each scanned file was included, excluded or skipped; served with --prometheus.debug at /debug/freader")
+	cmd.Flags().IntVar(&c.Collector.RetainLastN, "retain-last-n", c.Collector.RetainLastN, "Keep the last N records in memory, served at /recent on the metrics endpoint (?file=glob&contains=text&limit=n)")
+	cmd.Flags().BoolVar(&c.Collector.FollowName, "follow-name", c.Collector.FollowName, "Follow files by name like tail -F: read only the file currently at each path, never rotated copies")
+	cmd.Flags().BoolVar(&c.Collector.FromBeginning, "from-beginning", c.Collector.FromBeginning, "Ignore stored offsets on startup and re-read files from the beginning")
+	cmd.Flags().StringSliceVar(&c.Collector.FromBeginningPatterns, "from-beginning-pattern", c.Collector.FromBeginningPatterns, "Only replay files matching these patterns (implies --from-beginning)")
+
+	cmd.Flags().StringVar(&c.StartFromTime, "start-from-time", c.StartFromTime, "Skip records older than this RFC3339 time in files read from the beginning (needs parser.timestamp-pattern, parser.timestamp-field or parser.type auditd, cri or docker-json)")
+
+	cmd.Flags().StringVar(&c.Preset, "preset", c.Preset, "Node agent preset: kubernetes-node (/var/log/containers, CRI parser) or docker-node (/var/lib/docker/containers, docker-json parser)")
+	cmd.Flags().StringVar(&c.LogFormat, "log-format", c.LogFormat, "Log format: auto (journal when run by systemd, else text), text, json or journal")
+	cmd.Flags().BoolVar(&c.Once, "once", c.Once, "Read all matching files to their end, flush the sink, store offsets and exit (for cron-style batch runs)")
+	cmd.Flags().DurationVar(&c.ExitAfterIdle, "exit-after-idle", c.ExitAfterIdle, "Exit cleanly when no new data has arrived from any tracked file for this long (e.g. 5m); 0 disables")
+	cmd.Flags().BoolVar(&c.DryRun, "dry-run", c.DryRun, "Discover, read and parse as configured but count records instead of delivering them to the sink, without storing offsets; reports rates and parse error ratios")
+	cmd.Flags().DurationVar(&c.ProgressInterval, "progress-interval", c.ProgressInterval, "How often --once, --from-beginning and --start-from-time runs log per-file progress and ETA, and --dry-run its counts; 0 disables")
 
 	// Sink-related options are intentionally not exposed as command-line flags.
 	// Configure sink forwarding (type, filters, batching, and backend credentials)
@@ -184,54 +405,219 @@ func (c *Config) SetupFlags(cmd *cobra.Command) {
 	// Prometheus flags
 	cmd.Flags().BoolVar(&c.Prometheus.Enable, "prometheus.enable", c.Prometheus.Enable, "Enable Prometheus metrics HTTP endpoint")
 	cmd.Flags().StringVar(&c.Prometheus.Addr, "prometheus.addr", c.Prometheus.Addr, "Prometheus metrics listen address (e.g., :2112)")
+	cmd.Flags().StringVar(&c.Prometheus.TLSCertFile, "prometheus.tls-cert-file", c.Prometheus.TLSCertFile, "Serve metrics over HTTPS with this PEM certificate (needs --prometheus.tls-key-file)")
+	cmd.Flags().StringVar(&c.Prometheus.TLSKeyFile, "prometheus.tls-key-file", c.Prometheus.TLSKeyFile, "PEM private key for --prometheus.tls-cert-file")
+	cmd.Flags().StringVar(&c.Prometheus.BasicAuthUser, "prometheus.basic-auth-user", c.Prometheus.BasicAuthUser, "Require HTTP basic auth with this user on the metrics endpoint")
+	cmd.Flags().StringVar(&c.Prometheus.BasicAuthPassword, "prometheus.basic-auth-password", c.Prometheus.BasicAuthPassword, "Password for --prometheus.basic-auth-user (prefer FREADER_PROMETHEUS_BASIC_AUTH_PASSWORD)")
+	cmd.Flags().StringVar(&c.Prometheus.BearerToken, "prometheus.bearer-token", c.Prometheus.BearerToken, "Require this bearer token on the metrics endpoint (prefer FREADER_PROMETHEUS_BEARER_TOKEN)")
+	cmd.Flags().BoolVar(&c.Prometheus.Debug, "prometheus.debug", c.Prometheus.Debug, "Also serve tracked files, offsets and recent watcher decisions as JSON at /debug/freader and expvar at /debug/vars")
+	cmd.Flags().StringToStringVar(&c.Prometheus.Labels, "prometheus.labels", c.Prometheus.Labels, "Constant labels added to every metric, e.g. instance=node-1,pipeline=audit")
+
+	// Live stream flags
+	cmd.Flags().BoolVar(&c.Live.Enable, "live.enable", c.Live.Enable, "Stream records to browsers and WebSocket/SSE clients (page at /, streams at /sse and /ws)")
+	cmd.Flags().StringVar(&c.Live.Addr, "live.addr", c.Live.Addr, "Live stream listen address")
+	cmd.Flags().StringVar(&c.Live.BearerToken, "live.bearer-token", c.Live.BearerToken, "Require this token as a bearer header or token query parameter on the live stream (prefer FREADER_LIVE_BEARER_TOKEN); required unless --live.addr is a loopback address")
+	cmd.Flags().StringSliceVar(&c.Live.AllowedOrigins, "live.allowed-origins", c.Live.AllowedOrigins, "Origins of other web pages allowed to open the WebSocket stream (e.g. https://dashboard.example.com, or * for any)")
 }
 
-// Validate checks if the configuration is valid
-func (c *Config) Validate() error {
-	// Sink validation
-	switch c.Sink.Type {
-	case "", "console", "file", "clickhouse", "opensearch":
+// Validate checks the sink section; it is also used when the sink is reloaded.
+func (s SinkConfig) Validate() error {
+	switch s.Type {
+	case "", "console", "file", "exec", "unix", "grpc", "clickhouse", "opensearch", "influxdb", "sql":
 		// ok
 	default:
-		return fmt.Errorf("invalid sink.type: %s", c.Sink.Type)
+		return fmt.Errorf("invalid sink.type: %s", s.Type)
 	}
-	if c.Sink.Type != "" {
-		if c.Sink.BatchSize <= 0 {
+	if s.Type != "" {
+		if _, err := compileRecordFilter(s); err != nil {
+			return err
+		}
+		if _, err := compileRecordHeaders(s); err != nil {
+			return err
+		}
+		if len(s.Headers) > 0 && s.Type != "grpc" {
+			return fmt.Errorf("sink.headers is only supported by the grpc sink; the %s sink has no per-record metadata", s.Type)
+		}
+		if _, err := compileRecordRoute(s); err != nil {
+			return err
+		}
+		if err := common.ValidateFilters(s.Include); err != nil {
+			return fmt.Errorf("sink.include: %w", err)
+		}
+		if err := common.ValidateFilters(s.Exclude); err != nil {
+			return fmt.Errorf("sink.exclude: %w", err)
+		}
+		if s.BatchSize <= 0 {
 			return fmt.Errorf("sink.batch-size must be > 0")
 		}
-		if c.Sink.BatchInterval <= 0 {
+		if s.BatchBytes < 0 {
+			return fmt.Errorf("sink.batch-bytes must be >= 0")
+		}
+		if s.BatchInterval <= 0 {
 			return fmt.Errorf("sink.batch-interval must be > 0")
 		}
+		if s.Concurrency < 0 {
+			return fmt.Errorf("sink.concurrency must be >= 0")
+		}
+		if s.Retries < 0 {
+			return fmt.Errorf("sink.retries must be >= 0")
+		}
+		if s.RetryBackoff < 0 {
+			return fmt.Errorf("sink.retry-backoff must be >= 0")
+		}
 		// Delegate sink-specific validations to each sink config
-		switch c.Sink.Type {
+		switch s.Type {
 		case "console":
-			if err := c.Sink.Console.Validate(); err != nil {
+			if err := s.Console.Validate(); err != nil {
 				return err
 			}
 		case "file":
-			if err := c.Sink.File.Validate(); err != nil {
+			if err := s.File.Validate(); err != nil {
+				return err
+			}
+		case "exec":
+			if err := s.Exec.Validate(); err != nil {
+				return err
+			}
+		case "unix":
+			if err := s.Unix.Validate(); err != nil {
+				return err
+			}
+		case "grpc":
+			if err := s.Grpc.Validate(); err != nil {
 				return err
 			}
 		case "clickhouse":
-			if err := c.Sink.ClickHouse.Validate(); err != nil {
+			if err := s.ClickHouse.Validate(); err != nil {
 				return err
 			}
 		case "opensearch":
-			if err := c.Sink.OpenSearch.Validate(); err != nil {
+			if err := s.OpenSearch.Validate(); err != nil {
+				return err
+			}
+		case "influxdb":
+			if err := s.InfluxDB.Validate(); err != nil {
+				return err
+			}
+			if _, err := compileInfluxMapping(s); err != nil {
+				return err
+			}
+		case "sql":
+			if err := s.SQL.Validate(); err != nil {
 				return err
 			}
 		}
 	}
+	return nil
+}
+
+// Validate checks if the configuration is valid
+func (c *Config) Validate() error {
+	if err := c.Sink.Validate(); err != nil {
+		return err
+	}
 
 	// Basic validation for prometheus addr if enabled
 	if c.Prometheus.Enable && c.Prometheus.Addr == "" {
 		return fmt.Errorf("prometheus.addr must be set when prometheus.enable is true")
 	}
+	if err := c.Prometheus.Validate(); err != nil {
+		return err
+	}
+	if err := c.Live.Validate(); err != nil {
+		return err
+	}
+
+	switch c.Parser.Type {
+	case "", "auditd", parserTypeCRI, parserTypeDockerJSON:
+		// ok
+	default:
+		return fmt.Errorf("invalid parser.type: %s", c.Parser.Type)
+	}
+	if _, err := compileFieldRules(c.Parser.Fields); err != nil {
+		return err
+	}
+	for i, p := range c.Parser.Plugins {
+		if err := p.validate(i); err != nil {
+			return err
+		}
+	}
 
-	// Validate nested collector as well
-	if err := c.Collector.Validate(); err != nil {
+	tsFunc, err := c.Parser.timestampFunc()
+	if err != nil {
+		return err
+	}
+	if c.StartFromTime != "" {
+		if _, err := time.Parse(time.RFC3339Nano, c.StartFromTime); err != nil {
+			return fmt.Errorf("invalid start-from-time: %w", err)
+		}
+		if tsFunc == nil {
+			return fmt.Errorf("start-from-time requires parser.timestamp-pattern, parser.timestamp-field or parser.type auditd, cri or docker-json")
+		}
+	}
+	if c.Collector.MergeWindow > 0 && tsFunc == nil {
+		return fmt.Errorf("merge-window requires parser.timestamp-pattern, parser.timestamp-field or parser.type auditd, cri or docker-json")
+	}
+	if c.Collector.MergeWindow > 0 && c.Sink.Type == "grpc" {
+		return fmt.Errorf("merge-window is not supported with sink.type grpc, which stores offsets only once batches are acknowledged")
+	}
+
+	if _, err := newLogHandler(c.LogFormat, nil); err != nil {
+		return err
+	}
+
+	if err := c.Discovery.Docker.Validate(); err != nil {
+		return err
+	}
+	if err := c.validateLabelFilters(); err != nil {
+		return err
+	}
+	if c.ExitAfterIdle < 0 {
+		return fmt.Errorf("exit-after-idle must be >= 0")
+	}
+	if c.ProgressInterval < 0 {
+		return fmt.Errorf("progress-interval must be >= 0")
+	}
+	if c.KubernetesLease != "" && c.Collector.LeaseTTL <= 0 {
+		return fmt.Errorf("kubernetes-lease requires a positive lease-ttl")
+	}
+	if c.KubernetesLease != "" && (c.Collector.ShardCount > 0 || c.Collector.ShardDiscovery) {
+		return fmt.Errorf("kubernetes-lease cannot be combined with shard-count or shard-discovery")
+	}
+	if c.DryRun && c.Collector.ShardDiscovery {
+		return fmt.Errorf("dry-run cannot be combined with shard-discovery, which registers the collector in the offsets DB")
+	}
+	if c.Once && c.Discovery.Docker.Enable {
+		return fmt.Errorf("once cannot be combined with discovery.docker; list the container log files in collector.include instead")
+	}
+
+	// Validate nested collector as well; main sets its timestamp function from the parser
+	collectorCfg := c.Collector
+	if collectorCfg.TimestampFunc == nil {
+		collectorCfg.TimestampFunc = tsFunc
+	}
+	if err := collectorCfg.Validate(); err != nil {
 		return fmt.Errorf("invalid collector config: %w", err)
 	}
 
 	return nil
 }
+
+// validateLabelFilters rejects label: entries in sink.include/sink.exclude unless
+// Docker discovery, the only source of record labels, is enabled. Without it no record
+// has labels, so such an entry would silently match nothing.
+func (c *Config) validateLabelFilters() error {
+	if c.Discovery.Docker.Enable {
+		return nil
+	}
+	if common.UsesLabels(c.Sink.Include) || common.UsesLabels(c.Sink.Exclude) {
+		return fmt.Errorf("label: entries in sink.include/sink.exclude require discovery.docker.enable, as records carry no labels without it")
+	}
+	return nil
+}
+
+// backfill reports whether this run catches up on existing data rather than only
+// tailing new writes, which is when progress is reported.
+func (c *Config) backfill() bool {
+	return c.Once || c.Collector.FromBeginning || len(c.Collector.FromBeginningPatterns) > 0 || c.StartFromTime != ""
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"encoding/binary"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/loykin/freader"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func TestDefaultConfigAndValidate(t *testing.T) {
@@ -53,6 +58,62 @@ func TestValidate_SinkTypes(t *testing.T) {
 	if err := cfg2.Validate(); err != nil {
 		t.Fatalf("unexpected error for valid file sink: %v", err)
 	}
+
+	// Exec sink requires a command
+	cfg3 := DefaultConfig()
+	cfg3.Sink.Type = "exec"
+	if err := cfg3.Validate(); err == nil {
+		t.Fatal("expected error when sink.type=exec and sink.exec.command is empty")
+	}
+	cfg3.Sink.Exec.Command = []string{"cat"}
+	if err := cfg3.Validate(); err != nil {
+		t.Fatalf("unexpected error for valid exec sink: %v", err)
+	}
+	cfg3.Sink.Exec.RestartBackoff = -time.Second
+	if err := cfg3.Validate(); err == nil {
+		t.Fatal("expected error for negative sink.exec.restart-backoff")
+	}
+
+	// Unix socket sink requires a path and a known mode
+	cfg4 := DefaultConfig()
+	cfg4.Sink.Type = "unix"
+	if err := cfg4.Validate(); err == nil {
+		t.Fatal("expected error when sink.type=unix and sink.unix.path is empty")
+	}
+	cfg4.Sink.Unix.Path = "/tmp/freader.sock"
+	cfg4.Sink.Unix.Mode = "datagram"
+	if err := cfg4.Validate(); err != nil {
+		t.Fatalf("unexpected error for valid unix sink: %v", err)
+	}
+
+	// gRPC sink requires a target
+	cfg5 := DefaultConfig()
+	cfg5.Sink.Type = "grpc"
+	if err := cfg5.Validate(); err == nil {
+		t.Fatal("expected error when sink.type=grpc and sink.grpc.target is empty")
+	}
+	cfg5.Sink.Grpc.Target = "localhost:9000"
+	if err := cfg5.Validate(); err != nil {
+		t.Fatalf("unexpected error for valid grpc sink: %v", err)
+	}
+}
+
+func TestValidate_LabelFilters(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Sink.Type = "console"
+	cfg.Sink.Include = []string{"file:/var/log/audit/*", "label:compliance=true"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a label: include without docker discovery")
+	}
+	cfg.Sink.Include = []string{"file:/var/log/audit/*"}
+	cfg.Sink.Exclude = []string{"label:debug"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a label: exclude without docker discovery")
+	}
+	cfg.Discovery.Docker.Enable = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error with docker discovery: %v", err)
+	}
 }
 
 func TestLoadFromViper_WithEnvConfigAndFlags(t *testing.T) {
@@ -131,3 +192,299 @@ func TestLoadFromViper_WithEnvConfigAndFlags(t *testing.T) {
 		t.Fatalf("Validate failed after LoadFromViper: %v", err)
 	}
 }
+
+func TestLoadFromViper_SeparatorRules(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[[collector.separator-rules]]
+pattern = "appliance*.log"
+separators = ["\r\n", "\n"]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	cmd := &cobra.Command{Use: "freader-test"}
+	cfg.SetupFlags(cmd)
+	cfg.ConfigFile = path
+	if err := cfg.LoadFromViper(cmd); err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	want := []freader.SeparatorRule{{Pattern: "appliance*.log", Separators: []string{"\r\n", "\n"}}}
+	if !reflect.DeepEqual(cfg.Collector.SeparatorRules, want) {
+		t.Fatalf("separator rules = %#v, want %#v", cfg.Collector.SeparatorRules, want)
+	}
+}
+
+// Escapes are interpreted in --separator only: TOML strings decode their own, so a
+// backslash in a file value is literal.
+func TestLoadFromViper_SeparatorEscapes(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[collector]
+separator = '\d'
+
+[[collector.separator-rules]]
+pattern = "*.log"
+separators = ['\t', "\u0000"]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	load := func(flags ...string) (*Config, error) {
+		viper.Reset()
+		cfg := DefaultConfig()
+		cmd := &cobra.Command{Use: "freader-test"}
+		cfg.SetupFlags(cmd)
+		cfg.ConfigFile = path
+		if err := cmd.Flags().Parse(flags); err != nil {
+			t.Fatalf("parse flags: %v", err)
+		}
+		return cfg, cfg.LoadFromViper(cmd)
+	}
+
+	cfg, err := load()
+	if err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	if cfg.Collector.Separator != `\d` {
+		t.Fatalf("separator = %q, want literal \\d", cfg.Collector.Separator)
+	}
+	want := []freader.SeparatorRule{{Pattern: "*.log", Separators: []string{`\t`, "\x00"}}}
+	if !reflect.DeepEqual(cfg.Collector.SeparatorRules, want) {
+		t.Fatalf("separator rules = %#v, want %#v", cfg.Collector.SeparatorRules, want)
+	}
+
+	if cfg, err = load(`--separator=\0`); err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	if cfg.Collector.Separator != "\x00" {
+		t.Fatalf("separator = %q, want NUL from --separator", cfg.Collector.Separator)
+	}
+	if _, err = load(`--separator=\`); err == nil {
+		t.Fatal("expected an error for a trailing backslash in --separator")
+	}
+}
+
+func TestLoadFromViper_MultilineRules(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[[collector.multiline-rules]]
+pattern = "/var/log/app/*.log"
+java = true
+timeout = "2s"
+
+[[collector.multiline-rules]]
+pattern = "access*.log"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	cmd := &cobra.Command{Use: "freader-test"}
+	cfg.SetupFlags(cmd)
+	cfg.ConfigFile = path
+	if err := cfg.LoadFromViper(cmd); err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	want := []freader.MultilineRule{
+		{Pattern: "/var/log/app/*.log", Multiline: &freader.MultilineReader{
+			Mode:             freader.MultilineReaderModeContinueThrough,
+			StartPattern:     "^(ERROR|WARN|INFO|Exception)",
+			ConditionPattern: "^(\\s|at\\s|Caused by:)",
+			Timeout:          2 * time.Second,
+		}},
+		{Pattern: "access*.log"},
+	}
+	if !reflect.DeepEqual(cfg.Collector.MultilineRules, want) {
+		t.Fatalf("multiline rules = %#v, want %#v", cfg.Collector.MultilineRules, want)
+	}
+	if cfg.Collector.Multiline != nil {
+		t.Fatalf("multiline = %#v, want nil", cfg.Collector.Multiline)
+	}
+}
+
+func TestLoadFromViper_PriorityRules(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[[collector.priority-rules]]
+pattern = "/var/log/app/audit*.log"
+weight = 4
+
+[[collector.priority-rules]]
+pattern = "debug*.log"
+weight = 1
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	cmd := &cobra.Command{Use: "freader-test"}
+	cfg.SetupFlags(cmd)
+	cfg.ConfigFile = path
+	if err := cfg.LoadFromViper(cmd); err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	want := []freader.PriorityRule{
+		{Pattern: "/var/log/app/audit*.log", Weight: 4},
+		{Pattern: "debug*.log", Weight: 1},
+	}
+	if !reflect.DeepEqual(cfg.Collector.PriorityRules, want) {
+		t.Fatalf("priority rules = %#v, want %#v", cfg.Collector.PriorityRules, want)
+	}
+}
+
+func TestLoadFromViper_LengthPrefix(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[collector.length-prefix]
+width = 4
+byte-order = "little"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	cmd := &cobra.Command{Use: "freader-test"}
+	cfg.SetupFlags(cmd)
+	cfg.ConfigFile = path
+	if err := cfg.LoadFromViper(cmd); err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	lp := cfg.Collector.LengthPrefix
+	if lp == nil || lp.Width != 4 || lp.ByteOrder != binary.LittleEndian {
+		t.Fatalf("length prefix = %#v, want width 4 little endian", lp)
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	cfg, err := loadWithArgs(t, "--prometheus.labels", "instance=node-1,pipeline=audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"instance": "node-1", "pipeline": "audit"}
+	if !reflect.DeepEqual(cfg.Prometheus.Labels, want) {
+		t.Fatalf("prometheus.labels = %#v, want %#v", cfg.Prometheus.Labels, want)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "freader.toml")
+	if err := os.WriteFile(path, []byte("[prometheus]\nlabels = { instance = \"node-2\" }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = loadWithArgs(t, "--config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Prometheus.Labels["instance"]; got != "node-2" {
+		t.Fatalf("prometheus.labels.instance from file = %q, want node-2", got)
+	}
+}
+
+func TestPrometheusAuthSettings(t *testing.T) {
+	t.Setenv("FREADER_PROMETHEUS_BEARER_TOKEN", "from-env")
+	cfg, err := loadWithArgs(t, "--prometheus.enable", "--prometheus.basic-auth-user", "prom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Prometheus.BearerToken != "from-env" {
+		t.Fatalf("prometheus.bearer-token = %q, want it from the environment", cfg.Prometheus.BearerToken)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for basic auth user without password")
+	}
+
+	cfg, err = loadWithArgs(t, "--prometheus.enable", "--prometheus.tls-cert-file", "cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a certificate without key")
+	}
+}
+
+func TestLiveSettings(t *testing.T) {
+	t.Setenv("FREADER_LIVE_BEARER_TOKEN", "from-env")
+	cfg, err := loadWithArgs(t, "--live.enable", "--live.addr", "127.0.0.1:9999", "--live.allowed-origins", "https://a.example,https://b.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Live.Enable || cfg.Live.Addr != "127.0.0.1:9999" || cfg.Live.BearerToken != "from-env" ||
+		!reflect.DeepEqual(cfg.Live.AllowedOrigins, []string{"https://a.example", "https://b.example"}) {
+		t.Fatalf("unexpected live config: %+v", cfg.Live)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	cfg, err = loadWithArgs(t, "--live.enable", "--live.addr", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for live.enable without live.addr")
+	}
+
+	// A token is required unless the server only listens on loopback
+	t.Setenv("FREADER_LIVE_BEARER_TOKEN", "")
+	for addr, ok := range map[string]bool{"127.0.0.1:8081": true, "[::1]:8081": true, "localhost:8081": true, ":8081": false, "0.0.0.0:8081": false, "10.0.0.5:8081": false} {
+		cfg, err = loadWithArgs(t, "--live.enable", "--live.addr", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Validate(); (err == nil) != ok {
+			t.Errorf("Validate with live.addr %q = %v", addr, err)
+		}
+	}
+}
+
+func TestValidate_Standby(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Collector.Standby = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("standby with the default lease ttl: %v", err)
+	}
+	cfg.Collector.LeaseTTL = 0
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected an error for standby without a lease ttl")
+	}
+
+	cfg = DefaultConfig()
+	cfg.KubernetesLease = "logging/freader"
+	cfg.Collector.LeaseTTL = 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "kubernetes-lease") {
+		t.Fatalf("Validate = %v, want a kubernetes-lease error", err)
+	}
+}
+
+func TestValidate_Sharding(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Collector.ShardIndex, cfg.Collector.ShardCount = 1, 3
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("shard 1 of 3: %v", err)
+	}
+	cfg.Collector.ShardIndex = 3
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected an error for a shard index past the shard count")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Collector.ShardDiscovery = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("shard discovery: %v", err)
+	}
+	cfg.KubernetesLease = "freader"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "kubernetes-lease") {
+		t.Fatalf("Validate = %v, want a kubernetes-lease error", err)
+	}
+}
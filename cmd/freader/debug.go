@@ -0,0 +1,52 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/loykin/freader"
+)
+
+// debugCollector is the running collector served by the debug endpoints. The metrics
+// server starts before the collector exists, so the handlers look it up per request.
+var debugCollector atomic.Pointer[freader.Collector]
+
+var publishDebugVar sync.Once
+
+// debugHandlers returns the /debug/freader and /debug/vars handlers served next to
+// /metrics with --prometheus.debug.
+func debugHandlers() map[string]http.Handler {
+	publishDebugVar.Do(func() {
+		expvar.Publish("freader", expvar.Func(func() any {
+			if c := debugCollector.Load(); c != nil {
+				return c.DebugState()
+			}
+			return nil
+		}))
+	})
+	return map[string]http.Handler{
+		"/debug/freader": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := debugCollector.Load()
+			if c == nil {
+				http.Error(w, "collector not started", http.StatusServiceUnavailable)
+				return
+			}
+			c.DebugHandler().ServeHTTP(w, r)
+		}),
+		"/debug/vars": expvar.Handler(),
+	}
+}
+
+// recentHandler serves the records kept with --retain-last-n at /recent, next to /metrics.
+func recentHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := debugCollector.Load()
+		if c == nil {
+			http.Error(w, "collector not started", http.StatusServiceUnavailable)
+			return
+		}
+		c.RecentHandler().ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loykin/freader"
+)
+
+func TestDebugHandlers(t *testing.T) {
+	cfg, err := loadWithArgs(t, "--prometheus.debug", "--include", t.TempDir(),
+		"--fingerprint-strategy", "deviceAndInode", "--db-path", t.TempDir()+"/offsets.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Prometheus.Debug {
+		t.Fatal("prometheus.debug not set by flag")
+	}
+
+	handlers := debugHandlers()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handlers[path].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get("/debug/freader"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("before the collector exists: status %d, want 503", rec.Code)
+	}
+
+	c, err := freader.NewCollector(cfg.Collector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	debugCollector.Store(c)
+	defer debugCollector.Store(nil)
+
+	rec := get("/debug/freader")
+	var st freader.DebugState
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, decode error %v", rec.Code, err)
+	}
+	if st.FingerprintStrategy != "deviceAndInode" {
+		t.Fatalf("fingerprint_strategy = %q", st.FingerprintStrategy)
+	}
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(get("/debug/vars").Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vars["freader"]; !ok {
+		t.Fatal("expvar freader not published")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/loykin/freader"
+	cmddocker "github.com/loykin/freader/cmd/freader/discovery/docker"
+	"github.com/loykin/freader/pkg/parser/container"
+)
+
+// dockerDiscovery adds the log files of running containers to the collector's includes
+// and removes them when the containers stop. A nil *dockerDiscovery is disabled.
+type dockerDiscovery struct {
+	d      *cmddocker.Discoverer
+	cancel context.CancelFunc
+}
+
+func newDockerDiscovery(cfg cmddocker.Config) (*dockerDiscovery, error) {
+	if !cfg.Enable {
+		return nil, nil
+	}
+	d, err := cmddocker.NewDiscoverer(cfg, slog.Default())
+	if err != nil {
+		return nil, err
+	}
+	return &dockerDiscovery{d: d}, nil
+}
+
+// start polls the Docker API in the background. Includes of stopped containers are
+// removed after two poll intervals so the collector can read their last lines first.
+func (dd *dockerDiscovery) start(c *freader.Collector, pollInterval time.Duration) {
+	if dd == nil {
+		return
+	}
+	dd.d.OnAdd = func(ct cmddocker.Container) {
+		if err := c.AddInclude(ct.LogPath); err != nil {
+			slog.Warn("failed to tail container log", "container", ct.Name, "path", ct.LogPath, "error", err)
+		}
+	}
+	dd.d.OnRemove = func(ct cmddocker.Container) {
+		time.AfterFunc(2*pollInterval, func() { c.RemoveInclude(ct.LogPath) })
+	}
+	var ctx context.Context
+	ctx, dd.cancel = context.WithCancel(context.Background())
+	go dd.d.Run(ctx)
+}
+
+func (dd *dockerDiscovery) stop() {
+	if dd != nil && dd.cancel != nil {
+		dd.cancel()
+	}
+}
+
+// labels returns the labels of the container writing path, or nil when it is unknown.
+func (dd *dockerDiscovery) labels(path string) map[string]string {
+	if dd == nil {
+		return nil
+	}
+	ct, _ := dd.d.Lookup(path)
+	return ct.Labels
+}
+
+// enrich adds the name, image and labels of the container writing path.
+func (dd *dockerDiscovery) enrich(path string, md *container.Metadata) {
+	if dd == nil {
+		return
+	}
+	if ct, ok := dd.d.Lookup(path); ok {
+		md.ContainerID = ct.ID
+		md.Name = ct.Name
+		md.Image = ct.Image
+		md.Labels = ct.Labels
+	}
+}
@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultHost is the Docker Engine API endpoint used when Config.Host is empty.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// DefaultInterval is how often running containers are listed when Config.Interval is 0.
+const DefaultInterval = 10 * time.Second
+
+// Config holds Docker container discovery settings.
+type Config struct {
+	Enable bool `mapstructure:"enable"`
+	// Engine API endpoint: unix:///path/to/docker.sock, tcp://host:port or http(s)://host:port
+	Host string `mapstructure:"host"`
+	// Label filters a container must match, "key" or "key=value" (all must match)
+	Labels   []string      `mapstructure:"labels"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+func (c Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("discovery.docker.interval must be >= 0")
+	}
+	if c.Host != "" {
+		if _, _, err := parseHost(c.Host); err != nil {
+			return err
+		}
+	}
+	for _, l := range c.Labels {
+		if strings.TrimSpace(l) == "" || strings.HasPrefix(l, "=") {
+			return fmt.Errorf("discovery.docker.labels: invalid filter %q (use key or key=value)", l)
+		}
+	}
+	return nil
+}
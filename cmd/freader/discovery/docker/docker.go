@@ -0,0 +1,259 @@
+// Package docker discovers running containers through the Docker Engine API so their
+// json-file logs can be tailed and records enriched with container name, image and labels.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Container is a running container with a log file on the local host.
+type Container struct {
+	ID      string
+	Name    string
+	Image   string
+	Labels  map[string]string
+	LogPath string // empty unless the container uses the json-file logging driver
+}
+
+// Client is a minimal Docker Engine API client speaking plain HTTP, over the daemon's
+// unix socket or TCP.
+type Client struct {
+	http *http.Client
+	base string
+}
+
+// NewClient returns a client for host (see Config.Host; "" selects DefaultHost).
+func NewClient(host string) (*Client, error) {
+	if host == "" {
+		host = DefaultHost
+	}
+	network, addr, err := parseHost(host)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{http: &http.Client{Timeout: 30 * time.Second}}
+	switch network {
+	case "unix":
+		// The host part of the URL is ignored; every request dials the socket
+		c.base = "http://docker"
+		c.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", addr)
+			},
+		}
+	default:
+		c.base = network + "://" + addr
+	}
+	return c, nil
+}
+
+// parseHost splits a Docker host URL into the scheme to dial and its address.
+func parseHost(host string) (network, addr string, err error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("invalid docker host %q: missing socket path", host)
+		}
+		return "unix", u.Path, nil
+	case "tcp", "http":
+		return "http", u.Host, nil
+	case "https":
+		return "https", u.Host, nil
+	default:
+		return "", "", fmt.Errorf("invalid docker host %q: scheme must be unix, tcp, http or https", host)
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		var msg struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&msg)
+		return fmt.Errorf("docker api %s: %s %s", path, resp.Status, msg.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Containers lists running containers matching all label filters ("key" or
+// "key=value") and inspects each for its log path.
+func (c *Client) Containers(ctx context.Context, labels []string) ([]Container, error) {
+	query := url.Values{}
+	if len(labels) > 0 {
+		filters, _ := json.Marshal(map[string][]string{"label": labels})
+		query.Set("filters", string(filters))
+	}
+	var list []struct {
+		ID string `json:"Id"`
+	}
+	if err := c.get(ctx, "/containers/json", query, &list); err != nil {
+		return nil, err
+	}
+
+	out := make([]Container, 0, len(list))
+	for _, item := range list {
+		var info struct {
+			ID      string `json:"Id"`
+			Name    string `json:"Name"`
+			LogPath string `json:"LogPath"`
+			Config  struct {
+				Image  string            `json:"Image"`
+				Labels map[string]string `json:"Labels"`
+			} `json:"Config"`
+		}
+		if err := c.get(ctx, "/containers/"+item.ID+"/json", nil, &info); err != nil {
+			// The container may have exited between list and inspect
+			continue
+		}
+		out = append(out, Container{
+			ID:      info.ID,
+			Name:    strings.TrimPrefix(info.Name, "/"),
+			Image:   info.Config.Image,
+			Labels:  info.Config.Labels,
+			LogPath: info.LogPath,
+		})
+	}
+	return out, nil
+}
+
+// Discoverer polls the Docker API and reports containers as they start and stop.
+type Discoverer struct {
+	client   *Client
+	labels   []string
+	interval time.Duration
+	logger   *slog.Logger
+
+	// OnAdd and OnRemove are called from Run for containers with a log path.
+	OnAdd    func(Container)
+	OnRemove func(Container)
+
+	mu     sync.RWMutex
+	byID   map[string]Container
+	byPath map[string]Container
+}
+
+// NewDiscoverer returns a Discoverer for cfg; call Run to start polling.
+func NewDiscoverer(cfg Config, logger *slog.Logger) (*Discoverer, error) {
+	client, err := NewClient(cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Discoverer{
+		client:   client,
+		labels:   cfg.Labels,
+		interval: interval,
+		logger:   logger,
+		byID:     make(map[string]Container),
+		byPath:   make(map[string]Container),
+	}, nil
+}
+
+// Run syncs the container set immediately and then every interval until ctx is done.
+func (d *Discoverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		if err := d.Sync(ctx); err != nil && ctx.Err() == nil {
+			d.logger.Warn("docker discovery failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync lists running containers once and reports the changes since the last sync.
+func (d *Discoverer) Sync(ctx context.Context) error {
+	containers, err := d.client.Containers(ctx, d.labels)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(containers))
+	var added, removed []Container
+
+	d.mu.Lock()
+	for _, ct := range containers {
+		if ct.LogPath == "" {
+			if _, known := d.byID[ct.ID]; !known {
+				d.logger.Warn("container has no log file; only the json-file logging driver is supported", "container", ct.Name)
+				d.byID[ct.ID] = ct
+			}
+			seen[ct.ID] = true
+			continue
+		}
+		seen[ct.ID] = true
+		if _, known := d.byID[ct.ID]; !known {
+			added = append(added, ct)
+		}
+		d.byID[ct.ID] = ct
+		d.byPath[ct.LogPath] = ct
+	}
+	for id, ct := range d.byID {
+		if !seen[id] {
+			delete(d.byID, id)
+			if ct.LogPath != "" {
+				delete(d.byPath, ct.LogPath)
+				removed = append(removed, ct)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	for _, ct := range added {
+		d.logger.Info("docker container discovered", "container", ct.Name, "image", ct.Image, "path", ct.LogPath)
+		if d.OnAdd != nil {
+			d.OnAdd(ct)
+		}
+	}
+	for _, ct := range removed {
+		d.logger.Info("docker container gone", "container", ct.Name, "path", ct.LogPath)
+		if d.OnRemove != nil {
+			d.OnRemove(ct)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the container writing the log file at path.
+func (d *Discoverer) Lookup(path string) (Container, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	ct, ok := d.byPath[path]
+	return ct, ok
+}
@@ -0,0 +1,196 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEngine serves the two Engine API endpoints used by Client.
+type fakeEngine struct {
+	mu         sync.Mutex
+	containers map[string]map[string]any // id -> inspect document
+	filters    string
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/containers/json" {
+		f.filters = r.URL.Query().Get("filters")
+		var list []map[string]string
+		for id := range f.containers {
+			list = append(list, map[string]string{"Id": id})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+	doc, ok := f.containers[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "No such container: " + id})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func (f *fakeEngine) set(id, name, logPath string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.containers == nil {
+		f.containers = make(map[string]map[string]any)
+	}
+	f.containers[id] = map[string]any{
+		"Id":      id,
+		"Name":    "/" + name,
+		"LogPath": logPath,
+		"Config":  map[string]any{"Image": "nginx:1.27", "Labels": map[string]string{"app": name}},
+	}
+}
+
+func (f *fakeEngine) remove(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.containers, id)
+}
+
+// serveUnix starts the fake engine on a unix socket and returns its docker host URL.
+func serveUnix(t *testing.T, h http.Handler) string {
+	t.Helper()
+	// Keep the socket path short; unix socket paths are limited to ~100 bytes
+	dir, err := os.MkdirTemp("", "dk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return "unix://" + sock
+}
+
+func TestDiscoverer_Sync(t *testing.T) {
+	engine := &fakeEngine{}
+	engine.set("aaa", "web", "/var/lib/docker/containers/aaa/aaa-json.log")
+	engine.set("bbb", "syslog", "") // non json-file driver
+
+	d, err := NewDiscoverer(Config{Host: serveUnix(t, engine), Labels: []string{"freader.enable=true"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var added, removed []string
+	d.OnAdd = func(c Container) { added = append(added, c.Name) }
+	d.OnRemove = func(c Container) { removed = append(removed, c.Name) }
+
+	ctx := context.Background()
+	if err := d.Sync(ctx); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if engine.filters != `{"label":["freader.enable=true"]}` {
+		t.Fatalf("filters = %s", engine.filters)
+	}
+	if len(added) != 1 || added[0] != "web" {
+		t.Fatalf("added = %v", added)
+	}
+	ct, ok := d.Lookup("/var/lib/docker/containers/aaa/aaa-json.log")
+	if !ok || ct.Image != "nginx:1.27" || ct.Labels["app"] != "web" {
+		t.Fatalf("lookup = %+v %v", ct, ok)
+	}
+
+	// Unchanged containers are not reported again
+	if err := d.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 {
+		t.Fatalf("added = %v", added)
+	}
+
+	engine.remove("aaa")
+	engine.set("ccc", "api", "/var/lib/docker/containers/ccc/ccc-json.log")
+	if err := d.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 || added[1] != "api" || len(removed) != 1 || removed[0] != "web" {
+		t.Fatalf("added = %v, removed = %v", added, removed)
+	}
+	if _, ok := d.Lookup("/var/lib/docker/containers/aaa/aaa-json.log"); ok {
+		t.Fatal("removed container still found")
+	}
+}
+
+func TestDiscoverer_Run(t *testing.T) {
+	engine := &fakeEngine{}
+	engine.set("aaa", "web", "/logs/aaa-json.log")
+	d, err := NewDiscoverer(Config{Host: serveUnix(t, engine), Interval: 10 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(chan string, 1)
+	d.OnAdd = func(c Container) { found <- c.LogPath }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	select {
+	case p := <-found:
+		if p != "/logs/aaa-json.log" {
+			t.Fatalf("path = %s", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("container not discovered")
+	}
+	cancel()
+	<-done
+}
+
+func TestClient_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message":"daemon unavailable"}`))
+	}))
+	defer srv.Close()
+	c, err := NewClient(strings.Replace(srv.URL, "http://", "tcp://", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Containers(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "daemon unavailable") {
+		t.Fatalf("expected api error, got %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := (Config{}).Validate(); err != nil {
+		t.Fatalf("disabled config must be valid: %v", err)
+	}
+	valid := Config{Enable: true, Host: "unix:///var/run/docker.sock", Labels: []string{"team", "env=prod"}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range []Config{
+		{Enable: true, Host: "ftp://docker"},
+		{Enable: true, Host: "unix://"},
+		{Enable: true, Labels: []string{"=x"}},
+		{Enable: true, Interval: -time.Second},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+}
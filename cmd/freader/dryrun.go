@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// dryRun stands in for the sink on --dry-run: it counts the records the configured sink
+// would have been handed, after sink.include, sink.exclude and sink.filter, instead of
+// delivering them, along with the outcomes of the parser and timestamp extraction. A
+// nil *dryRun counts nothing.
+type dryRun struct {
+	include []string
+	exclude []string
+	now     func() time.Time
+
+	mu        sync.Mutex
+	start     time.Time
+	received  int64 // records that reached the sink stage
+	forwarded int64 // records that passed the sink's filters
+	bytes     int64 // bytes of the forwarded records
+	parsers   map[string]*parseTally
+	last      dryRunTotals // at the previous report, for rates
+}
+
+// parseTally counts the outcomes of one parser.
+type parseTally struct {
+	parsed, failed int64
+}
+
+// dryRunTotals are the counts at one report.
+type dryRunTotals struct {
+	at               time.Time
+	forwarded, bytes int64
+}
+
+func newDryRun(cfg SinkConfig) *dryRun {
+	d := &dryRun{include: cfg.Include, exclude: cfg.Exclude, now: time.Now, parsers: make(map[string]*parseTally)}
+	d.start = d.now()
+	d.last.at = d.start
+	return d
+}
+
+// receive counts a record handed to the sink stage, before its filters.
+func (d *dryRun) receive() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.received++
+}
+
+// observeParse counts one parse outcome of parser.
+func (d *dryRun) observeParse(parser string, ok bool) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.parsers[parser]
+	if t == nil {
+		t = &parseTally{}
+		d.parsers[parser] = t
+	}
+	if ok {
+		t.parsed++
+	} else {
+		t.failed++
+	}
+}
+
+func (d *dryRun) Enqueue(line string) {
+	d.EnqueueEntry(Entry{Line: line, IngestTime: d.now()})
+}
+
+// EnqueueEntry counts e as delivered unless sink.include or sink.exclude reject it.
+func (d *dryRun) EnqueueEntry(e Entry) {
+	if !common.Allowed(e, d.include, d.exclude) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.forwarded++
+	d.bytes += int64(len(e.Line))
+}
+
+func (d *dryRun) Stop() error { return nil }
+
+// run reports every interval until stop is closed.
+func (d *dryRun) run(interval time.Duration, stop <-chan struct{}) {
+	if d == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.report(false)
+		}
+	}
+}
+
+// report logs the records counted so far with the rates since the previous report or,
+// when final, averaged over the whole run, and one line per parser with its error ratio.
+func (d *dryRun) report(final bool) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	since := d.last
+	msg := "dry run"
+	if final {
+		since = dryRunTotals{at: d.start}
+		msg = "dry run finished"
+	}
+	elapsed := now.Sub(since.at).Seconds()
+	rate := func(n, prev int64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(n-prev) / elapsed
+	}
+	slog.Info(msg, "elapsed", now.Sub(d.start).Round(time.Second),
+		"records", d.received, "forwarded", d.forwarded, "filtered", d.received-d.forwarded, "bytes", d.bytes,
+		"records_per_sec", int64(rate(d.forwarded, since.forwarded)), "bytes_per_sec", int64(rate(d.bytes, since.bytes)))
+
+	names := make([]string, 0, len(d.parsers))
+	for name := range d.parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := d.parsers[name]
+		slog.Info(msg+" parser", "parser", name, "parsed", t.parsed, "failed", t.failed,
+			"error_ratio", errorRatio(t.parsed, t.failed))
+	}
+	d.last = dryRunTotals{at: now, forwarded: d.forwarded, bytes: d.bytes}
+}
+
+// errorRatio returns failed out of all outcomes, rounded to four decimals.
+func errorRatio(parsed, failed int64) float64 {
+	if parsed+failed == 0 {
+		return 0
+	}
+	return float64(failed*10000/(parsed+failed)) / 10000
+}
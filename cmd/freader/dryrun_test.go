@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun_Counts(t *testing.T) {
+	d := newDryRun(SinkConfig{Exclude: []string{"file:*.gz"}})
+	for _, e := range []Entry{{Line: "a", File: "/var/log/app.log"}, {Line: "bcd", File: "/var/log/app.log"}, {Line: "old", File: "/var/log/app.log.1.gz"}} {
+		d.receive()
+		d.EnqueueEntry(e)
+	}
+	d.receive() // dropped by sink.filter before reaching the dry run sink
+	for _, ok := range []bool{true, true, true, false} {
+		d.observeParse("cri", ok)
+	}
+	if d.received != 4 || d.forwarded != 2 || d.bytes != 4 {
+		t.Fatalf("received=%d forwarded=%d bytes=%d, want 4, 2, 4", d.received, d.forwarded, d.bytes)
+	}
+	if got := errorRatio(d.parsers["cri"].parsed, d.parsers["cri"].failed); got != 0.25 {
+		t.Fatalf("error ratio = %v, want 0.25", got)
+	}
+	d.report(true)
+
+	var none *dryRun
+	none.receive()
+	none.observeParse("cri", false)
+	none.report(true)
+}
+
+func TestRunCollector_DryRun(t *testing.T) {
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logDir, "app.log"), []byte("first\nsecond\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "out.log")
+	configPath := filepath.Join(outDir, "freader.toml")
+	toml := "[sink]\ntype = \"file\"\n[sink.file]\npath = \"" + out + "\"\n"
+	if err := os.WriteFile(configPath, []byte(toml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(extra ...string) {
+		args := append([]string{"--config", configPath, "--once", "--include", logDir, "--fingerprint-strategy", "deviceAndInode",
+			"--store-offsets", "--db-path", filepath.Join(outDir, "offsets.db")}, extra...)
+		cfg, err := loadWithArgs(t, args...)
+		if err != nil {
+			t.Fatalf("LoadFromViper failed: %v", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		if err := runCollector(cfg, make(chan struct{}), nil); err != nil {
+			t.Fatalf("runCollector failed: %v", err)
+		}
+	}
+
+	run("--dry-run")
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected the dry run not to open the sink, stat = %v", err)
+	}
+	// The dry run stored no offsets, so a real run still delivers everything
+	run()
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); strings.Count(got, "first") != 1 || strings.Count(got, "second") != 1 {
+		t.Fatalf("output after the dry run = %q", got)
+	}
+}
+
+func TestValidate_DryRunWithShardDiscovery(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DryRun = true
+	cfg.Collector.ShardDiscovery = true
+	cfg.Collector.StoreOffsets = true
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "dry-run") {
+		t.Fatalf("Validate error = %v, want dry-run/shard-discovery conflict", err)
+	}
+}
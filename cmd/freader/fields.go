@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldRule copies the value at From to To in JSON object records, or moves it when
+// Move is set. Both are dot paths such as "kubernetes.labels.app", optionally in
+// JSONPath form ("$.kubernetes.labels.app"); From may index arrays ("items[0].name")
+// and keys containing dots are written in brackets ("labels['app.kubernetes.io/name']").
+type FieldRule struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+	Move bool   `mapstructure:"move"`
+}
+
+// pathSegment is an object key or, with isIndex, an array index.
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+type fieldRule struct {
+	from, to []pathSegment
+	move     bool
+}
+
+// fieldRules reshapes JSON records by applying each rule in order.
+type fieldRules []fieldRule
+
+func compileFieldRules(rules []FieldRule) (fieldRules, error) {
+	var out fieldRules
+	for i, r := range rules {
+		from, err := parseFieldPath(r.From)
+		if err != nil {
+			return nil, fmt.Errorf("parser.fields[%d].from: %w", i, err)
+		}
+		to, err := parseFieldPath(r.To)
+		if err != nil {
+			return nil, fmt.Errorf("parser.fields[%d].to: %w", i, err)
+		}
+		for _, seg := range to {
+			if seg.isIndex {
+				return nil, fmt.Errorf("parser.fields[%d].to: array indexes are not supported", i)
+			}
+		}
+		if r.Move && from[len(from)-1].isIndex {
+			return nil, fmt.Errorf("parser.fields[%d]: cannot move an array element", i)
+		}
+		out = append(out, fieldRule{from: from, to: to, move: r.Move})
+	}
+	return out, nil
+}
+
+// parseFieldPath splits a dot path or simple JSONPath into segments.
+func parseFieldPath(s string) ([]pathSegment, error) {
+	if s == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+	p := strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	var segs []pathSegment
+	for len(p) > 0 {
+		switch {
+		case p[0] == '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in %q", s)
+			}
+			inner := p[1:end]
+			if n := len(inner); n >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[n-1] == inner[0] {
+				segs = append(segs, pathSegment{key: inner[1 : n-1]})
+			} else if idx, err := strconv.Atoi(inner); err == nil && idx >= 0 {
+				segs = append(segs, pathSegment{index: idx, isIndex: true})
+			} else {
+				return nil, fmt.Errorf("invalid [%s] in %q", inner, s)
+			}
+			p = p[end+1:]
+		case p[0] == '.':
+			p = p[1:]
+			if p == "" || p[0] == '.' || p[0] == '[' {
+				return nil, fmt.Errorf("empty key in %q", s)
+			}
+		default:
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			segs = append(segs, pathSegment{key: p[:end]})
+			p = p[end:]
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("path %q has no keys", s)
+	}
+	return segs, nil
+}
+
+// reshape applies the rules to a JSON object record. Other records, and records none
+// of the rules changed, are returned as is.
+func (rs fieldRules) reshape(record string) string {
+	dec := json.NewDecoder(strings.NewReader(record))
+	dec.UseNumber() // keep large integers exact
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil || doc == nil {
+		return record
+	}
+	changed := false
+	for _, r := range rs {
+		if r.apply(doc) {
+			changed = true
+		}
+	}
+	if !changed {
+		return record
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return record
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// apply copies or moves one value and reports whether doc changed. A missing source or
+// a destination below a non-object value leaves doc alone.
+func (r fieldRule) apply(doc map[string]any) bool {
+	var parent any = doc
+	for _, seg := range r.from[:len(r.from)-1] {
+		var ok bool
+		if parent, ok = child(parent, seg); !ok {
+			return false
+		}
+	}
+	last := r.from[len(r.from)-1]
+	value, ok := child(parent, last)
+	if !ok {
+		return false
+	}
+
+	obj := doc
+	for _, seg := range r.to[:len(r.to)-1] {
+		next, ok := obj[seg.key]
+		if !ok {
+			m := map[string]any{}
+			obj[seg.key] = m
+			obj = m
+			continue
+		}
+		if obj, ok = next.(map[string]any); !ok {
+			return false
+		}
+	}
+	if r.move {
+		delete(parent.(map[string]any), last.key)
+	}
+	obj[r.to[len(r.to)-1].key] = value
+	return true
+}
+
+func child(v any, seg pathSegment) (any, bool) {
+	if seg.isIndex {
+		arr, ok := v.([]any)
+		if !ok || seg.index >= len(arr) {
+			return nil, false
+		}
+		return arr[seg.index], true
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	val, ok := obj[seg.key]
+	return val, ok
+}
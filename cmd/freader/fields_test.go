@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []pathSegment
+	}{
+		{"app", []pathSegment{{key: "app"}}},
+		{"kubernetes.labels.app", []pathSegment{{key: "kubernetes"}, {key: "labels"}, {key: "app"}}},
+		{"$.items[1].name", []pathSegment{{key: "items"}, {index: 1, isIndex: true}, {key: "name"}}},
+		{"labels['app.kubernetes.io/name']", []pathSegment{{key: "labels"}, {key: "app.kubernetes.io/name"}}},
+		{`$["a b"].c`, []pathSegment{{key: "a b"}, {key: "c"}}},
+	}
+	for _, c := range cases {
+		got, err := parseFieldPath(c.in)
+		if err != nil {
+			t.Fatalf("parseFieldPath(%q): %v", c.in, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("parseFieldPath(%q) = %#v, want %#v", c.in, got, c.want)
+		}
+	}
+	for _, bad := range []string{"", "$", "a..b", "a.", "a[", "a[x]", "a[-1]"} {
+		if _, err := parseFieldPath(bad); err == nil {
+			t.Fatalf("parseFieldPath(%q): expected error", bad)
+		}
+	}
+}
+
+func TestFieldRules_Reshape(t *testing.T) {
+	rules, err := compileFieldRules([]FieldRule{
+		{From: "kubernetes.labels['app.kubernetes.io/name']", To: "app"},
+		{From: "log", To: "message", Move: true},
+		{From: "$.spans[0].id", To: "trace.first_span"},
+		{From: "missing.field", To: "never"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := `{"log":"a<b","kubernetes":{"labels":{"app.kubernetes.io/name":"shop"}},"spans":[{"id":9007199254740993}]}`
+	got := rules.reshape(in)
+	want := `{"app":"shop","kubernetes":{"labels":{"app.kubernetes.io/name":"shop"}},"message":"a<b","spans":[{"id":9007199254740993}],"trace":{"first_span":9007199254740993}}`
+	if got != want {
+		t.Fatalf("reshape =\n%s\nwant\n%s", got, want)
+	}
+
+	// Non-objects and records no rule applies to pass through unchanged
+	for _, rec := range []string{"plain text", `["a"]`, `{"other": 1}`} {
+		if got := rules.reshape(rec); got != rec {
+			t.Fatalf("reshape(%q) = %q", rec, got)
+		}
+	}
+
+	// A destination below a non-object value is skipped
+	rules, _ = compileFieldRules([]FieldRule{{From: "a", To: "b.c"}})
+	if got := rules.reshape(`{"a":1,"b":"x"}`); got != `{"a":1,"b":"x"}` {
+		t.Fatalf("reshape = %q", got)
+	}
+}
+
+func TestCompileFieldRules_Errors(t *testing.T) {
+	cases := map[string]FieldRule{
+		"from":    {To: "x"},
+		"to":      {From: "x"},
+		"indexes": {From: "x", To: "y[0]"},
+		"move":    {From: "x[0]", To: "y", Move: true},
+	}
+	for want, r := range cases {
+		_, err := compileFieldRules([]FieldRule{r})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("compileFieldRules(%+v) = %v, want error mentioning %q", r, err, want)
+		}
+	}
+}
+
+func TestLoadFromViper_FieldRules(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[[parser.fields]]
+from = "kubernetes.labels.app"
+to = "app"
+
+[[parser.fields]]
+from = "log"
+to = "message"
+move = true
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	cmd := &cobra.Command{Use: "freader-test"}
+	cfg.SetupFlags(cmd)
+	cfg.ConfigFile = path
+	if err := cfg.LoadFromViper(cmd); err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	want := []FieldRule{
+		{From: "kubernetes.labels.app", To: "app"},
+		{From: "log", To: "message", Move: true},
+	}
+	if !reflect.DeepEqual(cfg.Parser.Fields, want) {
+		t.Fatalf("fields = %#v, want %#v", cfg.Parser.Fields, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	cfg.Parser.Fields = append(cfg.Parser.Fields, FieldRule{From: "a..b", To: "c"})
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "parser.fields[2].from") {
+		t.Fatalf("Validate = %v, want a parser.fields error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+)
+
+// recordFilterEnv declares the names a sink.filter expression can use: the record
+// envelope and, for JSON object records, their parsed fields.
+var recordFilterEnv = map[string]any{
+	"file":    "",
+	"message": "",
+	"host":    "",
+	"labels":  map[string]any{},
+	"fields":  map[string]any{},
+}
+
+// recordFilter forwards only the records its expression matches.
+type recordFilter struct {
+	prog   *vm.Program
+	host   string
+	labels map[string]any
+	fields bool // the expression refers to fields, so records are parsed
+}
+
+// compileRecordFilter returns the sink's filter, or nil when none is configured.
+func compileRecordFilter(cfg SinkConfig) (*recordFilter, error) {
+	if strings.TrimSpace(cfg.Filter) == "" {
+		return nil, nil
+	}
+	prog, err := expr.Compile(cfg.Filter, expr.Env(recordFilterEnv), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink.filter: %w", err)
+	}
+	names := identifiers(prog)
+	f := &recordFilter{prog: prog, labels: map[string]any{}, fields: names["fields"]}
+	if names["host"] {
+		f.host = cfg.host()
+	}
+	for k, v := range cfg.Labels {
+		f.labels[k] = v
+	}
+	return f, nil
+}
+
+// identifiers returns the names the program refers to.
+func identifiers(prog *vm.Program) map[string]bool {
+	v := identVisitor{}
+	node := prog.Node()
+	ast.Walk(&node, v)
+	return v
+}
+
+type identVisitor map[string]bool
+
+func (v identVisitor) Visit(node *ast.Node) {
+	if id, ok := (*node).(*ast.IdentifierNode); ok {
+		v[id.Value] = true
+	}
+}
+
+// allow evaluates the expression for one record. Records it cannot be evaluated on
+// (e.g. arithmetic on a string field) are dropped.
+func (f *recordFilter) allow(file, line string) bool {
+	env := map[string]any{
+		"file":    file,
+		"message": line,
+		"host":    f.host,
+		"labels":  f.labels,
+		"fields":  map[string]any{},
+	}
+	if f.fields && strings.HasPrefix(strings.TrimSpace(line), "{") {
+		fields := map[string]any{}
+		if json.Unmarshal([]byte(line), &fields) == nil {
+			env["fields"] = fields
+		}
+	}
+	out, err := expr.Run(f.prog, env)
+	if err != nil {
+		slog.Debug("sink.filter failed on record, dropping it", "file", file, "error", err)
+		return false
+	}
+	ok, _ := out.(bool)
+	return ok
+}
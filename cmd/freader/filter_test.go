@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordFilter_Allow(t *testing.T) {
+	cfg := SinkConfig{
+		Filter: `fields.status >= 500 && labels.app == "api" && file endsWith ".log"`,
+		Labels: map[string]string{"app": "api"},
+		Host:   "node-1",
+	}
+	f, err := compileRecordFilter(cfg)
+	if err != nil {
+		t.Fatalf("compileRecordFilter: %v", err)
+	}
+	cases := []struct {
+		file, line string
+		want       bool
+	}{
+		{"/var/log/app.log", `{"status":503}`, true},
+		{"/var/log/app.log", `{"status":200}`, false},
+		{"/var/log/app.txt", `{"status":503}`, false},
+		{"/var/log/app.log", `plain text 503`, false},
+		{"/var/log/app.log", `{"status":"oops"}`, false},
+	}
+	for _, c := range cases {
+		if got := f.allow(c.file, c.line); got != c.want {
+			t.Fatalf("allow(%q, %q) = %v, want %v", c.file, c.line, got, c.want)
+		}
+	}
+
+	f, err = compileRecordFilter(SinkConfig{Filter: `host == "node-1" && message contains "ERROR"`, Host: "node-1"})
+	if err != nil {
+		t.Fatalf("compileRecordFilter: %v", err)
+	}
+	if !f.allow("", "x ERROR y") || f.allow("", "x INFO y") {
+		t.Fatal("envelope filter mismatch")
+	}
+
+	// Evaluation errors drop the record
+	f, _ = compileRecordFilter(SinkConfig{Filter: `fields.n + 1 > 1`})
+	if f.allow("", `{"n":"x"}`) {
+		t.Fatal("expected a record failing evaluation to be dropped")
+	}
+
+	// A missing nested field needs optional chaining
+	f, _ = compileRecordFilter(SinkConfig{Filter: `fields.user?.id == "u1" || fields.level in ["error", "fatal"]`})
+	if !f.allow("", `{"user":{"id":"u1"}}`) || !f.allow("", `{"level":"fatal"}`) || f.allow("", `{"level":"info"}`) {
+		t.Fatal("optional chaining or in mismatch")
+	}
+
+	if f, err := compileRecordFilter(SinkConfig{Filter: "  "}); f != nil || err != nil {
+		t.Fatalf("blank filter = %v, %v; want none", f, err)
+	}
+}
+
+func TestValidate_SinkFilter(t *testing.T) {
+	for _, bad := range []string{`fields.status >=`, `feilds.status == 1`, `message matches "("`} {
+		cfg := DefaultConfig()
+		cfg.Sink.Type = "console"
+		cfg.Sink.Filter = bad
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "sink.filter") {
+			t.Fatalf("Validate(filter %q) = %v, want a sink.filter error", bad, err)
+		}
+	}
+}
+
+func TestSwapSink_Filter(t *testing.T) {
+	dir := t.TempDir()
+	cfg := fileSinkConfig(filepath.Join(dir, "out.log"))
+	cfg.Filter = `fields.level == "error"`
+	built, err := buildSink(&Config{Sink: cfg})
+	if err != nil {
+		t.Fatalf("buildSink: %v", err)
+	}
+	s := newSwapSink(built, cfg)
+	s.EnqueueEntry(Entry{Line: `{"level":"error","n":1}`, File: "a.log"})
+	s.EnqueueEntry(Entry{Line: `{"level":"info","n":2}`, File: "a.log"})
+
+	// A reload replaces the filter along with the sink
+	next := fileSinkConfig(filepath.Join(dir, "next.log"))
+	next.Filter = `fields.n == 4`
+	if err := s.reload(next); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	s.EnqueueEntry(Entry{Line: `{"level":"error","n":3}`, File: "a.log"})
+	s.EnqueueEntry(Entry{Line: `{"level":"info","n":4}`, File: "a.log"})
+	if err := s.Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	var got []string
+	for _, name := range []string{"out.log", "next.log"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		got = append(got, strings.Fields(string(data))...)
+	}
+	if strings.Join(got, ",") != `{"level":"error","n":1},{"level":"info","n":4}` {
+		t.Fatalf("unexpected forwarded records: %v", got)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+
+	"github.com/loykin/freader"
+	"github.com/spf13/cobra"
+)
+
+// gzipMagic starts every gzip stream; such files are searched decompressed.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// newGrepCmd returns the "grep" command, which searches the files the configuration
+// matches. It takes the same collector flags and config file as the root command.
+func newGrepCmd(config *Config) *cobra.Command {
+	var ignoreCase bool
+	cmd := &cobra.Command{
+		Use:   "grep <regexp>",
+		Short: "Search the matched files for records matching a regular expression",
+		Long: `Run a single scan with the configured include/exclude patterns and print
+every record of the matching files that matches the regular expression, as
+path:offset:record. Records are split on the configured separator, and
+gzip-compressed files (e.g. rotated app.log.1.gz) are searched decompressed,
+with offsets into the decompressed data. Files are searched whatever their
+size and stored offsets are ignored.`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return config.LoadFromViper(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			expr := args[0]
+			if ignoreCase {
+				expr = "(?i)" + expr
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("invalid regexp: %w", err)
+			}
+			return grepFiles(cmd.OutOrStdout(), config.Collector, re)
+		},
+	}
+	config.SetupFlags(cmd)
+	cmd.Flags().BoolVar(&ignoreCase, "ignore-case", false, "Match case-insensitively")
+	return cmd
+}
+
+// grepFiles prints the records matching re of every file cfg matches. Files that
+// cannot be read are logged and skipped.
+func grepFiles(w io.Writer, cfg freader.Config, re *regexp.Regexp) error {
+	// Small files are searched too, and offsets do not matter
+	cfg.FingerprintStrategy = freader.FingerprintStrategyDeviceAndInode
+	cfg.FingerprintOffset = 0
+	cfg.UpgradeFingerprints = false
+	cfg.StoreOffsets = false
+	files, err := freader.ListFiles(cfg)
+	if err != nil {
+		return err
+	}
+	var opts []freader.ReaderTailOption
+	if cfg.SeparatorRegex != "" {
+		sep, err := freader.CompileSeparatorRegex(cfg.SeparatorRegex)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, freader.WithReaderSeparatorRegex(sep))
+	} else {
+		opts = append(opts, freader.WithReaderSeparator(cfg.Separator))
+	}
+	bw := bufio.NewWriter(w)
+	defer func() { _ = bw.Flush() }()
+	for _, f := range files {
+		if f.Err != nil {
+			slog.Warn("skipping unreadable file", "path", f.Path, "error", f.Err)
+			continue
+		}
+		if err := grepFile(bw, f.Path, re, opts); err != nil {
+			slog.Warn("failed to search file", "path", f.Path, "error", err)
+		}
+	}
+	return bw.Flush()
+}
+
+func grepFile(w io.Writer, path string, re *regexp.Regexp, opts []freader.ReaderTailOption) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = zr.Close() }()
+		r = zr
+	}
+	rt := freader.NewReaderTail(r, opts...)
+	return rt.Run(func(rec string) {
+		if re.MatchString(rec) {
+			_, _ = fmt.Fprintf(w, "%s:%d:%s\n", path, rt.RecordOffset(), rec)
+		}
+	})
+}
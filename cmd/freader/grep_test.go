@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGrepCmd(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	// Files smaller than the checksum fingerprint are searched too
+	a := write("app.log", []byte("ok\nERROR one\n"))
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte("x\nerror two\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := write("app.log.1.gz", gz.Bytes())
+	write("skip.tmp", []byte("ERROR excluded\n"))
+
+	viper.Reset()
+	defer viper.Reset()
+	cmd := newGrepCmd(DefaultConfig())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--ignore-case", "error", "--include", dir, "--exclude", "*.tmp", "--db-path", filepath.Join(dir, "offsets.db")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("grep failed: %v", err)
+	}
+	want := []string{a + ":3:ERROR one", b + ":2:error two"}
+	if got := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected grep output:\n%s", out.String())
+	}
+
+	viper.Reset()
+	cmd = newGrepCmd(DefaultConfig())
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"(", "--include", dir})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for an invalid regexp")
+	}
+}
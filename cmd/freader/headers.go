@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// recordHeaders renders the sink.headers templates for each record. A template is
+// text with ${...} references to the record envelope: file, host, event_time (with an
+// optional Go layout, ${event_time:2006.01.02}; the ingest time when unknown),
+// labels.<key> or fields.<path> (a parser.fields path into JSON object records).
+// Missing values render empty, and a header whose template renders empty is left out.
+type recordHeaders struct {
+	headers   []headerTemplate
+	host      string
+	labels    map[string]string
+	useFields bool
+}
+
+type headerTemplate struct {
+	name  string
+	parts []templatePart
+}
+
+// templatePart is literal text, or with ref set, a reference to a record value.
+type templatePart struct {
+	text   string
+	ref    string        // "file", "host", "event_time", "labels" or "fields"
+	key    string        // label name for ref "labels"
+	layout string        // time layout for ref "event_time"
+	path   []pathSegment // field path for ref "fields"
+}
+
+// compileRecordHeaders returns the sink's header templates, or nil when none are
+// configured.
+func compileRecordHeaders(cfg SinkConfig) (*recordHeaders, error) {
+	if len(cfg.Headers) == 0 {
+		return nil, nil
+	}
+	h := &recordHeaders{host: cfg.host(), labels: cfg.Labels}
+	for name, tmpl := range cfg.Headers {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("sink.headers: header name is empty")
+		}
+		parts, err := parseHeaderTemplate(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("sink.headers.%s: %w", name, err)
+		}
+		h.useFields = h.useFields || usesFields(parts)
+		h.headers = append(h.headers, headerTemplate{name: name, parts: parts})
+	}
+	return h, nil
+}
+
+func parseHeaderTemplate(tmpl string) ([]templatePart, error) {
+	var parts []templatePart
+	for rest := tmpl; rest != ""; {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			parts = append(parts, templatePart{text: rest})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, templatePart{text: rest[:start]})
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated ${ in %q", tmpl)
+		}
+		ref := strings.TrimSpace(rest[start+2 : start+end])
+		part, err := parseHeaderRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+		rest = rest[start+end+1:]
+	}
+	return parts, nil
+}
+
+func parseHeaderRef(ref string) (templatePart, error) {
+	switch {
+	case ref == "file" || ref == "host":
+		return templatePart{ref: ref}, nil
+	case ref == "event_time":
+		return templatePart{ref: ref, layout: time.RFC3339}, nil
+	case strings.HasPrefix(ref, "event_time:") && len(ref) > len("event_time:"):
+		return templatePart{ref: "event_time", layout: strings.TrimPrefix(ref, "event_time:")}, nil
+	case strings.HasPrefix(ref, "labels.") && len(ref) > len("labels."):
+		return templatePart{ref: "labels", key: strings.TrimPrefix(ref, "labels.")}, nil
+	case strings.HasPrefix(ref, "fields."):
+		path, err := parseFieldPath(strings.TrimPrefix(ref, "fields."))
+		if err != nil {
+			return templatePart{}, err
+		}
+		return templatePart{ref: "fields", path: path}, nil
+	default:
+		return templatePart{}, fmt.Errorf("unknown reference ${%s}; use file, host, event_time[:<layout>], labels.<key> or fields.<path>", ref)
+	}
+}
+
+// render returns the headers of the record e, or nil if all of them render empty.
+func (h *recordHeaders) render(e Entry) map[string]string {
+	var fields map[string]any
+	if h.useFields {
+		fields = decodeFields(e.Line)
+	}
+	var out map[string]string
+	for _, t := range h.headers {
+		v := expandTemplate(t.parts, e, h.host, h.labels, fields, nil)
+		if v == "" {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(h.headers))
+		}
+		out[t.name] = v
+	}
+	return out
+}
+
+// usesFields reports whether a template references fields of JSON records.
+func usesFields(parts []templatePart) bool {
+	for _, p := range parts {
+		if p.ref == "fields" {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeFields returns the fields of a JSON object record, or nil for other records.
+func decodeFields(line string) map[string]any {
+	if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return nil
+	}
+	var fields map[string]any
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber() // keep large integers exact
+	_ = dec.Decode(&fields)
+	return fields
+}
+
+// expandTemplate renders parts for the record e, passing the values it references
+// through clean if it is set.
+func expandTemplate(parts []templatePart, e Entry, host string, labels map[string]string, fields map[string]any, clean func(string) string) string {
+	var b strings.Builder
+	for _, p := range parts {
+		var v string
+		switch p.ref {
+		case "":
+			b.WriteString(p.text)
+			continue
+		case "file":
+			v = e.File
+		case "host":
+			v = host
+		case "event_time":
+			v = e.Time().UTC().Format(p.layout)
+		case "labels":
+			v = labels[p.key]
+		case "fields":
+			v = fieldString(fields, p.path)
+		}
+		if clean != nil {
+			v = clean(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// fieldString returns the value at path in fields as text: strings and numbers as
+// they are, objects and arrays as JSON, and "" when it is missing or null.
+func fieldString(fields map[string]any, path []pathSegment) string {
+	var v any = fields
+	for _, seg := range path {
+		var ok bool
+		if v, ok = child(v, seg); !ok {
+			return ""
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordHeaders_Render(t *testing.T) {
+	h, err := compileRecordHeaders(SinkConfig{
+		Type: "grpc",
+		Host: "node-1",
+		Labels: map[string]string{
+			"env": "prod",
+		},
+		Headers: map[string]string{
+			"env":     "${labels.env}",
+			"level":   "${fields.level}",
+			"route":   "${host}/${fields.service}:${fields.http.status}",
+			"user":    "${fields.user['id']}",
+			"tags":    "${fields.tags}",
+			"source":  "${file}",
+			"missing": "${fields.nope}",
+		},
+	})
+	if err != nil {
+		t.Fatalf("compileRecordHeaders: %v", err)
+	}
+	got := h.render(Entry{File: "/var/log/app.log", Line: `{"level":"error","service":"api","http":{"status":503},"user":{"id":12345678901234567890},"tags":["a","b"]}`})
+	want := map[string]string{
+		"env":    "prod",
+		"level":  "error",
+		"route":  "node-1/api:503",
+		"user":   "12345678901234567890",
+		"tags":   `["a","b"]`,
+		"source": "/var/log/app.log",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("render = %v, want %v", got, want)
+	}
+
+	// Plain records only get the envelope values; unresolved parts render empty
+	got = h.render(Entry{Line: "plain text"})
+	want = map[string]string{"env": "prod", "route": "node-1/:"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("render = %v, want %v", got, want)
+	}
+
+	if h, err := compileRecordHeaders(SinkConfig{}); h != nil || err != nil {
+		t.Fatalf("expected no headers without templates, got %v, %v", h, err)
+	}
+}
+
+func TestRecordHeaders_Invalid(t *testing.T) {
+	for tmpl, want := range map[string]string{
+		"${labels.env":      "unterminated",
+		"${message}":        "unknown reference",
+		"${labels.}":        "unknown reference",
+		"${fields.a[oops]}": "invalid",
+	} {
+		_, err := compileRecordHeaders(SinkConfig{Headers: map[string]string{"h": tmpl}})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("template %q: expected error containing %q, got %v", tmpl, want, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Sink.Type = "console"
+	cfg.Sink.Headers = map[string]string{"env": "${labels.env}"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "only supported by the grpc sink") {
+		t.Fatalf("expected sink.headers to be rejected for the console sink, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// idleWatcher records when data last arrived from any tracked file for --exit-after-idle.
+type idleWatcher struct {
+	last atomic.Int64 // unix nanoseconds
+}
+
+func newIdleWatcher(now time.Time) *idleWatcher {
+	w := &idleWatcher{}
+	w.touch(now)
+	return w
+}
+
+// touch notes that a record was read at t.
+func (w *idleWatcher) touch(t time.Time) {
+	w.last.Store(t.UnixNano())
+}
+
+// expired returns a channel closed once no record has arrived for idle. Checking stops
+// without closing the channel when stop is closed first.
+func (w *idleWatcher) expired(idle time.Duration, stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	check := min(max(idle/4, 10*time.Millisecond), time.Second)
+	go func() {
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				if now.Sub(time.Unix(0, w.last.Load())) >= idle {
+					close(done)
+					return
+				}
+			}
+		}
+	}()
+	return done
+}
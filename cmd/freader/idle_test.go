@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdleWatcher(t *testing.T) {
+	w := newIdleWatcher(time.Now())
+	stop := make(chan struct{})
+	defer close(stop)
+	expired := w.expired(100*time.Millisecond, stop)
+
+	// Activity keeps pushing the deadline out
+	for range 5 {
+		time.Sleep(40 * time.Millisecond)
+		w.touch(time.Now())
+	}
+	select {
+	case <-expired:
+		t.Fatal("expired while data was arriving")
+	default:
+	}
+
+	select {
+	case <-expired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not expire after going idle")
+	}
+}
+
+func TestIdleWatcher_Stop(t *testing.T) {
+	w := newIdleWatcher(time.Now())
+	stop := make(chan struct{})
+	expired := w.expired(50*time.Millisecond, stop)
+	close(stop)
+	select {
+	case <-expired:
+		t.Fatal("expired after stop")
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestRunCollector_ExitAfterIdle(t *testing.T) {
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logDir, "app.log"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "out.log")
+	configPath := filepath.Join(outDir, "freader.toml")
+	toml := "[sink]\ntype = \"file\"\n[sink.file]\npath = \"" + out + "\"\n"
+	if err := os.WriteFile(configPath, []byte(toml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadWithArgs(t, "--config", configPath, "--include", logDir, "--poll-interval", "50ms",
+		"--fingerprint-strategy", "deviceAndInode", "--exit-after-idle", "1s",
+		"--db-path", filepath.Join(outDir, "offsets.db"))
+	if err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- runCollector(cfg, make(chan struct{}), nil) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("runCollector failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("runCollector did not exit after going idle")
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "hello") {
+		t.Fatalf("output = %q, want the record read before going idle", b)
+	}
+}
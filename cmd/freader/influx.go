@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	cmdinflux "github.com/loykin/freader/cmd/freader/sink/influxdb"
+)
+
+// influxMapping turns records into points for the influxdb sink, following
+// sink.influxdb.measurement, tags and fields. The measurement and tag values are
+// templates in the sink.headers syntax; a tag whose value renders empty is left out.
+// Each field is a path into JSON object records: numbers are written as floats,
+// booleans and strings as they are, objects and arrays as JSON strings, and missing or
+// null values are left out. Records with none of the fields, and records whose
+// measurement renders empty, do not map to a point.
+type influxMapping struct {
+	measurement []templatePart
+	tags        map[string][]templatePart
+	fields      map[string][]pathSegment
+	host        string
+	labels      map[string]string
+}
+
+func compileInfluxMapping(cfg SinkConfig) (*influxMapping, error) {
+	m := &influxMapping{
+		tags:   make(map[string][]templatePart, len(cfg.InfluxDB.Tags)),
+		fields: make(map[string][]pathSegment, len(cfg.InfluxDB.Fields)),
+		host:   cfg.host(),
+		labels: cfg.Labels,
+	}
+	var err error
+	if m.measurement, err = parseHeaderTemplate(cfg.InfluxDB.Measurement); err != nil {
+		return nil, fmt.Errorf("sink.influxdb.measurement: %w", err)
+	}
+	for _, k := range sortedKeys(cfg.InfluxDB.Tags) {
+		if k == "" {
+			return nil, fmt.Errorf("sink.influxdb.tags: empty tag key")
+		}
+		if m.tags[k], err = parseHeaderTemplate(cfg.InfluxDB.Tags[k]); err != nil {
+			return nil, fmt.Errorf("sink.influxdb.tags.%s: %w", k, err)
+		}
+	}
+	for _, k := range sortedKeys(cfg.InfluxDB.Fields) {
+		if k == "" {
+			return nil, fmt.Errorf("sink.influxdb.fields: empty field key")
+		}
+		if _, ok := m.tags[k]; ok {
+			return nil, fmt.Errorf("sink.influxdb: %q is both a tag and a field", k)
+		}
+		if m.fields[k], err = parseFieldPath(cfg.InfluxDB.Fields[k]); err != nil {
+			return nil, fmt.Errorf("sink.influxdb.fields.%s: %w", k, err)
+		}
+	}
+	return m, nil
+}
+
+// point maps the record e, reporting false when it does not map to a point.
+func (m *influxMapping) point(e Entry) (cmdinflux.Point, bool) {
+	doc := decodeFields(e.Line)
+	if doc == nil {
+		return cmdinflux.Point{}, false
+	}
+	p := cmdinflux.Point{Fields: make(map[string]any, len(m.fields)), Time: e.Time()}
+	for k, path := range m.fields {
+		if v, ok := pointField(doc, path); ok {
+			p.Fields[k] = v
+		}
+	}
+	if len(p.Fields) == 0 {
+		return cmdinflux.Point{}, false
+	}
+	if p.Measurement = expandTemplate(m.measurement, e, m.host, m.labels, doc, nil); p.Measurement == "" {
+		return cmdinflux.Point{}, false
+	}
+	p.Tags = make(map[string]string, len(m.tags))
+	for k, parts := range m.tags {
+		p.Tags[k] = expandTemplate(parts, e, m.host, m.labels, doc, nil)
+	}
+	return p, true
+}
+
+// pointField returns the value at path in doc as a line protocol field value.
+func pointField(doc map[string]any, path []pathSegment) (any, bool) {
+	var v any = doc
+	for _, seg := range path {
+		var ok bool
+		if v, ok = child(v, seg); !ok {
+			return nil, false
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, false
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string, bool:
+		return v, true
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
+		}
+		return string(b), true
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cmdinflux "github.com/loykin/freader/cmd/freader/sink/influxdb"
+)
+
+func TestInfluxMapping_Point(t *testing.T) {
+	m, err := compileInfluxMapping(SinkConfig{
+		Type:   "influxdb",
+		Host:   "node-1",
+		Labels: map[string]string{"env": "prod"},
+		InfluxDB: cmdinflux.Config{
+			Measurement: "http_${fields.kind}",
+			Tags:        map[string]string{"host": "${host}", "env": "${labels.env}", "route": "${fields.req.route}"},
+			Fields:      map[string]string{"status": "status", "latency": "req.latency_ms", "cached": "cached", "msg": "msg", "items": "items"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("compileInfluxMapping: %v", err)
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p, ok := m.point(Entry{Line: `{"kind":"access","status":200,"cached":true,"msg":"ok","items":[1,2],"req":{"latency_ms":12.5}}`, EventTime: at})
+	if !ok {
+		t.Fatal("expected a point")
+	}
+	want := cmdinflux.Point{
+		Measurement: "http_access",
+		Tags:        map[string]string{"host": "node-1", "env": "prod", "route": ""},
+		Fields:      map[string]any{"status": 200.0, "latency": 12.5, "cached": true, "msg": "ok", "items": "[1,2]"},
+		Time:        at,
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("point = %+v, want %+v", p, want)
+	}
+
+	for _, line := range []string{"plain text", `{"kind":"access"}`, `{"status":null}`} {
+		if _, ok := m.point(Entry{Line: line}); ok {
+			t.Fatalf("expected %q not to map to a point", line)
+		}
+	}
+}
+
+func TestInfluxMapping_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  cmdinflux.Config
+		want string
+	}{
+		{cmdinflux.Config{Measurement: "m_${fields.kind", Fields: map[string]string{"v": "v"}}, "unterminated"},
+		{cmdinflux.Config{Measurement: "m", Tags: map[string]string{"t": "${message}"}, Fields: map[string]string{"v": "v"}}, "unknown reference"},
+		{cmdinflux.Config{Measurement: "m", Fields: map[string]string{"v": "a..b"}}, "empty key"},
+		{cmdinflux.Config{Measurement: "m", Tags: map[string]string{"v": "x"}, Fields: map[string]string{"v": "v"}}, "both a tag and a field"},
+	} {
+		_, err := compileInfluxMapping(SinkConfig{Type: "influxdb", InfluxDB: tc.cfg})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("compileInfluxMapping(%+v) = %v, want error containing %q", tc.cfg, err, tc.want)
+		}
+	}
+}
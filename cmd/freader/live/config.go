@@ -0,0 +1,49 @@
+package live
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Config holds live stream server options.
+type Config struct {
+	Enable bool   `mapstructure:"enable"`
+	Addr   string `mapstructure:"addr"`
+	// BearerToken, when set, must be sent as an "Authorization: Bearer" header or, for
+	// browsers whose EventSource and WebSocket cannot set headers, a token query parameter.
+	BearerToken string `mapstructure:"bearer-token"`
+	// AllowedOrigins lists the origins, e.g. "https://dashboard.example.com", whose pages
+	// may open the WebSocket stream besides pages served by the live server itself; "*"
+	// allows any. Clients sending no Origin header are not browsers and are accepted.
+	AllowedOrigins []string `mapstructure:"allowed-origins"`
+}
+
+// Validate checks that an enabled server has an address, and a bearer token unless it
+// only listens on loopback.
+func (c Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Addr == "" {
+		return errors.New("live.addr must be set when live.enable is true")
+	}
+	if c.BearerToken == "" && !isLoopback(c.Addr) {
+		return fmt.Errorf("live.bearer-token must be set when live.addr %q is not a loopback address", c.Addr)
+	}
+	return nil
+}
+
+// isLoopback reports whether addr only listens on loopback; a missing host listens on
+// every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
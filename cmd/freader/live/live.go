@@ -0,0 +1,242 @@
+// Package live streams collected records to WebSocket and Server-Sent Events clients,
+// for tailing a freader instance from a browser.
+package live
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clientBuffer is how many records are queued per client; records arriving while it
+// is full are dropped for that client so a slow reader never holds back collection.
+const clientBuffer = 256
+
+// Record is one collected record as sent to clients, JSON encoded.
+type Record struct {
+	File string    `json:"file"`
+	Line string    `json:"line"`
+	Time time.Time `json:"time"`
+}
+
+// Hub fans records out to the connected clients.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+	closed  bool
+}
+
+type client struct {
+	filter filter
+	ch     chan Record
+	done   chan struct{} // closed when the hub shuts down
+}
+
+// NewHub returns a hub without clients.
+func NewHub() *Hub {
+	return &Hub{clients: make(map[*client]struct{})}
+}
+
+// Publish hands r to every client whose filter accepts it. It never blocks.
+func (h *Hub) Publish(r Record) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if !c.filter.allow(r) {
+			continue
+		}
+		select {
+		case c.ch <- r:
+		default:
+		}
+	}
+}
+
+func (h *Hub) subscribe(f filter) (*client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, errors.New("live server is shutting down")
+	}
+	c := &client{filter: f, ch: make(chan Record, clientBuffer), done: make(chan struct{})}
+	h.clients[c] = struct{}{}
+	return c, nil
+}
+
+func (h *Hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+// Close disconnects all clients and refuses new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for c := range h.clients {
+		close(c.done)
+		delete(h.clients, c)
+	}
+}
+
+// filter is the per-connection selection from the query string: include and exclude
+// substrings of the line (like the sink filters) and a file glob matched against the
+// path or its base name.
+type filter struct {
+	includes []string
+	excludes []string
+	file     string
+}
+
+func parseFilter(q url.Values) (filter, error) {
+	f := filter{includes: q["include"], excludes: q["exclude"], file: q.Get("file")}
+	if f.file != "" {
+		if _, err := filepath.Match(f.file, ""); err != nil {
+			return filter{}, fmt.Errorf("invalid file pattern %q: %w", f.file, err)
+		}
+	}
+	return f, nil
+}
+
+func (f filter) allow(r Record) bool {
+	if f.file != "" {
+		full, _ := filepath.Match(f.file, r.File)
+		base, _ := filepath.Match(f.file, filepath.Base(r.File))
+		if !full && !base {
+			return false
+		}
+	}
+	if len(f.includes) > 0 {
+		ok := false
+		for _, s := range f.includes {
+			if strings.Contains(r.Line, s) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, s := range f.excludes {
+		if strings.Contains(r.Line, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Handler serves a browser page at /, Server-Sent Events at /sse and WebSocket at /ws.
+// Both streams accept the include, exclude and file query parameters. A non-empty
+// cfg.BearerToken is required on every request, and WebSocket upgrades from other
+// origins than cfg.AllowedOrigins are refused.
+func (h *Hub) Handler(cfg Config) http.Handler {
+	token := cfg.BearerToken
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	})
+	mux.HandleFunc("/sse", h.serveSSE)
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		h.serveWebSocket(w, r, cfg.AllowedOrigins)
+	})
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.URL.Query().Get("token")
+		if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			got = auth
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (h *Hub) serveSSE(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc := http.NewResponseController(w)
+	c, err := h.subscribe(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer h.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			return
+		case rec := <-c.ch:
+			b, _ := json.Marshal(rec)
+			_ = rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Start serves the hub on cfg.Addr. The returned function disconnects all clients and
+// stops the server.
+func Start(cfg Config, h *Hub) (func() error, error) {
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: h.Handler(cfg), ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	return func() error {
+		h.Close()
+		return srv.Close()
+	}, nil
+}
+
+// page is a minimal live tail; the query string of the page is passed on to /sse.
+const page = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>freader live</title></head>
+<body style="margin:0;font:12px monospace;background:#111;color:#ddd">
+<pre id="out" style="margin:0;padding:8px;white-space:pre-wrap"></pre>
+<script>
+const out = document.getElementById("out");
+const es = new EventSource("sse" + location.search);
+es.onmessage = (ev) => {
+  const r = JSON.parse(ev.data);
+  const atEnd = window.innerHeight + window.scrollY >= document.body.scrollHeight - 4;
+  out.append(r.file + ": " + r.line + "\n");
+  while (out.childNodes.length > 5000) out.removeChild(out.firstChild);
+  if (atEnd) window.scrollTo(0, document.body.scrollHeight);
+};
+</script>
+</body>
+</html>
+`
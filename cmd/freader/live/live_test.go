@@ -0,0 +1,230 @@
+package live
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForClients waits until n clients are subscribed so published records reach them.
+func waitForClients(t *testing.T, h *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		h.mu.RLock()
+		got := len(h.clients)
+		h.mu.RUnlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("clients = %d, want %d", got, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f, err := parseFilter(url.Values{"include": {"ERROR", "WARN"}, "exclude": {"noisy"}, "file": {"app*.log"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		rec  Record
+		want bool
+	}{
+		{Record{File: "/var/log/app.log", Line: "ERROR boom"}, true},
+		{Record{File: "/var/log/app-2.log", Line: "WARN slow"}, true},
+		{Record{File: "/var/log/app.log", Line: "INFO fine"}, false},
+		{Record{File: "/var/log/app.log", Line: "ERROR noisy"}, false},
+		{Record{File: "/var/log/db.log", Line: "ERROR boom"}, false},
+	}
+	for _, c := range cases {
+		if got := f.allow(c.rec); got != c.want {
+			t.Errorf("allow(%+v) = %v, want %v", c.rec, got, c.want)
+		}
+	}
+	if _, err := parseFilter(url.Values{"file": {"["}}); err == nil {
+		t.Fatal("expected error for an invalid file pattern")
+	}
+}
+
+func TestHub_SSE(t *testing.T) {
+	h := NewHub()
+	srv := httptest.NewServer(h.Handler(Config{}))
+	defer srv.Close()
+	defer h.Close()
+
+	resp, err := http.Get(srv.URL + "/sse?include=keep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+	waitForClients(t, h, 1)
+	h.Publish(Record{File: "/a.log", Line: "drop me"})
+	h.Publish(Record{File: "/a.log", Line: "keep me"})
+
+	r := bufio.NewReader(resp.Body)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			t.Fatalf("invalid record %q: %v", data, err)
+		}
+		if rec.Line != "keep me" || rec.File != "/a.log" {
+			t.Fatalf("unexpected record: %+v", rec)
+		}
+		return
+	}
+}
+
+func TestHub_WebSocket(t *testing.T) {
+	h := NewHub()
+	srv := httptest.NewServer(h.Handler(Config{}))
+	defer srv.Close()
+	defer h.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	_, err = conn.Write([]byte("GET /ws?file=b.log HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	// Example key and accept value from RFC 6455
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("accept = %q", got)
+	}
+
+	waitForClients(t, h, 1)
+	h.Publish(Record{File: "/a.log", Line: "other file"})
+	h.Publish(Record{File: "/b.log", Line: "hello"})
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		t.Fatal(err)
+	}
+	if hdr[0] != 0x80|opText || hdr[1]&0x80 != 0 {
+		t.Fatalf("unexpected frame header %x", hdr)
+	}
+	payload := make([]byte, hdr[1])
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	var rec Record
+	if err := json.Unmarshal(payload, &rec); err != nil || rec.Line != "hello" {
+		t.Fatalf("unexpected record %q: %v", payload, err)
+	}
+
+	// A masked close frame is echoed and ends the stream
+	mask := []byte{1, 2, 3, 4}
+	body := []byte{0x03 ^ mask[0], 0xE8 ^ mask[1]}
+	if _, err := conn.Write(append([]byte{0x80 | opClose, 0x80 | 2, 1, 2, 3, 4}, body...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		t.Fatal(err)
+	}
+	if hdr[0] != 0x80|opClose {
+		t.Fatalf("expected a close frame, got %x", hdr)
+	}
+	waitForClients(t, h, 0)
+}
+
+func TestHandler_Token(t *testing.T) {
+	h := NewHub()
+	srv := httptest.NewServer(h.Handler(Config{BearerToken: "secret"}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status without token = %d", resp.StatusCode)
+	}
+	resp, err = http.Get(srv.URL + "/?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status with token = %d", resp.StatusCode)
+	}
+}
+
+func TestHub_WebSocketOrigin(t *testing.T) {
+	h := NewHub()
+	defer h.Close()
+	upgrade := func(srv *httptest.Server, origin string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	srv := httptest.NewServer(h.Handler(Config{}))
+	defer srv.Close()
+	for origin, want := range map[string]int{
+		"":                     http.StatusSwitchingProtocols, // not a browser
+		srv.URL:                http.StatusSwitchingProtocols, // the live page itself
+		"https://evil.example": http.StatusForbidden,
+	} {
+		if got := upgrade(srv, origin); got != want {
+			t.Errorf("origin %q: status = %d, want %d", origin, got, want)
+		}
+	}
+
+	allowed := httptest.NewServer(h.Handler(Config{AllowedOrigins: []string{"https://dashboard.example/"}}))
+	defer allowed.Close()
+	if got := upgrade(allowed, "https://dashboard.example"); got != http.StatusSwitchingProtocols {
+		t.Errorf("allowed origin: status = %d", got)
+	}
+	if got := upgrade(allowed, "https://evil.example"); got != http.StatusForbidden {
+		t.Errorf("other origin: status = %d", got)
+	}
+}
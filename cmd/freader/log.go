@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Log formats accepted by --log-format.
+const (
+	logFormatAuto    = "auto"
+	logFormatText    = "text"
+	logFormatJSON    = "json"
+	logFormatJournal = "journal"
+)
+
+// newLogHandler returns the slog handler for a --log-format value. "auto" picks the
+// journal format when stderr is connected to journald (JOURNAL_STREAM is set) and
+// plain text otherwise.
+func newLogHandler(format string, w io.Writer) (slog.Handler, error) {
+	switch format {
+	case "", logFormatAuto:
+		if os.Getenv("JOURNAL_STREAM") != "" {
+			return newJournalHandler(w, nil), nil
+		}
+		return slog.NewTextHandler(w, nil), nil
+	case logFormatText:
+		return slog.NewTextHandler(w, nil), nil
+	case logFormatJSON:
+		return slog.NewJSONHandler(w, nil), nil
+	case logFormatJournal:
+		return newJournalHandler(w, nil), nil
+	default:
+		return nil, fmt.Errorf("invalid log-format: %s (use auto, text, json or journal)", format)
+	}
+}
+
+// newJournalHandler returns a handler writing one line per record prefixed with its
+// syslog priority (sd-daemon(3) "<N>" convention) so journald stores the level, and
+// omitting the time and level attributes journald already records.
+func newJournalHandler(w io.Writer, opts *slog.HandlerOptions) *recordHandler {
+	return newRecordHandler(opts, func(level slog.Level, line []byte) error {
+		_, err := fmt.Fprintf(w, "<%d>%s", journalPriority(level), line)
+		return err
+	})
+}
+
+// recordHandler formats records as text lines without time and level and passes each
+// line with its level to emit, for sinks that store those themselves (journald, the
+// Windows event log).
+type recordHandler struct {
+	mu    *sync.Mutex
+	buf   *bytes.Buffer
+	inner slog.Handler // text handler writing into buf
+	emit  func(level slog.Level, line []byte) error
+}
+
+func newRecordHandler(opts *slog.HandlerOptions, emit func(level slog.Level, line []byte) error) *recordHandler {
+	if opts == nil {
+		opts = &slog.HandlerOptions{}
+	}
+	o := *opts
+	replace := o.ReplaceAttr
+	o.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+			return slog.Attr{}
+		}
+		if replace != nil {
+			return replace(groups, a)
+		}
+		return a
+	}
+	buf := &bytes.Buffer{}
+	return &recordHandler{mu: &sync.Mutex{}, buf: buf, inner: slog.NewTextHandler(buf, &o), emit: emit}
+}
+
+func (h *recordHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *recordHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf.Reset()
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.emit(r.Level, h.buf.Bytes())
+}
+
+func (h *recordHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recordHandler{mu: h.mu, buf: h.buf, inner: h.inner.WithAttrs(attrs), emit: h.emit}
+}
+
+func (h *recordHandler) WithGroup(name string) slog.Handler {
+	return &recordHandler{mu: h.mu, buf: h.buf, inner: h.inner.WithGroup(name), emit: h.emit}
+}
+
+// journalPriority maps a slog level to a syslog priority.
+func journalPriority(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return 3 // err
+	case l >= slog.LevelWarn:
+		return 4 // warning
+	case l >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestJournalHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(newJournalHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})).With("component", "watcher")
+
+	logger.Info("scan done", "files", 3)
+	logger.Error("read failed")
+	logger.Debug("detail")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"<6>msg=\"scan done\" component=watcher files=3",
+		"<3>msg=\"read failed\" component=watcher",
+		"<7>msg=detail component=watcher",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", out.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestNewLogHandler(t *testing.T) {
+	t.Setenv("JOURNAL_STREAM", "")
+	h, err := newLogHandler(logFormatAuto, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.(*slog.TextHandler); !ok {
+		t.Fatalf("auto outside journald: got %T", h)
+	}
+	t.Setenv("JOURNAL_STREAM", "8:12345")
+	if h, _ = newLogHandler(logFormatAuto, &bytes.Buffer{}); h == nil {
+		t.Fatal("nil handler")
+	}
+	if _, ok := h.(*recordHandler); !ok {
+		t.Fatalf("auto under journald: got %T", h)
+	}
+	if h, _ = newLogHandler(logFormatJSON, &bytes.Buffer{}); h == nil {
+		t.Fatal("nil handler")
+	}
+	if _, ok := h.(*slog.JSONHandler); !ok {
+		t.Fatalf("json: got %T", h)
+	}
+	if _, err := newLogHandler("xml", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
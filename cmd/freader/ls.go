@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/loykin/freader"
+	"github.com/spf13/cobra"
+)
+
+// newLsCmd returns the "ls" command, which lists the files the configuration matches.
+// It takes the same collector flags and config file as the root command.
+func newLsCmd(config *Config) *cobra.Command {
+	var (
+		errorsOnly bool
+		explain    string
+	)
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List matched files, their stored offsets and files that cannot be read",
+		Long: `Run a single scan with the configured include/exclude patterns and list the
+matching files with their size and stored offset. Files and directories that
+cannot be read (e.g. permission denied) are listed with the error; --errors
+shows only those. --explain <path> instead reports whether that file is
+tracked and, if not, why: outside the scanned directories, filtered out by an
+include or exclude pattern, or not yet fingerprintable (too small, not enough
+separators, unreadable).`,
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return config.LoadFromViper(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if explain != "" {
+				d, err := freader.ExplainPath(config.Collector, explain)
+				if err != nil {
+					return err
+				}
+				return printDecision(cmd.OutOrStdout(), d)
+			}
+			files, err := freader.ListFiles(config.Collector)
+			if err != nil {
+				return err
+			}
+			return printFiles(cmd.OutOrStdout(), files, errorsOnly)
+		},
+	}
+	config.SetupFlags(cmd)
+	cmd.Flags().BoolVar(&errorsOnly, "errors", false, "Only list files and directories that cannot be read")
+	cmd.Flags().StringVar(&explain, "explain", "", "Explain whether the file at this path is tracked and, if not, why")
+	return cmd
+}
+
+func printFiles(w io.Writer, files []freader.ListedFile, errorsOnly bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if errorsOnly {
+		_, _ = fmt.Fprintln(tw, "PATH\tERROR")
+	} else {
+		_, _ = fmt.Fprintln(tw, "PATH\tSIZE\tOFFSET\tSTATUS")
+	}
+	for _, f := range files {
+		if errorsOnly {
+			if f.Err != nil {
+				_, _ = fmt.Fprintf(tw, "%s\t%v\n", f.Path, f.Err)
+			}
+			continue
+		}
+		size, offset, status := "-", "-", "ok"
+		if f.Size >= 0 {
+			size = fmt.Sprint(f.Size)
+		}
+		if f.Offset >= 0 {
+			offset = fmt.Sprint(f.Offset)
+		}
+		if f.Err != nil {
+			status = f.Err.Error()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Path, size, offset, status)
+	}
+	return tw.Flush()
+}
+
+func printDecision(w io.Writer, d freader.Decision) error {
+	_, err := fmt.Fprintf(w, "%s: %s (%s)\n", d.Path, d.Action, d.Reason)
+	return err
+}
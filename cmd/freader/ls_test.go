@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/freader"
+	"github.com/spf13/viper"
+)
+
+func TestPrintFiles(t *testing.T) {
+	files := []freader.ListedFile{
+		{Path: "/var/log/a.log", Size: 120, Offset: 100},
+		{Path: "/var/log/b.log", Size: 10, Offset: -1},
+		{Path: "/var/log/secret.log", Size: -1, Offset: -1, Err: fs.ErrPermission},
+	}
+
+	var out bytes.Buffer
+	if err := printFiles(&out, files, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header and 3 rows, got %q", out.String())
+	}
+	if f := strings.Fields(lines[1]); f[1] != "120" || f[2] != "100" || f[3] != "ok" {
+		t.Fatalf("unexpected row: %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[2] != "-" {
+		t.Fatalf("missing offset should print '-': %q", lines[2])
+	}
+
+	out.Reset()
+	if err := printFiles(&out, files, true); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "/var/log/secret.log") || !strings.Contains(lines[1], "permission denied") {
+		t.Fatalf("unexpected --errors output: %q", out.String())
+	}
+}
+
+func TestLsCmd(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(p, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+	cmd := newLsCmd(DefaultConfig())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--include", dir, "--fingerprint-strategy", "deviceAndInode", "--db-path", filepath.Join(dir, "offsets.db")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ls failed: %v", err)
+	}
+	if !strings.Contains(out.String(), p) {
+		t.Fatalf("expected %s in output: %q", p, out.String())
+	}
+}
+
+func TestLsExplain(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(p, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+	cmd := newLsCmd(DefaultConfig())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--include", dir, "--exclude", "app*", "--fingerprint-strategy", "deviceAndInode",
+		"--db-path", filepath.Join(dir, "offsets.db"), "--explain", p})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ls --explain failed: %v", err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, p+": excluded") || !strings.Contains(got, `"app*"`) {
+		t.Fatalf("unexpected --explain output: %q", got)
+	}
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/loykin/freader"
+	"github.com/loykin/freader/cmd/freader/live"
 	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
 	"github.com/loykin/freader/pkg/parser/audit"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
+
+	// PostgreSQL driver for --postgres-dsn
+	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
 func main() {
@@ -52,12 +60,34 @@ Notes:
 			return config.Validate()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCollector(config)
+			if isService() {
+				return runService(config)
+			}
+			handler, err := newLogHandler(config.LogFormat, os.Stderr)
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(slog.New(handler))
+
+			// Setup signal handling for graceful shutdown
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+			stop := make(chan struct{})
+			go func() {
+				<-sigCh
+				close(stop)
+			}()
+			// SIGHUP reloads the sink configuration without restarting collection
+			reload := make(chan SinkConfig)
+			go watchReload(config.ConfigFile, reload, stop)
+			return runCollector(config, stop, reload)
 		},
 	}
 
 	// Setup flags from config
 	config.SetupFlags(rootCmd)
+	rootCmd.AddCommand(newOffsetsCmd(), newLsCmd(config), newGrepCmd(config), newBenchCmd())
+	addServiceCmd(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		slog.Error(err.Error())
@@ -65,46 +95,114 @@ Notes:
 	}
 }
 
-func runCollector(config *Config) error {
+// runCollector runs the collector with the configured sink until stop is closed. Sink
+// configurations received on reload replace the running sink; reload may be nil.
+func runCollector(config *Config, stop <-chan struct{}, reload <-chan SinkConfig) error {
 	// Optionally start Prometheus metrics endpoint
 	var metricsStop = func() error { return nil }
 	if config.Prometheus.Enable {
 		// Register library metrics and sink metrics before exposing the endpoint
-		if err := freader.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		if err := freader.RegisterMetrics(prometheus.DefaultRegisterer, freader.WithMetricLabels(config.Prometheus.Labels)); err != nil {
 			return fmt.Errorf("failed to register default metrics: %w", err)
 		}
-		if err := cmdmetrics.Register(prometheus.DefaultRegisterer); err != nil {
+		if err := cmdmetrics.Register(prometheus.WrapRegistererWith(config.Prometheus.Labels, prometheus.DefaultRegisterer)); err != nil {
 			return fmt.Errorf("failed to register sink metrics: %w", err)
 		}
-		stopFn, err := freader.StartMetrics(config.Prometheus.Addr)
+		opts := freader.MetricsServerOptions{
+			TLSCertFile:       config.Prometheus.TLSCertFile,
+			TLSKeyFile:        config.Prometheus.TLSKeyFile,
+			BasicAuthUser:     config.Prometheus.BasicAuthUser,
+			BasicAuthPassword: config.Prometheus.BasicAuthPassword,
+			BearerToken:       config.Prometheus.BearerToken,
+		}
+		if config.Prometheus.Debug {
+			opts.Handlers = debugHandlers()
+		}
+		if config.Collector.RetainLastN > 0 {
+			if opts.Handlers == nil {
+				opts.Handlers = map[string]http.Handler{}
+			}
+			opts.Handlers["/recent"] = recentHandler()
+		}
+		stopFn, err := freader.StartMetricsWithOptions(config.Prometheus.Addr, opts)
 		if err != nil {
 			return fmt.Errorf("failed to start prometheus endpoint: %w", err)
 		}
 		metricsStop = stopFn
 	}
 
-	// Start optional external sink (clickhouse/opensearch)
-	sink, err := buildSink(config)
-	if err != nil {
-		return fmt.Errorf("failed to build sink: %w", err)
+	// Optionally stream records to live tail clients
+	var hub *live.Hub
+	if config.Live.Enable {
+		hub = live.NewHub()
+		liveStop, err := live.Start(config.Live, hub)
+		if err != nil {
+			_ = metricsStop()
+			return fmt.Errorf("failed to start live stream server: %w", err)
+		}
+		defer func() { _ = liveStop() }()
 	}
-	if sink != nil {
+
+	// Start optional external sink (clickhouse/opensearch); a dry run counts what it
+	// would be handed instead
+	var built Sink
+	var dry *dryRun
+	if config.DryRun {
+		dry = newDryRun(config.Sink)
+		built = dry
+		slog.Info("dry run: records are counted instead of delivered, and offsets are not stored", "sink", config.Sink.Type)
+	} else {
+		var err error
+		if built, err = buildSink(config); err != nil {
+			return fmt.Errorf("failed to build sink: %w", err)
+		}
+	}
+	var sink *swapSink
+	if built != nil {
+		sink = newSwapSink(built, config.Sink)
 		defer func() { _ = sink.Stop() }()
 	}
 
 	// Prepare collector configuration from nested config
 	cfg := config.Collector
+	if config.DryRun {
+		// Resume from the stored offsets but leave them to the instance that owns them
+		cfg.ReadOnlyOffsets = true
+	}
+
+	// Optional Docker container discovery
+	discovery, err := newDockerDiscovery(config.Discovery.Docker)
+	if err != nil {
+		_ = metricsStop()
+		return fmt.Errorf("failed to set up docker discovery: %w", err)
+	}
+
+	// Parse failures are counted and, with parser.error-file, written out with their error
+	parseErrs, err := newParseErrors(config.Parser)
+	if err != nil {
+		_ = metricsStop()
+		return fmt.Errorf("failed to open parser.error-file: %w", err)
+	}
+	if dry != nil {
+		parseErrs = parseErrs.withTally(dry.observeParse)
+	}
+	defer func() { _ = parseErrs.stop() }()
 
 	// Optional parser transform
-	transform := func(s string) (string, bool) { return s, true }
-	if config.Parser.Type == "auditd" {
-		format := config.Parser.Format
-		if format == "" {
-			format = "json"
-		}
+	transform := func(path, s string) (string, bool) { return s, true }
+	format := config.Parser.Format
+	if format == "" {
+		format = "json"
+	}
+	switch config.Parser.Type {
+	case "auditd":
 		drop := config.Parser.DropNonMatching
-		transform = func(s string) (string, bool) {
-			rec, ok, _ := audit.Parse(s)
+		transform = func(path, s string) (string, bool) {
+			rec, ok, err := audit.Parse(s)
+			if !ok && err == nil {
+				err = errNotAuditRecord
+			}
+			parseErrs.observe("auditd", path, s, err)
 			if !ok {
 				if drop {
 					return "", false
@@ -117,22 +215,100 @@ func runCollector(config *Config) error {
 			// raw falls back to original line
 			return s, true
 		}
+	case parserTypeCRI, parserTypeDockerJSON:
+		transform = containerTransform(config.Parser.Type, format, config.Parser.DropNonMatching, discovery.enrich, parseErrs.reporter(config.Parser.Type))
+	}
+	if rules, _ := compileFieldRules(config.Parser.Fields); len(rules) > 0 { // validated in Config.Validate
+		parse := transform
+		transform = func(path, s string) (string, bool) {
+			out, ok := parse(path, s)
+			if !ok {
+				return "", false
+			}
+			return rules.reshape(out), true
+		}
+	}
+	plugins, err := loadPlugins(config.Parser.Plugins)
+	if err != nil {
+		_ = metricsStop()
+		return fmt.Errorf("failed to load plugin: %w", err)
 	}
 
-	cfg.OnLineFunc = func(line string) {
-		out, ok := transform(line)
-		if !ok {
-			return
+	// Event time for sinks, from the same source as --start-from-time (validated in Config.Validate)
+	eventTime, _ := config.Parser.timestampFunc()
+	if config.StartFromTime != "" {
+		cfg.StartFromTime, _ = time.Parse(time.RFC3339Nano, config.StartFromTime)
+		cfg.TimestampFunc = eventTime
+	}
+	if cfg.MergeWindow > 0 {
+		cfg.TimestampFunc = eventTime
+	}
+
+	activity := newIdleWatcher(time.Now())
+	// emit outputs one (possibly plugin-produced) record of e; ack, if set, tracks the
+	// delivery of the collector batch e came in
+	emit := func(e freader.LineEvent, out string, ack *Ack) {
+		if e.Repeats > 0 {
+			out = freader.RepeatSummary(out, e.Repeats)
+		}
+		if hub != nil {
+			hub.Publish(live.Record{File: e.File, Line: out, Time: e.Ts})
 		}
 		if sink != nil {
 			// When a sink is configured (stdout/opensearch/clickhouse), it is the single output path.
 			// Do not duplicate to local output.
-			sink.Enqueue(out)
+			entry := Entry{Line: out, IngestTime: e.Ts, File: e.File, Labels: discovery.labels(e.File), Ack: ack}
+			if eventTime != nil {
+				var ok bool
+				entry.EventTime, ok = eventTime(e.Line)
+				cmdmetrics.ParserObserve("timestamp", ok)
+				dry.observeParse("timestamp", ok)
+			}
+			dry.receive()
+			sink.EnqueueEntry(entry)
 			return
 		}
 		// No sink configured: fallback print to stdout
 		fmt.Println(out)
 	}
+	handle := func(e freader.LineEvent, ack *Ack) {
+		activity.touch(e.Ts)
+		out, ok := transform(e.File, e.Line)
+		if !ok {
+			return
+		}
+		if len(plugins) == 0 {
+			emit(e, out, ack)
+			return
+		}
+		for _, rec := range plugins.apply(e.File, out, parseErrs) {
+			emit(e, rec, ack)
+		}
+	}
+	cfg.OnEventFunc = func(e freader.LineEvent) { handle(e, nil) }
+
+	if _, ok := built.(Acker); ok {
+		// The sink confirms delivery: hand it the collector's batches and let the
+		// collector store their offsets once the sink has settled the batch's Ack, or
+		// read the records again if it failed
+		cfg.OnLinesAckFunc = func(recs []freader.Record) func() error {
+			ack := new(Ack)
+			for _, rec := range recs {
+				handle(rec, ack)
+			}
+			return ack.Wait
+		}
+		cfg.LinesBatchSize = config.Sink.BatchSize
+		cfg.LinesBatchInterval = config.Sink.BatchInterval
+	}
+
+	// A dry run reads next to the lease holder rather than competing with it
+	if config.KubernetesLease != "" && !config.DryRun {
+		if cfg.Leaser, err = newKubernetesLeaser(config.KubernetesLease); err != nil {
+			_ = metricsStop()
+			return fmt.Errorf("failed to set up kubernetes lease: %w", err)
+		}
+	}
 
 	// Create collector
 	c, err := freader.NewCollector(cfg)
@@ -140,21 +316,122 @@ func runCollector(config *Config) error {
 		_ = metricsStop()
 		return errors.New("error creating collector: " + err.Error())
 	}
+	debugCollector.Store(c)
+	defer debugCollector.CompareAndSwap(c, nil)
 
-	// Setup signal handling for graceful shutdown
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	// Backfill progress reporting; stopped before the collector on shutdown
+	progressStop := make(chan struct{})
+	progressDone := make(chan struct{})
+	if config.ProgressInterval > 0 && config.backfill() {
+		go func() {
+			defer close(progressDone)
+			reportProgress(c.Stats, config.ProgressInterval, progressStop)
+		}()
+	} else {
+		close(progressDone)
+	}
+	stopProgress := func() {
+		close(progressStop)
+		<-progressDone
+	}
+	if dry != nil {
+		go dry.run(config.ProgressInterval, progressStop)
+		defer dry.report(true)
+	}
+
+	// One-shot batch mode: read what the files hold now, flush and exit
+	if config.Once {
+		defer func() { _ = metricsStop() }()
+		return runOnce(c, stop, stopProgress)
+	}
 
 	// Start the collector
 	c.Start()
 
-	// Wait for interrupt signal
+	discovery.start(c, cfg.PollInterval)
+	defer discovery.stop()
+
+	// Under a systemd Type=notify unit, report readiness and ping the watchdog while
+	// the scan loop keeps completing scans
+	if _, err := sdNotify("READY=1"); err != nil {
+		slog.Warn("failed to notify systemd", "error", err)
+	}
+	watchdogStop := make(chan struct{})
+	if timeout := sdWatchdogInterval(); timeout > 0 {
+		stale := max(3*cfg.PollInterval, timeout)
+		go runWatchdog(watchdogStop, timeout, scanHealthy(c.LastScan, time.Now(), stale))
+	}
+
+	// Wait for interrupt signal, service stop or, with --exit-after-idle, a quiet period
+	var idle <-chan struct{}
+	if config.ExitAfterIdle > 0 {
+		idle = activity.expired(config.ExitAfterIdle, stop)
+	}
 	fmt.Println("Running... Press Ctrl+C to stop")
-	<-sigCh
+wait:
+	for {
+		select {
+		case <-stop:
+			break wait
+		case <-idle:
+			slog.Info("no new data, exiting", "idle", config.ExitAfterIdle)
+			break wait
+		case next := <-reload:
+			if dry != nil {
+				slog.Info("dry run: ignoring sink reload")
+				continue
+			}
+			if sink == nil {
+				slog.Warn("no sink configured at startup; restart to enable one")
+				continue
+			}
+			if err := sink.reload(next); err != nil {
+				slog.Error("sink reload failed; keeping the running sink", "error", err)
+			}
+		}
+	}
 
 	fmt.Println("Shutting down...")
+	_, _ = sdNotify("STOPPING=1")
+	close(watchdogStop)
+	stopProgress()
 	c.Stop()
 	_ = metricsStop()
 
 	return nil
 }
+
+// runOnce reads every matching file to its end with Collector.RunOnce; closing stop
+// interrupts the run. stopProgress ends progress reporting once reading is over.
+// Offsets are stored before returning and the caller's deferred sink Stop flushes the
+// last batch.
+func runOnce(c *freader.Collector, stop <-chan struct{}, stopProgress func()) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := c.RunOnce(ctx)
+	stopProgress()
+	st := c.Stats()
+	slog.Info("one-shot run finished", "files", st.TrackedFiles, "lines", st.LinesRead, "bytes", st.BytesRead)
+	if err != nil {
+		return fmt.Errorf("one-shot run: %w", err)
+	}
+	return nil
+}
+
+// newKubernetesLeaser returns the leaser for --kubernetes-lease "[namespace/]name",
+// connecting to the API server with the pod's service account.
+func newKubernetesLeaser(ref string) (freader.Leaser, error) {
+	cfg := freader.KubernetesLeaseConfig{Name: ref}
+	if ns, name, ok := strings.Cut(ref, "/"); ok {
+		cfg.Namespace, cfg.Name = ns, name
+	}
+	return freader.NewKubernetesLeaser(cfg)
+}
@@ -1,7 +1,32 @@
 package metrics
 
+import "errors"
+
 // Config holds metrics endpoint options.
 type Config struct {
-	Enable bool   `mapstructure:"enable"`
-	Addr   string `mapstructure:"addr"`
+	Enable bool              `mapstructure:"enable"`
+	Addr   string            `mapstructure:"addr"`
+	Labels map[string]string `mapstructure:"labels"` // constant labels added to every metric, e.g. instance
+
+	// Optional protection of the endpoint: HTTPS with a PEM key pair, and basic auth
+	// and/or a bearer token (either is accepted when both are set).
+	TLSCertFile       string `mapstructure:"tls-cert-file"`
+	TLSKeyFile        string `mapstructure:"tls-key-file"`
+	BasicAuthUser     string `mapstructure:"basic-auth-user"`
+	BasicAuthPassword string `mapstructure:"basic-auth-password"`
+	BearerToken       string `mapstructure:"bearer-token"`
+
+	// Debug also serves the collector's state at /debug/freader and expvar at /debug/vars.
+	Debug bool `mapstructure:"debug"`
+}
+
+// Validate checks that the TLS and basic-auth settings are complete.
+func (c Config) Validate() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("prometheus.tls-cert-file and prometheus.tls-key-file must be set together")
+	}
+	if c.BasicAuthUser != "" && c.BasicAuthPassword == "" {
+		return errors.New("prometheus.basic-auth-password is required with prometheus.basic-auth-user")
+	}
+	return nil
 }
@@ -23,7 +23,7 @@ var (
 			Namespace: "freader",
 			Subsystem: "sink",
 			Name:      "dropped_total",
-			Help:      "Total number of lines dropped before enqueue (filtered or buffer_full).",
+			Help:      "Total number of lines dropped (filtered or buffer_full before enqueue, or unmapped).",
 		},
 		[]string{"sink", "reason"},
 	)
@@ -65,6 +65,106 @@ var (
 		},
 		[]string{"sink"},
 	)
+	retriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "freader",
+			Subsystem: "sink",
+			Name:      "retries_total",
+			Help:      "Total number of flushes retried after a failure.",
+		},
+		[]string{"sink"},
+	)
+	routeOverflowTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "freader",
+			Subsystem: "sink",
+			Name:      "route_overflow_total",
+			Help:      "Total number of records sent to sink.route-fallback because their templated index or table was past sink.route-limit.",
+		},
+		[]string{"sink"},
+	)
+	lastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "freader",
+			Subsystem: "sink",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last successful flush.",
+		},
+		[]string{"sink"},
+	)
+	queueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "freader",
+			Subsystem: "sink",
+			Name:      "queue_depth",
+			Help:      "Lines waiting in the sink buffer to be batched.",
+		},
+		[]string{"sink"},
+	)
+	queueCapacity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "freader",
+			Subsystem: "sink",
+			Name:      "queue_capacity",
+			Help:      "Capacity of the sink buffer; lines are dropped with reason buffer_full beyond it.",
+		},
+		[]string{"sink"},
+	)
+
+	// Parser outcomes, so format drift shows up as a rising failure ratio
+	parserRecordsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "freader",
+			Subsystem: "parser",
+			Name:      "records_total",
+			Help:      "Total number of records handed to a parser (auditd, cri, docker-json, timestamp or plugin:<file>).",
+		},
+		[]string{"parser"},
+	)
+	parserFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "freader",
+			Subsystem: "parser",
+			Name:      "failures_total",
+			Help:      "Total number of records a parser could not parse.",
+		},
+		[]string{"parser"},
+	)
+
+	// Backfill progress gauges, set while a one-shot or from-beginning run catches up
+	backfillBytesRead = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "freader",
+			Subsystem: "backfill",
+			Name:      "bytes_read",
+			Help:      "Bytes read so far from the files being backfilled.",
+		},
+	)
+	backfillBytesTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "freader",
+			Subsystem: "backfill",
+			Name:      "bytes_total",
+			Help:      "Current total size of the files being backfilled.",
+		},
+	)
+	backfillETA = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "freader",
+			Subsystem: "backfill",
+			Name:      "eta_seconds",
+			Help:      "Estimated seconds until the backfill catches up at the recent read rate; -1 when unknown.",
+		},
+	)
+	backfillFileProgress = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "freader",
+			Subsystem: "backfill",
+			Name:      "file_progress_ratio",
+			Help:      "Fraction of each backfilled file read so far (0-1).",
+		},
+		[]string{"path"},
+	)
 )
 
 // Register registers sink-related metrics to the provided Prometheus registerer.
@@ -72,6 +172,8 @@ var (
 func Register(r prometheus.Registerer) error {
 	collectors := []prometheus.Collector{
 		enqueuedTotal, droppedTotal, flushTotal, flushFailuresTotal, batchSize, flushDuration,
+		retriesTotal, routeOverflowTotal, lastSuccess, queueDepth, queueCapacity, parserRecordsTotal, parserFailuresTotal,
+		backfillBytesRead, backfillBytesTotal, backfillETA, backfillFileProgress,
 	}
 	for _, c := range collectors {
 		if err := r.Register(c); err != nil {
@@ -104,7 +206,8 @@ func SinkDropped(sink, reason string) {
 	droppedTotal.WithLabelValues(sink, reason).Inc()
 }
 
-// SinkFlushObserve records a flush metrics set: batch size, duration, and success/failure counts.
+// SinkFlushObserve records a flush metrics set: batch size, duration, success/failure
+// counts and, on success, the last-success timestamp.
 func SinkFlushObserve(sink string, size int, dur time.Duration, success bool) {
 	if sink == "" {
 		sink = "unknown"
@@ -114,7 +217,59 @@ func SinkFlushObserve(sink string, size int, dur time.Duration, success bool) {
 		flushTotal.WithLabelValues(sink).Inc()
 	}
 	flushDuration.WithLabelValues(sink).Observe(dur.Seconds())
-	if !success {
+	if success {
+		lastSuccess.WithLabelValues(sink).SetToCurrentTime()
+	} else {
 		flushFailuresTotal.WithLabelValues(sink).Inc()
 	}
 }
+
+// SinkRetried increments the retry counter for a sink.
+func SinkRetried(sink string) {
+	if sink == "" {
+		sink = "unknown"
+	}
+	retriesTotal.WithLabelValues(sink).Inc()
+}
+
+// SinkRouteOverflow increments the counter of records a sink sent to its route
+// fallback.
+func SinkRouteOverflow(sink string) {
+	if sink == "" {
+		sink = "unknown"
+	}
+	routeOverflowTotal.WithLabelValues(sink).Inc()
+}
+
+// SinkQueue records the number of buffered lines of a sink and the buffer capacity.
+func SinkQueue(sink string, depth, capacity int) {
+	if sink == "" {
+		sink = "unknown"
+	}
+	queueDepth.WithLabelValues(sink).Set(float64(depth))
+	queueCapacity.WithLabelValues(sink).Set(float64(capacity))
+}
+
+// ParserObserve counts a record handed to parser and, unless ok, its failure.
+func ParserObserve(parser string, ok bool) {
+	parserRecordsTotal.WithLabelValues(parser).Inc()
+	if !ok {
+		parserFailuresTotal.WithLabelValues(parser).Inc()
+	}
+}
+
+// BackfillProgress records overall backfill progress; a negative eta means unknown.
+func BackfillProgress(read, total int64, eta time.Duration) {
+	backfillBytesRead.Set(float64(read))
+	backfillBytesTotal.Set(float64(total))
+	if eta < 0 {
+		backfillETA.Set(-1)
+	} else {
+		backfillETA.Set(eta.Seconds())
+	}
+}
+
+// BackfillFileProgress records the fraction of the file at path read so far.
+func BackfillFileProgress(path string, ratio float64) {
+	backfillFileProgress.WithLabelValues(path).Set(ratio)
+}
@@ -136,3 +136,68 @@ func TestSinkMetrics(t *testing.T) {
 		t.Fatalf("flush_duration_seconds sum did not increase: before=%v after=%v", dSum, dSum2)
 	}
 }
+
+func TestSinkQueueRetryAndLastSuccess(t *testing.T) {
+	SinkQueue("sinkB", 3, 10)
+	if got := testutil.ToFloat64(queueDepth.WithLabelValues("sinkB")); got != 3 {
+		t.Fatalf("queue_depth = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(queueCapacity.WithLabelValues("sinkB")); got != 10 {
+		t.Fatalf("queue_capacity = %v, want 10", got)
+	}
+
+	SinkRetried("sinkB")
+	SinkRetried("sinkB")
+	if got := getCounterVecValue(t, retriesTotal, "sinkB"); got != 2 {
+		t.Fatalf("retries_total = %v, want 2", got)
+	}
+
+	SinkRouteOverflow("sinkB")
+	if got := getCounterVecValue(t, routeOverflowTotal, "sinkB"); got != 1 {
+		t.Fatalf("route_overflow_total = %v, want 1", got)
+	}
+
+	SinkFlushObserve("sinkB", 1, time.Millisecond, false)
+	if got := testutil.ToFloat64(lastSuccess.WithLabelValues("sinkB")); got != 0 {
+		t.Fatalf("last_success_timestamp_seconds = %v after a failure, want 0", got)
+	}
+	before := float64(time.Now().Unix())
+	SinkFlushObserve("sinkB", 1, time.Millisecond, true)
+	if got := testutil.ToFloat64(lastSuccess.WithLabelValues("sinkB")); got < before {
+		t.Fatalf("last_success_timestamp_seconds = %v, want >= %v", got, before)
+	}
+}
+
+func TestBackfillProgress(t *testing.T) {
+	BackfillProgress(250, 1000, 30*time.Second)
+	if got := testutil.ToFloat64(backfillBytesRead); got != 250 {
+		t.Fatalf("bytes_read = %v, want 250", got)
+	}
+	if got := testutil.ToFloat64(backfillBytesTotal); got != 1000 {
+		t.Fatalf("bytes_total = %v, want 1000", got)
+	}
+	if got := testutil.ToFloat64(backfillETA); got != 30 {
+		t.Fatalf("eta_seconds = %v, want 30", got)
+	}
+	BackfillProgress(250, 1000, -1)
+	if got := testutil.ToFloat64(backfillETA); got != -1 {
+		t.Fatalf("eta_seconds = %v, want -1 when unknown", got)
+	}
+
+	BackfillFileProgress("/var/log/a.log", 0.5)
+	if got := testutil.ToFloat64(backfillFileProgress.WithLabelValues("/var/log/a.log")); got != 0.5 {
+		t.Fatalf("file_progress_ratio = %v, want 0.5", got)
+	}
+}
+
+func TestParserObserve(t *testing.T) {
+	ParserObserve("cri-test", true)
+	ParserObserve("cri-test", false)
+	ParserObserve("cri-test", true)
+	if got := getCounterVecValue(t, parserRecordsTotal, "cri-test"); got != 3 {
+		t.Fatalf("records_total = %v, want 3", got)
+	}
+	if got := getCounterVecValue(t, parserFailuresTotal, "cri-test"); got != 1 {
+		t.Fatalf("failures_total = %v, want 1", got)
+	}
+}
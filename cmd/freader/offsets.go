@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/loykin/freader"
+	"github.com/spf13/cobra"
+)
+
+// newOffsetsCmd returns the "offsets" command group for maintaining the offsets store.
+func newOffsetsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offsets",
+		Short: "Inspect and maintain the offsets store",
+	}
+	cmd.AddCommand(newOffsetsMigrateCmd(), newOffsetsExportCmd(), newOffsetsImportCmd())
+	return cmd
+}
+
+func newOffsetsMigrateCmd() *cobra.Command {
+	m := freader.OffsetMigration{DBPath: "collector.db"}
+	cmd := &cobra.Command{
+		Use:   "migrate --from <strategy> --to <strategy>",
+		Short: "Rewrite stored offsets for a different fingerprint strategy",
+		Long: `Recompute fingerprints for files that are still on disk and rewrite their stored
+offsets under the new strategy, so --fingerprint-strategy can be changed without
+re-ingesting everything. Stop freader before migrating.
+
+Offsets of files that are missing, no longer match the stored fingerprint (rotated)
+or are too small for the new fingerprint are reported and left untouched.
+
+Example:
+  freader offsets migrate --db-path collector.db --from deviceAndInode --to checksum`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if m.Separator, err = unescapeSeparator(m.Separator); err != nil {
+				return err
+			}
+			results, err := freader.MigrateOffsets(m)
+			printMigration(cmd.OutOrStdout(), results, m.DryRun)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&m.DBPath, "db-path", m.DBPath, "Path to offsets SQLite DB")
+	cmd.Flags().StringVar(&m.From, "from", "", "Fingerprint strategy the offsets were stored with")
+	cmd.Flags().StringVar(&m.To, "to", "", "Fingerprint strategy to migrate to")
+	cmd.Flags().IntVar(&m.FromFingerprintSize, "from-fingerprint-size", 0, "Fingerprint size used with --from (0 = 1024)")
+	cmd.Flags().IntVar(&m.ToFingerprintSize, "to-fingerprint-size", 0, "Fingerprint size to use with --to (0 = 1024)")
+	cmd.Flags().Int64Var(&m.FromFingerprintOffset, "from-fingerprint-offset", 0, "Checksum fingerprint offset used with --from")
+	cmd.Flags().Int64Var(&m.ToFingerprintOffset, "to-fingerprint-offset", 0, "Checksum fingerprint offset to use with --to")
+	cmd.Flags().StringVar(&m.Separator, "separator", "\n", "Record separator for the checksumSeparator strategy")
+	cmd.Flags().BoolVar(&m.DryRun, "dry-run", false, "Report what would be migrated without writing")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func printMigration(w io.Writer, results []freader.MigratedOffset, dryRun bool) {
+	migrated := 0
+	for _, r := range results {
+		if r.Err != nil {
+			_, _ = fmt.Fprintf(w, "skipped   %s (offset %d): %v\n", r.Path, r.Offset, r.Err)
+			continue
+		}
+		migrated++
+		_, _ = fmt.Fprintf(w, "migrated  %s (offset %d): %s -> %s\n", r.Path, r.Offset, r.OldID, r.NewID)
+	}
+	verb := "migrated"
+	if dryRun {
+		verb = "would migrate"
+	}
+	_, _ = fmt.Fprintf(w, "%s %d of %d offsets\n", verb, migrated, len(results))
+}
+
+func newOffsetsExportCmd() *cobra.Command {
+	dbPath := "collector.db"
+	var strategy string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write the stored offsets to stdout as JSON",
+		Long: `Write a portable JSON snapshot of the offsets store to stdout, for a backup before
+upgrades or to move state to another host with "freader offsets import".
+
+Example:
+  freader offsets export --db-path collector.db > offsets.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := freader.ExportOffsets(dbPath, strategy, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "exported %d offsets\n", n)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dbPath, "db-path", dbPath, "Path to offsets SQLite DB")
+	cmd.Flags().StringVar(&strategy, "strategy", "", "Only export offsets of this fingerprint strategy")
+	return cmd
+}
+
+// Position file formats accepted by "offsets import".
+const (
+	importFormatJSON     = "json"
+	importFormatFilebeat = "filebeat"
+	importFormatPromtail = "promtail"
+)
+
+func newOffsetsImportCmd() *cobra.Command {
+	defaults := DefaultConfig().Collector
+	im := freader.OffsetImport{
+		DBPath:            "collector.db",
+		Strategy:          defaults.FingerprintStrategy,
+		FingerprintSize:   defaults.FingerprintSize,
+		FingerprintOffset: defaults.FingerprintOffset,
+	}
+	format := importFormatJSON
+	var replace bool
+	cmd := &cobra.Command{
+		Use:   "import [--format json|filebeat|promtail] <path>",
+		Short: "Restore a JSON snapshot or import positions from Filebeat or Promtail",
+		Long: `Restore offsets from a snapshot written by "freader offsets export" (path "-" reads
+stdin), or convert the read positions of another log shipper into stored offsets, so
+moving to freader does not re-ingest everything that agent already shipped. Stop
+freader (and the other agent) before importing.
+
+  json      an export snapshot; offsets are stored as exported, merged with the
+            existing ones unless --replace is given
+  filebeat  the registry directory (data/registry/filebeat) or a registry file;
+            log and filestream inputs are supported
+  promtail  the positions.yaml file
+
+Snapshot offsets keep their fingerprints: checksum-based ones carry over to another
+host, device+inode ones only match on the filesystem they were exported from.
+
+For filebeat and promtail, files are fingerprinted with the given strategy, which
+must match the configuration freader will run with. Positions of files that are
+missing, were rotated since (their inode changed, when recorded) or are shorter than
+the offset are reported and skipped.
+
+Examples:
+  freader offsets import --db-path collector.db offsets.json
+  freader offsets import --format filebeat --db-path collector.db /var/lib/filebeat/registry/filebeat`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			switch format {
+			case importFormatJSON:
+				return restoreSnapshot(cmd, im.DBPath, args[0], replace)
+			case importFormatFilebeat:
+				im.Positions, err = freader.ReadFilebeatRegistry(args[0])
+			case importFormatPromtail:
+				im.Positions, err = freader.ReadPromtailPositions(args[0])
+			default:
+				return fmt.Errorf("invalid format %q (want %s, %s or %s)", format, importFormatJSON, importFormatFilebeat, importFormatPromtail)
+			}
+			if err != nil {
+				return err
+			}
+			if im.Separator, err = unescapeSeparator(im.Separator); err != nil {
+				return err
+			}
+			results, err := freader.ImportOffsets(im)
+			printImport(cmd.OutOrStdout(), results, im.DryRun)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", format, "Input format: json (export snapshot), filebeat or promtail")
+	cmd.Flags().StringVar(&im.DBPath, "db-path", im.DBPath, "Path to offsets SQLite DB")
+	cmd.Flags().StringVar(&im.Strategy, "fingerprint-strategy", im.Strategy, "Fingerprint strategy freader runs with")
+	cmd.Flags().IntVar(&im.FingerprintSize, "fingerprint-size", im.FingerprintSize, "Fingerprint size freader runs with")
+	cmd.Flags().Int64Var(&im.FingerprintOffset, "fingerprint-offset", im.FingerprintOffset, "Checksum fingerprint offset freader runs with")
+	cmd.Flags().StringVar(&im.Separator, "separator", "\n", "Record separator for the checksumSeparator strategy")
+	cmd.Flags().BoolVar(&im.DryRun, "dry-run", false, "Report what would be imported without writing (filebeat/promtail)")
+	cmd.Flags().BoolVar(&replace, "replace", false, "Delete all stored offsets before restoring a json snapshot")
+	return cmd
+}
+
+// restoreSnapshot restores the JSON snapshot at path ("-" for stdin) into the store.
+func restoreSnapshot(cmd *cobra.Command, dbPath, path string, replace bool) error {
+	in := cmd.InOrStdin()
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+	n, err := freader.RestoreOffsets(dbPath, in, replace)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "restored %d offsets\n", n)
+	return nil
+}
+
+func printImport(w io.Writer, results []freader.ImportedOffset, dryRun bool) {
+	imported := 0
+	for _, r := range results {
+		if r.Err != nil {
+			_, _ = fmt.Fprintf(w, "skipped   %s (offset %d): %v\n", r.Path, r.Offset, r.Err)
+			continue
+		}
+		imported++
+		_, _ = fmt.Fprintf(w, "imported  %s (offset %d): %s\n", r.Path, r.Offset, r.ID)
+	}
+	verb := "imported"
+	if dryRun {
+		verb = "would import"
+	}
+	_, _ = fmt.Fprintf(w, "%s %d of %d positions\n", verb, imported, len(results))
+}
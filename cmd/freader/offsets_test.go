@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/freader/internal/store"
+)
+
+func TestOffsetsMigrateCmd(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+
+	cmd := newOffsetsCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"migrate", "--db-path", dbPath, "--from", "deviceAndInode", "--to", "checksum", "--dry-run"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "would migrate 0 of 0 offsets") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	cmd = newOffsetsCmd()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"migrate", "--db-path", dbPath, "--from", "checksum", "--to", "checksum"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("migrating to the same strategy should fail")
+	}
+}
+
+func TestOffsetsImportCmd(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(logPath, []byte(strings.Repeat("a line of the old agent\n", 4)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	positions := filepath.Join(dir, "positions.yaml")
+	yaml := "positions:\n  " + logPath + ": \"48\"\n  " + filepath.Join(dir, "gone.log") + ": \"1\"\n"
+	if err := os.WriteFile(positions, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (string, error) {
+		cmd := newOffsetsCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(append([]string{"import"}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("--format", "promtail", "--db-path", filepath.Join(dir, "offsets.db"), positions)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out, "imported  "+logPath+" (offset 48)") || !strings.Contains(out, "imported 1 of 2 positions") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	if _, err := run("--format", "fluentd", positions); err == nil {
+		t.Fatal("unknown format should fail")
+	}
+}
+
+func TestOffsetsExportImportCmd(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.db")
+	db, err := store.NewSQLiteStore(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save("abc", "checksum", "/var/log/app.log", 42); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	cmd := newOffsetsCmd()
+	var out, stderr bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"export", "--db-path", src})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `"path": "/var/log/app.log"`) || !strings.Contains(stderr.String(), "exported 1 offsets") {
+		t.Fatalf("unexpected export output: %q / %q", out.String(), stderr.String())
+	}
+
+	// Restore from stdin into a fresh store
+	dst := filepath.Join(dir, "dst.db")
+	snapshot := out.String()
+	cmd = newOffsetsCmd()
+	out.Reset()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetIn(strings.NewReader(snapshot))
+	cmd.SetArgs([]string{"import", "--db-path", dst, "-"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "restored 1 offsets") {
+		t.Fatalf("unexpected import output: %q", out.String())
+	}
+	db, err = store.NewSQLiteStore(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	if offset, found, err := db.Load("abc", "checksum"); err != nil || !found || offset != 42 {
+		t.Fatalf("restored offset = %d, %v, %v", offset, found, err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCollector_Once(t *testing.T) {
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logDir, "app.log"), []byte("first\nsecond\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "out.log")
+	configPath := filepath.Join(outDir, "freader.toml")
+	toml := "[sink]\ntype = \"file\"\n[sink.file]\npath = \"" + out + "\"\n"
+	if err := os.WriteFile(configPath, []byte(toml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func() string {
+		cfg, err := loadWithArgs(t, "--config", configPath, "--once", "--include", logDir, "--fingerprint-strategy", "deviceAndInode",
+			"--store-offsets", "--db-path", filepath.Join(outDir, "offsets.db"))
+		if err != nil {
+			t.Fatalf("LoadFromViper failed: %v", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		if err := runCollector(cfg, make(chan struct{}), nil); err != nil {
+			t.Fatalf("runCollector failed: %v", err)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if got := run(); strings.Count(got, "first") != 1 || strings.Count(got, "second") != 1 {
+		t.Fatalf("first run output = %q", got)
+	}
+	// The second run resumes from the stored offsets and finds nothing new
+	if got := run(); got != "" {
+		t.Fatalf("second run output = %q, want empty", got)
+	}
+}
+
+func TestValidate_OnceWithDockerDiscovery(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Once = true
+	cfg.Discovery.Docker.Enable = true
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "once") {
+		t.Fatalf("Validate error = %v, want once/discovery conflict", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
+)
+
+// errNotAuditRecord is the error reported for lines the auditd parser does not recognize.
+var errNotAuditRecord = errors.New("not an audit record")
+
+// parseErrorRecord is the JSON line written to parser.error-file for a record a parser
+// could not handle.
+type parseErrorRecord struct {
+	Time   string `json:"time"`
+	File   string `json:"file"`
+	Parser string `json:"parser"`
+	Error  string `json:"error"`
+	Line   string `json:"line"`
+}
+
+// parseErrors counts parser outcomes and, with parser.error-file, appends the records
+// that failed to parse with their error to that file. A nil *parseErrors only counts.
+type parseErrors struct {
+	mu    sync.Mutex
+	f     *os.File
+	now   func() time.Time
+	tally func(parser string, ok bool) // also handed every outcome, see withTally
+}
+
+// newParseErrors opens the error file of cfg for appending; it returns nil when none is
+// configured.
+func newParseErrors(cfg ParserConfig) (*parseErrors, error) {
+	if cfg.ErrorFile == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(cfg.ErrorFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &parseErrors{f: f, now: time.Now}, nil
+}
+
+// observe records the outcome of parsing line from path; err is nil on success.
+func (p *parseErrors) observe(parser, path, line string, err error) {
+	cmdmetrics.ParserObserve(parser, err == nil)
+	if p == nil {
+		return
+	}
+	if p.tally != nil {
+		p.tally(parser, err == nil)
+	}
+	if err == nil || p.f == nil {
+		return
+	}
+	b, _ := json.Marshal(parseErrorRecord{
+		Time:   p.now().UTC().Format(time.RFC3339Nano),
+		File:   path,
+		Parser: parser,
+		Error:  err.Error(),
+		Line:   line,
+	})
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.f.Write(append(b, '\n')); err != nil {
+		slog.Warn("failed to write parser.error-file", "error", err)
+	}
+}
+
+// withTally returns p, or a parseErrors without an error file if p is nil, that also
+// hands every outcome to tally.
+func (p *parseErrors) withTally(tally func(parser string, ok bool)) *parseErrors {
+	if p == nil {
+		p = &parseErrors{now: time.Now}
+	}
+	p.tally = tally
+	return p
+}
+
+// reporter returns observe bound to parser, in the form the transforms take.
+func (p *parseErrors) reporter(parser string) func(path, line string, err error) {
+	return func(path, line string, err error) { p.observe(parser, path, line, err) }
+}
+
+// stop closes the error file.
+func (p *parseErrors) stop() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f == nil {
+		return nil
+	}
+	return p.f.Close()
+}
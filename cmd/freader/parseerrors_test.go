@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseErrors_WritesFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.jsonl")
+	p, err := newParseErrors(ParserConfig{ErrorFile: path})
+	if err != nil {
+		t.Fatalf("newParseErrors: %v", err)
+	}
+	p.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	const logPath = "/var/log/containers/web.log"
+	transform := containerTransform(parserTypeCRI, "json", true, nil, p.reporter(parserTypeCRI))
+	if _, ok := transform(logPath, "2024-05-01T12:00:01Z stdout F ok"); !ok {
+		t.Fatal("expected a parsed record")
+	}
+	if _, ok := transform(logPath, "garbage"); ok {
+		t.Fatal("drop-non-matching drops other lines")
+	}
+	p.observe("auditd", "/var/log/audit/audit.log", "type=X", errNotAuditRecord)
+	if err := p.stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 error records, got %q", data)
+	}
+	var rec parseErrorRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("invalid error record %q: %v", lines[0], err)
+	}
+	want := parseErrorRecord{Time: "2024-05-01T12:00:00Z", File: logPath, Parser: "cri", Error: "not a cri record", Line: "garbage"}
+	if rec != want {
+		t.Fatalf("error record = %+v, want %+v", rec, want)
+	}
+	if !strings.Contains(lines[1], `"parser":"auditd"`) {
+		t.Fatalf("unexpected second record %q", lines[1])
+	}
+
+	// A restart appends rather than truncating earlier failures
+	p, err = newParseErrors(ParserConfig{ErrorFile: path})
+	if err != nil {
+		t.Fatalf("newParseErrors: %v", err)
+	}
+	p.observe("timestamp", logPath, "x", errors.New("no time"))
+	_ = p.stop()
+	data, _ = os.ReadFile(path)
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Fatalf("want 3 error records after reopening, got %d", n)
+	}
+}
+
+func TestParseErrors_NilOnlyCounts(t *testing.T) {
+	p, err := newParseErrors(ParserConfig{})
+	if err != nil || p != nil {
+		t.Fatalf("newParseErrors without error-file = %v, %v; want nil", p, err)
+	}
+	p.observe("cri", "a.log", "x", errors.New("bad"))
+	p.reporter("cri")("a.log", "y", nil)
+	if err := p.stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// PluginConfig loads a WebAssembly module implementing the transform ABI:
+//
+//   - it exports its memory as "memory";
+//   - freader_alloc(size i32) -> i32 returns a buffer of size bytes, into which the
+//     host writes the record's file path followed by the record;
+//   - freader_transform(path_ptr, path_len, rec_ptr, rec_len i32) -> i64 returns
+//     out_ptr<<32 | out_len, pointing at zero or more output records, each a
+//     little-endian u32 length followed by that many bytes; 0 drops the record;
+//   - it may import freader.log(ptr, len i32) to log a message.
+//
+// Buffers stay owned by the module; it may reuse them on the next call.
+type PluginConfig struct {
+	Path        string        `mapstructure:"path"`
+	Timeout     time.Duration `mapstructure:"timeout"`       // per record; default 1s
+	MaxMemoryMB int           `mapstructure:"max-memory-mb"` // linear memory limit; default 64
+}
+
+const (
+	defaultPluginTimeout     = time.Second
+	defaultPluginMaxMemoryMB = 64
+)
+
+var (
+	pluginAllocType     = [2][]api.ValueType{{api.ValueTypeI32}, {api.ValueTypeI32}}
+	pluginTransformType = [2][]api.ValueType{{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, {api.ValueTypeI64}}
+)
+
+func (p PluginConfig) validate(i int) error {
+	if p.Path == "" {
+		return fmt.Errorf("parser.plugins[%d].path must be set", i)
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("parser.plugins[%d].timeout must be >= 0", i)
+	}
+	if p.MaxMemoryMB < 0 || p.MaxMemoryMB > 4096 {
+		return fmt.Errorf("parser.plugins[%d].max-memory-mb must be between 0 and 4096", i)
+	}
+	return nil
+}
+
+// plugin runs one module on wazero. Records are transformed one at a time; a trapped
+// or timed out instance is closed and replaced by a fresh one on the next record.
+type plugin struct {
+	name    string
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	timeout time.Duration
+
+	mu   sync.Mutex
+	inst api.Module
+}
+
+func loadPlugin(cfg PluginConfig) (*plugin, error) {
+	bin, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin: %w", err)
+	}
+	maxMB := cfg.MaxMemoryMB
+	if maxMB == 0 {
+		maxMB = defaultPluginMaxMemoryMB
+	}
+	p := &plugin{name: filepath.Base(cfg.Path), timeout: cfg.Timeout}
+	if p.timeout == 0 {
+		p.timeout = defaultPluginTimeout
+	}
+	ctx := context.Background()
+	// Closing on context done makes the per-record timeout interrupt running code
+	p.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(maxMB)*16).
+		WithCloseOnContextDone(true))
+	fail := func(err error) (*plugin, error) {
+		_ = p.runtime.Close(ctx)
+		return nil, fmt.Errorf("plugin %s: %w", cfg.Path, err)
+	}
+	_, err = p.runtime.NewHostModuleBuilder("freader").
+		NewFunctionBuilder().WithFunc(p.log).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	if p.module, err = p.runtime.CompileModule(ctx, bin); err != nil {
+		return fail(err)
+	}
+	if _, ok := p.module.ExportedMemories()["memory"]; !ok {
+		return fail(fmt.Errorf("memory is not exported"))
+	}
+	for name, want := range map[string][2][]api.ValueType{"freader_alloc": pluginAllocType, "freader_transform": pluginTransformType} {
+		def, ok := p.module.ExportedFunctions()[name]
+		if !ok {
+			return fail(fmt.Errorf("%s is not exported", name))
+		}
+		if !slices.Equal(def.ParamTypes(), want[0]) || !slices.Equal(def.ResultTypes(), want[1]) {
+			return fail(fmt.Errorf("%s has type %v -> %v, want %v -> %v", name, def.ParamTypes(), def.ResultTypes(), want[0], want[1]))
+		}
+	}
+	// Instantiate up front so unresolved imports and failing start functions surface at startup
+	if p.inst, err = p.instantiate(ctx); err != nil {
+		return fail(err)
+	}
+	return p, nil
+}
+
+func (p *plugin) instantiate(ctx context.Context) (api.Module, error) {
+	// An empty name lets a replacement instance coexist with the one it replaces
+	return p.runtime.InstantiateModule(ctx, p.module, wazero.NewModuleConfig().WithName(""))
+}
+
+func (p *plugin) log(_ context.Context, m api.Module, ptr, n uint32) {
+	msg, ok := m.Memory().Read(ptr, n)
+	if !ok {
+		slog.Warn("plugin logged out of bounds", "plugin", p.name, "ptr", ptr, "len", n)
+		return
+	}
+	slog.Info(string(msg), "plugin", p.name)
+}
+
+// transform runs the module on one record and returns its output records.
+func (p *plugin) transform(path, rec string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inst == nil {
+		inst, err := p.instantiate(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		p.inst = inst
+	}
+	out, err := p.call(path, rec)
+	if err != nil {
+		_ = p.inst.Close(context.Background())
+		p.inst = nil
+	}
+	return out, err
+}
+
+func (p *plugin) call(path, rec string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	size := uint64(len(path) + len(rec))
+	if size > 1<<31 {
+		return nil, fmt.Errorf("record of %d bytes is too large", len(rec))
+	}
+	res, err := p.inst.ExportedFunction("freader_alloc").Call(ctx, size)
+	if err != nil {
+		return nil, err
+	}
+	mem := p.inst.Memory()
+	ptr := uint32(res[0])
+	recPtr := ptr + uint32(len(path))
+	if !mem.WriteString(ptr, path) || !mem.WriteString(recPtr, rec) {
+		return nil, fmt.Errorf("buffer of %d bytes at %d is out of bounds", size, ptr)
+	}
+	res, err = p.inst.ExportedFunction("freader_transform").Call(ctx, uint64(ptr), uint64(len(path)), uint64(recPtr), uint64(len(rec)))
+	if err != nil {
+		return nil, err
+	}
+	if res[0] == 0 {
+		return nil, nil
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	buf, ok := mem.Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("output of %d bytes at %d is out of bounds", outLen, outPtr)
+	}
+	return decodePluginRecords(buf)
+}
+
+// decodePluginRecords splits length-prefixed output records.
+func decodePluginRecords(buf []byte) ([]string, error) {
+	var out []string
+	for len(buf) > 0 {
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("truncated output record length")
+		}
+		n := binary.LittleEndian.Uint32(buf)
+		buf = buf[4:]
+		if uint64(n) > uint64(len(buf)) {
+			return nil, fmt.Errorf("output record of %d bytes exceeds the %d returned", n, len(buf))
+		}
+		out = append(out, string(buf[:n]))
+		buf = buf[n:]
+	}
+	return out, nil
+}
+
+// plugins chains modules: each output record of one is the input of the next.
+type plugins []*plugin
+
+func loadPlugins(cfgs []PluginConfig) (plugins, error) {
+	var out plugins
+	for _, cfg := range cfgs {
+		p, err := loadPlugin(cfg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// apply runs rec through the chain. A plugin that fails on a record (trap, timeout or
+// malformed output) passes it through unchanged, so data is not lost to a buggy plugin;
+// outcomes are observed by errs as parser "plugin:<file>".
+func (ps plugins) apply(path, rec string, errs *parseErrors) []string {
+	recs := []string{rec}
+	for _, p := range ps {
+		var next []string
+		for _, r := range recs {
+			out, err := p.transform(path, r)
+			errs.observe("plugin:"+p.name, path, r, err)
+			if err != nil {
+				slog.Warn("plugin failed, passing record through", "plugin", p.name, "path", path, "error", err)
+				next = append(next, r)
+				continue
+			}
+			next = append(next, out...)
+		}
+		recs = next
+	}
+	return recs
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pluginLEB(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func pluginSection(id byte, entries ...[]byte) []byte {
+	content := pluginLEB(uint32(len(entries)))
+	for _, e := range entries {
+		content = append(content, e...)
+	}
+	return append(append([]byte{id}, pluginLEB(uint32(len(content)))...), content...)
+}
+
+func pluginName(s string) []byte {
+	return append(pluginLEB(uint32(len(s))), s...)
+}
+
+func pluginCode(body ...byte) []byte {
+	body = append([]byte{0}, append(body, 0x0B)...) // no locals
+	return append(pluginLEB(uint32(len(body))), body...)
+}
+
+// testPluginWasm assembles a plugin whose transform emits every record twice. An empty
+// record is dropped, one starting with '!' traps, '~' loops forever and '#' is logged
+// through freader.log before being emitted.
+func testPluginWasm(transformExport string) []byte {
+	cat := func(parts ...[]byte) []byte {
+		var out []byte
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return out
+	}
+	transform := []byte{
+		0x20, 3, 0x45, 0x04, 0x40, 0x42, 0, 0x0F, 0x0B, // if len == 0: return 0
+		0x20, 2, 0x2D, 0, 0, 0x41, '!', 0x46, 0x04, 0x40, 0x00, 0x0B, // '!': unreachable
+		0x20, 2, 0x2D, 0, 0, 0x41, 0xFE, 0x00, 0x46, 0x04, 0x40, 0x03, 0x40, 0x0C, 0, 0x0B, 0x0B, // '~': loop {}
+		0x20, 2, 0x2D, 0, 0, 0x41, '#', 0x46, 0x04, 0x40, 0x20, 2, 0x20, 3, 0x10, 0, 0x0B, // '#': log(rec)
+		0x41, 0x80, 0x02, 0x20, 3, 0x36, 2, 0, // [256] = len
+		0x41, 0x84, 0x02, 0x20, 2, 0x20, 3, 0xFC, 10, 0, 0, // copy rec to 260
+		0x41, 0x84, 0x02, 0x20, 3, 0x6A, 0x20, 3, 0x36, 2, 0, // [260+len] = len
+		0x41, 0x88, 0x02, 0x20, 3, 0x6A, 0x20, 2, 0x20, 3, 0xFC, 10, 0, 0, // copy rec to 264+len
+		0x20, 3, 0x41, 1, 0x74, 0x41, 8, 0x6A, 0xAD, 0x42, 0x80, 0x80, 0x80, 0x80, 0x80, 0x20, 0x84, // 256<<32 | 8+2*len
+	}
+	return cat(
+		[]byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00},
+		pluginSection(1,
+			[]byte{0x60, 1, 0x7F, 1, 0x7F},
+			[]byte{0x60, 4, 0x7F, 0x7F, 0x7F, 0x7F, 1, 0x7E},
+			[]byte{0x60, 2, 0x7F, 0x7F, 0},
+		),
+		pluginSection(2, cat(pluginName("freader"), pluginName("log"), []byte{0x00, 2})),
+		pluginSection(3, []byte{0}, []byte{1}),
+		pluginSection(5, []byte{0x00, 1}),
+		pluginSection(7,
+			cat(pluginName("memory"), []byte{0x02, 0}),
+			cat(pluginName("freader_alloc"), []byte{0x00, 1}),
+			cat(pluginName(transformExport), []byte{0x00, 2}),
+		),
+		pluginSection(10, pluginCode(0x41, 0x80, 0x08), pluginCode(transform...)),
+	)
+}
+
+func writeTestPlugin(t *testing.T, transformExport string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dup.wasm")
+	if err := os.WriteFile(path, testPluginWasm(transformExport), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPlugins_Apply(t *testing.T) {
+	path := writeTestPlugin(t, "freader_transform")
+	ps, err := loadPlugins([]PluginConfig{{Path: path, Timeout: 50 * time.Millisecond}})
+	if err != nil {
+		t.Fatalf("loadPlugins: %v", err)
+	}
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"hello", []string{"hello", "hello"}},
+		{"", nil},
+		{"#note", []string{"#note", "#note"}},
+		{"!boom", []string{"!boom"}}, // trap: passed through
+		{"after trap", []string{"after trap", "after trap"}},
+		{"~spin", []string{"~spin"}}, // timeout: passed through
+		{"after timeout", []string{"after timeout", "after timeout"}},
+	}
+	for _, c := range cases {
+		if got := ps.apply("/var/log/app.log", c.in, nil); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("apply(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+
+	// Plugins chain: every output of one is transformed by the next
+	ps = append(ps, ps[0])
+	if got := ps.apply("/var/log/app.log", "x", nil); !reflect.DeepEqual(got, []string{"x", "x", "x", "x"}) {
+		t.Fatalf("chained apply = %q", got)
+	}
+
+	// The test module never grows its single page, so a larger record cannot be written
+	long := strings.Repeat("y", 100000)
+	if got := ps.apply("/var/log/app.log", long, nil); len(got) != 1 || got[0] != long {
+		t.Fatalf("record larger than the plugin's memory: got %d records", len(got))
+	}
+}
+
+func TestLoadPlugin_Errors(t *testing.T) {
+	dir := t.TempDir()
+	notWasm := filepath.Join(dir, "plugin.wasm")
+	if err := os.WriteFile(notWasm, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing.wasm"), notWasm, writeTestPlugin(t, "transform")} {
+		if _, err := loadPlugin(PluginConfig{Path: path}); err == nil {
+			t.Fatalf("loadPlugin(%s): expected error", path)
+		}
+	}
+}
+
+func TestDecodePluginRecords(t *testing.T) {
+	got, err := decodePluginRecords([]byte{2, 0, 0, 0, 'h', 'i', 0, 0, 0, 0})
+	if err != nil || !reflect.DeepEqual(got, []string{"hi", ""}) {
+		t.Fatalf("decodePluginRecords = %q, %v", got, err)
+	}
+	for _, bad := range [][]byte{{1, 0}, {5, 0, 0, 0, 'a'}} {
+		if _, err := decodePluginRecords(bad); err == nil {
+			t.Fatalf("decodePluginRecords(%v): expected error", bad)
+		}
+	}
+}
+
+func TestPluginConfig_Validate(t *testing.T) {
+	for _, p := range []PluginConfig{{}, {Path: "a.wasm", Timeout: -1}, {Path: "a.wasm", MaxMemoryMB: 5000}} {
+		cfg := &Config{Parser: ParserConfig{Plugins: []PluginConfig{p}}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "parser.plugins[0]") {
+			t.Fatalf("Validate(%+v) = %v, want a parser.plugins error", p, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/loykin/freader/pkg/parser/container"
+	"github.com/spf13/viper"
+)
+
+// Parser types for container runtime log files.
+const (
+	parserTypeCRI        = "cri"
+	parserTypeDockerJSON = "docker-json"
+)
+
+// presets bundle the settings for running freader as a node agent. They are applied as
+// viper defaults, so flags, environment variables and the config file still override them.
+var presets = map[string]map[string]any{
+	"kubernetes-node": {
+		"collector.include": []string{"/var/log/containers/*.log"},
+		"parser.type":       parserTypeCRI,
+	},
+	"docker-node": {
+		"collector.include": []string{"/var/lib/docker/containers/*/*-json.log"},
+		"parser.type":       parserTypeDockerJSON,
+	},
+}
+
+func applyPreset(v *viper.Viper, name string) error {
+	preset, ok := presets[name]
+	if !ok {
+		names := make([]string, 0, len(presets))
+		for n := range presets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	for key, value := range preset {
+		v.SetDefault(key, value)
+	}
+	return nil
+}
+
+// containerTransform parses CRI or Docker json-file lines, joins partial lines per file
+// and adds the container metadata derived from the file path, completed by enrich when
+// set. Lines that are not container log entries are passed through unless drop is set.
+// Each line's parse outcome goes to report when set.
+func containerTransform(parserType, format string, drop bool, enrich func(path string, md *container.Metadata), report func(path, line string, err error)) func(path, line string) (string, bool) {
+	parse := container.ParseCRI
+	if parserType == parserTypeDockerJSON {
+		parse = container.ParseDockerJSON
+	}
+	errNotMatching := fmt.Errorf("not a %s record", parserType)
+	joiner := &container.Joiner{}
+	return func(path, line string) (string, bool) {
+		rec, ok := parse(line)
+		if report != nil {
+			var err error
+			if !ok {
+				err = errNotMatching
+			}
+			report(path, line, err)
+		}
+		if !ok {
+			if drop {
+				return "", false
+			}
+			return line, true
+		}
+		if rec, ok = joiner.Add(path, rec); !ok {
+			return "", false
+		}
+		if format == "raw" {
+			return rec.Log, true
+		}
+		rec.Metadata = container.MetadataFromPath(path)
+		if enrich != nil {
+			enrich(path, &rec.Metadata)
+		}
+		return rec.JSON(), true
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func loadWithArgs(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	cfg := DefaultConfig()
+	cmd := &cobra.Command{Use: "freader-test"}
+	cfg.SetupFlags(cmd)
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	return cfg, cfg.LoadFromViper(cmd)
+}
+
+func TestLoadFromViper_Preset(t *testing.T) {
+	cfg, err := loadWithArgs(t, "--preset", "kubernetes-node")
+	if err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	if want := []string{"/var/log/containers/*.log"}; !reflect.DeepEqual(cfg.Collector.Include, want) {
+		t.Fatalf("include = %v, want %v", cfg.Collector.Include, want)
+	}
+	if cfg.Parser.Type != parserTypeCRI {
+		t.Fatalf("parser.type = %q, want %q", cfg.Parser.Type, parserTypeCRI)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	// Explicit settings override the preset
+	cfg, err = loadWithArgs(t, "--preset", "docker-node", "--include", "/data/containers/*/*-json.log")
+	if err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	if want := []string{"/data/containers/*/*-json.log"}; !reflect.DeepEqual(cfg.Collector.Include, want) {
+		t.Fatalf("include = %v, want %v", cfg.Collector.Include, want)
+	}
+	if cfg.Parser.Type != parserTypeDockerJSON {
+		t.Fatalf("parser.type = %q, want %q", cfg.Parser.Type, parserTypeDockerJSON)
+	}
+
+	if _, err := loadWithArgs(t, "--preset", "mesos-node"); err == nil || !strings.Contains(err.Error(), "kubernetes-node") {
+		t.Fatalf("expected unknown preset error listing presets, got %v", err)
+	}
+}
+
+func TestContainerTransform(t *testing.T) {
+	const path = "/var/log/containers/web_shop_nginx-8c2d6f1e4b7a9c0d3e5f7a1b2c4d6e8f0a2b4c6d8e0f1a3b5c7d9e1f3a5b7c9d.log"
+
+	transform := containerTransform(parserTypeCRI, "json", false, nil, nil)
+	if _, ok := transform(path, "2024-05-01T12:00:00Z stdout P hello "); ok {
+		t.Fatal("partial line must be held back")
+	}
+	out, ok := transform(path, "2024-05-01T12:00:01Z stdout F world")
+	if !ok {
+		t.Fatal("expected joined record")
+	}
+	for _, want := range []string{`"log":"hello world"`, `"namespace":"shop"`, `"pod":"web"`, `"container":"nginx"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %s in %s", want, out)
+		}
+	}
+	if out, ok := transform(path, "not a cri line"); !ok || out != "not a cri line" {
+		t.Fatalf("non-matching lines pass through, got %q %v", out, ok)
+	}
+
+	transform = containerTransform(parserTypeDockerJSON, "raw", true, nil, nil)
+	if out, ok := transform(path, `{"log":"plain\n","stream":"stdout","time":"2024-05-01T12:00:00Z"}`); !ok || out != "plain" {
+		t.Fatalf("raw format returns the message, got %q %v", out, ok)
+	}
+	if _, ok := transform(path, "garbage"); ok {
+		t.Fatal("drop-non-matching drops other lines")
+	}
+}
+
+func TestLoadFromViper_DockerDiscovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[discovery.docker]
+enable = true
+labels = ["freader.enable=true"]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := loadWithArgs(t, "--config", path)
+	if err != nil {
+		t.Fatalf("LoadFromViper failed: %v", err)
+	}
+	if !cfg.Discovery.Docker.Enable {
+		t.Fatal("discovery not enabled")
+	}
+	if want := []string{"freader.enable=true"}; !reflect.DeepEqual(cfg.Discovery.Docker.Labels, want) {
+		t.Fatalf("labels = %v, want %v", cfg.Discovery.Docker.Labels, want)
+	}
+	if len(cfg.Collector.Include) != 0 {
+		t.Fatalf("default includes must be dropped, got %v", cfg.Collector.Include)
+	}
+	if cfg.Parser.Type != parserTypeDockerJSON {
+		t.Fatalf("parser.type = %q, want %q", cfg.Parser.Type, parserTypeDockerJSON)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/loykin/freader"
+	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
+)
+
+// backfillProgress summarizes how far a backfill has got between two Stats snapshots.
+type backfillProgress struct {
+	Files   int // tracked files with a known size
+	Done    int // files read to their current end
+	Read    int64
+	Total   int64
+	Rate    float64        // bytes per second since the previous snapshot
+	ETA     time.Duration  // at Rate; -1 when unknown
+	Changed []fileProgress // files that advanced since the previous snapshot
+}
+
+// fileProgress is the progress of one file in backfillProgress.
+type fileProgress struct {
+	Path  string
+	Read  int64
+	Total int64
+	ETA   time.Duration // -1 when unknown
+}
+
+// Complete reports whether every tracked file has been read to its end.
+func (p backfillProgress) Complete() bool {
+	return p.Files > 0 && p.Done == p.Files
+}
+
+// measureProgress compares files with the positions of the previous snapshot taken
+// elapsed ago (by file ID) and updates prev to the current positions.
+func measureProgress(files []freader.FileStats, prev map[string]int64, elapsed time.Duration) backfillProgress {
+	var p backfillProgress
+	var delta int64
+	for _, f := range files {
+		if f.Size < 0 {
+			continue
+		}
+		read := min(f.Position, f.Size)
+		p.Files++
+		p.Read += read
+		p.Total += f.Size
+		if read >= f.Size {
+			p.Done++
+		}
+
+		last, seen := prev[f.ID]
+		prev[f.ID] = read
+		if !seen || read == last {
+			continue
+		}
+		d := max(read-last, 0)
+		delta += d
+		p.Changed = append(p.Changed, fileProgress{Path: f.Path, Read: read, Total: f.Size, ETA: eta(f.Size-read, d, elapsed)})
+	}
+	if elapsed > 0 {
+		p.Rate = float64(delta) / elapsed.Seconds()
+	}
+	p.ETA = eta(p.Total-p.Read, delta, elapsed)
+	return p
+}
+
+// eta estimates how long reading remaining bytes takes at read bytes per elapsed.
+func eta(remaining, read int64, elapsed time.Duration) time.Duration {
+	if remaining <= 0 {
+		return 0
+	}
+	if read <= 0 || elapsed <= 0 {
+		return -1
+	}
+	return time.Duration(float64(elapsed) * float64(remaining) / float64(read)).Round(time.Second)
+}
+
+// percent returns read/total as a percentage rounded to one decimal.
+func percent(read, total int64) float64 {
+	if total <= 0 {
+		return 100
+	}
+	return float64(read*1000/total) / 10
+}
+
+// reportProgress logs backfill progress and updates the freader_backfill_* gauges every
+// interval: an overall line plus one line per file that advanced. It returns once every
+// tracked file has been read to its end, or when stop is closed after a final report.
+func reportProgress(stats func() freader.Stats, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := make(map[string]int64)
+	last := time.Now()
+	report := func() backfillProgress {
+		now := time.Now()
+		st := stats()
+		p := measureProgress(st.Files, prev, now.Sub(last))
+		last = now
+
+		for _, f := range st.Files {
+			if f.Size >= 0 {
+				cmdmetrics.BackfillFileProgress(f.Path, percent(min(f.Position, f.Size), f.Size)/100)
+			}
+		}
+		for _, f := range p.Changed {
+			slog.Info("backfill file progress", "path", f.Path, "read", f.Read, "total", f.Total,
+				"percent", percent(f.Read, f.Total), "eta", f.ETA)
+		}
+		slog.Info("backfill progress", "files", p.Files, "done", p.Done, "read", p.Read, "total", p.Total,
+			"percent", percent(p.Read, p.Total), "bytes_per_sec", int64(p.Rate), "eta", p.ETA)
+		cmdmetrics.BackfillProgress(p.Read, p.Total, p.ETA)
+		return p
+	}
+
+	for {
+		select {
+		case <-stop:
+			report()
+			return
+		case <-ticker.C:
+			if report().Complete() {
+				slog.Info("backfill caught up")
+				return
+			}
+		}
+	}
+}
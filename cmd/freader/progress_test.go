@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loykin/freader"
+)
+
+func TestMeasureProgress(t *testing.T) {
+	prev := make(map[string]int64)
+	files := []freader.FileStats{
+		{ID: "a", Path: "/data/a.log", Position: 100, Size: 1000},
+		{ID: "b", Path: "/data/b.log", Position: 500, Size: 500},
+		{ID: "gone", Path: "/data/gone.log", Size: -1},
+	}
+	p := measureProgress(files, prev, 0)
+	if p.Files != 2 || p.Done != 1 || p.Read != 600 || p.Total != 1500 {
+		t.Fatalf("first snapshot = %+v", p)
+	}
+	if p.ETA != -1 || len(p.Changed) != 0 {
+		t.Fatalf("first snapshot has no rate yet: eta=%v changed=%v", p.ETA, p.Changed)
+	}
+
+	// a advances 300 bytes in 10s: 30 B/s, 600 bytes left overall
+	files[0].Position = 400
+	p = measureProgress(files, prev, 10*time.Second)
+	if p.Read != 900 || p.Rate != 30 || p.ETA != 20*time.Second {
+		t.Fatalf("second snapshot = %+v", p)
+	}
+	if len(p.Changed) != 1 || p.Changed[0].Path != "/data/a.log" || p.Changed[0].ETA != 20*time.Second {
+		t.Fatalf("changed = %+v", p.Changed)
+	}
+	if p.Complete() {
+		t.Fatal("complete before a is read")
+	}
+
+	files[0].Position = 1000
+	if p = measureProgress(files, prev, 10*time.Second); !p.Complete() || p.ETA != 0 {
+		t.Fatalf("final snapshot = %+v", p)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	for _, tc := range []struct {
+		read, total int64
+		want        float64
+	}{
+		{0, 1000, 0},
+		{1, 3, 33.3},
+		{1000, 1000, 100},
+		{0, 0, 100},
+	} {
+		if got := percent(tc.read, tc.total); got != tc.want {
+			t.Errorf("percent(%d, %d) = %v, want %v", tc.read, tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestReportProgress(t *testing.T) {
+	stats := func() freader.Stats {
+		return freader.Stats{Files: []freader.FileStats{{ID: "a", Path: "/data/a.log", Position: 10, Size: 10}}}
+	}
+
+	// Returns by itself once everything is read
+	done := make(chan struct{})
+	go func() {
+		reportProgress(stats, 10*time.Millisecond, make(chan struct{}))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reportProgress did not return after catching up")
+	}
+
+	// And when stopped before that
+	stop := make(chan struct{})
+	close(stop)
+	reportProgress(func() freader.Stats { return freader.Stats{} }, time.Hour, stop)
+}
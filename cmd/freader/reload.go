@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+	"github.com/spf13/viper"
+)
+
+// swapSink forwards to a sink that can be replaced while the collector keeps running.
+// It also applies sink.filter, sink.headers and a templated index or table (see
+// recordRoute), so a reload can change them too.
+type swapSink struct {
+	mu      sync.RWMutex
+	sink    Sink
+	cfg     SinkConfig
+	filter  *recordFilter
+	headers *recordHeaders
+	route   *recordRoute
+}
+
+func newSwapSink(s Sink, cfg SinkConfig) *swapSink {
+	// validated in SinkConfig.Validate
+	filter, _ := compileRecordFilter(cfg)
+	headers, _ := compileRecordHeaders(cfg)
+	route, _ := compileRecordRoute(cfg)
+	return &swapSink{sink: s, cfg: cfg, filter: filter, headers: headers, route: route}
+}
+
+func (s *swapSink) Enqueue(line string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.filter != nil && !s.filter.allow("", line) {
+		return
+	}
+	if s.headers != nil || s.route != nil {
+		s.enqueue(Entry{Line: line, IngestTime: time.Now()})
+		return
+	}
+	s.sink.Enqueue(line)
+}
+
+func (s *swapSink) EnqueueEntry(e Entry) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.filter != nil && !s.filter.allow(e.File, e.Line) {
+		return
+	}
+	s.enqueue(e)
+}
+
+// enqueue renders the headers and target of e and hands it to the sink; s.mu is held.
+func (s *swapSink) enqueue(e Entry) {
+	if s.headers != nil {
+		e.Headers = s.headers.render(e)
+	}
+	if s.route != nil {
+		e.Target = s.route.target(e)
+	}
+	s.sink.EnqueueEntry(e)
+}
+
+func (s *swapSink) Stop() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sink.Stop()
+}
+
+// reload builds a sink from cfg and puts it in place of the running one, which keeps
+// serving until the new sink is ready. Entries still queued in the old sink are moved
+// to the new one; the old sink then flushes the batch it is holding and stops. An
+// unchanged configuration keeps the running sink. The collector is set up at startup
+// for a sink that confirms delivery (an Acker) or for one that does not, so a sink can
+// only be replaced by one of the same kind.
+func (s *swapSink) reload(cfg SinkConfig) error {
+	if cfg.Type == "" {
+		return errors.New("sink.type cannot be disabled without a restart")
+	}
+	s.mu.RLock()
+	unchanged := reflect.DeepEqual(s.cfg, cfg)
+	s.mu.RUnlock()
+	if unchanged {
+		slog.Info("sink configuration unchanged")
+		return nil
+	}
+
+	filter, err := compileRecordFilter(cfg)
+	if err != nil {
+		return err
+	}
+	headers, err := compileRecordHeaders(cfg)
+	if err != nil {
+		return err
+	}
+	route, err := compileRecordRoute(cfg)
+	if err != nil {
+		return err
+	}
+	next, err := buildSink(&Config{Sink: cfg})
+	if err != nil {
+		return fmt.Errorf("failed to build sink: %w", err)
+	}
+	s.mu.RLock()
+	_, acked := s.sink.(Acker)
+	s.mu.RUnlock()
+	if _, ok := next.(Acker); ok != acked {
+		if err := next.Stop(); err != nil {
+			slog.Warn("failed to stop the rejected sink", "error", err)
+		}
+		if acked {
+			return fmt.Errorf("sink %q does not confirm delivery like the running %s sink; restart to switch", cfg.Type, s.cfg.Type)
+		}
+		return fmt.Errorf("sink %q confirms delivery, which the running %s sink was not set up for; restart to switch", cfg.Type, s.cfg.Type)
+	}
+	s.mu.Lock()
+	old := s.sink
+	s.sink, s.cfg, s.filter, s.headers, s.route = next, cfg, filter, headers, route
+	s.mu.Unlock()
+
+	requeued := 0
+	if d, ok := old.(common.Drainer); ok {
+		for _, e := range d.Drain() {
+			next.EnqueueEntry(e)
+			if e.Ack != nil {
+				// Counted in again by next if it confirms delivery
+				e.Ack.Done(nil)
+			}
+			requeued++
+		}
+	}
+	if err := old.Stop(); err != nil {
+		slog.Warn("failed to stop the replaced sink", "error", err)
+	}
+	slog.Info("sink reloaded", "type", cfg.Type, "requeued", requeued)
+	return nil
+}
+
+// reloadSinkConfig reads the configuration file and environment again and returns the
+// validated sink section. Other sections only take effect on restart.
+func reloadSinkConfig(configFile string) (SinkConfig, error) {
+	v := viper.GetViper()
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return SinkConfig{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+	next := DefaultConfig()
+	if err := v.Unmarshal(next); err != nil {
+		return SinkConfig{}, err
+	}
+	if err := next.Sink.Validate(); err != nil {
+		return next.Sink, err
+	}
+	return next.Sink, next.validateLabelFilters()
+}
+
+// watchReload reloads the sink configuration on SIGHUP and hands it to runCollector
+// until stop is closed. A configuration that fails to load is logged and skipped.
+func watchReload(configFile string, reload chan<- SinkConfig, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+		}
+		cfg, err := reloadSinkConfig(configFile)
+		if err != nil {
+			slog.Error("config reload failed; keeping the running sink", "error", err)
+			continue
+		}
+		select {
+		case reload <- cfg:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// DefaultRouteLimit is how many distinct indices or tables a templated sink target may
+// render to when sink.route-limit is unset.
+const DefaultRouteLimit = 100
+
+// recordRoute renders a templated sink.opensearch.index or sink.clickhouse.table for
+// each record, e.g. "logs-${labels.app}-${event_time:2006.01.02}", in the sink.headers
+// template syntax. The values it references are made safe for an index or table name,
+// and render as "unknown" when missing. To bound the indices or tables a
+// high-cardinality value (a request ID, say) can create, only the first
+// sink.route-limit targets rendered are used: records rendering to any other go to
+// sink.route-fallback and are counted in freader_sink_route_overflow_total.
+type recordRoute struct {
+	sink      string
+	parts     []templatePart
+	host      string
+	labels    map[string]string
+	useFields bool
+	clean     func(string) string
+	limit     int
+	fallback  string
+
+	mu       sync.Mutex
+	targets  map[string]bool
+	overflow bool // logged the first record sent to the fallback
+}
+
+// compileRecordRoute returns the route of a templated index or table, or nil when the
+// sink's target is not a template.
+func compileRecordRoute(cfg SinkConfig) (*recordRoute, error) {
+	var name, tmpl string
+	var clean func(string) string
+	switch cfg.Type {
+	case "opensearch":
+		name, tmpl, clean = "sink.opensearch.index", cfg.OpenSearch.Index, cleanIndexName
+	case "clickhouse":
+		name, tmpl, clean = "sink.clickhouse.table", cfg.ClickHouse.Table, cleanTableName
+	}
+	if !common.IsTemplate(tmpl) {
+		if cfg.RouteLimit != 0 || cfg.RouteFallback != "" {
+			return nil, fmt.Errorf("sink.route-limit and sink.route-fallback require a templated sink.opensearch.index or sink.clickhouse.table")
+		}
+		return nil, nil
+	}
+	parts, err := parseHeaderTemplate(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if cfg.RouteLimit < 0 {
+		return nil, fmt.Errorf("sink.route-limit must be >= 0")
+	}
+	if common.IsTemplate(cfg.RouteFallback) {
+		return nil, fmt.Errorf("sink.route-fallback must not be a template")
+	}
+	r := &recordRoute{
+		sink:      cfg.Type,
+		parts:     parts,
+		host:      cfg.host(),
+		labels:    cfg.Labels,
+		useFields: usesFields(parts),
+		clean:     clean,
+		limit:     cfg.RouteLimit,
+		fallback:  cfg.RouteFallback,
+		targets:   make(map[string]bool),
+	}
+	if r.limit == 0 {
+		r.limit = DefaultRouteLimit
+	}
+	if r.fallback == "" {
+		// The template with every reference rendered as "other", e.g. "logs-other-other"
+		r.fallback = expandTemplate(parts, Entry{}, "", nil, nil, func(string) string { return "other" })
+	}
+	return r, nil
+}
+
+// target returns the index or table the record e goes to.
+func (r *recordRoute) target(e Entry) string {
+	var fields map[string]any
+	if r.useFields {
+		fields = decodeFields(e.Line)
+	}
+	t := expandTemplate(r.parts, e, r.host, r.labels, fields, r.clean)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.targets[t] {
+		return t
+	}
+	if len(r.targets) >= r.limit {
+		if !r.overflow {
+			r.overflow = true
+			slog.Warn("sink route limit reached, sending records for further targets to the fallback",
+				"sink", r.sink, "limit", r.limit, "target", t, "fallback", r.fallback)
+		}
+		cmdmetrics.SinkRouteOverflow(r.sink)
+		return r.fallback
+	}
+	r.targets[t] = true
+	return t
+}
+
+// cleanIndexName makes v usable in an OpenSearch index name: lower case, without the
+// characters index names cannot contain.
+func cleanIndexName(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\/*?"<>|,#: `, r) {
+			return '_'
+		}
+		return r
+	}, strings.ToLower(v))
+}
+
+// cleanTableName makes v usable in an unquoted ClickHouse table name: ASCII letters,
+// digits and underscores.
+func cleanTableName(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, v)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cmdclick "github.com/loykin/freader/cmd/freader/sink/clickhouse"
+	cmdos "github.com/loykin/freader/cmd/freader/sink/opensearch"
+)
+
+func TestRecordRoute_Target(t *testing.T) {
+	r, err := compileRecordRoute(SinkConfig{
+		Type:       "opensearch",
+		Labels:     map[string]string{"team": "Payments"},
+		RouteLimit: 3,
+		OpenSearch: cmdos.Config{Index: "logs-${labels.team}-${fields.app}-${event_time:2006.01.02}"},
+	})
+	if err != nil {
+		t.Fatalf("compileRecordRoute: %v", err)
+	}
+	day := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{Line: `{"app":"API/v2"}`, EventTime: day}, "logs-payments-api_v2-2024.05.01"},
+		{Entry{Line: "plain", IngestTime: day.Add(2 * time.Hour)}, "logs-payments-unknown-2024.05.02"},
+		{Entry{Line: `{"app":"web"}`, EventTime: day}, "logs-payments-web-2024.05.01"},
+		// Past the limit of 3 targets
+		{Entry{Line: `{"app":"worker"}`, EventTime: day}, "logs-other-other-other"},
+		{Entry{Line: `{"app":"web"}`, EventTime: day}, "logs-payments-web-2024.05.01"},
+	} {
+		if got := r.target(tc.entry); got != tc.want {
+			t.Fatalf("target(%q) = %q, want %q", tc.entry.Line, got, tc.want)
+		}
+	}
+
+	r, err = compileRecordRoute(SinkConfig{
+		Type:          "clickhouse",
+		RouteFallback: "logs.overflow",
+		RouteLimit:    1,
+		ClickHouse:    cmdclick.Config{Table: "logs.app_${fields.app}"},
+	})
+	if err != nil {
+		t.Fatalf("compileRecordRoute: %v", err)
+	}
+	if got := r.target(Entry{Line: `{"app":"api-gw.eu"}`}); got != "logs.app_api_gw_eu" {
+		t.Fatalf("target = %q", got)
+	}
+	if got := r.target(Entry{Line: `{"app":"web"}`}); got != "logs.overflow" {
+		t.Fatalf("target past the limit = %q, want the fallback", got)
+	}
+
+	if r, err := compileRecordRoute(SinkConfig{Type: "opensearch", OpenSearch: cmdos.Config{Index: "logs"}}); r != nil || err != nil {
+		t.Fatalf("expected no route for a plain index, got %v, %v", r, err)
+	}
+}
+
+func TestRecordRoute_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  SinkConfig
+		want string
+	}{
+		{SinkConfig{Type: "opensearch", OpenSearch: cmdos.Config{Index: "logs-${labels.app"}}, "unterminated"},
+		{SinkConfig{Type: "clickhouse", ClickHouse: cmdclick.Config{Table: "logs_${message}"}}, "unknown reference"},
+		{SinkConfig{Type: "opensearch", OpenSearch: cmdos.Config{Index: "logs-${file}"}, RouteLimit: -1}, "route-limit"},
+		{SinkConfig{Type: "opensearch", OpenSearch: cmdos.Config{Index: "logs-${file}"}, RouteFallback: "x-${host}"}, "must not be a template"},
+		{SinkConfig{Type: "opensearch", OpenSearch: cmdos.Config{Index: "logs"}, RouteLimit: 10}, "require a templated"},
+	} {
+		_, err := compileRecordRoute(tc.cfg)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("compileRecordRoute(%+v) = %v, want error containing %q", tc.cfg, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unescapeSeparator interprets Go-style escapes in a separator given on the command
+// line, where a real newline or NUL byte is awkward or impossible to pass: `\n`, `\r`,
+// `\t`, `\x00`, `\\` and the shorthand `\0` for NUL (find -print0 style output).
+// Separators without a backslash are returned unchanged; a trailing unpaired
+// backslash is an error.
+func unescapeSeparator(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	if trailing := len(s) - len(strings.TrimRight(s, `\`)); trailing%2 == 1 {
+		return "", fmt.Errorf("separator %q ends with an unpaired backslash (write \\\\ for a literal one)", s)
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			b.WriteString(`\"`)
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '0' && (i+2 == len(s) || s[i+2] < '0' || s[i+2] > '7'):
+			b.WriteString(`\x00`)
+			i++
+		case s[i] == '\\':
+			b.WriteByte(s[i])
+			b.WriteByte(s[i+1])
+			i++
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	out, err := strconv.Unquote(`"` + b.String() + `"`)
+	if err != nil {
+		return "", fmt.Errorf("invalid escape in separator %q", s)
+	}
+	return out, nil
+}
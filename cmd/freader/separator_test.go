@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestUnescapeSeparator(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "<END>", want: "<END>"},
+		{in: "\n", want: "\n"},
+		{in: `\n`, want: "\n"},
+		{in: `\r\n`, want: "\r\n"},
+		{in: `\0`, want: "\x00"},
+		{in: `\x00`, want: "\x00"},
+		{in: `\000`, want: "\x00"},
+		{in: `a"\0`, want: "a\"\x00"},
+		{in: `\\`, want: `\`},
+		{in: `\q`, wantErr: true},
+		{in: `\`, wantErr: true},
+		{in: `<END>\`, wantErr: true},
+		{in: `\\\`, wantErr: true},
+		{in: `<END>\\`, want: `<END>\`},
+	}
+	for _, tt := range tests {
+		got, err := unescapeSeparator(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("unescapeSeparator(%q) expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("unescapeSeparator(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
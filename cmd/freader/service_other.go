@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// addServiceCmd registers the "service" command; native services are Windows-only,
+// elsewhere use a systemd unit (see README).
+func addServiceCmd(root *cobra.Command) {}
+
+// isService reports whether the process was started by the Windows service manager.
+func isService() bool { return false }
+
+func runService(config *Config) error {
+	return errors.New("running as a service is only supported on Windows")
+}
@@ -0,0 +1,227 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const defaultServiceName = "freader"
+
+// serviceName is the name the process was installed under; install passes it back
+// through the hidden --service-name flag so the event log source matches.
+var serviceName = defaultServiceName
+
+// addServiceCmd registers "service install/uninstall/start/stop" and the hidden
+// --service-name flag used when the service manager starts freader.
+func addServiceCmd(root *cobra.Command) {
+	root.Flags().StringVar(&serviceName, "service-name", defaultServiceName, "Windows service name (set by service install)")
+	_ = root.Flags().MarkHidden("service-name")
+
+	var name string
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Manage freader as a native Windows service",
+	}
+	cmd.PersistentFlags().StringVar(&name, "name", defaultServiceName, "Service name")
+
+	install := &cobra.Command{
+		Use:   "install [-- freader flags...]",
+		Short: "Install freader as an automatically started service",
+		Long: `Install freader as a Windows service started at boot. Arguments after -- are
+passed to freader when the service starts; use absolute paths, e.g.
+
+  freader service install -- --config C:\freader\config.toml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := installService(name, args); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "service %s installed\n", name)
+			return nil
+		},
+	}
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the service and its event log source",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := uninstallService(name); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "service %s removed\n", name)
+			return nil
+		},
+	}
+	start := &cobra.Command{
+		Use:   "start",
+		Short: "Start the installed service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withService(name, func(s *mgr.Service) error { return s.Start() })
+		},
+	}
+	stop := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the service and wait until it has stopped",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withService(name, stopService)
+		},
+	}
+	cmd.AddCommand(install, uninstall, start, stop)
+	root.AddCommand(cmd)
+}
+
+func installService(name string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = m.Disconnect() }()
+	if s, err := m.OpenService(name); err == nil {
+		_ = s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+
+	args = append([]string{"--service-name", name}, args...)
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "freader log collector",
+		Description: "Tails log files and forwards records to the configured sink.",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = s.Close() }()
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		_ = s.Delete()
+		return fmt.Errorf("failed to register event log source: %w", err)
+	}
+	return nil
+}
+
+func uninstallService(name string) error {
+	err := withService(name, func(s *mgr.Service) error { return s.Delete() })
+	if err != nil {
+		return err
+	}
+	if err := eventlog.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove event log source: %w", err)
+	}
+	return nil
+}
+
+func withService(name string, fn func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = m.Disconnect() }()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s: %w", name, err)
+	}
+	defer func() { _ = s.Close() }()
+	return fn(s)
+}
+
+func stopService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isService reports whether the process was started by the Windows service manager.
+func isService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// runService runs the collector under the service manager, logging to the event log.
+func runService(config *Config) error {
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = elog.Close() }()
+	slog.SetDefault(slog.New(newEventLogHandler(elog)))
+	return svc.Run(serviceName, &service{config: config})
+}
+
+// newEventLogHandler writes records to the Windows event log with the matching type.
+func newEventLogHandler(elog *eventlog.Log) *recordHandler {
+	return newRecordHandler(nil, func(level slog.Level, line []byte) error {
+		msg := string(line)
+		switch {
+		case level >= slog.LevelError:
+			return elog.Error(1, msg)
+		case level >= slog.LevelWarn:
+			return elog.Warning(1, msg)
+		default:
+			return elog.Info(1, msg)
+		}
+	})
+}
+
+// service implements svc.Handler around runCollector.
+type service struct {
+	config *Config
+}
+
+func (s *service) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- runCollector(s.config, stop, nil) }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-done:
+			// The collector exited on its own, e.g. a sink failed to start
+			if err != nil {
+				slog.Error("collector failed", "error", err)
+				return true, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				close(stop)
+				if err := <-done; err != nil {
+					slog.Error("collector failed", "error", err)
+					return true, 1
+				}
+				return false, 0
+			}
+		}
+	}
+}
@@ -8,12 +8,26 @@ import (
 	"github.com/loykin/freader/cmd/freader/sink/clickhouse"
 	"github.com/loykin/freader/cmd/freader/sink/common"
 	"github.com/loykin/freader/cmd/freader/sink/console"
+	execsink "github.com/loykin/freader/cmd/freader/sink/exec"
+	grpcsink "github.com/loykin/freader/cmd/freader/sink/grpc"
+	"github.com/loykin/freader/cmd/freader/sink/influxdb"
 	"github.com/loykin/freader/cmd/freader/sink/opensearch"
+	"github.com/loykin/freader/cmd/freader/sink/sqldb"
+	"github.com/loykin/freader/cmd/freader/sink/unix"
 )
 
 // Sink is the common sink interface from subpackages.
 type Sink = common.Sink
 
+// Acker is implemented by sinks that confirm delivery, see common.Acker.
+type Acker = common.Acker
+
+// Entry is a formatted record with its event and ingest time, see Sink.EnqueueEntry.
+type Entry = common.Entry
+
+// Ack tracks the delivery of a collector batch by an Acker, see common.Ack.
+type Ack = common.Ack
+
 // buildSink constructs and starts a sink based on Config. Returns nil when Sink is disabled.
 func buildSink(cfg *Config) (Sink, error) {
 	switch cfg.Sink.Type {
@@ -34,12 +48,48 @@ func buildSink(cfg *Config) (Sink, error) {
 			return nil, err
 		}
 		return s, nil
+	case "exec":
+		return execsink.New(
+			cfg.Sink.Exec.Command,
+			cfg.Sink.Exec.RestartBackoff,
+			cfg.Sink.batchOptions(),
+			cfg.Sink.Include,
+			cfg.Sink.Exclude,
+		)
+	case "unix":
+		return unix.New(
+			cfg.Sink.Unix.Path,
+			cfg.Sink.Unix.Mode,
+			cfg.Sink.host(),
+			cfg.Sink.Labels,
+			cfg.Sink.batchOptions(),
+			cfg.Sink.Include,
+			cfg.Sink.Exclude,
+		)
+	case "grpc":
+		tlsCfg, err := cfg.Sink.Grpc.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		return grpcsink.New(
+			cfg.Sink.Grpc.Target,
+			cfg.Sink.host(),
+			cfg.Sink.Labels,
+			cfg.Sink.Grpc.AckTimeout,
+			tlsCfg,
+			cfg.Sink.batchOptions(),
+			cfg.Sink.Include,
+			cfg.Sink.Exclude,
+		)
 	case "clickhouse":
-		host := cfg.Sink.Host
-		if host == "" {
-			if h, err := os.Hostname(); err == nil {
-				host = h
-			}
+		host := cfg.Sink.host()
+		tlsCfg, err := cfg.Sink.ClickHouse.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		proxy, err := common.ParseProxyURL(cfg.Sink.ClickHouse.ProxyURL)
+		if err != nil {
+			return nil, err
 		}
 		s, err := clickhouse.New(
 			cfg.Sink.ClickHouse.Addr,
@@ -49,21 +99,26 @@ func buildSink(cfg *Config) (Sink, error) {
 			cfg.Sink.ClickHouse.Password,
 			host,
 			cfg.Sink.Labels,
-			cfg.Sink.BatchSize,
-			cfg.Sink.BatchInterval,
+			cfg.Sink.batchOptions(),
 			cfg.Sink.Include,
 			cfg.Sink.Exclude,
+			tlsCfg,
+			proxy,
+			cfg.Sink.ClickHouse.Compression,
 		)
 		if err != nil {
 			return nil, err
 		}
 		return s, nil
 	case "opensearch":
-		host := cfg.Sink.Host
-		if host == "" {
-			if h, err := os.Hostname(); err == nil {
-				host = h
-			}
+		host := cfg.Sink.host()
+		tlsCfg, err := cfg.Sink.OpenSearch.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		proxy, err := common.ParseProxyURL(cfg.Sink.OpenSearch.ProxyURL)
+		if err != nil {
+			return nil, err
 		}
 		s, err := opensearch.New(
 			cfg.Sink.OpenSearch.URL,
@@ -72,16 +127,71 @@ func buildSink(cfg *Config) (Sink, error) {
 			cfg.Sink.OpenSearch.Password,
 			host,
 			cfg.Sink.Labels,
-			cfg.Sink.BatchSize,
-			cfg.Sink.BatchInterval,
+			cfg.Sink.batchOptions(),
 			cfg.Sink.Include,
 			cfg.Sink.Exclude,
+			tlsCfg,
+			proxy,
+			cfg.Sink.OpenSearch.Compression,
 		)
 		if err != nil {
 			return nil, err
 		}
 		return s, nil
+	case "influxdb":
+		tlsCfg, err := cfg.Sink.InfluxDB.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		proxy, err := common.ParseProxyURL(cfg.Sink.InfluxDB.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		mapping, err := compileInfluxMapping(cfg.Sink)
+		if err != nil {
+			return nil, err
+		}
+		return influxdb.New(
+			cfg.Sink.InfluxDB,
+			mapping.point,
+			cfg.Sink.batchOptions(),
+			cfg.Sink.Include,
+			cfg.Sink.Exclude,
+			tlsCfg,
+			proxy,
+		)
+	case "sql":
+		return sqldb.New(
+			cfg.Sink.SQL,
+			cfg.Sink.host(),
+			cfg.Sink.Labels,
+			cfg.Sink.batchOptions(),
+			cfg.Sink.Include,
+			cfg.Sink.Exclude,
+		)
 	default:
 		return nil, fmt.Errorf("unsupported sink: %s", cfg.Sink.Type)
 	}
 }
+
+// batchOptions collects the batching settings shared by network sinks.
+func (s SinkConfig) batchOptions() common.BatchOptions {
+	return common.BatchOptions{
+		Size:        s.BatchSize,
+		Bytes:       s.BatchBytes,
+		Interval:    s.BatchInterval,
+		Concurrency: s.Concurrency,
+		Ordered:     s.Ordered,
+		Retries:     s.Retries,
+		Backoff:     s.RetryBackoff,
+	}
+}
+
+// host returns the configured host, defaulting to os.Hostname().
+func (s SinkConfig) host() string {
+	if s.Host != "" {
+		return s.Host
+	}
+	h, _ := os.Hostname()
+	return h
+}
@@ -4,13 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"log/slog"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	ch "github.com/ClickHouse/clickhouse-go/v2"
-	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
 	"github.com/loykin/freader/cmd/freader/sink/common"
 )
 
@@ -21,9 +20,11 @@ type Sink struct {
 	table    string
 	host     string
 	labels   map[string]string
+	mu       sync.Mutex
+	created  map[string]bool // routed tables created so far, see ensureTable
 }
 
-func New(addr, database, table, user, pass, host string, labels map[string]string, batchSize int, batchInterval time.Duration, includes, excludes []string) (common.Sink, error) {
+func New(addr, database, table, user, pass, host string, labels map[string]string, batch common.BatchOptions, includes, excludes []string, tlsCfg *tls.Config, proxy *url.URL, compression common.CompressionConfig) (common.Sink, error) {
 	if addr == "" || table == "" {
 		return nil, fmt.Errorf("clickhouse addr and table are required")
 	}
@@ -36,16 +37,30 @@ func New(addr, database, table, user, pass, host string, labels map[string]strin
 		}
 		hostport := u.Host
 		secure := u.Scheme == "https"
-		opts = ch.Options{Addr: []string{hostport}, Protocol: ch.HTTP, Auth: ch.Auth{Username: user, Password: pass, Database: database}}
+		opts = ch.Options{Addr: []string{hostport}, Protocol: ch.HTTP, Auth: ch.Auth{Username: user, Password: pass, Database: database}, HTTPProxyURL: proxy}
 		if secure {
-			opts.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+			opts.TLS = tlsCfg
+			if opts.TLS == nil {
+				opts.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
 		}
 	} else {
-		opts = ch.Options{Addr: []string{addr}, Auth: ch.Auth{Username: user, Password: pass, Database: database}}
+		// Native protocol: TLS is used only when explicitly configured (secure port, e.g. 9440)
+		opts = ch.Options{Addr: []string{addr}, Auth: ch.Auth{Username: user, Password: pass, Database: database}, TLS: tlsCfg}
 	}
-	// Run embedded migrations to ensure table exists
-	if err := runMigrations(&opts, database, table); err != nil {
-		return nil, err
+	switch compression.Method {
+	case "gzip":
+		opts.Compression = &ch.Compression{Method: ch.CompressionGZIP}
+	case "zstd":
+		opts.Compression = &ch.Compression{Method: ch.CompressionZSTD}
+	case "lz4":
+		opts.Compression = &ch.Compression{Method: ch.CompressionLZ4}
+	}
+	// Run embedded migrations to ensure table exists; routed tables are created on first use
+	if !common.IsTemplate(table) {
+		if err := runMigrations(&opts, database, table); err != nil {
+			return nil, err
+		}
 	}
 	// Open insert connection
 	conn, err := ch.Open(&opts)
@@ -53,12 +68,13 @@ func New(addr, database, table, user, pass, host string, labels map[string]strin
 		return nil, err
 	}
 	s := &Sink{
-		batcher:  common.NewBatcher(batchSize, batchInterval, includes, excludes, "clickhouse"),
+		batcher:  common.NewBatcherWithOptions(batch, includes, excludes, "clickhouse"),
 		conn:     conn,
 		database: database,
 		table:    table,
 		host:     host,
 		labels:   labels,
+		created:  make(map[string]bool),
 	}
 	s.start()
 	return s, nil
@@ -68,32 +84,7 @@ func (s *Sink) start() {
 	s.batcher.Wg.Add(1)
 	go func() {
 		defer s.batcher.Wg.Done()
-		buf := make([]string, 0, s.batcher.BatchSize)
-		ticker := time.NewTicker(s.batcher.BatchInterval)
-		defer ticker.Stop()
-		flush := func() {
-			if len(buf) == 0 {
-				return
-			}
-			if err := s.flush(buf); err != nil {
-				slog.Error("clickhouse flush failed", "error", err)
-			}
-			buf = buf[:0]
-		}
-		for {
-			select {
-			case <-s.batcher.StopCh:
-				flush()
-				return
-			case <-ticker.C:
-				flush()
-			case line := <-s.batcher.Ch:
-				buf = append(buf, line)
-				if len(buf) >= s.batcher.BatchSize {
-					flush()
-				}
-			}
-		}
+		s.batcher.RunEntries(s.flush)
 	}()
 }
 
@@ -105,26 +96,75 @@ func (s *Sink) Stop() error {
 
 func (s *Sink) Enqueue(line string) { s.batcher.Enqueue(line) }
 
-func (s *Sink) flush(lines []string) error {
+func (s *Sink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *Sink) Drain() []common.Entry { return s.batcher.Drain() }
+
+// flush inserts each entry with ts as the ingest time, event_time as the event time
+// (the ingest time when unknown) and schema_version as common.SchemaVersion, into the
+// table it was routed to (Entry.Target) or the configured one, one insert per table.
+func (s *Sink) flush(lines []common.Entry) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	tbl := s.table
+	var tables []string
+	byTable := make(map[string][]common.Entry)
+	for _, e := range lines {
+		tbl := s.table
+		if e.Target != "" {
+			tbl = e.Target
+		}
+		if _, ok := byTable[tbl]; !ok {
+			tables = append(tables, tbl)
+		}
+		byTable[tbl] = append(byTable[tbl], e)
+	}
+	for _, tbl := range tables {
+		if err := s.insert(ctx, tbl, byTable[tbl]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sink) insert(ctx context.Context, table string, lines []common.Entry) error {
+	tbl := table
 	if s.database != "" && !strings.Contains(tbl, ".") {
-		tbl = s.database + "." + s.table
+		tbl = s.database + "." + table
 	}
-	start := time.Now()
-	batch, err := s.conn.PrepareBatch(ctx, "INSERT INTO "+tbl+" (ts, host, labels, message)")
+	if table != s.table {
+		if err := s.ensureTable(ctx, tbl); err != nil {
+			return err
+		}
+	}
+	batch, err := s.conn.PrepareBatch(ctx, "INSERT INTO "+tbl+" (ts, event_time, schema_version, host, labels, message)")
 	if err != nil {
-		cmdmetrics.SinkFlushObserve("clickhouse", len(lines), time.Since(start), false)
 		return err
 	}
-	for _, ln := range lines {
-		if err := batch.Append(time.Now(), s.host, s.labels, ln); err != nil {
-			cmdmetrics.SinkFlushObserve("clickhouse", len(lines), time.Since(start), false)
+	for _, e := range lines {
+		if err := batch.Append(e.IngestTime, e.Time(), uint16(common.SchemaVersion), s.host, s.labels, e.Line); err != nil {
 			return err
 		}
 	}
-	err = batch.Send()
-	cmdmetrics.SinkFlushObserve("clickhouse", len(lines), time.Since(start), err == nil)
-	return err
+	return batch.Send()
+}
+
+// ensureTable creates the routed table tbl with the current schema the first time it
+// is written to.
+func (s *Sink) ensureTable(ctx context.Context, tbl string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created[tbl] {
+		return nil
+	}
+	stmts, err := schemaStatements(tbl)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if err := s.conn.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("create table %s: %w", tbl, err)
+		}
+	}
+	s.created[tbl] = true
+	return nil
 }
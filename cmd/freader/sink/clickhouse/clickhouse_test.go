@@ -3,6 +3,8 @@ package clickhouse
 import (
 	"strings"
 	"testing"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
 )
 
 func TestClickHouseMigration_LabelsMapType(t *testing.T) {
@@ -15,9 +17,37 @@ func TestClickHouseMigration_LabelsMapType(t *testing.T) {
 	}
 }
 
+func TestClickHouseMigration_SchemaVersion(t *testing.T) {
+	content, err := ReadEmbeddedMigration("00003_add_schema_version.sql")
+	if err != nil {
+		t.Fatalf("failed to read embedded migration: %v", err)
+	}
+	if !strings.Contains(content, "schema_version UInt16 DEFAULT 1") {
+		t.Fatalf("expected schema_version to default to 1 for existing rows, got: %q", content)
+	}
+}
+
 func TestClickHouseNew_MissingConfig(t *testing.T) {
 	// Should fail fast before attempting any connection
-	if _, err := New("", "", "", "", "", "", nil, 1, 1, nil, nil); err == nil {
+	if _, err := New("", "", "", "", "", "", nil, common.BatchOptions{Size: 1, Interval: 1}, nil, nil, nil, nil, common.CompressionConfig{}); err == nil {
 		t.Fatal("expected error when addr or table is missing")
 	}
 }
+
+func TestClickHouseSchemaStatements(t *testing.T) {
+	stmts, err := schemaStatements("logs.app_api")
+	if err != nil {
+		t.Fatalf("schemaStatements: %v", err)
+	}
+	if len(stmts) != 3 {
+		t.Fatalf("expected one statement per migration, got %q", stmts)
+	}
+	if !strings.HasPrefix(stmts[0], "CREATE TABLE IF NOT EXISTS logs.app_api (") {
+		t.Fatalf("expected the table to be created first, got %q", stmts[0])
+	}
+	for _, stmt := range stmts {
+		if strings.Contains(stmt, "__TABLE_FULL__") || strings.Contains(stmt, "goose") || strings.Contains(stmt, "DROP") {
+			t.Fatalf("unexpected statement %q", stmt)
+		}
+	}
+}
@@ -1,19 +1,43 @@
 package clickhouse
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
 
 // Config holds ClickHouse sink connection settings.
 type Config struct {
-	Addr     string `mapstructure:"addr"` // http(s)://host:8123 or native host:9000
-	Database string `mapstructure:"database"`
-	Table    string `mapstructure:"table"` // table or db.table
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
+	Addr     string           `mapstructure:"addr"` // http(s)://host:8123 or native host:9000
+	Database string           `mapstructure:"database"`
+	Table    string           `mapstructure:"table"` // table or db.table
+	User     string           `mapstructure:"user"`
+	Password string           `mapstructure:"password"`
+	ProxyURL string           `mapstructure:"proxy-url"` // HTTP protocol only; empty uses HTTP(S)_PROXY/NO_PROXY
+	TLS      common.TLSConfig `mapstructure:"tls"`       // applies to https:// and native secure connections
+	// Compression selects the driver's block compression; gzip is HTTP-only and min-bytes is ignored.
+	Compression common.CompressionConfig `mapstructure:"compression"`
 }
 
 func (c Config) Validate() error {
 	if c.Addr == "" || c.Table == "" {
 		return fmt.Errorf("sink.clickhouse requires addr and table")
 	}
+	if _, err := common.ParseProxyURL(c.ProxyURL); err != nil {
+		return fmt.Errorf("sink.clickhouse: %w", err)
+	}
+	if c.ProxyURL != "" && !strings.Contains(c.Addr, "://") {
+		return fmt.Errorf("sink.clickhouse.proxy-url requires an http(s):// addr")
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("sink.clickhouse: %w", err)
+	}
+	if err := c.Compression.Validate("gzip", "zstd", "lz4"); err != nil {
+		return fmt.Errorf("sink.clickhouse: %w", err)
+	}
+	if c.Compression.Method == "gzip" && !strings.Contains(c.Addr, "://") {
+		return fmt.Errorf("sink.clickhouse.compression.method gzip requires an http(s):// addr")
+	}
 	return nil
 }
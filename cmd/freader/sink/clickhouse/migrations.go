@@ -23,6 +23,31 @@ func ReadEmbeddedMigration(name string) (string, error) {
 	return string(b), nil
 }
 
+// schemaStatements returns the Up statements of the embedded migrations for the table
+// fullTable, in order. They are idempotent, so routed tables, which goose does not
+// track, are created by running all of them.
+func schemaStatements(fullTable string) ([]string, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var stmts []string
+	for _, e := range entries {
+		b, err := migrationFS.ReadFile("migrations/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		up = strings.TrimPrefix(strings.TrimSpace(up), "-- +goose Up")
+		for _, stmt := range strings.Split(up, ";") {
+			if stmt = strings.TrimSpace(stmt); stmt != "" {
+				stmts = append(stmts, strings.ReplaceAll(stmt, "__TABLE_FULL__", fullTable))
+			}
+		}
+	}
+	return stmts, nil
+}
+
 // runMigrations injects the configured table into embedded SQL and applies it via goose.
 func runMigrations(opts *ch.Options, database, table string) error {
 	// Open DB and ping
@@ -0,0 +1,4 @@
+-- +goose Up
+ALTER TABLE __TABLE_FULL__ ADD COLUMN IF NOT EXISTS event_time DateTime64(3) DEFAULT ts AFTER ts;
+-- +goose Down
+ALTER TABLE __TABLE_FULL__ DROP COLUMN IF EXISTS event_time;
@@ -0,0 +1,4 @@
+-- +goose Up
+ALTER TABLE __TABLE_FULL__ ADD COLUMN IF NOT EXISTS schema_version UInt16 DEFAULT 1 AFTER event_time;
+-- +goose Down
+ALTER TABLE __TABLE_FULL__ DROP COLUMN IF EXISTS schema_version;
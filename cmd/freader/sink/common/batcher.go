@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"log/slog"
 	"sync"
 	"time"
@@ -10,19 +11,54 @@ import (
 
 // Batcher provides buffering, timing, and stop coordination for sinks.
 type Batcher struct {
-	Ch            chan string
+	Ch            chan Entry
 	BatchSize     int
+	BatchBytes    int // optional cap on summed line bytes per batch; 0 disables
 	BatchInterval time.Duration
+	Concurrency   int           // max in-flight flushes; <= 1 flushes synchronously
+	Ordered       bool          // settle batches in dispatch order, whatever order their flushes complete in
+	Retries       int           // extra attempts for a failed flush
+	RetryBackoff  time.Duration // wait before the first retry, doubled for each further one
 	filter        *filter
+	stopMu        sync.RWMutex // held by EnqueueEntry while queueing an entry with an Ack
+	stopped       bool         // set by the run loop on Stop; no entry with an Ack is queued after
 	Wg            sync.WaitGroup
 	StopOnce      sync.Once
 	StopCh        chan struct{}
 	Sink          string
 }
 
+// BatchOptions groups the batching and dispatch settings of network sinks.
+type BatchOptions struct {
+	Size        int
+	Bytes       int
+	Interval    time.Duration
+	Concurrency int
+	Ordered     bool // settle batches in dispatch order; see Batcher.RunEntries
+	Retries     int
+	Backoff     time.Duration
+}
+
+// NewBatcherWithOptions is like NewBatcher but also applies byte caps and concurrency.
+func NewBatcherWithOptions(opts BatchOptions, includes, excludes []string, sink string) Batcher {
+	return Batcher{
+		Ch:            make(chan Entry, opts.Size*2),
+		BatchSize:     opts.Size,
+		BatchBytes:    opts.Bytes,
+		BatchInterval: opts.Interval,
+		Concurrency:   opts.Concurrency,
+		Ordered:       opts.Ordered,
+		Retries:       opts.Retries,
+		RetryBackoff:  opts.Backoff,
+		filter:        &filter{includes: includes, excludes: excludes},
+		StopCh:        make(chan struct{}),
+		Sink:          sink,
+	}
+}
+
 func NewBatcher(size int, interval time.Duration, includes, excludes []string, sink string) Batcher {
 	return Batcher{
-		Ch:            make(chan string, size*2),
+		Ch:            make(chan Entry, size*2),
 		BatchSize:     size,
 		BatchInterval: interval,
 		filter:        &filter{includes: includes, excludes: excludes},
@@ -31,17 +67,224 @@ func NewBatcher(size int, interval time.Duration, includes, excludes []string, s
 	}
 }
 
+// errStopped settles the Ack of entries queued after the sink stopped.
+var errStopped = errors.New("sink stopped")
+
+// Enqueue queues line with the current time as its ingest time and no event time.
 func (b *Batcher) Enqueue(line string) {
-	if !b.filter.allow(line) {
+	b.EnqueueEntry(Entry{Line: line, IngestTime: time.Now()})
+}
+
+// EnqueueEntry queues e unless the include/exclude filters reject it. An entry with
+// an Ack is counted in it and waits for room in a full queue instead of being dropped,
+// so the collector is held back rather than losing records it would not read again.
+// Once the sink has stopped, such an entry is not queued and its Ack is settled with
+// an error at once.
+func (b *Batcher) EnqueueEntry(e Entry) {
+	if !b.filter.allowEntry(e) {
 		cmdmetrics.SinkDropped(b.Sink, "filtered")
 		return
 	}
+	if e.Ack != nil {
+		e.Ack.add()
+		b.stopMu.RLock()
+		defer b.stopMu.RUnlock()
+		if b.stopped {
+			e.Ack.Done(errStopped)
+			return
+		}
+		select {
+		case b.Ch <- e:
+			cmdmetrics.SinkEnqueued(b.Sink)
+			cmdmetrics.SinkQueue(b.Sink, len(b.Ch), cap(b.Ch))
+		case <-b.StopCh:
+			e.Ack.Done(errStopped)
+		}
+		return
+	}
 	select {
-	case b.Ch <- line:
+	case b.Ch <- e:
 		cmdmetrics.SinkEnqueued(b.Sink)
+		cmdmetrics.SinkQueue(b.Sink, len(b.Ch), cap(b.Ch))
 	default:
 		// buffer full, drop with a warning to avoid blocking file ingestion
 		slog.Warn("sink buffer full; dropping line")
 		cmdmetrics.SinkDropped(b.Sink, "buffer_full")
 	}
 }
+
+// Drain removes and returns the entries still waiting in the queue without flushing
+// them. Entries the run loop already took are flushed by it as usual. The Acks of the
+// entries returned are left for the caller to settle.
+func (b *Batcher) Drain() []Entry {
+	var out []Entry
+	for {
+		select {
+		case e := <-b.Ch:
+			out = append(out, e)
+		default:
+			cmdmetrics.SinkQueue(b.Sink, len(b.Ch), cap(b.Ch))
+			return out
+		}
+	}
+}
+
+// Run is RunEntries for sinks that only need the lines.
+func (b *Batcher) Run(flush func(lines []string) error) {
+	b.RunEntries(func(entries []Entry) error {
+		lines := make([]string, len(entries))
+		for i, e := range entries {
+			lines[i] = e.Line
+		}
+		return flush(lines)
+	})
+}
+
+// RunEntries collects queued entries and calls flush when a batch reaches BatchSize lines,
+// BatchBytes bytes, or BatchInterval elapses, and once more on Stop for what is still
+// buffered or queued. A single line larger than BatchBytes is flushed on its own.
+// flush must not retain the slice.
+//
+// With Concurrency > 1, up to Concurrency batches are flushed in parallel and Run
+// waits for all of them before returning. When Ordered is set, they are still flushed
+// in parallel, but each batch is committed (its Acks settled) only after every batch
+// dispatched before it, and keeps its slot until then, so a slow batch holds back at
+// most Concurrency-1 completed ones.
+//
+// A failed flush is retried up to Retries times, waiting RetryBackoff (at least
+// minRetryBackoff) before the first retry and twice as long before each further one
+// (at most maxRetryBackoff). Once Stop is called, failed flushes are no longer retried.
+// Flush latency, outcome and retries are recorded in the sink metrics for every attempt.
+//
+// The Ack of each entry is settled once its batch has been flushed or has finally
+// failed.
+func (b *Batcher) RunEntries(flush func(entries []Entry) error) {
+	buf := make([]Entry, 0, b.BatchSize)
+	bufBytes := 0
+	ticker := time.NewTicker(b.BatchInterval)
+	defer ticker.Stop()
+
+	var (
+		inflight sync.WaitGroup
+		sem      chan struct{}
+	)
+	if b.Concurrency > 1 {
+		sem = make(chan struct{}, b.Concurrency)
+	}
+	defer inflight.Wait()
+	// With Ordered, closed once the last dispatched batch has been committed
+	var committed chan struct{}
+
+	dispatch := func(entries []Entry) {
+		if sem == nil {
+			b.commit(b.flushWithRetry(flush, entries), entries)
+			return
+		}
+		batch := append([]Entry(nil), entries...)
+		sem <- struct{}{} // blocks while all slots are in flight
+		inflight.Add(1)
+		var prev, done chan struct{}
+		if b.Ordered {
+			prev, done = committed, make(chan struct{})
+			committed = done
+		}
+		go func() {
+			defer inflight.Done()
+			err := b.flushWithRetry(flush, batch)
+			if prev != nil {
+				<-prev
+			}
+			b.commit(err, batch)
+			if done != nil {
+				close(done)
+			}
+			<-sem
+		}()
+	}
+	flushBuf := func() {
+		if len(buf) == 0 {
+			return
+		}
+		dispatch(buf)
+		buf = buf[:0]
+		bufBytes = 0
+	}
+	add := func(e Entry) {
+		cmdmetrics.SinkQueue(b.Sink, len(b.Ch), cap(b.Ch))
+		if b.BatchBytes > 0 && bufBytes+len(e.Line) > b.BatchBytes {
+			flushBuf()
+		}
+		buf = append(buf, e)
+		bufBytes += len(e.Line)
+		if len(buf) >= b.BatchSize || (b.BatchBytes > 0 && bufBytes >= b.BatchBytes) {
+			flushBuf()
+		}
+	}
+	for {
+		select {
+		case <-b.StopCh:
+			// Wait for entries with an Ack being queued, so each one is either in the
+			// queue drained here or settled by EnqueueEntry
+			b.stopMu.Lock()
+			b.stopped = true
+			b.stopMu.Unlock()
+			for _, e := range b.Drain() {
+				add(e)
+			}
+			flushBuf()
+			return
+		case <-ticker.C:
+			flushBuf()
+		case e := <-b.Ch:
+			add(e)
+		}
+	}
+}
+
+// minRetryBackoff and maxRetryBackoff bound the wait between flush retries, so a
+// failing backend is not retried in a tight loop.
+const (
+	minRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff = 30 * time.Second
+)
+
+// flushWithRetry calls flush until it succeeds, Retries retries have failed or Stop
+// is called, and returns the last error.
+func (b *Batcher) flushWithRetry(flush func(entries []Entry) error, entries []Entry) error {
+	backoff := max(b.RetryBackoff, minRetryBackoff)
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := flush(entries)
+		cmdmetrics.SinkFlushObserve(b.Sink, len(entries), time.Since(start), err == nil)
+		if err == nil || attempt >= b.Retries {
+			return err
+		}
+		select {
+		case <-b.StopCh:
+			return err
+		default:
+		}
+		slog.Warn("sink flush failed; retrying", "sink", b.Sink, "attempt", attempt+1, "backoff", backoff, "error", err)
+		cmdmetrics.SinkRetried(b.Sink)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-b.StopCh:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// commit settles the Acks of the entries of a flushed batch with the flush's outcome.
+func (b *Batcher) commit(err error, entries []Entry) {
+	if err != nil {
+		slog.Error("sink flush failed", "sink", b.Sink, "error", err)
+	}
+	for _, e := range entries {
+		if e.Ack != nil {
+			e.Ack.Done(err)
+		}
+	}
+}
@@ -1,17 +1,23 @@
 package common
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-func drain(ch <-chan string, max int, timeout time.Duration) []string {
+func drain(ch <-chan Entry, max int, timeout time.Duration) []string {
 	out := []string{}
 	deadline := time.After(timeout)
 	for len(out) < max {
 		select {
-		case s := <-ch:
-			out = append(out, s)
+		case e := <-ch:
+			out = append(out, e.Line)
 		case <-deadline:
 			return out
 		}
@@ -50,3 +56,393 @@ func TestBatcher_BufferFullDrops(t *testing.T) {
 		t.Fatalf("unexpected channel content: %+v", got)
 	}
 }
+
+func TestBatcher_Drain(t *testing.T) {
+	b := NewBatcher(10, time.Hour, nil, nil, "test")
+	b.Enqueue("a")
+	b.Enqueue("b")
+
+	got := b.Drain()
+	if len(got) != 2 || got[0].Line != "a" || got[1].Line != "b" {
+		t.Fatalf("unexpected drained entries: %+v", got)
+	}
+	if len(b.Ch) != 0 {
+		t.Fatalf("queue not empty after drain: %d", len(b.Ch))
+	}
+	if got := b.Drain(); len(got) != 0 {
+		t.Fatalf("expected nothing left to drain, got %+v", got)
+	}
+}
+
+func TestBatcher_Run_FlushesOnBatchBytes(t *testing.T) {
+	b := NewBatcher(100, time.Hour, nil, nil, "test")
+	b.BatchBytes = 10
+
+	var mu sync.Mutex
+	var batches [][]string
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			batches = append(batches, append([]string(nil), lines...))
+			return nil
+		})
+	}()
+
+	b.Enqueue("aaaa")         // 4 bytes
+	b.Enqueue("bbbb")         // 8 bytes
+	b.Enqueue("cccc")         // would exceed 10: flushes [aaaa bbbb] first
+	b.Enqueue("oversized-xx") // larger than the cap: flushes [cccc], then goes alone
+	time.Sleep(20 * time.Millisecond)
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+
+	want := [][]string{{"aaaa", "bbbb"}, {"cccc"}, {"oversized-xx"}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Fatalf("unexpected batches: %+v, want %+v", batches, want)
+	}
+}
+
+func TestBatcher_Run_FlushesOnBatchSize(t *testing.T) {
+	b := NewBatcher(2, time.Hour, nil, nil, "test")
+
+	flushed := make(chan []string, 4)
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			flushed <- append([]string(nil), lines...)
+			return nil
+		})
+	}()
+	b.Enqueue("x")
+	b.Enqueue("y")
+	select {
+	case got := <-flushed:
+		if !reflect.DeepEqual(got, []string{"x", "y"}) {
+			t.Fatalf("unexpected batch: %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected flush once batch size was reached")
+	}
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+}
+
+// runConcurrent starts b.Run with a flush that blocks batches starting with "slow"
+// until release is closed, and returns a counter of started flushes.
+func runConcurrent(b *Batcher, release chan struct{}) *atomic.Int32 {
+	started := &atomic.Int32{}
+	b.Ch = make(chan Entry, 8) // Size=1 would only buffer two lines
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			started.Add(1)
+			if strings.HasPrefix(lines[0], "slow") {
+				<-release
+			}
+			return nil
+		})
+	}()
+	return started
+}
+
+func TestBatcher_Run_ConcurrencyUnordered(t *testing.T) {
+	b := NewBatcherWithOptions(BatchOptions{Size: 1, Interval: time.Hour, Concurrency: 2}, nil, nil, "test")
+	release := make(chan struct{})
+	started := runConcurrent(&b, release)
+
+	b.Enqueue("slow-1")
+	b.Enqueue("fast-2")
+	b.Enqueue("fast-3")
+	time.Sleep(50 * time.Millisecond)
+	// fast-2 finished and freed its slot, so fast-3 overtakes the slow batch
+	if got := started.Load(); got != 3 {
+		t.Fatalf("expected 3 flushes started while slow batch in flight, got %d", got)
+	}
+	close(release)
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+}
+
+func TestBatcher_Run_ConcurrencyOrdered(t *testing.T) {
+	b := NewBatcherWithOptions(BatchOptions{Size: 1, Interval: time.Hour, Concurrency: 2, Ordered: true}, nil, nil, "test")
+	release := make(chan struct{})
+	started := runConcurrent(&b, release)
+
+	acks := []*Ack{new(Ack), new(Ack), new(Ack)}
+	for i, line := range []string{"slow-1", "fast-2", "fast-3"} {
+		b.EnqueueEntry(Entry{Line: line, Ack: acks[i]})
+	}
+	time.Sleep(50 * time.Millisecond)
+	// Ordered batches are still sent in parallel, but fast-2 is not committed before
+	// slow-1 and keeps its slot until then, so fast-3 waits
+	if got := started.Load(); got != 2 {
+		t.Fatalf("expected 2 flushes started while the slow batch is in flight, got %d", got)
+	}
+	settled := make(chan int, len(acks))
+	for i, ack := range acks {
+		go func() {
+			_ = ack.Wait()
+			settled <- i
+		}()
+	}
+	select {
+	case i := <-settled:
+		t.Fatalf("batch %d committed before the slow batch completed", i+1)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	for range acks {
+		select {
+		case <-settled:
+		case <-time.After(time.Second):
+			t.Fatal("a batch was never committed")
+		}
+	}
+	if got := started.Load(); got != 3 {
+		t.Fatalf("expected remaining batch to flush after commit, got %d", got)
+	}
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+}
+
+func TestBatcher_RunEntries_KeepsTimes(t *testing.T) {
+	b := NewBatcher(2, time.Hour, nil, nil, "test")
+	event := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	got := make(chan []Entry, 1)
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.RunEntries(func(entries []Entry) error {
+			got <- append([]Entry(nil), entries...)
+			return nil
+		})
+	}()
+	b.EnqueueEntry(Entry{Line: "with event time", EventTime: event, IngestTime: event.Add(time.Minute)})
+	b.Enqueue("ingest time only")
+
+	entries := <-got
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if !entries[0].Time().Equal(event) {
+		t.Fatalf("Time() = %v, want event time %v", entries[0].Time(), event)
+	}
+	if !entries[1].EventTime.IsZero() || entries[1].IngestTime.IsZero() || !entries[1].Time().Equal(entries[1].IngestTime) {
+		t.Fatalf("Enqueue must set only the ingest time: %+v", entries[1])
+	}
+}
+
+func TestBatcher_Run_RetriesFailedFlush(t *testing.T) {
+	b := NewBatcherWithOptions(BatchOptions{Size: 1, Interval: time.Hour, Retries: 2, Backoff: time.Millisecond}, nil, nil, "test")
+	var attempts atomic.Int32
+	done := make(chan struct{})
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			if attempts.Add(1) < 3 {
+				return errors.New("backend unavailable")
+			}
+			close(done)
+			return nil
+		})
+	}()
+	b.Enqueue("a")
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("flush was not retried, attempts = %d", attempts.Load())
+	}
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+	if got := attempts.Load(); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestBatcher_Run_GivesUpAfterRetries(t *testing.T) {
+	b := NewBatcherWithOptions(BatchOptions{Size: 1, Interval: time.Hour, Retries: 1, Backoff: time.Millisecond}, nil, nil, "test")
+	var attempts atomic.Int32
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			attempts.Add(1)
+			return errors.New("backend unavailable")
+		})
+	}()
+	b.Enqueue("a")
+	deadline := time.Now().Add(2 * time.Second)
+	for attempts.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The backoff is at least minRetryBackoff, so a third attempt would show by now
+	time.Sleep(2 * minRetryBackoff)
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+	if got := attempts.Load(); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestBatcher_Run_StopInterruptsBackoff(t *testing.T) {
+	b := NewBatcherWithOptions(BatchOptions{Size: 1, Interval: time.Hour, Retries: 5, Backoff: time.Hour}, nil, nil, "test")
+	var attempts atomic.Int32
+	failed := make(chan struct{}, 1)
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			attempts.Add(1)
+			failed <- struct{}{}
+			return errors.New("backend unavailable")
+		})
+	}()
+	b.Enqueue("a")
+	<-failed
+	stopped := make(chan struct{})
+	go func() {
+		b.StopOnce.Do(func() { close(b.StopCh) })
+		b.Wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop waited for the retry backoff")
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Fatalf("expected no retry after Stop, got %d attempts", got)
+	}
+}
+
+func TestBatcher_Ack(t *testing.T) {
+	b := NewBatcherWithOptions(BatchOptions{Size: 2, Interval: time.Hour, Concurrency: 2}, nil, []string{"skip"}, "test")
+	var fail atomic.Bool
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			time.Sleep(10 * time.Millisecond)
+			if fail.Load() {
+				return errors.New("backend unavailable")
+			}
+			return nil
+		})
+	}()
+
+	// Filtered entries are not counted in
+	ack := new(Ack)
+	for _, line := range []string{"a", "skip", "b"} {
+		b.EnqueueEntry(Entry{Line: line, IngestTime: time.Now(), Ack: ack})
+	}
+	if err := ack.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	// A batch that finally fails settles its Ack with the error
+	fail.Store(true)
+	ack = new(Ack)
+	b.EnqueueEntry(Entry{Line: "c", Ack: ack})
+	b.EnqueueEntry(Entry{Line: "d", Ack: ack})
+	if err := ack.Wait(); err == nil {
+		t.Fatal("expected the failed flush to be reported")
+	}
+
+	// The batch buffered on Stop is flushed; entries queued after it fail
+	fail.Store(false)
+	ack = new(Ack)
+	b.EnqueueEntry(Entry{Line: "e", Ack: ack})
+	b.StopOnce.Do(func() { close(b.StopCh) })
+	b.Wg.Wait()
+	if err := ack.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	ack = new(Ack)
+	b.EnqueueEntry(Entry{Line: "f", Ack: ack})
+	if err := ack.Wait(); err == nil {
+		t.Fatal("expected an entry queued after Stop to fail")
+	}
+}
+
+func TestBatcher_AckWaitsForRoom(t *testing.T) {
+	b := NewBatcherWithOptions(BatchOptions{Size: 1, Interval: time.Hour}, nil, nil, "test")
+	release := make(chan struct{})
+	b.Wg.Add(1)
+	go func() {
+		defer b.Wg.Done()
+		b.Run(func(lines []string) error {
+			<-release
+			return nil
+		})
+	}()
+	defer func() {
+		b.StopOnce.Do(func() { close(b.StopCh) })
+		b.Wg.Wait()
+	}()
+
+	// The run loop holds one entry in its flush and the queue two more
+	ack := new(Ack)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			b.EnqueueEntry(Entry{Line: fmt.Sprint(i), Ack: ack})
+		}
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected acknowledged entries to wait for room instead of being dropped")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	if err := ack.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+// Entries queued while the sink stops are either flushed or failed, never left in the
+// queue with their Ack unsettled.
+func TestBatcher_AckSettledAcrossStop(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		b := NewBatcherWithOptions(BatchOptions{Size: 1, Interval: time.Hour}, nil, nil, "test")
+		b.Wg.Add(1)
+		go func() {
+			defer b.Wg.Done()
+			b.Run(func(lines []string) error { return nil })
+		}()
+		ack := new(Ack)
+		var senders sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			senders.Add(1)
+			go func() {
+				defer senders.Done()
+				for j := 0; j < 1000; j++ {
+					b.EnqueueEntry(Entry{Line: "x", Ack: ack})
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		b.StopOnce.Do(func() { close(b.StopCh) })
+		senders.Wait()
+		b.Wg.Wait()
+		settled := make(chan struct{})
+		go func() {
+			_ = ack.Wait()
+			close(settled)
+		}()
+		select {
+		case <-settled:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("round %d: an entry queued across Stop was never settled", round)
+		}
+	}
+}
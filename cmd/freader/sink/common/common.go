@@ -1,7 +1,123 @@
 package common
 
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
 // Sink specifies the minimal interface for a line-forwarding backend.
 type Sink interface {
 	Enqueue(line string)
+	// EnqueueEntry is Enqueue with the record's event time, for sinks that index by it.
+	EnqueueEntry(e Entry)
 	Stop() error
 }
+
+// Drainer is implemented by sinks whose queued entries can be handed to another sink.
+type Drainer interface {
+	// Drain removes and returns the entries still waiting in the queue.
+	Drain() []Entry
+}
+
+// Acker is implemented by sinks that confirm delivery. Records are then handed to the
+// sink in the collector's batches, their entries carrying the batch's Ack, and their
+// offsets stored only once the sink has resolved it.
+type Acker interface {
+	// AcksEntries marks the sink; it settles the Ack of every entry it queues once the
+	// entry is delivered or has finally failed, and at once for entries queued after
+	// it stopped.
+	AcksEntries()
+}
+
+// Ack tracks the delivery of the entries of one collector batch by an Acker. The sink
+// counts each entry carrying it in with add when queueing it and out with Done once
+// it is settled; Wait returns after the last one, with the first error.
+type Ack struct {
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+func (a *Ack) add() { a.wg.Add(1) }
+
+// Done settles one entry counted in by the sink, with the error that kept it from
+// being delivered, if any.
+func (a *Ack) Done(err error) {
+	if err != nil {
+		a.mu.Lock()
+		if a.err == nil {
+			a.err = err
+		}
+		a.mu.Unlock()
+	}
+	a.wg.Done()
+}
+
+// Wait returns once every entry counted in has been settled, with the first error.
+// Entries must no longer be queued with the Ack.
+func (a *Ack) Wait() error {
+	a.wg.Wait()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Entry is one record handed to a sink: the formatted line, when the event happened
+// (zero if unknown), when freader read it, the file it was read from (if known) and
+// the headers rendered from sink.headers, for sinks that carry per-record metadata.
+// Labels are those of the record's source, the container writing the file when Docker
+// discovery is enabled, for the label: include/exclude filters.
+// Target is the OpenSearch index or ClickHouse table rendered for the record from a
+// templated sink.opensearch.index or sink.clickhouse.table; empty uses the configured one.
+// Ack, if set, is resolved by an Acker once the entry is delivered.
+type Entry struct {
+	Line       string
+	File       string
+	EventTime  time.Time
+	IngestTime time.Time
+	Headers    map[string]string
+	Target     string
+	Labels     map[string]string
+	Ack        *Ack
+}
+
+// IsTemplate reports whether an index or table name is a template rendered per record,
+// e.g. "logs-${labels.app}".
+func IsTemplate(name string) bool {
+	return strings.Contains(name, "${")
+}
+
+// Time returns the event time, falling back to the ingest time when it is unknown.
+func (e Entry) Time() time.Time {
+	if e.EventTime.IsZero() {
+		return e.IngestTime
+	}
+	return e.EventTime
+}
+
+// SchemaVersion is the version of the record envelope written by the structured
+// sinks: the Document fields and the ClickHouse columns. Fields are only added, with
+// the version unchanged, and consumers should ignore fields they do not know.
+// Renaming or removing a field, or changing its type or meaning, bumps the version.
+// See the envelope table in the README.
+const SchemaVersion = 1
+
+// Document is the structured form of e shipped by the OpenSearch and unix socket sinks:
+// @timestamp is the event time (falling back to the ingest time), ingest_time the
+// ingest time and event_time, only when known, the event time. schema_version is
+// SchemaVersion.
+func (e Entry) Document(host string, labels map[string]string) map[string]any {
+	doc := map[string]any{
+		"schema_version": SchemaVersion,
+		"@timestamp":     e.Time().UTC().Format(time.RFC3339Nano),
+		"ingest_time":    e.IngestTime.UTC().Format(time.RFC3339Nano),
+		"message":        e.Line,
+		"host":           host,
+		"labels":         labels,
+	}
+	if !e.EventTime.IsZero() {
+		doc["event_time"] = e.EventTime.UTC().Format(time.RFC3339Nano)
+	}
+	return doc
+}
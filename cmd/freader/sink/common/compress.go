@@ -0,0 +1,77 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// CompressionConfig controls request payload compression for network sinks.
+type CompressionConfig struct {
+	Method   string `mapstructure:"method"`    // "none" (default), "gzip"; ClickHouse also accepts "zstd" and "lz4"
+	MinBytes int    `mapstructure:"min-bytes"` // HTTP bodies smaller than this are sent uncompressed
+}
+
+// Validate checks the method against the set supported by the calling sink.
+func (c CompressionConfig) Validate(supported ...string) error {
+	if c.MinBytes < 0 {
+		return fmt.Errorf("compression.min-bytes must be >= 0")
+	}
+	if c.Method == "" || c.Method == "none" {
+		return nil
+	}
+	for _, m := range supported {
+		if c.Method == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported compression.method: %s", c.Method)
+}
+
+// Enabled reports whether a compression method other than none is selected.
+func (c CompressionConfig) Enabled() bool {
+	return c.Method != "" && c.Method != "none"
+}
+
+// GzipTransport gzips request bodies of at least MinBytes before handing them to Base.
+type GzipTransport struct {
+	Base     http.RoundTripper
+	MinBytes int
+}
+
+func (t *GzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	if len(body) < t.MinBytes {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+		return base.RoundTrip(out)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	compressed := buf.Bytes()
+	out.Body = io.NopCloser(bytes.NewReader(compressed))
+	out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(compressed)), nil }
+	out.ContentLength = int64(len(compressed))
+	out.Header.Set("Content-Encoding", "gzip")
+	return base.RoundTrip(out)
+}
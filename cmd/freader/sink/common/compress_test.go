@@ -0,0 +1,64 @@
+package common
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionConfig_Validate(t *testing.T) {
+	if err := (CompressionConfig{}).Validate("gzip"); err != nil {
+		t.Fatalf("empty method should be valid: %v", err)
+	}
+	if err := (CompressionConfig{Method: "zstd"}).Validate("gzip"); err == nil {
+		t.Fatal("expected error for method not supported by sink")
+	}
+	if err := (CompressionConfig{Method: "gzip", MinBytes: -1}).Validate("gzip"); err == nil {
+		t.Fatal("expected error for negative min-bytes")
+	}
+}
+
+func TestGzipTransport_Threshold(t *testing.T) {
+	type seen struct {
+		encoding string
+		body     string
+	}
+	var got []seen
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rd io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip reader: %v", err)
+				return
+			}
+			rd = zr
+		}
+		b, _ := io.ReadAll(rd)
+		got = append(got, seen{encoding: r.Header.Get("Content-Encoding"), body: string(b)})
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &GzipTransport{MinBytes: 16}}
+	small := "tiny"
+	large := strings.Repeat("log line ", 10)
+	for _, body := range []string{small, large} {
+		resp, err := client.Post(ts.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(got))
+	}
+	if got[0].encoding != "" || got[0].body != small {
+		t.Fatalf("small body should pass through uncompressed: %+v", got[0])
+	}
+	if got[1].encoding != "gzip" || got[1].body != large {
+		t.Fatalf("large body should be gzip-compressed: %+v", got[1])
+	}
+}
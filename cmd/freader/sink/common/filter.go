@@ -1,18 +1,29 @@
 package common
 
-import "strings"
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
 
-// filter applies include/exclude substring filters.
+// filter applies include/exclude filters. An entry "file:<glob>" matches records read
+// from a path matching the glob, or whose base name does; "label:<key>" and
+// "label:<key>=<value>" match records whose source has the label (Entry.Labels); any
+// other entry matches records whose line contains it.
 type filter struct {
 	includes []string
 	excludes []string
 }
 
 func (f *filter) allow(line string) bool {
+	return f.allowEntry(Entry{Line: line})
+}
+
+func (f *filter) allowEntry(e Entry) bool {
 	if len(f.includes) > 0 {
 		ok := false
 		for _, inc := range f.includes {
-			if inc == "" || strings.Contains(line, inc) {
+			if inc == "" || matches(inc, e) {
 				ok = true
 				break
 			}
@@ -22,9 +33,60 @@ func (f *filter) allow(line string) bool {
 		}
 	}
 	for _, exc := range f.excludes {
-		if exc != "" && strings.Contains(line, exc) {
+		if exc != "" && matches(exc, e) {
 			return false
 		}
 	}
 	return true
 }
+
+// matches reports whether the filter entry p matches e.
+func matches(p string, e Entry) bool {
+	if glob, ok := strings.CutPrefix(p, "file:"); ok {
+		if e.File == "" {
+			return false
+		}
+		full, _ := filepath.Match(glob, e.File)
+		base, _ := filepath.Match(glob, filepath.Base(e.File))
+		return full || base
+	}
+	if label, ok := strings.CutPrefix(p, "label:"); ok {
+		key, value, hasValue := strings.Cut(label, "=")
+		v, ok := e.Labels[key]
+		return ok && (!hasValue || v == value)
+	}
+	return strings.Contains(e.Line, p)
+}
+
+// Allowed reports whether e passes the include and exclude entries, as a sink's
+// batcher applies them.
+func Allowed(e Entry, includes, excludes []string) bool {
+	return (&filter{includes: includes, excludes: excludes}).allowEntry(e)
+}
+
+// UsesLabels reports whether any include/exclude entry is a label: entry.
+func UsesLabels(entries []string) bool {
+	for _, p := range entries {
+		if strings.HasPrefix(p, "label:") {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFilters checks the file globs and label keys of include/exclude entries.
+func ValidateFilters(entries []string) error {
+	for _, p := range entries {
+		if glob, ok := strings.CutPrefix(p, "file:"); ok {
+			if _, err := filepath.Match(glob, ""); err != nil || glob == "" {
+				return fmt.Errorf("invalid file glob in %q", p)
+			}
+		}
+		if label, ok := strings.CutPrefix(p, "label:"); ok {
+			if key, _, _ := strings.Cut(label, "="); key == "" {
+				return fmt.Errorf("missing label key in %q (use label:key or label:key=value)", p)
+			}
+		}
+	}
+	return nil
+}
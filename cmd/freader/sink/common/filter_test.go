@@ -44,3 +44,36 @@ func TestFilter_Allow_EmptyIncludesMeansAllowAllUnlessExcluded(t *testing.T) {
 		t.Fatalf("expected excluded substring to block")
 	}
 }
+
+func TestFilter_AllowEntry_SourceFilters(t *testing.T) {
+	audit := Entry{Line: "type=USER_LOGIN", File: "/var/log/audit/audit.log"}
+	app := Entry{Line: "GET /", File: "/var/log/app/app.log", Labels: map[string]string{"team": "payments", "tier": "web"}}
+
+	f := &filter{includes: []string{"file:/var/log/audit/*", "label:tier=db"}}
+	if !f.allowEntry(audit) || f.allowEntry(app) {
+		t.Fatalf("expected only the audit record to match the includes")
+	}
+	f = &filter{includes: []string{"file:app.log"}}
+	if !f.allowEntry(app) || f.allowEntry(Entry{Line: "app.log"}) {
+		t.Fatalf("expected a file glob to match the base name, and not the line")
+	}
+	f = &filter{excludes: []string{"label:team"}}
+	if f.allowEntry(app) || !f.allowEntry(audit) {
+		t.Fatalf("expected label:team to exclude only the record with the label")
+	}
+	f = &filter{includes: []string{"label:team=payments"}, excludes: []string{"GET"}}
+	if f.allowEntry(app) {
+		t.Fatalf("expected the line exclude to apply after a label include")
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	if err := ValidateFilters([]string{"ERROR", "file:/var/log/*.log", "label:app", "label:app=web"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range []string{"file:[", "file:", "label:", "label:=web"} {
+		if err := ValidateFilters([]string{p}); err == nil {
+			t.Fatalf("expected %q to be rejected", p)
+		}
+	}
+}
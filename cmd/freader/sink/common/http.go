@@ -0,0 +1,44 @@
+package common
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ParseProxyURL validates an optional per-sink proxy URL. An empty string returns nil,
+// meaning the standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables apply.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy-url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy-url scheme: %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy-url must include a host")
+	}
+	return u, nil
+}
+
+// NewHTTPTransport returns a transport cloned from http.DefaultTransport using tlsCfg for
+// TLS connections. When proxy is nil, proxies are taken from the environment.
+func NewHTTPTransport(tlsCfg *tls.Config, proxy *url.URL) *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsCfg != nil {
+		tr.TLSClientConfig = tlsCfg
+	}
+	if proxy != nil {
+		tr.Proxy = http.ProxyURL(proxy)
+	} else {
+		tr.Proxy = http.ProxyFromEnvironment
+	}
+	return tr
+}
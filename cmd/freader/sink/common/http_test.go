@@ -0,0 +1,35 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseProxyURL(t *testing.T) {
+	if u, err := ParseProxyURL(""); err != nil || u != nil {
+		t.Fatalf("empty proxy: got %v, %v", u, err)
+	}
+	u, err := ParseProxyURL("http://proxy.corp:3128")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Host != "proxy.corp:3128" {
+		t.Fatalf("unexpected host: %q", u.Host)
+	}
+	for _, bad := range []string{"ftp://proxy:21", "http://", "://bad"} {
+		if _, err := ParseProxyURL(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestNewHTTPTransport_ExplicitProxyOverridesEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:8080")
+	proxy, _ := ParseProxyURL("http://sink-proxy:3128")
+	tr := NewHTTPTransport(nil, proxy)
+	req, _ := http.NewRequest(http.MethodPost, "https://opensearch.internal:9200/_bulk", nil)
+	got, err := tr.Proxy(req)
+	if err != nil || got == nil || got.Host != "sink-proxy:3128" {
+		t.Fatalf("expected sink proxy, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig holds client-side TLS options shared by network sinks.
+type TLSConfig struct {
+	CAFile             string `mapstructure:"ca-file"`              // PEM bundle of trusted CAs (private clusters)
+	CertFile           string `mapstructure:"cert-file"`            // client certificate (PEM)
+	KeyFile            string `mapstructure:"key-file"`             // client private key (PEM)
+	InsecureSkipVerify bool   `mapstructure:"insecure-skip-verify"` // disable server certificate verification
+	MinVersion         string `mapstructure:"min-version"`          // "1.0", "1.1", "1.2" (default) or "1.3"
+	ServerName         string `mapstructure:"server-name"`          // override SNI / verification host name
+}
+
+// Enabled reports whether any TLS option was set explicitly.
+func (c TLSConfig) Enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.InsecureSkipVerify || c.MinVersion != "" || c.ServerName != ""
+}
+
+// Validate checks option consistency without touching the filesystem.
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("tls.cert-file and tls.key-file must be set together")
+	}
+	if _, err := parseTLSVersion(c.MinVersion); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Build returns a *tls.Config for the options, or nil when TLS was not configured.
+func (c TLSConfig) Build() (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	minVersion, _ := parseTLSVersion(c.MinVersion)
+	cfg := &tls.Config{
+		MinVersion:         minVersion,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, // #nosec G402 -- explicit opt-in via config
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls.ca-file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls.ca-file %s contains no PEM certificates", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		r, err := newCertReloader(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = r.clientCertificate
+	}
+	return cfg, nil
+}
+
+// certReloader serves the client certificate for mutual TLS and loads the key pair
+// again when either file's modification time changes, so a renewed certificate is
+// presented by new connections without a restart. Connections already open keep the
+// certificate they were made with.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	certMod, keyMod := r.modTimes()
+	if err := r.load(certMod, keyMod); err != nil {
+		return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+	}
+	return r, nil
+}
+
+func (r *certReloader) modTimes() (certMod, keyMod time.Time) {
+	if info, err := os.Stat(r.certFile); err == nil {
+		certMod = info.ModTime()
+	}
+	if info, err := os.Stat(r.keyFile); err == nil {
+		keyMod = info.ModTime()
+	}
+	return certMod, keyMod
+}
+
+func (r *certReloader) load(certMod, keyMod time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.certMod, r.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+// clientCertificate is the tls.Config.GetClientCertificate hook. A pair that fails to
+// load, e.g. while the certificate was replaced but the key not yet, is logged and the
+// previous certificate kept until the next handshake tries again.
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	certMod, keyMod := r.modTimes()
+	if !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod) {
+		if err := r.load(certMod, keyMod); err != nil {
+			slog.Warn("failed to reload tls client certificate; keeping the previous one", "cert", r.certFile, "error", err)
+		} else {
+			slog.Info("reloaded tls client certificate", "cert", r.certFile)
+		}
+	}
+	return r.cert, nil
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls.min-version: %s", v)
+	}
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate and key to dir and returns their paths.
+func writeSelfSigned(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "freader-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func TestTLSConfig_DisabledReturnsNil(t *testing.T) {
+	cfg, err := TLSConfig{}.Build()
+	if err != nil || cfg != nil {
+		t.Fatalf("expected nil config without options, got %v, %v", cfg, err)
+	}
+}
+
+func TestTLSConfig_Validate(t *testing.T) {
+	if err := (TLSConfig{CertFile: "c.pem"}).Validate(); err == nil {
+		t.Fatal("expected error when key-file is missing")
+	}
+	if err := (TLSConfig{MinVersion: "1.4"}).Validate(); err == nil {
+		t.Fatal("expected error for unsupported min-version")
+	}
+	if err := (TLSConfig{MinVersion: "1.3"}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTLSConfig_BuildWithCAAndClientCert(t *testing.T) {
+	certPath, keyPath := writeSelfSigned(t, t.TempDir())
+	cfg, err := TLSConfig{
+		CAFile:     certPath,
+		CertFile:   certPath,
+		KeyFile:    keyPath,
+		MinVersion: "1.3",
+		ServerName: "logs.internal",
+	}.Build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if cfg.RootCAs == nil {
+		t.Fatal("expected RootCAs from ca-file")
+	}
+	if cfg.GetClientCertificate == nil {
+		t.Fatal("expected a client certificate")
+	}
+	if cfg.MinVersion != tls.VersionTLS13 || cfg.ServerName != "logs.internal" {
+		t.Fatalf("unexpected tls config: min=%x sni=%q", cfg.MinVersion, cfg.ServerName)
+	}
+}
+
+func TestTLSConfig_BuildRejectsInvalidCA(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(p, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (TLSConfig{CAFile: p}).Build(); err == nil {
+		t.Fatal("expected error for ca-file without PEM certificates")
+	}
+}
+
+func TestTLSConfig_ReloadsClientCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeSelfSigned(t, dir)
+	cfg, err := TLSConfig{CertFile: certPath, KeyFile: keyPath}.Build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	first, err := cfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A half-written renewal keeps the previous certificate
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(keyPath, []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil || got != first {
+		t.Fatalf("expected the previous certificate, got %v, %v", got, err)
+	}
+
+	// The renewed pair is presented once both files are in place
+	writeSelfSigned(t, dir)
+	later = later.Add(time.Minute)
+	for _, p := range []string{certPath, keyPath} {
+		if err := os.Chtimes(p, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err = cfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == first || string(got.Certificate[0]) == string(first.Certificate[0]) {
+		t.Fatal("expected the renewed certificate")
+	}
+}
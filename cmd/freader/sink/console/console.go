@@ -8,7 +8,6 @@ import (
 	"os"
 	"time"
 
-	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
 	"github.com/loykin/freader/cmd/freader/sink/common"
 )
 
@@ -56,39 +55,21 @@ func (s *fileSink) start() {
 			slog.Error("file sink open failed", "error", err)
 			return
 		}
-		buf := make([]string, 0, s.batcher.BatchSize)
-		ticker := time.NewTicker(s.batcher.BatchInterval)
-		defer ticker.Stop()
-		flush := func() {
-			if len(buf) == 0 {
-				return
-			}
-			start := time.Now()
-			for _, ln := range buf {
+		s.batcher.Run(func(lines []string) error {
+			for _, ln := range lines {
 				_, _ = fmt.Fprintln(s.f, ln)
 			}
-			cmdmetrics.SinkFlushObserve("file", len(buf), time.Since(start), true)
-			buf = buf[:0]
-		}
-		for {
-			select {
-			case <-s.batcher.StopCh:
-				flush()
-				return
-			case <-ticker.C:
-				flush()
-			case line := <-s.batcher.Ch:
-				buf = append(buf, line)
-				if len(buf) >= s.batcher.BatchSize {
-					flush()
-				}
-			}
-		}
+			return nil
+		})
 	}()
 }
 
 func (s *fileSink) Enqueue(line string) { s.batcher.Enqueue(line) }
 
+func (s *fileSink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *fileSink) Drain() []common.Entry { return s.batcher.Drain() }
+
 func (s *fileSink) Stop() error {
 	s.batcher.StopOnce.Do(func() { close(s.batcher.StopCh) })
 	s.batcher.Wg.Wait()
@@ -102,39 +83,21 @@ func (s *stdoutSink) start() {
 	s.batcher.Wg.Add(1)
 	go func() {
 		defer s.batcher.Wg.Done()
-		buf := make([]string, 0, s.batcher.BatchSize)
-		ticker := time.NewTicker(s.batcher.BatchInterval)
-		defer ticker.Stop()
-		flush := func() {
-			if len(buf) == 0 {
-				return
-			}
-			start := time.Now()
-			for _, ln := range buf {
+		s.batcher.Run(func(lines []string) error {
+			for _, ln := range lines {
 				_, _ = fmt.Fprintln(s.w, ln)
 			}
-			cmdmetrics.SinkFlushObserve("console", len(buf), time.Since(start), true)
-			buf = buf[:0]
-		}
-		for {
-			select {
-			case <-s.batcher.StopCh:
-				flush()
-				return
-			case <-ticker.C:
-				flush()
-			case line := <-s.batcher.Ch:
-				buf = append(buf, line)
-				if len(buf) >= s.batcher.BatchSize {
-					flush()
-				}
-			}
-		}
+			return nil
+		})
 	}()
 }
 
 func (s *stdoutSink) Enqueue(line string) { s.batcher.Enqueue(line) }
 
+func (s *stdoutSink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *stdoutSink) Drain() []common.Entry { return s.batcher.Drain() }
+
 func (s *stdoutSink) Stop() error {
 	s.batcher.StopOnce.Do(func() { close(s.batcher.StopCh) })
 	s.batcher.Wg.Wait()
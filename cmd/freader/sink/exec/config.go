@@ -0,0 +1,25 @@
+package exec
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config holds exec sink options.
+type Config struct {
+	Command []string `mapstructure:"command"` // program and arguments, run without a shell
+	// RestartBackoff is the wait before restarting a command that exited, doubled for each
+	// further exit in a row.
+	RestartBackoff time.Duration `mapstructure:"restart-backoff"`
+}
+
+// Validate ensures the exec sink configuration is correct when used.
+func (c Config) Validate() error {
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return fmt.Errorf("sink.exec.command must be set when sink.type is 'exec'")
+	}
+	if c.RestartBackoff < 0 {
+		return fmt.Errorf("sink.exec.restart-backoff must be >= 0")
+	}
+	return nil
+}
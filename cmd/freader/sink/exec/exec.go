@@ -0,0 +1,170 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// maxRestartBackoff caps the wait between command restarts.
+const maxRestartBackoff = 30 * time.Second
+
+// stopTimeout is how long Stop waits for the command to exit after closing its stdin
+// before killing it.
+const stopTimeout = 5 * time.Second
+
+// Sink writes records, one per line, to the stdin of a long-running command and
+// restarts the command when it exits.
+type Sink struct {
+	batcher common.Batcher
+	command []string
+	backoff time.Duration
+	proc    *process
+	exits   int // exits in a row without a successful write since
+}
+
+// process is one run of the command.
+type process struct {
+	cmd   *osexec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{} // closed once the command exited
+}
+
+// New starts command and returns a sink streaming records to its stdin. The command's
+// stdout and stderr are passed through to freader's.
+func New(command []string, restartBackoff time.Duration, batch common.BatchOptions, includes, excludes []string) (common.Sink, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, errors.New("exec sink requires a command")
+	}
+	batch.Concurrency = 1 // batches written in parallel would interleave on stdin
+	s := &Sink{
+		batcher: common.NewBatcherWithOptions(batch, includes, excludes, "exec"),
+		command: command,
+		backoff: restartBackoff,
+	}
+	p, err := s.spawn()
+	if err != nil {
+		return nil, err
+	}
+	s.proc = p
+	s.start()
+	return s, nil
+}
+
+func (s *Sink) start() {
+	s.batcher.Wg.Add(1)
+	go func() {
+		defer s.batcher.Wg.Done()
+		s.batcher.Run(s.flush)
+	}()
+}
+
+// spawn starts the command and reaps it in the background.
+func (s *Sink) spawn() (*process, error) {
+	cmd := osexec.Command(s.command[0], s.command[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("exec sink: start %s: %w", s.command[0], err)
+	}
+	p := &process{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("exec sink command exited", "command", s.command[0], "error", err)
+		} else {
+			slog.Info("exec sink command exited", "command", s.command[0])
+		}
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// restart waits out the restart backoff and starts the command again. Stop cuts the
+// wait short so the last batch is not held back.
+func (s *Sink) restart() error {
+	wait := s.backoff
+	for i := 1; i < s.exits && wait < maxRestartBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, maxRestartBackoff)
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-s.batcher.StopCh:
+			t.Stop()
+		}
+	}
+	p, err := s.spawn()
+	if err != nil {
+		return err
+	}
+	s.proc = p
+	return nil
+}
+
+func (s *Sink) flush(lines []string) error {
+	if s.proc != nil {
+		select {
+		case <-s.proc.done:
+			s.proc = nil
+			s.exits++
+		default:
+		}
+	}
+	if s.proc == nil {
+		if err := s.restart(); err != nil {
+			s.exits++
+			return err
+		}
+	}
+	var b strings.Builder
+	for _, ln := range lines {
+		b.WriteString(ln)
+		b.WriteByte('\n')
+	}
+	if _, err := io.WriteString(s.proc.stdin, b.String()); err != nil {
+		// The command exited mid-write; the next attempt restarts it
+		_ = s.proc.stdin.Close()
+		s.proc = nil
+		s.exits++
+		return fmt.Errorf("exec sink: write to %s: %w", s.command[0], err)
+	}
+	s.exits = 0
+	return nil
+}
+
+func (s *Sink) Enqueue(line string) { s.batcher.Enqueue(line) }
+
+func (s *Sink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *Sink) Drain() []common.Entry { return s.batcher.Drain() }
+
+// Stop flushes the last batch, closes the command's stdin and waits for it to exit,
+// killing it after stopTimeout.
+func (s *Sink) Stop() error {
+	s.batcher.StopOnce.Do(func() { close(s.batcher.StopCh) })
+	s.batcher.Wg.Wait()
+	if s.proc == nil {
+		return nil
+	}
+	_ = s.proc.stdin.Close()
+	select {
+	case <-s.proc.done:
+	case <-time.After(stopTimeout):
+		_ = s.proc.cmd.Process.Kill()
+		<-s.proc.done
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// appendTo returns a command appending its stdin to path.
+func appendTo(t *testing.T, path string) []string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("exec sink tests use sh")
+	}
+	return []string{"sh", "-c", `cat >> "$0"`, path}
+}
+
+func waitForContent(t *testing.T, path, want string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		if string(data) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("content of %s = %q, want %q", path, data, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExecSink_WritesLinesToStdin(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.log")
+	s, err := New(appendTo(t, out), time.Millisecond, common.BatchOptions{Size: 2, Interval: time.Hour}, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.Enqueue("line1")
+	s.Enqueue("line2") // reaches the batch size
+	s.Enqueue("line3") // flushed on stop
+	waitForContent(t, out, "line1\nline2\n")
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	waitForContent(t, out, "line1\nline2\nline3\n")
+}
+
+func TestExecSink_RestartsExitedCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.log")
+	sink, err := New(appendTo(t, out), time.Millisecond, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s := sink.(*Sink)
+	defer func() { _ = s.Stop() }()
+	s.Enqueue("before")
+	waitForContent(t, out, "before\n")
+
+	first := s.proc
+	if err := first.cmd.Process.Kill(); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	<-first.done
+
+	s.Enqueue("after")
+	waitForContent(t, out, "before\nafter\n")
+}
+
+func TestExecSink_InvalidCommand(t *testing.T) {
+	if _, err := New(nil, time.Second, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil); err == nil {
+		t.Fatal("expected error for an empty command")
+	}
+	_, err := New([]string{filepath.Join(t.TempDir(), "missing")}, time.Second, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "start") {
+		t.Fatalf("expected a start error for a missing command, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package grpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// Config holds gRPC sink settings.
+type Config struct {
+	Target     string           `mapstructure:"target"`      // host:port or a grpc target URI
+	AckTimeout time.Duration    `mapstructure:"ack-timeout"` // 0 uses grpcsink.DefaultAckTimeout
+	TLS        common.TLSConfig `mapstructure:"tls"`
+}
+
+// Validate ensures the gRPC sink configuration is correct when used.
+func (c Config) Validate() error {
+	if c.Target == "" {
+		return fmt.Errorf("sink.grpc.target must be set when sink.type is 'grpc'")
+	}
+	if c.AckTimeout < 0 {
+		return fmt.Errorf("sink.grpc.ack-timeout must be >= 0")
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("sink.grpc: %w", err)
+	}
+	return nil
+}
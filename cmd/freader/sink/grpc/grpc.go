@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+	"github.com/loykin/freader/pkg/grpcsink"
+	"github.com/loykin/freader/pkg/grpcsink/sinkpb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Sink streams batches to a RecordSink service (pkg/grpcsink/sinkpb/sink.proto) and
+// counts a batch as delivered once the service acknowledges it.
+type Sink struct {
+	batcher common.Batcher
+	client  *grpcsink.Client
+}
+
+// New returns a sink streaming to target. Failed batches are retried by the batcher,
+// so the client itself makes one attempt per flush.
+func New(target, host string, labels map[string]string, ackTimeout time.Duration, tlsCfg *tls.Config, batch common.BatchOptions, includes, excludes []string) (common.Sink, error) {
+	if target == "" {
+		return nil, errors.New("grpc sink requires a target")
+	}
+	client, err := grpcsink.New(target, grpcsink.Options{Host: host, Labels: labels, TLS: tlsCfg, AckTimeout: ackTimeout})
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		batcher: common.NewBatcherWithOptions(batch, includes, excludes, "grpc"),
+		client:  client,
+	}
+	s.batcher.Wg.Add(1)
+	go func() {
+		defer s.batcher.Wg.Done()
+		s.batcher.RunEntries(s.flush)
+	}()
+	return s, nil
+}
+
+// flush sends entries as one batch and waits for its acknowledgement.
+func (s *Sink) flush(entries []common.Entry) error {
+	records := make([]*sinkpb.Record, len(entries))
+	for i, e := range entries {
+		records[i] = &sinkpb.Record{Line: e.Line, File: e.File, IngestTime: timestamppb.New(e.IngestTime), Headers: e.Headers}
+		if !e.EventTime.IsZero() {
+			records[i].EventTime = timestamppb.New(e.EventTime)
+		}
+	}
+	return s.client.Send(context.Background(), records)
+}
+
+func (s *Sink) Enqueue(line string) { s.batcher.Enqueue(line) }
+
+func (s *Sink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *Sink) Drain() []common.Entry { return s.batcher.Drain() }
+
+// AcksEntries marks the sink as confirming delivery (see common.Acker): the Ack of an
+// entry is settled once the service has acknowledged its batch, or with the error of
+// the last attempt once the batcher's retries are exhausted.
+func (s *Sink) AcksEntries() {}
+
+func (s *Sink) Stop() error {
+	s.batcher.StopOnce.Do(func() { close(s.batcher.StopCh) })
+	s.batcher.Wg.Wait()
+	return s.client.Close()
+}
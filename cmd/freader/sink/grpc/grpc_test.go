@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+	"github.com/loykin/freader/pkg/grpcsink/sinkpb"
+
+	"google.golang.org/grpc"
+)
+
+// server acknowledges every batch, rejecting those whose first line is "reject".
+type server struct {
+	sinkpb.UnimplementedRecordSinkServer
+	mu      sync.Mutex
+	records []*sinkpb.Record
+	host    string
+}
+
+func (s *server) Stream(stream sinkpb.RecordSink_StreamServer) error {
+	for {
+		b, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		ack := &sinkpb.Ack{Seq: b.GetSeq()}
+		if b.GetRecords()[0].GetLine() == "reject" {
+			ack.Error = "rejected"
+		} else {
+			s.mu.Lock()
+			s.records = append(s.records, b.GetRecords()...)
+			s.host = b.GetHost()
+			s.mu.Unlock()
+		}
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+func startServer(t *testing.T) (*server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server{}
+	gs := grpc.NewServer()
+	sinkpb.RegisterRecordSinkServer(gs, srv)
+	go func() { _ = gs.Serve(ln) }()
+	t.Cleanup(gs.Stop)
+	return srv, ln.Addr().String()
+}
+
+func TestGRPCSink_AckSettledByService(t *testing.T) {
+	srv, addr := startServer(t)
+	s, err := New(addr, "node-1", map[string]string{"env": "test"}, time.Second, nil,
+		common.BatchOptions{Size: 100, Interval: 20 * time.Millisecond}, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+
+	event := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, ok := s.(common.Acker); !ok {
+		t.Fatal("expected the grpc sink to confirm delivery")
+	}
+	ack := new(common.Ack)
+	s.EnqueueEntry(common.Entry{Line: "first", File: "/var/log/app.log", EventTime: event, IngestTime: event.Add(time.Second), Headers: map[string]string{"level": "error"}, Ack: ack})
+	s.EnqueueEntry(common.Entry{Line: "second", IngestTime: event, Ack: ack})
+	if err := ack.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	srv.mu.Lock()
+	records, host := srv.records, srv.host
+	srv.mu.Unlock()
+	if len(records) != 2 || host != "node-1" {
+		t.Fatalf("expected 2 acknowledged records from node-1, got %d from %q", len(records), host)
+	}
+	if got := records[0]; got.GetLine() != "first" || got.GetFile() != "/var/log/app.log" || !got.GetEventTime().AsTime().Equal(event) || got.GetHeaders()["level"] != "error" {
+		t.Fatalf("unexpected record: %v", got)
+	}
+	if records[1].GetEventTime() != nil {
+		t.Fatalf("expected no event time for a plain line, got %v", records[1].GetEventTime())
+	}
+
+	// A rejected batch fails its Ack
+	ack = new(common.Ack)
+	s.EnqueueEntry(common.Entry{Line: "reject", Ack: ack})
+	if err := ack.Wait(); err == nil {
+		t.Fatal("expected the rejected batch to fail its Ack")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (Config{}).Validate(); err == nil {
+		t.Fatal("expected an error without a target")
+	}
+	if err := (Config{Target: "localhost:9000", AckTimeout: -time.Second}).Validate(); err == nil {
+		t.Fatal("expected an error for a negative ack-timeout")
+	}
+	if err := (Config{Target: "localhost:9000"}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
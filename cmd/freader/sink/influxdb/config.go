@@ -0,0 +1,58 @@
+package influxdb
+
+import (
+	"fmt"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// Config holds InfluxDB line protocol sink settings. Records are written to the
+// InfluxDB 2.x API (/api/v2/write, org and bucket) or, with database set, the 1.x API
+// (/write); VictoriaMetrics accepts both.
+type Config struct {
+	URL       string           `mapstructure:"url"` // http(s)://host:8086, or http://host:8428 for VictoriaMetrics
+	Org       string           `mapstructure:"org"`
+	Bucket    string           `mapstructure:"bucket"`
+	Token     string           `mapstructure:"token"`    // sent as "Authorization: Token <token>"
+	Database  string           `mapstructure:"database"` // 1.x database instead of org and bucket
+	User      string           `mapstructure:"user"`     // 1.x basic auth
+	Password  string           `mapstructure:"password"`
+	ProxyURL  string           `mapstructure:"proxy-url"` // per-sink proxy; empty uses HTTP(S)_PROXY/NO_PROXY
+	TLS       common.TLSConfig `mapstructure:"tls"`
+	Precision string           `mapstructure:"precision"` // timestamp precision: "ns" (default), "us", "ms" or "s"
+	// Mapping of parsed records to points: the measurement and tag values are templates
+	// in the sink.headers syntax, and each field is a parser.fields path into JSON
+	// records whose value keeps its type (numbers are written as floats).
+	Measurement string            `mapstructure:"measurement"`
+	Tags        map[string]string `mapstructure:"tags"`
+	Fields      map[string]string `mapstructure:"fields"`
+	// Compression gzips write bodies of at least min-bytes.
+	Compression common.CompressionConfig `mapstructure:"compression"`
+}
+
+// Validate ensures the InfluxDB sink configuration is correct when used. The mapping
+// templates and paths are checked where they are compiled.
+func (c Config) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("sink.influxdb.url must be set when sink.type is 'influxdb'")
+	}
+	if c.Database == "" && c.Bucket == "" {
+		return fmt.Errorf("sink.influxdb requires bucket (2.x) or database (1.x)")
+	}
+	if c.Measurement == "" || len(c.Fields) == 0 {
+		return fmt.Errorf("sink.influxdb requires measurement and at least one field")
+	}
+	if _, err := precisionUnit(c.Precision); err != nil {
+		return fmt.Errorf("sink.influxdb: %w", err)
+	}
+	if _, err := common.ParseProxyURL(c.ProxyURL); err != nil {
+		return fmt.Errorf("sink.influxdb: %w", err)
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("sink.influxdb: %w", err)
+	}
+	if err := c.Compression.Validate("gzip"); err != nil {
+		return fmt.Errorf("sink.influxdb: %w", err)
+	}
+	return nil
+}
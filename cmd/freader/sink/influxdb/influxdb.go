@@ -0,0 +1,255 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// Point is one line protocol point. Field values are float64, int64, bool or string.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]any
+	Time        time.Time
+}
+
+// Mapper converts a record to a point; ok is false for records that do not map to
+// one, e.g. plain text, or JSON records without any of the configured fields.
+type Mapper func(e common.Entry) (p Point, ok bool)
+
+// Sink writes records mapped to points in InfluxDB line protocol over HTTP. Records
+// that do not map to a point are dropped and counted as "unmapped".
+type Sink struct {
+	batcher   common.Batcher
+	client    *http.Client
+	writeURL  string
+	token     string
+	user      string
+	password  string
+	precision time.Duration
+	mapper    Mapper
+}
+
+// New returns a sink writing to the 2.x or, with cfg.Database set, the 1.x write API
+// at cfg.URL, mapping records to points with mapper.
+func New(cfg Config, mapper Mapper, batch common.BatchOptions, includes, excludes []string, tlsCfg *tls.Config, proxy *url.URL) (common.Sink, error) {
+	if cfg.URL == "" || (cfg.Bucket == "" && cfg.Database == "") {
+		return nil, errors.New("influxdb url and bucket or database are required")
+	}
+	if mapper == nil {
+		return nil, errors.New("influxdb sink requires a record mapping")
+	}
+	precision, err := precisionUnit(cfg.Precision)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid influxdb url: %w", err)
+	}
+	q := url.Values{}
+	if cfg.Database != "" {
+		base.Path += "/write"
+		q.Set("db", cfg.Database)
+		q.Set("precision", map[time.Duration]string{time.Nanosecond: "n", time.Microsecond: "u", time.Millisecond: "ms", time.Second: "s"}[precision])
+	} else {
+		base.Path += "/api/v2/write"
+		q.Set("org", cfg.Org)
+		q.Set("bucket", cfg.Bucket)
+		q.Set("precision", map[time.Duration]string{time.Nanosecond: "ns", time.Microsecond: "us", time.Millisecond: "ms", time.Second: "s"}[precision])
+	}
+	base.RawQuery = q.Encode()
+
+	var transport http.RoundTripper = common.NewHTTPTransport(tlsCfg, proxy)
+	if cfg.Compression.Method == "gzip" {
+		transport = &common.GzipTransport{Base: transport, MinBytes: cfg.Compression.MinBytes}
+	}
+	s := &Sink{
+		batcher:   common.NewBatcherWithOptions(batch, includes, excludes, "influxdb"),
+		client:    &http.Client{Transport: transport},
+		writeURL:  base.String(),
+		token:     cfg.Token,
+		user:      cfg.User,
+		password:  cfg.Password,
+		precision: precision,
+		mapper:    mapper,
+	}
+	s.start()
+	return s, nil
+}
+
+func (s *Sink) start() {
+	s.batcher.Wg.Add(1)
+	go func() {
+		defer s.batcher.Wg.Done()
+		s.batcher.RunEntries(s.flush)
+	}()
+}
+
+func (s *Sink) Stop() error {
+	s.batcher.StopOnce.Do(func() { close(s.batcher.StopCh) })
+	s.batcher.Wg.Wait()
+	return nil
+}
+
+func (s *Sink) Enqueue(line string) { s.batcher.Enqueue(line) }
+
+func (s *Sink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *Sink) Drain() []common.Entry { return s.batcher.Drain() }
+
+// flush writes the points of the entries that map to one in a single request.
+func (s *Sink) flush(entries []common.Entry) error {
+	var body []byte
+	for _, e := range entries {
+		p, ok := s.mapper(e)
+		if !ok {
+			cmdmetrics.SinkDropped("influxdb", "unmapped")
+			continue
+		}
+		if p.Time.IsZero() {
+			p.Time = e.Time()
+		}
+		body = AppendLine(body, p, s.precision)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.writeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	} else if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		slog.Debug("influxdb write rejected", "status", resp.StatusCode, "body", string(msg))
+		return fmt.Errorf("influxdb write failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// AppendLine appends p to b in line protocol, followed by a newline, with its time in
+// units of precision. Tags are sorted by key and empty tag values left out, as line
+// protocol does not allow them; fields whose value cannot be written (NaN, infinities,
+// other types) are left out too, and a point left without fields is not written.
+func AppendLine(b []byte, p Point, precision time.Duration) []byte {
+	var fields []byte
+	for _, k := range sortedKeys(p.Fields) {
+		v, ok := fieldValue(p.Fields[k])
+		if !ok {
+			continue
+		}
+		if len(fields) > 0 {
+			fields = append(fields, ',')
+		}
+		fields = appendEscaped(fields, k, ",= ")
+		fields = append(fields, '=')
+		fields = append(fields, v...)
+	}
+	if len(fields) == 0 {
+		return b
+	}
+	b = appendEscaped(b, p.Measurement, ", ")
+	for _, k := range sortedKeys(p.Tags) {
+		if p.Tags[k] == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = appendEscaped(b, k, ",= ")
+		b = append(b, '=')
+		b = appendEscaped(b, p.Tags[k], ",= ")
+	}
+	b = append(b, ' ')
+	b = append(b, fields...)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, p.Time.UnixNano()/int64(precision), 10)
+	return append(b, '\n')
+}
+
+// fieldValue formats a field value, reporting false for values line protocol cannot carry.
+func fieldValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", true
+	case bool:
+		return strconv.FormatBool(v), true
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`, true
+	default:
+		return "", false
+	}
+}
+
+// appendEscaped appends s with the characters in special, and newlines, escaped by a
+// backslash.
+func appendEscaped(b []byte, s, special string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			b = append(b, `\n`...)
+		case strings.IndexByte(special, c) >= 0:
+			b = append(b, '\\', c)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// precisionUnit returns the duration of a precision setting: "ns" (or empty), "us",
+// "ms" or "s".
+func precisionUnit(p string) (time.Duration, error) {
+	switch p {
+	case "", "ns":
+		return time.Nanosecond, nil
+	case "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("unsupported precision %q; use ns, us, ms or s", p)
+	}
+}
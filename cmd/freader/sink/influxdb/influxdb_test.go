@@ -0,0 +1,176 @@
+package influxdb
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+func TestAppendLine(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	for _, tc := range []struct {
+		name      string
+		p         Point
+		precision time.Duration
+		want      string
+	}{
+		{
+			"types and order",
+			Point{Measurement: "http", Tags: map[string]string{"path": "/api", "host": "h1"},
+				Fields: map[string]any{"status": 200.0, "ok": true, "msg": "done", "bytes": int64(512)}, Time: ts},
+			time.Nanosecond,
+			`http,host=h1,path=/api bytes=512i,msg="done",ok=true,status=200 1700000000123456789`,
+		},
+		{
+			"escaping",
+			Point{Measurement: "my metric,x", Tags: map[string]string{"a b": "c=d,e", "empty": ""},
+				Fields: map[string]any{"f=1": `say "hi" \ bye`}, Time: ts},
+			time.Second,
+			`my\ metric\,x,a\ b=c\=d\,e f\=1="say \"hi\" \\ bye" 1700000000`,
+		},
+		{
+			"unwritable fields left out",
+			Point{Measurement: "m", Fields: map[string]any{"nan": math.NaN(), "v": 1.5, "x": []int{1}}, Time: ts},
+			time.Millisecond,
+			`m v=1.5 1700000000123`,
+		},
+		{
+			"no fields",
+			Point{Measurement: "m", Fields: map[string]any{"nan": math.Inf(1)}, Time: ts},
+			time.Nanosecond,
+			``,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := strings.TrimSuffix(string(AppendLine(nil, tc.p, tc.precision)), "\n")
+			if got != tc.want {
+				t.Fatalf("AppendLine = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInfluxDBSink_Write(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		cfg       Config
+		wantPath  string
+		wantQuery string
+		wantAuth  func(r *http.Request) bool
+		wantTime  string
+	}{
+		{
+			"v2",
+			Config{Org: "acme", Bucket: "logs", Token: "secret", Precision: "ms"},
+			"/api/v2/write", "bucket=logs&org=acme&precision=ms",
+			func(r *http.Request) bool { return r.Header.Get("Authorization") == "Token secret" },
+			"1700000000123",
+		},
+		{
+			"v1",
+			Config{Database: "logs", User: "u", Password: "p", Precision: "us"},
+			"/write", "db=logs&precision=u",
+			func(r *http.Request) bool { u, p, ok := r.BasicAuth(); return ok && u == "u" && p == "p" },
+			"1700000000123000",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var bodies []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tc.wantPath || r.URL.RawQuery != tc.wantQuery || !tc.wantAuth(r) {
+					t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				b, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(b))
+				mu.Unlock()
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer ts.Close()
+
+			cfg := tc.cfg
+			cfg.URL = ts.URL
+			mapper := func(e common.Entry) (Point, bool) {
+				if e.Line == "skip" {
+					return Point{}, false
+				}
+				return Point{Measurement: "logs", Fields: map[string]any{"line": e.Line}}, true
+			}
+			s, err := New(cfg, mapper, common.BatchOptions{Size: 10, Interval: time.Hour}, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			at := time.UnixMilli(1700000000123)
+			s.EnqueueEntry(common.Entry{Line: "a", EventTime: at})
+			s.EnqueueEntry(common.Entry{Line: "skip", EventTime: at})
+			time.Sleep(20 * time.Millisecond)
+			if err := s.Stop(); err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(bodies) != 1 {
+				t.Fatalf("expected 1 write, got %d", len(bodies))
+			}
+			if want := `logs line="a" ` + tc.wantTime + "\n"; bodies[0] != want {
+				t.Fatalf("body = %q, want %q", bodies[0], want)
+			}
+		})
+	}
+}
+
+func TestInfluxDBSink_Rejected(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":"invalid","message":"unable to parse"}`, http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	s, err := New(Config{URL: ts.URL, Bucket: "logs"}, func(e common.Entry) (Point, bool) {
+		return Point{Measurement: "logs", Fields: map[string]any{"line": e.Line}}, true
+	}, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+	err = s.(*Sink).flush([]common.Entry{{Line: "a"}})
+	if err == nil || !strings.Contains(err.Error(), "unable to parse") {
+		t.Fatalf("expected the server's error, got %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{URL: "http://localhost:8086", Bucket: "logs", Measurement: "logs", Fields: map[string]string{"v": "value"}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		edit func(c *Config)
+		want string
+	}{
+		{"no url", func(c *Config) { c.URL = "" }, "url must be set"},
+		{"no bucket", func(c *Config) { c.Bucket = "" }, "bucket (2.x) or database"},
+		{"no fields", func(c *Config) { c.Fields = nil }, "at least one field"},
+		{"precision", func(c *Config) { c.Precision = "m" }, "unsupported precision"},
+		{"compression", func(c *Config) { c.Compression.Method = "zstd" }, "compression"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := valid
+			tc.edit(&c)
+			if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Validate = %v, want error containing %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,35 @@
 package opensearch
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
 
 // Config holds OpenSearch sink connection settings.
 type Config struct {
-	URL      string `mapstructure:"url"` // http(s)://host:9200
-	Index    string `mapstructure:"index"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
+	URL      string           `mapstructure:"url"` // http(s)://host:9200
+	Index    string           `mapstructure:"index"`
+	User     string           `mapstructure:"user"`
+	Password string           `mapstructure:"password"`
+	ProxyURL string           `mapstructure:"proxy-url"` // per-sink proxy; empty uses HTTP(S)_PROXY/NO_PROXY
+	TLS      common.TLSConfig `mapstructure:"tls"`
+	// Compression gzips bulk bodies of at least min-bytes.
+	Compression common.CompressionConfig `mapstructure:"compression"`
 }
 
 func (c Config) Validate() error {
 	if c.URL == "" || c.Index == "" {
 		return fmt.Errorf("sink.opensearch requires url and index")
 	}
+	if _, err := common.ParseProxyURL(c.ProxyURL); err != nil {
+		return fmt.Errorf("sink.opensearch: %w", err)
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("sink.opensearch: %w", err)
+	}
+	if err := c.Compression.Validate("gzip"); err != nil {
+		return fmt.Errorf("sink.opensearch: %w", err)
+	}
 	return nil
 }
@@ -3,12 +3,13 @@ package opensearch
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"time"
 
-	cmdmetrics "github.com/loykin/freader/cmd/freader/metrics"
 	"github.com/loykin/freader/cmd/freader/sink/common"
 	osclient "github.com/opensearch-project/opensearch-go"
 	"github.com/opensearch-project/opensearch-go/opensearchutil"
@@ -22,7 +23,7 @@ type Sink struct {
 	labels  map[string]string
 }
 
-func New(baseURL, index, user, pass, host string, labels map[string]string, batchSize int, batchInterval time.Duration, includes, excludes []string) (common.Sink, error) {
+func New(baseURL, index, user, pass, host string, labels map[string]string, batch common.BatchOptions, includes, excludes []string, tlsCfg *tls.Config, proxy *url.URL, compression common.CompressionConfig) (common.Sink, error) {
 	if baseURL == "" || index == "" {
 		return nil, fmt.Errorf("opensearch url and index are required")
 	}
@@ -31,12 +32,16 @@ func New(baseURL, index, user, pass, host string, labels map[string]string, batc
 		cfg.Username = user
 		cfg.Password = pass
 	}
+	cfg.Transport = common.NewHTTPTransport(tlsCfg, proxy)
+	if compression.Method == "gzip" {
+		cfg.Transport = &common.GzipTransport{Base: cfg.Transport, MinBytes: compression.MinBytes}
+	}
 	cli, err := osclient.NewClient(cfg)
 	if err != nil {
 		return nil, err
 	}
 	s := &Sink{
-		batcher: common.NewBatcher(batchSize, batchInterval, includes, excludes, "opensearch"),
+		batcher: common.NewBatcherWithOptions(batch, includes, excludes, "opensearch"),
 		client:  cli,
 		index:   index,
 		host:    host,
@@ -50,32 +55,7 @@ func (s *Sink) start() {
 	s.batcher.Wg.Add(1)
 	go func() {
 		defer s.batcher.Wg.Done()
-		buf := make([]string, 0, s.batcher.BatchSize)
-		ticker := time.NewTicker(s.batcher.BatchInterval)
-		defer ticker.Stop()
-		flush := func() {
-			if len(buf) == 0 {
-				return
-			}
-			if err := s.flush(buf); err != nil {
-				slog.Error("opensearch flush failed", "error", err)
-			}
-			buf = buf[:0]
-		}
-		for {
-			select {
-			case <-s.batcher.StopCh:
-				flush()
-				return
-			case <-ticker.C:
-				flush()
-			case line := <-s.batcher.Ch:
-				buf = append(buf, line)
-				if len(buf) >= s.batcher.BatchSize {
-					flush()
-				}
-			}
-		}
+		s.batcher.RunEntries(s.flush)
 	}()
 }
 
@@ -87,27 +67,27 @@ func (s *Sink) Stop() error {
 
 func (s *Sink) Enqueue(line string) { s.batcher.Enqueue(line) }
 
-func (s *Sink) flush(lines []string) error {
+func (s *Sink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *Sink) Drain() []common.Entry { return s.batcher.Drain() }
+
+// flush indexes each entry, into the index it was routed to (Entry.Target) or the
+// configured one, with @timestamp set to its event time (falling back to the ingest
+// time), plus ingest_time and, when known, event_time.
+func (s *Sink) flush(lines []common.Entry) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
-	start := time.Now()
 	bi, err := opensearchutil.NewBulkIndexer(opensearchutil.BulkIndexerConfig{
 		Client: s.client,
 		Index:  s.index,
 	})
 	if err != nil {
-		cmdmetrics.SinkFlushObserve("opensearch", len(lines), time.Since(start), false)
 		return err
 	}
-	for _, ln := range lines {
-		doc := map[string]any{
-			"@timestamp": time.Now().UTC().Format(time.RFC3339Nano),
-			"message":    ln,
-			"host":       s.host,
-			"labels":     s.labels,
-		}
-		b, _ := json.Marshal(doc)
+	for _, e := range lines {
+		b, _ := json.Marshal(e.Document(s.host, s.labels))
 		err = bi.Add(ctx, opensearchutil.BulkIndexerItem{
+			Index:      e.Target,
 			Action:     "index",
 			DocumentID: "",
 			Body:       bytes.NewReader(b),
@@ -120,19 +100,15 @@ func (s *Sink) flush(lines []string) error {
 			},
 		})
 		if err != nil {
-			cmdmetrics.SinkFlushObserve("opensearch", len(lines), time.Since(start), false)
 			return err
 		}
 	}
 	if err := bi.Close(ctx); err != nil {
-		cmdmetrics.SinkFlushObserve("opensearch", len(lines), time.Since(start), false)
 		return err
 	}
 	stats := bi.Stats()
 	if stats.NumFailed > 0 {
-		cmdmetrics.SinkFlushObserve("opensearch", len(lines), time.Since(start), false)
 		return fmt.Errorf("opensearch bulk failed items: %d", stats.NumFailed)
 	}
-	cmdmetrics.SinkFlushObserve("opensearch", len(lines), time.Since(start), true)
 	return nil
 }
@@ -1,10 +1,18 @@
 package opensearch
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
 )
 
 func TestOpenSearchSink_StartStopWithServer(t *testing.T) {
@@ -15,7 +23,7 @@ func TestOpenSearchSink_StartStopWithServer(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	s, err := New(ts.URL, "logs-freader", "", "", "h1", map[string]string{"k": "v"}, 2, 10*time.Millisecond, nil, nil)
+	s, err := New(ts.URL, "logs-freader", "", "", "h1", map[string]string{"k": "v"}, common.BatchOptions{Size: 2, Interval: 10 * time.Millisecond}, nil, nil, nil, nil, common.CompressionConfig{})
 	if err != nil {
 		t.Fatalf("failed to create sink: %v", err)
 	}
@@ -26,7 +34,125 @@ func TestOpenSearchSink_StartStopWithServer(t *testing.T) {
 }
 
 func TestOpenSearchSink_MissingConfig(t *testing.T) {
-	if _, err := New("", "", "", "", "h1", nil, 1, 1, nil, nil); err == nil {
+	if _, err := New("", "", "", "", "h1", nil, common.BatchOptions{Size: 1, Interval: 1}, nil, nil, nil, nil, common.CompressionConfig{}); err == nil {
 		t.Fatal("expected error when url or index missing")
 	}
 }
+
+func TestOpenSearchSink_CustomCA(t *testing.T) {
+	var bulks atomic.Int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			bulks.Add(1)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	s, err := New(ts.URL, "logs-freader", "", "", "h1", nil, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil, &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil, common.CompressionConfig{})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	s.Enqueue("hello")
+	// give the batcher time to pull the line before Stop triggers the final flush
+	time.Sleep(30 * time.Millisecond)
+	_ = s.Stop()
+	if bulks.Load() == 0 {
+		t.Fatal("expected bulk request to succeed over TLS with custom CA")
+	}
+}
+
+func TestOpenSearchSink_ProxyURL(t *testing.T) {
+	// The fake proxy answers on behalf of the unreachable backend host.
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "opensearch.invalid:9200" && strings.HasSuffix(r.URL.Path, "/_bulk") {
+			proxied.Add(1)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New("http://opensearch.invalid:9200", "logs-freader", "", "", "h1", nil, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil, nil, proxyURL, common.CompressionConfig{})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	s.Enqueue("via-proxy")
+	time.Sleep(30 * time.Millisecond)
+	_ = s.Stop()
+	if proxied.Load() == 0 {
+		t.Fatal("expected bulk request to be sent through the configured proxy")
+	}
+}
+
+func TestOpenSearchSink_EventTime(t *testing.T) {
+	bodies := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			b, _ := io.ReadAll(r.Body)
+			bodies <- string(b)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer ts.Close()
+
+	s, err := New(ts.URL, "logs-freader", "", "", "h1", nil, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil, nil, nil, common.CompressionConfig{})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+
+	event := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.EnqueueEntry(common.Entry{Line: "hello", EventTime: event, IngestTime: event.Add(time.Hour)})
+	select {
+	case body := <-bodies:
+		for _, want := range []string{`"@timestamp":"2024-05-01T12:00:00Z"`, `"event_time":"2024-05-01T12:00:00Z"`, `"ingest_time":"2024-05-01T13:00:00Z"`, `"schema_version":1`} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("missing %s in %s", want, body)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no bulk request")
+	}
+}
+
+func TestOpenSearchSink_Target(t *testing.T) {
+	bodies := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			b, _ := io.ReadAll(r.Body)
+			bodies <- r.URL.Path + "\n" + string(b)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`))
+	}))
+	defer ts.Close()
+
+	s, err := New(ts.URL, "logs-${labels.app}", "", "", "h1", nil, common.BatchOptions{Size: 2, Interval: time.Hour}, nil, nil, nil, nil, common.CompressionConfig{})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+
+	s.EnqueueEntry(common.Entry{Line: "a", Target: "logs-api"})
+	s.EnqueueEntry(common.Entry{Line: "b", Target: "logs-web"})
+	select {
+	case body := <-bodies:
+		for _, want := range []string{`"_index":"logs-api"`, `"_index":"logs-web"`} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("missing %s in %s", want, body)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no bulk request")
+	}
+}
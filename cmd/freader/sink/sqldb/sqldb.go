@@ -0,0 +1,143 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/loykin/freader/cmd/freader/sink/common"
+	_ "modernc.org/sqlite"
+)
+
+// dialect is what differs between the supported databases: the database/sql driver
+// name, the placeholder syntax and the column types of a created table.
+type dialect struct {
+	driver      string
+	placeholder func(n int) string
+	columns     string
+}
+
+var dialects = map[string]dialect{
+	"postgres": {
+		driver:      "pgx",
+		placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+		columns:     "ts TIMESTAMPTZ NOT NULL, event_time TIMESTAMPTZ NOT NULL, schema_version SMALLINT NOT NULL DEFAULT 1, host TEXT NOT NULL, labels JSONB, message TEXT NOT NULL",
+	},
+	"mysql": {
+		driver:      "mysql",
+		placeholder: func(int) string { return "?" },
+		columns:     "ts DATETIME(6) NOT NULL, event_time DATETIME(6) NOT NULL, schema_version SMALLINT UNSIGNED NOT NULL DEFAULT 1, host VARCHAR(255) NOT NULL, labels JSON, message LONGTEXT NOT NULL",
+	},
+	"sqlite": {
+		driver:      "sqlite",
+		placeholder: func(int) string { return "?" },
+		columns:     "ts TIMESTAMP NOT NULL, event_time TIMESTAMP NOT NULL, schema_version INTEGER NOT NULL DEFAULT 1, host TEXT NOT NULL, labels TEXT, message TEXT NOT NULL",
+	},
+}
+
+// Sink inserts records into a table through database/sql, one transaction per batch
+// with a prepared insert statement executed for each record.
+type Sink struct {
+	batcher common.Batcher
+	db      *sql.DB
+	insert  string
+	host    string
+	labels  string // labels as a JSON object
+}
+
+// New opens the database of cfg, creating the table first when cfg.CreateTable is set.
+func New(cfg Config, host string, labels map[string]string, batch common.BatchOptions, includes, excludes []string) (common.Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	d := dialects[cfg.Driver]
+	db, err := sql.Open(d.driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", cfg.Driver, err)
+	}
+	maxConns := cfg.MaxConns
+	if maxConns == 0 {
+		maxConns = batch.Concurrency + 1
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+	if cfg.CreateTable {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+cfg.Table+" ("+d.columns+")"); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("create table %s: %w", cfg.Table, err)
+		}
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	lb, err := json.Marshal(labels)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	ph := make([]string, 6)
+	for i := range ph {
+		ph[i] = d.placeholder(i + 1)
+	}
+	s := &Sink{
+		batcher: common.NewBatcherWithOptions(batch, includes, excludes, "sql"),
+		db:      db,
+		insert:  "INSERT INTO " + cfg.Table + " (ts, event_time, schema_version, host, labels, message) VALUES (" + strings.Join(ph, ", ") + ")",
+		host:    host,
+		labels:  string(lb),
+	}
+	s.start()
+	return s, nil
+}
+
+func (s *Sink) start() {
+	s.batcher.Wg.Add(1)
+	go func() {
+		defer s.batcher.Wg.Done()
+		s.batcher.RunEntries(s.flush)
+	}()
+}
+
+func (s *Sink) Stop() error {
+	s.batcher.StopOnce.Do(func() { close(s.batcher.StopCh) })
+	s.batcher.Wg.Wait()
+	return s.db.Close()
+}
+
+func (s *Sink) Enqueue(line string) { s.batcher.Enqueue(line) }
+
+func (s *Sink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *Sink) Drain() []common.Entry { return s.batcher.Drain() }
+
+// flush inserts the entries in one transaction, with ts as the ingest time, event_time
+// as the event time (the ingest time when unknown) and schema_version as
+// common.SchemaVersion. A failed batch is rolled back, so a retry does not duplicate
+// records.
+func (s *Sink) flush(entries []common.Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.PrepareContext(ctx, s.insert)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, e := range entries {
+		if _, err := stmt.ExecContext(ctx, e.IngestTime.UTC(), e.Time().UTC(), common.SchemaVersion, s.host, s.labels, e.Line); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
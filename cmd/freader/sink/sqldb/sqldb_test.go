@@ -0,0 +1,99 @@
+package sqldb
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+func TestSQLSink_InsertSQLite(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "logs.db")
+	cfg := Config{Driver: "sqlite", DSN: dsn, Table: "logs", CreateTable: true}
+	s, err := New(cfg, "h1", map[string]string{"env": "prod"}, common.BatchOptions{Size: 10, Interval: time.Hour}, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ingest := time.Date(2024, 5, 1, 12, 0, 5, 0, time.UTC)
+	event := ingest.Add(-5 * time.Second)
+	s.EnqueueEntry(common.Entry{Line: "first", EventTime: event, IngestTime: ingest})
+	s.EnqueueEntry(common.Entry{Line: "second", IngestTime: ingest})
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	rows, err := db.Query("SELECT ts, event_time, schema_version, host, labels, message FROM logs ORDER BY message")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var got []string
+	for rows.Next() {
+		var ts, et time.Time
+		var version int
+		var host, labels, msg string
+		if err := rows.Scan(&ts, &et, &version, &host, &labels, &msg); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		if !ts.Equal(ingest) || version != common.SchemaVersion || host != "h1" || labels != `{"env":"prod"}` {
+			t.Fatalf("unexpected row %v %d %q %q for %q", ts, version, host, labels, msg)
+		}
+		got = append(got, msg+"@"+et.UTC().Format(time.TimeOnly))
+	}
+	if want := "first@12:00:00,second@12:00:05"; strings.Join(got, ",") != want {
+		t.Fatalf("rows = %v, want %s", got, want)
+	}
+}
+
+func TestSQLSink_RollsBackFailedBatch(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "logs.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec("CREATE TABLE logs (" + dialects["sqlite"].columns + ", CHECK (message <> 'bad'))"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	s, err := New(Config{Driver: "sqlite", DSN: dsn, Table: "logs"}, "h1", nil, common.BatchOptions{Size: 10, Interval: time.Hour}, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+	now := time.Now()
+	if err := s.(*Sink).flush([]common.Entry{{Line: "ok", IngestTime: now}, {Line: "bad", IngestTime: now}}); err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM logs").Scan(&n); err != nil || n != 0 {
+		t.Fatalf("expected the batch to be rolled back, got %d rows (%v)", n, err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Driver: "postgres", DSN: "postgres://localhost/logs", Table: "public.logs"}, ""},
+		{Config{Driver: "oracle", DSN: "x", Table: "logs"}, "driver"},
+		{Config{Driver: "mysql", Table: "logs"}, "requires dsn and table"},
+		{Config{Driver: "mysql", DSN: "x", Table: "logs; DROP TABLE x"}, "letters, digits"},
+		{Config{Driver: "sqlite", DSN: "x", Table: "logs", MaxConns: -1}, "max-conns"},
+	} {
+		err := tc.cfg.Validate()
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Fatalf("Validate(%+v) = %v, want %q", tc.cfg, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package unix
+
+import "fmt"
+
+// Config holds unix domain socket sink options.
+type Config struct {
+	Path string `mapstructure:"path"` // socket path
+	Mode string `mapstructure:"mode"` // "stream" (default) or "datagram"
+}
+
+// Validate ensures the unix socket sink configuration is correct when used.
+func (c Config) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("sink.unix.path must be set when sink.type is 'unix'")
+	}
+	switch c.Mode {
+	case "", "stream", "datagram":
+		return nil
+	default:
+		return fmt.Errorf("sink.unix.mode must be 'stream' or 'datagram'")
+	}
+}
@@ -0,0 +1,124 @@
+package unix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// writeTimeout bounds a flush so a reader that stopped draining the socket does not
+// block the sink forever.
+const writeTimeout = 10 * time.Second
+
+// Sink writes records as NDJSON to a unix domain socket. A stream socket carries each
+// batch in one write; a datagram socket gets one record per datagram.
+type Sink struct {
+	batcher  common.Batcher
+	path     string
+	datagram bool
+	host     string
+	labels   map[string]string
+	conn     net.Conn
+}
+
+// New returns a sink writing to the socket at path; mode is "stream" (default) or
+// "datagram". The socket is connected on the first flush and again after a failed one,
+// so the receiving agent may start after freader or restart.
+func New(path, mode, host string, labels map[string]string, batch common.BatchOptions, includes, excludes []string) (common.Sink, error) {
+	if path == "" {
+		return nil, errors.New("unix socket sink requires a path")
+	}
+	if mode != "" && mode != "stream" && mode != "datagram" {
+		return nil, fmt.Errorf("unsupported unix socket mode: %s", mode)
+	}
+	batch.Concurrency = 1 // one connection; parallel batches would interleave
+	s := &Sink{
+		batcher:  common.NewBatcherWithOptions(batch, includes, excludes, "unix"),
+		path:     path,
+		datagram: mode == "datagram",
+		host:     host,
+		labels:   labels,
+	}
+	s.start()
+	return s, nil
+}
+
+func (s *Sink) start() {
+	s.batcher.Wg.Add(1)
+	go func() {
+		defer s.batcher.Wg.Done()
+		s.batcher.RunEntries(s.flush)
+	}()
+}
+
+func (s *Sink) dial() error {
+	network := "unix"
+	if s.datagram {
+		network = "unixgram"
+	}
+	conn, err := net.Dial(network, s.path)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// flush writes each entry as one JSON document per line (see common.Entry.Document).
+// On error the connection is dropped and dialed again by the next attempt.
+func (s *Sink) flush(entries []common.Entry) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	var buf bytes.Buffer
+	for _, e := range entries {
+		b, err := json.Marshal(e.Document(s.host, s.labels))
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+		if s.datagram {
+			if err := s.write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+	}
+	if s.datagram {
+		return nil
+	}
+	return s.write(buf.Bytes())
+}
+
+func (s *Sink) write(b []byte) error {
+	if _, err := s.conn.Write(b); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *Sink) Enqueue(line string) { s.batcher.Enqueue(line) }
+
+func (s *Sink) EnqueueEntry(e common.Entry) { s.batcher.EnqueueEntry(e) }
+
+func (s *Sink) Drain() []common.Entry { return s.batcher.Drain() }
+
+func (s *Sink) Stop() error {
+	s.batcher.StopOnce.Do(func() { close(s.batcher.StopCh) })
+	s.batcher.Wg.Wait()
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package unix
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/cmd/freader/sink/common"
+)
+
+// socketPath returns a short socket path; unix socket paths are limited to ~100 bytes.
+func socketPath(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket sink tests are not run on Windows")
+	}
+	dir, err := os.MkdirTemp("", "fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "s.sock")
+}
+
+func decode(t *testing.T, line []byte) map[string]any {
+	t.Helper()
+	var doc map[string]any
+	if err := json.Unmarshal(line, &doc); err != nil {
+		t.Fatalf("invalid json %q: %v", line, err)
+	}
+	return doc
+}
+
+func TestUnixSink_Stream(t *testing.T) {
+	path := socketPath(t)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	s, err := New(path, "stream", "node-1", map[string]string{"env": "test"},
+		common.BatchOptions{Size: 2, Interval: time.Hour, Retries: 1, Backoff: time.Millisecond}, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+	event := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.EnqueueEntry(common.Entry{Line: "first", EventTime: event, IngestTime: event.Add(time.Second)})
+	s.Enqueue("second")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	doc := decode(t, line)
+	if doc["message"] != "first" || doc["host"] != "node-1" || doc["@timestamp"] != "2024-05-01T12:00:00Z" ||
+		doc["event_time"] != "2024-05-01T12:00:00Z" || doc["ingest_time"] != "2024-05-01T12:00:01Z" ||
+		doc["schema_version"] != float64(common.SchemaVersion) {
+		t.Fatalf("unexpected document: %v", doc)
+	}
+	if labels, _ := doc["labels"].(map[string]any); labels["env"] != "test" {
+		t.Fatalf("unexpected labels: %v", doc["labels"])
+	}
+	line, err = r.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if doc := decode(t, line); doc["message"] != "second" {
+		t.Fatalf("unexpected document: %v", doc)
+	}
+
+	// A receiver that went away is reconnected to by the retry
+	_ = conn.Close()
+	s.Enqueue("third")
+	s.Enqueue("fourth")
+	conn, err = ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	r = bufio.NewReader(conn)
+	for _, want := range []string{"third", "fourth"} {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("read after reconnect: %v", err)
+		}
+		if doc := decode(t, line); doc["message"] != want {
+			t.Fatalf("message = %v, want %s", doc["message"], want)
+		}
+	}
+}
+
+func TestUnixSink_Datagram(t *testing.T) {
+	path := socketPath(t)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	s, err := New(path, "datagram", "node-1", nil, common.BatchOptions{Size: 2, Interval: time.Hour}, nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+	s.Enqueue("a")
+	s.Enqueue("b")
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 4096)
+	for _, want := range []string{"a", "b"} {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if doc := decode(t, buf[:n]); doc["message"] != want {
+			t.Fatalf("message = %v, want %s", doc["message"], want)
+		}
+	}
+}
+
+func TestUnixSink_InvalidConfig(t *testing.T) {
+	if _, err := New("", "stream", "", nil, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil); err == nil {
+		t.Fatal("expected error for an empty path")
+	}
+	if _, err := New("/tmp/x.sock", "seqpacket", "", nil, common.BatchOptions{Size: 1, Interval: time.Hour}, nil, nil); err == nil {
+		t.Fatal("expected error for an unsupported mode")
+	}
+	if err := (Config{Path: "/tmp/x.sock", Mode: "seqpacket"}).Validate(); err == nil {
+		t.Fatal("expected validation error for an unsupported mode")
+	}
+}
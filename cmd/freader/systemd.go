@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends a state such as "READY=1" to the systemd notification socket named by
+// NOTIFY_SOCKET (sd_notify(3)). It reports false without error when freader is not
+// running under a Type=notify unit.
+func sdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// A leading '@' names a Linux abstract socket; net handles the translation
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sdWatchdogInterval returns the watchdog timeout systemd expects pings within
+// (WatchdogSec=, passed as WATCHDOG_USEC), or 0 when the watchdog is not enabled for
+// this process.
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// runWatchdog pings the systemd watchdog at half the timeout while healthy reports
+// true, so a wedged scan loop stops the pings and systemd restarts the service.
+// It returns when stop is closed.
+func runWatchdog(stop <-chan struct{}, timeout time.Duration, healthy func() bool) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if healthy() {
+				_, _ = sdNotify("WATCHDOG=1")
+			}
+		}
+	}
+}
+
+// scanHealthy reports whether the collector's scan loop is making progress: the last
+// scan finished within the allowed staleness, counted from start until the first scan.
+func scanHealthy(lastScan func() (time.Time, time.Duration), started time.Time, stale time.Duration) func() bool {
+	return func() bool {
+		at, _ := lastScan()
+		if at.IsZero() {
+			at = started
+		}
+		return time.Since(at) <= stale
+	}
+}
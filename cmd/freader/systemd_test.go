@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := sdNotify("READY=1"); sent || err != nil {
+		t.Fatalf("without NOTIFY_SOCKET: sent=%v err=%v", sent, err)
+	}
+
+	// Keep the socket path short; unix socket paths are limited to ~100 bytes
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	addr := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	t.Setenv("NOTIFY_SOCKET", addr)
+	if sent, err := sdNotify("READY=1"); !sent || err != nil {
+		t.Fatalf("sdNotify: sent=%v err=%v", sent, err)
+	}
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Fatalf("got %q", got)
+	}
+
+	// Watchdog pings stop while the scan loop is unhealthy
+	healthy := make(chan bool, 1)
+	healthy <- false
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runWatchdog(stop, 20*time.Millisecond, func() bool {
+			select {
+			case h := <-healthy:
+				return h
+			default:
+				return true
+			}
+		})
+		close(done)
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err = conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "WATCHDOG=1" {
+		t.Fatalf("got %q", got)
+	}
+	close(stop)
+	<-done
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	if d := sdWatchdogInterval(); d != 0 {
+		t.Fatalf("watchdog disabled: got %v", d)
+	}
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	if d := sdWatchdogInterval(); d != 30*time.Second {
+		t.Fatalf("got %v, want 30s", d)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if d := sdWatchdogInterval(); d != 0 {
+		t.Fatalf("watchdog for another pid: got %v", d)
+	}
+}
+
+func TestScanHealthy(t *testing.T) {
+	var last time.Time
+	lastScan := func() (time.Time, time.Duration) { return last, 0 }
+
+	healthy := scanHealthy(lastScan, time.Now(), time.Minute)
+	if !healthy() {
+		t.Fatal("healthy before the first scan within the grace period")
+	}
+	if scanHealthy(lastScan, time.Now().Add(-2*time.Minute), time.Minute)() {
+		t.Fatal("no scan since start beyond the staleness limit")
+	}
+	last = time.Now().Add(-2 * time.Minute)
+	if healthy() {
+		t.Fatal("stale last scan must be unhealthy")
+	}
+	last = time.Now()
+	if !healthy() {
+		t.Fatal("recent scan must be healthy")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loykin/freader/pkg/parser/audit"
+	"github.com/loykin/freader/pkg/parser/container"
+)
+
+// timestampLayoutUnix parses epoch seconds with an optional fraction (e.g. 1700000000.123).
+const timestampLayoutUnix = "unix"
+
+// timestampFunc builds the record timestamp extractor used by --start-from-time and for
+// the event time sent to sinks. An explicit parser.timestamp-pattern wins, then
+// parser.timestamp-field; otherwise the auditd or container parser supplies the time.
+// It returns nil when no timestamp source is configured.
+func (p ParserConfig) timestampFunc() (func(string) (time.Time, bool), error) {
+	if p.TimestampPattern != "" {
+		re, err := regexp.Compile(p.TimestampPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parser.timestamp-pattern: %w", err)
+		}
+		layout := p.TimestampLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return func(record string) (time.Time, bool) {
+			m := re.FindStringSubmatch(record)
+			if m == nil {
+				return time.Time{}, false
+			}
+			// Use the first capture group when present, otherwise the whole match
+			value := m[0]
+			if len(m) > 1 {
+				value = m[1]
+			}
+			return parseTimestamp(value, layout)
+		}, nil
+	}
+	if p.TimestampField != "" {
+		layout := p.TimestampLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		path := strings.Split(p.TimestampField, ".")
+		return func(record string) (time.Time, bool) {
+			return jsonTimestamp(record, path, layout)
+		}, nil
+	}
+	switch p.Type {
+	case "auditd":
+		return func(record string) (time.Time, bool) {
+			rec, ok, _ := audit.Parse(record)
+			if !ok || rec.EpochSec == 0 {
+				return time.Time{}, false
+			}
+			return time.Unix(rec.EpochSec, rec.EpochNSec), true
+		}, nil
+	case parserTypeCRI:
+		return containerTimestamp(container.ParseCRI), nil
+	case parserTypeDockerJSON:
+		return containerTimestamp(container.ParseDockerJSON), nil
+	}
+	return nil, nil
+}
+
+func parseTimestamp(value, layout string) (time.Time, bool) {
+	if layout == timestampLayoutUnix {
+		sec, frac, _ := strings.Cut(value, ".")
+		s, err := strconv.ParseInt(sec, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		var nsec int64
+		if frac != "" {
+			if len(frac) > 9 {
+				frac = frac[:9]
+			}
+			frac += strings.Repeat("0", 9-len(frac))
+			if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+				return time.Time{}, false
+			}
+		}
+		return time.Unix(s, nsec), true
+	}
+	ts, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+// jsonTimestamp reads the field at path from a JSON object record. Strings are parsed
+// with layout; numbers are epoch seconds.
+func jsonTimestamp(record string, path []string, layout string) (time.Time, bool) {
+	var v any
+	if err := json.Unmarshal([]byte(record), &v); err != nil {
+		return time.Time{}, false
+	}
+	for _, key := range path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return time.Time{}, false
+		}
+		if v, ok = obj[key]; !ok {
+			return time.Time{}, false
+		}
+	}
+	switch val := v.(type) {
+	case string:
+		return parseTimestamp(val, layout)
+	case float64:
+		return parseTimestamp(strconv.FormatFloat(val, 'f', -1, 64), timestampLayoutUnix)
+	default:
+		return time.Time{}, false
+	}
+}
+
+func containerTimestamp(parse func(string) (container.Record, bool)) func(string) (time.Time, bool) {
+	return func(record string) (time.Time, bool) {
+		rec, ok := parse(record)
+		if !ok || rec.Time.IsZero() {
+			return time.Time{}, false
+		}
+		return rec.Time, true
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParserTimestampFunc(t *testing.T) {
+	p := ParserConfig{TimestampPattern: `^(\S+) `}
+	fn, err := p.timestampFunc()
+	if err != nil || fn == nil {
+		t.Fatalf("timestampFunc() nil=%v, err=%v", fn == nil, err)
+	}
+	ts, ok := fn("2024-05-01T12:00:00Z GET /health")
+	if !ok || !ts.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got %v, %v", ts, ok)
+	}
+	if _, ok := fn("no timestamp here"); ok {
+		t.Fatal("expected no timestamp for non-matching record")
+	}
+
+	p = ParserConfig{TimestampPattern: `ts=(\d+\.\d+)`, TimestampLayout: "unix"}
+	fn, _ = p.timestampFunc()
+	ts, ok = fn("level=info ts=1700000000.5 msg=hi")
+	if !ok || !ts.Equal(time.Unix(1700000000, 500000000)) {
+		t.Fatalf("unix layout: got %v, %v", ts, ok)
+	}
+
+	fn, _ = (ParserConfig{TimestampField: "meta.ts"}).timestampFunc()
+	ts, ok = fn(`{"msg":"hi","meta":{"ts":"2024-05-01T12:00:00.25Z"}}`)
+	if !ok || !ts.Equal(time.Date(2024, 5, 1, 12, 0, 0, 250000000, time.UTC)) {
+		t.Fatalf("timestamp-field: got %v, %v", ts, ok)
+	}
+	ts, ok = fn(`{"meta":{"ts":1700000000.5}}`)
+	if !ok || !ts.Equal(time.Unix(1700000000, 500000000)) {
+		t.Fatalf("numeric timestamp-field: got %v, %v", ts, ok)
+	}
+	for _, record := range []string{`{"meta":{}}`, `{"meta":"x"}`, "not json", `{"meta":{"ts":true}}`} {
+		if _, ok := fn(record); ok {
+			t.Fatalf("expected no timestamp for %s", record)
+		}
+	}
+
+	if _, err := (ParserConfig{TimestampPattern: "("}).timestampFunc(); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+	if fn, _ := (ParserConfig{}).timestampFunc(); fn != nil {
+		t.Fatal("expected nil without a timestamp source")
+	}
+
+	if runtime.GOOS == "linux" {
+		fn, _ = (ParserConfig{Type: "auditd"}).timestampFunc()
+		ts, ok = fn("type=SYSCALL msg=audit(1700000000.123:456): arch=c000003e")
+		if !ok || !ts.Equal(time.Unix(1700000000, 123000000)) {
+			t.Fatalf("auditd: got %v, %v", ts, ok)
+		}
+	}
+}
+
+func TestValidate_StartFromTime(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StartFromTime = "2024-05-01T12:00:00Z"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("start-from-time without a timestamp source should fail")
+	}
+	cfg.Parser.TimestampPattern = `^(\S+)`
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.StartFromTime = "yesterday"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("invalid start-from-time should fail")
+	}
+}
+
+func TestValidate_MergeWindow(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Collector.MergeWindow = time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("merge-window without a timestamp source should fail")
+	}
+	cfg.Parser.TimestampPattern = `^(\S+)`
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Collector.TimestampFunc != nil {
+		t.Fatal("Validate must not modify the collector config")
+	}
+	cfg.Sink.Type = "grpc"
+	cfg.Sink.Grpc.Target = "localhost:9000"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("merge-window with the grpc sink should fail")
+	}
+}
@@ -9,9 +9,15 @@
 package freader
 
 import (
+	"io"
+	"regexp"
+
+	"github.com/loykin/freader/internal/clock"
 	"github.com/loykin/freader/internal/collector"
 	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/kubelease"
 	"github.com/loykin/freader/internal/metrics"
+	"github.com/loykin/freader/internal/store"
 	"github.com/loykin/freader/internal/tailer"
 	"github.com/loykin/freader/internal/watcher"
 	"github.com/prometheus/client_golang/prometheus"
@@ -24,10 +30,143 @@ type Config = collector.Config
 // LineEvent re-exports collector.LineEvent for event callbacks.
 type LineEvent = collector.LineEvent
 
+// SeparatorRule re-exports collector.SeparatorRule for Config.SeparatorRules.
+type SeparatorRule = collector.SeparatorRule
+
+// MultilineRule re-exports collector.MultilineRule for Config.MultilineRules.
+type MultilineRule = collector.MultilineRule
+
+// PriorityRule re-exports collector.PriorityRule for Config.PriorityRules.
+type PriorityRule = collector.PriorityRule
+
+// Record re-exports collector.Record delivered on Collector.Records.
+type Record = collector.Record
+
+// DefaultLinesBatchSize is the Config.OnLinesFunc batch size used when Config.LinesBatchSize is 0.
+const DefaultLinesBatchSize = collector.DefaultLinesBatchSize
+
+// DefaultRecordsBuffer is the Collector.Records channel capacity used when Config.RecordsBuffer is 0.
+const DefaultRecordsBuffer = collector.DefaultRecordsBuffer
+
+// DeliveryRetryDelay is how long a file waits before its records are read again after
+// Config.OnLinesAckFunc failed to deliver them.
+const DeliveryRetryDelay = collector.DeliveryRetryDelay
+
+// DefaultCatchUpReaders is the number of parallel chunk readers used when Config.CatchUpReaders is 0.
+const DefaultCatchUpReaders = collector.DefaultCatchUpReaders
+
+// DefaultIdleRecheckInterval is how often idle files are read when Config.IdleRecheckInterval is 0.
+const DefaultIdleRecheckInterval = collector.DefaultIdleRecheckInterval
+
+// DefaultNetworkFSRetries is the Config.NetworkFSRetries used when it is 0.
+const DefaultNetworkFSRetries = collector.DefaultNetworkFSRetries
+
+// DefaultStoreMaintenanceInterval is the Config.StoreMaintenanceInterval set by Config.Default.
+const DefaultStoreMaintenanceInterval = collector.DefaultStoreMaintenanceInterval
+
+// DefaultLeaseTTL is the Config.LeaseTTL set by Config.Default.
+const DefaultLeaseTTL = collector.DefaultLeaseTTL
+
+// ErrorContext re-exports collector.ErrorContext passed to Config.OnErrorFunc.
+type ErrorContext = collector.ErrorContext
+
+// ErrorKind re-exports collector.ErrorKind classifying errors passed to Config.OnErrorFunc.
+type ErrorKind = collector.ErrorKind
+
+// Error kinds reported through Config.OnErrorFunc.
+const (
+	ErrorKindRead                = collector.ErrorKindRead
+	ErrorKindFingerprintMismatch = collector.ErrorKindFingerprintMismatch
+	ErrorKindStore               = collector.ErrorKindStore
+	ErrorKindCallback            = collector.ErrorKindCallback
+	ErrorKindOffset              = collector.ErrorKindOffset
+	ErrorKindDelivery            = collector.ErrorKindDelivery
+)
+
+// Policies for Config.OffsetMismatch.
+const (
+	OffsetMismatchStart      = collector.OffsetMismatchStart
+	OffsetMismatchEnd        = collector.OffsetMismatchEnd
+	OffsetMismatchQuarantine = collector.OffsetMismatchQuarantine
+)
+
 // Collector re-exports collector.Collector so callers can keep the concrete type
 // when using the root-level constructor.
 type Collector = collector.Collector
 
+// Stats re-exports collector.Stats returned by Collector.Stats.
+type Stats = collector.Stats
+
+// FileStats re-exports collector.FileStats describing one tracked file in Stats.
+type FileStats = collector.FileStats
+
+// TrackedFile re-exports collector.TrackedFile returned by Collector.TrackedFiles.
+type TrackedFile = collector.TrackedFile
+
+// UnreadableFile re-exports collector.UnreadableFile listed by Collector.Unreadable.
+type UnreadableFile = collector.UnreadableFile
+
+// DeletedFile re-exports collector.DeletedFile listed in Stats.Deleted.
+type DeletedFile = collector.DeletedFile
+
+// QuarantinedFile re-exports collector.QuarantinedFile listed in Stats.Quarantined.
+type QuarantinedFile = collector.QuarantinedFile
+
+// Decision re-exports collector.Decision listed by Collector.Decisions.
+type Decision = collector.Decision
+
+// Decision actions.
+const (
+	DecisionAdded   = watcher.DecisionAdded
+	DecisionRemoved = watcher.DecisionRemoved
+	DecisionSkipped = watcher.DecisionSkipped
+
+	DecisionIncluded = watcher.DecisionIncluded
+	DecisionExcluded = watcher.DecisionExcluded
+)
+
+// DebugState re-exports collector.DebugState returned by Collector.DebugState and
+// served by Collector.DebugHandler.
+type DebugState = collector.DebugState
+
+// RecentRecord re-exports collector.RecentRecord served by Collector.RecentHandler.
+type RecentRecord = collector.RecentRecord
+
+// ListedFile re-exports collector.ListedFile returned by ListFiles.
+type ListedFile = collector.ListedFile
+
+// ListFiles reports the files a configuration matches, their stored offsets and any
+// that cannot be read, without starting a collector.
+func ListFiles(cfg Config) ([]ListedFile, error) { return collector.ListFiles(cfg) }
+
+// RepeatSummary formats a run of n repeated copies of line the way Config.RepeatWindow
+// hands it to line callbacks: "message repeated n times: [line]".
+func RepeatSummary(line string, n int) string { return collector.RepeatSummary(line, n) }
+
+// ExplainPath reports whether a configuration would track the file at path and, if
+// not, why, without starting a collector.
+func ExplainPath(cfg Config, path string) (Decision, error) { return collector.ExplainPath(cfg, path) }
+
+// Leaser re-exports store.Leaser, the lease behind Config.LeaseTTL and Config.Standby,
+// for custom Config.Leaser implementations.
+type Leaser = store.Leaser
+
+// Lease re-exports store.Lease, the holder recorded by a Leaser.
+type Lease = store.Lease
+
+// KubernetesLeaseConfig re-exports kubelease.Config locating a Kubernetes Lease object.
+type KubernetesLeaseConfig = kubelease.Config
+
+// NewKubernetesLeaser returns a Leaser holding the lease in a Kubernetes
+// coordination.k8s.io/v1 Lease, for Config.Leaser.
+func NewKubernetesLeaser(cfg KubernetesLeaseConfig) (Leaser, error) {
+	l, err := kubelease.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
 // FileTracker re-exports file_tracker.FileTracker for root-level usage.
 type FileTracker = file_tracker.FileTracker
 
@@ -37,9 +176,89 @@ func NewFileTracker() *FileTracker { return file_tracker.New() }
 // GetFileIDFromPath re-exports the utility to compute a file ID from a path.
 func GetFileIDFromPath(path string) (string, error) { return file_tracker.GetFileIDFromPath(path) }
 
+// Sentinel errors re-exported so callers can react with errors.Is instead of matching strings.
+var (
+	// ErrFingerprintMismatch: a tracked file's content changed (rotation, truncation, overwrite).
+	ErrFingerprintMismatch = tailer.ErrFingerprintMismatch
+	// ErrFileTooSmall: a file is smaller than the checksum fingerprint size.
+	ErrFileTooSmall = file_tracker.ErrFileTooSmall
+	// ErrNotEnoughSeparators: a file has fewer separators than the checksumSeparator fingerprint needs.
+	ErrNotEnoughSeparators = file_tracker.ErrNotEnoughSeparators
+	// ErrStoreCorrupt: the offsets database is not a valid SQLite database or fails its
+	// integrity check; see Config.RebuildCorruptStore.
+	ErrStoreCorrupt = store.ErrStoreCorrupt
+	// ErrLeaseHeld: another collector process holds the lease on the offsets database.
+	ErrLeaseHeld = store.ErrLeaseHeld
+	// ErrLeaseLost: another collector process took over the lease; offsets are no longer saved.
+	ErrLeaseLost = store.ErrLeaseLost
+	// ErrFileNotTracked: a per-file operation such as Collector.SeekFile named an untracked path.
+	ErrFileNotTracked = collector.ErrFileNotTracked
+	// ErrFileChanged: MigrateOffsets found a file no longer matching its stored fingerprint.
+	ErrFileChanged = collector.ErrFileChanged
+	// ErrRecordTooLarge: a length prefix exceeded LengthPrefix.MaxRecordSize.
+	ErrRecordTooLarge = tailer.ErrRecordTooLarge
+	// ErrAlreadyStarted: Collector.RunOnce was called on a collector already started.
+	ErrAlreadyStarted = collector.ErrAlreadyStarted
+	// ErrCallbackStalled: a record callback ran longer than Config.CallbackTimeout.
+	ErrCallbackStalled = collector.ErrCallbackStalled
+	// ErrOffsetMismatch: a stored offset failed verification on resume; see
+	// Config.OffsetMismatch.
+	ErrOffsetMismatch = collector.ErrOffsetMismatch
+)
+
+// FileFingerprintMismatchError re-exports the typed mismatch error for use with errors.As.
+type FileFingerprintMismatchError = tailer.FileFingerprintMismatchError
+
+// FileSizeTooSmallError re-exports the typed size error for use with errors.As.
+type FileSizeTooSmallError = file_tracker.FileSizeTooSmallError
+
+// NotEnoughSeparatorsError re-exports the typed separator error for use with errors.As.
+type NotEnoughSeparatorsError = file_tracker.NotEnoughSeparatorsError
+
 // TailReader re-exports tailer.TailReader for root-level usage.
 type TailReader = tailer.TailReader
 
+// ReaderTail re-exports tailer.ReaderTail, which splits any io.Reader into records.
+type ReaderTail = tailer.ReaderTail
+
+// ReaderTailOption re-exports tailer.ReaderTailOption.
+type ReaderTailOption = tailer.ReaderTailOption
+
+// NewReaderTail applies the collector's separator/multiline record splitting to an
+// arbitrary stream such as a network connection or a decompression reader.
+func NewReaderTail(r io.Reader, opts ...ReaderTailOption) *ReaderTail {
+	return tailer.NewReaderTail(r, opts...)
+}
+
+// WithReaderSeparator sets the ReaderTail record separator (default "\n").
+func WithReaderSeparator(sep string) ReaderTailOption { return tailer.WithSeparator(sep) }
+
+// WithReaderSeparatorRegex splits ReaderTail records on regex matches; see CompileSeparatorRegex.
+func WithReaderSeparatorRegex(re *regexp.Regexp) ReaderTailOption {
+	return tailer.WithSeparatorRegex(re)
+}
+
+// CompileSeparatorRegex compiles a record separator pattern such as `\r?\n`, rejecting
+// patterns that match the empty string.
+func CompileSeparatorRegex(expr string) (*regexp.Regexp, error) {
+	return tailer.CompileSeparatorRegex(expr)
+}
+
+// WithReaderMultiline enables multiline grouping for a ReaderTail.
+func WithReaderMultiline(m *MultilineReader) ReaderTailOption { return tailer.WithMultiline(m) }
+
+// WithReaderBufferSize sets the ReaderTail buffered read size (default 4KB).
+func WithReaderBufferSize(n int) ReaderTailOption { return tailer.WithReadBufferSize(n) }
+
+// LengthPrefix re-exports tailer.LengthPrefix describing length-prefixed binary framing.
+type LengthPrefix = tailer.LengthPrefix
+
+// DefaultMaxRecordSize bounds length-prefixed records when LengthPrefix.MaxRecordSize is 0.
+const DefaultMaxRecordSize = tailer.DefaultMaxRecordSize
+
+// WithReaderLengthPrefix reads length-prefixed binary records from a ReaderTail.
+func WithReaderLengthPrefix(lp *LengthPrefix) ReaderTailOption { return tailer.WithLengthPrefix(lp) }
+
 // MultilineReader re-exports tailer.MultilineReader so external users don't import internal packages.
 type MultilineReader = tailer.MultilineReader
 
@@ -61,11 +280,187 @@ func NewCollector(cfg Config) (*Collector, error) {
 	return collector.NewCollector(cfg)
 }
 
+// OffsetMigration re-exports collector.OffsetMigration for MigrateOffsets.
+type OffsetMigration = collector.OffsetMigration
+
+// MigratedOffset re-exports collector.MigratedOffset reported by MigrateOffsets.
+type MigratedOffset = collector.MigratedOffset
+
+// MigrateOffsets rewrites offsets stored under one fingerprint strategy for another,
+// so switching strategies does not re-read files still on disk.
+func MigrateOffsets(m OffsetMigration) ([]MigratedOffset, error) {
+	return collector.MigrateOffsets(m)
+}
+
+// ForeignPosition re-exports collector.ForeignPosition, a position recorded by another agent.
+type ForeignPosition = collector.ForeignPosition
+
+// OffsetImport re-exports collector.OffsetImport for ImportOffsets.
+type OffsetImport = collector.OffsetImport
+
+// ImportedOffset re-exports collector.ImportedOffset reported by ImportOffsets.
+type ImportedOffset = collector.ImportedOffset
+
+// ImportOffsets stores positions recorded by another agent as offsets for files still
+// on disk, so migrating from it does not re-ingest what it already shipped.
+func ImportOffsets(im OffsetImport) ([]ImportedOffset, error) {
+	return collector.ImportOffsets(im)
+}
+
+// ReadFilebeatRegistry reads file positions from a Filebeat registry directory or file.
+func ReadFilebeatRegistry(path string) ([]ForeignPosition, error) {
+	return collector.ReadFilebeatRegistry(path)
+}
+
+// ReadPromtailPositions reads file positions from a Promtail positions.yaml.
+func ReadPromtailPositions(path string) ([]ForeignPosition, error) {
+	return collector.ReadPromtailPositions(path)
+}
+
+// OffsetSnapshot re-exports collector.OffsetSnapshot, the JSON format of ExportOffsets.
+type OffsetSnapshot = collector.OffsetSnapshot
+
+// SnapshotOffset re-exports collector.SnapshotOffset.
+type SnapshotOffset = collector.SnapshotOffset
+
+// ExportOffsets writes the stored offsets (all strategies when strategy is empty) to w
+// as a JSON OffsetSnapshot and returns how many were written.
+func ExportOffsets(dbPath, strategy string, w io.Writer) (int, error) {
+	return collector.ExportOffsets(dbPath, strategy, w)
+}
+
+// RestoreOffsets stores the offsets of a JSON OffsetSnapshot read from r; with replace,
+// all other stored offsets are deleted first.
+func RestoreOffsets(dbPath string, r io.Reader, replace bool) (int, error) {
+	return collector.RestoreOffsets(dbPath, r, replace)
+}
+
+// Option re-exports collector.Option for New.
+type Option = collector.Option
+
+// New constructs a Collector from functional options, validating the result. It is an
+// alternative to NewCollector with a Config struct; offsets are only stored with WithStore.
+func New(opts ...Option) (*Collector, error) {
+	return collector.New(opts...)
+}
+
+// Functional options for New.
+var (
+	WithInclude       = collector.WithInclude
+	WithExclude       = collector.WithExclude
+	WithSeparator     = collector.WithSeparator
+	WithPollInterval  = collector.WithPollInterval
+	WithWorkers       = collector.WithWorkers
+	WithFingerprint   = collector.WithFingerprint
+	WithMultiline     = collector.WithMultiline
+	WithStore         = collector.WithStore
+	WithOnLine        = collector.WithOnLine
+	WithOnLineBytes   = collector.WithOnLineBytes
+	WithOnEvent       = collector.WithOnEvent
+	WithOnError       = collector.WithOnError
+	WithLogger        = collector.WithLogger
+	WithFromBeginning = collector.WithFromBeginning
+	WithStartFromTime = collector.WithStartFromTime
+	WithRecordsBuffer = collector.WithRecordsBuffer
+	WithOnLines       = collector.WithOnLines
+	WithOnLinesAck    = collector.WithOnLinesAck
+	WithOnLinesErr    = collector.WithOnLinesErr
+	WithLinesBatch    = collector.WithLinesBatch
+	WithBufferSizes   = collector.WithBufferSizes
+
+	WithStoreMaintenance = collector.WithStoreMaintenance
+	WithLease            = collector.WithLease
+	WithStandby          = collector.WithStandby
+	WithLeaser           = collector.WithLeaser
+	WithShard            = collector.WithShard
+	WithShardDiscovery   = collector.WithShardDiscovery
+	WithMetrics          = collector.WithMetrics
+	WithScanTrace        = collector.WithScanTrace
+	WithClock            = collector.WithClock
+	WithMultilineRule    = collector.WithMultilineRule
+	WithPriorityRule     = collector.WithPriorityRule
+	WithRepeatWindow     = collector.WithRepeatWindow
+	WithMergeWindow      = collector.WithMergeWindow
+	WithCatchUp          = collector.WithCatchUp
+	WithStartupThrottle  = collector.WithStartupThrottle
+	WithCallbackTimeout  = collector.WithCallbackTimeout
+	WithPostgresStore    = collector.WithPostgresStore
+	WithIdleFiles        = collector.WithIdleFiles
+	WithScanBudget       = collector.WithScanBudget
+	WithExcludeDirs      = collector.WithExcludeDirs
+	WithIgnoreFile       = collector.WithIgnoreFile
+	WithFollowName       = collector.WithFollowName
+	WithSkipLocked       = collector.WithSkipLocked
+	WithHoldDeleted      = collector.WithHoldDeleted
+	WithOffsetMismatch   = collector.WithOffsetMismatch
+	WithKeepDuplicates   = collector.WithKeepDuplicates
+	WithReadOnlyOffsets  = collector.WithReadOnlyOffsets
+
+	WithFingerprintOffset   = collector.WithFingerprintOffset
+	WithFingerprintUpgrade  = collector.WithFingerprintUpgrade
+	WithRetainLastN         = collector.WithRetainLastN
+	WithSeparatorAutoDetect = collector.WithSeparatorAutoDetect
+)
+
+// Clock is the time source behind the collector's tickers, timeouts and back-off;
+// see Config.Clock. pkg/testkit provides a fake implementation for tests.
+type Clock = clock.Clock
+
+// ClockTimer and ClockTicker are the timers and tickers returned by a Clock.
+type (
+	ClockTimer  = clock.Timer
+	ClockTicker = clock.Ticker
+)
+
+// RealClock returns the Clock backed by the time package, used when Config.Clock is nil.
+func RealClock() Clock { return clock.Real() }
+
+// Metrics is a set of collector metrics; see Config.Metrics.
+type Metrics = metrics.Set
+
+// NewMetrics returns an unregistered metrics set for Config.Metrics or WithMetrics.
+func NewMetrics() *Metrics {
+	return metrics.NewSet()
+}
+
+// MetricsOption customizes RegisterMetrics.
+type MetricsOption func(*metricsOptions)
+
+type metricsOptions struct {
+	set    *metrics.Set
+	labels prometheus.Labels
+}
+
+// WithMetricsSet registers m instead of the process-wide set used by collectors
+// without Config.Metrics.
+func WithMetricsSet(m *Metrics) MetricsOption {
+	return func(o *metricsOptions) { o.set = m }
+}
+
+// WithMetricLabels adds constant labels (e.g. instance, pipeline) to every registered
+// metric. Sets registered to the same registerer must use the same label names.
+func WithMetricLabels(labels map[string]string) MetricsOption {
+	return func(o *metricsOptions) { o.labels = labels }
+}
+
 // RegisterMetrics exposes registration of built-in library metrics so callers can
 // register them alongside their own collectors (e.g., sink metrics) before starting
 // the HTTP server. It is safe to call multiple times.
-func RegisterMetrics(r prometheus.Registerer) error {
-	return metrics.Register(r)
+//
+// To run several collectors in one process, give each its own set and labels:
+//
+//	m := freader.NewMetrics()
+//	err := freader.RegisterMetrics(reg, freader.WithMetricsSet(m), freader.WithMetricLabels(map[string]string{"pipeline": "audit"}))
+//	cfg.Metrics = m
+func RegisterMetrics(r prometheus.Registerer, opts ...MetricsOption) error {
+	o := metricsOptions{set: metrics.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.set == nil {
+		o.set = metrics.Default()
+	}
+	return o.set.Register(r, o.labels)
 }
 
 // StartMetrics registers freader metrics on the default Prometheus registry and starts an HTTP server.
@@ -80,3 +475,20 @@ func StartMetrics(addr string) (func() error, error) {
 	}
 	return srv.Stop, nil
 }
+
+// MetricsServerOptions re-exports metrics.ServerOptions: TLS, basic-auth and bearer-token
+// protection for the metrics endpoint, and an optional custom Gatherer to serve.
+type MetricsServerOptions = metrics.ServerOptions
+
+// StartMetricsWithOptions is like StartMetrics but serves HTTPS and requires
+// authentication as configured in opts.
+func StartMetricsWithOptions(addr string, opts MetricsServerOptions) (func() error, error) {
+	if err := metrics.Register(prometheus.DefaultRegisterer); err != nil {
+		return nil, err
+	}
+	srv, err := metrics.StartWithOptions(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return srv.Stop, nil
+}
@@ -0,0 +1,26 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReal(t *testing.T) {
+	c := Real()
+	start := c.Now()
+	<-c.After(time.Millisecond)
+	assert.GreaterOrEqual(t, c.Since(start), time.Millisecond)
+
+	timer := c.NewTimer(time.Hour)
+	assert.True(t, timer.Stop())
+	timer.Reset(time.Millisecond)
+	<-timer.C()
+
+	ticker := c.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Reset(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+}
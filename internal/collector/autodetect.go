@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"github.com/loykin/freader/internal/file_tracker"
+)
+
+// separatorSampleSize is how much of the head of a file SeparatorAutoDetect reads.
+const separatorSampleSize = 64 << 10
+
+// separatorAnyNewline is the separator of files whose line endings SeparatorAutoDetect
+// could not settle on; it splits LF and CRLF lines alike.
+const separatorAnyNewline = `\r?\n`
+
+// detectSeparator samples the head of path for SeparatorAutoDetect. It returns "\r\n"
+// when every line ending found is CRLF, "\n" when none is, and "" when the sample holds
+// no line ending or both kinds.
+func detectSeparator(path string) (string, error) {
+	f, err := file_tracker.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	buf := make([]byte, separatorSampleSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	sample := buf[:n]
+	lf, crlf := bytes.Count(sample, []byte("\n")), bytes.Count(sample, []byte("\r\n"))
+	switch {
+	case lf == 0:
+		return "", nil
+	case crlf == lf:
+		return "\r\n", nil
+	case crlf == 0:
+		return "\n", nil
+	default:
+		return "", nil
+	}
+}
+
+// separatorFor returns how records of the file id at path are split: the literal
+// separator, or a regex taking precedence over it. With SeparatorAutoDetect, files no
+// separator rule matches get the separator detected from their head, recorded for
+// TrackedFiles.
+func (c *Collector) separatorFor(id, path string) (string, *regexp.Regexp) {
+	if re := c.separatorRegexFor(path); re != nil || !c.cfg.SeparatorAutoDetect {
+		return c.cfg.Separator, re
+	}
+	sep, err := detectSeparator(path)
+	if err != nil {
+		c.logger.Warn("failed to sample separator, splitting on either line ending", "path", path, "error", err)
+	}
+	c.separators.Store(id, sep)
+	if sep == "" {
+		c.logger.Debug("separator not determined, splitting on either line ending", "file", id, "path", path)
+		return c.cfg.Separator, c.anyNewline
+	}
+	c.logger.Debug("detected separator", "file", id, "path", path, "separator", sep)
+	return sep, nil
+}
+
+// detectedSeparator returns the separator SeparatorAutoDetect chose for id, with
+// separatorAnyNewline for files it could not decide on, or "" when it was not used.
+func (c *Collector) detectedSeparator(id string) string {
+	sep, ok := c.separators.Load(id)
+	if !ok {
+		return ""
+	}
+	if sep == "" {
+		return separatorAnyNewline
+	}
+	return sep.(string)
+}
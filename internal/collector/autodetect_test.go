@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectSeparator(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name, content, want string
+	}{
+		{"lf", "a\nb\n", "\n"},
+		{"crlf", "a\r\nb\r\n", "\r\n"},
+		{"mixed", "a\r\nb\n", ""},
+		{"no line ending", "partial", ""},
+		{"empty", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := filepath.Join(dir, tc.name)
+			require.NoError(t, os.WriteFile(p, []byte(tc.content), 0644))
+			got, err := detectSeparator(p)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := detectSeparator(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestCollector_SeparatorAutoDetect(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"linux.log":   "l1\nl2\n",
+		"windows.log": "w1\r\nw2\r\n",
+		"new.log":     "",
+		"ruled.log":   "r1|r2|",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	c, err := New(
+		WithInclude(dir),
+		WithPollInterval(20*time.Millisecond),
+		WithFromBeginning(),
+		WithSeparatorAutoDetect(),
+		WithSeparatorRule("ruled.log", "|"),
+		WithOnLine(func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, line)
+		}),
+	)
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	read := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		out := slices.Clone(lines)
+		slices.Sort(out)
+		return out
+	}
+	assert.Eventually(t, func() bool { return len(read()) == 6 }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{"l1", "l2", "r1", "r2", "w1", "w2"}, read())
+
+	// A file created empty splits on either line ending once it is written to
+	f, err := os.OpenFile(filepath.Join(dir, "new.log"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("n1\r\nn2\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Eventually(t, func() bool { return len(read()) == 8 }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{"l1", "l2", "n1", "n2", "r1", "r2", "w1", "w2"}, read())
+
+	separators := map[string]string{}
+	for _, tf := range c.TrackedFiles() {
+		separators[filepath.Base(tf.Path)] = tf.Separator
+	}
+	assert.Equal(t, map[string]string{"linux.log": "\n", "windows.log": "\r\n", "new.log": `\r?\n`, "ruled.log": ""}, separators)
+}
+
+func TestConfig_SeparatorAutoDetectValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"regex", Config{SeparatorAutoDetect: true, SeparatorRegex: `\r?\n`}, "mutually exclusive"},
+		{"token separator", Config{SeparatorAutoDetect: true, Separator: "<END>"}, "chooses between"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorContains(t, tc.cfg.validateSeparatorAutoDetect(), tc.want)
+		})
+	}
+	assert.NoError(t, (&Config{SeparatorAutoDetect: true, Separator: "\r\n"}).validateSeparatorAutoDetect())
+}
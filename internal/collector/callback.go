@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCallbackStalled is reported through Config.OnErrorFunc, wrapped with how long it
+// has been running, when a record callback exceeds Config.CallbackTimeout.
+var ErrCallbackStalled = errors.New("callback stalled")
+
+// callbackGate serializes the record callbacks across workers, separately from c.mu so
+// that a slow callback holds up delivery but not scans, stats or per-file state. It
+// tracks the running callback for the stall watchdog and, when skipping, lets workers
+// give up on a callback that has stalled instead of queueing behind it.
+type callbackGate struct {
+	sem chan struct{} // holds a token while a callback runs
+
+	mu      sync.Mutex
+	path    string        // file of the records being delivered
+	started time.Time     // zero while no callback runs
+	stalled bool          // the running callback was reported as stalled
+	stallCh chan struct{} // closed once the running callback is reported as stalled
+}
+
+func newCallbackGate() *callbackGate {
+	return &callbackGate{sem: make(chan struct{}, 1), stallCh: make(chan struct{})}
+}
+
+// enter waits for the callback in progress, if any, to return and marks a callback for
+// the records of path as started at now(). With skip it gives up and returns false as
+// soon as the callback in progress is reported as stalled.
+func (g *callbackGate) enter(path string, skip bool, now func() time.Time) bool {
+	if skip {
+		g.mu.Lock()
+		stallCh := g.stallCh
+		g.mu.Unlock()
+		select {
+		case g.sem <- struct{}{}:
+		case <-stallCh:
+			return false
+		}
+	} else {
+		g.sem <- struct{}{}
+	}
+	g.mu.Lock()
+	g.path, g.started = path, now()
+	g.mu.Unlock()
+	return true
+}
+
+// exit marks the callback as returned. It reports how long it ran when it had been
+// reported as stalled.
+func (g *callbackGate) exit(now func() time.Time) (stalledFor time.Duration, wasStalled bool) {
+	g.mu.Lock()
+	if g.stalled {
+		stalledFor, wasStalled = now().Sub(g.started), true
+		g.stalled = false
+		g.stallCh = make(chan struct{})
+	}
+	g.path, g.started = "", time.Time{}
+	g.mu.Unlock()
+	<-g.sem
+	return stalledFor, wasStalled
+}
+
+// checkStall reports the running callback as stalled, once, when it has been running
+// for at least timeout. It returns the callback's file and running time when it did.
+func (g *callbackGate) checkStall(now time.Time, timeout time.Duration) (path string, running time.Duration, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.started.IsZero() || g.stalled {
+		return "", 0, false
+	}
+	running = now.Sub(g.started)
+	if running < timeout {
+		return "", 0, false
+	}
+	g.stalled = true
+	close(g.stallCh)
+	return g.path, running, true
+}
+
+// deliverCallback runs fn, a record callback for n records of path, through the gate.
+// Records are counted as skipped and fn is not run when SkipStalledCallbacks gives up
+// on a stalled callback.
+func (c *Collector) deliverCallback(path string, n int, fn func()) {
+	skip := c.cfg.CallbackTimeout > 0 && c.cfg.SkipStalledCallbacks
+	if !c.callbacks.enter(path, skip, c.clock.Now) {
+		c.metrics.AddCallbackSkips(n)
+		return
+	}
+	defer func() {
+		if d, ok := c.callbacks.exit(c.clock.Now); ok {
+			c.logger.Warn("stalled callback returned", "file", path, "duration", d)
+		}
+	}()
+	fn()
+}
+
+// watchCallbacks reports callbacks running longer than CallbackTimeout until Stop. It
+// checks four times per timeout, so a stall is reported within 1.25 timeouts.
+func (c *Collector) watchCallbacks(timeout time.Duration) {
+	defer c.workerWg.Done()
+	ticker := c.clock.NewTicker(max(timeout/4, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C():
+			path, running, ok := c.callbacks.checkStall(c.clock.Now(), timeout)
+			if !ok {
+				continue
+			}
+			c.metrics.IncCallbackStalls()
+			c.logger.Warn("record callback stalled; delivery from all workers is waiting for it",
+				"file", path, "running", running, "skipping", c.cfg.SkipStalledCallbacks)
+			c.reportError(fmt.Errorf("%w for %s", ErrCallbackStalled, running.Round(time.Millisecond)),
+				ErrorContext{Kind: ErrorKindCallback, Path: path})
+		}
+	}
+}
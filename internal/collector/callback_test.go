@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackGate(t *testing.T) {
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+	g := newCallbackGate()
+
+	_, _, ok := g.checkStall(now, time.Second)
+	assert.False(t, ok, "nothing running")
+
+	require.True(t, g.enter("a.log", true, clock))
+	now = start.Add(500 * time.Millisecond)
+	_, _, ok = g.checkStall(now, time.Second)
+	assert.False(t, ok)
+
+	// A second caller waits while the callback runs and, when skipping, gives up once
+	// it is reported as stalled
+	entered := make(chan bool)
+	go func() { entered <- g.enter("b.log", true, clock) }()
+	now = start.Add(2 * time.Second)
+	path, running, ok := g.checkStall(now, time.Second)
+	require.True(t, ok)
+	assert.Equal(t, "a.log", path)
+	assert.Equal(t, 2*time.Second, running)
+	assert.False(t, <-entered)
+
+	// Reported once per stall; skipping callers do not wait while it lasts
+	_, _, ok = g.checkStall(now.Add(time.Second), time.Second)
+	assert.False(t, ok)
+	assert.False(t, g.enter("c.log", true, clock))
+
+	now = start.Add(3 * time.Second)
+	d, stalled := g.exit(clock)
+	assert.True(t, stalled)
+	assert.Equal(t, 3*time.Second, d)
+
+	// Delivery resumes after the stalled callback returns
+	require.True(t, g.enter("d.log", true, clock))
+	_, stalled = g.exit(clock)
+	assert.False(t, stalled)
+}
+
+func TestCollector_CallbackTimeout(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte("block\nafter\n"), 0644))
+
+	var (
+		mu      sync.Mutex
+		lines   []string
+		errs    []error
+		kinds   []ErrorKind
+		release = make(chan struct{})
+		blocked = make(chan struct{})
+		stalled = make(chan struct{}, 1)
+	)
+	c, err := New(WithInclude(dir), WithPollInterval(20*time.Millisecond), WithWorkers(2),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithCallbackTimeout(50*time.Millisecond, true),
+		WithOnLine(func(line string) {
+			if line == "block" {
+				close(blocked)
+				<-release
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, line)
+		}),
+		WithOnError(func(err error, ctx ErrorContext) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+			kinds = append(kinds, ctx.Kind)
+			select {
+			case stalled <- struct{}{}:
+			default:
+			}
+		}))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	<-blocked
+	select {
+	case <-stalled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stalled callback was not reported")
+	}
+	mu.Lock()
+	require.NotEmpty(t, errs)
+	assert.True(t, errors.Is(errs[0], ErrCallbackStalled))
+	assert.Equal(t, ErrorKindCallback, kinds[0])
+	mu.Unlock()
+
+	// Records of other files are skipped, and committed, rather than queued behind it
+	b := filepath.Join(dir, "b.log")
+	require.NoError(t, os.WriteFile(b, []byte("skipped\n"), 0644))
+	assert.Eventually(t, func() bool {
+		for _, f := range c.TrackedFiles() {
+			if f.Path == b && f.Offset == int64(len("skipped\n")) {
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	close(release)
+
+	// Delivery recovers once the callback returns
+	f, err := os.OpenFile(b, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("delivered\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(lines) == 3
+	}, 2*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"block", "after", "delivered"}, lines)
+	mu.Unlock()
+}
+
+func TestConfig_CallbackTimeoutValidation(t *testing.T) {
+	_, err := New(WithInclude(t.TempDir()), WithCallbackTimeout(-time.Second, false))
+	assert.Error(t, err)
+
+	cfg := Config{SkipStalledCallbacks: true}
+	cfg.Default()
+	cfg.Include = []string{t.TempDir()}
+	assert.ErrorContains(t, cfg.Validate(), "requires a callback timeout")
+}
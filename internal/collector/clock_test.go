@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_FakeClock(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte("one\n"), 0644))
+
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	clk := testkit.NewFakeClock(start)
+	var (
+		mu     sync.Mutex
+		events []LineEvent
+	)
+	c, err := New(WithInclude(dir), WithPollInterval(time.Hour), WithClock(clk),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithOnEvent(func(e LineEvent) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		}))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	// The poll ticker and the idle worker's back-off wait on the fake clock
+	require.True(t, clk.BlockUntil(2, 3*time.Second))
+	assert.Eventually(t, func() bool {
+		at, _ := c.LastScan()
+		return at.Equal(start)
+	}, 3*time.Second, 5*time.Millisecond, "initial scan")
+
+	// Nothing is read until the back-off wait elapses on the fake clock
+	assert.Eventually(t, func() bool {
+		clk.Advance(2 * time.Second)
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 1
+	}, 3*time.Second, 5*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, "one", events[0].Line)
+	assert.False(t, events[0].Ts.Before(start))
+	assert.True(t, events[0].Ts.Before(start.Add(time.Minute)), "record time from the fake clock")
+	mu.Unlock()
+
+	// A file created later is only discovered by the next poll
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.log"), []byte("two\n"), 0644))
+	clk.Advance(time.Hour)
+	assert.Eventually(t, func() bool {
+		at, _ := c.LastScan()
+		return !at.Before(start.Add(time.Hour)) && c.Stats().TrackedFiles == 2
+	}, 3*time.Second, 5*time.Millisecond)
+}
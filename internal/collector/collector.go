@@ -1,11 +1,18 @@
 package collector
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/loykin/freader/internal/clock"
 	"github.com/loykin/freader/internal/file_tracker"
 	"github.com/loykin/freader/internal/metrics"
 	"github.com/loykin/freader/internal/store"
@@ -15,17 +22,62 @@ import (
 	"github.com/cenkalti/backoff/v4"
 )
 
+// ErrFileNotTracked is returned by per-file operations for paths the collector is not tracking.
+var ErrFileNotTracked = errors.New("file not tracked")
+
 type Collector struct {
-	cfg         Config
-	fileManager *file_tracker.FileTracker
-	watcher     *watcher.Watcher
-	offsetDB    store.Store
-	scheduler   *TailScheduler
-	mu          sync.Mutex
-	onLineFunc  func(line string)
-	onEventFunc func(event LineEvent)
-	stopCh      chan struct{}
-	workerWg    sync.WaitGroup
+	cfg          Config
+	fileManager  *file_tracker.FileTracker
+	watcher      *watcher.Watcher
+	offsetDB     store.Store
+	instanceID   string // lease holder name in the offset store; see Config.InstanceID
+	leaser       store.Leaser
+	shard        *sharder         // files owned by this collector; nil when not sharded
+	membership   store.Membership // shard members with cfg.ShardDiscovery; nil otherwise
+	standby      atomic.Bool      // standing by in standby mode; see Config.Standby
+	metrics      *metrics.Set
+	clock        clock.Clock
+	scheduler    *TailScheduler
+	separatorRe  *regexp.Regexp   // compiled cfg.SeparatorRegex; nil splits on cfg.Separator
+	anyNewline   *regexp.Regexp   // splits files cfg.SeparatorAutoDetect could not decide on
+	ruleRes      []*regexp.Regexp // compiled cfg.SeparatorRules, by index
+	mu           sync.Mutex
+	callbacks    *callbackGate // serializes the record callbacks; see Config.CallbackTimeout
+	onLineFunc   func(line string)
+	onEventFunc  func(event LineEvent)
+	onErrorFunc  func(err error, ctx ErrorContext)
+	logger       *slog.Logger
+	stopCh       chan struct{}
+	startOnce    sync.Once
+	stopOnce     sync.Once
+	workerWg     sync.WaitGroup
+	records      chan Record              // created by Records; guarded by mu
+	workersDone  bool                     // set by Stop once no worker can send on records; guarded by mu
+	beforeStart  map[string]bool          // files still skipping records older than cfg.StartFromTime; guarded by mu
+	catchUp      map[string]bool          // files not read yet, caught up in chunks with cfg.CatchUpChunkSize; guarded by mu
+	startup      map[string]bool          // files found by the first scan still on their first pass; guarded by mu
+	startupNext  time.Time                // when the next startup file may be read with cfg.StartupStagger; guarded by mu
+	followed     map[string]string        // path each file was found at with cfg.FollowName; guarded by mu
+	upgrades     map[string]string        // checksum ID of each provisional ID being dropped; guarded by mu
+	startupLimit *rateLimiter             // shared by first-pass reads with cfg.StartupReadRateLimit; nil otherwise
+	failures     map[string]int           // consecutive fingerprint failures per file in NetworkFS mode; guarded by mu
+	unreadable   *watcher.UnreadableFiles // permission-denied files retried with back-off; shared with the watcher
+	decisions    *watcher.DecisionLog     // recent files added, removed or skipped; shared with the watcher
+	trace        *watcher.DecisionLog     // per-scan evaluation of every file with cfg.TraceScans; nil otherwise
+	recent       *recentRecords           // last cfg.RetainLastN records delivered; nil otherwise
+	iterErrs     chan error               // errors surfaced by Iter while iterating is set
+	wake         chan struct{}            // wakes an idle worker; see wakeWorker
+	repeats      map[string]*repeatRun    // runs of repeated records per file with cfg.RepeatWindow; guarded by mu
+	merge        *merger                  // orders records by event time with cfg.MergeWindow; nil otherwise
+	positions    sync.Map                 // file id -> *atomic.Int64 read position, advanced during a read
+	lastRead     sync.Map                 // file id -> time.Time a read last found new data
+	separators   sync.Map                 // file id -> separator chosen by cfg.SeparatorAutoDetect; "" if undecided
+	deleted      sync.Map                 // file id -> *deletedFile read on with cfg.HoldDeleted after it was deleted
+	quarantined  sync.Map                 // file id -> QuarantinedFile left unread; see OffsetMismatchQuarantine
+	iterating    atomic.Bool
+	started      atomic.Bool
+	linesRead    atomic.Int64
+	bytesRead    atomic.Int64
 }
 
 func (c *Collector) worker() {
@@ -36,6 +88,7 @@ func (c *Collector) worker() {
 	bo.InitialInterval = 100 * time.Millisecond
 	bo.MaxInterval = 2 * time.Second
 	bo.MaxElapsedTime = 0
+	bo.Clock = c.clock
 
 	loopLimit := c.scheduler.GetCount()
 
@@ -48,7 +101,11 @@ func (c *Collector) worker() {
 				select {
 				case <-c.stopCh:
 					return
-				case <-time.After(bo.NextBackOff()):
+				case <-c.wake:
+					// A file was added or a multiline timeout completed a record
+					loopLimit = c.scheduler.GetCount()
+					loopCount = 0
+				case <-c.clock.After(bo.NextBackOff()):
 					loopLimit = c.scheduler.GetCount()
 					loopCount = 0
 				}
@@ -59,95 +116,619 @@ func (c *Collector) worker() {
 			if !ok {
 				continue
 			}
-
-			err := fileTail.ReadOnce(func(line string) {
-				c.mu.Lock()
-				defer c.mu.Unlock()
-				if c.onEventFunc != nil {
-					file := ""
-					if fileInfo := c.fileManager.Get(fileTail.FileId); fileInfo != nil {
-						file = fileInfo.Path
-					}
-					c.onEventFunc(LineEvent{
-						Line: line,
-						File: file,
-						Ts:   time.Now().UTC(),
-					})
-				} else if c.onLineFunc != nil {
-					c.onLineFunc(line)
-				}
-				// Metrics: count processed line and bytes emitted (approximate)
-				metrics.IncLines(1)
-				metrics.AddBytes(len(line))
+			if n, _ := c.readTail(fileTail, c.stopCh); n > 0 {
 				bo.Reset()
-			})
-			if os.IsNotExist(err) {
-				slog.Debug("file not found", "file", fileTail.FileId, "error", err)
-			} else if err != nil {
-				// Check if this is a file size or separator issue (expected conditions to skip)
-				if file_tracker.IsFileSizeTooSmall(err) || file_tracker.IsNotEnoughSeparators(err) {
-					slog.Debug("file not ready for reading", "file", fileTail.FileId, "error", err)
-					// Remove from scheduler as file doesn't meet fingerprinting requirements
-					c.scheduler.Remove(fileTail.FileId)
-					c.fileManager.Remove(fileTail.FileId)
-				} else if tailer.IsFileFingerprintMismatch(err) {
-					// File content changed (rotation, truncation, overwrite) - this is normal
-					slog.Debug("file content changed, removing stale entry", "file", fileTail.FileId, "error", err)
-					c.scheduler.Remove(fileTail.FileId)
-					c.fileManager.Remove(fileTail.FileId)
-					// Watcher will re-add the file with new fingerprint on next scan
+			}
+		}
+	}
+}
+
+// readTail reads fileTail from its offset to the current end of the file, delivers the
+// records and commits the new offset. Records not yet handed to the Records channel when
+// stop is closed are re-read next time. It returns the number of records delivered and
+// the read or store error that was reported, if any; expected conditions such as a
+// rotated or too small file are handled here and not returned.
+func (c *Collector) readTail(fileTail *tailer.TailReader, stop <-chan struct{}) (int, error) {
+	path := c.pathOf(fileTail.FileId)
+	start := fileTail.Offset
+	var ackErr error
+	defer func() {
+		c.scheduler.Observe(fileTail.FileId, fileTail.Offset != start)
+		if fileTail.Offset != start {
+			c.lastRead.Store(fileTail.FileId, c.clock.Now())
+		}
+		if ackErr == nil && c.scheduler.Draining(fileTail.FileId) {
+			// Rotated away and read to its end: deliver what it still holds before the
+			// file that replaced it is read
+			if err := c.flushFile(fileTail, path); err != nil {
+				c.redeliver(fileTail, path, err)
+			} else {
+				_ = c.commitOffset(fileTail)
+			}
+		}
+		if !c.scheduler.SetIdle(fileTail.FileId) {
+			// Removed while being read: deliver the record it was still assembling
+			c.flushFile(fileTail, path)
+			c.upgraded(fileTail)
+			c.scheduler.Release(fileTail.FileId)
+			fileTail.ReleaseFile()
+		}
+	}()
+
+	if !c.unreadable.Due(path) {
+		// Permission denied earlier; wait for the back-off to expire
+		return 0, nil
+	}
+
+	// Per-read state, so the hot path does not take c.mu for every line
+	c.mu.Lock()
+	skipOld := c.beforeStart[fileTail.FileId]
+	catchUp := c.catchUp[fileTail.FileId]
+	delete(c.catchUp, fileTail.FileId)
+	records := c.records
+	c.mu.Unlock()
+	batch := c.newLineBatch()
+	fileTail.Throttle = c.startupThrottle(fileTail.FileId, stop)
+
+	pos := c.position(fileTail.FileId)
+	resumeAt := int64(-1)
+	lines := 0
+	// deliver hands rec, whose line is b, to the Records channel and the callbacks. It
+	// reports false if stop was closed first.
+	deliver := func(rec Record, b []byte) bool {
+		if c.merge != nil {
+			c.merge.add(fileTail.FileId, fileTail.Offset, rec, b, c.cfg.TimestampFunc, c.clock.Now())
+			lines++
+			return true
+		}
+		if records != nil {
+			select {
+			case records <- rec:
+			case <-stop:
+				return false
+			}
+		}
+		c.emit(rec, b, batch)
+		lines++
+		return true
+	}
+	reps := c.repeatRunOf(fileTail.FileId)
+	if summary := reps.expire(c.clock.Now(), c.cfg.RepeatWindow); summary != nil {
+		deliver(*summary, nil)
+	}
+	read := func(b []byte) {
+		pos.Store(fileTail.Offset)
+		if skipOld {
+			if ts, ok := c.cfg.TimestampFunc(string(b)); !ok || ts.Before(c.cfg.StartFromTime) {
+				return
+			}
+			skipOld = false
+			c.mu.Lock()
+			delete(c.beforeStart, fileTail.FileId)
+			c.mu.Unlock()
+		}
+		if resumeAt >= 0 {
+			return
+		}
+		rec := Record{Line: string(b), File: path, Ts: c.clock.Now().UTC()}
+		summary, dup := reps.observe(rec, c.clock.Now(), c.cfg.RepeatWindow)
+		if summary != nil && !deliver(*summary, nil) {
+			resumeAt = fileTail.Offset
+			return
+		}
+		if !dup && !deliver(rec, b) {
+			// Undelivered on shutdown: re-read from this record next time
+			resumeAt = fileTail.Offset
+		}
+	}
+	var err error
+	if catchUp {
+		readers := c.cfg.CatchUpReaders
+		if readers <= 0 {
+			readers = DefaultCatchUpReaders
+		}
+		err = fileTail.ReadChunked(c.cfg.CatchUpChunkSize, readers, stop, read)
+	}
+	if errors.Is(err, tailer.ErrStopped) {
+		// Stopped mid catch-up: the rest is read on the next start
+		err = nil
+	} else if err == nil && resumeAt < 0 {
+		err = fileTail.ReadOnceBytes(read)
+	}
+	// Deliver before the offset below is committed
+	batch.flush()
+	if ackErr = batch.acked(); ackErr != nil {
+		c.redeliver(fileTail, path, ackErr)
+		return 0, ackErr
+	}
+	if resumeAt >= 0 {
+		fileTail.Offset = resumeAt
+	}
+	pos.Store(fileTail.Offset)
+	if fileTail.Multiline != nil {
+		c.metrics.SetMultilineDepth(path, fileTail.Multiline.Depth())
+	}
+	var readErr error
+	if c.releaseDeleted(fileTail, fileTail.Offset != start, err) {
+		c.logger.Debug("deleted file released", "file", fileTail.FileId, "path", path)
+	} else if os.IsNotExist(err) {
+		c.logger.Debug("file not found", "file", fileTail.FileId, "error", err)
+	} else if c.retryOnNetworkFS(fileTail.FileId, err) {
+		c.logger.Debug("fingerprint check failed on network filesystem, retrying", "file", fileTail.FileId, "error", err)
+	} else if err != nil {
+		// Check if this is a file size or separator issue (expected conditions to skip)
+		if file_tracker.IsFileSizeTooSmall(err) || file_tracker.IsNotEnoughSeparators(err) {
+			c.logger.Debug("file not ready for reading", "file", fileTail.FileId, "error", err)
+			// Remove from scheduler as file doesn't meet fingerprinting requirements
+			c.flushFile(fileTail, path)
+			c.scheduler.Remove(fileTail.FileId)
+			c.fileManager.Remove(fileTail.FileId)
+			c.decisions.Record(watcher.Decision{Action: watcher.DecisionRemoved, Path: path, FileID: fileTail.FileId, Reason: err.Error()})
+			c.fileRemoved(fileTail.FileId, path)
+		} else if tailer.IsFileFingerprintMismatch(err) {
+			// File content changed (rotation, truncation, overwrite) - this is normal
+			c.logger.Debug("file content changed, removing stale entry", "file", fileTail.FileId, "error", err)
+			c.reportError(err, ErrorContext{Kind: ErrorKindFingerprintMismatch, FileID: fileTail.FileId, Path: path})
+			if c.cfg.OnFingerprintMismatch != nil {
+				c.cfg.OnFingerprintMismatch(path)
+			}
+			c.flushFile(fileTail, path)
+			if c.cfg.FollowName {
+				// Another file is at the followed path; a scan may still find this one
+				// under its rotated name and must not track it again
+				c.watcher.Retire(fileTail.FileId)
+			}
+			c.scheduler.Remove(fileTail.FileId)
+			c.fileManager.Remove(fileTail.FileId)
+			c.decisions.Record(watcher.Decision{Action: watcher.DecisionRemoved, Path: path, FileID: fileTail.FileId, Reason: err.Error()})
+			c.fileRemoved(fileTail.FileId, path)
+			// Watcher will re-add the file with new fingerprint on next scan
+		} else if file_tracker.IsLocked(err) {
+			c.metrics.IncLocked()
+			if c.cfg.SkipLocked {
+				c.logger.Debug("file locked by another process, skipping", "file", fileTail.FileId, "path", path, "error", err)
+			} else {
+				c.metrics.IncReadErrors()
+				if c.unreadable.Fail(path, err) {
+					c.logger.Warn("file locked by another process, retrying with back-off", "file", fileTail.FileId, "path", path, "error", err)
 				} else {
-					metrics.IncReadErrors()
-					slog.Error("failed to read file", "file", fileTail.FileId, "error", err)
+					c.logger.Debug("file still locked", "file", fileTail.FileId, "path", path, "error", err)
 				}
+				c.reportError(err, ErrorContext{Kind: ErrorKindRead, FileID: fileTail.FileId, Path: path})
+				readErr = err
+			}
+		} else if errors.Is(err, fs.ErrPermission) {
+			c.metrics.IncReadErrors()
+			if c.unreadable.Fail(path, err) {
+				c.logger.Warn("permission denied, retrying with back-off", "file", fileTail.FileId, "path", path, "error", err)
 			} else {
-				// Update the offset in the FileTracker
-				c.fileManager.UpdateOffset(fileTail.FileId, fileTail.Offset)
-
-				// Save the current offset to the store if enabled
-				if c.offsetDB != nil && c.cfg.StoreOffsets {
-					fileInfo := c.fileManager.Get(fileTail.FileId)
-					if fileInfo != nil {
-						if err := c.offsetDB.Save(fileTail.FileId, c.cfg.FingerprintStrategy, fileInfo.Path, fileTail.Offset); err != nil {
-							slog.Error("failed to save offset", "file", fileTail.FileId, "offset", fileTail.Offset, "error", err)
-						} else {
-							slog.Debug("saved offset", "file", fileTail.FileId, "path", fileInfo.Path, "offset", fileTail.Offset)
-						}
-					}
-				}
+				c.logger.Debug("permission still denied", "file", fileTail.FileId, "path", path, "error", err)
+			}
+			c.reportError(err, ErrorContext{Kind: ErrorKindRead, FileID: fileTail.FileId, Path: path})
+			readErr = err
+		} else {
+			c.metrics.IncReadErrors()
+			c.logger.Error("failed to read file", "file", fileTail.FileId, "error", err)
+			c.reportError(err, ErrorContext{Kind: ErrorKindRead, FileID: fileTail.FileId, Path: c.pathOf(fileTail.FileId)})
+			readErr = err
+		}
+	} else {
+		if c.cfg.NetworkFS {
+			c.mu.Lock()
+			delete(c.failures, fileTail.FileId)
+			c.mu.Unlock()
+		}
+		if resumeAt < 0 {
+			// Read to the end: the first pass is over
+			c.mu.Lock()
+			delete(c.startup, fileTail.FileId)
+			c.mu.Unlock()
+		}
+		if c.unreadable.Succeed(path) {
+			c.logger.Info("file is readable again", "file", fileTail.FileId, "path", path)
+		}
+		if err := c.commitOffset(fileTail); err != nil {
+			readErr = err
+		}
+		if c.cfg.FollowName && resumeAt < 0 && c.rotatedAway(fileTail.FileId) {
+			c.retire(fileTail, path)
+		}
+	}
+	return lines, readErr
+}
+
+// emit hands rec, whose line is b, to the OnLinesFunc batch or the line callbacks and
+// counts it. b may be nil for repeat summaries.
+func (c *Collector) emit(rec Record, b []byte, batch *lineBatch) {
+	line := rec.Line
+	if rec.Repeats > 0 {
+		// Line callbacks have no Repeats, so they get the count in the line
+		line = RepeatSummary(rec.Line, rec.Repeats)
+		b = []byte(line)
+	}
+	if batch != nil {
+		batch.add(rec)
+	} else if c.cfg.OnLineBytesFunc != nil || c.onEventFunc != nil || c.onLineFunc != nil {
+		c.deliverCallback(rec.File, 1, func() {
+			if c.cfg.OnLineBytesFunc != nil {
+				c.cfg.OnLineBytesFunc(b)
+			} else if c.onEventFunc != nil {
+				c.onEventFunc(rec)
+			} else {
+				c.onLineFunc(line)
+			}
+		})
+	}
+	c.recent.add(rec)
+	// Metrics: count processed line and bytes emitted (approximate)
+	c.metrics.IncLines(1)
+	c.metrics.AddBytes(len(b))
+	c.linesRead.Add(1)
+	c.bytesRead.Add(int64(len(b)))
+}
+
+// redeliver handles records of fileTail that OnLinesAckFunc failed to deliver: it
+// rewinds fileTail to the offset last committed, so the records past it are read and
+// delivered again after DeliveryRetryDelay, and reports err.
+func (c *Collector) redeliver(fileTail *tailer.TailReader, path string, err error) {
+	offset := fileTail.SafeOffset()
+	if info := c.fileManager.Get(fileTail.FileId); info != nil && info.Offset < offset {
+		offset = info.Offset
+	}
+	fileTail.Rewind(offset)
+	c.position(fileTail.FileId).Store(offset)
+	c.mu.Lock()
+	// The records of the run are read again, the first of them included
+	delete(c.repeats, fileTail.FileId)
+	c.mu.Unlock()
+	c.scheduler.Delay(fileTail.FileId, c.clock.Now().Add(DeliveryRetryDelay))
+	c.logger.Warn("records not delivered; reading them again", "file", fileTail.FileId, "path", path, "offset", offset, "error", err)
+	c.reportError(err, ErrorContext{Kind: ErrorKindDelivery, FileID: fileTail.FileId, Path: path})
+}
+
+// commitOffset records the offset up to which fileTail's records have been delivered
+// in the FileTracker and, if enabled, the offset store.
+func (c *Collector) commitOffset(fileTail *tailer.TailReader) error {
+	offset := fileTail.SafeOffset()
+	if held, ok := c.merge.heldFrom(fileTail.FileId); ok && held < offset {
+		// Re-read the records the merge still holds after a crash
+		offset = held
+	}
+	c.fileManager.UpdateOffset(fileTail.FileId, offset)
+
+	if !c.storesOffsets() {
+		return nil
+	}
+	fileInfo := c.fileManager.Get(fileTail.FileId)
+	if fileInfo == nil {
+		return nil
+	}
+	if err := c.offsetDB.Save(fileTail.FileId, c.cfg.FingerprintStrategy, fileInfo.Path, offset); err != nil {
+		c.logger.Error("failed to save offset", "file", fileTail.FileId, "offset", offset, "error", err)
+		c.reportError(err, ErrorContext{Kind: ErrorKindStore, FileID: fileTail.FileId, Path: fileInfo.Path, Op: "save"})
+		return err
+	}
+	c.logger.Debug("saved offset", "file", fileTail.FileId, "path", fileInfo.Path, "offset", offset)
+	return nil
+}
+
+// multilineFor returns the multiline settings for path: the first matching multiline
+// rule, else cfg.Multiline. nil means no grouping.
+func (c *Collector) multilineFor(path string) *tailer.MultilineReader {
+	for _, rule := range c.cfg.MultilineRules {
+		if rule.Pattern == "" || watcher.MatchesAny(path, []string{rule.Pattern}) {
+			return rule.Multiline
+		}
+	}
+	return c.cfg.Multiline
+}
+
+// ownMultiline returns copies of the multiline settings of a Config, so the collector
+// never writes to, or assembles records in, a MultilineReader the caller may share with
+// other collectors. newMultiline clones them again for each file.
+func ownMultiline(ml *tailer.MultilineReader, rules []MultilineRule) (*tailer.MultilineReader, []MultilineRule) {
+	if ml != nil {
+		ml = ml.Clone()
+	}
+	if rules != nil {
+		rules = append([]MultilineRule(nil), rules...)
+		for i := range rules {
+			if rules[i].Multiline != nil {
+				rules[i].Multiline = rules[i].Multiline.Clone()
+			}
+		}
+	}
+	return ml, rules
+}
+
+// newMultiline returns a per-file copy of the multiline settings for path that wakes a
+// worker to read file id when its timeout completes a record, or nil without multiline
+// grouping.
+func (c *Collector) newMultiline(id, path string) *tailer.MultilineReader {
+	ml := c.multilineFor(path)
+	if ml == nil {
+		return nil
+	}
+	m := ml.Clone()
+	if m.Clock == nil {
+		m.Clock = c.clock
+	}
+	m.OnTimeout = func() {
+		c.scheduler.Wake(id)
+		c.wakeWorker()
+	}
+	return m
+}
+
+// wakeWorker cuts the idle back-off of one worker short, e.g. to read a file that was
+// just added or to deliver a record a multiline timeout completed.
+func (c *Collector) wakeWorker() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flushFile delivers what is held back for fileTail once no more lines will be read
+// from it: the multiline record it is still assembling, marked Forced, and the summary
+// of its run of repeated records. It also stops the multiline timeout. Records go to
+// the Records channel too unless the collector is stopping. It returns the error of
+// OnLinesAckFunc, if it did not confirm the records.
+func (c *Collector) flushFile(fileTail *tailer.TailReader, path string) error {
+	c.mu.Lock()
+	records := c.records
+	reps := c.repeats[fileTail.FileId]
+	delete(c.repeats, fileTail.FileId)
+	c.mu.Unlock()
+	batch := c.newLineBatch()
+	offset := fileTail.SafeOffset()
+	deliver := func(rec Record, b []byte) {
+		if c.merge != nil {
+			c.merge.add(fileTail.FileId, offset, rec, b, c.cfg.TimestampFunc, c.clock.Now())
+			return
+		}
+		if records != nil {
+			select {
+			case records <- rec:
+			case <-c.stopCh:
 			}
+		}
+		c.emit(rec, b, batch)
+	}
+
+	if fileTail.Multiline != nil {
+		n := fileTail.FlushMultiline(func(b []byte, forced bool) {
+			rec := Record{Line: string(b), File: path, Ts: c.clock.Now().UTC(), Forced: forced}
+			summary, dup := reps.observe(rec, c.clock.Now(), c.cfg.RepeatWindow)
+			if summary != nil {
+				deliver(*summary, nil)
+			}
+			if !dup {
+				deliver(rec, b)
+			}
+		})
+		fileTail.Multiline.Close()
+		c.metrics.DeleteMultilineDepth(path)
+		if n > 0 {
+			c.logger.Debug("flushed pending multiline records", "file", fileTail.FileId, "path", path, "records", n)
+		}
+	}
+	if reps != nil {
+		if summary := reps.end(c.clock.Now()); summary != nil {
+			deliver(*summary, nil)
+		}
+	}
+	batch.flush()
+	return batch.acked()
+}
+
+// reportError forwards err to the configured OnErrorFunc, if any.
+func (c *Collector) reportError(err error, ctx ErrorContext) {
+	if c.onErrorFunc != nil {
+		c.onErrorFunc(err, ctx)
+	}
+	if c.iterating.Load() {
+		select {
+		case c.iterErrs <- err:
+		default:
+			// Iterator is not keeping up; the error is still logged
+		}
+	}
+}
+
+// retryOnNetworkFS reports whether err, a failure to match the file's fingerprint, should
+// be retried on a later pass rather than dropping the file. In NetworkFS mode a file gets
+// cfg.NetworkFSRetries consecutive attempts, since a stale attribute cache can briefly
+// make it look shorter or different than it is.
+func (c *Collector) retryOnNetworkFS(id string, err error) bool {
+	if !c.cfg.NetworkFS || err == nil {
+		return false
+	}
+	if !file_tracker.IsFileSizeTooSmall(err) && !file_tracker.IsNotEnoughSeparators(err) && !tailer.IsFileFingerprintMismatch(err) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failures[id]++
+	if c.failures[id] < c.cfg.NetworkFSRetries {
+		return true
+	}
+	delete(c.failures, id)
+	return false
+}
+
+// position returns the read position of id reported by Stats, creating it on first use.
+func (c *Collector) position(id string) *atomic.Int64 {
+	if p, ok := c.positions.Load(id); ok {
+		return p.(*atomic.Int64)
+	}
+	p, _ := c.positions.LoadOrStore(id, new(atomic.Int64))
+	return p.(*atomic.Int64)
+}
+
+// fileRemoved forgets the read position of id and runs the OnFileRemoved hook, if any.
+func (c *Collector) fileRemoved(id, path string) {
+	c.positions.Delete(id)
+	c.lastRead.Delete(id)
+	c.merge.forget(id)
+	if c.cfg.OnFileRemoved != nil {
+		c.cfg.OnFileRemoved(id, path)
+	}
+}
 
-			c.scheduler.SetIdle(fileTail.FileId)
+// replayFromBeginning reports whether the stored offset for path must be ignored.
+func (c *Collector) replayFromBeginning(path string) bool {
+	if len(c.cfg.FromBeginningPatterns) > 0 {
+		return watcher.MatchesAny(path, c.cfg.FromBeginningPatterns)
+	}
+	return c.cfg.FromBeginning
+}
+
+// separatorRegexFor returns the regex splitting records of path: the first matching
+// separator rule, else the SeparatorRegex, else nil for the literal Separator.
+func (c *Collector) separatorRegexFor(path string) *regexp.Regexp {
+	for i, rule := range c.cfg.SeparatorRules {
+		if rule.Pattern == "" || watcher.MatchesAny(path, []string{rule.Pattern}) {
+			return c.ruleRes[i]
 		}
 	}
+	return c.separatorRe
+}
+
+// weightFor returns the scheduling weight of path: that of the first matching priority
+// rule, else 1.
+func (c *Collector) weightFor(path string) int {
+	for _, rule := range c.cfg.PriorityRules {
+		if rule.Pattern == "" || watcher.MatchesAny(path, []string{rule.Pattern}) {
+			return rule.Weight
+		}
+	}
+	return 1
+}
+
+// pathOf returns the tracked path for id, the path a file read on with
+// cfg.HoldDeleted was deleted from, or "" when the file is no longer tracked.
+func (c *Collector) pathOf(id string) string {
+	if fileInfo := c.fileManager.Get(id); fileInfo != nil {
+		return fileInfo.Path
+	}
+	if d, ok := c.deleted.Load(id); ok {
+		return d.(*deletedFile).path
+	}
+	return ""
 }
 
 func NewCollector(cfg Config) (*Collector, error) {
+	cfg.applyNetworkFS()
+	if err := cfg.validateStartFromTime(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateMergeWindow(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateSharding(); err != nil {
+		return nil, err
+	}
+	var separatorRe *regexp.Regexp
+	if cfg.SeparatorRegex != "" {
+		var err error
+		if separatorRe, err = tailer.CompileSeparatorRegex(cfg.SeparatorRegex); err != nil {
+			return nil, err
+		}
+	}
+	ruleRes, err := compileSeparatorRules(cfg.SeparatorRules)
+	if err != nil {
+		return nil, err
+	}
+	var anyNewline *regexp.Regexp
+	if cfg.SeparatorAutoDetect {
+		if err := cfg.validateSeparatorAutoDetect(); err != nil {
+			return nil, err
+		}
+		anyNewline = regexp.MustCompile(separatorAnyNewline)
+	}
+	if err := cfg.validateLengthPrefix(); err != nil {
+		return nil, err
+	}
+
 	c := &Collector{
-		cfg:    cfg,
-		stopCh: make(chan struct{}),
+		cfg:         cfg,
+		separatorRe: separatorRe,
+		ruleRes:     ruleRes,
+		anyNewline:  anyNewline,
+		stopCh:      make(chan struct{}),
+		logger:      cfg.Logger,
+		metrics:     cfg.Metrics,
+		clock:       cfg.Clock,
+		beforeStart: make(map[string]bool),
+		catchUp:     make(map[string]bool),
+		startup:     make(map[string]bool),
+		followed:    make(map[string]string),
+		upgrades:    make(map[string]string),
+		failures:    make(map[string]int),
+		iterErrs:    make(chan error, 16),
+		wake:        make(chan struct{}, 1),
+		repeats:     make(map[string]*repeatRun),
+	}
+	if cfg.MergeWindow > 0 {
+		c.merge = newMerger()
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+	if c.metrics == nil {
+		c.metrics = metrics.Default()
+	}
+	if c.clock == nil {
+		c.clock = clock.Real()
+	}
+	c.cfg.Multiline, c.cfg.MultilineRules = ownMultiline(cfg.Multiline, cfg.MultilineRules)
+	if cfg.StartupReadRateLimit > 0 {
+		c.startupLimit = newRateLimiter(cfg.StartupReadRateLimit, c.clock)
 	}
 
 	// Initialize offset store if enabled
 	if cfg.StoreOffsets {
 		var err error
-		c.offsetDB, err = store.NewSQLiteStore(cfg.DBPath)
+		c.offsetDB, err = openStore(cfg, c.logger)
 		if err != nil {
 			return nil, err
 		}
+		if cfg.ReadOnlyOffsets {
+			c.offsetDB = store.ReadOnly(c.offsetDB)
+		}
 	}
 
 	c.scheduler = NewTailScheduler()
+	c.scheduler.logger = c.logger
+	c.scheduler.clock = c.clock
+	c.scheduler.idleAfter = cfg.IdleAfter
+	c.scheduler.idleCheck = cfg.IdleRecheckInterval
+	if c.scheduler.idleCheck <= 0 {
+		c.scheduler.idleCheck = DefaultIdleRecheckInterval
+	}
+	if err := c.acquireLease(); err != nil {
+		if c.offsetDB != nil {
+			_ = c.offsetDB.Close()
+		}
+		return nil, err
+	}
+	if err := c.joinShard(); err != nil {
+		if c.offsetDB != nil {
+			_ = c.offsetDB.Close()
+		}
+		return nil, err
+	}
 
 	c.fileManager = file_tracker.New()
 
 	// If we have an offset store, load existing files and their offsets
 	if c.offsetDB != nil && c.cfg.StoreOffsets {
 		// We'll implement this in the watcher's callback function
-		slog.Debug("offset store enabled, offsets will be loaded when files are discovered")
+		c.logger.Debug("offset store enabled, offsets will be loaded when files are discovered")
 	}
 
-	var err error
 	config := watcher.DefaultConfig()
 	config.PollInterval = cfg.PollInterval
 	config.FileTracker = c.fileManager
@@ -156,13 +737,65 @@ func NewCollector(cfg Config) (*Collector, error) {
 	config.FingerprintSeparator = cfg.Separator
 	config.Include = cfg.Include
 	config.Exclude = cfg.Exclude
+	config.ExcludeDirs = cfg.ExcludeDirs
+	config.IgnoreFile = cfg.IgnoreFile
+	config.FingerprintOffset = cfg.FingerprintOffset
+	config.ProvisionalIDs = cfg.UpgradeFingerprints
+	config.KeepDuplicates = cfg.KeepDuplicates
+	config.ScanBudget = cfg.ScanBudget
+	config.ScanMaxFiles = cfg.ScanMaxFiles
+	config.Logger = c.logger
+	config.Clock = c.clock
+	c.unreadable = watcher.NewUnreadableFiles(cfg.PollInterval)
+	c.unreadable.OnChange = c.metrics.SetUnreadableFiles
+	config.Unreadable = c.unreadable
+	config.SkipLocked = cfg.SkipLocked
+	config.OnLocked = func(string) { c.metrics.IncLocked() }
+	c.decisions = watcher.NewDecisionLog(0)
+	c.recent = newRecentRecords(cfg.RetainLastN)
+	config.Decisions = c.decisions
+	if cfg.TraceScans {
+		c.trace = watcher.NewDecisionLog(watcher.DefaultTraceHistory)
+		config.Trace = c.trace
+	}
+	if cfg.NetworkFS {
+		config.MissedScans = cfg.NetworkFSRetries
+	}
+	config.OnReplace = func(id, previous string) {
+		// Read the rotated-away file to its end before its replacement
+		c.logger.Debug("file replaced", "file", id, "previous", previous)
+		c.scheduler.Hold(id, previous)
+	}
+	if c.shard != nil {
+		config.Owns = c.shard.owns
+		config.OnDisown = func(id string) {
+			c.logger.Debug("file now owned by another shard", "file", id)
+			c.untrack(id, false)
+		}
+	}
+	config.OnUpgrade = func(id, provisional string) {
+		// Read the file under its checksum ID from where the provisional one got to
+		c.logger.Debug("file grown enough to fingerprint", "file", id, "provisional", provisional)
+		c.scheduler.Hold(id, provisional)
+		c.mu.Lock()
+		c.upgrades[provisional] = id
+		c.mu.Unlock()
+	}
 
 	c.onLineFunc = cfg.OnLineFunc
 	c.onEventFunc = cfg.OnEventFunc
+	c.onErrorFunc = cfg.OnErrorFunc
+	c.callbacks = newCallbackGate()
 
 	c.watcher, err = watcher.NewWatcher(
 		config,
 		func(id, path string) {
+			if _, ok := c.deleted.Load(id); ok {
+				// Found again, e.g. moved back into the includes: its reader carries on
+				c.logger.Debug("deleted file found again", "file", id, "path", path)
+				c.forgetDeleted(id)
+				return
+			}
 			// Initialize with offset 0
 			offset := int64(0)
 
@@ -170,47 +803,88 @@ func NewCollector(cfg Config) (*Collector, error) {
 			if c.offsetDB != nil {
 				// Load by ID and strategy
 				storedOffset, found, err := c.offsetDB.Load(id, c.cfg.FingerprintStrategy)
+				if !found && err == nil && c.cfg.UpgradeFingerprints {
+					storedOffset, found = c.provisionalOffset(path)
+				}
+				if found && c.replayFromBeginning(path) {
+					c.logger.Info("ignoring stored offset, reading from beginning", "file", id, "path", path, "offset", storedOffset)
+					found = false
+				}
 				if err != nil {
-					slog.Error("failed to load offset", "file", id, "error", err)
+					c.logger.Error("failed to load offset", "file", id, "error", err)
+					c.reportError(err, ErrorContext{Kind: ErrorKindStore, FileID: id, Path: path, Op: "load"})
 				} else if found {
-					offset = storedOffset
-					slog.Debug("loaded offset from store", "file", id, "offset", offset)
+					resumed, ok := c.resumeOffset(id, path, storedOffset)
+					if !ok {
+						// Quarantined: keep the stored offset and leave the file unread
+						c.fileManager.UpdateOffset(id, storedOffset)
+						return
+					}
+					offset = resumed
+					c.logger.Debug("loaded offset from store", "file", id, "offset", offset)
 
 					// Update the offset in the FileTracker
 					// The file was just added by the watcher, so we need to update its offset
 					c.fileManager.UpdateOffset(id, offset)
 
 					// Metrics: note that we restored an offset on startup/discovery
-					metrics.IncRestoredOffsets()
+					c.metrics.IncRestoredOffsets()
 				}
 			}
 
+			// Files read from the start skip records older than StartFromTime
+			if !c.cfg.StartFromTime.IsZero() && offset == 0 {
+				c.mu.Lock()
+				c.beforeStart[id] = true
+				c.mu.Unlock()
+			}
+			if c.cfg.CatchUpChunkSize > 0 {
+				c.mu.Lock()
+				c.catchUp[id] = true
+				c.mu.Unlock()
+			}
+			if scanned, _ := c.watcher.LastScan(); scanned.IsZero() {
+				// Found by the first scan, e.g. historical logs present at startup
+				c.startupFile(id)
+			}
+
+			separator, separatorRe := c.separatorFor(id, path)
 			fileTail := tailer.TailReader{
 				FileId:      id,
 				Offset:      offset,
-				Separator:   c.cfg.Separator,
-				Multiline:   c.cfg.Multiline,
+				Separator:   separator,
+				Multiline:   c.newMultiline(id, path),
 				FileManager: c.fileManager,
+				Logger:      c.logger,
+
+				HoldMultiline:   true,
+				SeparatorRegex:  separatorRe,
+				LengthPrefix:    c.cfg.LengthPrefix,
+				ReadBufferSize:  c.cfg.ReadBufferSize,
+				ChunkBufferSize: c.cfg.ChunkBufferSize,
+				ResetOnTruncate: c.cfg.FollowName,
+				KeepOpen:        c.cfg.HoldDeleted > 0,
+			}
+			if c.cfg.FollowName {
+				c.mu.Lock()
+				c.followed[id] = path
+				c.mu.Unlock()
 			}
-			slog.Debug("file added", "file", id, "path", path, "offset", offset)
+			c.logger.Debug("file added", "file", id, "path", path, "offset", offset)
+			c.scheduler.SetWeight(id, c.weightFor(path))
 			c.scheduler.Add(id, &fileTail, false)
+			c.wakeWorker()
 			// Metrics: track discovered and active files
-			metrics.IncFilesSeen()
-			metrics.IncActiveFiles()
+			c.metrics.IncFilesSeen()
+			c.metrics.IncActiveFiles()
+
+			if c.cfg.OnFileAdded != nil {
+				c.cfg.OnFileAdded(id, path)
+			}
 		},
 		func(id string) {
-			// Remove from scheduler
-			c.scheduler.Remove(id)
-			// Metrics: active files decrease
-			metrics.DecActiveFiles()
-
-			// Delete offset from store if available
-			if c.offsetDB != nil && c.cfg.StoreOffsets {
-				if err := c.offsetDB.Delete(id, c.cfg.FingerprintStrategy); err != nil {
-					slog.Error("failed to delete offset", "file", id, "error", err)
-				} else {
-					slog.Debug("deleted offset", "file", id)
-				}
+			if !c.holdDeleted(id) {
+				c.untrack(id, true)
 			}
 		})
 	if err != nil {
@@ -220,33 +894,311 @@ func NewCollector(cfg Config) (*Collector, error) {
 	return c, nil
 }
 
+// untrack stops reading the file id, which the watcher is about to drop from the
+// tracker, so its path is still known. The offset is deleted from the store when
+// deleteOffset is set; files handed over to another shard keep it for their new owner.
+func (c *Collector) untrack(id string, deleteOffset bool) {
+	path := c.pathOf(id)
+	// Remove from scheduler; a worker reading it flushes it when done
+	if fileTail, running := c.scheduler.Remove(id); !running {
+		if fileTail != nil {
+			c.flushFile(fileTail, path)
+			c.upgraded(fileTail)
+			fileTail.ReleaseFile()
+		}
+		c.scheduler.Release(id)
+	}
+	c.mu.Lock()
+	delete(c.beforeStart, id)
+	delete(c.catchUp, id)
+	delete(c.startup, id)
+	delete(c.failures, id)
+	delete(c.followed, id)
+	c.mu.Unlock()
+	c.separators.Delete(id)
+	c.forgetDeleted(id)
+	// Metrics: active files decrease; quarantined files were never read
+	if _, quarantined := c.quarantined.LoadAndDelete(id); !quarantined {
+		c.metrics.DecActiveFiles()
+	}
+
+	// Delete offset from store if available
+	if deleteOffset && c.storesOffsets() {
+		if err := c.offsetDB.Delete(id, c.cfg.FingerprintStrategy); err != nil {
+			c.logger.Error("failed to delete offset", "file", id, "error", err)
+			c.reportError(err, ErrorContext{Kind: ErrorKindStore, FileID: id, Path: path, Op: "delete"})
+		} else {
+			c.logger.Debug("deleted offset", "file", id)
+		}
+	}
+
+	c.fileRemoved(id, path)
+}
+
+// openStore opens the offset store configured by cfg: PostgreSQL when PostgresDSN is
+// set, otherwise the SQLite database at DBPath.
+func openStore(cfg Config, logger *slog.Logger) (store.Store, error) {
+	if cfg.PostgresDSN != "" {
+		return store.NewPostgresStore(cfg.PostgresDSN, store.PostgresOptions{
+			Driver:    cfg.PostgresDriver,
+			Namespace: cfg.PostgresNamespace,
+			Logger:    logger,
+		})
+	}
+	return store.NewSQLiteStoreWithOptions(cfg.DBPath, store.Options{
+		Logger:           logger,
+		RebuildIfCorrupt: cfg.RebuildCorruptStore,
+	})
+}
+
+// Start launches the workers and the watcher. Calling it more than once has no effect.
 func (c *Collector) Start() {
-	// Start worker goroutines
-	if c.cfg.WorkerCount > 0 {
-		for i := 0; i < c.cfg.WorkerCount; i++ {
+	c.startOnce.Do(func() {
+		c.started.Store(true)
+		// Start worker goroutines
+		if c.cfg.WorkerCount > 0 {
+			for i := 0; i < c.cfg.WorkerCount; i++ {
+				c.workerWg.Add(1)
+				go c.worker()
+			}
+		}
+		if m, ok := c.offsetDB.(store.Maintainer); ok && c.cfg.StoreMaintenanceInterval > 0 {
 			c.workerWg.Add(1)
-			go c.worker()
+			go c.maintainStore(m, c.cfg.StoreMaintenanceInterval)
 		}
+		if c.leaser != nil {
+			c.workerWg.Add(1)
+			go c.renewLease(c.leaser, c.cfg.LeaseTTL)
+		}
+		if c.membership != nil {
+			c.workerWg.Add(1)
+			go c.heartbeatShard()
+		}
+		if c.merge != nil {
+			c.workerWg.Add(1)
+			go c.runMerge()
+		}
+		if c.cfg.CallbackTimeout > 0 {
+			c.workerWg.Add(1)
+			go c.watchCallbacks(c.cfg.CallbackTimeout)
+		}
+
+		// Start the watcher
+		c.watcher.Start()
+	})
+}
+
+// SeekFile repositions the read offset of the tracked file at path and persists it
+// when offsets are stored. The offset must lie within the file and should point at a
+// record boundary. If the file is being read, the read in progress completes first
+// and reading continues from offset afterwards.
+func (c *Collector) SeekFile(path string, offset int64) error {
+	id := c.fileIDOf(path)
+	if id == "" {
+		return fmt.Errorf("%w: %s", ErrFileNotTracked, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset > info.Size() {
+		return fmt.Errorf("offset %d out of range [0, %d] for %s", offset, info.Size(), path)
+	}
+	return c.seek(id, path, offset)
+}
+
+// SeekToEnd moves the read offset of the tracked file at path to its current end,
+// skipping everything written so far.
+func (c *Collector) SeekToEnd(path string) error {
+	id := c.fileIDOf(path)
+	if id == "" {
+		return fmt.Errorf("%w: %s", ErrFileNotTracked, path)
 	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return c.seek(id, path, info.Size())
+}
 
-	// Start the watcher
-	c.watcher.Start()
+func (c *Collector) seek(id, path string, offset int64) error {
+	if !c.scheduler.Seek(id, offset) {
+		return fmt.Errorf("%w: %s", ErrFileNotTracked, path)
+	}
+	c.fileManager.UpdateOffset(id, offset)
+	c.position(id).Store(offset)
+	if c.storesOffsets() {
+		if err := c.offsetDB.Save(id, c.cfg.FingerprintStrategy, path, offset); err != nil {
+			c.logger.Error("failed to save offset", "file", id, "offset", offset, "error", err)
+			c.reportError(err, ErrorContext{Kind: ErrorKindStore, FileID: id, Path: path, Op: "save"})
+			return err
+		}
+	}
+	c.logger.Info("file offset moved", "file", id, "path", path, "offset", offset)
+	return nil
 }
 
-func (c *Collector) Stop() {
-	// Signal all workers to stop
-	close(c.stopCh)
+// fileIDOf returns the id of the tracked file at path, or "" if none is tracked.
+func (c *Collector) fileIDOf(path string) string {
+	clean := filepath.Clean(path)
+	for id, f := range c.fileManager.GetAllFiles() {
+		if filepath.Clean(f.Path) == clean {
+			return id
+		}
+	}
+	return ""
+}
 
-	// Wait for all workers to finish
-	c.workerWg.Wait()
+// AddInclude adds an include pattern at runtime. It takes effect on the next scan;
+// files already tracked keep their offsets.
+func (c *Collector) AddInclude(pattern string) error {
+	if err := c.watcher.AddInclude(pattern); err != nil {
+		return err
+	}
+	c.logger.Info("include pattern added", "pattern", pattern)
+	return nil
+}
 
-	// Stop the watcher
-	c.watcher.Stop()
+// RemoveInclude removes an include pattern at runtime. On the next scan, files no
+// longer matched by any include are untracked and their stored offsets deleted.
+// It reports whether the pattern was present.
+func (c *Collector) RemoveInclude(pattern string) bool {
+	removed := c.watcher.RemoveInclude(pattern)
+	if removed {
+		c.logger.Info("include pattern removed", "pattern", pattern)
+	}
+	return removed
+}
 
-	// Close the offset store if it exists
-	if c.offsetDB != nil {
-		if err := c.offsetDB.Close(); err != nil {
-			slog.Error("failed to close offset store", "error", err)
+// SetExclude replaces the exclude patterns at runtime, effective from the next scan.
+func (c *Collector) SetExclude(patterns []string) {
+	c.watcher.SetExclude(patterns)
+	c.logger.Info("exclude patterns updated", "patterns", patterns)
+}
+
+// Rescan makes the watcher look for new, removed and renamed files now instead of
+// waiting up to PollInterval for its next scan. It returns without waiting for the
+// scan.
+func (c *Collector) Rescan() {
+	c.watcher.Rescan()
+}
+
+// Records returns a channel delivering every collected record, as an alternative to
+// OnLineFunc/OnEventFunc (which, if set, are still called). The channel is created on
+// the first call with Config.RecordsBuffer capacity; call Records before Start so no
+// record is missed. When the channel is full, reading blocks until the consumer catches
+// up. Stop closes the channel once all workers have exited, so consumers should range
+// over it until it is closed; records not yet delivered at that point are re-read on the
+// next run when offsets are stored.
+func (c *Collector) Records() <-chan Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.records == nil {
+		size := c.cfg.RecordsBuffer
+		if size <= 0 {
+			size = DefaultRecordsBuffer
+		}
+		c.records = make(chan Record, size)
+		if c.workersDone {
+			// Already stopped: nothing will be delivered
+			close(c.records)
+		}
+	}
+	return c.records
+}
+
+// Pause stops scheduling new reads. Reads already in progress run to the end of
+// their file; tracking, discovery and stored offsets are kept, so Resume continues
+// exactly where reading stopped. Pause and Resume may be called at any time after
+// NewCollector and are idempotent.
+func (c *Collector) Pause() {
+	c.scheduler.SetPaused(true)
+	c.logger.Info("collector paused")
+}
+
+// Resume restarts reading after Pause. Workers pick files up again within their
+// idle back-off interval.
+func (c *Collector) Resume() {
+	c.scheduler.SetPaused(false)
+	c.logger.Info("collector resumed")
+}
+
+// Paused reports whether the collector is currently paused.
+func (c *Collector) Paused() bool {
+	return c.scheduler.Paused()
+}
+
+// flushOnStop delivers the records held back for each file (see flushFile) and by the
+// merge, and commits the offsets past them. With the Records channel they are left
+// unread instead, as nothing may be receiving any more: multiline and merged records
+// are read again on the next start and repeat counts are dropped.
+func (c *Collector) flushOnStop() {
+	c.mu.Lock()
+	records := c.records
+	c.mu.Unlock()
+	tails := c.scheduler.Tails()
+	failed := make(map[string]bool)
+	for _, fileTail := range tails {
+		if records != nil {
+			if fileTail.Multiline != nil {
+				fileTail.Multiline.Close()
+				c.metrics.DeleteMultilineDepth(c.pathOf(fileTail.FileId))
+			}
+			continue
+		}
+		if err := c.flushFile(fileTail, c.pathOf(fileTail.FileId)); err != nil {
+			// Leave the stored offset before the records, so they are read on the next start
+			c.logger.Warn("records not delivered on stop", "file", fileTail.FileId, "error", err)
+			failed[fileTail.FileId] = true
 		}
 	}
+	if records != nil {
+		return
+	}
+	if c.merge != nil {
+		c.releaseMerged(true, nil)
+	}
+	for _, fileTail := range tails {
+		if (fileTail.Multiline != nil || c.merge != nil) && !failed[fileTail.FileId] {
+			_ = c.commitOffset(fileTail)
+		}
+	}
+}
+
+// Stop stops the workers and the watcher and closes the offset store. Calling it more
+// than once has no effect.
+func (c *Collector) Stop() {
+	c.stopOnce.Do(func() {
+		// Signal all workers to stop
+		close(c.stopCh)
+
+		// Wait for all workers to finish
+		c.workerWg.Wait()
+
+		c.flushOnStop()
+		for _, fileTail := range c.scheduler.Tails() {
+			fileTail.ReleaseFile()
+		}
+
+		c.mu.Lock()
+		c.workersDone = true
+		if c.records != nil {
+			close(c.records)
+		}
+		c.mu.Unlock()
+
+		// Stop the watcher
+		c.watcher.Stop()
+
+		c.releaseLease()
+		c.leaveShard()
+		// Close the offset store if it exists
+		if c.offsetDB != nil {
+			if err := c.offsetDB.Close(); err != nil {
+				c.logger.Error("failed to close offset store", "error", err)
+				c.reportError(err, ErrorContext{Kind: ErrorKindStore, Op: "close"})
+			}
+		}
+	})
 }
@@ -1,8 +1,12 @@
 package collector
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -11,10 +15,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/loykin/freader/internal/metrics"
+	"github.com/loykin/freader/internal/store"
 	"github.com/loykin/freader/internal/tailer"
 	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	_ "modernc.org/sqlite"
 )
 
@@ -1368,6 +1377,250 @@ func TestCollector_Multiline_EOFResidual(t *testing.T) {
 	mu.Unlock()
 }
 
+// A trace still being assembled is delivered, marked Forced, when the collector stops
+// long before the multiline timeout.
+func TestCollector_Multiline_FlushOnStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	p := filepath.Join(base, "ml_stop.log")
+	assert.NoError(t, os.WriteFile(p, []byte("INFO ok\nERROR boom\n  at a\n  at b\n"), 0644))
+
+	var mu sync.Mutex
+	var out []LineEvent
+	cfg := Config{
+		Include:             []string{p},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode,
+		Multiline: &tailer.MultilineReader{
+			Mode:             tailer.MultilineReaderModeContinueThrough,
+			StartPattern:     "^(ERROR|INFO)",
+			ConditionPattern: "^\\s",
+			Timeout:          time.Hour,
+		},
+		OnEventFunc: func(ev LineEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			out = append(out, ev)
+		},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(out) == 1
+	}, 2*time.Second, 20*time.Millisecond)
+	c.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, out, 2)
+	assert.Equal(t, "INFO ok", out[0].Line)
+	assert.False(t, out[0].Forced)
+	assert.Equal(t, "ERROR boom\n  at a\n  at b", out[1].Line)
+	assert.True(t, out[1].Forced)
+}
+
+// Removing a file delivers the trace it was still assembling.
+func TestCollector_Multiline_FlushOnRemoval(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	p := filepath.Join(base, "ml_removed.log")
+	assert.NoError(t, os.WriteFile(p, []byte("ERROR boom\n  at a\n"), 0644))
+
+	events := make(chan LineEvent, 4)
+	cfg := Config{
+		Include:             []string{p},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode,
+		Multiline: &tailer.MultilineReader{
+			Mode:             tailer.MultilineReaderModeContinueThrough,
+			StartPattern:     "^(ERROR|INFO)",
+			ConditionPattern: "^\\s",
+			Timeout:          time.Hour,
+		},
+		OnEventFunc: func(ev LineEvent) { events <- ev },
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	// Wait until a worker has read the whole file, holding the trace
+	assert.Eventually(t, func() bool {
+		files := c.Stats().Files
+		return len(files) == 1 && files[0].Position == int64(len("ERROR boom\n  at a\n"))
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Empty(t, events)
+	assert.NoError(t, os.Remove(p))
+
+	select {
+	case ev := <-events:
+		assert.Equal(t, "ERROR boom\n  at a", ev.Line)
+		assert.True(t, ev.Forced)
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for the flushed record")
+	}
+}
+
+// Files read side by side each assemble their records in their own copy of the
+// Multiline template, which is left untouched, and report their buffered lines.
+func TestCollector_Multiline_InterleavedFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	a := filepath.Join(base, "a.log")
+	b := filepath.Join(base, "b.log")
+	assert.NoError(t, os.WriteFile(a, []byte("ERROR a\n  a1\n  a2\n"), 0644))
+	assert.NoError(t, os.WriteFile(b, []byte("ERROR b\n  b1\n"), 0644))
+
+	template := &tailer.MultilineReader{
+		Mode:             tailer.MultilineReaderModeContinueThrough,
+		StartPattern:     "^(ERROR|INFO)",
+		ConditionPattern: "^\\s",
+		Timeout:          time.Hour,
+	}
+	set := metrics.NewSet()
+	reg := prometheus.NewRegistry()
+	require.NoError(t, set.Register(reg, nil))
+	depth := func() map[string]float64 {
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+		got := map[string]float64{}
+		for _, mf := range mfs {
+			if mf.GetName() != "freader_multiline_buffered_lines" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				got[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+		return got
+	}
+
+	var mu sync.Mutex
+	var out []string
+	cfg := Config{
+		Include:             []string{filepath.Join(base, "*.log")},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         2,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode,
+		Multiline:           template,
+		Metrics:             set,
+		OnLineFunc: func(s string) {
+			mu.Lock()
+			defer mu.Unlock()
+			out = append(out, s)
+		},
+	}
+	c, err := NewCollector(cfg)
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		d := depth()
+		return d[a] == 3 && d[b] == 2
+	}, 3*time.Second, 20*time.Millisecond)
+
+	// Continue both records, then complete them
+	for _, w := range []struct{ path, data string }{
+		{b, "  b2\n"}, {a, "  a3\n"}, {b, "INFO b done\n"}, {a, "INFO a done\n"},
+	} {
+		f, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString(w.data)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(out) == 2
+	}, 3*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"ERROR a\n  a1\n  a2\n  a3", "ERROR b\n  b1\n  b2"}, out)
+	mu.Unlock()
+	assert.Eventually(t, func() bool {
+		d := depth()
+		return d[a] == 1 && d[b] == 1
+	}, 3*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, 0, template.Depth())
+	assert.Nil(t, template.Clock)
+	assert.Nil(t, template.OnTimeout)
+}
+
+// Multiline rules group files matching their pattern and leave the others to the
+// global Multiline, or ungrouped with a nil rule.
+func TestCollector_MultilineRules(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	app := filepath.Join(base, "app.log")
+	access := filepath.Join(base, "access.log")
+	assert.NoError(t, os.WriteFile(app, []byte("ERROR boom\n  at a\nINFO next\n"), 0644))
+	assert.NoError(t, os.WriteFile(access, []byte("GET /a\n  GET /b\n"), 0644))
+
+	var mu sync.Mutex
+	out := map[string][]string{}
+	cfg := Config{
+		Include:             []string{filepath.Join(base, "*.log")},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode,
+		// Would group the indented access log line too
+		Multiline: &tailer.MultilineReader{
+			Mode:             tailer.MultilineReaderModeContinueThrough,
+			StartPattern:     "^\\S",
+			ConditionPattern: "^\\s",
+			Timeout:          time.Hour,
+		},
+		MultilineRules: []MultilineRule{
+			{Pattern: "access*.log"},
+			{Pattern: "app*.log", Multiline: &tailer.MultilineReader{
+				Mode:             tailer.MultilineReaderModeContinueThrough,
+				StartPattern:     "^(ERROR|INFO)",
+				ConditionPattern: "^\\s",
+				Timeout:          time.Hour,
+			}},
+		},
+		OnEventFunc: func(ev LineEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			out[filepath.Base(ev.File)] = append(out[filepath.Base(ev.File)], ev.Line)
+		},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(out["app.log"]) == 1 && len(out["access.log"]) == 2
+	}, 2*time.Second, 20*time.Millisecond)
+	c.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"ERROR boom\n  at a", "INFO next"}, out["app.log"])
+	assert.Equal(t, []string{"GET /a", "  GET /b"}, out["access.log"])
+}
+
 // Java-style stack trace grouping through Collector: ensure Java logs are collected as single records
 func TestCollector_JavaLogs_Multiline_Grouping(t *testing.T) {
 	if runtime.GOOS == "windows" {
@@ -1490,3 +1743,868 @@ func TestCollector_Multiline_TimeoutFlush_Collector(t *testing.T) {
 	assert.Contains(t, out, "ERROR start\n  d1")
 	mu.Unlock()
 }
+
+// Idle files are not read until their recheck, except to deliver a multiline timeout.
+func TestCollector_IdleFiles(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "idle.log")
+	require.NoError(t, os.WriteFile(p, []byte("ERROR start\n  d1\n"), 0644))
+
+	var (
+		mu  sync.Mutex
+		out []string
+	)
+	c, err := New(WithInclude(p), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithIdleFiles(time.Nanosecond, time.Hour),
+		WithMultiline(&tailer.MultilineReader{
+			Mode:             tailer.MultilineReaderModeContinueThrough,
+			StartPattern:     "^(ERROR|INFO|WARN)",
+			ConditionPattern: "^\\s",
+			Timeout:          time.Second,
+		}),
+		WithOnLine(func(s string) {
+			mu.Lock()
+			defer mu.Unlock()
+			out = append(out, s)
+		}))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(out) == 1
+	}, 3*time.Second, 20*time.Millisecond, "multiline timeout not delivered for an idle file")
+	assert.Equal(t, 1, c.Stats().IdleFiles)
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("INFO next\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, int64(len("ERROR start\n  d1\n")), c.Stats().Files[0].Position)
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int64(len("ERROR start\n  d1\n")), c.Stats().Files[0].Position,
+		"idle file read before its recheck")
+}
+
+// failingStore is a store.Store whose writes always fail.
+type failingStore struct{}
+
+func (failingStore) Save(string, string, string, int64) error { return errors.New("disk full") }
+func (failingStore) Load(string, string) (int64, bool, error) { return 0, false, nil }
+func (failingStore) Delete(string, string) error              { return errors.New("disk full") }
+func (failingStore) List(string) ([]store.Entry, error)       { return nil, nil }
+func (failingStore) Close() error                             { return nil }
+
+func TestCollector_OnErrorFunc_StoreAndFingerprintMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "app.log")
+	assert.NoError(t, os.WriteFile(testFile, []byte("first line of the log\n"), 0644))
+
+	var mu sync.Mutex
+	got := map[ErrorKind]ErrorContext{}
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        time.Hour, // only the initial scan, so the worker sees the mismatch
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     16,
+		OnLineFunc:          func(string) {},
+		OnErrorFunc: func(err error, ctx ErrorContext) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Error(t, err)
+			got[ctx.Kind] = ctx
+		},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.offsetDB = failingStore{}
+	c.cfg.StoreOffsets = true
+
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := got[ErrorKindStore]
+		return ok
+	}, 3*time.Second, 20*time.Millisecond)
+
+	// Overwrite the head of the file so the checksum fingerprint no longer matches.
+	assert.NoError(t, os.WriteFile(testFile, []byte("rewritten content entirely\n"), 0644))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := got[ErrorKindFingerprintMismatch]
+		return ok
+	}, 5*time.Second, 20*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "save", got[ErrorKindStore].Op)
+	assert.Equal(t, testFile, got[ErrorKindStore].Path)
+	assert.Equal(t, testFile, got[ErrorKindFingerprintMismatch].Path)
+}
+
+// syncBuffer is a goroutine-safe bytes.Buffer for capturing log output.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCollector_LoggerIsPerCollector(t *testing.T) {
+	tempDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.log"), []byte("hello\n"), 0644))
+
+	var out syncBuffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})).With("collector", "tenant-a")
+
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     4,
+		StoreOffsets:        true,
+		DBPath:              filepath.Join(tempDir, "offsets.db"),
+		OnLineFunc:          func(string) {},
+		Logger:              logger,
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		s := out.String()
+		return strings.Contains(s, "file added") && strings.Contains(s, "saved offset")
+	}, 3*time.Second, 20*time.Millisecond)
+	// Store migrations are reported through the same logger.
+	assert.Contains(t, out.String(), "collector=tenant-a")
+	assert.Contains(t, out.String(), "00001_create_table_offsets.sql")
+}
+
+func TestCollector_LifecycleHooks(t *testing.T) {
+	tempDir := t.TempDir()
+	keep := filepath.Join(tempDir, "keep.log")
+	gone := filepath.Join(tempDir, "gone.log")
+	assert.NoError(t, os.WriteFile(keep, []byte("keep this line\n"), 0644))
+	assert.NoError(t, os.WriteFile(gone, []byte("remove this line\n"), 0644))
+
+	var mu sync.Mutex
+	added := map[string]string{}
+	removed := map[string]string{}
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		OnLineFunc:          func(string) {},
+		OnFileAdded: func(id, path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			added[path] = id
+		},
+		OnFileRemoved: func(id, path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			removed[path] = id
+		},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(added) == 2
+	}, 3*time.Second, 20*time.Millisecond)
+
+	assert.NoError(t, os.Remove(gone))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := removed[gone]
+		return ok
+	}, 3*time.Second, 20*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, added[gone], removed[gone])
+	assert.NotContains(t, removed, keep)
+}
+
+func TestCollector_OnFingerprintMismatchHook(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "app.log")
+	assert.NoError(t, os.WriteFile(testFile, []byte("first line of the log\n"), 0644))
+
+	lines := make(chan string, 4)
+	mismatched := make(chan string, 1)
+	removed := make(chan string, 1)
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        time.Hour, // only the initial scan, so the worker sees the mismatch
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     16,
+		OnLineFunc:          func(line string) { lines <- line },
+		OnFingerprintMismatch: func(path string) {
+			mismatched <- path
+		},
+		OnFileRemoved: func(id, path string) {
+			removed <- path
+		},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case <-lines:
+	case <-time.After(3 * time.Second):
+		t.Fatal("initial line not read")
+	}
+
+	assert.NoError(t, os.WriteFile(testFile, []byte("rewritten content entirely\n"), 0644))
+	select {
+	case p := <-mismatched:
+		assert.Equal(t, testFile, p)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnFingerprintMismatch not called")
+	}
+	select {
+	case p := <-removed:
+		assert.Equal(t, testFile, p)
+	case <-time.After(time.Second):
+		t.Fatal("OnFileRemoved not called after mismatch")
+	}
+}
+
+func TestCollector_PauseResume(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "app.log")
+	assert.NoError(t, os.WriteFile(testFile, []byte("before pause\n"), 0644))
+
+	lines := make(chan string, 4)
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		OnLineFunc:          func(line string) { lines <- line },
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case l := <-lines:
+		assert.Equal(t, "before pause", l)
+	case <-time.After(3 * time.Second):
+		t.Fatal("initial line not read")
+	}
+
+	c.Pause()
+	assert.True(t, c.Paused())
+	// Let any in-flight read finish before appending.
+	assert.Eventually(t, func() bool { return c.Stats().ActiveReads == 0 }, time.Second, 10*time.Millisecond)
+
+	f, err := os.OpenFile(testFile, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("after pause\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	select {
+	case l := <-lines:
+		t.Fatalf("read %q while paused", l)
+	case <-time.After(500 * time.Millisecond):
+	}
+	st := c.Stats()
+	assert.True(t, st.Paused)
+	assert.Equal(t, 1, st.TrackedFiles)
+	assert.Equal(t, int64(len("before pause\n")), st.Files[0].Offset)
+
+	c.Resume()
+	assert.False(t, c.Paused())
+	select {
+	case l := <-lines:
+		assert.Equal(t, "after pause", l)
+	case <-time.After(5 * time.Second):
+		t.Fatal("line not read after Resume")
+	}
+}
+
+func TestCollector_AddIncludeAtRuntime(t *testing.T) {
+	base := t.TempDir()
+	tenantA := filepath.Join(base, "tenant-a")
+	tenantB := filepath.Join(base, "tenant-b")
+	assert.NoError(t, os.MkdirAll(tenantA, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tenantA, "app.log"), []byte("a: first\n"), 0644))
+
+	lines := make(chan string, 4)
+	cfg := Config{
+		Include:             []string{tenantA},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     4,
+		OnLineFunc:          func(line string) { lines <- line },
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case l := <-lines:
+		assert.Equal(t, "a: first", l)
+	case <-time.After(3 * time.Second):
+		t.Fatal("tenant-a line not read")
+	}
+
+	// A new tenant directory appears and is added without recreating the collector.
+	assert.NoError(t, os.MkdirAll(tenantB, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tenantB, "app.log"), []byte("b: first\n"), 0644))
+	assert.NoError(t, c.AddInclude(tenantB))
+
+	select {
+	case l := <-lines:
+		assert.Equal(t, "b: first", l, "tenant-a must not be re-read")
+	case <-time.After(3 * time.Second):
+		t.Fatal("tenant-b line not read")
+	}
+}
+
+func TestCollector_SeekFile(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "app.log")
+	assert.NoError(t, os.WriteFile(testFile, []byte("one\ntwo\n"), 0644))
+
+	lines := make(chan string, 8)
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     4,
+		DBPath:              filepath.Join(t.TempDir(), "offsets.db"),
+		StoreOffsets:        true,
+		OnLineFunc:          func(line string) { lines <- line },
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	next := func() string {
+		select {
+		case l := <-lines:
+			return l
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for line")
+			return ""
+		}
+	}
+	assert.Equal(t, "one", next())
+	assert.Equal(t, "two", next())
+
+	assert.ErrorIs(t, c.SeekFile(filepath.Join(tempDir, "missing.log"), 0), ErrFileNotTracked)
+	assert.Error(t, c.SeekFile(testFile, 100))
+
+	// Replay the second record.
+	assert.NoError(t, c.SeekFile(testFile, 4))
+	id := c.fileIDOf(testFile)
+	stored, found, err := c.offsetDB.Load(id, cfg.FingerprintStrategy)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(4), stored)
+	assert.Equal(t, "two", next())
+
+	// Skip everything written so far, then only new data is read.
+	c.Pause()
+	assert.Eventually(t, func() bool { return c.Stats().ActiveReads == 0 }, time.Second, 10*time.Millisecond)
+	f, err := os.OpenFile(testFile, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("skipped\n")
+	assert.NoError(t, err)
+	assert.NoError(t, c.SeekToEnd(testFile))
+	_, err = f.WriteString("three\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	c.Resume()
+	assert.Equal(t, "three", next())
+}
+
+func TestCollector_FromBeginning(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	appLog := filepath.Join(tempDir, "app.log")
+	otherLog := filepath.Join(tempDir, "other.log")
+	assert.NoError(t, os.WriteFile(appLog, []byte("app line\n"), 0644))
+	assert.NoError(t, os.WriteFile(otherLog, []byte("other line\n"), 0644))
+
+	// run starts a collector until want lines arrived (or, for want == 0, for a while)
+	// and returns everything it read.
+	run := func(patterns []string, want int) []string {
+		var mu sync.Mutex
+		var got []string
+		cfg := Config{
+			Include:               []string{tempDir},
+			PollInterval:          50 * time.Millisecond,
+			WorkerCount:           1,
+			Separator:             "\n",
+			FingerprintStrategy:   watcher.FingerprintStrategyChecksum,
+			FingerprintSize:       4,
+			DBPath:                dbPath,
+			StoreOffsets:          true,
+			FromBeginningPatterns: patterns,
+			OnLineFunc: func(line string) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, line)
+			},
+		}
+		c, err := NewCollector(cfg)
+		assert.NoError(t, err)
+		c.Start()
+		if want == 0 {
+			time.Sleep(time.Second)
+		} else {
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(got) >= want
+			}, 5*time.Second, 20*time.Millisecond)
+		}
+		c.Stop()
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+
+	assert.ElementsMatch(t, []string{"app line", "other line"}, run(nil, 2))
+	assert.Empty(t, run(nil, 0), "stored offsets resume after restart")
+	assert.Equal(t, []string{"app line"}, run([]string{"app*.log"}, 1))
+}
+
+func TestCollector_StartFromTime(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "2024-05-01T09:00:00Z old\n" +
+		"no timestamp, still before start\n" +
+		"2024-05-01T12:00:00Z at start\n" +
+		"continuation without timestamp\n" +
+		"2024-05-01T11:00:00Z out of order but after start\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "app.log"), []byte(content), 0644))
+
+	var mu sync.Mutex
+	var got []string
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		StartFromTime:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		TimestampFunc: func(record string) (time.Time, bool) {
+			head, _, _ := strings.Cut(record, " ")
+			ts, err := time.Parse(time.RFC3339, head)
+			return ts, err == nil
+		},
+		OnLineFunc: func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, line)
+		},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) >= 3
+	}, 3*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{
+		"2024-05-01T12:00:00Z at start",
+		"continuation without timestamp",
+		"2024-05-01T11:00:00Z out of order but after start",
+	}, got)
+	mu.Unlock()
+
+	cfg.TimestampFunc = nil
+	_, err = NewCollector(cfg)
+	assert.Error(t, err)
+}
+
+func TestCollector_Records(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "app.log")
+	assert.NoError(t, os.WriteFile(testFile, []byte("r1\nr2\nr3\n"), 0644))
+
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     2,
+		RecordsBuffer:       1,
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	records := c.Records()
+	assert.Equal(t, 1, cap(records))
+	c.Start()
+
+	var got []Record
+	for len(got) < 3 {
+		select {
+		case rec := <-records:
+			got = append(got, rec)
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for records")
+		}
+	}
+	assert.Equal(t, "r1", got[0].Line)
+	assert.Equal(t, "r3", got[2].Line)
+	assert.Equal(t, testFile, got[0].File)
+
+	c.Stop()
+	_, open := <-records
+	assert.False(t, open, "Stop closes the records channel")
+}
+
+func TestCollector_Records_StopWhileBlockedKeepsOffsets(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "app.log"), []byte("r1\nr2\nr3\nr4\nr5\n"), 0644))
+
+	newCollector := func() *Collector {
+		c, err := NewCollector(Config{
+			Include:             []string{tempDir},
+			PollInterval:        50 * time.Millisecond,
+			WorkerCount:         1,
+			Separator:           "\n",
+			FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+			FingerprintSize:     2,
+			DBPath:              dbPath,
+			StoreOffsets:        true,
+			RecordsBuffer:       1,
+		})
+		assert.NoError(t, err)
+		return c
+	}
+
+	// First run: take one record, then stop while the worker is blocked on a full channel.
+	c := newCollector()
+	records := c.Records()
+	c.Start()
+	var got []string
+	select {
+	case rec := <-records:
+		got = append(got, rec.Line)
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for first record")
+	}
+	assert.Eventually(t, func() bool { return len(records) == 1 }, time.Second, 10*time.Millisecond)
+	c.Stop()
+	for rec := range records {
+		got = append(got, rec.Line)
+	}
+
+	// Second run resumes with the first record that was not delivered.
+	c = newCollector()
+	records = c.Records()
+	c.Start()
+	for len(got) < 5 {
+		select {
+		case rec := <-records:
+			got = append(got, rec.Line)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out; got %v", got)
+		}
+	}
+	c.Stop()
+	for rec := range records {
+		got = append(got, rec.Line)
+	}
+	assert.Equal(t, []string{"r1", "r2", "r3", "r4", "r5"}, got)
+}
+
+func TestCollector_SeparatorRules(t *testing.T) {
+	tempDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "appliance.log"), []byte("a1\r\na2<END>a3\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "plain.log"), []byte("p1<END>p2\n"), 0644))
+
+	var mu sync.Mutex
+	var got []string
+	c, err := New(
+		WithInclude(tempDir),
+		WithPollInterval(50*time.Millisecond),
+		WithSeparatorRule("appliance*.log", "\r\n", "\n", "<END>"),
+		WithOnLine(func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, line)
+		}),
+	)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) >= 4
+	}, 5*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	// Files not matching a rule keep the default separator
+	assert.ElementsMatch(t, []string{"a1", "a2", "a3", "p1<END>p2"}, got)
+}
+
+func TestCollector_LengthPrefix(t *testing.T) {
+	tempDir := t.TempDir()
+	var data []byte
+	for _, rec := range []string{"alpha", "be\nta"} {
+		data = binary.AppendUvarint(data, uint64(len(rec)))
+		data = append(data, rec...)
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "journal.bin"), data, 0644))
+
+	var mu sync.Mutex
+	var got []string
+	c, err := New(
+		WithInclude(tempDir),
+		WithPollInterval(50*time.Millisecond),
+		WithLengthPrefix(0, nil),
+		WithOnLineBytes(func(b []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, string(b))
+		}),
+	)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) >= 2
+	}, 5*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"alpha", "be\nta"}, got)
+
+	_, err = New(WithLengthPrefix(3, nil))
+	assert.Error(t, err)
+	ml := &tailer.MultilineReader{Mode: tailer.MultilineReaderModeContinuePast, StartPattern: `^\S`, ConditionPattern: `^\s`, Timeout: time.Second}
+	_, err = New(WithLengthPrefix(0, nil), WithMultiline(ml))
+	assert.ErrorContains(t, err, "length-prefixed")
+	_, err = New(WithLengthPrefix(0, nil), WithMultilineRule("*.bin", ml))
+	assert.ErrorContains(t, err, "length-prefixed")
+}
+
+func TestCollector_RetryOnNetworkFS(t *testing.T) {
+	c, err := New(WithNetworkFS(2))
+	assert.NoError(t, err)
+	assert.Equal(t, watcher.FingerprintStrategyChecksum, c.cfg.FingerprintStrategy)
+
+	mismatch := &tailer.FileFingerprintMismatchError{Path: "app.log"}
+	assert.True(t, c.retryOnNetworkFS("id", mismatch), "first failure is retried")
+	assert.False(t, c.retryOnNetworkFS("id", mismatch), "dropped once retries are exhausted")
+	assert.True(t, c.retryOnNetworkFS("id", mismatch), "count restarts for a rediscovered file")
+	assert.False(t, c.retryOnNetworkFS("id", errors.New("permission denied")), "other errors are not retried")
+
+	plain, err := New()
+	assert.NoError(t, err)
+	assert.False(t, plain.retryOnNetworkFS("id", mismatch))
+}
+
+// Records of a file rotated away by rename are all delivered before any record of the
+// file that replaced it, even with several workers.
+func TestCollector_RotationOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	w, err := testkit.NewLogWriter(filepath.Join(base, "app.log"))
+	assert.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(3)
+	assert.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		lines []string
+		files = map[string]string{}
+	)
+	c, err := NewCollector(Config{
+		Include:             []string{filepath.Join(base, "app.log*")},
+		PollInterval:        20 * time.Millisecond,
+		WorkerCount:         4,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode,
+		OnEventFunc: func(ev LineEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, ev.Line)
+			files[ev.Line] = ev.File
+		},
+	})
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+	received := func(n int) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(lines) == n
+		}
+	}
+	assert.Eventually(t, received(3), 2*time.Second, 10*time.Millisecond)
+
+	// Rotate right after a burst, so the new file is found while the old one is read
+	_, err = w.Write(50000)
+	assert.NoError(t, err)
+	rotated, err := w.Rotate()
+	assert.NoError(t, err)
+	_, err = w.Write(5)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, received(50008), 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, w.Written(), lines)
+	assert.Equal(t, rotated, files["line 50003"])
+	assert.Equal(t, w.Path(), files["line 50004"])
+}
+
+// A file far behind is caught up in parallel chunks and still delivered in order.
+func TestCollector_CatchUp(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	w, err := testkit.NewLogWriter(filepath.Join(base, "big.log"))
+	assert.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(20000)
+	assert.NoError(t, err)
+
+	sink := testkit.NewLineSink()
+	c, err := New(WithInclude(w.Path()), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithCatchUp(4096, 3),
+		WithOnLine(sink.Add))
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.True(t, sink.Wait(20000, 5*time.Second))
+	_, err = w.Write(5)
+	assert.NoError(t, err)
+	sink.WaitForLines(t, w.Written(), 5*time.Second)
+}
+
+// Stopping during a catch-up does not wait for the rest of the file to be read.
+func TestCollector_CatchUpStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	w, err := testkit.NewLogWriter(filepath.Join(t.TempDir(), "big.log"))
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(100000)
+	require.NoError(t, err)
+
+	// Throttled to 256KB/s, reading the whole file would take several seconds
+	sink := testkit.NewLineSink()
+	c, err := New(WithInclude(w.Path()), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithCatchUp(16*1024, 2),
+		WithStartupThrottle(256*1024, 0),
+		WithOnLine(sink.Add))
+	require.NoError(t, err)
+	c.Start()
+	require.True(t, sink.Wait(1, 5*time.Second))
+
+	begin := time.Now()
+	c.Stop()
+	assert.Less(t, time.Since(begin), time.Second, "Stop waited for the catch-up")
+	assert.Less(t, len(sink.Lines()), 100000)
+}
+
+func TestCollector_FingerprintOffset(t *testing.T) {
+	base := t.TempDir()
+	header := "time,level,message\n"
+	require.NoError(t, os.WriteFile(filepath.Join(base, "a.csv"), []byte(header+"1,INFO,started\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "b.csv"), []byte(header+"2,WARN,retrying\n"), 0644))
+
+	sink := testkit.NewLineSink()
+	c, err := New(WithInclude(base), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyChecksum, 8),
+		WithFingerprintOffset(int64(len(header))),
+		WithOnLine(sink.Add))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	// With the header fingerprinted both files would share an ID and one would be ignored
+	require.True(t, sink.Wait(4, 3*time.Second), "got %q", sink.Lines())
+	testkit.AssertLinesUnordered(t, sink.Lines(), []string{"time,level,message", "1,INFO,started", "time,level,message", "2,WARN,retrying"})
+}
+
+func TestCollector_Rescan(t *testing.T) {
+	base := t.TempDir()
+	sink := testkit.NewLineSink()
+	c, err := New(WithInclude(base), WithPollInterval(time.Hour),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0), WithOnLine(sink.Add))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+	require.Eventually(t, func() bool {
+		scanned, _ := c.LastScan()
+		return !scanned.IsZero()
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(base, "new.log"), []byte("created\n"), 0644))
+	c.Rescan()
+	sink.WaitForLines(t, []string{"created"}, 3*time.Second)
+}
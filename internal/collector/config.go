@@ -1,8 +1,17 @@
 package collector
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"time"
 
+	"github.com/loykin/freader/internal/clock"
+	"github.com/loykin/freader/internal/metrics"
+	"github.com/loykin/freader/internal/store"
 	"github.com/loykin/freader/internal/tailer"
 	"github.com/loykin/freader/internal/watcher"
 )
@@ -12,6 +21,60 @@ type LineEvent struct {
 	Line string
 	File string
 	Ts   time.Time
+	// Forced marks a multiline record flushed incomplete because its file was removed
+	// or the collector stopped before a following line or the timeout completed it.
+	Forced bool
+	// Repeats is set on the summary ending a run of identical records collapsed by
+	// Config.RepeatWindow: the number of copies dropped after the first.
+	Repeats int
+}
+
+// Record is one collected record delivered on Collector.Records.
+type Record = LineEvent
+
+// DefaultCatchUpReaders is the number of parallel chunk readers used when
+// Config.CatchUpReaders is 0.
+const DefaultCatchUpReaders = 4
+
+// DefaultIdleRecheckInterval is how often idle files are read when
+// Config.IdleRecheckInterval is 0.
+const DefaultIdleRecheckInterval = time.Second
+
+// DeliveryRetryDelay is how long a file waits before it is read again after
+// Config.OnLinesAckFunc failed to deliver its records.
+const DeliveryRetryDelay = time.Second
+
+// DefaultRecordsBuffer is the Collector.Records channel capacity when Config.RecordsBuffer is 0.
+const DefaultRecordsBuffer = 1024
+
+// ErrorKind classifies errors reported through Config.OnErrorFunc.
+type ErrorKind string
+
+const (
+	// ErrorKindRead reports a failure while opening or reading a tracked file.
+	ErrorKindRead ErrorKind = "read"
+	// ErrorKindFingerprintMismatch reports that a tracked file's content no longer matches
+	// its fingerprint (rotation, truncation, overwrite); the file is re-discovered on the next scan.
+	ErrorKindFingerprintMismatch ErrorKind = "fingerprint_mismatch"
+	// ErrorKindStore reports a failure of the offset store.
+	ErrorKindStore ErrorKind = "store"
+	// ErrorKindCallback reports a record callback running longer than
+	// Config.CallbackTimeout (ErrCallbackStalled).
+	ErrorKindCallback ErrorKind = "callback"
+	// ErrorKindOffset reports a stored offset failing verification before reading
+	// resumes from it (ErrOffsetMismatch); see Config.OffsetMismatch.
+	ErrorKindOffset ErrorKind = "offset"
+	// ErrorKindDelivery reports a batch Config.OnLinesAckFunc did not confirm; its
+	// records are read again.
+	ErrorKindDelivery ErrorKind = "delivery"
+)
+
+// ErrorContext describes where an error passed to Config.OnErrorFunc occurred.
+type ErrorContext struct {
+	Kind   ErrorKind
+	FileID string // empty for errors not tied to a file
+	Path   string
+	Op     string // store operation ("load", "save", "delete", "close", "maintenance", "lease"); empty otherwise
 }
 
 type Config struct {
@@ -20,17 +83,359 @@ type Config struct {
 	PollInterval        time.Duration
 	FingerprintStrategy string
 	FingerprintSize     int
-	Include             []string
+	Include             []string // directories, files or globs; "!pattern" narrows earlier entries
 	Exclude             []string
 	OnLineFunc          func(line string)
 	OnEventFunc         func(event LineEvent)
 	DBPath              string
 	StoreOffsets        bool
+	// ExcludeDirs are patterns for directories scans do not descend into, such as
+	// node_modules, .git or archived, matched against the base name or the full path.
+	// Unlike Exclude, which filters files, it saves walking large trees of no interest.
+	ExcludeDirs []string
+	// IgnoreFile, if set, names gitignore-style files (e.g. ".freaderignore") read from
+	// the include roots on every scan, so application teams can opt files out next to
+	// them without changing the collector's configuration. Their patterns are merged
+	// into Exclude; a trailing "/" prunes directories and "!" re-includes.
+	IgnoreFile string
+	// FingerprintOffset, for the checksum strategy, hashes the FingerprintSize bytes
+	// starting at this offset instead of the first ones, so files that begin with the
+	// same header (CSV header rows, banner preambles) get distinct IDs. Files shorter
+	// than FingerprintOffset+FingerprintSize wait until they have grown.
+	FingerprintOffset int64
+	// UpgradeFingerprints, for the checksum strategy, reads files too small to
+	// fingerprint right away under a provisional device-and-inode ID instead of waiting
+	// until they have grown. Once a file can be fingerprinted it is tracked under its
+	// checksum ID from the offset reached, including the offset stored by an earlier
+	// run, and OnFileRemoved/OnFileAdded report the switch.
+	UpgradeFingerprints bool
+	// ScanBudget and ScanMaxFiles bound the time a scan spends and the files it examines
+	// per PollInterval tick on enormous trees; a scan that reaches either carries on
+	// where it stopped on the next tick. Files missing from the tree are only dropped
+	// once a scan has walked all of it. 0 means no limit.
+	ScanBudget   time.Duration
+	ScanMaxFiles int
 	// Multiline optionally configures the multiline aggregator used by tailers.
-	// If nil, multiline grouping is disabled.
+	// If nil, multiline grouping is disabled. It is only a template: each file
+	// assembles its records in a copy of its own, so interleaved files never mix
+	// lines, and the value may be shared by several collectors.
+	Multiline *tailer.MultilineReader
+	// RepeatWindow, if set, collapses identical consecutive records of a file, like
+	// syslog's "last message repeated N times": the first is delivered, copies arriving
+	// within RepeatWindow of it are dropped, and once the window has passed or a
+	// different record arrives, one summary record with the same line and Repeats set
+	// to N follows. OnLineFunc and OnLineBytesFunc, which have no Repeats, receive it as
+	// RepeatSummary. 0 delivers every record.
+	RepeatWindow time.Duration
+	// MultilineRules lets files matching a pattern use their own multiline grouping, or
+	// none, instead of Multiline; the first rule matching a file wins.
+	MultilineRules []MultilineRule
+	// PriorityRules give files matching a pattern a scheduling weight, so critical logs
+	// (audit, security) are read ahead of chatty debug logs when all workers are busy.
+	// The first rule matching a file wins; other files weigh 1. While files compete for
+	// workers, a file of weight w is read about w times as often as one of weight 1.
+	PriorityRules []PriorityRule
+	// OnErrorFunc, if set, is called for read failures, fingerprint mismatches and
+	// store errors in addition to logging. It may be called from several workers
+	// concurrently and must not block.
+	OnErrorFunc func(err error, ctx ErrorContext)
+	// Logger receives log output from the collector, watcher, tailers and offset store.
+	// If nil, slog.Default() is used.
+	Logger *slog.Logger
+	// OnFileAdded, OnFileRemoved and OnFingerprintMismatch are optional lifecycle hooks
+	// for tracking the set of tailed files. OnFileAdded runs when the watcher starts
+	// tracking a file, OnFileRemoved when a file stops being tracked (deleted, excluded,
+	// rotated away or no longer fingerprintable) and OnFingerprintMismatch when a tracked
+	// file's content no longer matches its fingerprint. Hooks run synchronously on the
+	// watcher or worker goroutines and must not block.
+	OnFileAdded           func(id, path string)
+	OnFileRemoved         func(id, path string)
+	OnFingerprintMismatch func(path string)
+	// FromBeginning ignores offsets stored by previous runs, so discovered files are
+	// re-read from byte zero; the stored rows are overwritten as reading progresses.
+	// If FromBeginningPatterns is non-empty, only files whose base name or path matches
+	// one of the glob patterns are replayed; setting patterns implies FromBeginning.
+	FromBeginning         bool
+	FromBeginningPatterns []string
+	// FollowName follows files by name like tail -F: only the file currently at a
+	// path is read. A file renamed away (rotated) is dropped rather than followed to its
+	// new name, even if that name is included too; when a scan finds it there, what it
+	// still holds is read first. The file created in its place is read from the start,
+	// and so is a file truncated in place (copytruncate). Point Include at the live
+	// paths.
+	FollowName bool
+	// StartFromTime, if non-zero, skips records older than this time in files read from
+	// the beginning (no stored offset), e.g. for targeted backfills. Records are scanned
+	// in order until TimestampFunc returns the first time at or after StartFromTime; from
+	// then on every record is emitted. Records without a timestamp before that point are
+	// skipped. Requires TimestampFunc.
+	StartFromTime time.Time
+	// TimestampFunc extracts the event time of a record; ok is false when none is found.
+	TimestampFunc func(record string) (ts time.Time, ok bool)
+	// MergeWindow, if set, delivers the records of all files as one stream ordered by
+	// their TimestampFunc event time, e.g. for incident timelines. Each record is held
+	// for MergeWindow after it was read, so a record read up to MergeWindow later with
+	// an earlier event time is still delivered before it. Records without a timestamp
+	// take that of the previous record of their file. Stored offsets stay before held
+	// records, so they are read again after a crash. Requires TimestampFunc.
+	MergeWindow time.Duration
+	// RecordsBuffer is the capacity of the channel returned by Collector.Records;
+	// 0 uses DefaultRecordsBuffer.
+	RecordsBuffer int
+	// OnLinesFunc, if set, receives records in batches instead of OnLineFunc/OnEventFunc
+	// being called per line. Each worker batches independently and flushes when
+	// LinesBatchSize records (default DefaultLinesBatchSize) or LinesBatchBytes of line
+	// data (0 = unbounded) are pending, when the oldest pending record is older than
+	// LinesBatchInterval (0 = unbounded), and always at the end of each read pass, so
+	// offsets are never stored ahead of delivered records.
+	OnLinesFunc        func(lines []Record)
+	LinesBatchSize     int
+	LinesBatchBytes    int
+	LinesBatchInterval time.Duration
+	// OnLinesAckFunc is OnLinesFunc for sinks that confirm delivery, and takes precedence
+	// over it. It is called like OnLinesFunc and may hand the records on without waiting
+	// for them; the func it returns, if not nil, is called outside the callback
+	// serialization before the offsets past the records are stored and reports whether
+	// they were delivered. On an error the offsets are not stored: the file is read again
+	// from its last stored offset after DeliveryRetryDelay, and the error is passed to
+	// OnErrorFunc as ErrorKindDelivery. Not supported with MergeWindow.
+	OnLinesAckFunc func(lines []Record) (wait func() error)
+	// OnLineBytesFunc, if set, is called per record instead of OnEventFunc/OnLineFunc
+	// without converting the record to a string. b is only valid until the callback
+	// returns and must be copied to be retained. OnLinesFunc takes precedence.
+	OnLineBytesFunc func(b []byte)
+	// CallbackTimeout, if set, reports a record callback (OnLineFunc, OnEventFunc,
+	// OnLineBytesFunc or OnLinesFunc) that has been running for this long as stalled: it
+	// is logged, counted in freader_callback_stalls_total and passed to OnErrorFunc as
+	// ErrCallbackStalled. Callbacks are serialized across workers, so one hung call holds
+	// up delivery from every worker; scans and other collector state are not blocked.
+	// With SkipStalledCallbacks, records that would wait for a stalled callback are
+	// dropped instead, counted in freader_callback_skipped_records_total, and their
+	// offsets are committed as if delivered, until the callback returns.
+	CallbackTimeout      time.Duration
+	SkipStalledCallbacks bool
+	// ReadBufferSize and ChunkBufferSize tune TailReader memory use for files with very
+	// long records: the buffered read size per syscall (0 = 4KB) and the initial
+	// capacity of the pooled record buffer (0 = tailer.DefaultChunkBufferSize).
+	ReadBufferSize  int
+	ChunkBufferSize int
+	// CatchUpChunkSize, if set, speeds up the first read of a file that is at least two
+	// chunks behind its end, e.g. a multi-GB log discovered on startup: the data up to
+	// the current end is split into chunks of about this many bytes that end on a
+	// separator, read by up to CatchUpReaders goroutines in parallel (0 = 4) and
+	// delivered in file order. Files split by SeparatorRegex, SeparatorRules or
+	// LengthPrefix, grouped by multiline, or with a separator that can overlap itself
+	// (e.g. "\n\n") are read sequentially. Each reader holds one chunk in memory.
+	CatchUpChunkSize int64
+	CatchUpReaders   int
+	// StartupReadRateLimit and StartupStagger spread the first pass over the files found
+	// by the first scan, so starting on a node with many large historical logs does not
+	// saturate disk IO and the sink at once. StartupReadRateLimit caps the combined read
+	// rate of those files, in bytes per second, until a read of each has reached its
+	// end. StartupStagger hands them to workers one at a time, this far apart, in scan
+	// order. Files found later are not affected. 0 disables either.
+	StartupReadRateLimit int64
+	StartupStagger       time.Duration
+	// IdleAfter, if set, stops reading files that have had no new data for this long on
+	// every pass of the workers: they are only read every IdleRecheckInterval (0 = 1s)
+	// until they grow again, so thousands of idle files do not cost a read each that
+	// only hits their end. New data in an idle file is picked up within
+	// IdleRecheckInterval; a multiline timeout delivers its record right away.
+	IdleAfter           time.Duration
+	IdleRecheckInterval time.Duration
+	// SeparatorRegex, if set, splits records on matches of this regular expression
+	// (e.g. `\r?\n` for mixed LF/CRLF files) instead of Separator. Offsets advance by
+	// the actual match length. The pattern must not match the empty string, and a match
+	// is taken as soon as it is complete, so it should not be able to grow with more
+	// input. Separator is still used by the checksumSeparator fingerprint.
+	SeparatorRegex string
+	// SeparatorRules lets files matching a pattern use a list of alternative separators
+	// instead of Separator/SeparatorRegex; the first rule matching a file wins.
+	SeparatorRules []SeparatorRule
+	// SeparatorAutoDetect, if set, chooses the separator of each file from the line
+	// endings in its first 64KB when it starts being tracked: "\r\n" if all are CRLF,
+	// "\n" if none is, and `\r?\n` if the sample holds both or no line ending yet. The
+	// choice is logged and reported in TrackedFile.Separator. Files matching a
+	// SeparatorRules pattern keep their rule. Separator must be "\n" or "\r\n" (it is
+	// still used by the checksumSeparator fingerprint), and SeparatorRegex unset.
+	SeparatorAutoDetect bool
+	// LengthPrefix, if set, reads length-prefixed binary records (binary journals,
+	// protobuf-delimited files) instead of separator-delimited text; separator settings
+	// are then ignored. Pair it with OnLineBytesFunc to receive the raw bytes, and with
+	// a checksum or deviceAndInode fingerprint. It cannot be combined with Multiline or
+	// MultilineRules, which group text lines.
+	LengthPrefix *tailer.LengthPrefix
+	// NetworkFS enables a safety mode for NFS/SMB mounts, where inode numbers are not
+	// stable and attribute caches can briefly report missing files, short sizes or stale
+	// content. It forces checksum fingerprinting (deviceAndInode is replaced by checksum
+	// with FingerprintSize defaulting to watcher.DefaultFingerprintStrategySize), and a
+	// file is only dropped after NetworkFSRetries consecutive scans without it or reads
+	// failing its fingerprint (0 = DefaultNetworkFSRetries). Until then it keeps its
+	// offset and is retried, instead of being re-discovered and re-read from the start.
+	NetworkFS        bool
+	NetworkFSRetries int
+	// SkipLocked quietly skips files another process holds open exclusively or locked
+	// (Windows sharing and lock violations) until it lets go: they are logged at debug
+	// level only and tried again on the next scan or read. Otherwise such files are
+	// reported once and retried with back-off like unreadable ones. Either way an open
+	// failing with a sharing violation is first retried for about a tenth of a second,
+	// and lock failures are counted in the freader_locked_total metric.
+	SkipLocked bool
+	// HoldDeleted, if positive, keeps every tracked file open between reads, so a file
+	// deleted while its writer still holds it open is read to its end: when a scan no
+	// longer finds it and nothing is at its path, it is read on through the open file
+	// and only released, closing it, once reads have found no new data for HoldDeleted.
+	// Until then the deleted file keeps taking disk space; Stats.Deleted lists such
+	// files. 0 drops a deleted file on the next scan, losing what was not read yet. It
+	// takes a file descriptor per tracked file and is not supported on Windows, where
+	// files held open cannot be deleted.
+	HoldDeleted time.Duration
+	// KeepDuplicates reads a file reachable at several watched paths (hard links, bind
+	// mounts, symlinks, or copies with the same checksum fingerprint) once per path.
+	// By default such a file is read once, at the path it was found at first, and its
+	// other paths are skipped as duplicates, so its records are not delivered twice;
+	// they are logged and listed in Collector.Decisions. With KeepDuplicates each other
+	// path is tracked under an ID of its own, the file's ID with "@" and the path
+	// appended, and a file renamed to such a path is read again from the start.
+	KeepDuplicates bool
+	// OffsetMismatch is what happens to a file whose stored offset fails verification
+	// before reading resumes from it, on discovery or when a standby takes over: the
+	// offset lies past the end of the file, or the file's fingerprint no longer matches
+	// the identity the offset was stored for. OffsetMismatchStart ("", the default)
+	// reads it from the start, OffsetMismatchEnd from its current end, and
+	// OffsetMismatchQuarantine leaves it unread. Mismatches are reported as
+	// ErrorKindOffset and counted in freader_offset_mismatches_total.
+	OffsetMismatch string
+	// ReadOnlyOffsets, with StoreOffsets, resumes files from their stored offsets but
+	// never writes or deletes any, so a trial run next to the instance that owns the
+	// store (a dry run validating a configuration change, say) leaves its positions
+	// alone. The store's lease is not taken, and it cannot be combined with
+	// ShardDiscovery, which registers the collector in the store.
+	ReadOnlyOffsets bool
+	// StoreMaintenanceInterval, if positive, checkpoints the offset store's WAL into the
+	// database (truncating the -wal file) and vacuums it at this interval, keeping a
+	// long-running collector.db from growing with churned rows. 0 disables it.
+	StoreMaintenanceInterval time.Duration
+	// RebuildCorruptStore recovers from a collector.db that fails its integrity check on
+	// startup instead of refusing to start: readable offsets are copied into a new
+	// database and the damaged file is kept next to it as <DBPath>.corrupt-<time>.
+	// Files whose offsets were lost are read again from the start.
+	RebuildCorruptStore bool
+	// LeaseTTL, if positive, guards DBPath against a second collector process: the
+	// collector takes a lease row in the store on creation, renews it every LeaseTTL/3
+	// and releases it on Stop. NewCollector fails with store.ErrLeaseHeld while another
+	// instance holds an unexpired lease; a lease that was not renewed within LeaseTTL
+	// (the holder crashed or hung) is taken over. A collector whose lease was taken over
+	// stops saving offsets and reports store.ErrLeaseLost. 0 disables the lease.
+	LeaseTTL time.Duration
+	// Standby runs the collector as one of several redundant instances of which only the
+	// lease holder (the leader) reads and commits offsets. Instead of failing while
+	// another instance holds the lease, NewCollector returns a collector standing by: it
+	// tracks files but reads nothing until it takes the lease over, trying every
+	// LeaseTTL/3. The leader's offsets are then loaded and reading continues from them,
+	// within LeaseTTL/3 of a clean shutdown of the leader or LeaseTTL*4/3 of a crash. A
+	// leader that loses the lease, or cannot renew it in time, stands by again. Needs
+	// LeaseTTL and a lease: the offset store's, or Leaser.
+	Standby bool
+	// Leaser, if set, holds the lease instead of the offset store, e.g. a Kubernetes
+	// Lease (see the kubelease package), for instances that do not share a store.
+	Leaser store.Leaser
+	// PostgresDSN, if set, keeps offsets in the PostgreSQL database at this DSN instead of
+	// the SQLite database at DBPath, so that a fleet of collectors or an HA pair can share
+	// them; see store.NewPostgresStore. The application must register the database/sql
+	// driver named PostgresDriver ("pgx" when empty), e.g. by importing
+	// github.com/jackc/pgx/v5/stdlib. PostgresNamespace separates collectors reading
+	// different files, such as one per host; the lease is then held per namespace.
+	// StoreMaintenanceInterval and RebuildCorruptStore apply to SQLite only.
+	PostgresDSN       string
+	PostgresDriver    string
+	PostgresNamespace string
+	// InstanceID names this collector in the lease and, with ShardDiscovery, among the
+	// shard members; empty uses "<hostname>-<pid>-<random>".
+	InstanceID string
+	// ShardIndex and ShardCount split the files matched by Include between ShardCount
+	// collectors, e.g. pods of a StatefulSet on a shared volume: each reads only the
+	// files whose identity hashes to its ShardIndex (rendezvous hashing, so changing
+	// ShardCount moves only the files of the shards added or removed). 0 disables
+	// sharding.
+	ShardIndex int
+	ShardCount int
+	// ShardDiscovery shards files between the collectors registered in the offset store
+	// instead of a fixed ShardCount: each heartbeats its InstanceID every
+	// ShardMemberTTL/3 and the files are split between the members seen alive, so an
+	// instance joining or leaving takes or hands over its share within ShardMemberTTL.
+	// Needs StoreOffsets with a store shared by the instances (PostgresDSN, or one
+	// SQLite DBPath on a single host).
+	//
+	// A sharded collector hands a file over by committing its offset and dropping it;
+	// the new owner reads on from the stored offset, so records read but not yet
+	// committed at the handover may be delivered twice. Sharded collectors do not take
+	// the exclusive lease (LeaseTTL).
+	ShardDiscovery bool
+	// ShardMemberTTL is how long a member stays in the shard set without heartbeating;
+	// DefaultShardMemberTTL when 0.
+	ShardMemberTTL time.Duration
+	// Metrics receives this collector's Prometheus metrics. If nil, the process-wide set
+	// registered by metrics.Register is used; give each collector in a process its own
+	// set, registered with distinguishing constant labels, to tell them apart.
+	Metrics *metrics.Set
+	// TraceScans records, for every scan, each file examined and why it was included,
+	// excluded by the filters or skipped (too small, not enough separators, unreadable).
+	// The last watcher.DefaultTraceHistory entries are returned by Collector.Trace and
+	// served in DebugState. Meant for debugging; it costs an entry per file per scan.
+	TraceScans bool
+	// RetainLastN keeps the last N records delivered in memory, queryable with
+	// Collector.Recent and RecentHandler, to inspect current traffic without a sink.
+	// 0 keeps none.
+	RetainLastN int
+	// Clock drives the poll ticker, the idle back-off of workers, the Multiline timeout
+	// (unless Multiline.Clock is set), OnLinesFunc batch ages, store maintenance and
+	// lease renewal, and record timestamps. nil uses the real clock; tests can pass a
+	// fake one (see pkg/testkit) to step through them without sleeping.
+	Clock clock.Clock
+}
+
+// DefaultLeaseTTL is the Config.LeaseTTL set by Default.
+const DefaultLeaseTTL = 30 * time.Second
+
+// DefaultShardMemberTTL is the Config.ShardMemberTTL used when it is 0.
+const DefaultShardMemberTTL = 15 * time.Second
+
+// DefaultStoreMaintenanceInterval is the Config.StoreMaintenanceInterval set by Default.
+const DefaultStoreMaintenanceInterval = time.Hour
+
+// DefaultNetworkFSRetries is the Config.NetworkFSRetries used when it is 0.
+const DefaultNetworkFSRetries = 3
+
+// SeparatorRule splits files matching Pattern on any of Separators. A record ends at
+// the earliest separator found; when several start at the same byte the one listed
+// first wins, so list "\r\n" before "\n".
+type SeparatorRule struct {
+	// Pattern is a glob matched against the file's base name or full path; empty
+	// matches every file.
+	Pattern    string
+	Separators []string
+}
+
+// MultilineRule groups the records of files matching Pattern with Multiline. A nil
+// Multiline disables grouping for them, e.g. for access logs next to application logs.
+type MultilineRule struct {
+	// Pattern is a glob matched against the file's base name or full path; empty
+	// matches every file.
+	Pattern   string
 	Multiline *tailer.MultilineReader
 }
 
+// PriorityRule gives files matching Pattern the scheduling Weight; see
+// Config.PriorityRules.
+type PriorityRule struct {
+	// Pattern is a glob matched against the file's base name or full path; empty
+	// matches every file.
+	Pattern string
+	// Weight is between 1 and 1<<20.
+	Weight int
+}
+
 func (c *Config) Default() {
 	c.WorkerCount = 1
 	c.PollInterval = 100 * time.Millisecond
@@ -38,6 +443,8 @@ func (c *Config) Default() {
 	c.FingerprintStrategy = watcher.FingerprintStrategyDeviceAndInode
 	c.DBPath = "collector.db"
 	c.StoreOffsets = true
+	c.StoreMaintenanceInterval = DefaultStoreMaintenanceInterval
+	c.LeaseTTL = DefaultLeaseTTL
 }
 
 func (c *Config) SetDefaultFingerprint() {
@@ -59,16 +466,246 @@ func (c *Config) Validate() error {
 			return err
 		}
 	}
+	if err := validateMultilineRules(c.MultilineRules); err != nil {
+		return err
+	}
+	if err := validatePriorityRules(c.PriorityRules); err != nil {
+		return err
+	}
+	if err := c.validateStartFromTime(); err != nil {
+		return err
+	}
+	if err := c.validateMergeWindow(); err != nil {
+		return err
+	}
+	if c.ReadBufferSize < 0 || c.ChunkBufferSize < 0 {
+		return errors.New("read and chunk buffer sizes must not be negative")
+	}
+	if c.RepeatWindow < 0 {
+		return errors.New("repeat window must not be negative")
+	}
+	if c.CallbackTimeout < 0 {
+		return errors.New("callback timeout must not be negative")
+	}
+	if c.SkipStalledCallbacks && c.CallbackTimeout == 0 {
+		return errors.New("skipping stalled callbacks requires a callback timeout")
+	}
+	if c.CatchUpChunkSize < 0 || c.CatchUpReaders < 0 {
+		return errors.New("catch-up chunk size and readers must not be negative")
+	}
+	if c.StartupReadRateLimit < 0 || c.StartupStagger < 0 {
+		return errors.New("startup read rate limit and stagger must not be negative")
+	}
+	if c.IdleAfter < 0 || c.IdleRecheckInterval < 0 {
+		return errors.New("idle after and recheck interval must not be negative")
+	}
+	if c.NetworkFSRetries < 0 {
+		return errors.New("network fs retries must not be negative")
+	}
+	if c.HoldDeleted < 0 {
+		return errors.New("hold deleted must not be negative")
+	}
+	if c.HoldDeleted > 0 && runtime.GOOS == "windows" {
+		return errors.New("hold deleted is not supported on Windows")
+	}
+	if c.StoreMaintenanceInterval < 0 {
+		return errors.New("store maintenance interval must not be negative")
+	}
+	if c.LeaseTTL < 0 {
+		return errors.New("lease ttl must not be negative")
+	}
+	if (c.Standby || c.Leaser != nil) && c.LeaseTTL == 0 {
+		return errors.New("standby and a leaser require a lease ttl")
+	}
+	if c.RetainLastN < 0 {
+		return errors.New("retain last n must not be negative")
+	}
+	switch c.OffsetMismatch {
+	case "", OffsetMismatchStart, OffsetMismatchEnd, OffsetMismatchQuarantine:
+	default:
+		return fmt.Errorf("unknown offset mismatch policy %q (want %s, %s or %s)", c.OffsetMismatch,
+			OffsetMismatchStart, OffsetMismatchEnd, OffsetMismatchQuarantine)
+	}
+	if err := c.validateSharding(); err != nil {
+		return err
+	}
+	if c.SeparatorRegex != "" {
+		if _, err := tailer.CompileSeparatorRegex(c.SeparatorRegex); err != nil {
+			return err
+		}
+	}
+	if _, err := compileSeparatorRules(c.SeparatorRules); err != nil {
+		return err
+	}
+	if err := c.validateSeparatorAutoDetect(); err != nil {
+		return err
+	}
+	if err := c.validateLengthPrefix(); err != nil {
+		return err
+	}
 	// Build a watcher config to reuse its validation rules
+	eff := *c
+	eff.applyNetworkFS()
 	wc := watcher.Config{
 		PollInterval:        c.PollInterval,
-		FingerprintStrategy: c.FingerprintStrategy,
-		FingerprintSize:     c.FingerprintSize,
+		FingerprintStrategy: eff.FingerprintStrategy,
+		FingerprintSize:     eff.FingerprintSize,
 		Include:             c.Include,
 		Exclude:             c.Exclude,
+		ExcludeDirs:         c.ExcludeDirs,
+		IgnoreFile:          c.IgnoreFile,
+		FingerprintOffset:   c.FingerprintOffset,
+		ProvisionalIDs:      eff.UpgradeFingerprints,
+		KeepDuplicates:      c.KeepDuplicates,
+		ScanBudget:          c.ScanBudget,
+		ScanMaxFiles:        c.ScanMaxFiles,
 		FileTracker:         nil, // set at runtime by NewCollector
 		// For checksumSeparator strategy, watcher expects FingerprintSeparator to be the record separator
 		FingerprintSeparator: c.Separator,
 	}
 	return wc.Validate()
 }
+
+// applyNetworkFS applies the settings implied by NetworkFS.
+func (c *Config) applyNetworkFS() {
+	if !c.NetworkFS {
+		return
+	}
+	if c.FingerprintStrategy == watcher.FingerprintStrategyDeviceAndInode || c.FingerprintStrategy == "" {
+		c.FingerprintStrategy = watcher.FingerprintStrategyChecksum
+		if c.FingerprintSize <= 0 {
+			c.FingerprintSize = watcher.DefaultFingerprintStrategySize
+		}
+	}
+	if c.NetworkFSRetries == 0 {
+		c.NetworkFSRetries = DefaultNetworkFSRetries
+	}
+}
+
+// validateMultilineRules checks the pattern and multiline settings of each rule.
+func validateMultilineRules(rules []MultilineRule) error {
+	for _, rule := range rules {
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("multiline rule %q: %w", rule.Pattern, err)
+		}
+		if rule.Multiline == nil {
+			continue
+		}
+		if err := rule.Multiline.Validate(); err != nil {
+			return fmt.Errorf("multiline rule %q: %w", rule.Pattern, err)
+		}
+	}
+	return nil
+}
+
+func validatePriorityRules(rules []PriorityRule) error {
+	for _, rule := range rules {
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("priority rule %q: %w", rule.Pattern, err)
+		}
+		if rule.Weight < 1 || rule.Weight > strideUnit {
+			// A file of weight above strideUnit would advance by no pass at all
+			return fmt.Errorf("priority rule %q: weight must be between 1 and %d", rule.Pattern, strideUnit)
+		}
+	}
+	return nil
+}
+
+// compileSeparatorRules compiles the separators of each rule in order.
+func compileSeparatorRules(rules []SeparatorRule) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("separator rule %q: %w", rule.Pattern, err)
+		}
+		re, err := tailer.AlternativeSeparatorsRegex(rule.Separators)
+		if err != nil {
+			return nil, fmt.Errorf("separator rule %q: %w", rule.Pattern, err)
+		}
+		res[i] = re
+	}
+	return res, nil
+}
+
+func (c *Config) validateMergeWindow() error {
+	if c.MergeWindow < 0 {
+		return errors.New("merge window must not be negative")
+	}
+	if c.MergeWindow > 0 && c.TimestampFunc == nil {
+		return errors.New("merge window requires a timestamp function")
+	}
+	if c.MergeWindow > 0 && c.OnLinesAckFunc != nil {
+		return errors.New("merge window does not support acknowledged batches")
+	}
+	return nil
+}
+
+func (c *Config) validateStartFromTime() error {
+	if !c.StartFromTime.IsZero() && c.TimestampFunc == nil {
+		return errors.New("start-from-time requires a timestamp function")
+	}
+	return nil
+}
+
+// validateSharding checks the ShardIndex, ShardCount and ShardDiscovery settings.
+func (c *Config) validateSharding() error {
+	if c.ShardIndex < 0 || c.ShardCount < 0 || c.ShardMemberTTL < 0 {
+		return errors.New("shard index, count and member ttl must not be negative")
+	}
+	if c.ShardCount > 0 && c.ShardIndex >= c.ShardCount {
+		return fmt.Errorf("shard index %d must be below the shard count %d", c.ShardIndex, c.ShardCount)
+	}
+	if c.ShardIndex > 0 && c.ShardCount == 0 {
+		return errors.New("shard index requires a shard count")
+	}
+	if c.ShardDiscovery && c.ShardCount > 0 {
+		return errors.New("shard discovery and a fixed shard count are mutually exclusive")
+	}
+	if c.ShardDiscovery && !c.StoreOffsets {
+		return errors.New("shard discovery requires an offset store")
+	}
+	if c.ShardDiscovery && c.ReadOnlyOffsets {
+		return errors.New("shard discovery cannot be combined with read-only offsets")
+	}
+	if c.sharded() && (c.Standby || c.Leaser != nil) {
+		return errors.New("sharding cannot be combined with standby or a leaser")
+	}
+	return nil
+}
+
+// sharded reports whether the files are split between several collectors.
+func (c *Config) sharded() bool {
+	return c.ShardCount > 0 || c.ShardDiscovery
+}
+
+// validateLengthPrefix checks the LengthPrefix layout and that it is not combined with
+// multiline grouping, which would deliver a partial binary record as a line.
+func (c *Config) validateLengthPrefix() error {
+	if c.LengthPrefix == nil {
+		return nil
+	}
+	switch {
+	case c.Multiline != nil:
+		return errors.New("multiline does not apply to length-prefixed records")
+	case len(c.MultilineRules) > 0:
+		return errors.New("multiline rules do not apply to length-prefixed records")
+	}
+	return c.LengthPrefix.Validate()
+}
+
+// validateSeparatorAutoDetect checks that SeparatorAutoDetect is not combined with
+// settings it would override.
+func (c *Config) validateSeparatorAutoDetect() error {
+	if !c.SeparatorAutoDetect {
+		return nil
+	}
+	switch {
+	case c.SeparatorRegex != "":
+		return errors.New("separator auto-detection and a separator regex are mutually exclusive")
+	case c.LengthPrefix != nil:
+		return errors.New("separator auto-detection does not apply to length-prefixed records")
+	case c.Separator != "" && c.Separator != "\n" && c.Separator != "\r\n":
+		return fmt.Errorf("separator auto-detection chooses between \\n and \\r\\n, not separator %q", c.Separator)
+	}
+	return nil
+}
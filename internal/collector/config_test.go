@@ -105,3 +105,62 @@ func TestConfigValidate_Multiline_ValidationPropagation(t *testing.T) {
 		t.Fatalf("Validate() should succeed with valid multiline: %v", err)
 	}
 }
+
+func TestConfigValidate_MultilineRules(t *testing.T) {
+	c := Config{}
+	c.Default()
+
+	c.MultilineRules = []MultilineRule{{Pattern: "app*.log", Multiline: &tailer.MultilineReader{Mode: "continueThrough"}}}
+	if err := c.Validate(); err == nil {
+		t.Fatal("Validate() should reject a rule with an invalid multiline configuration")
+	}
+	c.MultilineRules = []MultilineRule{{Pattern: "[", Multiline: nil}}
+	if err := c.Validate(); err == nil {
+		t.Fatal("Validate() should reject a malformed rule pattern")
+	}
+
+	// A nil Multiline disables grouping for matching files
+	c.MultilineRules = []MultilineRule{{Pattern: "access*.log"}}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() should accept a rule without multiline: %v", err)
+	}
+}
+
+func TestConfigValidate_SeparatorRegex(t *testing.T) {
+	c := Config{}
+	c.Default()
+
+	for _, expr := range []string{"(", `\n*`} {
+		c.SeparatorRegex = expr
+		if err := c.Validate(); err == nil {
+			t.Fatalf("Validate() should reject separator regex %q", expr)
+		}
+	}
+
+	c.SeparatorRegex = `\r?\n`
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() should accept a valid separator regex: %v", err)
+	}
+}
+
+func TestConfig_NetworkFS(t *testing.T) {
+	c := Config{}
+	c.Default()
+	c.NetworkFS = true
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() should accept NetworkFS with the default strategy: %v", err)
+	}
+
+	c.applyNetworkFS()
+	if c.FingerprintStrategy != watcher.FingerprintStrategyChecksum || c.FingerprintSize != watcher.DefaultFingerprintStrategySize {
+		t.Fatalf("NetworkFS should force checksum fingerprinting, got %s/%d", c.FingerprintStrategy, c.FingerprintSize)
+	}
+	if c.NetworkFSRetries != DefaultNetworkFSRetries {
+		t.Fatalf("NetworkFSRetries = %d, want %d", c.NetworkFSRetries, DefaultNetworkFSRetries)
+	}
+
+	c.NetworkFSRetries = -1
+	if err := c.Validate(); err == nil {
+		t.Fatal("Validate() should reject negative NetworkFSRetries")
+	}
+}
@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// Decision records why the collector started, stopped or failed to track a path.
+type Decision = watcher.Decision
+
+// DebugState is the JSON document served by DebugHandler: the collector's filters,
+// scheduler state, tracked files and its most recent tracking decisions.
+type DebugState struct {
+	Time                time.Time         `json:"time"`
+	InstanceID          string            `json:"instance_id,omitempty"`
+	Standby             bool              `json:"standby,omitempty"`
+	ShardMembers        []string          `json:"shard_members,omitempty"`
+	Include             []string          `json:"include"`
+	Exclude             []string          `json:"exclude"`
+	FingerprintStrategy string            `json:"fingerprint_strategy"`
+	FingerprintSize     int               `json:"fingerprint_size,omitempty"`
+	FingerprintOffset   int64             `json:"fingerprint_offset,omitempty"`
+	LinesRead           int64             `json:"lines_read"`
+	BytesRead           int64             `json:"bytes_read"`
+	LastScanAt          time.Time         `json:"last_scan_at"`
+	LastScanDuration    string            `json:"last_scan_duration"`
+	Scheduler           DebugScheduler    `json:"scheduler"`
+	Files               []DebugFile       `json:"files"`
+	Unreadable          []DebugUnreadable `json:"unreadable"`
+	Decisions           []Decision        `json:"decisions"`       // oldest first
+	Trace               []Decision        `json:"trace,omitempty"` // with Config.TraceScans, oldest first
+}
+
+// DebugScheduler describes the read scheduler in a DebugState.
+type DebugScheduler struct {
+	QueueDepth  int  `json:"queue_depth"`
+	ActiveReads int  `json:"active_reads"`
+	IdleFiles   int  `json:"idle_files"`
+	Paused      bool `json:"paused"`
+}
+
+// DebugFile describes a tracked file in a DebugState, like TrackedFile; ID is its
+// fingerprint.
+type DebugFile struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	Strategy  string    `json:"strategy"`
+	Offset    int64     `json:"offset"`
+	Position  int64     `json:"position"`
+	Size      int64     `json:"size"`
+	Lag       int64     `json:"lag"`
+	LastRead  time.Time `json:"last_read"`
+	Separator string    `json:"separator,omitempty"` // with Config.SeparatorAutoDetect
+}
+
+// DebugUnreadable describes a path failing with permission errors in a DebugState.
+type DebugUnreadable struct {
+	Path      string    `json:"path"`
+	Error     string    `json:"error"`
+	Since     time.Time `json:"since"`
+	Attempts  int       `json:"attempts"`
+	NextRetry time.Time `json:"next_retry"`
+}
+
+// Decisions returns the most recent files added, removed or skipped, oldest first.
+func (c *Collector) Decisions() []Decision {
+	return c.decisions.List()
+}
+
+// Trace returns the recent per-scan evaluation of every scanned file, oldest first, or
+// nil unless Config.TraceScans is set.
+func (c *Collector) Trace() []Decision {
+	return c.trace.List()
+}
+
+// Explain reports whether the file at path is tracked and, if not, why; see
+// watcher.Watcher.Explain.
+func (c *Collector) Explain(path string) Decision {
+	return c.watcher.Explain(path)
+}
+
+// DebugState returns a snapshot of the collector for troubleshooting. Like Stats, it
+// stats every tracked file.
+func (c *Collector) DebugState() DebugState {
+	files := c.TrackedFiles()
+	st := c.stats(files)
+	ds := DebugState{
+		Time:                c.clock.Now(),
+		InstanceID:          c.instanceID,
+		Standby:             c.standby.Load(),
+		ShardMembers:        c.ShardMembers(),
+		Include:             c.watcher.Include(),
+		Exclude:             c.watcher.Exclude(),
+		FingerprintStrategy: c.cfg.FingerprintStrategy,
+		LinesRead:           st.LinesRead,
+		BytesRead:           st.BytesRead,
+		LastScanAt:          st.LastScanAt,
+		LastScanDuration:    st.LastScanDuration.String(),
+		Scheduler: DebugScheduler{
+			QueueDepth:  st.QueueDepth,
+			ActiveReads: st.ActiveReads,
+			IdleFiles:   st.IdleFiles,
+			Paused:      st.Paused,
+		},
+		Files:      make([]DebugFile, 0, len(st.Files)),
+		Unreadable: make([]DebugUnreadable, 0, len(st.Unreadable)),
+		Decisions:  c.Decisions(),
+		Trace:      c.Trace(),
+	}
+	if ds.FingerprintStrategy != watcher.FingerprintStrategyDeviceAndInode {
+		ds.FingerprintSize = c.cfg.FingerprintSize
+		ds.FingerprintOffset = c.cfg.FingerprintOffset
+	}
+	for _, f := range files {
+		ds.Files = append(ds.Files, DebugFile(f))
+	}
+	for _, u := range st.Unreadable {
+		du := DebugUnreadable{Path: u.Path, Since: u.Since, Attempts: u.Attempts, NextRetry: u.NextRetry}
+		if u.Err != nil {
+			du.Error = u.Err.Error()
+		}
+		ds.Unreadable = append(ds.Unreadable, du)
+	}
+	if ds.Decisions == nil {
+		ds.Decisions = []Decision{}
+	}
+	return ds
+}
+
+// DebugHandler serves DebugState as indented JSON.
+func (c *Collector) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(c.DebugState())
+	})
+}
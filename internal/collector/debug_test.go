@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_DebugHandler(t *testing.T) {
+	tempDir := t.TempDir()
+	a := filepath.Join(tempDir, "a.log")
+	require.NoError(t, os.WriteFile(a, []byte("one\ntwo\n"), 0644))
+
+	c, err := NewCollector(Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     3,
+		OnLineFunc:          func(string) {},
+	})
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool { return c.Stats().LinesRead == 2 }, 3*time.Second, 20*time.Millisecond)
+	require.NoError(t, os.Remove(a))
+	assert.Eventually(t, func() bool { return len(c.Decisions()) == 2 }, 3*time.Second, 20*time.Millisecond)
+
+	srv := httptest.NewServer(c.DebugHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var st DebugState
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	assert.Equal(t, []string{tempDir}, st.Include)
+	assert.Equal(t, watcher.FingerprintStrategyChecksum, st.FingerprintStrategy)
+	assert.Equal(t, int64(2), st.LinesRead)
+	assert.Empty(t, st.Files)
+	if assert.Len(t, st.Decisions, 2) {
+		assert.Equal(t, watcher.DecisionAdded, st.Decisions[0].Action)
+		assert.Equal(t, a, st.Decisions[0].Path)
+		assert.NotEmpty(t, st.Decisions[0].FileID)
+		assert.Equal(t, watcher.DecisionRemoved, st.Decisions[1].Action)
+		assert.Equal(t, st.Decisions[0].FileID, st.Decisions[1].FileID)
+		assert.NotEmpty(t, st.Decisions[1].Reason)
+	}
+}
+
+func TestCollector_TraceScans(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.log"), []byte("one\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.tmp"), []byte("one\n"), 0644))
+
+	c, err := New(WithInclude(tempDir), WithExclude("*.tmp"), WithPollInterval(20*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0), WithScanTrace(), WithOnLine(func(string) {}))
+	require.NoError(t, err)
+	assert.Nil(t, c.Trace())
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool { return len(c.Trace()) >= 2 }, 3*time.Second, 20*time.Millisecond)
+	actions := map[string]string{}
+	for _, d := range c.DebugState().Trace {
+		actions[filepath.Base(d.Path)] = d.Action
+	}
+	assert.Equal(t, watcher.DecisionIncluded, actions["a.log"])
+	assert.Equal(t, watcher.DecisionExcluded, actions["a.tmp"])
+	assert.Equal(t, watcher.DecisionExcluded, c.Explain(filepath.Join(tempDir, "a.tmp")).Action)
+}
@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/loykin/freader/internal/tailer"
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// DeletedFile describes a file deleted while open that Config.HoldDeleted still reads
+// through the file kept open, as listed in Stats.Deleted.
+type DeletedFile struct {
+	ID     string
+	Path   string    // where the file was before it was deleted
+	Since  time.Time // when a scan found it gone
+	Offset int64
+	Size   int64 // disk space the file still takes; -1 if unknown
+}
+
+// deletedFile is a file read on with cfg.HoldDeleted after it was deleted.
+type deletedFile struct {
+	path   string
+	since  time.Time
+	offset atomic.Int64 // updated by the worker reading it
+	size   atomic.Int64
+}
+
+// holdDeleted keeps reading the file id, which the watcher is about to drop, through
+// the file its reader kept open when cfg.HoldDeleted is set and nothing is at its path
+// any more. It reports whether it did; otherwise the caller untracks id.
+func (c *Collector) holdDeleted(id string) bool {
+	if c.cfg.HoldDeleted <= 0 {
+		return false
+	}
+	path := c.pathOf(id)
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		return false
+	}
+	d := &deletedFile{path: path, since: c.clock.Now()}
+	d.size.Store(-1)
+	if fileInfo := c.fileManager.Get(id); fileInfo != nil {
+		d.offset.Store(fileInfo.Offset)
+	}
+	c.deleted.Store(id, d)
+	c.metrics.SetDeletedFiles(c.deletedCount())
+	c.logger.Info("file deleted while open, reading it to its end", "file", id, "path", path)
+	c.wakeWorker()
+	return true
+}
+
+// releaseDeleted untracks the file of fileTail, read on with cfg.HoldDeleted after it
+// was deleted, once a read of it failed with err or found no new data for
+// cfg.HoldDeleted; active tells whether this read found data. It reports whether it
+// did. Called by the worker reading it, which delivers what it still holds.
+func (c *Collector) releaseDeleted(fileTail *tailer.TailReader, active bool, err error) bool {
+	v, ok := c.deleted.Load(fileTail.FileId)
+	if !ok {
+		return false
+	}
+	d := v.(*deletedFile)
+	d.offset.Store(fileTail.Offset)
+	d.size.Store(fileTail.HeldSize())
+	var reason string
+	if err != nil {
+		reason = "deleted file no longer readable: " + err.Error()
+	} else {
+		last := d.since
+		if at, ok := c.lastRead.Load(fileTail.FileId); ok && at.(time.Time).After(last) {
+			last = at.(time.Time)
+		}
+		if active || c.clock.Now().Sub(last) < c.cfg.HoldDeleted {
+			return false
+		}
+		reason = "deleted file idle for " + c.cfg.HoldDeleted.String()
+	}
+	c.logger.Info("releasing deleted file", "file", fileTail.FileId, "path", d.path, "offset", fileTail.Offset, "reason", reason)
+	c.decisions.Record(watcher.Decision{Action: watcher.DecisionRemoved, Path: d.path, FileID: fileTail.FileId, Reason: reason})
+	c.untrack(fileTail.FileId, true)
+	return true
+}
+
+// forgetDeleted stops treating id as deleted, once it is untracked or found again.
+func (c *Collector) forgetDeleted(id string) {
+	if _, ok := c.deleted.LoadAndDelete(id); ok {
+		c.metrics.SetDeletedFiles(c.deletedCount())
+	}
+}
+
+func (c *Collector) deletedCount() int {
+	n := 0
+	c.deleted.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// deletedFiles returns the files read on with cfg.HoldDeleted, sorted by path.
+func (c *Collector) deletedFiles() []DeletedFile {
+	var files []DeletedFile
+	c.deleted.Range(func(id, v any) bool {
+		d := v.(*deletedFile)
+		files = append(files, DeletedFile{ID: id.(string), Path: d.path, Since: d.since, Offset: d.offset.Load(), Size: d.size.Load()})
+		return true
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
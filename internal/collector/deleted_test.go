@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_HoldDeleted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("files held open cannot be deleted on Windows")
+	}
+	base := t.TempDir()
+	w, err := testkit.NewLogWriter(filepath.Join(base, "app.log"))
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(3)
+	require.NoError(t, err)
+
+	sink := testkit.NewLineSink()
+	c, err := New(WithInclude(filepath.Join(base, "*.log")), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithHoldDeleted(500*time.Millisecond),
+		WithOnLine(sink.Add))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+
+	// Deleted while the writer still holds it open
+	require.NoError(t, os.Remove(w.Path()))
+	assert.Eventually(t, func() bool {
+		st := c.Stats()
+		return len(st.Files) == 0 && len(st.Deleted) == 1
+	}, 3*time.Second, 20*time.Millisecond, "deleted file not held")
+
+	_, err = w.Write(2)
+	require.NoError(t, err)
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+	deleted := c.Stats().Deleted
+	require.Len(t, deleted, 1)
+	assert.Equal(t, w.Path(), deleted[0].Path)
+	assert.Positive(t, deleted[0].Size)
+
+	// Released once idle
+	assert.Eventually(t, func() bool { return len(c.Stats().Deleted) == 0 }, 3*time.Second, 20*time.Millisecond,
+		"idle deleted file not released")
+	testkit.AssertLines(t, sink.Lines(), w.Written())
+}
+
+func TestConfig_HoldDeletedValidation(t *testing.T) {
+	cfg := Config{HoldDeleted: -time.Second}
+	assert.ErrorContains(t, cfg.Validate(), "hold deleted")
+
+	_, err := New(WithHoldDeleted(-time.Second))
+	assert.Error(t, err)
+}
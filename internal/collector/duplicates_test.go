@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_Duplicates(t *testing.T) {
+	lines := []string{"first record", "second record"}
+	for _, tc := range []struct {
+		name string
+		keep bool
+		want []string
+	}{
+		{"dedupe", false, lines},
+		{"keep", true, append(append([]string(nil), lines...), lines...)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			base := t.TempDir()
+			p := filepath.Join(base, "app.log")
+			require.NoError(t, os.WriteFile(p, []byte("first record\nsecond record\n"), 0644))
+			require.NoError(t, os.Link(p, filepath.Join(base, "app-link.log")))
+
+			sink := testkit.NewLineSink()
+			opts := []Option{WithInclude(filepath.Join(base, "*.log")), WithPollInterval(50 * time.Millisecond),
+				WithFingerprint(watcher.FingerprintStrategyChecksum, 8), WithOnLine(sink.Add)}
+			if tc.keep {
+				opts = append(opts, WithKeepDuplicates())
+			}
+			c, err := New(opts...)
+			require.NoError(t, err)
+			c.Start()
+			defer c.Stop()
+
+			require.True(t, sink.Wait(len(tc.want), 3*time.Second), "got %q", sink.Lines())
+			time.Sleep(200 * time.Millisecond)
+			testkit.AssertLinesUnordered(t, sink.Lines(), tc.want)
+			assert.Len(t, c.TrackedFiles(), len(tc.want)/len(lines))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"github.com/loykin/freader/internal/tailer"
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// rotatedAway reports whether file id, followed by name with cfg.FollowName, is no
+// longer at the path it was found at.
+func (c *Collector) rotatedAway(id string) bool {
+	c.mu.Lock()
+	path, ok := c.followed[id]
+	c.mu.Unlock()
+	return ok && c.pathOf(id) != path
+}
+
+// retire stops reading fileTail, rotated away from the followed path and read to its
+// end with cfg.FollowName. It is dropped like a deleted file, except that its stored
+// offset is kept, and scans do not track it again under its new name. Called by the
+// worker reading it, which delivers what it still holds.
+func (c *Collector) retire(fileTail *tailer.TailReader, path string) {
+	c.logger.Info("file rotated away, no longer following it", "file", fileTail.FileId, "path", path)
+	c.watcher.Retire(fileTail.FileId)
+	c.scheduler.Remove(fileTail.FileId)
+	c.fileManager.Remove(fileTail.FileId)
+	c.mu.Lock()
+	delete(c.followed, fileTail.FileId)
+	delete(c.startup, fileTail.FileId)
+	delete(c.failures, fileTail.FileId)
+	c.mu.Unlock()
+	c.metrics.DecActiveFiles()
+	c.decisions.Record(watcher.Decision{Action: watcher.DecisionRemoved, Path: path, FileID: fileTail.FileId,
+		Reason: "rotated away, following the file at its former path"})
+	c.fileRemoved(fileTail.FileId, path)
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_FollowName(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	w, err := testkit.NewLogWriter(filepath.Join(base, "app.log"))
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(3)
+	require.NoError(t, err)
+
+	sink := testkit.NewLineSink()
+	// The rotated name is included too, so without FollowName it would be followed
+	c, err := New(WithInclude(filepath.Join(base, "app.log*")), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithFollowName(),
+		WithOnLine(sink.Add))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+
+	rotated, err := w.Rotate()
+	require.NoError(t, err)
+	_, err = w.Write(2)
+	require.NoError(t, err)
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+	assert.Eventually(t, func() bool {
+		files := c.Stats().Files
+		return len(files) == 1 && files[0].Path == w.Path()
+	}, 3*time.Second, 20*time.Millisecond, "rotated file still tracked")
+
+	// Later writes to the rotated copy are not read
+	f, err := os.OpenFile(rotated, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("late\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// A file truncated in place is read from the start again
+	require.NoError(t, w.Truncate())
+	_, err = w.Write(1)
+	require.NoError(t, err)
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+	time.Sleep(200 * time.Millisecond)
+	testkit.AssertLines(t, sink.Lines(), w.Written())
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// ForeignPosition is a read position recorded by another log shipper, such as an
+// entry of a Filebeat registry or a Promtail positions file.
+type ForeignPosition struct {
+	Path   string
+	Offset int64
+	// Device and Inode identify the file the position was recorded for, when the agent
+	// stores them (Filebeat); zero when unknown (Promtail).
+	Device, Inode uint64
+}
+
+// OffsetImport describes converting foreign positions into offsets stored for the
+// fingerprint strategy freader will run with, so switching agents does not re-read
+// what the previous one already shipped.
+type OffsetImport struct {
+	DBPath    string
+	Positions []ForeignPosition
+	// Strategy, FingerprintSize, FingerprintOffset and Separator must match the
+	// collector configuration the offsets are imported for; see OffsetMigration.
+	Strategy          string
+	FingerprintSize   int
+	FingerprintOffset int64
+	Separator         string
+	// DryRun computes the result without writing to the store.
+	DryRun bool
+}
+
+// ImportedOffset is the outcome for one foreign position.
+type ImportedOffset struct {
+	Path   string
+	ID     string // empty when skipped
+	Offset int64
+	// Err explains why the position was skipped: the file is missing, is no longer the
+	// file the position was recorded for, is shorter than the offset or too small to
+	// fingerprint. nil when imported.
+	Err error
+}
+
+// ImportOffsets fingerprints every file named by im.Positions that is still on disk,
+// checks it is the file the position was recorded for (same device and inode when
+// known, and at least Offset bytes long) and stores the offset under the file's ID,
+// replacing any offset already stored for it.
+func ImportOffsets(im OffsetImport) ([]ImportedOffset, error) {
+	wc := watcher.Config{FingerprintStrategy: im.Strategy, FingerprintSize: 1, FingerprintSeparator: "\n"}
+	if err := wc.Validate(); err != nil {
+		return nil, err
+	}
+	fp := OffsetMigration{Separator: im.Separator}
+
+	var db store.Store
+	if !im.DryRun {
+		sqlite, err := store.NewSQLiteStore(im.DBPath)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = sqlite.Close() }()
+		db = sqlite
+	}
+
+	results := make([]ImportedOffset, 0, len(im.Positions))
+	for _, p := range im.Positions {
+		res := ImportedOffset{Path: p.Path, Offset: p.Offset}
+		if res.Err = checkForeignPosition(p); res.Err == nil {
+			res.ID, res.Err = fp.fingerprint(p.Path, im.Strategy, im.FingerprintOffset, im.FingerprintSize)
+		}
+		if res.Err == nil && db != nil {
+			if err := db.Save(res.ID, im.Strategy, p.Path, p.Offset); err != nil {
+				return results, err
+			}
+		}
+		if res.Err != nil {
+			res.ID = ""
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// checkForeignPosition verifies that the file at p.Path is still the one p was recorded for.
+func checkForeignPosition(p ForeignPosition) error {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return err
+	}
+	if p.Inode != 0 {
+		if dev, ino, ok := file_tracker.DeviceAndInode(info); ok && (ino != p.Inode || (p.Device != 0 && dev != p.Device)) {
+			return ErrFileChanged
+		}
+	}
+	if p.Offset < 0 || p.Offset > info.Size() {
+		return fmt.Errorf("%w: offset %d beyond size %d", ErrFileChanged, p.Offset, info.Size())
+	}
+	return nil
+}
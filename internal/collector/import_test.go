@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFilebeatRegistry_Directory(t *testing.T) {
+	dir := t.TempDir()
+	// The checkpoint holds a log input entry; log.json updates it, removes another and
+	// adds a filestream entry
+	checkpoint := `[
+{"_key":"filebeat::logs::native::11-22","source":"/var/log/a.log","offset":100,"FileStateOS":{"inode":11,"device":22},"type":"log"},
+{"_key":"filebeat::logs::native::33-22","source":"/var/log/old.log","offset":5,"FileStateOS":{"inode":33,"device":22},"type":"log"}
+]`
+	log := `{"op":"set","id":3}
+{"k":"filebeat::logs::native::11-22","v":{"source":"/var/log/a.log","offset":250,"FileStateOS":{"inode":11,"device":22},"type":"log"}}
+{"op":"remove","id":4}
+{"k":"filebeat::logs::native::33-22"}
+{"op":"set","id":5}
+{"k":"filestream::app::native::44-22","v":{"cursor":{"offset":70},"meta":{"source":"/var/log/b.log","identifier_name":"native"}}}
+{"op":"set","id":6}
+{"k":"filestream::app::native::55-22","v":{"cursor":{"offs`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2.json"), []byte(checkpoint), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "active.dat"), []byte("/usr/share/filebeat/data/registry/filebeat/2.json\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "log.json"), []byte(log), 0600))
+
+	positions, err := ReadFilebeatRegistry(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []ForeignPosition{
+		{Path: "/var/log/a.log", Offset: 250, Device: 22, Inode: 11},
+		{Path: "/var/log/b.log", Offset: 70, Device: 22, Inode: 44},
+	}, positions)
+}
+
+func TestReadFilebeatRegistry_Legacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry")
+	legacy := `[{"source":"/var/log/a.log","offset":42,"FileStateOS":{"inode":7,"device":8}},{"type":"stdin"}]`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0600))
+
+	positions, err := ReadFilebeatRegistry(path)
+	require.NoError(t, err)
+	assert.Equal(t, []ForeignPosition{{Path: "/var/log/a.log", Offset: 42, Device: 8, Inode: 7}}, positions)
+
+	require.NoError(t, os.WriteFile(path, []byte("[{"), 0600))
+	_, err = ReadFilebeatRegistry(path)
+	assert.Error(t, err)
+}
+
+func TestReadPromtailPositions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "positions.yaml")
+	content := `positions:
+  /var/log/b.log: "20"
+  /var/log/a.log: "10"
+  journal-systemd: s=abc;i=1
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	positions, err := ReadPromtailPositions(path)
+	require.NoError(t, err)
+	assert.Equal(t, []ForeignPosition{
+		{Path: "/var/log/a.log", Offset: 10},
+		{Path: "/var/log/b.log", Offset: 20},
+	}, positions)
+}
+
+func TestImportOffsets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode checks on Windows")
+	}
+	dir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	kept := filepath.Join(dir, "kept.log")
+	rotated := filepath.Join(dir, "rotated.log")
+	require.NoError(t, os.WriteFile(kept, []byte("first line long enough\nsecond line\n"), 0644))
+	require.NoError(t, os.WriteFile(rotated, []byte("a new file at the old path\n"), 0644))
+
+	info, err := os.Stat(kept)
+	require.NoError(t, err)
+	dev, ino, ok := file_tracker.DeviceAndInode(info)
+	require.True(t, ok)
+
+	im := OffsetImport{
+		DBPath: dbPath,
+		Positions: []ForeignPosition{
+			{Path: kept, Offset: 23, Device: dev, Inode: ino},
+			{Path: rotated, Offset: 5, Device: dev, Inode: ino + 1000},
+			{Path: kept + ".gone", Offset: 1},
+			{Path: rotated, Offset: 1 << 20},
+		},
+		Strategy:        watcher.FingerprintStrategyChecksum,
+		FingerprintSize: 16,
+		DryRun:          true,
+	}
+	results, err := ImportOffsets(im)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	keptID, err := file_tracker.GetFileFingerprintFromPath(kept, 16)
+	require.NoError(t, err)
+	assert.Equal(t, keptID, results[0].ID)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrFileChanged)
+	assert.ErrorIs(t, results[2].Err, os.ErrNotExist)
+	assert.ErrorIs(t, results[3].Err, ErrFileChanged)
+	_, err = os.Stat(dbPath)
+	assert.ErrorIs(t, err, os.ErrNotExist, "dry run must not create the store")
+
+	im.DryRun = false
+	_, err = ImportOffsets(im)
+	require.NoError(t, err)
+	db, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	offset, found, err := db.Load(keptID, watcher.FingerprintStrategyChecksum)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(23), offset)
+	entries, err := db.List(watcher.FingerprintStrategyChecksum)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, fmt.Sprint(entries))
+
+	_, err = ImportOffsets(OffsetImport{DBPath: dbPath, Strategy: "bogus"})
+	assert.Error(t, err)
+}
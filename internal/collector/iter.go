@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"context"
+	"iter"
+)
+
+// Iter returns an iterator over collected records for use with range-over-func:
+//
+//	for rec, err := range c.Iter(ctx) {
+//		if err != nil {
+//			log.Println(err)
+//			continue
+//		}
+//		fmt.Println(rec.Line)
+//	}
+//
+// Iteration consumes Records, so the two must not be used at the same time. If the
+// collector has not been started, Iter starts it and stops it when the loop ends;
+// otherwise the caller remains responsible for Stop. Read and store errors are yielded
+// with a zero Record and iteration continues. When ctx is done, ctx.Err() is yielded
+// once and iteration ends; it also ends when the collector is stopped.
+func (c *Collector) Iter(ctx context.Context) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		records := c.Records()
+		c.iterating.Store(true)
+		defer c.iterating.Store(false)
+
+		if !c.started.Load() {
+			c.Start()
+			defer c.Stop()
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				yield(Record{}, ctx.Err())
+				return
+			case rec, ok := <-records:
+				if !ok {
+					return
+				}
+				if !yield(rec, nil) {
+					return
+				}
+			case err := <-c.iterErrs:
+				if !yield(Record{}, err) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector_Iter(t *testing.T) {
+	tempDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "app.log"), []byte("a1\na2\na3\n"), 0644))
+
+	c, err := NewCollector(Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     2,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var got []string
+	for rec, err := range c.Iter(ctx) {
+		assert.NoError(t, err)
+		got = append(got, rec.Line)
+		if len(got) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a1", "a2", "a3"}, got)
+
+	// Breaking out stopped the collector that Iter started.
+	_, open := <-c.Records()
+	assert.False(t, open)
+	c.Stop() // idempotent
+}
+
+func TestCollector_Iter_ContextCancel(t *testing.T) {
+	c, err := NewCollector(Config{
+		Include:             []string{t.TempDir()},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     2,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var errs []error
+	for _, err := range c.Iter(ctx) {
+		errs = append(errs, err)
+	}
+	if assert.Len(t, errs, 1) {
+		assert.True(t, errors.Is(errs[0], context.DeadlineExceeded))
+	}
+}
@@ -0,0 +1,204 @@
+package collector
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+)
+
+// InstanceID returns the name this collector holds the offset store lease under;
+// empty when offsets are not stored.
+func (c *Collector) InstanceID() string {
+	return c.instanceID
+}
+
+// defaultInstanceID returns "<hostname>-<pid>-<random>".
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Standby reports whether the collector is standing by in standby mode while another
+// instance holds the lease; it then neither reads nor saves offsets.
+func (c *Collector) Standby() bool {
+	return c.standby.Load()
+}
+
+// leaseTarget names what the lease guards in log and error messages.
+func (c *Collector) leaseTarget() string {
+	switch {
+	case c.cfg.Leaser != nil:
+		return "lease"
+	case c.cfg.PostgresDSN != "":
+		return "postgres offset store"
+	default:
+		return c.cfg.DBPath
+	}
+}
+
+func (c *Collector) lease() store.Lease {
+	host, _ := os.Hostname()
+	return store.Lease{InstanceID: c.instanceID, Hostname: host, PID: os.Getpid()}
+}
+
+// acquireLease takes the lease when Config.LeaseTTL is set: Config.Leaser, else the
+// offset store's. In standby mode a lease held by another instance leaves the
+// collector standing by instead of failing.
+func (c *Collector) acquireLease() error {
+	c.instanceID = c.cfg.InstanceID
+	if c.instanceID == "" {
+		c.instanceID = defaultInstanceID()
+	}
+	if c.cfg.LeaseTTL <= 0 || c.cfg.sharded() {
+		// Shards share the store and split the files instead
+		return nil
+	}
+	c.leaser = c.cfg.Leaser
+	if c.leaser == nil {
+		l, ok := c.offsetDB.(store.Leaser)
+		if !ok {
+			return nil
+		}
+		c.leaser = l
+	}
+	err := c.leaser.AcquireLease(c.lease(), c.cfg.LeaseTTL)
+	if c.cfg.Standby && errors.Is(err, store.ErrLeaseHeld) {
+		c.logger.Info("another instance holds the lease, standing by", "store", c.leaseTarget(), "instance_id", c.instanceID, "error", err)
+		c.setLeader(false)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.leaseTarget(), err)
+	}
+	c.logger.Debug("acquired offset store lease", "store", c.leaseTarget(), "instance_id", c.instanceID, "ttl", c.cfg.LeaseTTL)
+	if c.cfg.Standby {
+		c.setLeader(true)
+	}
+	return nil
+}
+
+// setLeader switches between reading (leader) and standing by.
+func (c *Collector) setLeader(leader bool) {
+	c.standby.Store(!leader)
+	c.scheduler.SetStandby(!leader)
+	c.metrics.SetLeader(leader)
+}
+
+// storesOffsets reports whether offsets are written to the store. They are not while
+// standing by, as the leader owns them.
+func (c *Collector) storesOffsets() bool {
+	return c.offsetDB != nil && c.cfg.StoreOffsets && !c.standby.Load()
+}
+
+// renewLease heartbeats the lease every ttl/3 until Stop. Without standby mode it gives
+// up once another instance has taken the lease over. In standby mode a leader that
+// loses the lease, or cannot renew it before it expires, stands by, and a collector
+// standing by tries to take the lease at the same interval.
+func (c *Collector) renewLease(l store.Leaser, ttl time.Duration) {
+	defer c.workerWg.Done()
+	ticker := c.clock.NewTicker(max(ttl/3, time.Millisecond))
+	defer ticker.Stop()
+	expires := c.clock.Now().Add(ttl)
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C():
+			if c.standby.Load() {
+				if c.campaign(l, ttl) {
+					expires = c.clock.Now().Add(ttl)
+				}
+				continue
+			}
+			err := l.RenewLease(c.instanceID, ttl)
+			if err == nil {
+				expires = c.clock.Now().Add(ttl)
+				continue
+			}
+			c.reportError(err, ErrorContext{Kind: ErrorKindStore, Op: "lease"})
+			if errors.Is(err, store.ErrLeaseLost) {
+				if c.cfg.Standby {
+					c.logger.Warn("lease taken over by another instance, standing by", "store", c.leaseTarget(), "instance_id", c.instanceID, "error", err)
+					c.setLeader(false)
+					continue
+				}
+				c.logger.Error("offset store lease lost, no longer saving offsets", "store", c.leaseTarget(), "instance_id", c.instanceID, "error", err)
+				return
+			}
+			c.logger.Warn("failed to renew offset store lease", "store", c.leaseTarget(), "error", err)
+			if c.cfg.Standby && !c.clock.Now().Before(expires) {
+				c.logger.Warn("lease expired before it could be renewed, standing by", "store", c.leaseTarget(), "instance_id", c.instanceID)
+				c.setLeader(false)
+			}
+		}
+	}
+}
+
+// campaign tries to take the lease while standing by. On success the collector picks
+// up every tracked file at the offset the previous leader stored and starts reading.
+func (c *Collector) campaign(l store.Leaser, ttl time.Duration) bool {
+	err := l.AcquireLease(c.lease(), ttl)
+	if errors.Is(err, store.ErrLeaseHeld) {
+		return false
+	}
+	if err != nil {
+		c.logger.Warn("failed to acquire lease", "store", c.leaseTarget(), "error", err)
+		c.reportError(err, ErrorContext{Kind: ErrorKindStore, Op: "lease"})
+		return false
+	}
+	c.reloadOffsets()
+	c.setLeader(true)
+	c.logger.Info("acquired lease, reading as leader", "store", c.leaseTarget(), "instance_id", c.instanceID)
+	return true
+}
+
+// reloadOffsets moves every tracked file to its stored offset, discarding the
+// positions seen while standing by.
+func (c *Collector) reloadOffsets() {
+	if c.offsetDB == nil || !c.cfg.StoreOffsets {
+		return
+	}
+	for id, f := range c.fileManager.GetAllFiles() {
+		offset, found, err := c.offsetDB.Load(id, c.cfg.FingerprintStrategy)
+		if err != nil {
+			c.logger.Error("failed to load offset", "file", id, "error", err)
+			c.reportError(err, ErrorContext{Kind: ErrorKindStore, FileID: id, Path: f.Path, Op: "load"})
+			continue
+		}
+		if !found {
+			continue
+		}
+		offset, ok := c.resumeOffset(id, f.Path, offset)
+		if !ok {
+			// Quarantined: stop reading it; standing by, it was not being read
+			c.scheduler.Remove(id)
+			c.scheduler.Release(id)
+			c.metrics.DecActiveFiles()
+			continue
+		}
+		c.scheduler.Seek(id, offset)
+		c.fileManager.UpdateOffset(id, offset)
+		c.position(id).Store(offset)
+	}
+}
+
+// releaseLease gives up the lease on Stop so the next instance can start right away.
+func (c *Collector) releaseLease() {
+	if c.leaser == nil || c.standby.Load() {
+		return
+	}
+	if err := c.leaser.ReleaseLease(c.instanceID); err != nil {
+		c.logger.Warn("failed to release offset store lease", "store", c.leaseTarget(), "error", err)
+		c.reportError(err, ErrorContext{Kind: ErrorKindStore, Op: "lease"})
+	}
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func leaseConfig(dbPath, instanceID string, ttl time.Duration) Config {
+	return Config{
+		FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode,
+		PollInterval:        time.Hour,
+		StoreOffsets:        true,
+		DBPath:              dbPath,
+		LeaseTTL:            ttl,
+		InstanceID:          instanceID,
+	}
+}
+
+func TestCollector_LeaseRefusesSecondInstance(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	first, err := NewCollector(leaseConfig(dbPath, "first", time.Minute))
+	require.NoError(t, err)
+	first.Start()
+	assert.Equal(t, "first", first.InstanceID())
+
+	_, err = NewCollector(leaseConfig(dbPath, "second", time.Minute))
+	require.ErrorIs(t, err, store.ErrLeaseHeld)
+	assert.Contains(t, err.Error(), "instance first")
+
+	first.Stop()
+	second, err := NewCollector(leaseConfig(dbPath, "second", time.Minute))
+	require.NoError(t, err, "Stop releases the lease")
+	second.Stop()
+}
+
+func TestCollector_LeaseLostAfterTakeover(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	lost := make(chan error, 1)
+	cfg := leaseConfig(dbPath, "stale", 30*time.Millisecond)
+	cfg.OnErrorFunc = func(err error, ctx ErrorContext) {
+		if ctx.Op == "lease" && errors.Is(err, store.ErrLeaseLost) {
+			select {
+			case lost <- err:
+			default:
+			}
+		}
+	}
+	// Not started yet, so nothing renews the lease and it expires
+	stale, err := NewCollector(cfg)
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	fresh, err := NewCollector(leaseConfig(dbPath, "fresh", time.Minute))
+	require.NoError(t, err)
+	defer fresh.Stop()
+
+	stale.Start()
+	select {
+	case err := <-lost:
+		assert.Contains(t, err.Error(), "instance fresh")
+	case <-time.After(2 * time.Second):
+		t.Fatal("stale collector did not report the lost lease")
+	}
+	assert.ErrorIs(t, stale.offsetDB.Save("id", "deviceAndInode", "/var/log/app.log", 1), store.ErrLeaseLost)
+	stale.Stop()
+
+	holder, ok, err := fresh.offsetDB.(store.Leaser).CurrentLease()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "fresh", holder.InstanceID, "stopping the stale collector keeps the new holder's lease")
+}
+
+func TestDefaultInstanceID(t *testing.T) {
+	a, b := defaultInstanceID(), defaultInstanceID()
+	assert.NotEqual(t, a, b)
+	assert.NotEmpty(t, a)
+}
+
+func TestCollector_StandbyTakesOver(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(logPath, []byte("one\n"), 0644))
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+
+	type reader struct {
+		mu    sync.Mutex
+		lines []string
+	}
+	read := func(r *reader) []string {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return append([]string(nil), r.lines...)
+	}
+	start := func(instanceID string, r *reader) *Collector {
+		c, err := New(WithInclude(dir), WithPollInterval(20*time.Millisecond),
+			WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+			WithStore(dbPath), WithLease(instanceID, 150*time.Millisecond), WithStandby(),
+			WithOnLine(func(line string) {
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				r.lines = append(r.lines, line)
+			}))
+		require.NoError(t, err)
+		c.Start()
+		return c
+	}
+	appendLine := func(line string) {
+		f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString(line + "\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+
+	var leaderLines, standbyLines reader
+	leader := start("leader", &leaderLines)
+	assert.False(t, leader.Standby())
+	assert.Eventually(t, func() bool { return len(read(&leaderLines)) == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	standby := start("standby", &standbyLines)
+	defer standby.Stop()
+	assert.True(t, standby.Standby(), "a second instance stands by instead of failing")
+	appendLine("two")
+	assert.Eventually(t, func() bool { return len(read(&leaderLines)) == 2 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, read(&standbyLines), "only the leader reads")
+
+	// Stopping the leader releases the lease; the standby continues from its offsets
+	leader.Stop()
+	assert.Eventually(t, func() bool { return !standby.Standby() }, 2*time.Second, 10*time.Millisecond)
+	appendLine("three")
+	assert.Eventually(t, func() bool { return len(read(&standbyLines)) == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"three"}, read(&standbyLines))
+	assert.Equal(t, []string{"one", "two"}, read(&leaderLines))
+}
+
+func TestCollector_StandbyRequiresLease(t *testing.T) {
+	_, err := New(WithInclude(t.TempDir()), WithLease("", 0), WithStandby())
+	assert.Error(t, err)
+}
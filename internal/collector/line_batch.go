@@ -0,0 +1,83 @@
+package collector
+
+import "time"
+
+// DefaultLinesBatchSize is the OnLinesFunc batch size used when Config.LinesBatchSize is 0.
+const DefaultLinesBatchSize = 256
+
+// lineBatch accumulates records for Config.OnLinesFunc or OnLinesAckFunc within one
+// worker.
+type lineBatch struct {
+	c       *Collector
+	size    int
+	recs    []Record
+	bytes   int
+	firstTs time.Time
+	waits   []func() error // OnLinesAckFunc confirmations of the flushed batches
+}
+
+// newLineBatch returns nil when neither OnLinesFunc nor OnLinesAckFunc is configured;
+// flush and acked are nil-safe.
+func (c *Collector) newLineBatch() *lineBatch {
+	if c.cfg.OnLinesFunc == nil && c.cfg.OnLinesAckFunc == nil {
+		return nil
+	}
+	size := c.cfg.LinesBatchSize
+	if size <= 0 {
+		size = DefaultLinesBatchSize
+	}
+	return &lineBatch{c: c, size: size}
+}
+
+func (b *lineBatch) add(rec Record) {
+	if len(b.recs) == 0 {
+		b.firstTs = b.c.clock.Now()
+		b.recs = make([]Record, 0, b.size)
+	}
+	b.recs = append(b.recs, rec)
+	b.bytes += len(rec.Line)
+
+	cfg := &b.c.cfg
+	if len(b.recs) >= b.size ||
+		(cfg.LinesBatchBytes > 0 && b.bytes >= cfg.LinesBatchBytes) ||
+		(cfg.LinesBatchInterval > 0 && b.c.clock.Since(b.firstTs) >= cfg.LinesBatchInterval) {
+		b.flush()
+	}
+}
+
+// flush hands the pending records to OnLinesAckFunc or OnLinesFunc. Callbacks are
+// serialized across workers like OnLineFunc; each call receives a fresh slice it may
+// keep.
+func (b *lineBatch) flush() {
+	if b == nil || len(b.recs) == 0 {
+		return
+	}
+	recs := b.recs
+	if ack := b.c.cfg.OnLinesAckFunc; ack != nil {
+		b.c.deliverCallback(recs[0].File, len(recs), func() {
+			if wait := ack(recs); wait != nil {
+				b.waits = append(b.waits, wait)
+			}
+		})
+	} else {
+		b.c.deliverCallback(recs[0].File, len(recs), func() { b.c.cfg.OnLinesFunc(recs) })
+	}
+	b.recs = nil
+	b.bytes = 0
+}
+
+// acked waits for the confirmations of the batches flushed so far and returns the
+// first error, if any.
+func (b *lineBatch) acked() error {
+	if b == nil {
+		return nil
+	}
+	var first error
+	for _, wait := range b.waits {
+		if err := wait(); err != nil && first == nil {
+			first = err
+		}
+	}
+	b.waits = nil
+	return first
+}
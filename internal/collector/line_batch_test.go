@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector_OnLinesFunc(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&content, "line-%d\n", i)
+	}
+
+	tests := []struct {
+		name      string
+		size      int
+		maxBytes  int
+		wantSizes []int
+	}{
+		{name: "bounded by count", size: 4, wantSizes: []int{4, 4, 2}},
+		{name: "bounded by bytes", size: 100, maxBytes: 18, wantSizes: []int{3, 3, 3, 1}}, // 6 bytes per line
+		{name: "end of read flushes", size: 100, wantSizes: []int{10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "app.log")
+			assert.NoError(t, os.WriteFile(testFile, []byte(content.String()), 0644))
+
+			var mu sync.Mutex
+			var sizes []int
+			var lines []string
+			c, err := New(
+				WithInclude(tempDir),
+				WithPollInterval(50*time.Millisecond),
+				WithFingerprint(watcher.FingerprintStrategyChecksum, 8),
+				WithLinesBatch(tt.size, tt.maxBytes, 0),
+				WithOnLines(func(batch []Record) {
+					mu.Lock()
+					defer mu.Unlock()
+					sizes = append(sizes, len(batch))
+					for _, rec := range batch {
+						assert.Equal(t, testFile, rec.File)
+						lines = append(lines, rec.Line)
+					}
+				}),
+			)
+			assert.NoError(t, err)
+			c.Start()
+			defer c.Stop()
+
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(lines) == 10
+			}, 3*time.Second, 20*time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.wantSizes, sizes)
+			assert.Equal(t, "line-0", lines[0])
+			assert.Equal(t, "line-9", lines[9])
+		})
+	}
+}
+
+func TestCollector_OnLineBytesFunc(t *testing.T) {
+	tempDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "app.log"), []byte("b1\nb2\n"), 0644))
+
+	var mu sync.Mutex
+	var got []string
+	c, err := New(
+		WithInclude(tempDir),
+		WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyChecksum, 2),
+		WithOnLine(func(string) { t.Error("OnLineFunc must not be called when OnLineBytesFunc is set") }),
+		WithOnLineBytes(func(b []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, string(b)) // copy: b is only valid during the call
+		}),
+	)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	}, 3*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"b1", "b2"}, got)
+}
+
+func TestCollector_OnLinesAckFunc(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "app.log")
+	assert.NoError(t, os.WriteFile(testFile, []byte("a1\na2\na3\n"), 0644))
+
+	var mu sync.Mutex
+	var batches [][]string
+	var kinds []ErrorKind
+	var c *Collector
+	var err error
+	c, err = New(
+		WithInclude(tempDir),
+		WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyChecksum, 2),
+		WithLinesBatch(2, 0, 0),
+		WithOnLinesErr(func(batch []Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			var lines []string
+			for _, rec := range batch {
+				lines = append(lines, rec.Line)
+			}
+			batches = append(batches, lines)
+			if len(batches) == 2 {
+				return errors.New("sink unavailable")
+			}
+			return nil
+		}),
+		WithOnError(func(err error, ctx ErrorContext) {
+			mu.Lock()
+			defer mu.Unlock()
+			kinds = append(kinds, ctx.Kind)
+			// The offset past the undelivered records must not be stored
+			assert.Equal(t, int64(0), c.fileManager.Get(ctx.FileID).Offset)
+		}),
+	)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(batches) == 4
+	}, 3*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	// Both batches came from one read, so the confirmed one is read again with the failed one
+	assert.Equal(t, [][]string{{"a1", "a2"}, {"a3"}, {"a1", "a2"}, {"a3"}}, batches)
+	assert.Equal(t, []ErrorKind{ErrorKindDelivery}, kinds)
+	assert.Eventually(t, func() bool {
+		return c.fileManager.Get(c.scheduler.Tails()[0].FileId).Offset == 9
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCollector_OnLinesAckFunc_MergeWindow(t *testing.T) {
+	_, err := New(
+		WithMergeWindow(time.Second, func(string) (time.Time, bool) { return time.Time{}, false }),
+		WithOnLinesAck(func([]Record) func() error { return nil }),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"os"
+	"sort"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// ListedFile is one file matched by a configuration, as reported by ListFiles.
+type ListedFile struct {
+	Path   string
+	ID     string // empty when the file could not be fingerprinted
+	Size   int64  // -1 if the file could not be stat'ed
+	Offset int64  // stored offset; -1 when none is stored
+	Err    error  // why the file cannot be read; nil when readable
+}
+
+// ListFiles runs a single scan with cfg's include/exclude patterns and fingerprint
+// settings and reports the matching files, their stored offsets (if an offsets database
+// exists at cfg.DBPath) and the files and directories that cannot be read. Nothing is
+// read beyond what fingerprinting needs and no offsets are written.
+func ListFiles(cfg Config) ([]ListedFile, error) {
+	tracker := file_tracker.New()
+	unreadable := watcher.NewUnreadableFiles(cfg.PollInterval)
+	w, err := listWatcher(cfg, tracker, unreadable)
+	if err != nil {
+		return nil, err
+	}
+	w.Scan()
+
+	var db store.Store
+	if cfg.StoreOffsets && cfg.PostgresDSN != "" {
+		if db, err = openStore(cfg, cfg.Logger); err != nil {
+			return nil, err
+		}
+		defer func() { _ = db.Close() }()
+	} else if cfg.StoreOffsets {
+		if _, err := os.Stat(cfg.DBPath); err == nil {
+			if db, err = store.NewSQLiteStoreWithLogger(cfg.DBPath, cfg.Logger); err != nil {
+				return nil, err
+			}
+			defer func() { _ = db.Close() }()
+		}
+	}
+
+	var files []ListedFile
+	for id, f := range tracker.GetAllFiles() {
+		lf := ListedFile{Path: f.Path, ID: id, Size: -1, Offset: -1}
+		if info, err := os.Stat(f.Path); err == nil {
+			lf.Size = info.Size()
+		}
+		// deviceAndInode fingerprints only stat the file, so check it can be opened
+		if file, err := file_tracker.Open(f.Path); err != nil {
+			lf.Err = err
+		} else {
+			_ = file.Close()
+		}
+		if db != nil {
+			offset, found, err := db.Load(id, cfg.FingerprintStrategy)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				lf.Offset = offset
+			}
+		}
+		files = append(files, lf)
+	}
+	for _, u := range unreadable.List() {
+		lf := ListedFile{Path: u.Path, Size: -1, Offset: -1, Err: u.Err}
+		if info, err := os.Stat(u.Path); err == nil && !info.IsDir() {
+			lf.Size = info.Size()
+		}
+		files = append(files, lf)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// ExplainPath runs a single scan with cfg like ListFiles and reports whether the file
+// at path would be tracked and, if not, why.
+func ExplainPath(cfg Config, path string) (Decision, error) {
+	w, err := listWatcher(cfg, file_tracker.New(), watcher.NewUnreadableFiles(cfg.PollInterval))
+	if err != nil {
+		return Decision{}, err
+	}
+	w.Scan()
+	return w.Explain(path), nil
+}
+
+// listWatcher validates cfg and returns a watcher for it tracking files in tracker only.
+func listWatcher(cfg Config, tracker *file_tracker.FileTracker, unreadable *watcher.UnreadableFiles) (*watcher.Watcher, error) {
+	cfg.applyNetworkFS()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	wc := watcher.Config{
+		PollInterval:         cfg.PollInterval,
+		FingerprintStrategy:  cfg.FingerprintStrategy,
+		FingerprintSize:      cfg.FingerprintSize,
+		FingerprintSeparator: cfg.Separator,
+		FingerprintOffset:    cfg.FingerprintOffset,
+		Include:              cfg.Include,
+		Exclude:              cfg.Exclude,
+		ExcludeDirs:          cfg.ExcludeDirs,
+		IgnoreFile:           cfg.IgnoreFile,
+		KeepDuplicates:       cfg.KeepDuplicates,
+		FileTracker:          tracker,
+		Logger:               cfg.Logger,
+		Unreadable:           unreadable,
+	}
+	return watcher.NewWatcher(wc, func(id, path string) {}, nil)
+}
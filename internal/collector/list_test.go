@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	a := filepath.Join(dir, "a.log")
+	b := filepath.Join(dir, "b.log")
+	require.NoError(t, os.WriteFile(a, []byte("first file content\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("second file content\n"), 0644))
+
+	cfg := Config{}
+	cfg.Default()
+	cfg.Include = []string{dir}
+	cfg.FingerprintStrategy = watcher.FingerprintStrategyChecksum
+	cfg.FingerprintSize = 8
+	cfg.DBPath = dbPath
+
+	files, err := ListFiles(cfg)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, a, files[0].Path)
+	assert.Equal(t, int64(len("first file content\n")), files[0].Size)
+	assert.Equal(t, int64(-1), files[0].Offset, "no database yet")
+	_, err = os.Stat(dbPath)
+	assert.True(t, os.IsNotExist(err), "listing does not create the database")
+
+	// Collect once so an offset is stored
+	c, err := NewCollector(cfg)
+	require.NoError(t, err)
+	c.Start()
+	assert.Eventually(t, func() bool { return c.Stats().LinesRead >= 2 }, 5e9, 2e7)
+	c.Stop()
+
+	files, err = ListFiles(cfg)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, files[0].Size, files[0].Offset)
+	assert.NoError(t, files[0].Err)
+	assert.NotEmpty(t, files[0].ID)
+}
+
+func TestExplainPath(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(p, []byte("hello\n"), 0644))
+
+	cfg := Config{
+		Include:             []string{dir},
+		Exclude:             []string{"*.tmp"},
+		PollInterval:        time.Second,
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     64,
+		Separator:           "\n",
+	}
+	d, err := ExplainPath(cfg, p)
+	require.NoError(t, err)
+	assert.Equal(t, watcher.DecisionSkipped, d.Action)
+	assert.Contains(t, d.Reason, "too small")
+
+	cfg.FingerprintSize = 4
+	d, err = ExplainPath(cfg, p)
+	require.NoError(t, err)
+	assert.Equal(t, watcher.DecisionIncluded, d.Action)
+	assert.Equal(t, "tracked", d.Reason)
+
+	archived := filepath.Join(dir, "archived", "old.log")
+	require.NoError(t, os.MkdirAll(filepath.Dir(archived), 0755))
+	require.NoError(t, os.WriteFile(archived, []byte("hello\n"), 0644))
+	cfg.ExcludeDirs = []string{"archived"}
+	d, err = ExplainPath(cfg, archived)
+	require.NoError(t, err)
+	assert.Equal(t, watcher.DecisionExcluded, d.Action)
+	assert.Contains(t, d.Reason, "exclude-dirs")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".freaderignore"), []byte("app.log\n"), 0644))
+	cfg.IgnoreFile = ".freaderignore"
+	d, err = ExplainPath(cfg, p)
+	require.NoError(t, err)
+	assert.Equal(t, watcher.DecisionExcluded, d.Action)
+	assert.Contains(t, d.Reason, `"app.log"`)
+}
@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+)
+
+// maintainStore checkpoints and vacuums the offset store every interval until Stop.
+func (c *Collector) maintainStore(m store.Maintainer, interval time.Duration) {
+	defer c.workerWg.Done()
+	ticker := c.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C():
+			c.runStoreMaintenance(m)
+		}
+	}
+}
+
+func (c *Collector) runStoreMaintenance(m store.Maintainer) {
+	start := c.clock.Now()
+	for _, step := range []func() error{m.Checkpoint, m.Vacuum} {
+		if err := step(); err != nil {
+			c.logger.Warn("offset store maintenance failed", "error", err)
+			c.reportError(err, ErrorContext{Kind: ErrorKindStore, Op: "maintenance"})
+			return
+		}
+	}
+	c.logger.Debug("offset store maintenance finished", "duration", c.clock.Since(start))
+}
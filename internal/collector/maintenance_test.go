@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMaintainer struct {
+	checkpoints, vacuums atomic.Int32
+	fail                 atomic.Bool
+}
+
+func (m *fakeMaintainer) Checkpoint() error {
+	m.checkpoints.Add(1)
+	if m.fail.Load() {
+		return errors.New("database is locked")
+	}
+	return nil
+}
+
+func (m *fakeMaintainer) Vacuum() error         { m.vacuums.Add(1); return nil }
+func (m *fakeMaintainer) IntegrityCheck() error { return nil }
+
+func TestCollector_MaintainStore(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		errs []ErrorContext
+	)
+	c, err := NewCollector(Config{
+		FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode,
+		OnErrorFunc: func(err error, ctx ErrorContext) {
+			mu.Lock()
+			errs = append(errs, ctx)
+			mu.Unlock()
+		},
+	})
+	require.NoError(t, err)
+
+	m := &fakeMaintainer{}
+	c.workerWg.Add(1)
+	go c.maintainStore(m, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return m.vacuums.Load() >= 2 }, 2*time.Second, 10*time.Millisecond)
+
+	m.fail.Store(true)
+	before := m.vacuums.Load()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	c.Stop()
+
+	assert.Equal(t, ErrorContext{Kind: ErrorKindStore, Op: "maintenance"}, errs[0])
+	assert.LessOrEqual(t, m.vacuums.Load(), before+1, "vacuum is skipped after a failed checkpoint")
+}
+
+func TestNewCollector_RebuildCorruptStore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "collector.db")
+	require.NoError(t, os.WriteFile(dbPath, []byte(strings.Repeat("this is not a sqlite database ", 20)), 0644))
+
+	cfg := Config{FingerprintStrategy: watcher.FingerprintStrategyDeviceAndInode, StoreOffsets: true, DBPath: dbPath}
+	_, err := NewCollector(cfg)
+	require.ErrorIs(t, err, store.ErrStoreCorrupt)
+
+	cfg.RebuildCorruptStore = true
+	c, err := NewCollector(cfg)
+	require.NoError(t, err)
+	c.Stop()
+
+	aside, err := filepath.Glob(dbPath + ".corrupt-*")
+	require.NoError(t, err)
+	assert.Len(t, aside, 1)
+}
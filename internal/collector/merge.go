@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// merger holds records of all files and releases them ordered by event time; see
+// Config.MergeWindow.
+type merger struct {
+	mu      sync.Mutex
+	items   mergeHeap
+	seq     uint64
+	last    map[string]time.Time // event time of the last record added per file
+	flight  []*mergeItem         // released records not yet handed to OnLinesFunc
+	release sync.Mutex           // serializes releaseMerged
+}
+
+// mergeItem is a record held by the merger.
+type mergeItem struct {
+	rec     Record
+	b       []byte
+	id      string    // file the record was read from
+	offset  int64     // where re-reading the file delivers the record again
+	at      time.Time // event time
+	arrived time.Time
+	seq     uint64 // arrival order, to keep records with equal event times in order
+}
+
+type mergeHeap []*mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*mergeItem)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return it
+}
+
+func newMerger() *merger {
+	return &merger{last: make(map[string]time.Time)}
+}
+
+// add holds rec, whose line is b, read from file id at offset. Its event time is
+// found by ts; records without one take that of the previous record of the file, or
+// their read time.
+func (m *merger) add(id string, offset int64, rec Record, b []byte, ts func(string) (time.Time, bool), now time.Time) {
+	it := &mergeItem{rec: rec, id: id, offset: offset, arrived: now}
+	if b != nil {
+		it.b = append([]byte(nil), b...)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if at, ok := ts(rec.Line); ok {
+		it.at = at
+	} else if at, ok := m.last[id]; ok {
+		it.at = at
+	} else {
+		it.at = rec.Ts
+	}
+	m.last[id] = it.at
+	m.seq++
+	it.seq = m.seq
+	heap.Push(&m.items, it)
+}
+
+// next returns the record to release next without removing it: the one with the
+// earliest event time once it has been held for window, or right away if all is set.
+// Otherwise it returns how long to wait before calling again, or window if nothing is
+// held.
+func (m *merger) next(now time.Time, window time.Duration, all bool) (*mergeItem, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.items) == 0 {
+		return nil, window
+	}
+	it := m.items[0]
+	if wait := it.arrived.Add(window).Sub(now); wait > 0 && !all {
+		return nil, wait
+	}
+	return it, 0
+}
+
+// pop removes the record returned by next once it is being released. It keeps
+// counting for heldFrom until settle.
+func (m *merger) pop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flight = append(m.flight, heap.Pop(&m.items).(*mergeItem))
+}
+
+// settle forgets the records popped since the last call once they were delivered.
+func (m *merger) settle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flight = nil
+}
+
+// forget drops the per-file state of id once it is no longer tracked.
+func (m *merger) forget(id string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.last, id)
+}
+
+// heldFrom returns the offset of file id from which held records would be read
+// again, and false if none of its records is held. It is nil-safe.
+func (m *merger) heldFrom(id string) (int64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	offset, ok := int64(0), false
+	for _, items := range [][]*mergeItem{m.items, m.flight} {
+		for _, it := range items {
+			if it.id == id && (!ok || it.offset < offset) {
+				offset, ok = it.offset, true
+			}
+		}
+	}
+	return offset, ok
+}
+
+// runMerge releases merged records as their window passes until the collector stops.
+func (c *Collector) runMerge() {
+	defer c.workerWg.Done()
+	for {
+		wait := c.releaseMerged(false, c.stopCh)
+		select {
+		case <-c.stopCh:
+			return
+		case <-c.clock.After(wait):
+		}
+	}
+}
+
+// releaseMerged delivers the held records that are due, or all of them, in event time
+// order to the Records channel and the callbacks, and returns how long until the next
+// one is due. Records still held when stop is closed stay held.
+func (c *Collector) releaseMerged(all bool, stop <-chan struct{}) time.Duration {
+	c.merge.release.Lock()
+	defer c.merge.release.Unlock()
+	c.mu.Lock()
+	records := c.records
+	c.mu.Unlock()
+	batch := c.newLineBatch()
+	defer func() {
+		// Offsets may pass the released records once they are delivered
+		batch.flush()
+		c.merge.settle()
+	}()
+	for {
+		it, wait := c.merge.next(c.clock.Now(), c.cfg.MergeWindow, all)
+		if it == nil {
+			return wait
+		}
+		if records != nil {
+			select {
+			case records <- it.rec:
+			case <-stop:
+				return wait
+			}
+		}
+		c.merge.pop()
+		c.emit(it.rec, it.b, batch)
+	}
+}
@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leadingTime parses a leading RFC3339 timestamp.
+func leadingTime(record string) (time.Time, bool) {
+	ts, err := time.Parse(time.RFC3339, strings.SplitN(record, " ", 2)[0])
+	return ts, err == nil
+}
+
+func TestMerger(t *testing.T) {
+	now := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	window := time.Second
+	m := newMerger()
+
+	m.add("a", 0, Record{Line: "2024-01-01T00:00:03Z a3"}, nil, leadingTime, now)
+	m.add("a", 24, Record{Line: "  continued"}, []byte("  continued"), leadingTime, now)
+	m.add("b", 0, Record{Line: "2024-01-01T00:00:02Z b2"}, nil, leadingTime, now.Add(500*time.Millisecond))
+
+	// b2 has the earliest event time, so it is released first, a window after it was read
+	it, wait := m.next(now, window, false)
+	assert.Nil(t, it)
+	assert.Equal(t, 1500*time.Millisecond, wait)
+
+	off, ok := m.heldFrom("a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), off)
+	_, ok = m.heldFrom("c")
+	assert.False(t, ok)
+
+	// and holds up a3 until then
+	it, wait = m.next(now.Add(window), window, false)
+	assert.Nil(t, it)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	var lines []string
+	for {
+		it, _ := m.next(now.Add(2*window), window, false)
+		if it == nil {
+			break
+		}
+		lines = append(lines, it.rec.Line)
+		m.pop()
+	}
+	// The line without a timestamp follows the record before it
+	assert.Equal(t, []string{"2024-01-01T00:00:02Z b2", "2024-01-01T00:00:03Z a3", "  continued"}, lines)
+
+	// Released records count as held until they were delivered
+	_, ok = m.heldFrom("a")
+	assert.True(t, ok)
+	m.settle()
+	_, ok = m.heldFrom("a")
+	assert.False(t, ok)
+
+	var none *merger
+	_, ok = none.heldFrom("a")
+	assert.False(t, ok)
+}
+
+func TestCollector_MergeWindow(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"),
+		[]byte("2024-01-01T00:00:01Z a1\n2024-01-01T00:00:03Z a3\n2024-01-01T00:00:05Z a5\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.log"),
+		[]byte("2024-01-01T00:00:02Z b2\n2024-01-01T00:00:04Z b4\n"), 0644))
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	c, err := New(WithInclude(dir), WithPollInterval(50*time.Millisecond), WithWorkers(2),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithMergeWindow(200*time.Millisecond, leadingTime),
+		WithOnLine(func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, line)
+		}))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(lines) == 5
+	}, 3*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"2024-01-01T00:00:01Z a1",
+		"2024-01-01T00:00:02Z b2",
+		"2024-01-01T00:00:03Z a3",
+		"2024-01-01T00:00:04Z b4",
+		"2024-01-01T00:00:05Z a5",
+	}, lines)
+}
+
+// Stop delivers what the merge still holds, and offsets are stored past it.
+func TestCollector_MergeWindow_Stop(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.log")
+	data := "2024-01-01T00:00:02Z two\n2024-01-01T00:00:01Z one\n"
+	require.NoError(t, os.WriteFile(p, []byte(data), 0644))
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	c, err := New(WithInclude(dir), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithStore(filepath.Join(t.TempDir(), "offsets.db")),
+		WithMergeWindow(time.Hour, leadingTime),
+		WithOnLine(func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, line)
+		}))
+	require.NoError(t, err)
+	c.Start()
+
+	assert.Eventually(t, func() bool {
+		files := c.Stats().Files
+		return len(files) == 1 && files[0].Position == int64(len(data))
+	}, 2*time.Second, 20*time.Millisecond)
+	// Held records keep the stored offset at the first of them
+	assert.Equal(t, int64(0), c.fileManager.Get(c.fileIDOf(p)).Offset)
+	mu.Lock()
+	assert.Empty(t, lines)
+	mu.Unlock()
+
+	c.Stop()
+	assert.Equal(t, []string{"2024-01-01T00:00:01Z one", "2024-01-01T00:00:02Z two"}, lines)
+	assert.Equal(t, int64(len(data)), c.fileManager.Get(c.fileIDOf(p)).Offset)
+}
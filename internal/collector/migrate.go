@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// OffsetMigration describes rewriting the offsets stored for one fingerprint strategy
+// under another, so the strategy can be switched without re-reading every file.
+type OffsetMigration struct {
+	DBPath   string
+	From, To string // fingerprint strategies
+	// FromFingerprintSize and ToFingerprintSize are the checksum sizes (bytes, or
+	// separators for checksumSeparator); 0 uses watcher.DefaultFingerprintStrategySize.
+	// FromFingerprintSize is needed to verify that a file still has the stored ID.
+	FromFingerprintSize int
+	ToFingerprintSize   int
+	// FromFingerprintOffset and ToFingerprintOffset are where checksum fingerprints
+	// start; see Config.FingerprintOffset.
+	FromFingerprintOffset int64
+	ToFingerprintOffset   int64
+	// Separator is used by the checksumSeparator strategy; empty means "\n".
+	Separator string
+	// DryRun computes the result without writing to the store.
+	DryRun bool
+}
+
+// MigratedOffset is the outcome for one stored offset.
+type MigratedOffset struct {
+	Path   string
+	OldID  string
+	NewID  string // empty when skipped
+	Offset int64
+	// Err explains why the offset was skipped and left in place (file missing, no
+	// longer matching the stored ID, or too small for the new fingerprint); nil when
+	// migrated.
+	Err error
+}
+
+// ErrFileChanged reports that a file on disk no longer has the fingerprint an offset
+// was stored under, typically because it was rotated or rewritten.
+var ErrFileChanged = errors.New("file no longer matches the stored fingerprint")
+
+// MigrateOffsets recomputes fingerprints under m.To for every offset stored under
+// m.From whose file is still on disk and still matches, and moves the offset to the new
+// ID in one transaction. Offsets that cannot be migrated are reported and kept.
+func MigrateOffsets(m OffsetMigration) ([]MigratedOffset, error) {
+	for _, strategy := range []string{m.From, m.To} {
+		wc := watcher.Config{FingerprintStrategy: strategy, FingerprintSize: 1, FingerprintSeparator: "\n"}
+		if err := wc.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if m.From == m.To {
+		return nil, errors.New("source and target fingerprint strategies are the same")
+	}
+
+	db, err := store.NewSQLiteStore(m.DBPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+	renamer, ok := db.(store.Renamer)
+	if !ok {
+		return nil, errors.New("offset store cannot move offsets atomically")
+	}
+
+	entries, err := db.List(m.From)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MigratedOffset, 0, len(entries))
+	for _, e := range entries {
+		res := MigratedOffset{Path: e.Path, OldID: e.ID, Offset: e.Offset}
+		current, err := m.fingerprint(e.Path, m.From, m.FromFingerprintOffset, m.FromFingerprintSize)
+		switch {
+		case err != nil:
+			res.Err = err
+		case current != e.ID:
+			res.Err = ErrFileChanged
+		default:
+			res.NewID, res.Err = m.fingerprint(e.Path, m.To, m.ToFingerprintOffset, m.ToFingerprintSize)
+		}
+		if res.Err == nil && !m.DryRun {
+			if err := renamer.Rename(e.ID, m.From, res.NewID, m.To); err != nil {
+				return results, err
+			}
+		}
+		if res.Err != nil {
+			res.NewID = ""
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// fingerprint computes the ID of the file at path under strategy, like the watcher does.
+// offset only applies to the checksum strategy.
+func (m OffsetMigration) fingerprint(path, strategy string, offset int64, size int) (string, error) {
+	if size <= 0 {
+		size = watcher.DefaultFingerprintStrategySize
+	}
+	switch strategy {
+	case watcher.FingerprintStrategyChecksum:
+		return file_tracker.GetFileFingerprintRangeFromPath(path, offset, int64(size))
+	case watcher.FingerprintStrategyChecksumSeparator:
+		sep := m.Separator
+		if sep == "" {
+			sep = "\n"
+		}
+		return file_tracker.GetFileFingerprintUntilNSeparatorsFromPath(path, sep, size)
+	case watcher.FingerprintStrategyDeviceAndInode:
+		return file_tracker.GetFileIDFromPath(path)
+	default:
+		return "", fmt.Errorf("unsupported fingerprint strategy: %s", strategy)
+	}
+}
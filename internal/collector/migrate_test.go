@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateOffsets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based migration tests on Windows")
+	}
+	dir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	kept := filepath.Join(dir, "kept.log")
+	small := filepath.Join(dir, "small.log")
+	gone := filepath.Join(dir, "gone.log")
+	require.NoError(t, os.WriteFile(kept, []byte("a line long enough to fingerprint\n"), 0644))
+	require.NoError(t, os.WriteFile(small, []byte("tiny\n"), 0644))
+
+	keptID, err := file_tracker.GetFileIDFromPath(kept)
+	require.NoError(t, err)
+	smallID, err := file_tracker.GetFileIDFromPath(small)
+	require.NoError(t, err)
+
+	db, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.Save(keptID, watcher.FingerprintStrategyDeviceAndInode, kept, 10))
+	require.NoError(t, db.Save(smallID, watcher.FingerprintStrategyDeviceAndInode, small, 5))
+	require.NoError(t, db.Save("dev:1-ino:2", watcher.FingerprintStrategyDeviceAndInode, gone, 7))
+	require.NoError(t, db.Close())
+
+	m := OffsetMigration{
+		DBPath:            dbPath,
+		From:              watcher.FingerprintStrategyDeviceAndInode,
+		To:                watcher.FingerprintStrategyChecksum,
+		ToFingerprintSize: 16,
+		DryRun:            true,
+	}
+	results, err := MigrateOffsets(m)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	byPath := map[string]MigratedOffset{}
+	for _, r := range results {
+		byPath[r.Path] = r
+	}
+	assert.NoError(t, byPath[kept].Err)
+	assert.True(t, os.IsNotExist(byPath[gone].Err))
+	assert.True(t, file_tracker.IsFileSizeTooSmall(byPath[small].Err))
+
+	m.DryRun = false
+	_, err = MigrateOffsets(m)
+	require.NoError(t, err)
+
+	db, err = store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	newID, err := file_tracker.GetFileFingerprintFromPath(kept, 16)
+	require.NoError(t, err)
+	offset, found, err := db.Load(newID, watcher.FingerprintStrategyChecksum)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(10), offset)
+	left, err := db.List(watcher.FingerprintStrategyDeviceAndInode)
+	require.NoError(t, err)
+	assert.Len(t, left, 2, "skipped offsets stay under the old strategy")
+
+	// A file rewritten since its offset was stored is not migrated
+	require.NoError(t, os.WriteFile(small+".new", []byte("recreated with new inode\n"), 0644))
+	require.NoError(t, os.Rename(small+".new", small))
+	results, err = MigrateOffsets(m)
+	require.NoError(t, err)
+	for _, r := range results {
+		if r.Path == small {
+			assert.ErrorIs(t, r.Err, ErrFileChanged)
+		}
+	}
+
+	_, err = MigrateOffsets(OffsetMigration{DBPath: dbPath, From: "checksum", To: "checksum"})
+	assert.Error(t, err)
+	_, err = MigrateOffsets(OffsetMigration{DBPath: dbPath, From: "bogus", To: "checksum"})
+	assert.Error(t, err)
+}
+
+// A failure after the new offset was written leaves the store as it was, so a re-run
+// migrates the offset once.
+func TestMigrateOffsets_DeleteFailureRollsBack(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based migration tests on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "app.log")
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	require.NoError(t, os.WriteFile(path, []byte("a line long enough to fingerprint\n"), 0644))
+	oldID, err := file_tracker.GetFileIDFromPath(path)
+	require.NoError(t, err)
+	db, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.Save(oldID, watcher.FingerprintStrategyDeviceAndInode, path, 10))
+	require.NoError(t, db.Close())
+
+	// Fail the delete of the old row
+	raw, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = raw.Exec(`CREATE TRIGGER fail_delete BEFORE DELETE ON offsets BEGIN SELECT RAISE(ABORT, 'injected failure'); END`)
+	require.NoError(t, err)
+
+	m := OffsetMigration{
+		DBPath:            dbPath,
+		From:              watcher.FingerprintStrategyDeviceAndInode,
+		To:                watcher.FingerprintStrategyChecksum,
+		ToFingerprintSize: 16,
+	}
+	_, err = MigrateOffsets(m)
+	require.ErrorContains(t, err, "injected failure")
+
+	db, err = store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	entries, err := db.List("")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	require.Len(t, entries, 1, "the new offset is not kept without deleting the old one")
+	assert.Equal(t, oldID, entries[0].ID)
+
+	_, err = raw.Exec(`DROP TRIGGER fail_delete`)
+	require.NoError(t, err)
+	require.NoError(t, raw.Close())
+	results, err := MigrateOffsets(m)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+}
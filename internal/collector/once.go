@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/loykin/freader/internal/tailer"
+)
+
+// ErrAlreadyStarted is returned by RunOnce for a collector that was already started.
+var ErrAlreadyStarted = errors.New("collector already started")
+
+// RunOnce is the batch alternative to Start: it scans once, reads every matching file
+// from its stored offset to its current end with up to WorkerCount files in parallel,
+// delivers the records, persists the offsets and then stops the collector, closing the
+// Records channel and the offset store. A trailing record without a separator is left
+// for the next run, as when tailing. Cancelling ctx stops handing out files; files
+// already being read are finished. The returned error joins ctx.Err() and the read,
+// store and delivery errors reported through OnErrorFunc, so a cron job can tell a clean run from a
+// partial one. A collector can only be run once, by Start or RunOnce.
+func (c *Collector) RunOnce(ctx context.Context) error {
+	ran := false
+	c.startOnce.Do(func() {
+		ran = true
+		c.started.Store(true)
+	})
+	if !ran {
+		return ErrAlreadyStarted
+	}
+	defer c.Stop()
+
+	c.watcher.Scan()
+	if c.merge != nil {
+		c.workerWg.Add(1)
+		go c.runMerge()
+	}
+
+	workers := max(c.cfg.WorkerCount, 1)
+	work := make(chan *tailer.TailReader)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileTail := range work {
+				if _, err := c.readTail(fileTail, ctx.Done()); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, fileTail := range c.scheduler.Tails() {
+		select {
+		case work <- fileTail:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	if c.merge != nil {
+		// Nothing more arrives to be ordered before what is held
+		c.releaseMerged(true, ctx.Done())
+	}
+
+	return errors.Join(append([]error{ctx.Err()}, errs...)...)
+}
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func onceConfig(dir, dbPath string, onLine func(string)) Config {
+	return Config{
+		Include:             []string{dir},
+		PollInterval:        time.Hour,
+		WorkerCount:         2,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     3,
+		StoreOffsets:        true,
+		DBPath:              dbPath,
+		OnLineFunc:          onLine,
+	}
+}
+
+func TestCollector_RunOnce(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	a := filepath.Join(dir, "a.log")
+	require.NoError(t, os.WriteFile(a, []byte("a1\na2\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.log"), []byte("b1\npartial"), 0644))
+
+	run := func() []string {
+		var mu sync.Mutex
+		var lines []string
+		c, err := NewCollector(onceConfig(dir, dbPath, func(line string) {
+			mu.Lock()
+			lines = append(lines, line)
+			mu.Unlock()
+		}))
+		require.NoError(t, err)
+		require.NoError(t, c.RunOnce(context.Background()))
+		sort.Strings(lines)
+		return lines
+	}
+
+	// The unterminated record waits for its separator
+	assert.Equal(t, []string{"a1", "a2", "b1"}, run())
+
+	// Offsets were stored: a second run only sees new data
+	f, err := os.OpenFile(a, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("a3\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, []string{"a3"}, run())
+	assert.Empty(t, run())
+}
+
+func TestCollector_RunOnce_Records(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte("one\ntwo\n"), 0644))
+
+	cfg := onceConfig(dir, "", nil)
+	cfg.StoreOffsets = false
+	c, err := NewCollector(cfg)
+	require.NoError(t, err)
+	records := c.Records()
+
+	done := make(chan error, 1)
+	go func() { done <- c.RunOnce(context.Background()) }()
+
+	var got []string
+	for rec := range records {
+		got = append(got, rec.Line)
+	}
+	assert.Equal(t, []string{"one", "two"}, got)
+	assert.NoError(t, <-done)
+}
+
+func TestCollector_RunOnce_Errors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte("one\n"), 0644))
+
+	cfg := onceConfig(dir, "", func(string) {})
+	cfg.StoreOffsets = false
+
+	c, err := NewCollector(cfg)
+	require.NoError(t, err)
+	c.Start()
+	c.Stop()
+	assert.ErrorIs(t, c.RunOnce(context.Background()), ErrAlreadyStarted)
+
+	c, err = NewCollector(cfg)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.RunOnce(ctx), context.Canceled)
+}
@@ -0,0 +1,630 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/loykin/freader/internal/clock"
+	"github.com/loykin/freader/internal/metrics"
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/tailer"
+)
+
+// Option configures a Collector built with New.
+type Option func(*Config) error
+
+// New builds a Collector from functional options on top of Config.Default, except that
+// offsets are only stored when WithStore is given. The resulting Config is validated
+// before the collector is created.
+func New(opts ...Option) (*Collector, error) {
+	var cfg Config
+	cfg.Default()
+	cfg.StoreOffsets = false
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return NewCollector(cfg)
+}
+
+// WithInclude appends include patterns or directories.
+func WithInclude(patterns ...string) Option {
+	return func(c *Config) error {
+		c.Include = append(c.Include, patterns...)
+		return nil
+	}
+}
+
+// WithExclude appends exclude patterns.
+func WithExclude(patterns ...string) Option {
+	return func(c *Config) error {
+		c.Exclude = append(c.Exclude, patterns...)
+		return nil
+	}
+}
+
+// WithExcludeDirs appends patterns for directories scans do not descend into; see
+// Config.ExcludeDirs.
+func WithExcludeDirs(patterns ...string) Option {
+	return func(c *Config) error {
+		c.ExcludeDirs = append(c.ExcludeDirs, patterns...)
+		return nil
+	}
+}
+
+// WithIgnoreFile reads gitignore-style files of this name from the include roots; see
+// Config.IgnoreFile.
+func WithIgnoreFile(name string) Option {
+	return func(c *Config) error {
+		c.IgnoreFile = name
+		return nil
+	}
+}
+
+// WithSeparator sets the record separator.
+func WithSeparator(sep string) Option {
+	return func(c *Config) error {
+		if sep == "" {
+			return errors.New("separator must not be empty")
+		}
+		c.Separator = sep
+		return nil
+	}
+}
+
+// WithSeparatorRegex splits records on matches of expr; see Config.SeparatorRegex.
+func WithSeparatorRegex(expr string) Option {
+	return func(c *Config) error {
+		if _, err := tailer.CompileSeparatorRegex(expr); err != nil {
+			return err
+		}
+		c.SeparatorRegex = expr
+		return nil
+	}
+}
+
+// WithSeparatorAutoDetect chooses each file's separator from its line endings; see
+// Config.SeparatorAutoDetect.
+func WithSeparatorAutoDetect() Option {
+	return func(c *Config) error {
+		c.SeparatorAutoDetect = true
+		return nil
+	}
+}
+
+// WithSeparatorRule splits files matching pattern on any of seps; see Config.SeparatorRules.
+func WithSeparatorRule(pattern string, seps ...string) Option {
+	return func(c *Config) error {
+		rule := SeparatorRule{Pattern: pattern, Separators: seps}
+		if _, err := compileSeparatorRules([]SeparatorRule{rule}); err != nil {
+			return err
+		}
+		c.SeparatorRules = append(c.SeparatorRules, rule)
+		return nil
+	}
+}
+
+// WithPriorityRule gives files matching pattern the scheduling weight; see
+// Config.PriorityRules.
+func WithPriorityRule(pattern string, weight int) Option {
+	return func(c *Config) error {
+		rule := PriorityRule{Pattern: pattern, Weight: weight}
+		if err := validatePriorityRules([]PriorityRule{rule}); err != nil {
+			return err
+		}
+		c.PriorityRules = append(c.PriorityRules, rule)
+		return nil
+	}
+}
+
+// WithLengthPrefix reads length-prefixed binary records with a prefix of width bytes
+// (0 for varint) in the given byte order (nil for big endian); see Config.LengthPrefix.
+func WithLengthPrefix(width int, order binary.ByteOrder) Option {
+	return func(c *Config) error {
+		lp := &tailer.LengthPrefix{Width: width, ByteOrder: order}
+		if err := lp.Validate(); err != nil {
+			return err
+		}
+		c.LengthPrefix = lp
+		return nil
+	}
+}
+
+// WithNetworkFS enables the NFS/SMB safety mode, tolerating retries consecutive
+// failures per file (0 = DefaultNetworkFSRetries); see Config.NetworkFS.
+func WithNetworkFS(retries int) Option {
+	return func(c *Config) error {
+		if retries < 0 {
+			return errors.New("network fs retries must not be negative")
+		}
+		c.NetworkFS = true
+		c.NetworkFSRetries = retries
+		return nil
+	}
+}
+
+// WithHoldDeleted reads files deleted while open to their end, releasing them after
+// idle without new data; see Config.HoldDeleted.
+func WithHoldDeleted(idle time.Duration) Option {
+	return func(c *Config) error {
+		if idle < 0 {
+			return errors.New("hold deleted must not be negative")
+		}
+		c.HoldDeleted = idle
+		return nil
+	}
+}
+
+// WithOffsetMismatch sets what happens to files whose stored offset fails verification
+// on resume: OffsetMismatchStart, OffsetMismatchEnd or OffsetMismatchQuarantine; see
+// Config.OffsetMismatch.
+func WithOffsetMismatch(policy string) Option {
+	return func(c *Config) error {
+		c.OffsetMismatch = policy
+		return nil
+	}
+}
+
+// WithKeepDuplicates reads a file reachable at several watched paths once per path
+// instead of once; see Config.KeepDuplicates.
+func WithKeepDuplicates() Option {
+	return func(c *Config) error {
+		c.KeepDuplicates = true
+		return nil
+	}
+}
+
+// WithReadOnlyOffsets resumes from stored offsets without ever writing them; see
+// Config.ReadOnlyOffsets.
+func WithReadOnlyOffsets() Option {
+	return func(c *Config) error {
+		c.ReadOnlyOffsets = true
+		return nil
+	}
+}
+
+// WithSkipLocked quietly skips files another process holds locked; see
+// Config.SkipLocked.
+func WithSkipLocked() Option {
+	return func(c *Config) error {
+		c.SkipLocked = true
+		return nil
+	}
+}
+
+// WithPollInterval sets how often the watcher scans for files.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Config) error {
+		if d <= 0 {
+			return errors.New("poll interval must be greater than 0")
+		}
+		c.PollInterval = d
+		return nil
+	}
+}
+
+// WithScanBudget limits the time and the number of files one scan spends per poll
+// tick (0 = no limit), resuming on the next tick; see Config.ScanBudget.
+func WithScanBudget(budget time.Duration, maxFiles int) Option {
+	return func(c *Config) error {
+		if budget < 0 || maxFiles < 0 {
+			return errors.New("scan budget and max files must not be negative")
+		}
+		c.ScanBudget = budget
+		c.ScanMaxFiles = maxFiles
+		return nil
+	}
+}
+
+// WithWorkers sets the number of reader goroutines.
+func WithWorkers(n int) Option {
+	return func(c *Config) error {
+		if n <= 0 {
+			return errors.New("worker count must be greater than 0")
+		}
+		c.WorkerCount = n
+		return nil
+	}
+}
+
+// WithFingerprint selects the fingerprint strategy and size (bytes for checksum,
+// separators for checksumSeparator; ignored for deviceAndInode).
+func WithFingerprint(strategy string, size int) Option {
+	return func(c *Config) error {
+		c.FingerprintStrategy = strategy
+		c.FingerprintSize = size
+		return nil
+	}
+}
+
+// WithFingerprintOffset makes the checksum fingerprint start at offset; see
+// Config.FingerprintOffset.
+func WithFingerprintOffset(offset int64) Option {
+	return func(c *Config) error {
+		c.FingerprintOffset = offset
+		return nil
+	}
+}
+
+// WithFingerprintUpgrade reads files too small for a checksum fingerprint under a
+// provisional ID until they have grown; see Config.UpgradeFingerprints.
+func WithFingerprintUpgrade() Option {
+	return func(c *Config) error {
+		c.UpgradeFingerprints = true
+		return nil
+	}
+}
+
+// WithMultiline enables multiline grouping.
+func WithMultiline(m *tailer.MultilineReader) Option {
+	return func(c *Config) error {
+		c.Multiline = m
+		return nil
+	}
+}
+
+// WithRepeatWindow collapses identical consecutive records of a file arriving within
+// window into one summary record; see Config.RepeatWindow.
+func WithRepeatWindow(window time.Duration) Option {
+	return func(c *Config) error {
+		if window < 0 {
+			return errors.New("repeat window must not be negative")
+		}
+		c.RepeatWindow = window
+		return nil
+	}
+}
+
+// WithMultilineRule groups files matching pattern with m, or not at all if m is nil;
+// see Config.MultilineRules.
+func WithMultilineRule(pattern string, m *tailer.MultilineReader) Option {
+	return func(c *Config) error {
+		rule := MultilineRule{Pattern: pattern, Multiline: m}
+		if err := validateMultilineRules([]MultilineRule{rule}); err != nil {
+			return err
+		}
+		c.MultilineRules = append(c.MultilineRules, rule)
+		return nil
+	}
+}
+
+// WithStore persists offsets in the SQLite database at dbPath.
+func WithStore(dbPath string) Option {
+	return func(c *Config) error {
+		if dbPath == "" {
+			return errors.New("db path must not be empty")
+		}
+		c.DBPath = dbPath
+		c.StoreOffsets = true
+		return nil
+	}
+}
+
+// WithStoreMaintenance checkpoints and vacuums the offset store every interval (0 disables
+// it) and, with rebuildCorrupt, rebuilds a store failing its integrity check on startup;
+// see Config.StoreMaintenanceInterval and Config.RebuildCorruptStore.
+func WithStoreMaintenance(interval time.Duration, rebuildCorrupt bool) Option {
+	return func(c *Config) error {
+		if interval < 0 {
+			return errors.New("store maintenance interval must not be negative")
+		}
+		c.StoreMaintenanceInterval = interval
+		c.RebuildCorruptStore = rebuildCorrupt
+		return nil
+	}
+}
+
+// WithLease names the collector instanceID (empty for the default) in the offset store
+// lease and sets its TTL (0 disables the lease); see Config.LeaseTTL.
+func WithLease(instanceID string, ttl time.Duration) Option {
+	return func(c *Config) error {
+		if ttl < 0 {
+			return errors.New("lease ttl must not be negative")
+		}
+		c.InstanceID = instanceID
+		c.LeaseTTL = ttl
+		return nil
+	}
+}
+
+// WithStandby runs the collector as a redundant instance that reads only while it holds
+// the lease; see Config.Standby.
+func WithStandby() Option {
+	return func(c *Config) error {
+		c.Standby = true
+		return nil
+	}
+}
+
+// WithLeaser holds the lease with l instead of the offset store; see Config.Leaser.
+func WithLeaser(l store.Leaser) Option {
+	return func(c *Config) error {
+		if l == nil {
+			return errors.New("leaser must not be nil")
+		}
+		c.Leaser = l
+		return nil
+	}
+}
+
+// WithShard makes the collector shard index of count, reading only its share of the
+// files; see Config.ShardCount.
+func WithShard(index, count int) Option {
+	return func(c *Config) error {
+		if count <= 0 || index < 0 || index >= count {
+			return fmt.Errorf("shard index %d must be in [0, %d)", index, count)
+		}
+		c.ShardIndex = index
+		c.ShardCount = count
+		return nil
+	}
+}
+
+// WithShardDiscovery shards files between the collectors registered in the shared
+// offset store, each staying registered for ttl without heartbeating (0 uses
+// DefaultShardMemberTTL); see Config.ShardDiscovery.
+func WithShardDiscovery(ttl time.Duration) Option {
+	return func(c *Config) error {
+		if ttl < 0 {
+			return errors.New("shard member ttl must not be negative")
+		}
+		c.ShardDiscovery = true
+		c.ShardMemberTTL = ttl
+		return nil
+	}
+}
+
+// WithMetrics reports the collector's metrics to m instead of the process-wide set.
+func WithMetrics(m *metrics.Set) Option {
+	return func(c *Config) error {
+		c.Metrics = m
+		return nil
+	}
+}
+
+// WithScanTrace records why each scanned file was included, excluded or skipped; see
+// Config.TraceScans.
+func WithScanTrace() Option {
+	return func(c *Config) error {
+		c.TraceScans = true
+		return nil
+	}
+}
+
+// WithRetainLastN keeps the last n records delivered for Collector.Recent; see
+// Config.RetainLastN.
+func WithRetainLastN(n int) Option {
+	return func(c *Config) error {
+		if n < 0 {
+			return errors.New("retain last n must not be negative")
+		}
+		c.RetainLastN = n
+		return nil
+	}
+}
+
+// WithClock drives the collector's tickers, timeouts and back-off from clk; see Config.Clock.
+func WithClock(clk clock.Clock) Option {
+	return func(c *Config) error {
+		c.Clock = clk
+		return nil
+	}
+}
+
+// WithOnLine sets the per-line callback.
+func WithOnLine(fn func(line string)) Option {
+	return func(c *Config) error {
+		c.OnLineFunc = fn
+		return nil
+	}
+}
+
+// WithOnLineBytes sets the allocation-free per-line callback; see Config.OnLineBytesFunc.
+func WithOnLineBytes(fn func(b []byte)) Option {
+	return func(c *Config) error {
+		c.OnLineBytesFunc = fn
+		return nil
+	}
+}
+
+// WithOnEvent sets the per-line callback receiving file metadata; it takes precedence over WithOnLine.
+func WithOnEvent(fn func(event LineEvent)) Option {
+	return func(c *Config) error {
+		c.OnEventFunc = fn
+		return nil
+	}
+}
+
+// WithOnError sets Config.OnErrorFunc.
+func WithOnError(fn func(err error, ctx ErrorContext)) Option {
+	return func(c *Config) error {
+		c.OnErrorFunc = fn
+		return nil
+	}
+}
+
+// WithLogger sets the logger used by the collector and its components.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Config) error {
+		c.Logger = l
+		return nil
+	}
+}
+
+// WithFromBeginning ignores stored offsets, for all files or only those matching patterns.
+func WithFromBeginning(patterns ...string) Option {
+	return func(c *Config) error {
+		c.FromBeginning = true
+		c.FromBeginningPatterns = append(c.FromBeginningPatterns, patterns...)
+		return nil
+	}
+}
+
+// WithFollowName reads only the file currently at each path, like tail -F; see
+// Config.FollowName.
+func WithFollowName() Option {
+	return func(c *Config) error {
+		c.FollowName = true
+		return nil
+	}
+}
+
+// WithStartFromTime skips records older than t in files read from the beginning.
+func WithStartFromTime(t time.Time, timestampFunc func(record string) (time.Time, bool)) Option {
+	return func(c *Config) error {
+		c.StartFromTime = t
+		c.TimestampFunc = timestampFunc
+		return nil
+	}
+}
+
+// WithMergeWindow delivers the records of all files ordered by the event time that
+// timestampFunc extracts, holding each for window; see Config.MergeWindow.
+func WithMergeWindow(window time.Duration, timestampFunc func(record string) (time.Time, bool)) Option {
+	return func(c *Config) error {
+		c.MergeWindow = window
+		c.TimestampFunc = timestampFunc
+		return c.validateMergeWindow()
+	}
+}
+
+// WithRecordsBuffer sets the capacity of the Collector.Records channel.
+func WithRecordsBuffer(n int) Option {
+	return func(c *Config) error {
+		if n < 0 {
+			return errors.New("records buffer must not be negative")
+		}
+		c.RecordsBuffer = n
+		return nil
+	}
+}
+
+// WithOnLines sets the batch callback; see Config.OnLinesFunc.
+func WithOnLines(fn func(lines []Record)) Option {
+	return func(c *Config) error {
+		c.OnLinesFunc = fn
+		return nil
+	}
+}
+
+// WithOnLinesAck sets the acknowledged batch callback; see Config.OnLinesAckFunc.
+func WithOnLinesAck(fn func(lines []Record) (wait func() error)) Option {
+	return func(c *Config) error {
+		c.OnLinesAckFunc = fn
+		return nil
+	}
+}
+
+// WithOnLinesErr sets a batch callback that returns once the records are delivered,
+// or with the error that kept them from it; see Config.OnLinesAckFunc.
+func WithOnLinesErr(fn func(lines []Record) error) Option {
+	return func(c *Config) error {
+		c.OnLinesAckFunc = func(lines []Record) func() error {
+			err := fn(lines)
+			return func() error { return err }
+		}
+		return nil
+	}
+}
+
+// WithLinesBatch bounds OnLinesFunc batches by record count, line bytes and age
+// (zero keeps the default for size and leaves bytes/age unbounded).
+func WithLinesBatch(size, maxBytes int, interval time.Duration) Option {
+	return func(c *Config) error {
+		if size < 0 || maxBytes < 0 || interval < 0 {
+			return errors.New("lines batch limits must not be negative")
+		}
+		c.LinesBatchSize = size
+		c.LinesBatchBytes = maxBytes
+		c.LinesBatchInterval = interval
+		return nil
+	}
+}
+
+// WithCatchUp reads files far behind their end in chunks of chunkSize bytes with up to
+// readers goroutines on their first read; see Config.CatchUpChunkSize.
+func WithCatchUp(chunkSize int64, readers int) Option {
+	return func(c *Config) error {
+		if chunkSize < 0 || readers < 0 {
+			return errors.New("catch-up chunk size and readers must not be negative")
+		}
+		c.CatchUpChunkSize = chunkSize
+		c.CatchUpReaders = readers
+		return nil
+	}
+}
+
+// WithStartupThrottle limits the first pass over the files found by the first scan to
+// bytesPerSecond combined and starts reading them stagger apart; see
+// Config.StartupReadRateLimit.
+func WithStartupThrottle(bytesPerSecond int64, stagger time.Duration) Option {
+	return func(c *Config) error {
+		if bytesPerSecond < 0 || stagger < 0 {
+			return errors.New("startup read rate limit and stagger must not be negative")
+		}
+		c.StartupReadRateLimit = bytesPerSecond
+		c.StartupStagger = stagger
+		return nil
+	}
+}
+
+// WithPostgresStore keeps offsets in the PostgreSQL database at dsn, connecting with the
+// registered database/sql driver named driver ("pgx" when empty), under namespace; see
+// Config.PostgresDSN. It enables StoreOffsets.
+func WithPostgresStore(driver, dsn, namespace string) Option {
+	return func(c *Config) error {
+		if dsn == "" {
+			return errors.New("postgres dsn must not be empty")
+		}
+		c.PostgresDriver = driver
+		c.PostgresDSN = dsn
+		c.PostgresNamespace = namespace
+		c.StoreOffsets = true
+		return nil
+	}
+}
+
+// WithCallbackTimeout reports record callbacks running longer than timeout as stalled
+// and, with skip, drops records instead of waiting for them; see Config.CallbackTimeout.
+func WithCallbackTimeout(timeout time.Duration, skip bool) Option {
+	return func(c *Config) error {
+		if timeout < 0 {
+			return errors.New("callback timeout must not be negative")
+		}
+		c.CallbackTimeout = timeout
+		c.SkipStalledCallbacks = skip
+		return nil
+	}
+}
+
+// WithIdleFiles reads files without new data for after only every recheck (0 = 1s);
+// see Config.IdleAfter.
+func WithIdleFiles(after, recheck time.Duration) Option {
+	return func(c *Config) error {
+		if after < 0 || recheck < 0 {
+			return errors.New("idle after and recheck interval must not be negative")
+		}
+		c.IdleAfter = after
+		c.IdleRecheckInterval = recheck
+		return nil
+	}
+}
+
+// WithBufferSizes sets Config.ReadBufferSize and Config.ChunkBufferSize.
+func WithBufferSizes(readSize, chunkSize int) Option {
+	return func(c *Config) error {
+		if readSize < 0 || chunkSize < 0 {
+			return errors.New("read and chunk buffer sizes must not be negative")
+		}
+		c.ReadBufferSize = readSize
+		c.ChunkBufferSize = chunkSize
+		return nil
+	}
+}
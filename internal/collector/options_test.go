@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Options(t *testing.T) {
+	tempDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "app.log"), []byte("x<END>y<END>"), 0644))
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+
+	lines := make(chan string, 2)
+	c, err := New(
+		WithInclude(tempDir),
+		WithExclude("*.tmp"),
+		WithSeparator("<END>"),
+		WithPollInterval(50*time.Millisecond),
+		WithWorkers(2),
+		WithFingerprint(watcher.FingerprintStrategyChecksum, 4),
+		WithStore(dbPath),
+		WithOnLine(func(line string) { lines <- line }),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{tempDir}, c.cfg.Include)
+	assert.Equal(t, []string{"*.tmp"}, c.cfg.Exclude)
+	assert.Equal(t, 2, c.cfg.WorkerCount)
+	assert.True(t, c.cfg.StoreOffsets)
+
+	c.Start()
+	defer c.Stop()
+	for _, want := range []string{"x", "y"} {
+		select {
+		case got := <-lines:
+			assert.Equal(t, want, got)
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for line")
+		}
+	}
+	_, err = os.Stat(dbPath)
+	assert.NoError(t, err)
+}
+
+func TestNew_Defaults(t *testing.T) {
+	c, err := New(WithInclude(t.TempDir()))
+	assert.NoError(t, err)
+	assert.False(t, c.cfg.StoreOffsets, "offsets are opt-in with WithStore")
+	assert.Equal(t, "\n", c.cfg.Separator)
+	assert.Equal(t, 1, c.cfg.WorkerCount)
+}
+
+func TestNew_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "empty separator", opts: []Option{WithSeparator("")}},
+		{name: "zero workers", opts: []Option{WithWorkers(0)}},
+		{name: "zero poll interval", opts: []Option{WithPollInterval(0)}},
+		{name: "empty db path", opts: []Option{WithStore("")}},
+		{name: "negative records buffer", opts: []Option{WithRecordsBuffer(-1)}},
+		{name: "negative retain last n", opts: []Option{WithRetainLastN(-1)}},
+		{name: "checksum without size", opts: []Option{WithFingerprint(watcher.FingerprintStrategyChecksum, 0)}},
+		{name: "unknown strategy", opts: []Option{WithFingerprint("md5", 8)}},
+		{name: "start time without timestamp func", opts: []Option{WithStartFromTime(time.Now(), nil)}},
+		{name: "merge window without timestamp func", opts: []Option{WithMergeWindow(time.Second, nil)}},
+		{name: "negative catch-up chunk size", opts: []Option{WithCatchUp(-1, 0)}},
+		{name: "negative fingerprint offset", opts: []Option{WithFingerprintOffset(-1)}},
+		{name: "fingerprint offset without checksum", opts: []Option{WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0), WithFingerprintOffset(16)}},
+		{name: "fingerprint upgrade without checksum", opts: []Option{WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0), WithFingerprintUpgrade()}},
+		{name: "ignore file path", opts: []Option{WithIgnoreFile("dir/.freaderignore")}},
+		{name: "negative scan budget", opts: []Option{WithScanBudget(-time.Second, 0)}},
+		{name: "negative idle after", opts: []Option{WithIdleFiles(-time.Second, 0)}},
+		{name: "zero priority weight", opts: []Option{WithPriorityRule("*.log", 0)}},
+		{name: "priority weight past stride unit", opts: []Option{WithPriorityRule("*.log", strideUnit+1)}},
+		{name: "bad priority pattern", opts: []Option{WithPriorityRule("[", 2)}},
+		{name: "empty postgres dsn", opts: []Option{WithPostgresStore("", "", "")}},
+		{name: "shard index past count", opts: []Option{WithShard(2, 2)}},
+		{name: "shard discovery without store", opts: []Option{WithShardDiscovery(0)}},
+		{name: "unregistered postgres driver", opts: []Option{WithPostgresStore("no-such-driver", "postgres://localhost/freader", "")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts...)
+			assert.Error(t, err)
+		})
+	}
+}
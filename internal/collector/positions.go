@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// filebeatState holds the fields of a Filebeat registry value used for importing: the
+// log input stores source/offset/FileStateOS, the filestream input meta.source and
+// cursor.offset.
+type filebeatState struct {
+	Source      string `json:"source"`
+	Offset      *int64 `json:"offset"`
+	FileStateOS struct {
+		Inode  uint64 `json:"inode"`
+		Device uint64 `json:"device"`
+	} `json:"FileStateOS"`
+	Meta struct {
+		Source string `json:"source"`
+	} `json:"meta"`
+	Cursor struct {
+		Offset *int64 `json:"offset"`
+	} `json:"cursor"`
+}
+
+// ReadFilebeatRegistry reads file positions from a Filebeat registry: the registry
+// directory of Filebeat 7 and later (data/registry/filebeat, holding active.dat, the
+// checkpoint it names and the log.json written since), one of those files, or the single
+// JSON file of Filebeat 6. Entries of the log and filestream inputs are returned, sorted
+// by path; a path can appear more than once when rotated files are still registered.
+func ReadFilebeatRegistry(path string) ([]ForeignPosition, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]json.RawMessage)
+	if !info.IsDir() {
+		if err := readFilebeatFile(path, states); err != nil {
+			return nil, err
+		}
+		return filebeatPositions(states)
+	}
+
+	// active.dat names the current checkpoint, possibly by its absolute path on the
+	// original host, so only the file name is used
+	if active, err := os.ReadFile(filepath.Join(path, "active.dat")); err == nil {
+		if name := strings.TrimSpace(string(active)); name != "" {
+			if err := readFilebeatFile(filepath.Join(path, filepath.Base(name)), states); err != nil {
+				return nil, err
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := readFilebeatFile(filepath.Join(path, "log.json"), states); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return filebeatPositions(states)
+}
+
+// readFilebeatFile adds the entries of a registry file to states: a JSON array (a
+// checkpoint, whose entries carry their key in "_key", or a Filebeat 6 registry) or a
+// log.json stream of {"op":...} / {"k":...,"v":...} pairs, applied in order.
+func readFilebeatFile(path string, states map[string]json.RawMessage) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	if data[0] == '[' {
+		var entries []json.RawMessage
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("parse filebeat registry %s: %w", path, err)
+		}
+		for i, raw := range entries {
+			var key struct {
+				Key string `json:"_key"`
+			}
+			if err := json.Unmarshal(raw, &key); err != nil {
+				return fmt.Errorf("parse filebeat registry %s: %w", path, err)
+			}
+			if key.Key == "" {
+				key.Key = "#" + strconv.Itoa(i)
+			}
+			states[key.Key] = raw
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	op := "set"
+	for {
+		var line struct {
+			Op string          `json:"op"`
+			K  string          `json:"k"`
+			V  json.RawMessage `json:"v"`
+		}
+		if err := dec.Decode(&line); err == io.EOF {
+			return nil
+		} else if err != nil {
+			// A crash can leave a partial last line; keep what was read
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("parse filebeat registry %s: %w", path, err)
+		}
+		switch {
+		case line.Op != "":
+			op = line.Op
+		case line.K != "" && op == "remove":
+			delete(states, line.K)
+		case line.K != "":
+			states[line.K] = line.V
+		}
+	}
+}
+
+// filebeatPositions converts registry values into positions, skipping entries that are
+// not file states.
+func filebeatPositions(states map[string]json.RawMessage) ([]ForeignPosition, error) {
+	positions := make([]ForeignPosition, 0, len(states))
+	for key, raw := range states {
+		var st filebeatState
+		if err := json.Unmarshal(raw, &st); err != nil {
+			return nil, fmt.Errorf("parse filebeat registry entry %s: %w", key, err)
+		}
+		p := ForeignPosition{Path: st.Source, Device: st.FileStateOS.Device, Inode: st.FileStateOS.Inode}
+		offset := st.Offset
+		if p.Path == "" {
+			p.Path = st.Meta.Source
+			offset = st.Cursor.Offset
+		}
+		if p.Path == "" || offset == nil {
+			continue
+		}
+		p.Offset = *offset
+		if p.Inode == 0 {
+			p.Inode, p.Device = nativeFileIdentity(key)
+		}
+		positions = append(positions, p)
+	}
+	sortPositions(positions)
+	return positions, nil
+}
+
+// nativeFileIdentity parses the "<inode>-<device>" suffix of a registry key using the
+// native file identity, e.g. "filestream::my-id::native::1234-2049".
+func nativeFileIdentity(key string) (inode, device uint64) {
+	_, suffix, ok := strings.Cut(key, "::native::")
+	if !ok {
+		return 0, 0
+	}
+	ino, dev, _ := strings.Cut(suffix, "-")
+	inode, _ = strconv.ParseUint(ino, 10, 64)
+	device, _ = strconv.ParseUint(dev, 10, 64)
+	return inode, device
+}
+
+// ReadPromtailPositions reads file positions from a Promtail positions.yaml. Entries
+// that are not byte offsets, such as journal cursors, are skipped.
+func ReadPromtailPositions(path string) ([]ForeignPosition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Positions map[string]string `yaml:"positions"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse promtail positions %s: %w", path, err)
+	}
+	positions := make([]ForeignPosition, 0, len(file.Positions))
+	for p, value := range file.Positions {
+		offset, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		positions = append(positions, ForeignPosition{Path: p, Offset: offset})
+	}
+	sortPositions(positions)
+	return positions, nil
+}
+
+func sortPositions(positions []ForeignPosition) {
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Path != positions[j].Path {
+			return positions[i].Path < positions[j].Path
+		}
+		return positions[i].Inode < positions[j].Inode
+	})
+}
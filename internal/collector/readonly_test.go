@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_ReadOnlyOffsets(t *testing.T) {
+	base := t.TempDir()
+	p := filepath.Join(base, "app.log")
+	dbPath := filepath.Join(base, "offsets.db")
+	require.NoError(t, os.WriteFile(p, []byte("first record\n"), 0644))
+
+	run := func(readOnly bool, want ...string) {
+		t.Helper()
+		sink := testkit.NewLineSink()
+		opts := []Option{WithInclude(p), WithPollInterval(50 * time.Millisecond), WithStore(dbPath),
+			WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0), WithOnLine(sink.Add)}
+		if readOnly {
+			opts = append(opts, WithReadOnlyOffsets())
+		}
+		c, err := New(opts...)
+		require.NoError(t, err)
+		c.Start()
+		require.True(t, sink.Wait(len(want), 3*time.Second), "got %q", sink.Lines())
+		time.Sleep(200 * time.Millisecond)
+		c.Stop()
+		assert.Equal(t, want, sink.Lines())
+	}
+
+	run(false, "first record")
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("second record\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// Both read-only runs resume from the stored offset and leave it where it was
+	run(true, "second record")
+	run(true, "second record")
+	run(false, "second record")
+}
+
+func TestConfig_ReadOnlyOffsetsValidation(t *testing.T) {
+	_, err := New(WithInclude(t.TempDir()), WithStore(filepath.Join(t.TempDir(), "offsets.db")), WithShardDiscovery(time.Minute), WithReadOnlyOffsets())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read-only offsets")
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// recentRecords keeps the last records delivered, see Config.RetainLastN. A nil
+// *recentRecords keeps nothing.
+type recentRecords struct {
+	mu   sync.Mutex
+	buf  []Record
+	next int
+	full bool
+}
+
+func newRecentRecords(n int) *recentRecords {
+	if n <= 0 {
+		return nil
+	}
+	return &recentRecords{buf: make([]Record, n)}
+}
+
+func (r *recentRecords) add(rec Record) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = rec
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the kept records accepted by filter (all when nil), oldest first.
+func (r *recentRecords) list(filter func(Record) bool) []Record {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Record
+	keep := func(recs []Record) {
+		for _, rec := range recs {
+			if filter == nil || filter(rec) {
+				out = append(out, rec)
+			}
+		}
+	}
+	if r.full {
+		keep(r.buf[r.next:])
+	}
+	keep(r.buf[:r.next])
+	return out
+}
+
+// Recent returns the last Config.RetainLastN records delivered that filter accepts,
+// oldest first; a nil filter accepts all. It returns nil unless RetainLastN is set.
+func (c *Collector) Recent(filter func(Record) bool) []Record {
+	return c.recent.list(filter)
+}
+
+// RecentRecord is the JSON form of a record served by RecentHandler.
+type RecentRecord struct {
+	File    string    `json:"file"`
+	Line    string    `json:"line"`
+	Time    time.Time `json:"time"`
+	Repeats int       `json:"repeats,omitempty"`
+}
+
+// RecentHandler serves Recent as JSON, oldest first. The query parameters file (a glob
+// matched against the path or its base name), contains (a substring of the line) and
+// limit (the newest n matches) narrow the result.
+func (c *Collector) RecentHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		file, contains := q.Get("file"), q.Get("contains")
+		limit := 0
+		if s := q.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		recs := c.Recent(func(rec Record) bool {
+			if file != "" && !watcher.MatchesAny(rec.File, []string{file}) {
+				return false
+			}
+			return strings.Contains(rec.Line, contains)
+		})
+		if limit > 0 && len(recs) > limit {
+			recs = recs[len(recs)-limit:]
+		}
+		out := make([]RecentRecord, len(recs))
+		for i, rec := range recs {
+			out[i] = RecentRecord{File: rec.File, Line: rec.Line, Time: rec.Ts, Repeats: rec.Repeats}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+	})
+}
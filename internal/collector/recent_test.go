@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentRecords(t *testing.T) {
+	assert.Nil(t, newRecentRecords(0))
+	var none *recentRecords
+	none.add(Record{Line: "ignored"})
+	assert.Nil(t, none.list(nil))
+
+	r := newRecentRecords(3)
+	for _, l := range []string{"a", "b"} {
+		r.add(Record{Line: l})
+	}
+	assert.Equal(t, []Record{{Line: "a"}, {Line: "b"}}, r.list(nil))
+	for _, l := range []string{"c", "d", "e"} {
+		r.add(Record{Line: l})
+	}
+	assert.Equal(t, []Record{{Line: "c"}, {Line: "d"}, {Line: "e"}}, r.list(nil))
+	assert.Equal(t, []Record{{Line: "d"}}, r.list(func(rec Record) bool { return rec.Line == "d" }))
+}
+
+func TestCollector_Recent(t *testing.T) {
+	tempDir := t.TempDir()
+	a := filepath.Join(tempDir, "app.log")
+	b := filepath.Join(tempDir, "db.log")
+	require.NoError(t, os.WriteFile(a, []byte("app INFO 1\napp ERROR 2\napp ERROR 3\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("db ERROR 1\n"), 0644))
+
+	c, err := New(WithInclude(tempDir), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithRetainLastN(3),
+		WithOnLine(func(string) {}))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+	assert.Eventually(t, func() bool { return c.Stats().LinesRead == 4 }, 3*time.Second, 20*time.Millisecond)
+
+	// Only the last three records are kept
+	recent := c.Recent(nil)
+	require.Len(t, recent, 3)
+	lines := c.Recent(func(rec Record) bool { return strings.HasPrefix(rec.Line, "app") })
+	for _, rec := range lines {
+		assert.Equal(t, a, rec.File)
+	}
+
+	srv := httptest.NewServer(c.RecentHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?file=app*.log&contains=ERROR&limit=1")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var got []RecentRecord
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Len(t, got, 1)
+	assert.Equal(t, "app ERROR 3", got[0].Line)
+	assert.Equal(t, a, got[0].File)
+	assert.False(t, got[0].Time.IsZero())
+
+	resp, err = http.Get(srv.URL + "?limit=x")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
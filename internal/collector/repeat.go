@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+)
+
+// repeatRun collapses consecutive identical records of one file; see Config.RepeatWindow.
+// A run starts when a record is delivered; copies of it arriving within the window are
+// counted instead of delivered and summarized in one record when the run ends.
+type repeatRun struct {
+	active bool      // a run is in progress
+	rec    Record    // the record that started the run
+	start  time.Time // when the run started
+	count  int       // copies collapsed into the run
+}
+
+// observe handles rec arriving at now. It returns the summary of the run rec ends, if
+// any, and whether rec is a copy to drop. A nil run passes every record.
+func (r *repeatRun) observe(rec Record, now time.Time, window time.Duration) (summary *Record, dup bool) {
+	if r == nil {
+		return nil, false
+	}
+	if r.active && rec.Line == r.rec.Line && now.Sub(r.start) < window {
+		r.count++
+		return nil, true
+	}
+	summary = r.end(now)
+	r.active, r.rec, r.start = true, rec, now
+	return summary, false
+}
+
+// expire ends the run if its window has passed by now and returns its summary, if any.
+func (r *repeatRun) expire(now time.Time, window time.Duration) *Record {
+	if r == nil || !r.active || now.Sub(r.start) < window {
+		return nil
+	}
+	return r.end(now)
+}
+
+// end ends the run and returns its summary, or nil if no copy was collapsed into it.
+func (r *repeatRun) end(now time.Time) *Record {
+	r.active = false
+	if r.count == 0 {
+		return nil
+	}
+	summary := r.rec
+	summary.Ts = now.UTC()
+	summary.Repeats = r.count
+	summary.Forced = false
+	r.count = 0
+	return &summary
+}
+
+// RepeatSummary formats the summary of a run of n repeated copies of line for consumers
+// that only receive lines, such as OnLineFunc: "message repeated n times: [line]".
+func RepeatSummary(line string, n int) string {
+	return fmt.Sprintf("message repeated %d times: [%s]", n, line)
+}
+
+// repeatRunOf returns the run state of file id, or nil when Config.RepeatWindow is 0.
+func (c *Collector) repeatRunOf(id string) *repeatRun {
+	if c.cfg.RepeatWindow <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.repeats[id]
+	if !ok {
+		r = &repeatRun{}
+		c.repeats[id] = r
+	}
+	return r
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepeatRun(t *testing.T) {
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	window := time.Minute
+	r := &repeatRun{}
+
+	summary, dup := r.observe(Record{Line: "boom"}, start, window)
+	assert.Nil(t, summary)
+	assert.False(t, dup)
+	for i := 1; i <= 3; i++ {
+		_, dup = r.observe(Record{Line: "boom"}, start.Add(time.Duration(i)*time.Second), window)
+		assert.True(t, dup)
+	}
+	assert.Nil(t, r.expire(start.Add(30*time.Second), window))
+
+	// A different record ends the run
+	summary, dup = r.observe(Record{Line: "ok"}, start.Add(40*time.Second), window)
+	assert.False(t, dup)
+	require.NotNil(t, summary)
+	assert.Equal(t, "boom", summary.Line)
+	assert.Equal(t, 3, summary.Repeats)
+	assert.Equal(t, start.Add(40*time.Second), summary.Ts)
+
+	// So does the window passing; the next copy starts a new run
+	_, dup = r.observe(Record{Line: "ok"}, start.Add(50*time.Second), window)
+	assert.True(t, dup)
+	summary = r.expire(start.Add(100*time.Second), window)
+	require.NotNil(t, summary)
+	assert.Equal(t, 1, summary.Repeats)
+	summary, dup = r.observe(Record{Line: "ok"}, start.Add(101*time.Second), window)
+	assert.Nil(t, summary)
+	assert.False(t, dup)
+
+	// A nil run passes everything
+	var none *repeatRun
+	summary, dup = none.observe(Record{Line: "ok"}, start, window)
+	assert.Nil(t, summary)
+	assert.False(t, dup)
+	assert.Nil(t, none.expire(start, window))
+}
+
+func TestCollector_RepeatWindow(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "crash.log")
+	require.NoError(t, os.WriteFile(p, []byte("boom\nboom\nboom\nrestart\nboom\nboom\n"), 0644))
+
+	var (
+		mu     sync.Mutex
+		events []LineEvent
+		lines  []string
+	)
+	c, err := New(WithInclude(dir), WithPollInterval(50*time.Millisecond), WithRepeatWindow(time.Hour),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithOnEvent(func(e LineEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+			if e.Repeats > 0 {
+				lines = append(lines, RepeatSummary(e.Line, e.Repeats))
+			} else {
+				lines = append(lines, e.Line)
+			}
+		}))
+	require.NoError(t, err)
+	c.Start()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 4
+	}, 2*time.Second, 20*time.Millisecond)
+	// Stop ends the open run
+	c.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"boom",
+		"message repeated 2 times: [boom]",
+		"restart",
+		"boom",
+		"message repeated 1 times: [boom]",
+	}, lines)
+	assert.Equal(t, 2, events[1].Repeats)
+	assert.Equal(t, p, events[1].File)
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// Policies for Config.OffsetMismatch.
+const (
+	// OffsetMismatchStart reads the file from the start again.
+	OffsetMismatchStart = "start"
+	// OffsetMismatchEnd skips to the current end of the file.
+	OffsetMismatchEnd = "end"
+	// OffsetMismatchQuarantine does not read the file at all, keeping its stored offset,
+	// until it is deleted or the collector restarts; it is listed in Stats.Quarantined.
+	OffsetMismatchQuarantine = "quarantine"
+)
+
+// ErrOffsetMismatch is reported through Config.OnErrorFunc, wrapped with the details,
+// when a stored offset fails verification before reading resumes from it: it lies
+// past the end of the file, or the file no longer has the identity it was stored for.
+var ErrOffsetMismatch = errors.New("stored offset does not match the file")
+
+// QuarantinedFile describes a file not read because its stored offset failed
+// verification with Config.OffsetMismatch set to OffsetMismatchQuarantine.
+type QuarantinedFile struct {
+	ID     string
+	Path   string
+	Offset int64 // the stored offset, kept in the store
+	Size   int64 // size when it was quarantined
+	Err    error // wraps ErrOffsetMismatch
+}
+
+// resumeOffset verifies offset, stored for the file id found at path, before reading
+// resumes from it and returns the offset to resume from. A mismatch is logged, counted
+// and reported, and handled according to cfg.OffsetMismatch; ok is false when the file
+// was quarantined and must not be read.
+func (c *Collector) resumeOffset(id, path string, offset int64) (resume int64, ok bool) {
+	size, err := c.verifyOffset(id, path, offset)
+	if err == nil {
+		return offset, true
+	}
+	c.metrics.IncOffsetMismatches()
+	c.reportError(err, ErrorContext{Kind: ErrorKindOffset, FileID: id, Path: path})
+	switch c.cfg.OffsetMismatch {
+	case OffsetMismatchEnd:
+		c.logger.Warn("stored offset does not match the file, skipping to its end", "file", id, "path", path, "offset", offset, "size", size, "error", err)
+		return max(size, 0), true
+	case OffsetMismatchQuarantine:
+		c.logger.Warn("stored offset does not match the file, quarantining it", "file", id, "path", path, "offset", offset, "size", size, "error", err)
+		c.quarantined.Store(id, QuarantinedFile{ID: id, Path: path, Offset: offset, Size: size, Err: err})
+		c.decisions.Record(watcher.Decision{Action: watcher.DecisionSkipped, Path: path, FileID: id, Reason: "quarantined: " + err.Error()})
+		return offset, false
+	default:
+		c.logger.Warn("stored offset does not match the file, reading it from the start", "file", id, "path", path, "offset", offset, "size", size, "error", err)
+		return 0, true
+	}
+}
+
+// verifyOffset checks that the file id at path is at least offset bytes long and
+// still has the identity id under its fingerprint strategy, and returns its size. A
+// file that cannot be stat'ed is left to the reader.
+func (c *Collector) verifyOffset(id, path string, offset int64) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return -1, nil
+	}
+	size := info.Size()
+	if offset > size {
+		return size, fmt.Errorf("%w: offset %d is past the end of the file (%d bytes)", ErrOffsetMismatch, offset, size)
+	}
+	fileInfo := c.fileManager.Get(id)
+	if fileInfo == nil {
+		return size, nil
+	}
+	var current string
+	switch fileInfo.FingerprintStrategy {
+	case watcher.FingerprintStrategyChecksum:
+		current, err = file_tracker.GetFileFingerprintRangeFromPath(path, fileInfo.FingerprintOffset, fileInfo.FingerprintSize)
+	case watcher.FingerprintStrategyChecksumSeparator:
+		current, err = file_tracker.GetFileFingerprintUntilNSeparatorsFromPath(path, c.cfg.Separator, int(fileInfo.FingerprintSize))
+	default:
+		current, err = file_tracker.GetFileIDFromPath(path)
+	}
+	if err != nil {
+		return size, fmt.Errorf("%w: fingerprint: %w", ErrOffsetMismatch, err)
+	}
+	if current != watcher.Fingerprint(id) {
+		return size, fmt.Errorf("%w: fingerprint is now %s", ErrOffsetMismatch, current)
+	}
+	return size, nil
+}
+
+// isQuarantined reports whether id is not read; see OffsetMismatchQuarantine.
+func (c *Collector) isQuarantined(id string) bool {
+	_, ok := c.quarantined.Load(id)
+	return ok
+}
+
+// quarantinedFiles returns the quarantined files sorted by path.
+func (c *Collector) quarantinedFiles() []QuarantinedFile {
+	var files []QuarantinedFile
+	c.quarantined.Range(func(_, v any) bool {
+		files = append(files, v.(QuarantinedFile))
+		return true
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_OffsetMismatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	for _, tc := range []struct {
+		policy string
+		want   func(first, later []string) []string
+	}{
+		{OffsetMismatchStart, func(first, later []string) []string { return append(first, later...) }},
+		{OffsetMismatchEnd, func(first, later []string) []string { return later }},
+		{OffsetMismatchQuarantine, func(first, later []string) []string { return nil }},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			base := t.TempDir()
+			w, err := testkit.NewLogWriter(filepath.Join(base, "app.log"))
+			require.NoError(t, err)
+			defer func() { _ = w.Close() }()
+			first, err := w.Write(3)
+			require.NoError(t, err)
+
+			// An offset stored past the end of the file, e.g. for a reused inode
+			dbPath := filepath.Join(t.TempDir(), "offsets.db")
+			id, err := file_tracker.GetFileIDFromPath(w.Path())
+			require.NoError(t, err)
+			db, err := store.NewSQLiteStore(dbPath)
+			require.NoError(t, err)
+			require.NoError(t, db.Save(id, watcher.FingerprintStrategyDeviceAndInode, w.Path(), 1<<20))
+			require.NoError(t, db.Close())
+
+			var mu sync.Mutex
+			var errs []error
+			sink := testkit.NewLineSink()
+			c, err := New(WithInclude(filepath.Join(base, "*.log")), WithPollInterval(50*time.Millisecond),
+				WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+				WithStore(dbPath), WithOffsetMismatch(tc.policy),
+				WithOnLine(sink.Add),
+				WithOnError(func(err error, ctx ErrorContext) {
+					mu.Lock()
+					defer mu.Unlock()
+					if ctx.Kind == ErrorKindOffset {
+						errs = append(errs, err)
+					}
+				}))
+			require.NoError(t, err)
+			c.Start()
+			defer c.Stop()
+
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(errs) == 1
+			}, 3*time.Second, 20*time.Millisecond, "mismatch not reported")
+			assert.True(t, errors.Is(errs[0], ErrOffsetMismatch))
+			later, err := w.Write(2)
+			require.NoError(t, err)
+
+			want := tc.want(first, later)
+			if want != nil {
+				sink.WaitForLines(t, want, 3*time.Second)
+			}
+			time.Sleep(200 * time.Millisecond)
+			testkit.AssertLines(t, sink.Lines(), want)
+
+			st := c.Stats()
+			if tc.policy == OffsetMismatchQuarantine {
+				require.Len(t, st.Quarantined, 1)
+				assert.Equal(t, w.Path(), st.Quarantined[0].Path)
+				assert.Equal(t, int64(1<<20), st.Quarantined[0].Offset)
+				assert.Empty(t, st.Files)
+			} else {
+				assert.Empty(t, st.Quarantined)
+				assert.Len(t, st.Files, 1)
+			}
+		})
+	}
+}
+
+func TestConfig_OffsetMismatchValidation(t *testing.T) {
+	cfg := Config{OffsetMismatch: "rewind"}
+	assert.ErrorContains(t, cfg.Validate(), "offset mismatch policy")
+
+	_, err := New(WithOffsetMismatch("rewind"))
+	assert.Error(t, err)
+}
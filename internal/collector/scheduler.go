@@ -1,110 +1,487 @@
 package collector
 
 import (
-	"container/list"
+	"container/heap"
 	"log/slog"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/loykin/freader/internal/clock"
 	"github.com/loykin/freader/internal/tailer"
 )
 
+// strideUnit is the pass a file of weight 1 advances by when handed out.
+const strideUnit = 1 << 20
+
 type TailScheduler struct {
-	available *list.List
-	cursor    *list.Element
-	index     map[string]*list.Element
 	mu        sync.Mutex
-	running   map[string]bool
+	seeks     map[string]int64     // offsets to apply when a running file is next handed out
+	barriers  map[string]*barrier  // files held back until the file they replaced is drained; see Hold
+	removing  map[string]bool      // removed files whose barriers wait for Release
+	delays    map[string]time.Time // files not handed out before this time; see Delay
+	weights   map[string]int       // scheduling weight per file if not 1; see SetWeight
+	vtime     uint64               // pass of the file handed out last
+	activity  map[string]time.Time // when a read of each file last found data; see Observe
+	idleAfter time.Duration        // idle files are only handed out every idleCheck; see Observe
+	idleCheck time.Duration
+	paused    bool
+	standby   bool // see SetStandby
+	logger    *slog.Logger
+	clock     clock.Clock
+
+	// Scheduling state per file; see getNextAvailable
+	items   map[string]*schedItem
+	ready   readyQueue // idle files that may be handed out
+	delayed delayQueue // idle files waiting for their delay to pass
+	seq     uint64     // order in which files became ready
+	added   uint64     // order in which files were added; see Tails
+}
+
+// schedItem is the scheduling state of one file. An idle file is in ready, in delayed
+// while its delay has not passed, or in neither while held back by Hold; a running
+// file is in neither until SetIdle.
+type schedItem struct {
+	fileTail *tailer.TailReader
+	added    uint64    // when it was added
+	pass     uint64    // virtual time at which the file is next due
+	seq      uint64    // when it became ready, so files of equal pass take turns
+	until    time.Time // end of its delay while in delayed
+	state    itemState
+	pos      int // index in the queue holding it
+}
+
+type itemState uint8
+
+const (
+	itemRunning itemState = iota
+	itemReady
+	itemDelayed
+	itemHeld
+)
+
+// readyQueue is a min-heap of files by (pass, seq).
+type readyQueue []*schedItem
+
+func (q readyQueue) Len() int { return len(q) }
+func (q readyQueue) Less(i, j int) bool {
+	if q[i].pass != q[j].pass {
+		return q[i].pass < q[j].pass
+	}
+	return q[i].seq < q[j].seq
+}
+func (q readyQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].pos, q[j].pos = i, j
+}
+func (q *readyQueue) Push(x any) {
+	it := x.(*schedItem)
+	it.pos = len(*q)
+	*q = append(*q, it)
+}
+func (q *readyQueue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return it
+}
+
+// delayQueue is a min-heap of files by the end of their delay.
+type delayQueue []*schedItem
+
+func (q delayQueue) Len() int           { return len(q) }
+func (q delayQueue) Less(i, j int) bool { return q[i].until.Before(q[j].until) }
+func (q delayQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].pos, q[j].pos = i, j
+}
+func (q *delayQueue) Push(x any) {
+	it := x.(*schedItem)
+	it.pos = len(*q)
+	*q = append(*q, it)
+}
+func (q *delayQueue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return it
+}
+
+// barrier holds a file back until prev, the file it replaced at the same path, has
+// been read to its end.
+type barrier struct {
+	prev  string
+	armed bool // prev was handed out after the barrier was set; its read reaches the end
 }
 
 func NewTailScheduler() *TailScheduler {
 	return &TailScheduler{
-		available: list.New(),
-		running:   make(map[string]bool),
-		index:     make(map[string]*list.Element),
+		seeks:    make(map[string]int64),
+		barriers: make(map[string]*barrier),
+		removing: make(map[string]bool),
+		delays:   make(map[string]time.Time),
+		weights:  make(map[string]int),
+		items:    make(map[string]*schedItem),
+		activity: make(map[string]time.Time),
+		logger:   slog.Default(),
+		clock:    clock.Real(),
 	}
 }
 
-func (t *TailScheduler) Remove(id string) {
+// Remove stops scheduling id. It returns the reader of id, if scheduled, and whether
+// a worker is reading it right now. Files held back by Hold until id is drained keep
+// waiting until Release(id), so the caller can deliver what id still holds first.
+func (t *TailScheduler) Remove(id string) (fileTail *tailer.TailReader, running bool) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if elem, exists := t.index[id]; exists {
-		t.available.Remove(elem)
-		delete(t.index, id)
-		delete(t.running, id)
+	delete(t.barriers, id)
+	delete(t.delays, id)
+	delete(t.weights, id)
+	delete(t.activity, id)
+	it, ok := t.items[id]
+	if !ok {
+		return nil, false
+	}
+	switch it.state {
+	case itemRunning:
+		t.removing[id] = true
+	case itemReady:
+		heap.Remove(&t.ready, it.pos)
+	case itemDelayed:
+		heap.Remove(&t.delayed, it.pos)
+	}
+	delete(t.items, id)
+	delete(t.seeks, id)
+	return it.fileTail, it.state == itemRunning
+}
 
-		if t.cursor == elem {
-			t.cursor = elem.Next()
-			if t.cursor == nil {
-				t.cursor = t.available.Front()
-			}
+func (t *TailScheduler) GetCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.items)
+}
+
+// RunningCount returns the number of files currently being read by a worker.
+func (t *TailScheduler) RunningCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, it := range t.items {
+		if it.state == itemRunning {
+			n++
 		}
 	}
+	return n
 }
 
-func (t *TailScheduler) GetCount() int {
+// SetPaused stops (true) or restarts (false) handing out files to workers.
+// Tracked files, offsets and in-flight reads are not affected.
+func (t *TailScheduler) SetPaused(paused bool) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	return t.available.Len()
+	t.paused = paused
+}
+
+// SetStandby stops (true) or restarts (false) handing out files while the collector
+// stands by for the lease, independently of SetPaused.
+func (t *TailScheduler) SetStandby(standby bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.standby = standby
+}
+
+func (t *TailScheduler) Paused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.paused
+}
+
+// Seek sets the offset the next read of id starts from. If id is being read, the
+// seek is applied when it is next handed out. It reports whether id is scheduled.
+func (t *TailScheduler) Seek(id string, offset int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	it, ok := t.items[id]
+	if !ok {
+		return false
+	}
+	if it.state == itemRunning {
+		t.seeks[id] = offset
+		return true
+	}
+	it.fileTail.Offset = offset
+	return true
 }
 
 func (t *TailScheduler) Add(id string, fileTail *tailer.TailReader, update bool) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if !update {
-		if _, exists := t.index[id]; exists {
-			slog.Debug("file already exists", "id", id)
+	if it, ok := t.items[id]; ok {
+		if !update {
+			t.logger.Debug("file already exists", "id", id)
 			return
 		}
+		it.fileTail = fileTail
+	} else {
+		// Start level with the files being handed out rather than ahead of all of them
+		it = &schedItem{fileTail: fileTail, added: t.added, pass: t.vtime}
+		t.added++
+		t.items[id] = it
+		t.makeReady(it)
 	}
+	if _, ok := t.activity[id]; !ok {
+		t.activity[id] = t.clock.Now()
+	}
+}
 
-	elem := t.available.PushBack(fileTail)
-	t.index[id] = elem
+// makeReady queues it behind the ready files of the same pass.
+func (t *TailScheduler) makeReady(it *schedItem) {
+	it.state = itemReady
+	it.seq = t.seq
+	t.seq++
+	heap.Push(&t.ready, it)
+}
 
-	if t.cursor == nil {
-		t.cursor = t.available.Front()
+// unhold queues id again once no barrier holds it back.
+func (t *TailScheduler) unhold(id string) {
+	if it, ok := t.items[id]; ok && it.state == itemHeld && t.barriers[id] == nil {
+		t.makeReady(it)
 	}
 }
 
-func (t *TailScheduler) SetIdle(id string) {
+// Tails returns the scheduled readers in the order they were added.
+func (t *TailScheduler) Tails() []*tailer.TailReader {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if _, ok := t.index[id]; ok {
-		t.running[id] = false
+	items := make([]*schedItem, 0, len(t.items))
+	for _, it := range t.items {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].added < items[j].added })
+	tails := make([]*tailer.TailReader, len(items))
+	for i, it := range items {
+		tails[i] = it.fileTail
 	}
+	return tails
 }
 
-func (t *TailScheduler) getNextAvailable() (*tailer.TailReader, bool) {
+// SetIdle marks id as no longer being read and releases the files Hold kept back until
+// this read of id. It reports false if id was removed in the meantime.
+func (t *TailScheduler) SetIdle(id string) bool {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if t.available.Len() == 0 {
-		return nil, false
+	if it, ok := t.items[id]; ok {
+		if it.state == itemRunning {
+			t.makeReady(it)
+		}
+		for next, b := range t.barriers {
+			if b.prev == id && b.armed {
+				delete(t.barriers, next)
+				t.unhold(next)
+			}
+		}
+		return true
 	}
+	return false
+}
 
-	startCursor := t.cursor
-	for {
-		if t.cursor == nil {
-			t.cursor = t.available.Front()
+// Hold keeps id from being handed out until prev, the file it replaced at the same
+// path, has been read to its end, so records of prev are all delivered before any of
+// id. Nothing is held if prev is no longer scheduled.
+func (t *TailScheduler) Hold(id, prev string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.items[prev]; ok || t.removing[prev] {
+		t.barriers[id] = &barrier{prev: prev}
+	}
+}
+
+// SetWeight makes id be handed out about weight times as often as a file of weight 1
+// while files compete for workers; files weigh 1 by default.
+func (t *TailScheduler) SetWeight(id string, weight int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if weight <= 1 {
+		delete(t.weights, id)
+		return
+	}
+	t.weights[id] = weight
+}
+
+// weight returns the scheduling weight of id.
+func (t *TailScheduler) weight(id string) int {
+	if w, ok := t.weights[id]; ok {
+		return w
+	}
+	return 1
+}
+
+// Delay keeps id from being handed out before until.
+func (t *TailScheduler) Delay(id string, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.delays[id] = until
+	if it, ok := t.items[id]; ok && it.state == itemDelayed {
+		it.until = until
+		heap.Fix(&t.delayed, it.pos)
+	}
+}
+
+// Observe records whether a read of id found data. Once id has found none for
+// idleAfter, it is only handed out again after idleCheck, so idle files at their end
+// are not read over and over while workers have other files to read.
+func (t *TailScheduler) Observe(id string, active bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.items[id]; !ok {
+		return
+	}
+	now := t.clock.Now()
+	if active {
+		t.activity[id] = now
+		return
+	}
+	if t.idleAfter > 0 && now.Sub(t.activity[id]) >= t.idleAfter {
+		t.delays[id] = now.Add(t.idleCheck)
+	}
+}
+
+// Wake lets id be handed out again right away, e.g. when it has a record to deliver
+// while idle.
+func (t *TailScheduler) Wake(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.delays, id)
+	if it, ok := t.items[id]; ok && it.state == itemDelayed {
+		heap.Remove(&t.delayed, it.pos)
+		t.makeReady(it)
+	}
+}
+
+// IdleCount returns the number of files only handed out every idleCheck; see Observe.
+func (t *TailScheduler) IdleCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	n := 0
+	for id := range t.items {
+		if t.idleAfter > 0 && now.Sub(t.activity[id]) >= t.idleAfter {
+			n++
 		}
+	}
+	return n
+}
 
-		if fileTail, ok := t.cursor.Value.(*tailer.TailReader); ok {
-			if running, exists := t.running[fileTail.FileId]; !exists || !running {
-				t.running[fileTail.FileId] = true
-				t.cursor = t.cursor.Next()
-				return fileTail, true
-			}
+// Draining reports whether a file held back by Hold waits for the current read of id.
+func (t *TailScheduler) Draining(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range t.barriers {
+		if b.prev == id && b.armed {
+			return true
 		}
+	}
+	return false
+}
 
-		t.cursor = t.cursor.Next()
+// Release lets the files held back until id was drained be handed out, once what id
+// still held after its removal has been delivered.
+func (t *TailScheduler) Release(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-		if t.cursor == startCursor {
-			break
+	delete(t.removing, id)
+	for next, b := range t.barriers {
+		if b.prev == id {
+			delete(t.barriers, next)
+			t.unhold(next)
 		}
 	}
+}
+
+// due reports whether id is no longer delayed at now; see Delay.
+func (t *TailScheduler) due(id string, now time.Time) bool {
+	until, ok := t.delays[id]
+	if !ok {
+		return true
+	}
+	if now.Before(until) {
+		return false
+	}
+	delete(t.delays, id)
+	return true
+}
 
-	return nil, false
+// getNextAvailable hands out the idle file with the lowest pass; files of equal pass
+// take turns in the order they became ready. Each hand-out advances the pass of the
+// file by its stride, so a file of weight w is handed out about w times as often as a
+// file of weight 1 while they compete for workers (stride scheduling). Files are kept
+// in heaps, so a hand-out costs O(log n) in the number of tracked files; ones found
+// delayed or held back on the way are set aside until they may run again.
+func (t *TailScheduler) getNextAvailable() (*tailer.TailReader, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused || t.standby || len(t.items) == 0 {
+		return nil, false
+	}
+
+	now := t.clock.Now()
+	for t.delayed.Len() > 0 && !now.Before(t.delayed[0].until) {
+		t.makeReady(heap.Pop(&t.delayed).(*schedItem))
+	}
+	var it *schedItem
+	for t.ready.Len() > 0 {
+		next := heap.Pop(&t.ready).(*schedItem)
+		id := next.fileTail.FileId
+		if t.barriers[id] != nil {
+			next.state = itemHeld
+			continue
+		}
+		if !t.due(id, now) {
+			next.state = itemDelayed
+			next.until = t.delays[id]
+			heap.Push(&t.delayed, next)
+			continue
+		}
+		it = next
+		break
+	}
+	if it == nil {
+		return nil, false
+	}
+
+	fileTail := it.fileTail
+	it.state = itemRunning
+	for _, b := range t.barriers {
+		if b.prev == fileTail.FileId {
+			b.armed = true
+		}
+	}
+	if offset, ok := t.seeks[fileTail.FileId]; ok {
+		fileTail.Offset = offset
+		delete(t.seeks, fileTail.FileId)
+	}
+	t.vtime = it.pass
+	it.pass += strideUnit / uint64(t.weight(fileTail.FileId))
+	return fileTail, true
 }
@@ -8,22 +8,17 @@ import (
 
 	"github.com/loykin/freader/internal/file_tracker"
 	"github.com/loykin/freader/internal/tailer"
+	"github.com/loykin/freader/pkg/testkit"
 )
 
 func TestTailScheduler_Comprehensive(t *testing.T) {
 	t.Run("Initialization Test", func(t *testing.T) {
 		scheduler := NewTailScheduler()
-		if scheduler.available.Len() != 0 {
-			t.Error("Initial available list should be empty")
+		if len(scheduler.items) != 0 {
+			t.Error("Initial items map should be empty")
 		}
-		if len(scheduler.running) != 0 {
-			t.Error("Initial running map should be empty")
-		}
-		if len(scheduler.index) != 0 {
-			t.Error("Initial index map should be empty")
-		}
-		if scheduler.cursor != nil {
-			t.Error("Initial cursor should be nil")
+		if scheduler.ready.Len() != 0 {
+			t.Error("Initial ready queue should be empty")
 		}
 	})
 
@@ -34,23 +29,74 @@ func TestTailScheduler_Comprehensive(t *testing.T) {
 
 		// Add file
 		scheduler.Add("test1", file, false)
-		if scheduler.available.Len() != 1 {
+		if len(scheduler.items) != 1 {
 			t.Error("File was not added")
 		}
-		if scheduler.cursor == nil {
-			t.Error("Cursor was not set")
+		if scheduler.ready.Len() != 1 {
+			t.Error("File was not queued")
+		}
+
+		// Update replaces the reader in place
+		updated := &tailer.TailReader{FileId: "test1", FileManager: fm}
+		scheduler.Add("test1", updated, true)
+		if tails := scheduler.Tails(); len(tails) != 1 || tails[0] != updated {
+			t.Error("File was not updated in place")
 		}
 
 		// Remove file
 		scheduler.Remove("test1")
-		if scheduler.available.Len() != 0 {
+		if len(scheduler.items) != 0 {
 			t.Error("File was not removed")
 		}
-		if len(scheduler.index) != 0 {
-			t.Error("File was not removed from index")
+		if scheduler.ready.Len() != 0 {
+			t.Error("File was not removed from the ready queue")
+		}
+	})
+
+	t.Run("Pause Test", func(t *testing.T) {
+		scheduler := NewTailScheduler()
+		fm := file_tracker.New()
+		scheduler.Add("test1", &tailer.TailReader{FileId: "test1", FileManager: fm}, false)
+
+		scheduler.SetPaused(true)
+		if _, ok := scheduler.getNextAvailable(); ok {
+			t.Error("Paused scheduler should not hand out files")
+		}
+		if scheduler.GetCount() != 1 {
+			t.Error("Pausing should keep tracked files")
+		}
+
+		scheduler.SetPaused(false)
+		if file, ok := scheduler.getNextAvailable(); !ok || file.FileId != "test1" {
+			t.Error("Resumed scheduler should hand out files again")
+		}
+	})
+
+	t.Run("Seek Test", func(t *testing.T) {
+		scheduler := NewTailScheduler()
+		fm := file_tracker.New()
+		file := &tailer.TailReader{FileId: "test1", FileManager: fm, Offset: 10}
+		scheduler.Add("test1", file, false)
+
+		if scheduler.Seek("missing", 0) {
+			t.Error("Seek should fail for unscheduled files")
+		}
+		if !scheduler.Seek("test1", 3) || file.Offset != 3 {
+			t.Error("Seek on an idle file should apply immediately")
+		}
+
+		// While running, the seek is deferred until the file is handed out again.
+		if _, ok := scheduler.getNextAvailable(); !ok {
+			t.Fatal("file should be available")
 		}
-		if scheduler.running["test1"] {
-			t.Error("Running state was not cleared")
+		file.Offset = 20
+		scheduler.Seek("test1", 5)
+		if file.Offset != 20 {
+			t.Error("Seek must not modify a running reader")
+		}
+		scheduler.SetIdle("test1")
+		if next, ok := scheduler.getNextAvailable(); !ok || next.Offset != 5 {
+			t.Error("Deferred seek was not applied")
 		}
 	})
 
@@ -113,22 +159,22 @@ func TestTailScheduler_Comprehensive(t *testing.T) {
 		}
 	})
 
-	t.Run("Cursor Management Test", func(t *testing.T) {
+	t.Run("Ready Queue Management Test", func(t *testing.T) {
 		scheduler := NewTailScheduler()
 		fm := file_tracker.New()
 
-		// Set cursor when adding a file
+		// Queue a file when adding it
 		file1 := &tailer.TailReader{FileId: "test1", FileManager: fm}
 		scheduler.Add("test1", file1, false)
-		if scheduler.cursor == nil {
-			t.Error("Cursor not set after adding first file")
+		if scheduler.ready.Len() != 1 {
+			t.Error("File not queued after adding it")
 		}
 
-		// Remove the file that cursor points to
-		currentFile := scheduler.cursor.Value.(*tailer.TailReader)
+		// Remove the queued file
+		currentFile := scheduler.ready[0].fileTail
 		scheduler.Remove(currentFile.FileId)
-		if scheduler.cursor != nil {
-			t.Error("Cursor should be nil after removing the last file")
+		if scheduler.ready.Len() != 0 {
+			t.Error("Ready queue should be empty after removing the last file")
 		}
 	})
 
@@ -168,8 +214,8 @@ func TestTailScheduler_Comprehensive(t *testing.T) {
 		wg.Wait()
 		time.Sleep(100 * time.Millisecond) // Wait for all operations to complete
 
-		if scheduler.available.Len() != 100 {
-			t.Errorf("Unexpected number of files: expected 100, got %d", scheduler.available.Len())
+		if scheduler.GetCount() != 100 {
+			t.Errorf("Unexpected number of files: expected 100, got %d", scheduler.GetCount())
 		}
 	})
 
@@ -183,8 +229,239 @@ func TestTailScheduler_Comprehensive(t *testing.T) {
 		scheduler.SetIdle("nonexistent")
 
 		// State should not change
-		if scheduler.available.Len() != 0 {
+		if len(scheduler.items) != 0 {
 			t.Error("State changed after handling non-existent file")
 		}
 	})
 }
+
+func TestTailScheduler_Hold(t *testing.T) {
+	fm := file_tracker.New()
+	next := func(s *TailScheduler) string {
+		fileTail, ok := s.getNextAvailable()
+		if !ok {
+			return ""
+		}
+		return fileTail.FileId
+	}
+
+	t.Run("Held Until Replaced File Is Read", func(t *testing.T) {
+		scheduler := NewTailScheduler()
+		scheduler.Add("old", &tailer.TailReader{FileId: "old", FileManager: fm}, false)
+		if got := next(scheduler); got != "old" {
+			t.Fatalf("expected old, got %q", got)
+		}
+		// Rotation is noticed while old is being read
+		scheduler.Hold("new", "old")
+		scheduler.Add("new", &tailer.TailReader{FileId: "new", FileManager: fm}, false)
+		if got := next(scheduler); got != "" {
+			t.Fatalf("new must wait for old, got %q", got)
+		}
+		if scheduler.Draining("old") {
+			t.Error("the read of old started before the rotation does not drain it")
+		}
+		scheduler.SetIdle("old")
+		if got := next(scheduler); got != "old" {
+			t.Fatalf("expected old to be read again, got %q", got)
+		}
+		if !scheduler.Draining("old") {
+			t.Error("old should be draining")
+		}
+		scheduler.SetIdle("old")
+		if scheduler.Draining("old") {
+			t.Error("old should be drained")
+		}
+		if got := next(scheduler); got != "new" {
+			t.Fatalf("expected new, got %q", got)
+		}
+	})
+
+	t.Run("Held Until Removed File Is Released", func(t *testing.T) {
+		scheduler := NewTailScheduler()
+		scheduler.Add("old", &tailer.TailReader{FileId: "old", FileManager: fm}, false)
+		next(scheduler)
+		scheduler.Hold("new", "old")
+		scheduler.Add("new", &tailer.TailReader{FileId: "new", FileManager: fm}, false)
+		if _, running := scheduler.Remove("old"); !running {
+			t.Fatal("old should be running")
+		}
+		if got := next(scheduler); got != "" {
+			t.Fatalf("new must wait for old, got %q", got)
+		}
+		if scheduler.SetIdle("old") {
+			t.Error("old was removed")
+		}
+		scheduler.Release("old")
+		if got := next(scheduler); got != "new" {
+			t.Fatalf("expected new, got %q", got)
+		}
+	})
+
+	t.Run("Nothing Held For Unknown File", func(t *testing.T) {
+		scheduler := NewTailScheduler()
+		scheduler.Hold("new", "gone")
+		scheduler.Add("new", &tailer.TailReader{FileId: "new", FileManager: fm}, false)
+		if got := next(scheduler); got != "new" {
+			t.Fatalf("expected new, got %q", got)
+		}
+	})
+}
+
+func TestTailScheduler_Delay(t *testing.T) {
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	clk := testkit.NewFakeClock(start)
+	scheduler := NewTailScheduler()
+	scheduler.clock = clk
+	fm := file_tracker.New()
+
+	scheduler.Delay("late", start.Add(time.Second))
+	scheduler.Add("late", &tailer.TailReader{FileId: "late", FileManager: fm}, false)
+	if _, ok := scheduler.getNextAvailable(); ok {
+		t.Fatal("delayed file handed out early")
+	}
+	clk.Advance(time.Second)
+	if fileTail, ok := scheduler.getNextAvailable(); !ok || fileTail.FileId != "late" {
+		t.Fatal("expected the delayed file once due")
+	}
+	if len(scheduler.delays) != 0 {
+		t.Error("delay was not cleared")
+	}
+}
+
+func TestTailScheduler_Weight(t *testing.T) {
+	scheduler := NewTailScheduler()
+	fm := file_tracker.New()
+	scheduler.SetWeight("hot", 3)
+	scheduler.Add("hot", &tailer.TailReader{FileId: "hot", FileManager: fm}, false)
+	scheduler.Add("cold", &tailer.TailReader{FileId: "cold", FileManager: fm}, false)
+
+	counts := map[string]int{}
+	for i := 0; i < 400; i++ {
+		fileTail, ok := scheduler.getNextAvailable()
+		if !ok {
+			t.Fatal("expected a file")
+		}
+		counts[fileTail.FileId]++
+		scheduler.SetIdle(fileTail.FileId)
+	}
+	if counts["hot"] != 300 || counts["cold"] != 100 {
+		t.Errorf("expected a 3:1 split, got %v", counts)
+	}
+
+	// A file added later starts at the current pass instead of catching up
+	scheduler.Add("new", &tailer.TailReader{FileId: "new", FileManager: fm}, false)
+	counts = map[string]int{}
+	for i := 0; i < 10; i++ {
+		fileTail, _ := scheduler.getNextAvailable()
+		counts[fileTail.FileId]++
+		scheduler.SetIdle(fileTail.FileId)
+	}
+	if counts["new"] > 2 {
+		t.Errorf("new file monopolized the scheduler: %v", counts)
+	}
+
+	scheduler.Remove("hot")
+	if len(scheduler.weights) != 0 || len(scheduler.items) != 2 {
+		t.Error("weight and pass were not cleared")
+	}
+}
+
+func TestTailScheduler_Idle(t *testing.T) {
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	clk := testkit.NewFakeClock(start)
+	scheduler := NewTailScheduler()
+	scheduler.clock = clk
+	scheduler.idleAfter = time.Minute
+	scheduler.idleCheck = time.Second
+	fm := file_tracker.New()
+	scheduler.Add("quiet", &tailer.TailReader{FileId: "quiet", FileManager: fm}, false)
+
+	read := func(active bool) bool {
+		fileTail, ok := scheduler.getNextAvailable()
+		if !ok {
+			return false
+		}
+		scheduler.Observe(fileTail.FileId, active)
+		scheduler.SetIdle(fileTail.FileId)
+		return true
+	}
+
+	// Not idle for long enough yet: read on every pass
+	clk.Advance(30 * time.Second)
+	if !read(false) || !read(false) {
+		t.Fatal("expected the file before it has been idle for idleAfter")
+	}
+	clk.Advance(30 * time.Second)
+	if !read(false) {
+		t.Fatal("expected the file")
+	}
+	if read(false) {
+		t.Fatal("idle file handed out before its recheck")
+	}
+	if scheduler.IdleCount() != 1 {
+		t.Errorf("IdleCount = %d, want 1", scheduler.IdleCount())
+	}
+
+	// Rechecked every idleCheck until it has data again
+	clk.Advance(time.Second)
+	if !read(true) {
+		t.Fatal("expected the idle file at its recheck")
+	}
+	if !read(false) || scheduler.IdleCount() != 0 {
+		t.Fatal("file with new data is still idle")
+	}
+
+	// Wake hands an idle file out right away
+	clk.Advance(time.Minute)
+	read(false)
+	scheduler.Wake("quiet")
+	if !read(false) {
+		t.Fatal("expected the woken file")
+	}
+}
+
+// Files set aside while delayed or held back return to the rotation once they may run,
+// and hand-outs keep their order among thousands of files.
+func TestTailScheduler_Queues(t *testing.T) {
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	clk := testkit.NewFakeClock(start)
+	scheduler := NewTailScheduler()
+	scheduler.clock = clk
+	fm := file_tracker.New()
+	const n = 5000
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("f%d", i)
+		scheduler.Add(id, &tailer.TailReader{FileId: id, FileManager: fm}, false)
+	}
+	scheduler.Delay("f1", start.Add(time.Second))
+	scheduler.Hold("f2", "f0")
+
+	// One full pass: every file once, in the order added, except the delayed and held ones
+	for i := 0; i < n; i++ {
+		if i == 1 || i == 2 {
+			continue
+		}
+		fileTail, ok := scheduler.getNextAvailable()
+		if want := fmt.Sprintf("f%d", i); !ok || fileTail.FileId != want {
+			t.Fatalf("hand-out %d: got %v, want %s", i, fileTail, want)
+		}
+	}
+	if _, ok := scheduler.getNextAvailable(); ok {
+		t.Fatal("delayed or held file handed out")
+	}
+
+	scheduler.SetIdle("f0") // drains f0 for f2, armed when f0 was handed out
+	if fileTail, ok := scheduler.getNextAvailable(); !ok || fileTail.FileId != "f2" {
+		t.Fatalf("expected the released file, got %v", fileTail)
+	}
+	clk.Advance(time.Second)
+	if fileTail, ok := scheduler.getNextAvailable(); !ok || fileTail.FileId != "f1" {
+		t.Fatalf("expected the delayed file once due, got %v", fileTail)
+	}
+	if fileTail, ok := scheduler.getNextAvailable(); !ok || fileTail.FileId != "f0" {
+		t.Fatalf("expected f0 to be read again, got %v", fileTail)
+	}
+	if scheduler.ready.Len() != 0 || scheduler.delayed.Len() != 0 {
+		t.Errorf("queues not empty: ready %d, delayed %d", scheduler.ready.Len(), scheduler.delayed.Len())
+	}
+}
@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+)
+
+// sharder decides which files this collector reads when they are split between
+// several collectors (Config.ShardCount, Config.ShardDiscovery). A file belongs to the
+// member scoring highest for its identity (rendezvous hashing), so a member joining or
+// leaving moves only the files it takes or gives up.
+type sharder struct {
+	mu       sync.RWMutex
+	self     string
+	members  []string // sorted; always includes self
+	detached bool     // owns nothing: the membership heartbeat has expired
+}
+
+func newSharder(self string, members []string) *sharder {
+	s := &sharder{self: self}
+	s.setMembers(members)
+	return s
+}
+
+// owns reports whether the file id belongs to this collector.
+func (s *sharder) owns(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.detached {
+		return false
+	}
+	var (
+		best  string
+		score uint64
+	)
+	for i, m := range s.members {
+		if sc := shardScore(m, id); i == 0 || sc > score {
+			best, score = m, sc
+		}
+	}
+	return best == s.self
+}
+
+// setMembers replaces the member set and reattaches the sharder, reporting whether
+// ownership may have changed.
+func (s *sharder) setMembers(members []string) bool {
+	members = append(slices.Clone(members), s.self)
+	slices.Sort(members)
+	members = slices.Compact(members)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.detached && slices.Equal(members, s.members) {
+		return false
+	}
+	s.members, s.detached = members, false
+	return true
+}
+
+// detach gives up every file until the next setMembers, reporting whether the sharder
+// was attached.
+func (s *sharder) detach() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	was := !s.detached
+	s.detached = true
+	return was
+}
+
+// list returns the members, or nil while detached.
+func (s *sharder) list() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.detached {
+		return nil
+	}
+	return slices.Clone(s.members)
+}
+
+// shardScore is the rendezvous weight of member for the file id. FNV-1a mixes its
+// last bytes poorly, so the sum goes through the splitmix64 finalizer.
+func shardScore(member, id string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(member))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(id))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// ShardMembers returns the collectors the files are currently split between: the
+// shard indexes with Config.ShardCount, the instance IDs with Config.ShardDiscovery.
+// It is nil when the collector is not sharded, or owns no files because it could not
+// heartbeat its membership in time.
+func (c *Collector) ShardMembers() []string {
+	return c.shard.list()
+}
+
+// joinShard sets up sharding on creation. With ShardDiscovery it registers the
+// collector in the offset store and reads the current members.
+func (c *Collector) joinShard() error {
+	switch {
+	case c.cfg.ShardCount > 0:
+		members := make([]string, c.cfg.ShardCount)
+		for i := range members {
+			members[i] = strconv.Itoa(i)
+		}
+		c.shard = newSharder(strconv.Itoa(c.cfg.ShardIndex), members)
+	case c.cfg.ShardDiscovery:
+		m, ok := c.offsetDB.(store.Membership)
+		if !ok {
+			return errors.New("shard discovery: the offset store does not track members")
+		}
+		c.membership = m
+		c.shard = newSharder(c.instanceID, nil)
+		if _, err := c.refreshShard(); err != nil {
+			return fmt.Errorf("shard discovery: %w", err)
+		}
+		c.logger.Debug("joined shard", "instance_id", c.instanceID, "members", c.shard.list())
+	}
+	return nil
+}
+
+func (c *Collector) shardMemberTTL() time.Duration {
+	if c.cfg.ShardMemberTTL > 0 {
+		return c.cfg.ShardMemberTTL
+	}
+	return DefaultShardMemberTTL
+}
+
+// refreshShard heartbeats the membership and reloads the members, reporting whether
+// they changed.
+func (c *Collector) refreshShard() (bool, error) {
+	if err := c.membership.Heartbeat(c.instanceID, c.shardMemberTTL()); err != nil {
+		return false, err
+	}
+	members, err := c.membership.Members()
+	if err != nil {
+		return false, err
+	}
+	return c.shard.setMembers(members), nil
+}
+
+// heartbeatShard refreshes the membership every ShardMemberTTL/3 until Stop and
+// rescans when the members change, so that files move to their new owners. A
+// collector that cannot heartbeat before its membership expires gives up all its
+// files, as the other members take them over.
+func (c *Collector) heartbeatShard() {
+	defer c.workerWg.Done()
+	ttl := c.shardMemberTTL()
+	ticker := c.clock.NewTicker(max(ttl/3, time.Millisecond))
+	defer ticker.Stop()
+	expires := c.clock.Now().Add(ttl)
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C():
+			changed, err := c.refreshShard()
+			if err != nil {
+				c.logger.Warn("failed to heartbeat shard membership", "instance_id", c.instanceID, "error", err)
+				c.reportError(err, ErrorContext{Kind: ErrorKindStore, Op: "shard"})
+				if !c.clock.Now().Before(expires) && c.shard.detach() {
+					c.logger.Warn("shard membership expired, handing over all files", "instance_id", c.instanceID)
+					c.watcher.Rescan()
+				}
+				continue
+			}
+			expires = c.clock.Now().Add(ttl)
+			if changed {
+				c.logger.Info("shard members changed", "instance_id", c.instanceID, "members", c.shard.list())
+				c.watcher.Rescan()
+			}
+		}
+	}
+}
+
+// leaveShard unregisters the collector on Stop so the other members take its files
+// over right away.
+func (c *Collector) leaveShard() {
+	if c.membership == nil {
+		return
+	}
+	if err := c.membership.Leave(c.instanceID); err != nil {
+		c.logger.Warn("failed to leave shard", "instance_id", c.instanceID, "error", err)
+		c.reportError(err, ErrorContext{Kind: ErrorKindStore, Op: "shard"})
+	}
+}
@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharder(t *testing.T) {
+	members := []string{"a", "b", "c"}
+	shards := make([]*sharder, len(members))
+	for i, m := range members {
+		shards[i] = newSharder(m, members)
+	}
+
+	// Every file has exactly one owner, and the files are spread evenly
+	owned := make([]int, len(shards))
+	before := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		id := fmt.Sprintf("file-%d", i)
+		owners := 0
+		for j, s := range shards {
+			if s.owns(id) {
+				owners++
+				owned[j]++
+				before[id] = j
+			}
+		}
+		require.Equal(t, 1, owners, id)
+	}
+	for j, n := range owned {
+		assert.Greater(t, n, 800, members[j])
+	}
+
+	// A joining member only takes files over; none move between the others
+	shards = append(shards, newSharder("d", append(members, "d")))
+	for _, s := range shards[:3] {
+		assert.True(t, s.setMembers(append(members, "d")))
+		assert.False(t, s.setMembers([]string{"d", "c", "b", "a"}), "order does not matter")
+	}
+	moved := 0
+	for id, j := range before {
+		if !shards[j].owns(id) {
+			moved++
+			assert.True(t, shards[3].owns(id), id)
+		}
+	}
+	assert.Greater(t, moved, 500)
+	assert.Less(t, moved, 1000)
+
+	// A detached sharder owns nothing until its members are set again
+	assert.True(t, shards[0].detach())
+	assert.False(t, shards[0].detach())
+	assert.Nil(t, shards[0].list())
+	assert.False(t, shards[0].owns("file-0"))
+	assert.True(t, shards[0].setMembers([]string{"a"}), "reattaching is a change")
+	assert.True(t, shards[0].owns("file-0"))
+	assert.Equal(t, []string{"a"}, shards[0].list())
+}
+
+// shardCollector reads dir with the given options, collecting its lines.
+type shardCollector struct {
+	*Collector
+	mu    sync.Mutex
+	lines []string
+}
+
+func newShardCollector(t *testing.T, dir string, opts ...Option) *shardCollector {
+	sc := &shardCollector{}
+	opts = append([]Option{
+		WithInclude(dir),
+		WithPollInterval(20 * time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithOnLine(func(line string) {
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			sc.lines = append(sc.lines, line)
+		}),
+	}, opts...)
+	c, err := New(opts...)
+	require.NoError(t, err)
+	sc.Collector = c
+	return sc
+}
+
+// read returns the lines read that start with prefix.
+func (sc *shardCollector) read(prefix string) []string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var out []string
+	for _, line := range sc.lines {
+		if strings.HasPrefix(line, prefix) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func writeShardFiles(t *testing.T, dir, line string, n int) {
+	for i := 0; i < n; i++ {
+		f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%02d.log", i)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = fmt.Fprintf(f, "%s-%02d\n", line, i)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+}
+
+func TestCollector_StaticShards(t *testing.T) {
+	dir := t.TempDir()
+	writeShardFiles(t, dir, "line", 20)
+
+	a := newShardCollector(t, dir, WithShard(0, 2))
+	b := newShardCollector(t, dir, WithShard(1, 2))
+	a.Start()
+	defer a.Stop()
+	b.Start()
+	defer b.Stop()
+
+	assert.Eventually(t, func() bool { return len(a.read("line"))+len(b.read("line")) == 20 },
+		2*time.Second, 20*time.Millisecond)
+	assert.NotEmpty(t, a.read("line"))
+	assert.NotEmpty(t, b.read("line"))
+	assert.Len(t, a.TrackedFiles(), len(a.read("line")), "files of the other shard are not tracked")
+	assert.Equal(t, []string{"0", "1"}, a.ShardMembers())
+	assert.Equal(t, []string{"0", "1"}, a.DebugState().ShardMembers)
+}
+
+func TestCollector_ShardDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	writeShardFiles(t, dir, "first", 20)
+	ttl := 300 * time.Millisecond
+
+	a := newShardCollector(t, dir, WithStore(dbPath), WithLease("a", time.Minute), WithShardDiscovery(ttl))
+	a.Start()
+	defer a.Stop()
+	assert.Eventually(t, func() bool { return len(a.read("first")) == 20 }, 2*time.Second, 20*time.Millisecond,
+		"a single member owns every file")
+
+	// A second member takes part of the files over at the offsets the first committed
+	b := newShardCollector(t, dir, WithStore(dbPath), WithLease("b", time.Minute), WithShardDiscovery(ttl))
+	assert.Equal(t, []string{"a", "b"}, b.ShardMembers())
+	b.Start()
+	assert.Eventually(t, func() bool { return len(a.ShardMembers()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return len(a.TrackedFiles())+len(b.TrackedFiles()) == 20 },
+		2*time.Second, 10*time.Millisecond)
+	writeShardFiles(t, dir, "second", 20)
+	assert.Eventually(t, func() bool { return len(a.read("second"))+len(b.read("second")) == 20 },
+		2*time.Second, 20*time.Millisecond)
+	assert.NotEmpty(t, b.read("second"))
+	assert.Empty(t, b.read("first"), "handed over files are not read again")
+
+	// Leaving hands the files back
+	b.Stop()
+	assert.Eventually(t, func() bool { return len(a.ShardMembers()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	writeShardFiles(t, dir, "third", 20)
+	assert.Eventually(t, func() bool { return len(a.read("third")) == 20 }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestConfig_ShardValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"index without count", Config{ShardIndex: 1}, "requires a shard count"},
+		{"discovery and count", Config{ShardDiscovery: true, ShardCount: 2, StoreOffsets: true}, "mutually exclusive"},
+		{"discovery without store", Config{ShardDiscovery: true}, "requires an offset store"},
+		{"standby", Config{ShardCount: 2, Standby: true, StoreOffsets: true}, "standby"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorContains(t, tc.cfg.validateSharding(), tc.want)
+		})
+	}
+}
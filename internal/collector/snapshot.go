@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+)
+
+// OffsetSnapshotVersion is the OffsetSnapshot format written by ExportOffsets.
+const OffsetSnapshotVersion = 1
+
+// OffsetSnapshot is a portable JSON copy of an offsets store, for backups before
+// upgrades and for moving state between hosts.
+type OffsetSnapshot struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Offsets    []SnapshotOffset `json:"offsets"`
+}
+
+// SnapshotOffset is one stored offset in an OffsetSnapshot.
+type SnapshotOffset struct {
+	ID        string    `json:"id"`
+	Strategy  string    `json:"strategy"`
+	Path      string    `json:"path"`
+	Offset    int64     `json:"offset"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportOffsets writes the offsets stored in the database at dbPath to w as an
+// indented OffsetSnapshot, for all strategies or only the given one. It returns the
+// number of offsets written; a missing database is an error rather than created.
+func ExportOffsets(dbPath, strategy string, w io.Writer) (int, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return 0, err
+	}
+	db, err := store.NewSQLiteStore(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = db.Close() }()
+
+	entries, err := db.List(strategy)
+	if err != nil {
+		return 0, err
+	}
+	snap := OffsetSnapshot{
+		Version:    OffsetSnapshotVersion,
+		ExportedAt: time.Now().UTC(),
+		Offsets:    make([]SnapshotOffset, 0, len(entries)),
+	}
+	for _, e := range entries {
+		snap.Offsets = append(snap.Offsets, SnapshotOffset{
+			ID: e.ID, Strategy: e.Strategy, Path: e.Path, Offset: e.Offset, UpdatedAt: e.UpdatedAt.UTC(),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snap); err != nil {
+		return 0, err
+	}
+	return len(snap.Offsets), nil
+}
+
+// RestoreOffsets stores the offsets of an OffsetSnapshot read from r in the database at
+// dbPath, overwriting rows with the same ID and strategy. With replace, every other
+// stored offset is deleted first so the store matches the snapshot exactly. It returns
+// the number of offsets restored. Offsets are written as they were: device+inode IDs
+// only identify the same files on the filesystem they were exported from.
+func RestoreOffsets(dbPath string, r io.Reader, replace bool) (int, error) {
+	var snap OffsetSnapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("parse offsets snapshot: %w", err)
+	}
+	if snap.Version < 1 || snap.Version > OffsetSnapshotVersion {
+		return 0, fmt.Errorf("unsupported offsets snapshot version %d", snap.Version)
+	}
+	for _, o := range snap.Offsets {
+		if o.ID == "" || o.Strategy == "" || o.Offset < 0 {
+			return 0, fmt.Errorf("invalid offsets snapshot entry for %q", o.Path)
+		}
+	}
+
+	db, err := store.NewSQLiteStore(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = db.Close() }()
+
+	if replace {
+		existing, err := db.List("")
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range existing {
+			if err := db.Delete(e.ID, e.Strategy); err != nil {
+				return 0, err
+			}
+		}
+	}
+	for i, o := range snap.Offsets {
+		if err := db.Save(o.ID, o.Strategy, o.Path, o.Offset); err != nil {
+			return i, err
+		}
+	}
+	return len(snap.Offsets), nil
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/freader/internal/store"
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRestoreOffsets(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src.db")
+	db, err := store.NewSQLiteStore(src)
+	require.NoError(t, err)
+	require.NoError(t, db.Save("c1", watcher.FingerprintStrategyChecksum, "/var/log/a.log", 10))
+	require.NoError(t, db.Save("d1", watcher.FingerprintStrategyDeviceAndInode, "/var/log/b.log", 20))
+	require.NoError(t, db.Close())
+
+	var buf bytes.Buffer
+	n, err := ExportOffsets(src, "", &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	var snap OffsetSnapshot
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &snap))
+	assert.Equal(t, OffsetSnapshotVersion, snap.Version)
+	require.Len(t, snap.Offsets, 2)
+	assert.Equal(t, SnapshotOffset{ID: "c1", Strategy: "checksum", Path: "/var/log/a.log", Offset: 10, UpdatedAt: snap.Offsets[0].UpdatedAt}, snap.Offsets[0])
+
+	var only bytes.Buffer
+	n, err = ExportOffsets(src, watcher.FingerprintStrategyChecksum, &only)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Merge into a store holding other offsets, then replace it entirely
+	dst := filepath.Join(t.TempDir(), "dst.db")
+	db, err = store.NewSQLiteStore(dst)
+	require.NoError(t, err)
+	require.NoError(t, db.Save("c1", watcher.FingerprintStrategyChecksum, "/var/log/a.log", 1))
+	require.NoError(t, db.Save("other", watcher.FingerprintStrategyChecksum, "/var/log/c.log", 5))
+	require.NoError(t, db.Close())
+
+	list := func() []store.Entry {
+		db, err := store.NewSQLiteStore(dst)
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+		entries, err := db.List("")
+		require.NoError(t, err)
+		return entries
+	}
+
+	n, err = RestoreOffsets(dst, bytes.NewReader(buf.Bytes()), false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	entries := list()
+	require.Len(t, entries, 3)
+	assert.Equal(t, int64(10), entries[0].Offset)
+
+	_, err = RestoreOffsets(dst, bytes.NewReader(buf.Bytes()), true)
+	require.NoError(t, err)
+	entries = list()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/var/log/b.log", entries[1].Path)
+}
+
+func TestRestoreOffsets_Invalid(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "dst.db")
+	for _, in := range []string{
+		`not json`,
+		`{"version":2,"offsets":[]}`,
+		`{"version":1,"offsets":[{"path":"/a","strategy":"checksum","offset":1}]}`,
+	} {
+		_, err := RestoreOffsets(dst, strings.NewReader(in), false)
+		assert.Error(t, err, in)
+	}
+	_, err := os.Stat(dst)
+	assert.ErrorIs(t, err, os.ErrNotExist, "invalid snapshots must not create the store")
+
+	_, err = ExportOffsets(filepath.Join(t.TempDir(), "missing.db"), "", &bytes.Buffer{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
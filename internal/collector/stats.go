@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// Stats is a point-in-time snapshot of a collector's state returned by Collector.Stats.
+type Stats struct {
+	TrackedFiles     int
+	LinesRead        int64 // records delivered to callbacks since NewCollector
+	BytesRead        int64 // record bytes delivered, excluding separators
+	QueueDepth       int   // files scheduled for reading
+	ActiveReads      int   // files currently being read by a worker
+	IdleFiles        int   // files only rechecked every Config.IdleRecheckInterval
+	Paused           bool
+	LastScanAt       time.Time
+	LastScanDuration time.Duration
+	Files            []FileStats // sorted by path
+	// Unreadable lists files and directories failing with permission errors; they are
+	// retried with back-off.
+	Unreadable []UnreadableFile
+	// Deleted lists files deleted while open that are still read with
+	// Config.HoldDeleted, sorted by path; they are not among Files.
+	Deleted []DeletedFile
+	// Quarantined lists files left unread because their stored offset failed
+	// verification; see OffsetMismatchQuarantine. They are not among Files.
+	Quarantined []QuarantinedFile
+}
+
+// UnreadableFile describes a path that could not be opened for lack of permission.
+type UnreadableFile = watcher.UnreadableFile
+
+// FileStats describes one tracked file.
+type FileStats struct {
+	ID     string
+	Path   string
+	Offset int64
+	// Position is how far reading has got, including a read still in progress; it runs
+	// ahead of Offset, which only moves once a read completes, and tracks progress
+	// through large files.
+	Position int64
+	Size     int64 // current size on disk; -1 if the file could not be stat'ed
+	Lag      int64 // Size - Offset, i.e. bytes not yet read; 0 when Size is unknown
+}
+
+// TrackedFile describes a file the collector is reading, as returned by TrackedFiles.
+type TrackedFile struct {
+	ID       string // fingerprint
+	Path     string
+	Strategy string // fingerprint strategy of ID; deviceAndInode for provisional IDs
+	Offset   int64
+	// Position is how far reading has got, including a read still in progress; see
+	// FileStats.
+	Position int64
+	Size     int64     // current size on disk; -1 if the file could not be stat'ed
+	Lag      int64     // Size - Offset, i.e. bytes not yet read; 0 when Size is unknown
+	LastRead time.Time // when a read last found new data; zero if none has yet
+	// Separator is the separator Config.SeparatorAutoDetect chose for the file, `\r?\n`
+	// when it could not decide, or "" when detection did not apply.
+	Separator string
+}
+
+// TrackedFiles returns the files being read, sorted by path, for applications
+// rendering their own status pages. File sizes are read from disk, so the cost grows
+// with the number of tracked files.
+func (c *Collector) TrackedFiles() []TrackedFile {
+	files := c.fileManager.GetAllFiles()
+	tracked := make([]TrackedFile, 0, len(files))
+	for id, f := range files {
+		if c.isQuarantined(id) {
+			continue
+		}
+		tf := TrackedFile{ID: id, Path: f.Path, Strategy: f.FingerprintStrategy, Offset: f.Offset, Position: f.Offset, Size: -1}
+		if p, ok := c.positions.Load(id); ok {
+			tf.Position = max(tf.Position, p.(*atomic.Int64).Load())
+		}
+		if at, ok := c.lastRead.Load(id); ok {
+			tf.LastRead = at.(time.Time)
+		}
+		tf.Separator = c.detectedSeparator(id)
+		if info, err := os.Stat(f.Path); err == nil {
+			tf.Size = info.Size()
+			if lag := tf.Size - tf.Offset; lag > 0 {
+				tf.Lag = lag
+			}
+		}
+		tracked = append(tracked, tf)
+	}
+	sort.Slice(tracked, func(i, j int) bool { return tracked[i].Path < tracked[j].Path })
+	return tracked
+}
+
+// Stats returns a snapshot of the collector's counters, tracked files and scan timing.
+// File sizes are read from disk, so the cost grows with the number of tracked files.
+func (c *Collector) Stats() Stats {
+	return c.stats(c.TrackedFiles())
+}
+
+// stats returns the Stats of the collector with files, as returned by TrackedFiles.
+func (c *Collector) stats(files []TrackedFile) Stats {
+	st := Stats{
+		TrackedFiles: len(files),
+		LinesRead:    c.linesRead.Load(),
+		BytesRead:    c.bytesRead.Load(),
+		QueueDepth:   c.scheduler.GetCount(),
+		ActiveReads:  c.scheduler.RunningCount(),
+		IdleFiles:    c.scheduler.IdleCount(),
+		Paused:       c.scheduler.Paused(),
+		Files:        make([]FileStats, 0, len(files)),
+	}
+	st.LastScanAt, st.LastScanDuration = c.watcher.LastScan()
+	st.Unreadable = c.unreadable.List()
+	st.Deleted = c.deletedFiles()
+	st.Quarantined = c.quarantinedFiles()
+
+	for _, f := range files {
+		st.Files = append(st.Files, FileStats{ID: f.ID, Path: f.Path, Offset: f.Offset, Position: f.Position, Size: f.Size, Lag: f.Lag})
+	}
+	return st
+}
+
+// Unreadable returns the files and directories currently failing with permission errors.
+func (c *Collector) Unreadable() []UnreadableFile {
+	return c.unreadable.List()
+}
+
+// LastScan returns when the watcher last finished a scan and how long it took, without
+// the per-file cost of Stats. The zero time is returned before the first scan completes.
+func (c *Collector) LastScan() (time.Time, time.Duration) {
+	return c.watcher.LastScan()
+}
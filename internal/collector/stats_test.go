@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector_Stats(t *testing.T) {
+	tempDir := t.TempDir()
+	a := filepath.Join(tempDir, "a.log")
+	b := filepath.Join(tempDir, "b.log")
+	assert.NoError(t, os.WriteFile(a, []byte("one\ntwo\n"), 0644))
+	assert.NoError(t, os.WriteFile(b, []byte("three\n"), 0644))
+
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     3,
+		OnLineFunc:          func(string) {},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+
+	before := c.Stats()
+	assert.Equal(t, 0, before.TrackedFiles)
+	assert.True(t, before.LastScanAt.IsZero())
+
+	c.Start()
+	defer c.Stop()
+
+	assert.Eventually(t, func() bool { return c.Stats().LinesRead == 3 }, 3*time.Second, 20*time.Millisecond)
+
+	st := c.Stats()
+	assert.Equal(t, 2, st.TrackedFiles)
+	assert.Equal(t, 2, st.QueueDepth)
+	assert.Equal(t, int64(len("one")+len("two")+len("three")), st.BytesRead)
+	assert.False(t, st.LastScanAt.IsZero())
+	if assert.Len(t, st.Files, 2) {
+		assert.Equal(t, a, st.Files[0].Path)
+		assert.Equal(t, int64(8), st.Files[0].Offset)
+		assert.Equal(t, int64(0), st.Files[0].Lag)
+		assert.Equal(t, b, st.Files[1].Path)
+	}
+
+	files := c.TrackedFiles()
+	if assert.Len(t, files, 2) {
+		assert.Equal(t, st.Files[0].ID, files[0].ID)
+		assert.Equal(t, a, files[0].Path)
+		assert.Equal(t, watcher.FingerprintStrategyChecksum, files[0].Strategy)
+		assert.Equal(t, int64(8), files[0].Offset)
+		assert.Equal(t, int64(8), files[0].Size)
+		assert.False(t, files[0].LastRead.IsZero())
+	}
+}
+
+func TestCollector_Stats_PositionDuringRead(t *testing.T) {
+	tempDir := t.TempDir()
+	a := filepath.Join(tempDir, "a.log")
+	assert.NoError(t, os.WriteFile(a, []byte("one\ntwo\nthree\n"), 0644))
+
+	release := make(chan struct{})
+	reached := make(chan struct{})
+	cfg := Config{
+		Include:             []string{tempDir},
+		PollInterval:        50 * time.Millisecond,
+		WorkerCount:         1,
+		Separator:           "\n",
+		FingerprintStrategy: watcher.FingerprintStrategyChecksum,
+		FingerprintSize:     3,
+		OnLineFunc: func(line string) {
+			if line == "two" {
+				close(reached)
+				<-release
+			}
+		},
+	}
+	c, err := NewCollector(cfg)
+	assert.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case <-reached:
+	case <-time.After(3 * time.Second):
+		t.Fatal("second record not read")
+	}
+	// The read is blocked on "two": the offset is not committed yet, the position is
+	st := c.Stats()
+	if assert.Len(t, st.Files, 1) {
+		assert.Equal(t, int64(0), st.Files[0].Offset)
+		assert.Equal(t, int64(len("one\n")), st.Files[0].Position)
+	}
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		f := c.Stats().Files[0]
+		return f.Offset == 14 && f.Position == 14
+	}, 3*time.Second, 20*time.Millisecond)
+}
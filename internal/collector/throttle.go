@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/loykin/freader/internal/clock"
+)
+
+// rateLimiter spreads reads sharing it over time to stay at rate bytes per second on
+// average; see Config.StartupReadRateLimit.
+type rateLimiter struct {
+	mu    sync.Mutex
+	rate  float64
+	next  time.Time // when the bytes read so far have been paid for
+	clock clock.Clock
+}
+
+func newRateLimiter(bytesPerSecond int64, clk clock.Clock) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSecond), clock: clk}
+}
+
+// wait accounts for n bytes just read and blocks until the reads before them fit the
+// rate, or stop is closed.
+func (l *rateLimiter) wait(n int, stop <-chan struct{}) {
+	l.mu.Lock()
+	now := l.clock.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return
+	}
+	select {
+	case <-l.clock.After(delay):
+	case <-stop:
+	}
+}
+
+// startupFile marks id, found by the first scan, as on its first pass: its first read
+// shares cfg.StartupReadRateLimit, and with cfg.StartupStagger it is only handed out
+// a stagger after the startup file before it.
+func (c *Collector) startupFile(id string) {
+	if c.cfg.StartupReadRateLimit <= 0 && c.cfg.StartupStagger <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startup[id] = true
+	if c.cfg.StartupStagger > 0 {
+		now := c.clock.Now()
+		if c.startupNext.Before(now) {
+			c.startupNext = now
+		}
+		c.scheduler.Delay(id, c.startupNext)
+		c.startupNext = c.startupNext.Add(c.cfg.StartupStagger)
+	}
+}
+
+// startupThrottle returns the read throttle for file id while it is on its first pass,
+// or nil if it is past it or reads are not limited. A read of id that completes ends
+// the first pass.
+func (c *Collector) startupThrottle(id string, stop <-chan struct{}) func(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.startup[id] || c.startupLimit == nil {
+		return nil
+	}
+	return func(n int) { c.startupLimit.wait(n, stop) }
+}
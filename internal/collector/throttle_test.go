@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	clk := testkit.NewFakeClock(time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC))
+	l := newRateLimiter(1000, clk)
+
+	// The first read is paid for afterwards
+	l.wait(500, nil)
+	done := make(chan struct{})
+	go func() {
+		l.wait(1000, nil)
+		close(done)
+	}()
+	require.True(t, clk.BlockUntil(1, 3*time.Second))
+	clk.Advance(499 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("read before the previous one was paid for")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clk.Advance(time.Millisecond)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for the limiter")
+	}
+
+	// Stop ends the wait for the 1000 bytes read before
+	stop := make(chan struct{})
+	close(stop)
+	l.wait(1, stop)
+}
+
+func TestCollector_StartupThrottle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte("a1\na2\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.log"), []byte("b1\n"), 0644))
+
+	var (
+		mu    sync.Mutex
+		lines = map[string]time.Time{}
+	)
+	c, err := New(WithInclude(dir), WithPollInterval(50*time.Millisecond), WithWorkers(2),
+		WithFingerprint(watcher.FingerprintStrategyDeviceAndInode, 0),
+		WithStartupThrottle(1<<20, 500*time.Millisecond),
+		WithOnLine(func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines[line] = time.Now()
+		}))
+	require.NoError(t, err)
+	started := time.Now()
+	c.Start()
+	defer c.Stop()
+
+	received := func(line string) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			_, ok := lines[line]
+			return ok
+		}
+	}
+	assert.Eventually(t, received("a2"), 2*time.Second, 10*time.Millisecond)
+	// b.log, found by the same scan, is only read a stagger later
+	assert.Eventually(t, received("b1"), 3*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.GreaterOrEqual(t, lines["b1"].Sub(started), 500*time.Millisecond)
+	mu.Unlock()
+
+	// Files found later are read right away
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.log"), []byte("c1\n"), 0644))
+	assert.Eventually(t, received("c1"), 2*time.Second, 10*time.Millisecond)
+}
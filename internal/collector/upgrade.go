@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/tailer"
+	"github.com/loykin/freader/internal/watcher"
+)
+
+// upgraded passes the offset of fileTail, dropped from the provisional ID it was read
+// under with cfg.UpgradeFingerprints, on to the checksum ID the file is tracked under
+// now, before that ID is first read. Called once what fileTail still held has been
+// delivered.
+func (c *Collector) upgraded(fileTail *tailer.TailReader) {
+	c.mu.Lock()
+	id, ok := c.upgrades[fileTail.FileId]
+	delete(c.upgrades, fileTail.FileId)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	offset := fileTail.SafeOffset()
+	c.logger.Debug("file fingerprinted, keeping its offset", "file", id, "provisional", fileTail.FileId, "offset", offset)
+	c.scheduler.Seek(id, offset)
+	c.fileManager.UpdateOffset(id, offset)
+	if !c.storesOffsets() {
+		return
+	}
+	path := c.pathOf(id)
+	if err := c.offsetDB.Save(id, c.cfg.FingerprintStrategy, path, offset); err != nil {
+		c.logger.Error("failed to save offset", "file", id, "offset", offset, "error", err)
+		c.reportError(err, ErrorContext{Kind: ErrorKindStore, FileID: id, Path: path, Op: "save"})
+	}
+}
+
+// provisionalOffset returns the offset stored for the file at path under its
+// provisional ID by an earlier run, in which it was still too small to fingerprint,
+// and deletes it. found is false if there is none.
+func (c *Collector) provisionalOffset(path string) (offset int64, found bool) {
+	id, err := file_tracker.GetFileIDFromPath(path)
+	if err != nil {
+		return 0, false
+	}
+	offset, found, err = c.offsetDB.Load(id, c.cfg.FingerprintStrategy)
+	if err == nil && !found && c.cfg.KeepDuplicates {
+		id = watcher.DuplicateID(id, path)
+		offset, found, err = c.offsetDB.Load(id, c.cfg.FingerprintStrategy)
+	}
+	if err != nil || !found {
+		return 0, false
+	}
+	if c.storesOffsets() {
+		if err := c.offsetDB.Delete(id, c.cfg.FingerprintStrategy); err != nil {
+			c.logger.Error("failed to delete offset", "file", id, "error", err)
+			c.reportError(err, ErrorContext{Kind: ErrorKindStore, FileID: id, Path: path, Op: "delete"})
+		}
+	}
+	return offset, true
+}
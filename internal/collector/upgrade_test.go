@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/loykin/freader/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_UpgradeFingerprints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	w, err := testkit.NewLogWriter(filepath.Join(base, "app.log"))
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(2)
+	require.NoError(t, err)
+
+	sink := testkit.NewLineSink()
+	c, err := New(WithInclude(base), WithPollInterval(50*time.Millisecond),
+		WithFingerprint(watcher.FingerprintStrategyChecksum, 64),
+		WithFingerprintUpgrade(), WithOnLine(sink.Add))
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	// Far smaller than the fingerprint, the file is read right away
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+
+	// Grown past the fingerprint, it is read on from where it got to
+	_, err = w.Write(12)
+	require.NoError(t, err)
+	id, err := file_tracker.GetFileFingerprintFromPath(w.Path(), 64)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		files := c.Stats().Files
+		return len(files) == 1 && files[0].ID == id
+	}, 3*time.Second, 20*time.Millisecond, "not tracked under its checksum")
+	_, err = w.Write(1)
+	require.NoError(t, err)
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+	time.Sleep(200 * time.Millisecond)
+	testkit.AssertLines(t, sink.Lines(), w.Written())
+}
+
+func TestCollector_UpgradeFingerprintsStored(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based collector tests on Windows")
+	}
+	base := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "offsets.db")
+	w, err := testkit.NewLogWriter(filepath.Join(base, "app.log"))
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(1)
+	require.NoError(t, err)
+
+	run := func() *testkit.LineSink {
+		sink := testkit.NewLineSink()
+		c, err := New(WithInclude(base), WithPollInterval(50*time.Millisecond),
+			WithFingerprint(watcher.FingerprintStrategyChecksum, 64),
+			WithFingerprintUpgrade(), WithStore(dbPath), WithOnLine(sink.Add))
+		require.NoError(t, err)
+		c.Start()
+		defer c.Stop()
+		sink.Wait(1, 3*time.Second)
+		time.Sleep(200 * time.Millisecond)
+		return sink
+	}
+	testkit.AssertLines(t, run().Lines(), w.Written())
+
+	// Grown past the fingerprint while stopped: the offset stored under the
+	// provisional ID still applies
+	_, err = w.Write(10)
+	require.NoError(t, err)
+	testkit.AssertLines(t, run().Lines(), w.Written()[1:])
+}
@@ -5,6 +5,14 @@ import (
 	"fmt"
 )
 
+// Sentinel errors matched via errors.Is by the corresponding typed errors below.
+var (
+	// ErrFileTooSmall indicates a file is smaller than the checksum fingerprint size.
+	ErrFileTooSmall = errors.New("file too small for fingerprint")
+	// ErrNotEnoughSeparators indicates a file has fewer separators than the fingerprint requires.
+	ErrNotEnoughSeparators = errors.New("not enough separators for fingerprint")
+)
+
 type FileSizeTooSmallError struct {
 	Expected int64
 	Actual   int64
@@ -14,6 +22,9 @@ func (e *FileSizeTooSmallError) Error() string {
 	return fmt.Sprintf("expected file size to be greater than %d bytes, got %d bytes", e.Expected, e.Actual)
 }
 
+// Is reports whether target is ErrFileTooSmall.
+func (e *FileSizeTooSmallError) Is(target error) bool { return target == ErrFileTooSmall }
+
 // IsFileSizeTooSmall determines if the provided error is of type FileSizeTooSmallError.
 func IsFileSizeTooSmall(err error) bool {
 	var sizeErr *FileSizeTooSmallError
@@ -48,6 +59,9 @@ func (e *NotEnoughSeparatorsError) Error() string {
 	return fmt.Sprintf("expected at least %d occurrences of separator, got %d", e.Expected, e.Actual)
 }
 
+// Is reports whether target is ErrNotEnoughSeparators.
+func (e *NotEnoughSeparatorsError) Is(target error) bool { return target == ErrNotEnoughSeparators }
+
 func IsNotEnoughSeparators(err error) bool {
 	var e *NotEnoughSeparatorsError
 	return errors.As(err, &e)
@@ -16,3 +16,12 @@ func GetFileID(info os.FileInfo) (string, error) {
 	// Linux는 dev + ino만 사용
 	return fmt.Sprintf("dev:%d-ino:%d", stat.Dev, stat.Ino), nil
 }
+
+// DeviceAndInode returns the device and inode numbers of info.
+func DeviceAndInode(info os.FileInfo) (dev, ino uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), stat.Ino, true
+}
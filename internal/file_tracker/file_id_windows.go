@@ -11,3 +11,8 @@ import (
 func GetFileID(info os.FileInfo) (string, error) {
 	return "", errors.New("unsupported OS: windows")
 }
+
+// DeviceAndInode is not available on Windows; ok is always false.
+func DeviceAndInode(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
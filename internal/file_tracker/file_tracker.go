@@ -64,6 +64,33 @@ func (f *FileTracker) UpdateOffset(fileId string, offset int64) bool {
 	return false
 }
 
+// UpdatePath records that fileId is now found at path, e.g. after it was renamed.
+func (f *FileTracker) UpdatePath(fileId string, path string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if file, exists := f.info[fileId]; exists {
+		file.Path = path
+		f.info[fileId] = file
+		return true
+	}
+	return false
+}
+
+// UpdateFingerprintOffset records that fileId is fingerprinted from offset rather than
+// from its first byte.
+func (f *FileTracker) UpdateFingerprintOffset(fileId string, offset int64) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if file, exists := f.info[fileId]; exists {
+		file.FingerprintOffset = offset
+		f.info[fileId] = file
+		return true
+	}
+	return false
+}
+
 func (f *FileTracker) GetAllFiles() map[string]TrackedFile {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
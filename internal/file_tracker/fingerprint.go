@@ -11,7 +11,7 @@ import (
 )
 
 func GetFileFingerprintFromPath(path string, maxBytes int64) (string, error) {
-	file, err := os.Open(path)
+	file, err := Open(path)
 	if err != nil {
 		return "", fmt.Errorf("cannot open file: %s: %w", path, err)
 	}
@@ -20,29 +20,52 @@ func GetFileFingerprintFromPath(path string, maxBytes int64) (string, error) {
 	return GetFileFingerprint(file, maxBytes)
 }
 
+// GetFileFingerprintRangeFromPath is GetFileFingerprintRange for the file at path.
+func GetFileFingerprintRangeFromPath(path string, offset, maxBytes int64) (string, error) {
+	file, err := Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open file: %s: %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	return GetFileFingerprintRange(file, offset, maxBytes)
+}
+
 // GetFileFingerprint computes SHA-256 hash of a file's content, up to a specified maximum number of bytes.
 // It takes a file path and a maximum byte limit, returning the hash as a hexadecimal string or an error.
 // If the file size is smaller than maxBytes, it returns an error to indicate insufficient content for fingerprinting.
 // This is intentional to ensure consistent fingerprinting behavior (e.g., avoiding false matches on partial content).
 func GetFileFingerprint(file *os.File, maxBytes int64) (string, error) {
+	return GetFileFingerprintRange(file, 0, maxBytes)
+}
+
+// GetFileFingerprintRange is GetFileFingerprint over the maxBytes bytes starting at
+// offset, so files sharing an identical header (CSV header rows, banner preambles) can
+// be told apart by the content after it. The file must be at least offset+maxBytes
+// bytes long. It reads with ReadAt and leaves the file position unchanged.
+func GetFileFingerprintRange(file *os.File, offset, maxBytes int64) (string, error) {
 	info, err := file.Stat()
 	if err != nil {
 		return "", err
 	}
+	if offset < 0 {
+		return "", fmt.Errorf("negative fingerprint offset %d", offset)
+	}
 
 	// Return error only if file is smaller than required minimum
 	// This allows the caller (watcher) to skip the file gracefully
-	if info.Size() < maxBytes {
+	if info.Size() < offset+maxBytes {
 		return "", &FileSizeTooSmallError{
-			Expected: maxBytes,
+			Expected: offset + maxBytes,
 			Actual:   info.Size(),
 		}
 	}
 
-	var reader io.Reader = file
-	if maxBytes > 0 {
-		reader = io.LimitReader(file, maxBytes)
+	n := maxBytes
+	if n <= 0 {
+		n = info.Size() - offset
 	}
+	reader := io.NewSectionReader(file, offset, n)
 
 	hash := sha256.New()
 	if _, err := io.Copy(hash, reader); err != nil {
@@ -128,7 +151,7 @@ func GetFileFingerprintUntilNSeparators(file *os.File, sep string, n int) (strin
 
 // GetFileFingerprintUntilNSeparatorsFromPath opens path and computes separator-based fingerprint.
 func GetFileFingerprintUntilNSeparatorsFromPath(path, sep string, n int) (string, error) {
-	f, err := os.Open(path)
+	f, err := Open(path)
 	if err != nil {
 		return "", fmt.Errorf("cannot open file: %s: %w", path, err)
 	}
@@ -3,6 +3,7 @@ package file_tracker
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -249,6 +250,20 @@ func TestGetFileFingerprintUntilNSeparators_NotEnough(t *testing.T) {
 	_, err := GetFileFingerprintUntilNSeparatorsFromPath(p, "<END>", 2)
 	assert.Error(t, err)
 	assert.True(t, IsNotEnoughSeparators(err))
+	assert.ErrorIs(t, err, ErrNotEnoughSeparators)
+	assert.NotErrorIs(t, err, ErrFileTooSmall)
+}
+
+func TestFingerprintErrors_SentinelsMatchWrapped(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "small.txt")
+	assert.NoError(t, os.WriteFile(p, []byte("tiny"), 0644))
+
+	_, err := GetFileFingerprintFromPath(p, 1024)
+	wrapped := fmt.Errorf("scan %s: %w", p, err)
+	assert.ErrorIs(t, wrapped, ErrFileTooSmall)
+	var sizeErr *FileSizeTooSmallError
+	assert.ErrorAs(t, wrapped, &sizeErr)
+	assert.Equal(t, int64(4), sizeErr.Actual)
 }
 
 func TestGetFileFingerprintUntilNSeparators_CRLF(t *testing.T) {
@@ -262,3 +277,35 @@ func TestGetFileFingerprintUntilNSeparators_CRLF(t *testing.T) {
 	h := sha256.Sum256([]byte(content))
 	assert.Equal(t, hex.EncodeToString(h[:]), fp)
 }
+
+func TestGetFileFingerprintRange(t *testing.T) {
+	dir := t.TempDir()
+	header := "time,level,message\n"
+	a := filepath.Join(dir, "a.csv")
+	b := filepath.Join(dir, "b.csv")
+	assert.NoError(t, os.WriteFile(a, []byte(header+"1,INFO,started\n"), 0644))
+	assert.NoError(t, os.WriteFile(b, []byte(header+"2,WARN,retrying\n"), 0644))
+	offset := int64(len(header))
+
+	headA, err := GetFileFingerprintRangeFromPath(a, 0, offset)
+	assert.NoError(t, err)
+	headB, err := GetFileFingerprintRangeFromPath(b, 0, offset)
+	assert.NoError(t, err)
+	assert.Equal(t, headA, headB)
+	plain, err := GetFileFingerprintFromPath(a, offset)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, headA)
+
+	idA, err := GetFileFingerprintRangeFromPath(a, offset, 8)
+	assert.NoError(t, err)
+	idB, err := GetFileFingerprintRangeFromPath(b, offset, 8)
+	assert.NoError(t, err)
+	assert.NotEqual(t, idA, idB)
+	sum := sha256.Sum256([]byte("1,INFO,s"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), idA)
+
+	_, err = GetFileFingerprintRangeFromPath(a, offset, 64)
+	assert.True(t, IsFileSizeTooSmall(err))
+	_, err = GetFileFingerprintRangeFromPath(a, -1, 8)
+	assert.Error(t, err)
+}
@@ -0,0 +1,26 @@
+package file_tracker
+
+import (
+	"os"
+	"time"
+)
+
+// Open retries a file failing with a sharing violation this many more times, waiting
+// lockRetryDelay longer before each attempt.
+const (
+	lockRetries    = 3
+	lockRetryDelay = 20 * time.Millisecond
+)
+
+// Open opens path for reading like os.Open. While the open fails because another
+// process holds the file locked (IsLocked), e.g. a Windows writer reopening it during a
+// rotation, it is retried a few times over roughly a tenth of a second before the error
+// is returned.
+func Open(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	for i := 1; i <= lockRetries && IsLocked(err); i++ {
+		time.Sleep(time.Duration(i) * lockRetryDelay)
+		f, err = os.Open(path)
+	}
+	return f, err
+}
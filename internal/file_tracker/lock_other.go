@@ -0,0 +1,7 @@
+//go:build !windows
+
+package file_tracker
+
+// IsLocked reports whether err is a sharing or lock violation. Only Windows enforces
+// exclusive opens, so it is always false elsewhere.
+func IsLocked(err error) bool { return false }
@@ -0,0 +1,29 @@
+package file_tracker
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("hello\n"), 0o644))
+
+	f, err := Open(path)
+	require.NoError(t, err)
+	b, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(b))
+	assert.NoError(t, f.Close())
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.log"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.False(t, IsLocked(err), "a missing file is not locked")
+	assert.False(t, IsLocked(nil))
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package file_tracker
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// IsLocked reports whether err is a sharing or lock violation: another process opened
+// the file without sharing read access, or locked the range being read.
+func IsLocked(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
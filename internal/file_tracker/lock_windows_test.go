@@ -0,0 +1,44 @@
+//go:build windows
+
+package file_tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+// openExclusive opens path the way a writer denying readers does: without any share
+// mode.
+func openExclusive(t *testing.T, path string) windows.Handle {
+	name, err := windows.UTF16PtrFromString(path)
+	require.NoError(t, err)
+	h, err := windows.CreateFile(name, windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	require.NoError(t, err)
+	return h
+}
+
+func TestOpen_SharingViolation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("hello\n"), 0o644))
+
+	h := openExclusive(t, path)
+	_, err := Open(path)
+	assert.True(t, IsLocked(err), "still held after the retries: %v", err)
+	require.NoError(t, windows.CloseHandle(h))
+
+	// Released while Open is retrying
+	h = openExclusive(t, path)
+	go func() {
+		time.Sleep(lockRetryDelay)
+		_ = windows.CloseHandle(h)
+	}()
+	f, err := Open(path)
+	require.NoError(t, err)
+	assert.NoError(t, f.Close())
+}
@@ -5,4 +5,6 @@ type TrackedFile struct {
 	Path                string
 	FingerprintSize     int64
 	Offset              int64
+	// FingerprintOffset is where the checksum fingerprint of FingerprintSize bytes starts.
+	FingerprintOffset int64
 }
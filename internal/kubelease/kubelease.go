@@ -0,0 +1,359 @@
+// Package kubelease holds the collector lease in a Kubernetes coordination.k8s.io/v1
+// Lease object, so that redundant freader pods can elect a leader without sharing an
+// offset store. It talks to the API server over HTTPS with the pod's service account.
+package kubelease
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+)
+
+// Service account files mounted into pods.
+const (
+	DefaultTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	DefaultNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+const (
+	annotationHostname    = "freader.loykin.github.io/hostname"
+	annotationPID         = "freader.loykin.github.io/pid"
+	microTimeLayout       = "2006-01-02T15:04:05.000000Z07:00"
+	maxConflictRetries    = 3
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// Config locates the Lease object and the API server. Only Name is required inside a
+// pod; the other fields default to the in-cluster service account.
+type Config struct {
+	Name string
+	// Namespace of the Lease; the pod's namespace when empty.
+	Namespace string
+	// Server is the API server URL; https://$KUBERNETES_SERVICE_HOST:$KUBERNETES_SERVICE_PORT
+	// when empty.
+	Server string
+	// TokenFile holds the bearer token, re-read for every request as projected tokens
+	// rotate; DefaultTokenFile when empty. A missing file sends no token.
+	TokenFile string
+	// CAFile verifies the API server; DefaultCAFile when empty. Ignored with Client.
+	CAFile string
+	// Client, if set, sends the requests instead of one built from CAFile.
+	Client *http.Client
+}
+
+// Leaser implements store.Leaser on a Lease object.
+type Leaser struct {
+	url       string // of the Lease object
+	collURL   string // of the namespace's leases
+	name      string
+	namespace string
+	tokenFile string
+	client    *http.Client
+	now       func() time.Time
+}
+
+var _ store.Leaser = (*Leaser)(nil)
+
+// New returns a Leaser for the Lease cfg.Name. The Lease is created on first acquisition.
+func New(cfg Config) (*Leaser, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("kubernetes lease name must be set")
+	}
+	if cfg.Namespace == "" {
+		b, err := os.ReadFile(DefaultNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes lease namespace not set and not running in a pod: %w", err)
+		}
+		cfg.Namespace = strings.TrimSpace(string(b))
+	}
+	if cfg.Server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("kubernetes API server not set and not running in a pod")
+		}
+		cfg.Server = "https://" + net.JoinHostPort(host, port)
+	}
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = DefaultTokenFile
+	}
+	client := cfg.Client
+	if client == nil {
+		if cfg.CAFile == "" {
+			cfg.CAFile = DefaultCAFile
+		}
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		client = &http.Client{Transport: transport, Timeout: defaultRequestTimeout}
+	}
+	coll := strings.TrimRight(cfg.Server, "/") + "/apis/coordination.k8s.io/v1/namespaces/" +
+		url.PathEscape(cfg.Namespace) + "/leases"
+	return &Leaser{
+		url:       coll + "/" + url.PathEscape(cfg.Name),
+		collURL:   coll,
+		name:      cfg.Name,
+		namespace: cfg.Namespace,
+		tokenFile: cfg.TokenFile,
+		client:    client,
+		now:       time.Now,
+	}, nil
+}
+
+// lease is the subset of coordination.k8s.io/v1 Lease used here.
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       *string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *string `json:"acquireTime,omitempty"`
+	RenewTime            *string `json:"renewTime,omitempty"`
+	LeaseTransitions     *int32  `json:"leaseTransitions,omitempty"`
+}
+
+func (l *lease) holder() string {
+	if l.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *l.Spec.HolderIdentity
+}
+
+func parseMicroTime(s *string) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339Nano, *s)
+	return t
+}
+
+// expires returns when the lease runs out; the zero time if it has no holder.
+func (l *lease) expires() time.Time {
+	if l.holder() == "" || l.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}
+	}
+	return parseMicroTime(l.Spec.RenewTime).Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second)
+}
+
+func (l *lease) toStore() store.Lease {
+	out := store.Lease{
+		InstanceID:  l.holder(),
+		Hostname:    l.Metadata.Annotations[annotationHostname],
+		AcquiredAt:  parseMicroTime(l.Spec.AcquireTime),
+		HeartbeatAt: parseMicroTime(l.Spec.RenewTime),
+		ExpiresAt:   l.expires(),
+	}
+	out.PID, _ = strconv.Atoi(l.Metadata.Annotations[annotationPID])
+	return out
+}
+
+// hold sets l to be held by holder until ttl from now.
+func (l *lease) hold(holder store.Lease, ttl time.Duration, now time.Time) {
+	ts := now.UTC().Format(microTimeLayout)
+	if l.holder() != holder.InstanceID {
+		transitions := int32(0)
+		if l.Spec.LeaseTransitions != nil {
+			transitions = *l.Spec.LeaseTransitions
+		}
+		if l.holder() != "" {
+			transitions++
+		}
+		l.Spec.LeaseTransitions = &transitions
+		l.Spec.AcquireTime = &ts
+		l.Spec.HolderIdentity = &holder.InstanceID
+		if l.Metadata.Annotations == nil {
+			l.Metadata.Annotations = map[string]string{}
+		}
+		l.Metadata.Annotations[annotationHostname] = holder.Hostname
+		l.Metadata.Annotations[annotationPID] = strconv.Itoa(holder.PID)
+	}
+	seconds := int32(min(math.Ceil(ttl.Seconds()), math.MaxInt32))
+	l.Spec.LeaseDurationSeconds = &seconds
+	l.Spec.RenewTime = &ts
+}
+
+// errConflict reports a write rejected because the Lease changed since it was read.
+var errConflict = errors.New("lease was modified concurrently")
+
+// errNotFound reports a write to a Lease that was deleted since it was read.
+var errNotFound = errors.New("lease does not exist")
+
+func (k *Leaser) do(method, u string, body *lease) (*lease, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token, err := os.ReadFile(k.tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return nil, errConflict
+	case resp.StatusCode == http.StatusNotFound && method == http.MethodGet:
+		return nil, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errNotFound
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("kubernetes API %s %s: %s: %s", method, u, resp.Status, bytes.TrimSpace(data))
+	}
+	var out lease
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid Lease from kubernetes API: %w", err)
+	}
+	return &out, nil
+}
+
+// get returns the Lease, or nil if it does not exist.
+func (k *Leaser) get() (*lease, error) {
+	l, err := k.do(http.MethodGet, k.url, nil)
+	return l, err
+}
+
+func (k *Leaser) AcquireLease(l store.Lease, ttl time.Duration) error {
+	for range maxConflictRetries {
+		cur, err := k.get()
+		if err != nil {
+			return fmt.Errorf("failed to acquire kubernetes lease: %w", err)
+		}
+		now := k.now()
+		if cur == nil {
+			cur = &lease{
+				APIVersion: "coordination.k8s.io/v1",
+				Kind:       "Lease",
+				Metadata:   leaseMetadata{Name: k.name, Namespace: k.namespace},
+			}
+			cur.hold(l, ttl, now)
+			_, err = k.do(http.MethodPost, k.collURL, cur)
+		} else {
+			if h := cur.holder(); h != "" && h != l.InstanceID && now.Before(cur.expires()) {
+				return fmt.Errorf("%w: %s", store.ErrLeaseHeld, cur.toStore())
+			}
+			cur.hold(l, ttl, now)
+			_, err = k.do(http.MethodPut, k.url, cur)
+		}
+		// Read the Lease again if it changed or was deleted since
+		if errors.Is(err, errConflict) || errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to acquire kubernetes lease: %w", err)
+		}
+		return nil
+	}
+	return store.ErrLeaseHeld
+}
+
+func (k *Leaser) RenewLease(instanceID string, ttl time.Duration) error {
+	for range maxConflictRetries {
+		cur, err := k.get()
+		if err != nil {
+			return fmt.Errorf("failed to renew kubernetes lease: %w", err)
+		}
+		if cur == nil {
+			return store.ErrLeaseLost
+		}
+		if cur.holder() != instanceID {
+			if cur.holder() == "" {
+				return store.ErrLeaseLost
+			}
+			return fmt.Errorf("%w: %s", store.ErrLeaseLost, cur.toStore())
+		}
+		cur.hold(store.Lease{InstanceID: instanceID}, ttl, k.now())
+		_, err = k.do(http.MethodPut, k.url, cur)
+		if errors.Is(err, errConflict) {
+			continue
+		}
+		if errors.Is(err, errNotFound) {
+			return store.ErrLeaseLost
+		}
+		if err != nil {
+			return fmt.Errorf("failed to renew kubernetes lease: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to renew kubernetes lease: %w", errConflict)
+}
+
+// ReleaseLease clears the holder, as client-go's leader election does, so a standby
+// takes over on its next attempt.
+func (k *Leaser) ReleaseLease(instanceID string) error {
+	cur, err := k.get()
+	if err != nil {
+		return fmt.Errorf("failed to release kubernetes lease: %w", err)
+	}
+	if cur == nil || cur.holder() != instanceID {
+		return nil
+	}
+	empty := ""
+	one := int32(1)
+	cur.Spec.HolderIdentity = &empty
+	cur.Spec.LeaseDurationSeconds = &one
+	if _, err := k.do(http.MethodPut, k.url, cur); err != nil && !errors.Is(err, errConflict) && !errors.Is(err, errNotFound) {
+		return fmt.Errorf("failed to release kubernetes lease: %w", err)
+	}
+	return nil
+}
+
+func (k *Leaser) CurrentLease() (store.Lease, bool, error) {
+	cur, err := k.get()
+	if err != nil {
+		return store.Lease{}, false, fmt.Errorf("failed to read kubernetes lease: %w", err)
+	}
+	if cur == nil || cur.holder() == "" {
+		return store.Lease{}, false, nil
+	}
+	return cur.toStore(), true, nil
+}
@@ -0,0 +1,173 @@
+package kubelease
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI serves one namespace's leases with resourceVersion conflict checks.
+// beforeWrite, if set, runs before each write is applied, with mu held.
+type fakeAPI struct {
+	mu          sync.Mutex
+	leases      map[string]*lease
+	version     int
+	tokens      []string
+	beforeWrite func()
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = append(f.tokens, r.Header.Get("Authorization"))
+	const prefix = "/apis/coordination.k8s.io/v1/namespaces/logging/leases"
+	name := filepath.Base(r.URL.Path)
+	switch {
+	case r.Method == http.MethodGet && filepath.Dir(r.URL.Path) == prefix:
+		l, ok := f.leases[name]
+		if !ok {
+			http.Error(w, `{"reason":"NotFound"}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(l)
+	case r.Method == http.MethodPost && r.URL.Path == prefix, r.Method == http.MethodPut && filepath.Dir(r.URL.Path) == prefix:
+		var l lease
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if f.beforeWrite != nil {
+			f.beforeWrite()
+		}
+		cur, exists := f.leases[l.Metadata.Name]
+		if r.Method == http.MethodPut && !exists {
+			http.Error(w, `{"reason":"NotFound"}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost && exists ||
+			r.Method == http.MethodPut && cur.Metadata.ResourceVersion != l.Metadata.ResourceVersion {
+			http.Error(w, `{"reason":"Conflict"}`, http.StatusConflict)
+			return
+		}
+		f.version++
+		l.Metadata.ResourceVersion = strconv.Itoa(f.version)
+		f.leases[l.Metadata.Name] = &l
+		_ = json.NewEncoder(w).Encode(&l)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func newTestLeaser(t *testing.T, api *fakeAPI, now *time.Time) *Leaser {
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	token := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(token, []byte("secret\n"), 0o600))
+	k, err := New(Config{Name: "freader", Namespace: "logging", Server: srv.URL, TokenFile: token, Client: srv.Client()})
+	require.NoError(t, err)
+	k.now = func() time.Time { return *now }
+	return k
+}
+
+func TestLeaser(t *testing.T) {
+	api := &fakeAPI{leases: map[string]*lease{}}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := newTestLeaser(t, api, &now)
+	b := newTestLeaser(t, api, &now)
+
+	_, ok, err := a.CurrentLease()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The first instance creates the Lease; the second is refused while it is renewed
+	require.NoError(t, a.AcquireLease(store.Lease{InstanceID: "a", Hostname: "node-1", PID: 7}, 15*time.Second))
+	err = b.AcquireLease(store.Lease{InstanceID: "b"}, 15*time.Second)
+	require.ErrorIs(t, err, store.ErrLeaseHeld)
+	assert.Contains(t, err.Error(), "instance a (host node-1, pid 7")
+
+	now = now.Add(10 * time.Second)
+	require.NoError(t, a.RenewLease("a", 15*time.Second))
+	now = now.Add(10 * time.Second)
+	require.ErrorIs(t, b.AcquireLease(store.Lease{InstanceID: "b"}, 15*time.Second), store.ErrLeaseHeld)
+
+	cur, ok, err := a.CurrentLease()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a", cur.InstanceID)
+	assert.Equal(t, 7, cur.PID)
+	assert.Equal(t, now.Add(-10*time.Second).Add(15*time.Second), cur.ExpiresAt)
+
+	// Once it expires the standby takes over and the old holder loses it
+	now = now.Add(10 * time.Second)
+	require.NoError(t, b.AcquireLease(store.Lease{InstanceID: "b", Hostname: "node-2", PID: 8}, 15*time.Second))
+	err = a.RenewLease("a", 15*time.Second)
+	require.ErrorIs(t, err, store.ErrLeaseLost)
+	assert.Contains(t, err.Error(), "instance b")
+	assert.Equal(t, int32(1), *api.leases["freader"].Spec.LeaseTransitions)
+
+	// Releasing clears the holder so the other instance takes over at once
+	require.NoError(t, a.ReleaseLease("a"), "releasing a lease held by another instance is a no-op")
+	require.NoError(t, b.ReleaseLease("b"))
+	_, ok, err = a.CurrentLease()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, a.AcquireLease(store.Lease{InstanceID: "a"}, 15*time.Second))
+
+	assert.Equal(t, "Bearer secret", api.tokens[0])
+}
+
+func TestLeaser_Conflict(t *testing.T) {
+	api := &fakeAPI{leases: map[string]*lease{}}
+	now := time.Now()
+	a := newTestLeaser(t, api, &now)
+	require.NoError(t, a.AcquireLease(store.Lease{InstanceID: "a"}, time.Minute))
+
+	// A write based on a stale read is rejected by the API server and retried
+	stale, err := a.get()
+	require.NoError(t, err)
+	require.NoError(t, a.RenewLease("a", time.Minute))
+	stale.hold(store.Lease{InstanceID: "x"}, time.Minute, now)
+	_, err = a.do(http.MethodPut, a.url, stale)
+	assert.ErrorIs(t, err, errConflict)
+	require.NoError(t, a.RenewLease("a", time.Minute))
+}
+
+func TestLeaser_DeletedBeforeWrite(t *testing.T) {
+	api := &fakeAPI{leases: map[string]*lease{}}
+	now := time.Now()
+	a := newTestLeaser(t, api, &now)
+	require.NoError(t, a.AcquireLease(store.Lease{InstanceID: "a"}, time.Minute))
+
+	// The Lease is deleted between the GET and the PUT of a renewal: it is lost
+	api.mu.Lock()
+	api.beforeWrite = func() { delete(api.leases, "freader") }
+	api.mu.Unlock()
+	require.ErrorIs(t, a.RenewLease("a", time.Minute), store.ErrLeaseLost)
+
+	// An acquisition reads it again and creates it
+	require.NoError(t, a.AcquireLease(store.Lease{InstanceID: "a"}, time.Minute))
+	api.mu.Lock()
+	api.beforeWrite = nil
+	api.mu.Unlock()
+	cur, ok, err := a.CurrentLease()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a", cur.InstanceID)
+	require.NoError(t, a.ReleaseLease("a"))
+}
+
+func TestNew_RequiresName(t *testing.T) {
+	_, err := New(Config{Namespace: "logging", Server: "https://127.0.0.1:6443"})
+	assert.Error(t, err)
+}
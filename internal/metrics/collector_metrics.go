@@ -6,44 +6,120 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var (
-	linesTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Namespace: "freader",
-		Name:      "lines_total",
-		Help:      "Total number of log lines processed.",
-	})
-	bytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Namespace: "freader",
-		Name:      "bytes_total",
-		Help:      "Total number of bytes emitted from tailed files (approximate, excludes separators).",
-	})
-	errorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Namespace: "freader",
-		Name:      "errors_total",
-		Help:      "Total number of read errors encountered while tailing files.",
-	})
-	activeFiles = prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: "freader",
-		Name:      "active_files",
-		Help:      "Current number of active files being tailed.",
-	})
-	filesSeenTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Namespace: "freader",
-		Name:      "files_seen_total",
-		Help:      "Total number of files discovered by the watcher.",
-	})
-	restoredOffsetsTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Namespace: "freader",
-		Name:      "restored_offsets_total",
-		Help:      "Total number of files for which an offset was restored from the store upon discovery.",
-	})
-)
+// Set holds one group of collector metrics. The package-level functions update a shared
+// default Set; collectors embedded side by side in one process can each be given their
+// own Set, registered with constant labels (e.g. instance, pipeline) that tell them apart.
+type Set struct {
+	linesTotal           prometheus.Counter
+	bytesTotal           prometheus.Counter
+	errorsTotal          prometheus.Counter
+	activeFiles          prometheus.Gauge
+	filesSeenTotal       prometheus.Counter
+	unreadableFiles      prometheus.Gauge
+	lockedTotal          prometheus.Counter
+	deletedFiles         prometheus.Gauge
+	offsetMismatches     prometheus.Counter
+	restoredOffsetsTotal prometheus.Counter
+	callbackStallsTotal  prometheus.Counter
+	callbackSkipsTotal   prometheus.Counter
+	leader               prometheus.Gauge
+	multilineDepth       *prometheus.GaugeVec
+}
 
-// Register registers all freader metrics to the provided Prometheus registerer.
-// It is safe to call multiple times; AlreadyRegisteredError will be ignored.
-func Register(r prometheus.Registerer) error {
+// NewSet returns a Set whose metrics are not registered anywhere yet.
+func NewSet() *Set {
+	return &Set{
+		linesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "lines_total",
+			Help:      "Total number of log lines processed.",
+		}),
+		bytesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "bytes_total",
+			Help:      "Total number of bytes emitted from tailed files (approximate, excludes separators).",
+		}),
+		errorsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "errors_total",
+			Help:      "Total number of read errors encountered while tailing files.",
+		}),
+		activeFiles: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "freader",
+			Name:      "active_files",
+			Help:      "Current number of active files being tailed.",
+		}),
+		filesSeenTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "files_seen_total",
+			Help:      "Total number of files discovered by the watcher.",
+		}),
+		unreadableFiles: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "freader",
+			Name:      "unreadable_files",
+			Help:      "Current number of files and directories failing with permission errors.",
+		}),
+		lockedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "locked_total",
+			Help:      "Total number of scans and reads that found a file locked by another process (Windows sharing or lock violations).",
+		}),
+		deletedFiles: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "freader",
+			Name:      "deleted_open_files",
+			Help:      "Current number of deleted files still read through a file kept open (HoldDeleted), each taking disk space until released.",
+		}),
+		offsetMismatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "offset_mismatches_total",
+			Help:      "Total number of stored offsets that failed verification on resume (past the end of the file or fingerprint changed).",
+		}),
+		restoredOffsetsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "restored_offsets_total",
+			Help:      "Total number of files for which an offset was restored from the store upon discovery.",
+		}),
+		callbackStallsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "callback_stalls_total",
+			Help:      "Total number of record callbacks that ran longer than the callback timeout.",
+		}),
+		callbackSkipsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "freader",
+			Name:      "callback_skipped_records_total",
+			Help:      "Total number of records dropped instead of waiting for a stalled callback.",
+		}),
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "freader",
+			Name:      "leader",
+			Help:      "1 while this collector holds the lease and reads, 0 while it stands by (standby mode only).",
+		}),
+		multilineDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "freader",
+			Name:      "multiline_buffered_lines",
+			Help:      "Current number of lines held in the multiline record being assembled for a file, by path.",
+		}, []string{"path"}),
+	}
+}
+
+// defaultSet backs the package-level functions.
+var defaultSet = NewSet()
+
+// Default returns the Set updated by the package-level functions and by collectors
+// without a Set of their own.
+func Default() *Set { return defaultSet }
+
+// Register registers the metrics of s to r, adding constLabels to every metric. Sets
+// sharing a registerer must use the same label names with different values. It is safe
+// to call multiple times; AlreadyRegisteredError will be ignored.
+func (s *Set) Register(r prometheus.Registerer, constLabels prometheus.Labels) error {
+	if len(constLabels) > 0 {
+		r = prometheus.WrapRegistererWith(constLabels, r)
+	}
 	collectors := []prometheus.Collector{
-		linesTotal, bytesTotal, errorsTotal, activeFiles, filesSeenTotal, restoredOffsetsTotal,
+		s.linesTotal, s.bytesTotal, s.errorsTotal, s.activeFiles, s.filesSeenTotal, s.restoredOffsetsTotal, s.unreadableFiles,
+		s.lockedTotal, s.deletedFiles, s.offsetMismatches, s.callbackStallsTotal, s.callbackSkipsTotal, s.leader,
+		s.multilineDepth,
 	}
 	for _, c := range collectors {
 		if err := r.Register(c); err != nil {
@@ -58,30 +134,126 @@ func Register(r prometheus.Registerer) error {
 }
 
 // IncLines increments the processed lines counter by n.
-func IncLines(n int) {
+func (s *Set) IncLines(n int) {
 	if n > 0 {
-		linesTotal.Add(float64(n))
+		s.linesTotal.Add(float64(n))
 	}
 }
 
 // AddBytes adds n to the bytes counter.
-func AddBytes(n int) {
+func (s *Set) AddBytes(n int) {
 	if n > 0 {
-		bytesTotal.Add(float64(n))
+		s.bytesTotal.Add(float64(n))
 	}
 }
 
 // IncReadErrors increments the read errors counter by 1.
-func IncReadErrors() { errorsTotal.Inc() }
+func (s *Set) IncReadErrors() { s.errorsTotal.Inc() }
 
 // IncFilesSeen increments the files seen counter by 1.
-func IncFilesSeen() { filesSeenTotal.Inc() }
+func (s *Set) IncFilesSeen() { s.filesSeenTotal.Inc() }
 
 // IncActiveFiles increments the active files gauge by 1.
-func IncActiveFiles() { activeFiles.Inc() }
+func (s *Set) IncActiveFiles() { s.activeFiles.Inc() }
 
 // DecActiveFiles decrements the active files gauge by 1.
-func DecActiveFiles() { activeFiles.Dec() }
+func (s *Set) DecActiveFiles() { s.activeFiles.Dec() }
 
 // IncRestoredOffsets increments the restored offsets counter by 1.
-func IncRestoredOffsets() { restoredOffsetsTotal.Inc() }
+func (s *Set) IncRestoredOffsets() { s.restoredOffsetsTotal.Inc() }
+
+// SetUnreadableFiles sets the unreadable files gauge to n.
+func (s *Set) SetUnreadableFiles(n int) { s.unreadableFiles.Set(float64(n)) }
+
+// IncOffsetMismatches increments the offset mismatches counter by 1.
+func (s *Set) IncOffsetMismatches() { s.offsetMismatches.Inc() }
+
+// SetDeletedFiles sets the deleted open files gauge to n.
+func (s *Set) SetDeletedFiles(n int) { s.deletedFiles.Set(float64(n)) }
+
+// IncLocked increments the locked files counter by 1.
+func (s *Set) IncLocked() { s.lockedTotal.Inc() }
+
+// IncCallbackStalls increments the stalled callbacks counter by 1.
+func (s *Set) IncCallbackStalls() { s.callbackStallsTotal.Inc() }
+
+// AddCallbackSkips adds n to the records skipped for a stalled callback.
+func (s *Set) AddCallbackSkips(n int) {
+	if n > 0 {
+		s.callbackSkipsTotal.Add(float64(n))
+	}
+}
+
+// SetLeader sets the leader gauge to 1 when leader, else 0.
+func (s *Set) SetLeader(leader bool) {
+	if leader {
+		s.leader.Set(1)
+	} else {
+		s.leader.Set(0)
+	}
+}
+
+// SetMultilineDepth sets the multiline buffered lines gauge of path to n.
+func (s *Set) SetMultilineDepth(path string, n int) {
+	s.multilineDepth.WithLabelValues(path).Set(float64(n))
+}
+
+// DeleteMultilineDepth removes the multiline buffered lines gauge of path, once the
+// file is no longer read.
+func (s *Set) DeleteMultilineDepth(path string) {
+	s.multilineDepth.DeleteLabelValues(path)
+}
+
+// Register registers the default metrics to the provided Prometheus registerer.
+// It is safe to call multiple times; AlreadyRegisteredError will be ignored.
+func Register(r prometheus.Registerer) error {
+	return defaultSet.Register(r, nil)
+}
+
+// IncLines increments the processed lines counter by n.
+func IncLines(n int) { defaultSet.IncLines(n) }
+
+// AddBytes adds n to the bytes counter.
+func AddBytes(n int) { defaultSet.AddBytes(n) }
+
+// IncReadErrors increments the read errors counter by 1.
+func IncReadErrors() { defaultSet.IncReadErrors() }
+
+// IncFilesSeen increments the files seen counter by 1.
+func IncFilesSeen() { defaultSet.IncFilesSeen() }
+
+// IncActiveFiles increments the active files gauge by 1.
+func IncActiveFiles() { defaultSet.IncActiveFiles() }
+
+// DecActiveFiles decrements the active files gauge by 1.
+func DecActiveFiles() { defaultSet.DecActiveFiles() }
+
+// IncRestoredOffsets increments the restored offsets counter by 1.
+func IncRestoredOffsets() { defaultSet.IncRestoredOffsets() }
+
+// SetUnreadableFiles sets the unreadable files gauge to n.
+func SetUnreadableFiles(n int) { defaultSet.SetUnreadableFiles(n) }
+
+// IncOffsetMismatches increments the offset mismatches counter by 1.
+func IncOffsetMismatches() { defaultSet.IncOffsetMismatches() }
+
+// SetDeletedFiles sets the deleted open files gauge to n.
+func SetDeletedFiles(n int) { defaultSet.SetDeletedFiles(n) }
+
+// IncLocked increments the locked files counter by 1.
+func IncLocked() { defaultSet.IncLocked() }
+
+// IncCallbackStalls increments the stalled callbacks counter by 1.
+func IncCallbackStalls() { defaultSet.IncCallbackStalls() }
+
+// AddCallbackSkips adds n to the records skipped for a stalled callback.
+func AddCallbackSkips(n int) { defaultSet.AddCallbackSkips(n) }
+
+// SetLeader sets the leader gauge to 1 when leader, else 0.
+func SetLeader(leader bool) { defaultSet.SetLeader(leader) }
+
+// SetMultilineDepth sets the multiline buffered lines gauge of path to n.
+func SetMultilineDepth(path string, n int) { defaultSet.SetMultilineDepth(path, n) }
+
+// DeleteMultilineDepth removes the multiline buffered lines gauge of path.
+func DeleteMultilineDepth(path string) { defaultSet.DeleteMultilineDepth(path) }
@@ -49,6 +49,10 @@ func TestRegisterAndCounters(t *testing.T) {
 	baseFilesSeen := getMetric(mfs, "freader_files_seen_total")
 	baseActive := getMetric(mfs, "freader_active_files")
 	baseRestored := getMetric(mfs, "freader_restored_offsets_total")
+	baseLocked := getMetric(mfs, "freader_locked_total")
+	baseMismatches := getMetric(mfs, "freader_offset_mismatches_total")
+	baseStalls := getMetric(mfs, "freader_callback_stalls_total")
+	baseSkips := getMetric(mfs, "freader_callback_skipped_records_total")
 
 	// Perform updates
 	IncLines(3)
@@ -60,6 +64,14 @@ func TestRegisterAndCounters(t *testing.T) {
 	IncActiveFiles()
 	DecActiveFiles()
 	IncRestoredOffsets()
+	SetUnreadableFiles(2)
+	IncLocked()
+	SetDeletedFiles(3)
+	IncOffsetMismatches()
+	IncCallbackStalls()
+	AddCallbackSkips(4)
+	AddCallbackSkips(0) // no-op
+	SetLeader(true)
 
 	mfs2, err := reg.Gather()
 	if err != nil {
@@ -84,4 +96,101 @@ func TestRegisterAndCounters(t *testing.T) {
 	if got := getMetric(mfs2, "freader_restored_offsets_total") - baseRestored; got != 1 {
 		t.Fatalf("restored_offsets_total delta = %v, want 1", got)
 	}
+	if got := getMetric(mfs2, "freader_unreadable_files"); got != 2 {
+		t.Fatalf("unreadable_files = %v, want 2", got)
+	}
+	if got := getMetric(mfs2, "freader_deleted_open_files"); got != 3 {
+		t.Fatalf("deleted_open_files = %v, want 3", got)
+	}
+	if got := getMetric(mfs2, "freader_offset_mismatches_total") - baseMismatches; got != 1 {
+		t.Fatalf("offset_mismatches_total delta = %v, want 1", got)
+	}
+	if got := getMetric(mfs2, "freader_locked_total") - baseLocked; got != 1 {
+		t.Fatalf("locked_total delta = %v, want 1", got)
+	}
+	if got := getMetric(mfs2, "freader_callback_stalls_total") - baseStalls; got != 1 {
+		t.Fatalf("callback_stalls_total delta = %v, want 1", got)
+	}
+	if got := getMetric(mfs2, "freader_callback_skipped_records_total") - baseSkips; got != 4 {
+		t.Fatalf("callback_skipped_records_total delta = %v, want 4", got)
+	}
+	if got := getMetric(mfs2, "freader_leader"); got != 1 {
+		t.Fatalf("leader = %v, want 1", got)
+	}
+	SetUnreadableFiles(0)
+	SetDeletedFiles(0)
+	SetLeader(false)
+}
+
+func TestSet_RegisterWithConstLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	a, b := NewSet(), NewSet()
+	if err := a.Register(reg, prometheus.Labels{"pipeline": "a"}); err != nil {
+		t.Fatalf("register a failed: %v", err)
+	}
+	if err := b.Register(reg, prometheus.Labels{"pipeline": "b"}); err != nil {
+		t.Fatalf("register b failed: %v", err)
+	}
+	if err := a.Register(reg, prometheus.Labels{"pipeline": "a"}); err != nil {
+		t.Fatalf("registering a again should be ignored: %v", err)
+	}
+	if err := NewSet().Register(reg, nil); err == nil {
+		t.Fatal("an unlabelled set should collide with the labelled ones")
+	}
+
+	a.IncLines(2)
+	b.IncLines(5)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+	got := map[string]float64{}
+	for _, mf := range mfs {
+		if mf.GetName() != "freader_lines_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "pipeline" {
+					got[lp.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	if got["a"] != 2 || got["b"] != 5 {
+		t.Fatalf("lines_total by pipeline = %v, want a=2 b=5", got)
+	}
+}
+
+func TestSet_MultilineDepth(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s := NewSet()
+	if err := s.Register(reg, nil); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	s.SetMultilineDepth("/var/log/a.log", 3)
+	s.SetMultilineDepth("/var/log/b.log", 1)
+	s.DeleteMultilineDepth("/var/log/b.log")
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+	got := map[string]float64{}
+	for _, mf := range mfs {
+		if mf.GetName() != "freader_multiline_buffered_lines" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "path" {
+					got[lp.GetValue()] = m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	if len(got) != 1 || got["/var/log/a.log"] != 3 {
+		t.Fatalf("multiline_buffered_lines by path = %v, want only /var/log/a.log=3", got)
+	}
 }
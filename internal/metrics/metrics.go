@@ -2,9 +2,15 @@ package metrics
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -13,26 +19,123 @@ import (
 // Call Start to run the server and Stop to gracefully shut it down.
 type Server struct {
 	server *http.Server
+	addr   net.Addr
+}
+
+// ServerOptions protects the metrics endpoint and selects what it serves.
+type ServerOptions struct {
+	// TLSCertFile and TLSKeyFile, when both set, serve HTTPS with this PEM key pair.
+	TLSCertFile string
+	TLSKeyFile  string
+	// BasicAuthUser and BasicAuthPassword, when the user is set, require HTTP basic auth.
+	BasicAuthUser     string
+	BasicAuthPassword string
+	// BearerToken, when set, requires an "Authorization: Bearer <token>" header. If both
+	// basic auth and a bearer token are configured, either is accepted.
+	BearerToken string
+	// Gatherer is served at /metrics; nil uses the default Prometheus registry.
+	Gatherer prometheus.Gatherer
+	// Handlers are served next to /metrics, keyed by path (e.g. "/debug/vars"), behind
+	// the same TLS and authentication.
+	Handlers map[string]http.Handler
+}
+
+// Validate checks that the TLS and basic-auth settings are complete.
+func (o ServerOptions) Validate() error {
+	if (o.TLSCertFile == "") != (o.TLSKeyFile == "") {
+		return errors.New("metrics TLS needs both a certificate and a key file")
+	}
+	if o.BasicAuthUser != "" && o.BasicAuthPassword == "" {
+		return errors.New("metrics basic auth needs a password")
+	}
+	return nil
 }
 
 // Start creates and starts a metrics HTTP server on the given address.
 // The default Prometheus registry is exposed at /metrics.
 // It returns a Server and a nil error on success.
 func Start(addr string) (*Server, error) {
+	return StartWithOptions(addr, ServerOptions{})
+}
+
+// StartWithOptions is like Start but serves HTTPS and requires authentication as
+// configured in opts. The address is bound and the key pair loaded before it returns,
+// so configuration errors are reported to the caller.
+func StartWithOptions(addr string, opts ServerOptions) (*Server, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	handler := promhttp.Handler()
+	if opts.Gatherer != nil {
+		handler = promhttp.HandlerFor(opts.Gatherer, promhttp.HandlerOpts{})
+	}
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", opts.authenticate(handler))
+	for path, h := range opts.Handlers {
+		mux.Handle(path, opts.authenticate(h))
+	}
 
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if opts.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load metrics TLS key pair: %w", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if srv.TLSConfig != nil {
+		ln = tls.NewListener(ln, srv.TLSConfig)
 	}
 
-	// Start in a goroutine; caller controls lifetime via Stop.
+	// Serve in a goroutine; caller controls lifetime via Stop.
 	go func() {
-		_ = srv.ListenAndServe()
+		_ = srv.Serve(ln)
 	}()
 
-	return &Server{server: srv}, nil
+	return &Server{server: srv, addr: ln.Addr()}, nil
+}
+
+// Addr returns the address the server listens on, e.g. to find the port chosen for ":0".
+func (s *Server) Addr() net.Addr {
+	return s.addr
+}
+
+// authenticate wraps next with the configured basic-auth and bearer-token checks.
+func (o ServerOptions) authenticate(next http.Handler) http.Handler {
+	if o.BasicAuthUser == "" && o.BearerToken == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.BearerToken != "" {
+			const prefix = "Bearer "
+			if h := r.Header.Get("Authorization"); len(h) > len(prefix) && h[:len(prefix)] == prefix &&
+				subtle.ConstantTimeCompare([]byte(h[len(prefix):]), []byte(o.BearerToken)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if o.BasicAuthUser != "" {
+			if user, pass, ok := r.BasicAuth(); ok &&
+				subtle.ConstantTimeCompare([]byte(user), []byte(o.BasicAuthUser)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(pass), []byte(o.BasicAuthPassword)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="freader metrics"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="freader metrics"`)
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
 }
 
 // Stop gracefully shuts down the metrics server with a timeout.
@@ -2,9 +2,20 @@ package metrics
 
 import (
 	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math/big"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -84,3 +95,150 @@ func TestStopNilServer(t *testing.T) {
 		t.Fatalf("Stop on nil server returned error: %v", err)
 	}
 }
+
+// writeServerCert writes a self-signed certificate for 127.0.0.1 and its key to dir.
+func writeServerCert(t *testing.T, dir string) (certPath, keyPath string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "freader-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse cert: %v", err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certPath, keyPath, pool
+}
+
+func TestServerTLSAndAuth(t *testing.T) {
+	certPath, keyPath, pool := writeServerCert(t, t.TempDir())
+	reg := prometheus.NewRegistry()
+	set := NewSet()
+	if err := set.Register(reg, nil); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	s, err := StartWithOptions("127.0.0.1:0", ServerOptions{
+		TLSCertFile:       certPath,
+		TLSKeyFile:        keyPath,
+		BasicAuthUser:     "prom",
+		BasicAuthPassword: "secret",
+		BearerToken:       "token",
+		Gatherer:          reg,
+	})
+	if err != nil {
+		t.Fatalf("StartWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Stop() })
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	url := fmt.Sprintf("https://%s/metrics", s.Addr())
+	get := func(auth func(*http.Request)) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		auth(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := get(func(*http.Request) {}); got != http.StatusUnauthorized {
+		t.Fatalf("without credentials: status %d, want 401", got)
+	}
+	if got := get(func(r *http.Request) { r.SetBasicAuth("prom", "wrong") }); got != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d, want 401", got)
+	}
+	if got := get(func(r *http.Request) { r.SetBasicAuth("prom", "secret") }); got != http.StatusOK {
+		t.Fatalf("basic auth: status %d, want 200", got)
+	}
+	if got := get(func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }); got != http.StatusOK {
+		t.Fatalf("bearer token: status %d, want 200", got)
+	}
+
+	if resp, err := http.Get(fmt.Sprintf("http://%s/metrics", s.Addr())); err == nil {
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Fatal("plaintext request should not be served")
+		}
+	}
+}
+
+func TestServerOptionsValidate(t *testing.T) {
+	if _, err := StartWithOptions("127.0.0.1:0", ServerOptions{TLSCertFile: "cert.pem"}); err == nil {
+		t.Fatal("expected error for a certificate without key")
+	}
+	if _, err := StartWithOptions("127.0.0.1:0", ServerOptions{BasicAuthUser: "prom"}); err == nil {
+		t.Fatal("expected error for basic auth without password")
+	}
+	if _, err := StartWithOptions("127.0.0.1:0", ServerOptions{TLSCertFile: "missing.pem", TLSKeyFile: "missing-key.pem"}); err == nil {
+		t.Fatal("expected error for missing key pair files")
+	}
+}
+
+func TestServerExtraHandlers(t *testing.T) {
+	s, err := StartWithOptions("127.0.0.1:0", ServerOptions{
+		BearerToken: "token",
+		Handlers: map[string]http.Handler{
+			"/debug/test": http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			}),
+		},
+	})
+	if err != nil {
+		t.Fatalf("StartWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Stop() })
+
+	url := fmt.Sprintf("http://%s/debug/test", s.Addr())
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("without token: status %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("with token: status %d body %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLeaseHeld is returned by AcquireLease while another instance holds an unexpired lease.
+var ErrLeaseHeld = errors.New("offset store is in use by another instance")
+
+// ErrLeaseLost is returned once another instance has taken over the lease; the store
+// then refuses Save and Delete so the new holder's offsets are not overwritten.
+var ErrLeaseLost = errors.New("offset store lease was taken over by another instance")
+
+// leaseName is the key of the single lease row guarding a database.
+const leaseName = "collector"
+
+// Lease records which process owns a store. Times are taken from the holder's clock.
+type Lease struct {
+	InstanceID  string
+	Hostname    string
+	PID         int
+	AcquiredAt  time.Time
+	HeartbeatAt time.Time
+	ExpiresAt   time.Time
+}
+
+// String describes the holder for error and log messages.
+func (l Lease) String() string {
+	return fmt.Sprintf("instance %s (host %s, pid %d, last heartbeat %s)",
+		l.InstanceID, l.Hostname, l.PID, l.HeartbeatAt.Format(time.RFC3339))
+}
+
+// Leaser is implemented by stores that can guard against being written by several
+// processes at once. A holder acquires the lease, renews it well within its TTL and
+// releases it on shutdown; a lease that is not renewed expires and can be taken over.
+type Leaser interface {
+	// AcquireLease takes the lease for l.InstanceID until ttl from now. It fails with an
+	// error wrapping ErrLeaseHeld if another instance holds an unexpired lease.
+	AcquireLease(l Lease, ttl time.Duration) error
+	// RenewLease extends the lease held by instanceID. It fails with an error wrapping
+	// ErrLeaseLost if another instance holds it now.
+	RenewLease(instanceID string, ttl time.Duration) error
+	// ReleaseLease gives up the lease if instanceID still holds it.
+	ReleaseLease(instanceID string) error
+	// CurrentLease returns the recorded lease, which may have expired.
+	CurrentLease() (Lease, bool, error)
+}
+
+func (s *sqliteStore) AcquireLease(l Lease, ttl time.Duration) error {
+	now := time.Now()
+	res, err := s.execWithRetry(
+		`INSERT INTO instance_lease (name, instance_id, hostname, pid, acquired_at, heartbeat_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET
+		 instance_id = excluded.instance_id,
+		 hostname = excluded.hostname,
+		 pid = excluded.pid,
+		 acquired_at = excluded.acquired_at,
+		 heartbeat_at = excluded.heartbeat_at,
+		 expires_at = excluded.expires_at
+		 WHERE instance_lease.instance_id = excluded.instance_id OR instance_lease.expires_at <= ?`,
+		leaseName, l.InstanceID, l.Hostname, l.PID, now.UnixMilli(), now.UnixMilli(), now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return wrapErr("failed to acquire offset store lease", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return wrapErr("failed to acquire offset store lease", err)
+	} else if n == 0 {
+		holder, ok, err := s.CurrentLease()
+		if err != nil || !ok {
+			return ErrLeaseHeld
+		}
+		return fmt.Errorf("%w: %s", ErrLeaseHeld, holder)
+	}
+	s.leaseLost.Store(false)
+	return nil
+}
+
+func (s *sqliteStore) RenewLease(instanceID string, ttl time.Duration) error {
+	now := time.Now()
+	res, err := s.execWithRetry(
+		`UPDATE instance_lease SET heartbeat_at = ?, expires_at = ? WHERE name = ? AND instance_id = ?`,
+		now.UnixMilli(), now.Add(ttl).UnixMilli(), leaseName, instanceID)
+	if err != nil {
+		return wrapErr("failed to renew offset store lease", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return wrapErr("failed to renew offset store lease", err)
+	} else if n == 0 {
+		s.leaseLost.Store(true)
+		if holder, ok, err := s.CurrentLease(); err == nil && ok {
+			return fmt.Errorf("%w: %s", ErrLeaseLost, holder)
+		}
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *sqliteStore) ReleaseLease(instanceID string) error {
+	if _, err := s.execWithRetry(
+		`DELETE FROM instance_lease WHERE name = ? AND instance_id = ?`,
+		leaseName, instanceID); err != nil {
+		return wrapErr("failed to release offset store lease", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) CurrentLease() (Lease, bool, error) {
+	var (
+		l                            Lease
+		acquired, heartbeat, expires int64
+	)
+	err := s.db.QueryRow(
+		`SELECT instance_id, hostname, pid, acquired_at, heartbeat_at, expires_at
+		 FROM instance_lease WHERE name = ?`, leaseName).
+		Scan(&l.InstanceID, &l.Hostname, &l.PID, &acquired, &heartbeat, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Lease{}, false, nil
+	}
+	if err != nil {
+		return Lease{}, false, wrapErr("failed to read offset store lease", err)
+	}
+	l.AcquiredAt = time.UnixMilli(acquired)
+	l.HeartbeatAt = time.UnixMilli(heartbeat)
+	l.ExpiresAt = time.UnixMilli(expires)
+	return l, true, nil
+}
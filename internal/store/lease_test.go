@@ -0,0 +1,73 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore_Lease(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	a, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = a.Close() }()
+	b, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = b.Close() }()
+	la, lb := a.(Leaser), b.(Leaser)
+
+	_, ok, err := la.CurrentLease()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, la.AcquireLease(Lease{InstanceID: "a", Hostname: "host-a", PID: 1}, time.Minute))
+	require.NoError(t, la.AcquireLease(Lease{InstanceID: "a", Hostname: "host-a", PID: 1}, time.Minute), "re-acquiring an own lease succeeds")
+
+	err = lb.AcquireLease(Lease{InstanceID: "b", Hostname: "host-b", PID: 2}, time.Minute)
+	require.ErrorIs(t, err, ErrLeaseHeld)
+	assert.Contains(t, err.Error(), "instance a (host host-a, pid 1")
+
+	held, ok, err := lb.CurrentLease()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a", held.InstanceID)
+	assert.True(t, held.ExpiresAt.After(time.Now()))
+
+	require.NoError(t, la.RenewLease("a", time.Minute))
+	require.NoError(t, la.ReleaseLease("a"))
+	require.NoError(t, lb.AcquireLease(Lease{InstanceID: "b", Hostname: "host-b", PID: 2}, time.Minute))
+	require.NoError(t, lb.ReleaseLease("b"))
+}
+
+func TestSQLiteStore_LeaseTakeoverAfterExpiry(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	stale, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = stale.Close() }()
+	fresh, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = fresh.Close() }()
+
+	require.NoError(t, stale.(Leaser).AcquireLease(Lease{InstanceID: "stale"}, 10*time.Millisecond))
+	require.NoError(t, stale.Save("id", "checksum", "/var/log/app.log", 10))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, fresh.(Leaser).AcquireLease(Lease{InstanceID: "fresh"}, time.Minute))
+	require.NoError(t, fresh.Save("id", "checksum", "/var/log/app.log", 20))
+
+	err = stale.(Leaser).RenewLease("stale", time.Minute)
+	require.ErrorIs(t, err, ErrLeaseLost)
+	assert.Contains(t, err.Error(), "instance fresh")
+	assert.ErrorIs(t, stale.Save("id", "checksum", "/var/log/app.log", 11), ErrLeaseLost)
+	assert.ErrorIs(t, stale.Delete("id", "checksum"), ErrLeaseLost)
+	require.NoError(t, stale.(Leaser).ReleaseLease("stale"), "releasing a lost lease leaves the new holder alone")
+
+	offset, _, err := fresh.Load("id", "checksum")
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), offset)
+	_, ok, err := fresh.(Leaser).CurrentLease()
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Maintainer is implemented by stores backed by a database file that benefit from
+// periodic housekeeping, such as the SQLite store.
+type Maintainer interface {
+	// Checkpoint copies the write-ahead log into the database and truncates it.
+	Checkpoint() error
+	// Vacuum rebuilds the database file to release the space of deleted rows.
+	Vacuum() error
+	// IntegrityCheck verifies the database structure; failures wrap ErrStoreCorrupt.
+	IntegrityCheck() error
+}
+
+// Options configures NewSQLiteStoreWithOptions.
+type Options struct {
+	// Logger receives migration and recovery messages; slog.Default() when nil.
+	Logger *slog.Logger
+	// RebuildIfCorrupt recovers from a corrupt database (one that cannot be opened or
+	// fails the integrity check) instead of returning ErrStoreCorrupt: the file is moved
+	// aside as <path>.corrupt-<timestamp>, a new database is created and the offsets
+	// that can still be read from the old one are copied over. Files whose offsets are
+	// lost are read again according to the collector's settings.
+	RebuildIfCorrupt bool
+}
+
+// NewSQLiteStoreWithOptions opens the SQLite store at dbPath like NewSQLiteStore and
+// verifies its integrity, rebuilding a corrupt database when opts.RebuildIfCorrupt is set.
+func NewSQLiteStoreWithOptions(dbPath string, opts Options) (Store, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s, err := NewSQLiteStoreWithLogger(dbPath, logger)
+	if err == nil {
+		if err = s.(Maintainer).IntegrityCheck(); err != nil {
+			_ = s.Close()
+			s = nil
+		}
+	}
+	if err == nil || !opts.RebuildIfCorrupt || !errors.Is(err, ErrStoreCorrupt) {
+		return s, err
+	}
+
+	logger.Error("offset store is corrupt, rebuilding", "path", dbPath, "error", err)
+	entries := salvageEntries(dbPath)
+	aside := fmt.Sprintf("%s.corrupt-%s", dbPath, time.Now().UTC().Format("20060102T150405Z"))
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(dbPath+suffix, aside+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to move corrupt offset store aside: %w", err)
+		}
+	}
+	s, err = NewSQLiteStoreWithLogger(dbPath, logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if err := s.Save(e.ID, e.Strategy, e.Path, e.Offset); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	logger.Warn("offset store rebuilt", "path", dbPath, "corrupt_copy", aside, "recovered_offsets", len(entries))
+	return s, nil
+}
+
+// salvageEntries reads whatever offsets can still be read from a corrupt database.
+func salvageEntries(dbPath string) []Entry {
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query(`SELECT id, strategy, path, offset FROM offsets`)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = rows.Close() }()
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Strategy, &e.Path, &e.Offset); err != nil {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (s *sqliteStore) Checkpoint() error {
+	if _, err := s.execWithRetry(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return wrapErr("failed to checkpoint offset store", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Vacuum() error {
+	if _, err := s.execWithRetry(`VACUUM`); err != nil {
+		return wrapErr("failed to vacuum offset store", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) IntegrityCheck() error {
+	rows, err := s.db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return wrapErr("failed to check offset store integrity", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return wrapErr("failed to check offset store integrity", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return wrapErr("failed to check offset store integrity", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("offset store integrity check failed: %w: %s", ErrStoreCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
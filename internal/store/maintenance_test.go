@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore_Maintenance(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	st, err := NewSQLiteStoreWithOptions(dbPath, Options{})
+	require.NoError(t, err)
+	defer func() { _ = st.Close() }()
+
+	for i := range 200 {
+		require.NoError(t, st.Save(strings.Repeat("x", 100)+string(rune('a'+i%26))+string(rune('a'+i/26)), "checksum", "/var/log/app.log", int64(i)))
+	}
+	m, ok := st.(Maintainer)
+	require.True(t, ok)
+	require.NoError(t, m.IntegrityCheck())
+
+	info, err := os.Stat(dbPath + "-wal")
+	require.NoError(t, err)
+	require.Positive(t, info.Size())
+	require.NoError(t, m.Checkpoint())
+	info, err = os.Stat(dbPath + "-wal")
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "checkpoint should truncate the WAL")
+
+	require.NoError(t, m.Vacuum())
+	offset, found, err := st.Load(strings.Repeat("x", 100)+"ab", "checksum")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(26), offset)
+}
+
+func TestNewSQLiteStoreWithOptions_Garbage(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "collector.db")
+	require.NoError(t, os.WriteFile(dbPath, []byte(strings.Repeat("this is not a sqlite database ", 20)), 0644))
+
+	st, err := NewSQLiteStoreWithOptions(dbPath, Options{})
+	assert.ErrorIs(t, err, ErrStoreCorrupt)
+	assert.Nil(t, st)
+
+	st, err = NewSQLiteStoreWithOptions(dbPath, Options{RebuildIfCorrupt: true})
+	require.NoError(t, err)
+	defer func() { _ = st.Close() }()
+	require.NoError(t, st.Save("id", "checksum", "/var/log/app.log", 1))
+
+	aside, err := filepath.Glob(filepath.Join(dir, "collector.db.corrupt-*"))
+	require.NoError(t, err)
+	assert.Len(t, aside, 1, "the corrupt file is kept for inspection")
+}
+
+func TestNewSQLiteStoreWithOptions_SalvagesOffsets(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	st, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, st.Save("a", "checksum", "/var/log/a.log", 10))
+	require.NoError(t, st.Save("b", "checksum", "/var/log/b.log", 20))
+	require.NoError(t, st.(Maintainer).Checkpoint())
+	require.NoError(t, st.Close())
+
+	// Damage the path index: the table stays readable but the integrity check fails
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	var rootPage, pageSize int64
+	require.NoError(t, db.QueryRow(`SELECT rootpage FROM sqlite_master WHERE name = 'idx_offsets_path'`).Scan(&rootPage))
+	require.NoError(t, db.QueryRow(`PRAGMA page_size`).Scan(&pageSize))
+	require.NoError(t, db.Close())
+	f, err := os.OpenFile(dbPath, os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte(strings.Repeat("\xff", 64)), (rootPage-1)*pageSize+8)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = NewSQLiteStoreWithOptions(dbPath, Options{})
+	require.ErrorIs(t, err, ErrStoreCorrupt)
+
+	st, err = NewSQLiteStoreWithOptions(dbPath, Options{RebuildIfCorrupt: true})
+	require.NoError(t, err)
+	defer func() { _ = st.Close() }()
+	entries, err := st.List("")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	require.NoError(t, st.(Maintainer).IntegrityCheck())
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"time"
+)
+
+// Membership is implemented by stores that track the collectors sharing them, so that
+// sharded collectors can split the files between the instances that are alive. A
+// member heartbeats well within its TTL and leaves on shutdown; a member that stops
+// heartbeating drops out once its TTL has passed.
+type Membership interface {
+	// Heartbeat registers instanceID, or keeps it registered, until ttl from now.
+	Heartbeat(instanceID string, ttl time.Duration) error
+	// Members returns the registered instances whose TTL has not passed, sorted.
+	Members() ([]string, error)
+	// Leave unregisters instanceID.
+	Leave(instanceID string) error
+}
+
+func (s *sqliteStore) Heartbeat(instanceID string, ttl time.Duration) error {
+	now := time.Now()
+	if _, err := s.execWithRetry(
+		`INSERT INTO shard_members (instance_id, heartbeat_at, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT(instance_id) DO UPDATE SET
+		 heartbeat_at = excluded.heartbeat_at,
+		 expires_at = excluded.expires_at`,
+		instanceID, now.UnixMilli(), now.Add(ttl).UnixMilli()); err != nil {
+		return wrapErr("failed to register shard member", err)
+	}
+	// Forget instances that went away without leaving
+	if _, err := s.execWithRetry(`DELETE FROM shard_members WHERE expires_at <= ?`, now.UnixMilli()); err != nil {
+		return wrapErr("failed to register shard member", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Members() ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT instance_id FROM shard_members WHERE expires_at > ? ORDER BY instance_id`,
+		time.Now().UnixMilli())
+	if err != nil {
+		return nil, wrapErr("failed to list shard members", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var members []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, wrapErr("failed to list shard members", err)
+		}
+		members = append(members, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapErr("failed to list shard members", err)
+	}
+	return members, nil
+}
+
+func (s *sqliteStore) Leave(instanceID string) error {
+	if _, err := s.execWithRetry(`DELETE FROM shard_members WHERE instance_id = ?`, instanceID); err != nil {
+		return wrapErr("failed to unregister shard member", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore_Membership(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "collector.db")
+	a, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = a.Close() }()
+	b, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer func() { _ = b.Close() }()
+	ma, mb := a.(Membership), b.(Membership)
+
+	members, err := ma.Members()
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	require.NoError(t, mb.Heartbeat("b", time.Minute))
+	require.NoError(t, ma.Heartbeat("a", time.Minute))
+	require.NoError(t, ma.Heartbeat("a", time.Minute), "heartbeating again keeps one entry")
+	members, err = mb.Members()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, members)
+
+	// A member that stops heartbeating drops out once its TTL has passed
+	require.NoError(t, mb.Heartbeat("b", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	members, err = ma.Members()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, members)
+
+	require.NoError(t, ma.Leave("a"))
+	members, err = mb.Members()
+	require.NoError(t, err)
+	assert.Empty(t, members)
+}
@@ -2,14 +2,30 @@ package store
 
 import (
 	"embed"
-
-	"github.com/pressly/goose/v3"
+	"io/fs"
 )
 
 //go:embed migrations/*.sql
 var migrationFS embed.FS
 
-// InitMigrations initializes the embedded migrations
-func InitMigrations() {
-	goose.SetBaseFS(migrationFS)
+//go:embed migrations/postgres/*.sql
+var postgresMigrationFS embed.FS
+
+// migrations returns the embedded migration files rooted at the migrations directory.
+func migrations() fs.FS {
+	return subFS(migrationFS, "migrations")
+}
+
+// postgresMigrations returns the embedded PostgreSQL migration files.
+func postgresMigrations() fs.FS {
+	return subFS(postgresMigrationFS, "migrations/postgres")
+}
+
+func subFS(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		// fs.Sub only fails for invalid paths; the directory is fixed at compile time.
+		panic(err)
+	}
+	return sub
 }
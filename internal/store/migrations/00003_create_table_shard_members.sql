@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE shard_members (
+                         instance_id TEXT NOT NULL PRIMARY KEY,
+                         heartbeat_at BIGINT NOT NULL,
+                         expires_at BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE shard_members;
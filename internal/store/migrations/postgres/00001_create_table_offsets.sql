@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE offsets (
+                         namespace TEXT NOT NULL DEFAULT '',
+                         id TEXT NOT NULL,
+                         strategy TEXT NOT NULL,
+                         path TEXT NOT NULL,
+                         "offset" BIGINT NOT NULL,
+                         created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                         updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                         PRIMARY KEY (namespace, id, strategy)
+);
+
+CREATE INDEX idx_offsets_path ON offsets(namespace, path);
+
+-- +goose Down
+DROP TABLE offsets;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE instance_lease (
+                         name TEXT NOT NULL PRIMARY KEY,
+                         instance_id TEXT NOT NULL,
+                         hostname TEXT NOT NULL,
+                         pid INTEGER NOT NULL,
+                         acquired_at BIGINT NOT NULL,
+                         heartbeat_at BIGINT NOT NULL,
+                         expires_at BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE instance_lease;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE shard_members (
+                         namespace TEXT NOT NULL,
+                         instance_id TEXT NOT NULL,
+                         heartbeat_at BIGINT NOT NULL,
+                         expires_at BIGINT NOT NULL,
+                         PRIMARY KEY (namespace, instance_id)
+);
+
+-- +goose Down
+DROP TABLE shard_members;
@@ -0,0 +1,312 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// DefaultPostgresDriver is the database/sql driver name NewPostgresStore uses when
+// PostgresOptions.Driver is empty; it is the name registered by
+// github.com/jackc/pgx/v5/stdlib.
+const DefaultPostgresDriver = "pgx"
+
+// PostgresOptions configures NewPostgresStore.
+type PostgresOptions struct {
+	// Driver is the name of the database/sql driver to connect with; DefaultPostgresDriver
+	// when empty. freader does not link a PostgreSQL driver itself: the application
+	// registers one by importing it, e.g. _ "github.com/jackc/pgx/v5/stdlib" or
+	// _ "github.com/lib/pq" (Driver "postgres").
+	Driver string
+	// Namespace separates the offsets, and the lease, of collectors sharing a database
+	// that read different files, e.g. one namespace per host. Collectors reading the
+	// same files, such as an HA pair on a shared volume, use the same namespace.
+	Namespace string
+	// Logger receives migration progress; slog.Default() when nil.
+	Logger *slog.Logger
+}
+
+type postgresStore struct {
+	db        *sql.DB
+	namespace string
+	leaseLost atomic.Bool // set by RenewLease; Save and Delete then fail with ErrLeaseLost
+}
+
+// NewPostgresStore connects to the PostgreSQL database at dsn and migrates its schema,
+// so that several collectors can keep their offsets in one central database. Each
+// offset is one row keyed by namespace and file identity: saves lock only that row, so
+// collectors writing different files do not contend, and concurrent saves of one file
+// are serialized by the database.
+func NewPostgresStore(dsn string, opts PostgresOptions) (Store, error) {
+	driver := opts.Driver
+	if driver == "" {
+		driver = DefaultPostgresDriver
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("no database/sql driver %q is registered; import a PostgreSQL driver such as github.com/jackc/pgx/v5/stdlib", driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	s, err := NewPostgresStoreDB(db, opts)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewPostgresStoreDB is like NewPostgresStore for a connection pool opened by the
+// caller; opts.Driver is ignored. Closing the store closes db.
+func NewPostgresStoreDB(db *sql.DB, opts PostgresOptions) (Store, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Run embedded migrations; goose takes an advisory lock, so collectors starting
+	// together do not migrate concurrently
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up migrations: %w", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, postgresMigrations(),
+		goose.WithTableName("freader_db_version"),
+		goose.WithDisableGlobalRegistry(true),
+		goose.WithSessionLocker(locker),
+		goose.WithSlog(logger),
+		goose.WithVerbose(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up migrations: %w", err)
+	}
+	if _, err := provider.Up(ctx); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return &postgresStore{db: db, namespace: opts.Namespace}, nil
+}
+
+func (s *postgresStore) Save(fileID string, strategy string, path string, offset int64) error {
+	if s.leaseLost.Load() {
+		return ErrLeaseLost
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO offsets (namespace, id, strategy, path, "offset", updated_at)
+		 VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		 ON CONFLICT (namespace, id, strategy) DO UPDATE SET
+		 "offset" = excluded."offset",
+		 path = excluded.path,
+		 updated_at = CURRENT_TIMESTAMP`,
+		s.namespace, fileID, strategy, path, offset)
+	if err != nil {
+		return fmt.Errorf("failed to save offset: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) Load(fileID string, strategy string) (int64, bool, error) {
+	var offset int64
+	err := s.db.QueryRow(
+		`SELECT "offset" FROM offsets WHERE namespace = $1 AND id = $2 AND strategy = $3`,
+		s.namespace, fileID, strategy).Scan(&offset)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to load offset: %w", err)
+	}
+	return offset, true, nil
+}
+
+func (s *postgresStore) Delete(fileID string, strategy string) error {
+	if s.leaseLost.Load() {
+		return ErrLeaseLost
+	}
+	if _, err := s.db.Exec(
+		`DELETE FROM offsets WHERE namespace = $1 AND id = $2 AND strategy = $3`,
+		s.namespace, fileID, strategy); err != nil {
+		return fmt.Errorf("failed to delete offset: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) List(strategy string) ([]Entry, error) {
+	rows, err := s.db.Query(
+		`SELECT id, strategy, path, "offset", updated_at FROM offsets
+		 WHERE namespace = $1 AND ($2 = '' OR strategy = $2) ORDER BY path, strategy, id`,
+		s.namespace, strategy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Strategy, &e.Path, &e.Offset, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to list offsets: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
+
+// leaseName is the lease row guarding the store's namespace.
+func (s *postgresStore) leaseName() string {
+	if s.namespace == "" {
+		return leaseName
+	}
+	return leaseName + "/" + s.namespace
+}
+
+func (s *postgresStore) AcquireLease(l Lease, ttl time.Duration) error {
+	now := time.Now()
+	res, err := s.db.Exec(
+		`INSERT INTO instance_lease (name, instance_id, hostname, pid, acquired_at, heartbeat_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $5, $6)
+		 ON CONFLICT (name) DO UPDATE SET
+		 instance_id = excluded.instance_id,
+		 hostname = excluded.hostname,
+		 pid = excluded.pid,
+		 acquired_at = excluded.acquired_at,
+		 heartbeat_at = excluded.heartbeat_at,
+		 expires_at = excluded.expires_at
+		 WHERE instance_lease.instance_id = excluded.instance_id OR instance_lease.expires_at <= $5`,
+		s.leaseName(), l.InstanceID, l.Hostname, l.PID, now.UnixMilli(), now.Add(ttl).UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to acquire offset store lease: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to acquire offset store lease: %w", err)
+	} else if n == 0 {
+		holder, ok, err := s.CurrentLease()
+		if err != nil || !ok {
+			return ErrLeaseHeld
+		}
+		return fmt.Errorf("%w: %s", ErrLeaseHeld, holder)
+	}
+	s.leaseLost.Store(false)
+	return nil
+}
+
+func (s *postgresStore) RenewLease(instanceID string, ttl time.Duration) error {
+	now := time.Now()
+	res, err := s.db.Exec(
+		`UPDATE instance_lease SET heartbeat_at = $1, expires_at = $2 WHERE name = $3 AND instance_id = $4`,
+		now.UnixMilli(), now.Add(ttl).UnixMilli(), s.leaseName(), instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to renew offset store lease: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to renew offset store lease: %w", err)
+	} else if n == 0 {
+		s.leaseLost.Store(true)
+		if holder, ok, err := s.CurrentLease(); err == nil && ok {
+			return fmt.Errorf("%w: %s", ErrLeaseLost, holder)
+		}
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *postgresStore) ReleaseLease(instanceID string) error {
+	if _, err := s.db.Exec(
+		`DELETE FROM instance_lease WHERE name = $1 AND instance_id = $2`,
+		s.leaseName(), instanceID); err != nil {
+		return fmt.Errorf("failed to release offset store lease: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) CurrentLease() (Lease, bool, error) {
+	var (
+		l                            Lease
+		acquired, heartbeat, expires int64
+	)
+	err := s.db.QueryRow(
+		`SELECT instance_id, hostname, pid, acquired_at, heartbeat_at, expires_at
+		 FROM instance_lease WHERE name = $1`, s.leaseName()).
+		Scan(&l.InstanceID, &l.Hostname, &l.PID, &acquired, &heartbeat, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Lease{}, false, nil
+	}
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("failed to read offset store lease: %w", err)
+	}
+	l.AcquiredAt = time.UnixMilli(acquired)
+	l.HeartbeatAt = time.UnixMilli(heartbeat)
+	l.ExpiresAt = time.UnixMilli(expires)
+	return l, true, nil
+}
+
+func (s *postgresStore) Heartbeat(instanceID string, ttl time.Duration) error {
+	now := time.Now()
+	if _, err := s.db.Exec(
+		`INSERT INTO shard_members (namespace, instance_id, heartbeat_at, expires_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (namespace, instance_id) DO UPDATE SET
+		 heartbeat_at = excluded.heartbeat_at,
+		 expires_at = excluded.expires_at`,
+		s.namespace, instanceID, now.UnixMilli(), now.Add(ttl).UnixMilli()); err != nil {
+		return fmt.Errorf("failed to register shard member: %w", err)
+	}
+	// Forget instances that went away without leaving
+	if _, err := s.db.Exec(
+		`DELETE FROM shard_members WHERE namespace = $1 AND expires_at <= $2`,
+		s.namespace, now.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to register shard member: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) Members() ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT instance_id FROM shard_members WHERE namespace = $1 AND expires_at > $2 ORDER BY instance_id`,
+		s.namespace, time.Now().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shard members: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var members []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to list shard members: %w", err)
+		}
+		members = append(members, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list shard members: %w", err)
+	}
+	return members, nil
+}
+
+func (s *postgresStore) Leave(instanceID string) error {
+	if _, err := s.db.Exec(
+		`DELETE FROM shard_members WHERE namespace = $1 AND instance_id = $2`,
+		s.namespace, instanceID); err != nil {
+		return fmt.Errorf("failed to unregister shard member: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"database/sql"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresMigrations(t *testing.T) {
+	// Sources are collected without connecting; the SQLite handle is never used
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, postgresMigrations(),
+		goose.WithDisableGlobalRegistry(true))
+	require.NoError(t, err)
+	sources := provider.ListSources()
+	require.Len(t, sources, 3)
+	assert.Equal(t, int64(1), sources[0].Version)
+	assert.Equal(t, int64(3), sources[2].Version)
+
+	// The Postgres directory is not picked up by the SQLite migrations
+	provider, err = goose.NewProvider(goose.DialectSQLite3, db, migrations(),
+		goose.WithDisableGlobalRegistry(true))
+	require.NoError(t, err)
+	assert.Len(t, provider.ListSources(), 3)
+}
+
+func TestPostgresStore_UnknownDriver(t *testing.T) {
+	_, err := NewPostgresStore("postgres://localhost/freader", PostgresOptions{Driver: "no-such-driver"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"no-such-driver"`)
+}
+
+// openTestPostgres connects to the database named by FREADER_TEST_POSTGRES_DSN with the
+// driver named by FREADER_TEST_POSTGRES_DRIVER (default pgx), which the test binary must
+// have registered; the test is skipped otherwise.
+func openTestPostgres(t *testing.T, namespace string) Store {
+	dsn := os.Getenv("FREADER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("set FREADER_TEST_POSTGRES_DSN to run PostgreSQL store tests")
+	}
+	s, err := NewPostgresStore(dsn, PostgresOptions{Driver: os.Getenv("FREADER_TEST_POSTGRES_DRIVER"), Namespace: namespace})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// openDialectPostgres returns a func opening the PostgreSQL store over one SQLite
+// database migrated with the PostgreSQL schema, so its SQL runs without a server. The
+// queries only use features both dialects share, with $N parameters in order.
+func openDialectPostgres(t *testing.T) func(namespace string) Store {
+	path := filepath.Join(t.TempDir(), "postgres.db")
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	sources, err := fs.Glob(postgresMigrations(), "*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, sources)
+	for _, name := range sources {
+		data, err := fs.ReadFile(postgresMigrations(), name)
+		require.NoError(t, err)
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		// SQLite has no time zone type; TIMESTAMP makes the driver scan it as time.Time
+		up = strings.ReplaceAll(up, "TIMESTAMPTZ", "TIMESTAMP")
+		_, err = db.Exec(strings.TrimPrefix(up, "-- +goose Up"))
+		require.NoError(t, err, name)
+	}
+	return func(namespace string) Store {
+		db, err := sql.Open("sqlite", path)
+		require.NoError(t, err)
+		s := &postgresStore{db: db, namespace: namespace}
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	}
+}
+
+func TestPostgresStore(t *testing.T) {
+	testPostgresStore(t, func(namespace string) Store { return openTestPostgres(t, namespace) })
+}
+
+func TestPostgresStore_SQL(t *testing.T) {
+	testPostgresStore(t, openDialectPostgres(t))
+}
+
+func testPostgresStore(t *testing.T, open func(namespace string) Store) {
+	ns := "test-" + time.Now().Format("20060102150405.000000000")
+	s := open(ns)
+	other := open(ns + "-other")
+
+	require.NoError(t, s.Save("id1", "checksum", "/var/log/a.log", 10))
+	require.NoError(t, s.Save("id1", "checksum", "/var/log/a.log", 20))
+	require.NoError(t, s.Save("id2", "deviceAndInode", "/var/log/b.log", 5))
+
+	offset, found, err := s.Load("id1", "checksum")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(20), offset)
+
+	// Namespaces do not see each other's offsets
+	_, found, err = other.Load("id1", "checksum")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	entries, err := s.List("checksum")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/var/log/a.log", entries[0].Path)
+	entries, err = s.List("")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	require.NoError(t, s.Delete("id1", "checksum"))
+	_, found, err = s.Load("id1", "checksum")
+	require.NoError(t, err)
+	assert.False(t, found)
+	require.NoError(t, s.Delete("id2", "deviceAndInode"))
+
+	// The lease is held per namespace
+	l := s.(Leaser)
+	require.NoError(t, l.AcquireLease(Lease{InstanceID: "a", Hostname: "h", PID: 1}, time.Minute))
+	require.NoError(t, other.(Leaser).AcquireLease(Lease{InstanceID: "b", Hostname: "h", PID: 2}, time.Minute))
+	second := open(ns)
+	err = second.(Leaser).AcquireLease(Lease{InstanceID: "c", Hostname: "h", PID: 3}, time.Minute)
+	assert.ErrorIs(t, err, ErrLeaseHeld)
+	assert.Contains(t, err.Error(), "instance a")
+	require.NoError(t, l.RenewLease("a", time.Minute))
+	holder, ok, err := second.(Leaser).CurrentLease()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a", holder.InstanceID)
+	require.NoError(t, l.ReleaseLease("a"))
+	require.NoError(t, other.(Leaser).ReleaseLease("b"))
+
+	// A released lease cannot be renewed, and the store stops writing until it is
+	// acquired again
+	assert.ErrorIs(t, l.RenewLease("a", time.Minute), ErrLeaseLost)
+	assert.ErrorIs(t, s.Save("id3", "checksum", "/var/log/c.log", 1), ErrLeaseLost)
+	require.NoError(t, l.AcquireLease(Lease{InstanceID: "a", Hostname: "h", PID: 1}, time.Minute))
+	require.NoError(t, s.Save("id3", "checksum", "/var/log/c.log", 1))
+	require.NoError(t, s.Delete("id3", "checksum"))
+
+	// An expired lease is taken over
+	require.NoError(t, l.AcquireLease(Lease{InstanceID: "a", Hostname: "h", PID: 1}, -time.Second))
+	require.NoError(t, second.(Leaser).AcquireLease(Lease{InstanceID: "c", Hostname: "h", PID: 3}, time.Minute))
+	require.NoError(t, second.(Leaser).ReleaseLease("c"))
+
+	// Shard members are registered per namespace
+	m := s.(Membership)
+	require.NoError(t, m.Heartbeat("b", time.Minute))
+	require.NoError(t, m.Heartbeat("a", time.Minute))
+	require.NoError(t, other.(Membership).Heartbeat("c", time.Minute))
+	members, err := m.Members()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, members)
+	// Expired members are not listed
+	require.NoError(t, m.Heartbeat("z", -time.Second))
+	members, err = m.Members()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, members)
+	require.NoError(t, m.Leave("a"))
+	require.NoError(t, m.Leave("b"))
+	require.NoError(t, other.(Membership).Leave("c"))
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnly(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "offsets.db"))
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+	require.NoError(t, s.Save("id1", "checksum", "/var/log/app.log", 100))
+
+	ro := ReadOnly(s)
+	require.NoError(t, ro.Save("id1", "checksum", "/var/log/app.log", 200))
+	require.NoError(t, ro.Save("id2", "checksum", "/var/log/other.log", 10))
+	require.NoError(t, ro.Delete("id1", "checksum"))
+
+	offset, found, err := ro.Load("id1", "checksum")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(100), offset)
+	_, found, err = ro.Load("id2", "checksum")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, isLeaser := ro.(Leaser)
+	_, isMaintainer := ro.(Maintainer)
+	assert.False(t, isLeaser || isMaintainer, "a read-only store must not expose leases or maintenance")
+}
@@ -1,12 +1,15 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pressly/goose/v3"
@@ -25,12 +28,39 @@ type Store interface {
 	// Delete removes the offset for a file identified by its ID and strategy
 	Delete(fileID string, strategy string) error
 
+	// List returns the stored offsets for a strategy, or for all strategies when
+	// strategy is empty, ordered by path
+	List(strategy string) ([]Entry, error)
+
 	// Close closes the store and releases any resources
 	Close() error
 }
 
+// Renamer is implemented by stores that can move an offset to another ID atomically,
+// such as the SQLite store.
+type Renamer interface {
+	// Rename moves the offset stored under oldID and oldStrategy to newID and
+	// newStrategy in one transaction, replacing any offset stored there. It does nothing
+	// if there is no offset under oldID.
+	Rename(oldID, oldStrategy, newID, newStrategy string) error
+}
+
+// Entry is one stored offset.
+type Entry struct {
+	ID        string
+	Strategy  string
+	Path      string
+	Offset    int64
+	UpdatedAt time.Time
+}
+
+// ErrStoreCorrupt indicates the offsets database file is not a valid or intact SQLite database.
+// Errors returned by the store wrap it so callers can detect corruption with errors.Is.
+var ErrStoreCorrupt = errors.New("offset store is corrupt")
+
 type sqliteStore struct {
-	db *sql.DB
+	db        *sql.DB
+	leaseLost atomic.Bool // set by RenewLease; Save and Delete then fail with ErrLeaseLost
 }
 
 // isBusyError returns true if error indicates SQLITE_BUSY
@@ -42,6 +72,26 @@ func isBusyError(err error) bool {
 	return strings.Contains(err.Error(), "SQLITE_BUSY") || strings.Contains(err.Error(), "database is locked")
 }
 
+// isCorruptError returns true if error indicates SQLITE_CORRUPT or SQLITE_NOTADB
+func isCorruptError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "file is not a database") ||
+		strings.Contains(msg, "database disk image is malformed") ||
+		strings.Contains(msg, "SQLITE_CORRUPT") ||
+		strings.Contains(msg, "SQLITE_NOTADB")
+}
+
+// wrapErr annotates err with msg and marks corruption with ErrStoreCorrupt.
+func wrapErr(msg string, err error) error {
+	if isCorruptError(err) {
+		return fmt.Errorf("%s: %w: %w", msg, ErrStoreCorrupt, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
 // execWithRetry executes a statement with args, retrying on SQLITE_BUSY up to a small limit.
 func (s *sqliteStore) execWithRetry(query string, args ...any) (sql.Result, error) {
 	var (
@@ -64,6 +114,17 @@ func (s *sqliteStore) execWithRetry(query string, args ...any) (sql.Result, erro
 
 // NewSQLiteStore creates a new SQLite-based store with migrations
 func NewSQLiteStore(dbPath string) (Store, error) {
+	return NewSQLiteStoreWithLogger(dbPath, nil)
+}
+
+// NewSQLiteStoreWithLogger is like NewSQLiteStore but reports migration progress to
+// logger (slog.Default() when nil). Migrations run through a goose provider, so no
+// process-global goose state is touched and several stores may be opened concurrently.
+func NewSQLiteStoreWithLogger(dbPath string, logger *slog.Logger) (Store, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	// Ensure directory exists
 	if dir := filepath.Dir(dbPath); dir != "" {
 		if err := ensureDir(dir); err != nil {
@@ -72,36 +133,48 @@ func NewSQLiteStore(dbPath string) (Store, error) {
 	}
 
 	// Open database connection
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := openDB(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	// Improve concurrency/robustness: set busy timeout and WAL mode
-	// Ignore errors from pragmas; they are best-effort and platform/driver dependent
-	_, _ = db.Exec("PRAGMA busy_timeout = 2000")
-	_, _ = db.Exec("PRAGMA journal_mode = WAL")
-
-	// Set up goose with embedded migrations
-	InitMigrations()
-
-	// Run migrations
-	if err := goose.SetDialect("sqlite3"); err != nil {
+	// Run embedded migrations
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db, migrations(),
+		goose.WithTableName("freader_db_version"),
+		goose.WithDisableGlobalRegistry(true),
+		goose.WithSlog(logger),
+		goose.WithVerbose(true),
+	)
+	if err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("failed to set dialect: %w", err)
+		return nil, fmt.Errorf("failed to set up migrations: %w", err)
 	}
-
-	goose.SetTableName("freader_db_version")
-
-	if err := goose.Up(db, "migrations"); err != nil {
+	if _, err := provider.Up(context.Background()); err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+		return nil, wrapErr("failed to run migrations", err)
 	}
 
 	return &sqliteStore{db: db}, nil
 }
 
+// openDB opens the database at dbPath with a busy timeout and WAL mode.
+func openDB(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Improve concurrency/robustness: set busy timeout and WAL mode
+	// Ignore errors from pragmas; they are best-effort and platform/driver dependent
+	_, _ = db.Exec("PRAGMA busy_timeout = 2000")
+	_, _ = db.Exec("PRAGMA journal_mode = WAL")
+	return db, nil
+}
+
 func (s *sqliteStore) Save(fileID string, strategy string, path string, offset int64) error {
+	if s.leaseLost.Load() {
+		return ErrLeaseLost
+	}
 	_, err := s.execWithRetry(
 		`INSERT INTO offsets (id, strategy, path, offset, updated_at) 
 		 VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP) 
@@ -112,7 +185,7 @@ func (s *sqliteStore) Save(fileID string, strategy string, path string, offset i
 		fileID, strategy, path, offset)
 
 	if err != nil {
-		return fmt.Errorf("failed to save offset: %w", err)
+		return wrapErr("failed to save offset", err)
 	}
 
 	return nil
@@ -128,24 +201,81 @@ func (s *sqliteStore) Load(fileID string, strategy string) (int64, bool, error)
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, false, nil
 		}
-		return 0, false, fmt.Errorf("failed to load offset: %w", err)
+		return 0, false, wrapErr("failed to load offset", err)
 	}
 
 	return offset, true, nil
 }
 
 func (s *sqliteStore) Delete(fileID string, strategy string) error {
+	if s.leaseLost.Load() {
+		return ErrLeaseLost
+	}
 	_, err := s.execWithRetry(
 		`DELETE FROM offsets WHERE id = ? AND strategy = ?`,
 		fileID, strategy)
 
 	if err != nil {
-		return fmt.Errorf("failed to delete offset: %w", err)
+		return wrapErr("failed to delete offset", err)
 	}
 
 	return nil
 }
 
+func (s *sqliteStore) Rename(oldID, oldStrategy, newID, newStrategy string) error {
+	if s.leaseLost.Load() {
+		return ErrLeaseLost
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return wrapErr("failed to rename offset", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(
+		`INSERT INTO offsets (id, strategy, path, offset, updated_at)
+		 SELECT ?, ?, path, offset, CURRENT_TIMESTAMP FROM offsets WHERE id = ? AND strategy = ?
+		 ON CONFLICT(id, strategy) DO UPDATE SET
+		 offset = excluded.offset,
+		 path = excluded.path,
+		 updated_at = CURRENT_TIMESTAMP`,
+		newID, newStrategy, oldID, oldStrategy); err != nil {
+		return wrapErr("failed to rename offset", err)
+	}
+	if _, err := tx.Exec(
+		`DELETE FROM offsets WHERE id = ? AND strategy = ?`,
+		oldID, oldStrategy); err != nil {
+		return wrapErr("failed to rename offset", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return wrapErr("failed to rename offset", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) List(strategy string) ([]Entry, error) {
+	rows, err := s.db.Query(
+		`SELECT id, strategy, path, offset, updated_at FROM offsets
+		 WHERE ? = '' OR strategy = ? ORDER BY path, strategy, id`,
+		strategy, strategy)
+	if err != nil {
+		return nil, wrapErr("failed to list offsets", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Strategy, &e.Path, &e.Offset, &e.UpdatedAt); err != nil {
+			return nil, wrapErr("failed to list offsets", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapErr("failed to list offsets", err)
+	}
+	return entries, nil
+}
+
 func (s *sqliteStore) Close() error {
 	return s.db.Close()
 }
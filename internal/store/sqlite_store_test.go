@@ -2,6 +2,7 @@ package store
 
 import (
 	"database/sql"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -171,6 +172,17 @@ func TestSQLiteStore_Errors(t *testing.T) {
 	})
 }
 
+func TestNewSQLiteStore_CorruptFile(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "corrupt.db")
+	garbage := strings.Repeat("this is not a sqlite database ", 20)
+	assert.NoError(t, os.WriteFile(dbPath, []byte(garbage), 0644))
+
+	store, err := NewSQLiteStore(dbPath)
+	assert.Error(t, err)
+	assert.Nil(t, store)
+	assert.ErrorIs(t, err, ErrStoreCorrupt)
+}
+
 // TestNewSQLiteStore_Initialization verifies that NewSQLiteStore creates missing
 // directories, applies migrations, and sets recommended PRAGMAs.
 func TestNewSQLiteStore_Initialization(t *testing.T) {
@@ -230,3 +242,49 @@ func TestNewSQLiteStore_Initialization(t *testing.T) {
 		t.Fatalf("query busy_timeout failed: %v", err)
 	}
 }
+
+func TestSQLiteStore_List(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "list.db"))
+	require.NoError(t, err)
+	defer func() { _ = store.Close() }()
+
+	require.NoError(t, store.Save("b", "checksum", "/var/log/b.log", 2))
+	require.NoError(t, store.Save("a", "checksum", "/var/log/a.log", 1))
+	require.NoError(t, store.Save("c", "deviceAndInode", "/var/log/c.log", 3))
+
+	entries, err := store.List("checksum")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/var/log/a.log", entries[0].Path)
+	assert.Equal(t, int64(1), entries[0].Offset)
+	assert.Equal(t, "checksum", entries[0].Strategy)
+	assert.False(t, entries[0].UpdatedAt.IsZero())
+
+	all, err := store.List("")
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
+
+func TestSQLiteStore_Rename(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "rename.db"))
+	require.NoError(t, err)
+	defer func() { _ = store.Close() }()
+	renamer := store.(Renamer)
+
+	require.NoError(t, store.Save("dev:1-ino:2", "deviceAndInode", "/var/log/a.log", 42))
+	require.NoError(t, store.Save("abc", "checksum", "/var/log/old.log", 7))
+	require.NoError(t, renamer.Rename("dev:1-ino:2", "deviceAndInode", "abc", "checksum"))
+
+	// The offset replaces the one stored under the new ID
+	entries, err := store.List("")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, Entry{ID: "abc", Strategy: "checksum", Path: "/var/log/a.log", Offset: 42, UpdatedAt: entries[0].UpdatedAt}, entries[0])
+
+	// Renaming a missing offset changes nothing
+	require.NoError(t, renamer.Rename("missing", "deviceAndInode", "abc", "checksum"))
+	offset, found, err := store.Load("abc", "checksum")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(42), offset)
+}
@@ -0,0 +1,156 @@
+package tailer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// probeSize is how much ReadChunked reads at a time while looking for the separator
+// that ends a chunk.
+const probeSize = 4096
+
+// ReadChunked catches up on a file far behind its end faster than ReadOnceBytes: it
+// splits the data between Offset and the current end of the file into chunks of about
+// chunkSize bytes, each ending on a separator, reads up to readers chunks in parallel
+// and hands the records to callback in file order. As with ReadOnceBytes, Offset is at
+// the start of each record while its callback runs and ends after the last complete
+// record; a trailing record without a separator is left for ReadOnceBytes.
+//
+// Once stop is closed, no further chunks are read and ReadChunked returns ErrStopped
+// after the chunk being delivered, with Offset after its last record.
+//
+// Only plain Separator splitting without Multiline is supported, with a separator whose
+// occurrences cannot overlap. Files less than two chunks behind are not worth
+// splitting. ReadChunked reads nothing in these cases.
+func (t *TailReader) ReadChunked(chunkSize int64, readers int, stop <-chan struct{}, callback func([]byte)) error {
+	if chunkSize <= 0 || t.Multiline != nil || t.SeparatorRegex != nil || t.LengthPrefix != nil {
+		return nil
+	}
+	if err := t.open(); err != nil {
+		return err
+	}
+	defer t.cleanup()
+	stat, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	if size-t.Offset < 2*chunkSize {
+		return nil
+	}
+	sep := []byte(t.Separator)
+	if len(sep) == 0 {
+		return errors.New("separator must not be empty")
+	}
+	if selfOverlapping(sep) {
+		// A separator found mid-file may not be where a sequential read splits
+		return nil
+	}
+	bounds, err := chunkBounds(t.file, sep, t.Offset, size, chunkSize)
+	if err != nil {
+		return err
+	}
+	readers = max(readers, 1)
+	t.log().Debug("catching up in chunks", "fileId", t.FileId, "offset", t.Offset, "size", size,
+		"chunks", len(bounds)-1, "readers", readers)
+
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	results := make([]chan chunk, len(bounds)-1)
+	for i := range results {
+		results[i] = make(chan chunk, 1)
+	}
+	sem := make(chan struct{}, readers) // chunks read but not yet delivered
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := range results {
+			select {
+			case sem <- struct{}{}:
+			case <-done:
+				return
+			case <-stop:
+				return
+			}
+			go func(i int) {
+				data := make([]byte, bounds[i+1]-bounds[i])
+				n, err := t.file.ReadAt(data, bounds[i])
+				if n > 0 && t.Throttle != nil {
+					t.Throttle(n)
+				}
+				if err == io.EOF && int64(n) == bounds[i+1]-bounds[i] {
+					err = nil
+				}
+				results[i] <- chunk{data: data[:n], err: err}
+			}(i)
+		}
+	}()
+
+	for i, ch := range results {
+		var c chunk
+		select {
+		case c = <-ch:
+		case <-stop:
+			return ErrStopped
+		}
+		if c.err != nil {
+			return c.err
+		}
+		// Chunks end on a separator, except possibly the last one
+		t.Offset = bounds[i]
+		data := c.data
+		for {
+			idx := bytes.Index(data, sep)
+			if idx < 0 {
+				break
+			}
+			if idx > 0 {
+				callback(data[:idx])
+			}
+			t.Offset += int64(idx + len(sep))
+			data = data[idx+len(sep):]
+		}
+		<-sem
+	}
+	return nil
+}
+
+// selfOverlapping reports whether two occurrences of sep can overlap, as in "\n\n".
+func selfOverlapping(sep []byte) bool {
+	for k := 1; k < len(sep); k++ {
+		if bytes.Equal(sep[:k], sep[len(sep)-k:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// chunkBounds returns the offsets splitting [from, size) into chunks of at least
+// chunkSize bytes that end right after a separator; the last one ends at size.
+func chunkBounds(f *os.File, sep []byte, from, size, chunkSize int64) ([]int64, error) {
+	bounds := []int64{from}
+	buf := make([]byte, probeSize+len(sep)-1)
+	for at := from + chunkSize; at < size; {
+		end := int64(-1)
+		// Overlap probes by len(sep)-1 bytes so a separator across them is found
+		for p := at; p < size && end < 0; p += probeSize {
+			n, err := f.ReadAt(buf, p)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if idx := bytes.Index(buf[:n], sep); idx >= 0 {
+				end = p + int64(idx+len(sep))
+			}
+		}
+		if end < 0 || end >= size {
+			break
+		}
+		bounds = append(bounds, end)
+		at = end + chunkSize
+	}
+	return append(bounds, size), nil
+}
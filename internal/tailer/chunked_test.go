@@ -0,0 +1,102 @@
+package tailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/watcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailReader_ReadChunked(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		sb.WriteString(fmt.Sprintf("record %d %s\r\n", i, strings.Repeat("x", i%37)))
+		if i%50 == 0 {
+			sb.WriteString("\r\n") // empty record
+		}
+	}
+	sb.WriteString("partial")
+	data := sb.String()
+	p := filepath.Join(t.TempDir(), "big.log")
+	require.NoError(t, os.WriteFile(p, []byte(data), 0644))
+	fi, err := os.Stat(p)
+	require.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	require.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	type rec struct {
+		line   string
+		offset int64
+	}
+	read := func(r *TailReader, chunked bool) []rec {
+		var recs []rec
+		cb := func(b []byte) { recs = append(recs, rec{string(b), r.Offset}) }
+		if chunked {
+			require.NoError(t, r.ReadChunked(256, 3, nil, cb))
+		} else {
+			require.NoError(t, r.ReadOnceBytes(cb))
+		}
+		return recs
+	}
+	start := int64(strings.Index(data, "record 10 "))
+
+	want := read(&TailReader{FileId: id, FileManager: tr, Separator: "\r\n", Offset: start}, false)
+	r := &TailReader{FileId: id, FileManager: tr, Separator: "\r\n", Offset: start}
+	assert.Equal(t, want, read(r, true))
+	assert.Equal(t, int64(len(data)-len("partial")), r.Offset)
+	// The trailing partial record is left for ReadOnceBytes
+	assert.Empty(t, read(r, false))
+
+	// Files less than two chunks behind and self-overlapping separators are not split
+	r = &TailReader{FileId: id, FileManager: tr, Separator: "\r\n", Offset: int64(len(data) - 300)}
+	assert.Empty(t, read(r, true))
+	assert.Equal(t, int64(len(data)-300), r.Offset)
+	r = &TailReader{FileId: id, FileManager: tr, Separator: "\n\n"}
+	assert.Empty(t, read(r, true))
+	assert.Zero(t, r.Offset)
+}
+
+func TestTailReader_ReadChunked_Stop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	line := strings.Repeat("x", 99) + "\n"
+	data := strings.Repeat(line, 20000) // 2MB: 2000 chunks of 1KB
+	p := filepath.Join(t.TempDir(), "big.log")
+	require.NoError(t, os.WriteFile(p, []byte(data), 0644))
+	fi, err := os.Stat(p)
+	require.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	require.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	// Each chunk takes 5ms to read, so the whole file would take 10s
+	r := &TailReader{FileId: id, FileManager: tr, Separator: "\n", Throttle: func(int) { time.Sleep(5 * time.Millisecond) }}
+	stop := make(chan struct{})
+	records := 0
+	begin := time.Now()
+	err = r.ReadChunked(1024, 1, stop, func([]byte) {
+		if records++; records == 1 {
+			close(stop)
+		}
+	})
+	assert.ErrorIs(t, err, ErrStopped)
+	assert.Less(t, time.Since(begin), time.Second, "stopped catch-up kept reading")
+	assert.Less(t, r.Offset, int64(len(data)))
+	assert.Zero(t, r.Offset%int64(len(line)), "offset stays on a record boundary")
+}
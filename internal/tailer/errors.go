@@ -1,6 +1,15 @@
 package tailer
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStopped is returned by ReadChunked when it was stopped before reaching the end.
+var ErrStopped = errors.New("read stopped")
+
+// ErrFingerprintMismatch is matched via errors.Is by FileFingerprintMismatchError.
+var ErrFingerprintMismatch = errors.New("file fingerprint mismatch")
 
 // FileFingerprintMismatchError indicates that a file's fingerprint has changed,
 // usually due to file rotation, truncation, or overwrite.
@@ -15,11 +24,11 @@ func (e *FileFingerprintMismatchError) Error() string {
 		e.Path, e.ExpectedFingerprint, e.ActualFingerprint)
 }
 
-// IsFileFingerprintMismatch checks if an error is a FileFingerprintMismatchError.
+// Is reports whether target is ErrFingerprintMismatch.
+func (e *FileFingerprintMismatchError) Is(target error) bool { return target == ErrFingerprintMismatch }
+
+// IsFileFingerprintMismatch checks if an error is (or wraps) a FileFingerprintMismatchError.
 func IsFileFingerprintMismatch(err error) bool {
-	if err == nil {
-		return false
-	}
-	_, ok := err.(*FileFingerprintMismatchError)
-	return ok
+	var e *FileFingerprintMismatchError
+	return errors.As(err, &e)
 }
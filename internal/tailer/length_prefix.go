@@ -0,0 +1,107 @@
+package tailer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxRecordSize bounds length-prefixed records when LengthPrefix.MaxRecordSize is 0.
+const DefaultMaxRecordSize = 64 << 20
+
+// ErrRecordTooLarge is returned when a length prefix exceeds LengthPrefix.MaxRecordSize,
+// which usually means the data is not framed as configured. A TailReader returns it
+// once and then skips to the end of the file; a ReaderTail ends the stream with it.
+var ErrRecordTooLarge = errors.New("record exceeds max record size")
+
+// LengthPrefix describes length-prefixed binary framing, e.g. binary journals or
+// protobuf-delimited files: every record is preceded by its length in bytes, either
+// as an unsigned integer of Width bytes (1, 2, 4 or 8) in ByteOrder, or as an unsigned
+// varint (protobuf style) when Width is 0. The prefix does not count itself.
+type LengthPrefix struct {
+	Width int
+	// ByteOrder of fixed-width prefixes; nil means binary.BigEndian.
+	ByteOrder binary.ByteOrder
+	// MaxRecordSize rejects larger records; 0 uses DefaultMaxRecordSize.
+	MaxRecordSize int
+}
+
+// Validate checks the prefix width and record size bound.
+func (lp *LengthPrefix) Validate() error {
+	switch lp.Width {
+	case 0, 1, 2, 4, 8:
+	default:
+		return fmt.Errorf("length prefix width must be 0 (varint), 1, 2, 4 or 8, got %d", lp.Width)
+	}
+	if lp.MaxRecordSize < 0 {
+		return errors.New("length prefix max record size must not be negative")
+	}
+	return nil
+}
+
+// decode parses the prefix at the start of b. ok is false when b holds only part of it.
+func (lp *LengthPrefix) decode(b []byte) (size uint64, n int, ok bool, err error) {
+	if lp.Width == 0 {
+		size, n = binary.Uvarint(b)
+		if n < 0 {
+			return 0, 0, false, fmt.Errorf("%w: length prefix varint overflows 64 bits", ErrRecordTooLarge)
+		}
+		return size, n, n > 0, nil
+	}
+	if len(b) < lp.Width {
+		return 0, 0, false, nil
+	}
+	order := lp.ByteOrder
+	if order == nil {
+		order = binary.BigEndian
+	}
+	switch lp.Width {
+	case 1:
+		size = uint64(b[0])
+	case 2:
+		size = uint64(order.Uint16(b))
+	case 4:
+		size = uint64(order.Uint32(b))
+	case 8:
+		size = order.Uint64(b)
+	default:
+		return 0, 0, false, fmt.Errorf("unsupported length prefix width %d", lp.Width)
+	}
+	return size, lp.Width, true, nil
+}
+
+func (lp *LengthPrefix) maxRecordSize() uint64 {
+	if lp.MaxRecordSize > 0 {
+		return uint64(lp.MaxRecordSize)
+	}
+	return DefaultMaxRecordSize
+}
+
+// lengthPrefixSplitter frames records by their length prefix.
+type lengthPrefixSplitter struct {
+	lp       *LengthPrefix
+	consumed int // see regexSplitter.consumed
+}
+
+func (s *lengthPrefixSplitter) next(r *bufio.Reader, buf *[]byte) (chunk, line []byte, err error) {
+	discard(buf, &s.consumed)
+	for {
+		size, n, ok, err := s.lp.decode(*buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			if size > s.lp.maxRecordSize() {
+				return nil, nil, fmt.Errorf("%w: %d bytes", ErrRecordTooLarge, size)
+			}
+			if end := n + int(size); len(*buf) >= end {
+				s.consumed = end
+				return (*buf)[:end], (*buf)[n:end], nil
+			}
+		}
+		if err := fill(r, buf); err != nil {
+			return nil, nil, err
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package tailer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/loykin/freader/internal/watcher"
+	"github.com/stretchr/testify/assert"
+)
+
+// frame encodes records with the given prefix layout.
+func frame(lp LengthPrefix, records ...[]byte) []byte {
+	var order binary.AppendByteOrder = binary.BigEndian
+	if lp.ByteOrder != nil {
+		order = lp.ByteOrder.(binary.AppendByteOrder)
+	}
+	var out []byte
+	for _, rec := range records {
+		switch lp.Width {
+		case 0:
+			out = binary.AppendUvarint(out, uint64(len(rec)))
+		case 1:
+			out = append(out, byte(len(rec)))
+		case 2:
+			out = order.AppendUint16(out, uint16(len(rec)))
+		case 4:
+			out = order.AppendUint32(out, uint32(len(rec)))
+		case 8:
+			out = order.AppendUint64(out, uint64(len(rec)))
+		}
+		out = append(out, rec...)
+	}
+	return out
+}
+
+func TestReaderTail_LengthPrefix(t *testing.T) {
+	records := [][]byte{[]byte("first"), {0x00, '\n', 0xff}, bytes.Repeat([]byte("x"), 300)}
+	layouts := []LengthPrefix{
+		{Width: 0},
+		{Width: 2},
+		{Width: 4, ByteOrder: binary.LittleEndian},
+		{Width: 8, ByteOrder: binary.BigEndian},
+	}
+	for _, lp := range layouts {
+		input := frame(lp, records...)
+		rt := NewReaderTail(bytes.NewReader(input), WithLengthPrefix(&lp))
+		var got [][]byte
+		assert.NoError(t, rt.Run(func(s string) { got = append(got, []byte(s)) }), "width %d", lp.Width)
+		assert.Equal(t, records, got, "width %d", lp.Width)
+		assert.Equal(t, int64(len(input)), rt.Offset())
+	}
+}
+
+func TestReaderTail_LengthPrefix_Errors(t *testing.T) {
+	lp := &LengthPrefix{Width: 4}
+	truncated := frame(*lp, []byte("complete"), []byte("cut off"))
+	rt := NewReaderTail(bytes.NewReader(truncated[:len(truncated)-2]), WithLengthPrefix(lp))
+	rec, err := rt.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "complete", rec)
+	_, err = rt.Next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	small := &LengthPrefix{Width: 4, MaxRecordSize: 4}
+	rt = NewReaderTail(bytes.NewReader(frame(*small, []byte("too long"))), WithLengthPrefix(small))
+	_, err = rt.Next()
+	assert.ErrorIs(t, err, ErrRecordTooLarge)
+	_, err = rt.Next()
+	assert.ErrorIs(t, err, io.EOF)
+
+	overflow := bytes.Repeat([]byte{0xff}, binary.MaxVarintLen64+1)
+	rt = NewReaderTail(bytes.NewReader(overflow), WithLengthPrefix(&LengthPrefix{}))
+	_, err = rt.Next()
+	assert.ErrorIs(t, err, ErrRecordTooLarge)
+
+	assert.Error(t, (&LengthPrefix{Width: 3}).Validate())
+	assert.Error(t, (&LengthPrefix{MaxRecordSize: -1}).Validate())
+}
+
+func TestTailReader_LengthPrefix_ResumesPartialRecord(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	lp := &LengthPrefix{Width: 2}
+	data := frame(*lp, []byte("one"), []byte("two"))
+	p := filepath.Join(t.TempDir(), "journal.bin")
+	// Write the second record only partially
+	assert.NoError(t, os.WriteFile(p, data[:len(data)-1], 0644))
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n", LengthPrefix: lp}
+	var got []string
+	assert.NoError(t, reader.ReadOnceBytes(func(b []byte) { got = append(got, string(b)) }))
+	assert.Equal(t, []string{"one"}, got)
+	assert.Equal(t, int64(2+len("one")), reader.Offset)
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.Write(data[len(data)-1:])
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	got = nil
+	assert.NoError(t, reader.ReadOnceBytes(func(b []byte) { got = append(got, string(b)) }))
+	assert.Equal(t, []string{"two"}, got)
+	assert.Equal(t, int64(len(data)), reader.Offset)
+}
+
+func TestTailReader_LengthPrefix_CorruptPrefix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	lp := &LengthPrefix{Width: 4, MaxRecordSize: 16}
+	data := frame(*lp, []byte("one"))
+	data = append(data, 0xff, 0xff, 0xff, 0xff, 'j', 'u', 'n', 'k')
+	p := filepath.Join(t.TempDir(), "journal.bin")
+	assert.NoError(t, os.WriteFile(p, data, 0644))
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n", LengthPrefix: lp}
+	var got []string
+	err = reader.ReadOnceBytes(func(b []byte) { got = append(got, string(b)) })
+	assert.ErrorIs(t, err, ErrRecordTooLarge)
+	assert.Equal(t, []string{"one"}, got)
+	assert.Equal(t, int64(len(data)), reader.Offset)
+
+	// The error is reported once; records appended after the skipped data are read
+	got = nil
+	assert.NoError(t, reader.ReadOnceBytes(func(b []byte) { got = append(got, string(b)) }))
+	assert.Empty(t, got)
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.Write(frame(*lp, []byte("two")))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.NoError(t, reader.ReadOnceBytes(func(b []byte) { got = append(got, string(b)) }))
+	assert.Equal(t, []string{"two"}, got)
+}
+
+func TestTailReader_LengthPrefix_MultilineKeepsPartialRecord(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	lp := &LengthPrefix{Width: 2}
+	data := frame(*lp, []byte("one"), []byte("two"))
+	p := filepath.Join(t.TempDir(), "journal.bin")
+	assert.NoError(t, os.WriteFile(p, data[:2+len("one")], 0644))
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	ml := &MultilineReader{Mode: MultilineReaderModeContinuePast, StartPattern: `^\S`, ConditionPattern: `^\s`, Timeout: time.Second}
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n", LengthPrefix: lp, Multiline: ml}
+	assert.NoError(t, reader.ReadOnceBytes(func([]byte) {}))
+	offset := reader.Offset
+
+	// Append half of the second record: the residual must not be delivered or committed
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.Write(data[2+len("one") : len(data)-2])
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	var got []string
+	assert.NoError(t, reader.ReadOnceBytes(func(b []byte) { got = append(got, string(b)) }))
+	assert.Empty(t, got)
+	assert.Equal(t, offset, reader.Offset)
+}
@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"sync"
 	"time"
+
+	"github.com/loykin/freader/internal/clock"
 )
 
 const (
@@ -19,12 +21,19 @@ type MultilineReader struct {
 	ConditionPattern string // e.g. "^\\s" for indented lines, or "^(INFO|ERROR)" for boundaries
 	StartPattern     string // start of a multiline record; if set, only lines matching this begin accumulation
 	Timeout          time.Duration
+	// Clock drives the Timeout flush; nil uses the real clock. Set it before first use.
+	Clock clock.Clock
+	// OnTimeout, if set, is called after the Timeout flushed a record into the queue,
+	// so a reader waiting for new data can wake up and Read it. It must not block.
+	OnTimeout func()
 
 	re      *regexp.Regexp // compiled condition pattern
 	startRe *regexp.Regexp // compiled start pattern
 	buf     []byte         // current assembling record (without trailing separator)
 	queue   [][]byte       // ready records to be Read()
 	last    time.Time      // last time buf was updated
+	starts  uint64         // number of records begun in buf; see begun
+	lines   int            // number of lines in buf; see Depth
 
 	// channel-based delivery
 	outCh   chan []byte
@@ -69,6 +78,13 @@ func (m *MultilineReader) init() error {
 	return nil
 }
 
+func (m *MultilineReader) clock() clock.Clock {
+	if m.Clock != nil {
+		return m.Clock
+	}
+	return clock.Real()
+}
+
 // start initializes the output channel and a background goroutine that
 // periodically checks for Timeout to flush the current buffer.
 func (m *MultilineReader) start() {
@@ -81,24 +97,27 @@ func (m *MultilineReader) start() {
 	m.stopCh = make(chan struct{})
 	m.started = true
 	go func() {
-		// Use a ticker granularity smaller than Timeout
-		interval := m.Timeout / 4
+		// Use a ticker granularity well below Timeout, so records are flushed close to it
+		interval := m.Timeout / 10
 		if interval <= 0 {
 			interval = m.Timeout
 		}
-		ticker := time.NewTicker(interval)
+		ticker := m.clock().NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-m.stopCh:
 				return
-			case <-ticker.C:
+			case <-ticker.C():
+				flushed := false
 				m.mu.Lock()
-				if len(m.buf) > 0 && !m.last.IsZero() && time.Since(m.last) >= m.Timeout {
+				if len(m.buf) > 0 && !m.last.IsZero() && m.clock().Since(m.last) >= m.Timeout {
 					// flush due to timeout
 					rec := append([]byte(nil), m.buf...)
 					m.queue = append(m.queue, rec)
 					m.buf = nil
+					m.lines = 0
+					flushed = true
 					// non-blocking send
 					if m.outCh != nil {
 						select {
@@ -107,7 +126,11 @@ func (m *MultilineReader) start() {
 						}
 					}
 				}
+				onTimeout := m.OnTimeout
 				m.mu.Unlock()
+				if flushed && onTimeout != nil {
+					onTimeout()
+				}
 			}
 		}
 	}()
@@ -147,8 +170,7 @@ func (m *MultilineReader) Write(b []byte) error {
 	if len(m.buf) == 0 {
 		if m.startRe != nil {
 			if m.startRe.Match(line) {
-				m.buf = line
-				m.last = time.Now()
+				m.beginLocked(line)
 				return nil
 			}
 			// Not a start line: emit as a standalone record and publish to channel
@@ -163,8 +185,7 @@ func (m *MultilineReader) Write(b []byte) error {
 			return nil
 		}
 		// No start pattern configured; start with incoming line
-		m.buf = line
-		m.last = time.Now()
+		m.beginLocked(line)
 		return nil
 	}
 
@@ -178,26 +199,25 @@ func (m *MultilineReader) Write(b []byte) error {
 		// If line matches condition => keep accumulating.
 		// If it does NOT match => include it to current and emit the record (past the condition), start new buffer empty.
 		if matches {
-			m.buf = appendWithNL(m.buf, line)
-			m.last = time.Now()
+			m.appendLocked(line)
+			m.last = m.clock().Now()
 			return nil
 		}
-		m.buf = appendWithNL(m.buf, line)
+		m.appendLocked(line)
 		m.enqueueAndResetLocked()
 		return nil
 
 	case MultilineReaderModeContinueThrough:
 		// If line matches => keep accumulating; if not => emit current, then start new if StartPattern allows, else emit as single
 		if matches {
-			m.buf = appendWithNL(m.buf, line)
-			m.last = time.Now()
+			m.appendLocked(line)
+			m.last = m.clock().Now()
 			return nil
 		}
 		m.enqueueAndResetLocked()
 		if m.startRe != nil {
 			if m.startRe.Match(line) {
-				m.buf = line
-				m.last = time.Now()
+				m.beginLocked(line)
 				return nil
 			}
 			// Not a start line; emit it as standalone and publish to channel
@@ -211,8 +231,7 @@ func (m *MultilineReader) Write(b []byte) error {
 			}
 			return nil
 		}
-		m.buf = line
-		m.last = time.Now()
+		m.beginLocked(line)
 		return nil
 
 	case MultilineReaderModeHaltBefore:
@@ -221,8 +240,7 @@ func (m *MultilineReader) Write(b []byte) error {
 			m.enqueueAndResetLocked()
 			if m.startRe != nil {
 				if m.startRe.Match(line) {
-					m.buf = line
-					m.last = time.Now()
+					m.beginLocked(line)
 					return nil
 				}
 				// Not a start line; emit as standalone and keep buffer empty, and publish to channel
@@ -236,29 +254,27 @@ func (m *MultilineReader) Write(b []byte) error {
 				}
 				return nil
 			}
-			m.buf = line
-			m.last = time.Now()
+			m.beginLocked(line)
 			return nil
 		}
-		m.buf = appendWithNL(m.buf, line)
-		m.last = time.Now()
+		m.appendLocked(line)
+		m.last = m.clock().Now()
 		return nil
 
 	case MultilineReaderModeHaltWith:
 		// When condition matches, include this line in previous and emit.
 		if matches {
-			m.buf = appendWithNL(m.buf, line)
+			m.appendLocked(line)
 			m.enqueueAndResetLocked()
 			return nil
 		}
-		m.buf = appendWithNL(m.buf, line)
-		m.last = time.Now()
+		m.appendLocked(line)
+		m.last = m.clock().Now()
 		return nil
 	default:
 		// If mode is empty/unknown, default: no multiline, simply emit previous and make this line current
 		m.enqueueAndResetLocked()
-		m.buf = line
-		m.last = time.Now()
+		m.beginLocked(line)
 		return nil
 	}
 }
@@ -284,6 +300,55 @@ func (m *MultilineReader) Flush() {
 	}
 }
 
+// Clone returns a reader with the same configuration and no buffered state, so each
+// file can assemble its records independently.
+func (m *MultilineReader) Clone() *MultilineReader {
+	return &MultilineReader{
+		Mode:             m.Mode,
+		ConditionPattern: m.ConditionPattern,
+		StartPattern:     m.StartPattern,
+		Timeout:          m.Timeout,
+		Clock:            m.Clock,
+		OnTimeout:        m.OnTimeout,
+	}
+}
+
+// Pending reports whether a record is being assembled, i.e. lines were written that
+// neither a following line nor the Timeout has completed yet.
+func (m *MultilineReader) Pending() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buf) > 0
+}
+
+// Depth returns the number of lines held in the record being assembled, 0 if none.
+func (m *MultilineReader) Depth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lines
+}
+
+// begun returns a counter that changes whenever a new record starts being assembled.
+func (m *MultilineReader) begun() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.starts
+}
+
+// beginLocked starts assembling a new record with line.
+func (m *MultilineReader) beginLocked(line []byte) {
+	m.buf = line
+	m.lines = 1
+	m.last = m.clock().Now()
+	m.starts++
+}
+
+// appendLocked adds line to the record being assembled.
+func (m *MultilineReader) appendLocked(line []byte) {
+	m.buf = appendWithNL(m.buf, line)
+	m.lines++
+}
+
 func (m *MultilineReader) enqueueAndResetLocked() {
 	if len(m.buf) == 0 {
 		return
@@ -291,6 +356,7 @@ func (m *MultilineReader) enqueueAndResetLocked() {
 	rec := append([]byte(nil), m.buf...)
 	m.queue = append(m.queue, rec)
 	m.buf = nil
+	m.lines = 0
 	if m.outCh != nil {
 		select {
 		case m.outCh <- rec:
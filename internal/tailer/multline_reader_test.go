@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/loykin/freader/pkg/testkit"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -254,3 +255,68 @@ func TestMultilineReader_ChannelTimeout(t *testing.T) {
 		t.Fatalf("did not receive timeout-flushed record")
 	}
 }
+
+// With a fake clock the timeout flush happens exactly when the clock passes Timeout.
+func TestMultilineReader_TimeoutWithFakeClock(t *testing.T) {
+	clk := testkit.NewFakeClock(time.Time{})
+	m := &MultilineReader{
+		Mode:             MultilineReaderModeContinueThrough,
+		StartPattern:     "^(ERROR|INFO)",
+		ConditionPattern: "^\\s",
+		Timeout:          time.Minute,
+		Clock:            clk,
+	}
+	timedOut := make(chan struct{}, 1)
+	m.OnTimeout = func() { timedOut <- struct{}{} }
+	ch := m.Recv()
+	defer m.Close()
+	assert.True(t, clk.BlockUntil(1, time.Second), "timeout ticker not started")
+
+	assert.NoError(t, m.Write([]byte("ERROR start")))
+	assert.NoError(t, m.Write([]byte("  detail1")))
+	clk.Advance(59 * time.Second)
+	select {
+	case rec := <-ch:
+		t.Fatalf("flushed %q before the timeout", rec)
+	default:
+	}
+
+	clk.Advance(time.Second)
+	select {
+	case rec := <-ch:
+		assert.Equal(t, "ERROR start\n  detail1", string(rec))
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not receive timeout-flushed record")
+	}
+	select {
+	case <-timedOut:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnTimeout was not called")
+	}
+}
+
+// Depth counts the lines of the record being assembled, and a clone starts empty.
+func TestMultilineReader_Depth(t *testing.T) {
+	m := &MultilineReader{Mode: MultilineReaderModeContinueThrough, ConditionPattern: "^\\s", StartPattern: "^(ERROR|INFO)", Timeout: time.Minute}
+	defer m.Close()
+	assert.Equal(t, 0, m.Depth())
+	assert.NoError(t, m.Write([]byte("ERROR start")))
+	assert.NoError(t, m.Write([]byte("  detail1")))
+	assert.NoError(t, m.Write([]byte("  detail2")))
+	assert.Equal(t, 3, m.Depth())
+
+	c := m.Clone()
+	defer c.Close()
+	assert.Equal(t, 0, c.Depth())
+	assert.NoError(t, c.Write([]byte("INFO other")))
+	assert.Equal(t, 1, c.Depth())
+	assert.Equal(t, 3, m.Depth())
+
+	assert.NoError(t, m.Write([]byte("INFO next")))
+	assert.Equal(t, 1, m.Depth())
+	m.Flush()
+	assert.Equal(t, 0, m.Depth())
+	rec, err := m.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "ERROR start\n  detail1\n  detail2", string(rec))
+}
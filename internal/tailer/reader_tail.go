@@ -0,0 +1,184 @@
+package tailer
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ReaderTail splits an arbitrary stream (network connection, decompression reader, pipe)
+// into records using the same separator and multiline rules as TailReader.
+// Unlike a file tail, the end of the stream is final: a trailing record without a
+// separator is emitted at io.EOF instead of waiting for more data.
+type ReaderTail struct {
+	reader    *bufio.Reader
+	readSize  int
+	separator []byte
+	split     recordSplitter
+	binary    bool // length-prefixed framing: a partial record at EOF is an error
+	multiline *MultilineReader
+	buf       []byte
+	offset    int64
+	start     int64 // stream offset of the last record returned without multiline
+	eof       bool
+}
+
+// ReaderTailOption configures a ReaderTail.
+type ReaderTailOption func(*ReaderTail)
+
+// WithSeparator sets the record separator (default "\n"). Multi-byte separators are supported.
+func WithSeparator(sep string) ReaderTailOption {
+	return func(t *ReaderTail) {
+		if sep != "" {
+			t.separator = []byte(sep)
+		}
+	}
+}
+
+// WithSeparatorRegex splits records on the matches of re instead of a fixed
+// separator; see TailReader.SeparatorRegex and CompileSeparatorRegex.
+func WithSeparatorRegex(re *regexp.Regexp) ReaderTailOption {
+	return func(t *ReaderTail) {
+		if re != nil && !t.binary {
+			t.split = newRecordSplitter(re, nil)
+		}
+	}
+}
+
+// WithLengthPrefix reads length-prefixed binary records instead of splitting on
+// separators; see TailReader.LengthPrefix. A truncated record at the end of the
+// stream is reported as io.ErrUnexpectedEOF, and a prefix over the max record size
+// ends the stream with ErrRecordTooLarge.
+func WithLengthPrefix(lp *LengthPrefix) ReaderTailOption {
+	return func(t *ReaderTail) {
+		if lp != nil {
+			t.split = newRecordSplitter(nil, lp)
+			t.binary = true
+		}
+	}
+}
+
+// WithMultiline groups physical lines into logical records using m.
+// m must not be shared with another reader.
+func WithMultiline(m *MultilineReader) ReaderTailOption {
+	return func(t *ReaderTail) {
+		t.multiline = m
+	}
+}
+
+// WithReadBufferSize sets the size of the buffered reader wrapped around the stream
+// (default 4KB); see TailReader.ReadBufferSize.
+func WithReadBufferSize(n int) ReaderTailOption {
+	return func(t *ReaderTail) {
+		t.readSize = n
+	}
+}
+
+// NewReaderTail returns a ReaderTail reading records from r.
+func NewReaderTail(r io.Reader, opts ...ReaderTailOption) *ReaderTail {
+	t := &ReaderTail{separator: []byte("\n")}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.readSize > 0 {
+		t.reader = bufio.NewReaderSize(r, t.readSize)
+	} else {
+		t.reader = bufio.NewReader(r)
+	}
+	return t
+}
+
+// Offset returns the number of stream bytes consumed by the records returned so far.
+func (t *ReaderTail) Offset() int64 {
+	return t.offset
+}
+
+// RecordOffset returns the stream offset at which the record last returned by Next
+// starts. It is not tracked with multiline grouping.
+func (t *ReaderTail) RecordOffset() int64 {
+	return t.start
+}
+
+// Next returns the next record without its separator. It returns io.EOF once the
+// stream is exhausted and every buffered record has been returned; other read errors
+// are returned as-is. Empty lines are skipped unless multiline grouping is enabled.
+func (t *ReaderTail) Next() (string, error) {
+	for {
+		if t.multiline != nil {
+			if rec, err := t.multiline.Read(); err == nil {
+				return string(rec), nil
+			}
+		}
+		if t.eof {
+			return "", io.EOF
+		}
+
+		chunk, line, err := t.nextChunk()
+		if err == io.EOF {
+			t.eof = true
+			residual := t.buf
+			t.buf = nil
+			if t.binary && len(residual) > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			t.start = t.offset
+			t.offset += int64(len(residual))
+			if t.multiline != nil {
+				if len(residual) > 0 {
+					_ = t.multiline.Write(residual)
+				}
+				t.multiline.Flush()
+				continue
+			}
+			if len(residual) > 0 {
+				return string(residual), nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			if errors.Is(err, ErrRecordTooLarge) {
+				// The rest of the stream cannot be framed
+				t.eof = true
+				t.buf = nil
+			}
+			return "", err
+		}
+
+		t.start = t.offset
+		t.offset += int64(len(chunk))
+		if t.multiline != nil {
+			_ = t.multiline.Write(line)
+			continue
+		}
+		if len(line) > 0 {
+			return string(line), nil
+		}
+	}
+}
+
+func (t *ReaderTail) nextChunk() (chunk, line []byte, err error) {
+	if t.split != nil {
+		return t.split.next(t.reader, &t.buf)
+	}
+	chunk, err = nextChunk(t.reader, &t.buf, t.separator)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chunk, chunk[:len(chunk)-len(t.separator)], nil
+}
+
+// Run calls callback for every record until the stream ends. It returns nil at
+// io.EOF and the read error otherwise.
+func (t *ReaderTail) Run(callback func(string)) error {
+	for {
+		rec, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		callback(rec)
+	}
+}
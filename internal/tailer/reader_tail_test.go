@@ -0,0 +1,113 @@
+package tailer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderTail_Separators(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []ReaderTailOption
+		want  []string
+	}{
+		{name: "default newline", input: "a\nb\n\nc\n", want: []string{"a", "b", "c"}},
+		{name: "trailing record without separator", input: "a\nb", want: []string{"a", "b"}},
+		{name: "crlf", input: "a\r\nb\r\n", opts: []ReaderTailOption{WithSeparator("\r\n")}, want: []string{"a", "b"}},
+		{name: "token", input: "x<END>y<END>z", opts: []ReaderTailOption{WithSeparator("<END>")}, want: []string{"x", "y", "z"}},
+		{name: "empty", input: "", want: nil},
+		{name: "nul", input: "./a b\x00./c\nd\x00", opts: []ReaderTailOption{WithSeparator("\x00")}, want: []string{"./a b", "./c\nd"}},
+		{name: "regex mixed endings", input: "a\r\nb\nc\r\n", opts: []ReaderTailOption{WithSeparatorRegex(regexp.MustCompile(`\r?\n`))}, want: []string{"a", "b", "c"}},
+		{name: "regex variable token", input: "x<END>y<END-2>z", opts: []ReaderTailOption{WithSeparatorRegex(regexp.MustCompile(`<END(-\d+)?>`))}, want: []string{"x", "y", "z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewReaderTail(strings.NewReader(tt.input), tt.opts...)
+			var got []string
+			assert.NoError(t, rt.Run(func(s string) { got = append(got, s) }))
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, int64(len(tt.input)), rt.Offset())
+
+			_, err := rt.Next()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestReaderTail_RecordOffset(t *testing.T) {
+	rt := NewReaderTail(strings.NewReader("ab\n\ncd\nef"))
+	var offsets []int64
+	assert.NoError(t, rt.Run(func(string) { offsets = append(offsets, rt.RecordOffset()) }))
+	assert.Equal(t, []int64{0, 4, 7}, offsets)
+
+	rt = NewReaderTail(strings.NewReader("x<END>yy<END>z"), WithSeparator("<END>"))
+	offsets = nil
+	assert.NoError(t, rt.Run(func(string) { offsets = append(offsets, rt.RecordOffset()) }))
+	assert.Equal(t, []int64{0, 6, 13}, offsets)
+}
+
+func TestReaderTail_MultilineOverGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err := zw.Write([]byte("ERROR boom\n  at a\n  at b\nINFO ok\n  cont"))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	zr, err := gzip.NewReader(&compressed)
+	assert.NoError(t, err)
+	ml := &MultilineReader{Mode: MultilineReaderModeContinueThrough, StartPattern: "^(ERROR|INFO)", ConditionPattern: "^\\s", Timeout: time.Second}
+	rt := NewReaderTail(zr, WithMultiline(ml))
+
+	var got []string
+	assert.NoError(t, rt.Run(func(s string) { got = append(got, s) }))
+	assert.Equal(t, []string{"ERROR boom\n  at a\n  at b", "INFO ok\n  cont"}, got)
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestReaderTail_ReadError(t *testing.T) {
+	boom := errors.New("connection reset")
+	rt := NewReaderTail(io.MultiReader(strings.NewReader("a\n"), failingReader{boom}))
+
+	rec, err := rt.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", rec)
+	_, err = rt.Next()
+	assert.ErrorIs(t, err, boom)
+}
+
+func TestReaderTail_RecordLongerThanReadBuffer(t *testing.T) {
+	long := strings.Repeat("x", 10000) // larger than the default bufio buffer
+	rt := NewReaderTail(strings.NewReader(long + "\nshort\n"))
+	var got []string
+	assert.NoError(t, rt.Run(func(s string) { got = append(got, s) }))
+	assert.Equal(t, []string{long, "short"}, got)
+}
+
+func TestAlternativeSeparatorsRegex(t *testing.T) {
+	re, err := AlternativeSeparatorsRegex([]string{"\r\n", "\n", "<END>"})
+	assert.NoError(t, err)
+	input := "a\r\nb\nc<END>d.e\r\n"
+	rt := NewReaderTail(strings.NewReader(input), WithSeparatorRegex(re))
+	var got []string
+	assert.NoError(t, rt.Run(func(s string) { got = append(got, s) }))
+	// "\r\n" is listed before "\n", so no record keeps a trailing "\r"
+	assert.Equal(t, []string{"a", "b", "c", "d.e"}, got)
+	assert.Equal(t, int64(len(input)), rt.Offset())
+
+	_, err = AlternativeSeparatorsRegex(nil)
+	assert.Error(t, err)
+	_, err = AlternativeSeparatorsRegex([]string{"\n", ""})
+	assert.Error(t, err)
+}
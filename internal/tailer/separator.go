@@ -0,0 +1,109 @@
+package tailer
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// CompileSeparatorRegex compiles a record separator pattern such as `\r?\n`. Patterns
+// that can match the empty string are rejected since they would split nowhere.
+func CompileSeparatorRegex(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString("") {
+		return nil, errors.New("separator regex must not match the empty string: " + expr)
+	}
+	return re, nil
+}
+
+// AlternativeSeparatorsRegex returns a separator regex matching any of seps. Records
+// end at the earliest separator in the data; when several start at the same byte the
+// one listed first wins, so list "\r\n" before "\n".
+func AlternativeSeparatorsRegex(seps []string) (*regexp.Regexp, error) {
+	if len(seps) == 0 {
+		return nil, errors.New("separator list must not be empty")
+	}
+	quoted := make([]string, len(seps))
+	for i, sep := range seps {
+		if sep == "" {
+			return nil, errors.New("separators must not be empty")
+		}
+		quoted[i] = regexp.QuoteMeta(sep)
+	}
+	return CompileSeparatorRegex(strings.Join(quoted, "|"))
+}
+
+// recordSplitter frames records for readers that do not split on a literal separator.
+type recordSplitter interface {
+	// next returns the next record (line) and the bytes it occupies including its
+	// framing (chunk), reading from r into *buf as needed. Both slices stay valid until
+	// the next call. Incomplete data stays buffered and io.EOF is returned.
+	next(r *bufio.Reader, buf *[]byte) (chunk, line []byte, err error)
+}
+
+// newRecordSplitter returns the splitter for the given framing, or nil when records
+// are split on a literal separator.
+func newRecordSplitter(re *regexp.Regexp, lp *LengthPrefix) recordSplitter {
+	switch {
+	case lp != nil:
+		return &lengthPrefixSplitter{lp: lp}
+	case re != nil:
+		return &regexSplitter{re: re}
+	default:
+		return nil
+	}
+}
+
+// regexSplitter splits buffered data on the matches of a separator regex. A match is
+// taken as soon as it is complete in the data read so far, so separators should not
+// be able to grow with more input (prefer `\r?\n` over `\n+`).
+type regexSplitter struct {
+	re *regexp.Regexp
+	// consumed is the length of the chunk returned by the previous call, still at the
+	// front of buf so that the returned slices stay valid until the next call.
+	consumed int
+}
+
+func (s *regexSplitter) next(r *bufio.Reader, buf *[]byte) (chunk, line []byte, err error) {
+	discard(buf, &s.consumed)
+	for {
+		if loc := s.re.FindIndex(*buf); loc != nil {
+			s.consumed = loc[1]
+			return (*buf)[:loc[1]], (*buf)[:loc[0]], nil
+		}
+		if err := fill(r, buf); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// discard drops the first *consumed bytes of *buf and resets *consumed.
+func discard(buf *[]byte, consumed *int) {
+	if *consumed > 0 {
+		n := copy(*buf, (*buf)[*consumed:])
+		*buf = (*buf)[:n]
+		*consumed = 0
+	}
+}
+
+// fill appends the next read from r to *buf, growing it when it is full.
+func fill(r *bufio.Reader, buf *[]byte) error {
+	if len(*buf) == cap(*buf) {
+		*buf = append(*buf, 0)[:len(*buf)]
+	}
+	n, err := r.Read((*buf)[len(*buf):cap(*buf)])
+	*buf = (*buf)[:len(*buf)+n]
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		// No progress without an error; report EOF so callers retry later
+		return io.EOF
+	}
+	return err
+}
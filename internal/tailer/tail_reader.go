@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"regexp"
 	"sync"
 	"time"
 
@@ -14,29 +16,157 @@ import (
 	"github.com/loykin/freader/internal/watcher"
 )
 
+// DefaultChunkBufferSize is the initial capacity of the pooled record buffer.
+const DefaultChunkBufferSize = 4096
+
 // bufferPool is a global pool for reusing byte slices to reduce memory allocations
 var bufferPool = sync.Pool{
 	New: func() interface{} {
 		// Start with a reasonable buffer size (4KB)
-		buf := make([]byte, 0, 4096)
+		buf := make([]byte, 0, DefaultChunkBufferSize)
 		return &buf
 	},
 }
 
+// getChunkBuffer returns an empty pooled buffer with at least minCap capacity.
+func getChunkBuffer(minCap int) []byte {
+	bufPtr := bufferPool.Get().(*[]byte)
+	buf := (*bufPtr)[:0] // reset length but keep capacity
+	if cap(buf) < minCap {
+		bufferPool.Put(bufPtr)
+		buf = make([]byte, 0, minCap)
+	}
+	return buf
+}
+
 type TailReader struct {
 	FileId    string
 	Offset    int64
 	Separator string
+	// SeparatorRegex, if set, splits records on its matches instead of Separator
+	// (e.g. `\r?\n` for mixed line endings); Offset advances by the actual match
+	// length. See CompileSeparatorRegex. Separator is still used for the
+	// checksumSeparator fingerprint.
+	SeparatorRegex *regexp.Regexp
+	// LengthPrefix, if set, reads length-prefixed binary records instead of splitting
+	// on separators; it takes precedence over SeparatorRegex. Empty records are skipped.
+	// A partial record at the end of the file is never delivered, even with Multiline.
+	// A prefix over MaxRecordSize fails one read with ErrRecordTooLarge and skips the
+	// rest of the file as written so far; see skipCorrupt.
+	LengthPrefix *LengthPrefix
 	// Optional multiline aggregator; if set, physical lines are grouped into logical records.
 	Multiline *MultilineReader
+	// HoldMultiline keeps a multiline record still being assembled at the end of the
+	// file for the next ReadOnce instead of flushing it, so records written in several
+	// bursts are not split. The record is delivered once a following line or the
+	// Multiline timeout completes it, or by FlushMultiline; SafeOffset tells where it
+	// starts.
+	HoldMultiline bool
+	// Logger receives the reader's log output; nil uses slog.Default().
+	Logger *slog.Logger
+	// ReadBufferSize is the size of the buffered file reader, i.e. the largest read
+	// per syscall; 0 uses the bufio default (4KB).
+	ReadBufferSize int
+	// ChunkBufferSize is the initial capacity of the buffer records are assembled in;
+	// records larger than it grow the buffer. 0 uses DefaultChunkBufferSize.
+	ChunkBufferSize int
+	// Throttle, if set, is called with the number of bytes after every read from the
+	// file and may block to limit the read rate.
+	Throttle func(n int)
+	// ResetOnTruncate reads the file from the start again when it is found shorter than
+	// Offset, e.g. truncated in place by copytruncate, instead of waiting for it to grow
+	// past Offset.
+	ResetOnTruncate bool
+	// KeepOpen keeps the file open between reads. Once it is no longer at its path,
+	// e.g. deleted while its writer still has it open, reads carry on through the open
+	// file (see Deleted) instead of failing, until ReleaseFile closes it.
+	KeepOpen bool
 	// mu protects access to stopCh and doneCh to avoid data races between Run and Stop
 	mu          sync.Mutex
 	stopCh      chan struct{}
 	doneCh      chan struct{}
 	FileManager *file_tracker.FileTracker
 	file        *os.File
+	held        *os.File // the file kept open by KeepOpen
+	deleted     bool     // reads go to held as the file is no longer at its path
 	reader      *bufio.Reader
-	buf         []byte // internal buffer across reads for multi-byte separators
+	buf         []byte         // internal buffer across reads for multi-byte separators
+	split       recordSplitter // set by open when SeparatorRegex or LengthPrefix is used
+	pending     int64          // offset of the first line of the held multiline record; valid if holding
+	holding     bool           // a held multiline record or timeout-flushed records await delivery
+}
+
+// SafeOffset returns the offset up to which every record has been delivered: Offset,
+// or the start of the multiline record held by HoldMultiline. Persisting it instead of
+// Offset re-reads a held record after a crash rather than losing it.
+func (t *TailReader) SafeOffset() int64 {
+	if t.holding && t.pending < t.Offset {
+		return t.pending
+	}
+	return t.Offset
+}
+
+// Rewind moves the reader back to offset, at or before SafeOffset, so the records from
+// there are read again, e.g. after their delivery failed. The multiline record being
+// assembled is dropped, as its lines are read again too. It must not be called during
+// a read.
+func (t *TailReader) Rewind(offset int64) {
+	if t.Multiline != nil {
+		t.Multiline.Close()
+		t.Multiline = t.Multiline.Clone()
+	}
+	t.holding = false
+	t.Offset = offset
+}
+
+// FlushMultiline delivers the records the Multiline timeout completed and then the
+// record still being assembled, if any, with forced set; it returns the number of
+// records delivered. Call it once no more lines will be read for the file, e.g. when
+// it is removed or reading stops.
+func (t *TailReader) FlushMultiline(callback func(rec []byte, forced bool)) int {
+	if t.Multiline == nil {
+		return 0
+	}
+	n := t.drainMultiline(func(rec []byte) { callback(rec, false) })
+	t.Multiline.Flush()
+	n += t.drainMultiline(func(rec []byte) { callback(rec, true) })
+	t.holding = false
+	return n
+}
+
+// drainMultiline delivers the completed multiline records and returns how many.
+func (t *TailReader) drainMultiline(callback func([]byte)) int {
+	n := 0
+	for {
+		rec, err := t.Multiline.Read()
+		if err != nil {
+			return n
+		}
+		callback(rec)
+		n++
+	}
+}
+
+// writeMultiline feeds the line starting at offset start into the multiline aggregator,
+// delivers the records it completes and tracks where the record still held starts.
+func (t *TailReader) writeMultiline(line []byte, start int64, callback func([]byte)) {
+	begun := t.Multiline.begun()
+	_ = t.Multiline.Write(line)
+	t.drainMultiline(callback)
+	switch {
+	case !t.Multiline.Pending():
+		t.holding = false
+	case !t.holding || t.Multiline.begun() != begun:
+		t.holding = true
+		t.pending = start
+	}
+}
+
+func (t *TailReader) log() *slog.Logger {
+	if t.Logger != nil {
+		return t.Logger
+	}
+	return slog.Default()
 }
 
 func (t *TailReader) open() error {
@@ -44,27 +174,40 @@ func (t *TailReader) open() error {
 		return nil
 	}
 
+	if t.deleted {
+		return t.use(t.held)
+	}
 	fileInfo := t.FileManager.Get(t.FileId)
 	if fileInfo == nil {
+		if t.held != nil {
+			t.deleted = true
+			return t.use(t.held)
+		}
 		return errors.New("file not found: " + t.FileId)
 	}
 
-	file, err := os.Open(fileInfo.Path)
+	file, err := file_tracker.Open(fileInfo.Path)
 	if err != nil {
+		if os.IsNotExist(err) && t.held != nil {
+			t.log().Debug("file no longer at its path, reading on through the open file", "path", fileInfo.Path, "fileId", t.FileId)
+			t.deleted = true
+			return t.use(t.held)
+		}
 		return err
 	}
 
 	var fileId string
 	switch fileInfo.FingerprintStrategy {
 	case watcher.FingerprintStrategyChecksum:
-		fileId, err = file_tracker.GetFileFingerprint(file, fileInfo.FingerprintSize)
+		fileId, err = file_tracker.GetFileFingerprintRange(file, fileInfo.FingerprintOffset, fileInfo.FingerprintSize)
 		if err != nil {
 			// If file is too small for fingerprinting, it should have been skipped by watcher
 			// This can happen if file grew after initial scan
 			if file_tracker.IsFileSizeTooSmall(err) {
-				slog.Debug("file too small for fingerprinting",
+				t.log().Debug("file too small for fingerprinting",
 					"path", fileInfo.Path, "fileId", t.FileId, "error", err)
 			}
+			_ = file.Close()
 			return err
 		}
 	case watcher.FingerprintStrategyChecksumSeparator:
@@ -73,29 +216,33 @@ func (t *TailReader) open() error {
 			// If file doesn't have enough separators, it should have been skipped by watcher
 			// This can happen if file content changed after initial scan
 			if file_tracker.IsNotEnoughSeparators(err) {
-				slog.Debug("file has insufficient separators",
+				t.log().Debug("file has insufficient separators",
 					"path", fileInfo.Path, "fileId", t.FileId, "error", err)
 			}
+			_ = file.Close()
 			return err
 		}
 	case watcher.FingerprintStrategyDeviceAndInode:
 		stat, err := file.Stat()
 		if err != nil {
+			_ = file.Close()
 			return err
 		}
 
 		fileId, err = file_tracker.GetFileID(stat)
 		if err != nil {
+			_ = file.Close()
 			return err
 		}
 	default:
+		_ = file.Close()
 		return errors.New("unsupported fingerprint strategy: " + fileInfo.FingerprintStrategy)
 	}
 
-	if fileId != t.FileId {
+	if fileId != watcher.Fingerprint(t.FileId) {
 		// File content has changed (rotation, truncation, or overwrite)
 		// This is a normal scenario in dynamic environments
-		slog.Debug("file content changed, fingerprint mismatch",
+		t.log().Debug("file content changed, fingerprint mismatch",
 			"path", fileInfo.Path, "current_fingerprint", fileId, "tracked_fingerprint", t.FileId)
 		_ = file.Close()
 		return &FileFingerprintMismatchError{
@@ -105,47 +252,111 @@ func (t *TailReader) open() error {
 		}
 	}
 
-	_, err = file.Seek(t.Offset, io.SeekStart)
-	if err != nil {
+	if t.ResetOnTruncate && t.Offset > 0 {
+		stat, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		if stat.Size() < t.Offset {
+			t.log().Info("file truncated, reading from the start", "path", fileInfo.Path, "fileId", t.FileId,
+				"offset", t.Offset, "size", stat.Size())
+			t.Offset, t.pending = 0, 0
+		}
+	}
+
+	if err := t.use(file); err != nil {
 		_ = file.Close()
 		return err
 	}
+	if t.KeepOpen {
+		t.ReleaseFile()
+		t.held = file
+	}
+	return nil
+}
+
+// use makes file, positioned at Offset, the file read from.
+func (t *TailReader) use(file *os.File) error {
+	if _, err := file.Seek(t.Offset, io.SeekStart); err != nil {
+		return err
+	}
 
 	t.file = file
-	t.reader = bufio.NewReader(t.file)
+	var r io.Reader = t.file
+	if t.Throttle != nil {
+		r = throttledReader{r: r, throttle: t.Throttle}
+	}
+	if t.ReadBufferSize > 0 {
+		t.reader = bufio.NewReaderSize(r, t.ReadBufferSize)
+	} else {
+		t.reader = bufio.NewReader(r)
+	}
 
 	// Initialize buffer from pool if not already set
 	if t.buf == nil {
-		bufPtr := bufferPool.Get().(*[]byte)
-		t.buf = (*bufPtr)[:0] // reset length but keep capacity
+		t.buf = getChunkBuffer(t.ChunkBufferSize)
 	}
+	t.split = newRecordSplitter(t.SeparatorRegex, t.LengthPrefix)
 
 	return nil
 }
 
-func (t *TailReader) readNextChunk() ([]byte, error) {
-	sep := []byte(t.Separator)
+// throttledReader calls throttle after every read; see TailReader.Throttle.
+type throttledReader struct {
+	r        io.Reader
+	throttle func(n int)
+}
+
+func (tr throttledReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		tr.throttle(n)
+	}
+	return n, err
+}
+
+// readNextChunk returns the next record (line) and the bytes it occupies including
+// its separator (chunk).
+func (t *TailReader) readNextChunk(sep []byte) (chunk, line []byte, err error) {
+	if t.split != nil {
+		return t.split.next(t.reader, &t.buf)
+	}
+	chunk, err = nextChunk(t.reader, &t.buf, sep)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chunk, chunk[:len(chunk)-len(sep)], nil
+}
+
+// nextChunk returns the next chunk terminated by sep (separator included), reading
+// from r into *buf as needed. Incomplete data stays buffered and io.EOF is returned.
+func nextChunk(r *bufio.Reader, buf *[]byte, sep []byte) ([]byte, error) {
 	if len(sep) == 0 {
 		return nil, errors.New("separator must not be empty")
 	}
-	// Use internal buffer t.buf. Keep reading until we find sep or hit EOF.
+	// Keep reading until we find sep or hit EOF.
 	for {
 		// Search for separator in existing buffer
-		if idx := bytes.Index(t.buf, sep); idx >= 0 {
+		if idx := bytes.Index(*buf, sep); idx >= 0 {
 			end := idx + len(sep)
-			chunk := t.buf[:end]
+			chunk := (*buf)[:end]
 			// advance buffer efficiently using copy instead of allocating new slice
-			if end < len(t.buf) {
-				copy(t.buf, t.buf[end:])
-				t.buf = t.buf[:len(t.buf)-end]
+			if end < len(*buf) {
+				copy(*buf, (*buf)[end:])
+				*buf = (*buf)[:len(*buf)-end]
 			} else {
-				t.buf = t.buf[:0] // reset buffer if we consumed everything
+				*buf = (*buf)[:0] // reset buffer if we consumed everything
 			}
 			return chunk, nil
 		}
-		// Read more data
-		data, err := t.reader.ReadBytes(sep[len(sep)-1])
-		t.buf = append(t.buf, data...)
+		// Read more data; ReadSlice avoids an allocation per call, and a full bufio
+		// buffer just means the record is longer than it, so keep reading
+		data, err := r.ReadSlice(sep[len(sep)-1])
+		*buf = append(*buf, data...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
 		if err != nil {
 			if err == io.EOF {
 				// No complete separator in buffer; do not emit partial
@@ -161,13 +372,16 @@ func (t *TailReader) readLoop(callback func(string)) error {
 		return err
 	}
 	defer t.cleanup()
+	sep := []byte(t.Separator)
 
 	for {
 		select {
 		case <-t.stopCh:
+			// Deliver the record still being assembled rather than dropping it
+			t.FlushMultiline(func(rec []byte, _ bool) { callback(string(rec)) })
 			return nil
 		default:
-			chunk, err := t.readNextChunk()
+			chunk, line, err := t.readNextChunk(sep)
 			if err != nil {
 				if err == io.EOF {
 					// No new complete chunk. If multiline is enabled, drain any timeout-flushed records.
@@ -183,16 +397,18 @@ func (t *TailReader) readLoop(callback func(string)) error {
 					time.Sleep(500 * time.Millisecond)
 					continue
 				}
+				if errors.Is(err, ErrRecordTooLarge) {
+					t.log().Error("skipping unframed data", "file", t.FileId, "error", t.skipCorrupt(err))
+					t.cleanup()
+					if err := t.open(); err != nil {
+						return err
+					}
+					continue
+				}
 				return err
 			}
 
 			// Process chunk respecting multiline configuration
-			sep := []byte(t.Separator)
-			line := chunk
-			if len(chunk) >= len(sep) {
-				line = chunk[:len(chunk)-len(sep)]
-			}
-
 			if t.Multiline != nil {
 				_ = t.Multiline.Write(line)
 				for {
@@ -203,7 +419,7 @@ func (t *TailReader) readLoop(callback func(string)) error {
 					callback(string(rec))
 				}
 			} else {
-				if len(chunk) > len(sep) {
+				if len(line) > 0 {
 					callback(string(line))
 				}
 			}
@@ -214,64 +430,81 @@ func (t *TailReader) readLoop(callback func(string)) error {
 	}
 }
 
+// skipCorrupt moves Offset from a length prefix rejected with err to the current end
+// of the file: the records after it cannot be framed, and records appended later are
+// expected to start there. It returns err with the skipped range, so a corrupt prefix
+// is reported by one read only; Rewind or Collector.SeekFile read the range again,
+// e.g. with a larger LengthPrefix.MaxRecordSize.
+func (t *TailReader) skipCorrupt(err error) error {
+	stat, serr := t.file.Stat()
+	if serr != nil {
+		return err
+	}
+	from := t.Offset
+	t.Offset = stat.Size()
+	t.buf = t.buf[:0]
+	return fmt.Errorf("%w; skipped bytes %d to %d", err, from, t.Offset)
+}
+
 func (t *TailReader) ReadOnce(callback func(string)) error {
+	return t.ReadOnceBytes(func(b []byte) { callback(string(b)) })
+}
+
+// ReadOnceBytes is like ReadOnce but hands out records as byte slices that are only
+// valid until the callback returns; copy them to retain. It avoids the per-record
+// string allocation of ReadOnce.
+func (t *TailReader) ReadOnceBytes(callback func([]byte)) error {
 	if err := t.open(); err != nil {
 		return err
 	}
 	defer t.cleanup()
+	sep := []byte(t.Separator)
 
+	if t.Multiline != nil {
+		// Records the timeout flushed since the last read
+		t.drainMultiline(callback)
+		if !t.Multiline.Pending() {
+			t.holding = false
+		}
+	}
 	for {
-		chunk, err := t.readNextChunk()
+		chunk, line, err := t.readNextChunk(sep)
 		if err != nil {
 			if err == io.EOF {
 				// EOF for one-shot read. If there's residual data in our buffer (no trailing separator),
 				// account for it in the offset and deliver it appropriately.
 				// If multiline configured, flush residual aggregated record(s) and drain them.
+				// A length-prefixed residual is a partial record left for the next read.
 				if t.Multiline != nil {
-					var residual []byte
-					if len(t.buf) > 0 {
-						residual = append([]byte(nil), t.buf...)
+					if len(t.buf) > 0 && t.LengthPrefix == nil {
+						residual := append([]byte(nil), t.buf...)
 						// clear buffer as we're consuming it now
 						t.buf = nil
-						_ = t.Multiline.Write(residual)
+						t.writeMultiline(residual, t.Offset, callback)
 						// advance offset by the unread bytes we've buffered
 						t.Offset += int64(len(residual))
 					}
-					t.Multiline.Flush()
-					for {
-						rec, rerr := t.Multiline.Read()
-						if rerr != nil {
-							break
-						}
-						callback(string(rec))
+					if !t.HoldMultiline {
+						t.Multiline.Flush()
+						t.drainMultiline(callback)
+						t.holding = false
 					}
 				}
 				return nil
 			}
+			if errors.Is(err, ErrRecordTooLarge) {
+				return t.skipCorrupt(err)
+			}
 			return err
 		}
 
-		// multipline flush, get
-		sep := []byte(t.Separator)
-		line := chunk
-		if len(chunk) >= len(sep) {
-			line = chunk[:len(chunk)-len(sep)]
-		}
-
 		if t.Multiline != nil {
 			// Feed the physical line into the multiline aggregator and drain any ready records.
-			_ = t.Multiline.Write(line)
-			for {
-				rec, rerr := t.Multiline.Read()
-				if rerr != nil {
-					break
-				}
-				callback(string(rec))
-			}
+			t.writeMultiline(line, t.Offset, callback)
 		} else {
 			// If not using multiline, emit the single logical line when there is content beyond the separator.
-			if len(chunk) > len(sep) {
-				callback(string(line))
+			if len(line) > 0 {
+				callback(line)
 			}
 		}
 
@@ -291,7 +524,7 @@ func (t *TailReader) Run(callback func(string)) {
 	go func() {
 		defer close(localDone)
 		if err := t.readLoop(callback); err != nil {
-			slog.Error("failed to read file", "file", t.FileId, "error", err)
+			t.log().Error("failed to read file", "file", t.FileId, "error", err)
 			t.FileManager.Remove(t.FileId)
 		}
 	}()
@@ -326,16 +559,20 @@ func (t *TailReader) Stop() {
 
 func (t *TailReader) cleanup() {
 	if t.file != nil {
-		_ = t.file.Close()
+		if t.file != t.held {
+			_ = t.file.Close()
+		}
 		t.file = nil
 	}
 	t.reader = nil
+	t.split = nil
 
 	// Return buffer to pool for reuse instead of setting to nil
 	if t.buf != nil {
-		// Reset the buffer length but keep capacity for reuse
-		t.buf = t.buf[:0]
-		bufferPool.Put(&t.buf)
+		// Reset the buffer length but keep capacity for reuse; the pool gets its own
+		// slice header, as t.buf is cleared and may be reused by this reader
+		buf := t.buf[:0]
+		bufferPool.Put(&buf)
 		t.buf = nil
 	}
 }
@@ -343,3 +580,31 @@ func (t *TailReader) cleanup() {
 func (t *TailReader) Close() {
 	t.Stop()
 }
+
+// Deleted reports whether the file is read through the file KeepOpen kept open, as it
+// is no longer at its path.
+func (t *TailReader) Deleted() bool {
+	return t.deleted
+}
+
+// HeldSize returns the size of the file kept open by KeepOpen, or -1 if none is.
+func (t *TailReader) HeldSize() int64 {
+	if t.held == nil {
+		return -1
+	}
+	info, err := t.held.Stat()
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+// ReleaseFile closes the file kept open by KeepOpen, if any. It must not be called
+// during a read.
+func (t *TailReader) ReleaseFile() {
+	if t.held != nil {
+		_ = t.held.Close()
+		t.held = nil
+	}
+	t.deleted = false
+}
@@ -1,9 +1,11 @@
 package tailer
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/loykin/freader/internal/watcher"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTailReader_SeparatorsAndRestart(t *testing.T) {
@@ -273,6 +276,8 @@ func TestTailReader_OpenWithChecksum_SuccessAndMismatch(t *testing.T) {
 	reader2 := &TailReader{FileId: wrongId, FileManager: tr2, Separator: "\n"}
 	err = reader2.ReadOnce(func(s string) {})
 	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrFingerprintMismatch)
+	assert.True(t, IsFileFingerprintMismatch(fmt.Errorf("wrapped: %w", err)))
 }
 
 // New tests for offset correctness and multiline configuration via TailReader
@@ -309,6 +314,99 @@ func TestTailReader_Multiline_EOFResidual_OffsetAndRecords(t *testing.T) {
 	assert.Equal(t, int64(len(content)), reader.Offset)
 }
 
+func TestTailReader_HoldMultiline_AcrossReadsAndFlush(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	base := t.TempDir()
+	p := filepath.Join(base, "ml_hold.txt")
+	first := "INFO ok\nERROR boom\n  at a\n"
+	assert.NoError(t, os.WriteFile(p, []byte(first), 0644))
+
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	ml := &MultilineReader{
+		Mode:             MultilineReaderModeContinueThrough,
+		StartPattern:     "^(ERROR|INFO)",
+		ConditionPattern: "^\\s",
+		Timeout:          time.Hour,
+	}
+	defer ml.Close()
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n", Multiline: ml, HoldMultiline: true}
+	var out []string
+	assert.NoError(t, reader.ReadOnce(func(s string) { out = append(out, s) }))
+	// The trace may continue, so it is held and the safe offset stays at its start
+	assert.Equal(t, []string{"INFO ok"}, out)
+	assert.Equal(t, int64(len(first)), reader.Offset)
+	assert.Equal(t, int64(len("INFO ok\n")), reader.SafeOffset())
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("  at b\nINFO next\nERROR tail\n  at c\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	assert.NoError(t, reader.ReadOnce(func(s string) { out = append(out, s) }))
+	assert.Equal(t, []string{"INFO ok", "ERROR boom\n  at a\n  at b", "INFO next"}, out)
+	assert.Equal(t, int64(len(first+"  at b\nINFO next\n")), reader.SafeOffset())
+
+	var forced []bool
+	n := reader.FlushMultiline(func(rec []byte, f bool) {
+		out = append(out, string(rec))
+		forced = append(forced, f)
+	})
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "ERROR tail\n  at c", out[len(out)-1])
+	assert.Equal(t, []bool{true}, forced)
+	assert.Equal(t, reader.Offset, reader.SafeOffset())
+}
+
+func TestTailReader_Rewind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	base := t.TempDir()
+	p := filepath.Join(base, "ml_rewind.txt")
+	content := "INFO ok\nERROR boom\n  at a\n"
+	assert.NoError(t, os.WriteFile(p, []byte(content), 0644))
+
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	ml := &MultilineReader{
+		Mode:             MultilineReaderModeContinueThrough,
+		StartPattern:     "^(ERROR|INFO)",
+		ConditionPattern: "^\\s",
+		Timeout:          time.Hour,
+	}
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n", Multiline: ml, HoldMultiline: true}
+	defer func() { reader.Multiline.Close() }()
+	var out []string
+	assert.NoError(t, reader.ReadOnce(func(s string) { out = append(out, s) }))
+	assert.Equal(t, []string{"INFO ok"}, out)
+
+	// Reading again from the start neither repeats nor loses the held trace
+	reader.Rewind(0)
+	assert.Equal(t, int64(0), reader.SafeOffset())
+	assert.NotSame(t, ml, reader.Multiline)
+	assert.False(t, reader.Multiline.Pending())
+	out = nil
+	assert.NoError(t, reader.ReadOnce(func(s string) { out = append(out, s) }))
+	assert.Equal(t, []string{"INFO ok"}, out)
+	assert.Equal(t, int64(len("INFO ok\n")), reader.SafeOffset())
+	reader.FlushMultiline(func(rec []byte, _ bool) { out = append(out, string(rec)) })
+	assert.Equal(t, []string{"INFO ok", "ERROR boom\n  at a"}, out)
+}
+
 func TestTailReader_NoMultiline_EOFResidual_NotConsumed(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("skip inode-based tailer tests on Windows")
@@ -520,3 +618,121 @@ func TestTailReader_ReadLoop_NoMultiline_EmitsLines(t *testing.T) {
 	assert.Contains(t, got, "b")
 	assert.Contains(t, got, "c")
 }
+
+func TestTailReader_ReadOnceBytes_NoPerLineAllocations(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	p := filepath.Join(t.TempDir(), "bytes.log")
+	const lines = 1000
+	assert.NoError(t, os.WriteFile(p, []byte(strings.Repeat("some log line\n", lines)), 0644))
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n"}
+	var got []string
+	assert.NoError(t, reader.ReadOnceBytes(func(b []byte) {
+		if len(got) < 2 {
+			got = append(got, string(b))
+		}
+	}))
+	assert.Equal(t, []string{"some log line", "some log line"}, got)
+	assert.Equal(t, int64(lines*len("some log line\n")), reader.Offset)
+
+	var n int
+	allocs := testing.AllocsPerRun(5, func() {
+		reader.Offset = 0
+		_ = reader.ReadOnceBytes(func(b []byte) { n += len(b) })
+	})
+	// Opening the file costs a handful of allocations; none should scale with lines.
+	assert.Less(t, allocs, float64(lines/10))
+}
+
+func TestTailReader_BufferSizes_LongRecord(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	p := filepath.Join(t.TempDir(), "long.log")
+	long := strings.Repeat("x", 1<<20)
+	assert.NoError(t, os.WriteFile(p, []byte(long+"\nshort\n"), 0644))
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n",
+		ReadBufferSize: 64 << 10, ChunkBufferSize: 2 << 20}
+	var got []int
+	assert.NoError(t, reader.ReadOnce(func(s string) { got = append(got, len(s)) }))
+	assert.Equal(t, []int{len(long), len("short")}, got)
+}
+
+func TestTailReader_SeparatorRegex_MixedLineEndings(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tailer tests on Windows")
+	}
+	p := filepath.Join(t.TempDir(), "mixed.log")
+	assert.NoError(t, os.WriteFile(p, []byte("one\r\ntwo\n\r\nthree\r"), 0644))
+	fi, err := os.Stat(p)
+	assert.NoError(t, err)
+	id, err := file_tracker.GetFileID(fi)
+	assert.NoError(t, err)
+	tr := file_tracker.New()
+	tr.Add(id, p, watcher.FingerprintStrategyDeviceAndInode, 0)
+
+	re, err := CompileSeparatorRegex(`\r?\n`)
+	assert.NoError(t, err)
+	reader := &TailReader{FileId: id, FileManager: tr, Separator: "\n", SeparatorRegex: re}
+	var got []string
+	assert.NoError(t, reader.ReadOnce(func(s string) { got = append(got, s) }))
+	assert.Equal(t, []string{"one", "two"}, got)
+	// The blank CRLF line is consumed; the incomplete "three\r" is not
+	assert.Equal(t, int64(len("one\r\ntwo\n\r\n")), reader.Offset)
+
+	// Completing the record in a later write resumes from the stored offset
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("\nfour\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	got = nil
+	assert.NoError(t, reader.ReadOnce(func(s string) { got = append(got, s) }))
+	assert.Equal(t, []string{"three", "four"}, got)
+}
+
+func TestCompileSeparatorRegex_RejectsEmptyMatch(t *testing.T) {
+	_, err := CompileSeparatorRegex(`\n?`)
+	assert.Error(t, err)
+	_, err = CompileSeparatorRegex(`[`)
+	assert.Error(t, err)
+}
+
+// A file failing its fingerprint check on every read does not leak a descriptor per try.
+func TestTailReader_FailedOpenClosesFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("counts descriptors in /proc/self/fd")
+	}
+	openFDs := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		require.NoError(t, err)
+		return len(entries)
+	}
+	p := filepath.Join(t.TempDir(), "short.log")
+	require.NoError(t, os.WriteFile(p, []byte("short\n"), 0644))
+	tr := file_tracker.New()
+	tr.Add("checksum-id", p, watcher.FingerprintStrategyChecksum, 1024)
+	reader := &TailReader{FileId: "checksum-id", FileManager: tr, Separator: "\n"}
+
+	before := openFDs()
+	for i := 0; i < 50; i++ {
+		err := reader.ReadOnceBytes(func([]byte) {})
+		require.True(t, file_tracker.IsFileSizeTooSmall(err), "got %v", err)
+	}
+	assert.Equal(t, before, openFDs())
+}
@@ -2,8 +2,12 @@ package watcher
 
 import (
 	"errors"
+	"log/slog"
+	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/loykin/freader/internal/clock"
 	"github.com/loykin/freader/internal/file_tracker"
 )
 
@@ -13,12 +17,109 @@ type Config struct {
 	FingerprintSize      int
 	FingerprintSeparator string
 	Exclude              []string
-	Include              []string
+	Include              []string // "!pattern" entries make it ordered, see pathIncluded
 	FileTracker          *file_tracker.FileTracker
+	Logger               *slog.Logger // nil uses slog.Default()
+	// ExcludeDirs are patterns for directories a scan does not descend into at all,
+	// e.g. "node_modules", ".git" or "/var/log/*/archived", matched like Exclude
+	// against the base name or the full path. A trailing separator is ignored. The
+	// include roots themselves are always walked.
+	ExcludeDirs []string
+	// IgnoreFile, if set, is the name of gitignore-style files read from the include
+	// roots at the start of each scan, e.g. ".freaderignore". Files and directories
+	// they match are skipped like excluded ones, and so is the ignore file itself.
+	IgnoreFile string
+	// FingerprintOffset, for the checksum strategy, skips this many bytes before the
+	// FingerprintSize bytes that are hashed, so files starting with the same header
+	// (CSV header rows, banner preambles) do not all get the same ID. Files must then
+	// be at least FingerprintOffset+FingerprintSize bytes long to be tracked.
+	FingerprintOffset int64
+	// ProvisionalIDs, for the checksum strategy, tracks files too small to fingerprint
+	// under a provisional device-and-inode ID instead of skipping them until they have
+	// grown. Once such a file can be fingerprinted, a scan tracks it under its checksum
+	// ID, calling OnUpgrade right before the added callback, and then drops the
+	// provisional ID like a removed file. Like deviceAndInode IDs, provisional ones can
+	// be confused by inode reuse.
+	ProvisionalIDs bool
+	// KeepDuplicates reads a file found at several paths (hard links, bind mounts,
+	// symlinks, or copies with the same checksum fingerprint) once per path. By default
+	// it is read once, at the path it is tracked at or was found at first, and its other
+	// paths are skipped as duplicates. With KeepDuplicates, each other path is tracked
+	// under a DuplicateID of its own; a file renamed to such a path is then a new file,
+	// read from the start.
+	KeepDuplicates bool
+	// OnUpgrade, if set, is called during a scan right before the added callback for id
+	// when the file was tracked under the provisional ID until then; see ProvisionalIDs.
+	OnUpgrade func(id, provisional string)
+	// MissedScans is how many consecutive scans a tracked file may be missing or
+	// unfingerprintable before it is dropped; 0 or 1 drops it on the first miss. Higher
+	// values ride out stale directory listings and attribute caches on network
+	// filesystems.
+	MissedScans int
+	// Unreadable tracks paths failing with permission errors; nil creates a tracker
+	// retrying after PollInterval with exponential back-off. Pass one to share it with
+	// the readers of tracked files.
+	Unreadable *UnreadableFiles
+	// SkipLocked skips files another process holds locked (file_tracker.IsLocked, on
+	// Windows) quietly, logging them at debug level only and trying them again on the
+	// next scan. Otherwise they are retried with back-off and reported like Unreadable
+	// files.
+	SkipLocked bool
+	// OnLocked, if set, is called for every file a scan could not fingerprint because
+	// another process holds it locked.
+	OnLocked func(path string)
+	// Decisions, if set, records files the watcher starts tracking, drops or fails to
+	// fingerprint, for debugging why a file is or is not being read.
+	Decisions *DecisionLog
+	// Trace, if set, records every file each scan examines and why it was included,
+	// excluded by the filters or skipped (e.g. too small to fingerprint). Scans record
+	// one entry per file, so size it to hold a few scans.
+	Trace *DecisionLog
+	// OnReplace, if set, is called during a scan right before the added callback for id
+	// when id was found at the path previous was tracked at when the scan started,
+	// e.g. after rename rotation moved previous away and a new file took its place.
+	OnReplace func(id, previous string)
+	// Clock drives the poll ticker and the timestamps of scans, decisions and permission
+	// retries; nil uses the real clock.
+	Clock clock.Clock
+	// ScanBudget and ScanMaxFiles bound the time spent and the files examined by a scan
+	// on one tick of the poll ticker. A scan that reaches either stops after the file
+	// it examined last and carries on from there on the next tick, so files in a huge
+	// tree are picked up in several slices rather than by one long walk. Tracked files
+	// are only dropped once a scan has walked the whole tree, and LastScan reports
+	// completed scans. Scan ignores both. 0 means no limit.
+	ScanBudget   time.Duration
+	ScanMaxFiles int
+	// Owns, if set, shards files across instances: scans skip files whose ID it rejects
+	// and drop tracked ones it no longer accepts, calling OnDisown instead of the removed
+	// callback so their state is handed over rather than discarded.
+	Owns     func(id string) bool
+	OnDisown func(id string)
 }
 
 // Validate checks the configuration consistency according to the selected strategy.
 func (c Config) Validate() error {
+	if c.MissedScans < 0 {
+		return errors.New("missed scans must not be negative")
+	}
+	if err := validateInclude(c.Include); err != nil {
+		return err
+	}
+	if c.IgnoreFile != "" && filepath.Base(c.IgnoreFile) != c.IgnoreFile {
+		return errors.New("ignore file must be a file name, not a path: " + c.IgnoreFile)
+	}
+	if c.FingerprintOffset < 0 {
+		return errors.New("fingerprint offset must not be negative")
+	}
+	if c.ProvisionalIDs && c.FingerprintStrategy != FingerprintStrategyChecksum {
+		return errors.New("provisional IDs require the checksum strategy")
+	}
+	if c.FingerprintOffset > 0 && c.FingerprintStrategy != FingerprintStrategyChecksum {
+		return errors.New("fingerprint offset requires the checksum strategy")
+	}
+	if c.ScanBudget < 0 || c.ScanMaxFiles < 0 {
+		return errors.New("scan budget and max files must not be negative")
+	}
 	switch c.FingerprintStrategy {
 	case FingerprintStrategyDeviceAndInode:
 		// no extra requirements
@@ -48,3 +149,22 @@ func DefaultConfig() Config {
 		FileTracker:         file_tracker.New(),
 	}
 }
+
+// validateInclude rejects negated include entries that cannot match anything.
+func validateInclude(includes []string) error {
+	positive := false
+	for _, entry := range includes {
+		pattern, negated := negatedPattern(entry)
+		if !negated {
+			positive = true
+			continue
+		}
+		if pattern == "" {
+			return errors.New("negated include pattern is empty")
+		}
+		if !positive {
+			return errors.New("negated include pattern " + strconv.Quote(entry) + " must follow a pattern it narrows")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Decision actions recorded in a DecisionLog.
+const (
+	DecisionAdded   = "added"   // the path started being tracked
+	DecisionRemoved = "removed" // a tracked file stopped being tracked
+	DecisionSkipped = "skipped" // a matching path could not be tracked
+	DecisionRenamed = "renamed" // a tracked file was found at a new path
+
+	// Actions recorded by a scan trace; see Config.Trace.
+	DecisionIncluded = "included" // the file is tracked
+	DecisionExcluded = "excluded" // the file does not pass the include/exclude filters
+)
+
+// DefaultDecisionHistory is the capacity of a DecisionLog created with size 0.
+const DefaultDecisionHistory = 256
+
+// DefaultTraceHistory is the capacity of the scan trace kept by a collector.
+const DefaultTraceHistory = 1024
+
+// Decision records why a path started, stopped or failed to be tracked.
+type Decision struct {
+	Time   time.Time `json:"time"`
+	Scan   uint64    `json:"scan,omitempty"` // scan number, in a scan trace
+	Action string    `json:"action"`
+	Path   string    `json:"path"`
+	FileID string    `json:"file_id,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// DecisionLog keeps the most recent decisions in a ring buffer. It is safe for
+// concurrent use.
+type DecisionLog struct {
+	mu   sync.Mutex
+	buf  []Decision
+	next int
+	full bool
+	now  func() time.Time
+}
+
+// NewDecisionLog returns a log keeping the last size decisions (0 = DefaultDecisionHistory).
+func NewDecisionLog(size int) *DecisionLog {
+	if size <= 0 {
+		size = DefaultDecisionHistory
+	}
+	return &DecisionLog{buf: make([]Decision, size), now: time.Now}
+}
+
+// setNow makes the log stamp decisions with now; it is a no-op on a nil log.
+func (l *DecisionLog) setNow(now func() time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.now = now
+}
+
+// Record appends d, stamping it with the current time when d.Time is zero. Recording
+// to a nil log does nothing.
+func (l *DecisionLog) Record(d Decision) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if d.Time.IsZero() {
+		d.Time = l.now()
+	}
+	l.buf[l.next] = d
+	l.next = (l.next + 1) % len(l.buf)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// List returns the recorded decisions, oldest first.
+func (l *DecisionLog) List() []Decision {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]Decision(nil), l.buf[:l.next]...)
+	}
+	out := make([]Decision, 0, len(l.buf))
+	out = append(out, l.buf[l.next:]...)
+	return append(out, l.buf[:l.next]...)
+}
@@ -0,0 +1,55 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecisionLog_Ring(t *testing.T) {
+	var nilLog *DecisionLog
+	nilLog.Record(Decision{Path: "a"})
+	assert.Nil(t, nilLog.List())
+
+	l := NewDecisionLog(3)
+	assert.Empty(t, l.List())
+	for _, p := range []string{"a", "b", "c", "d", "e"} {
+		l.Record(Decision{Action: DecisionAdded, Path: p})
+	}
+	got := l.List()
+	if assert.Len(t, got, 3) {
+		assert.Equal(t, "c", got[0].Path)
+		assert.Equal(t, "e", got[2].Path)
+		assert.False(t, got[0].Time.IsZero())
+	}
+}
+
+func TestWatcher_RecordsDecisions(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.log")
+	require.NoError(t, os.WriteFile(p, []byte("hello\n"), 0644))
+
+	cfg := DefaultConfig()
+	cfg.Include = []string{dir}
+	cfg.FingerprintStrategy = FingerprintStrategyDeviceAndInode
+	cfg.Decisions = NewDecisionLog(0)
+	w, err := NewWatcher(cfg, func(string, string) {}, func(string) {})
+	require.NoError(t, err)
+
+	w.Scan()
+	w.Scan()
+	require.NoError(t, os.Remove(p))
+	w.Scan()
+
+	got := cfg.Decisions.List()
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, DecisionAdded, got[0].Action)
+		assert.Equal(t, p, got[0].Path)
+		assert.Equal(t, DecisionRemoved, got[1].Action)
+		assert.Equal(t, p, got[1].Path)
+		assert.Equal(t, got[0].FileID, got[1].FileID)
+	}
+}
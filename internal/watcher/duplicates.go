@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"os"
+	"strings"
+)
+
+// DuplicateID returns the ID the file with fingerprint id is tracked under at p, a
+// path other than the one it was found at first, with Config.KeepDuplicates.
+func DuplicateID(id, p string) string {
+	return id + "@" + p
+}
+
+// Fingerprint returns the fingerprint of the file tracked under id: id itself, or the
+// ID a DuplicateID was derived from. Fingerprints never contain '@'.
+func Fingerprint(id string) string {
+	fp, _, _ := strings.Cut(id, "@")
+	return fp
+}
+
+// duplicateOf returns the path the file with fingerprint id, found at p by scan cy,
+// is read at if p is a duplicate of it: another path the file is tracked at and is
+// still at, or was found at first by this scan. It returns "" otherwise, e.g. when
+// the file was renamed to p.
+func (w *Watcher) duplicateOf(cy *scanCycle, id, p string) string {
+	if f := w.fileManager.Get(id); f != nil {
+		if f.Path != p && w.stillAt(cy, id, f.Path) {
+			return f.Path
+		}
+		return ""
+	}
+	if first, ok := cy.foundAt[id]; ok && first != p {
+		return first
+	}
+	return ""
+}
+
+// stillAt reports whether the file with fingerprint id is at p, looking it up in the
+// paths scan cy examined so far before fingerprinting p again.
+func (w *Watcher) stillAt(cy *scanCycle, id, p string) bool {
+	if at, ok := cy.idAt[p]; ok {
+		return Fingerprint(at) == id
+	}
+	info, err := os.Stat(p)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	got, _, _ := w.computeFileID(p, info)
+	return got == id
+}
+
+// recordDuplicates logs and records the duplicate paths scan cy skipped that the
+// previous scan did not, and keeps them for the next one.
+func (w *Watcher) recordDuplicates(cy *scanCycle) {
+	for p, first := range cy.duplicates {
+		if w.duplicates[p] == first {
+			continue
+		}
+		w.logger.Info("file already read at another path, skipping duplicate", "path", p, "read_at", first)
+		w.decisions.Record(Decision{Action: DecisionSkipped, Path: p, FileID: cy.idAt[p], Reason: "duplicate of " + first + ", read there"})
+	}
+	w.duplicates = cy.duplicates
+}
@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher_Duplicates(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		t.Run(map[bool]string{false: "dedupe", true: "keep"}[keep], func(t *testing.T) {
+			dir := t.TempDir()
+			a, b, c := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log"), filepath.Join(dir, "c.log")
+			assert.NoError(t, os.WriteFile(a, []byte("same content\n"), 0644))
+			assert.NoError(t, os.Link(a, b))
+			assert.NoError(t, os.Symlink(a, c))
+
+			tracker := file_tracker.New()
+			var added []string
+			w, err := NewWatcher(Config{
+				Include:             []string{dir},
+				PollInterval:        time.Hour,
+				FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+				FileTracker:         tracker,
+				Decisions:           NewDecisionLog(0),
+				Trace:               NewDecisionLog(0),
+				KeepDuplicates:      keep,
+			}, func(id, path string) { added = append(added, id) }, func(id string) {})
+			assert.NoError(t, err)
+
+			w.scan(false)
+			w.scan(false)
+			id, err := file_tracker.GetFileIDFromPath(a)
+			assert.NoError(t, err)
+			assert.Equal(t, a, tracker.Get(id).Path)
+			if keep {
+				want := []string{id, DuplicateID(id, b), DuplicateID(id, c)}
+				sort.Strings(added)
+				sort.Strings(want)
+				assert.Equal(t, want, added)
+				assert.Equal(t, c, tracker.Get(DuplicateID(id, c)).Path)
+				assert.Equal(t, id, Fingerprint(DuplicateID(id, c)))
+				assert.Equal(t, "tracked", w.Explain(b).Reason)
+				return
+			}
+			assert.Equal(t, []string{id}, added)
+			assert.Len(t, tracker.GetAllFiles(), 1)
+
+			// Each duplicate path is recorded once, not on every scan
+			var skipped []string
+			for _, d := range w.decisions.List() {
+				if d.Action == DecisionSkipped {
+					skipped = append(skipped, d.Path)
+					assert.Equal(t, "duplicate of "+a+", read there", d.Reason)
+				}
+			}
+			assert.ElementsMatch(t, []string{b, c}, skipped)
+			d := w.Explain(b)
+			assert.Equal(t, DecisionSkipped, d.Action)
+			assert.Equal(t, "duplicate of "+a+", read there", d.Reason)
+
+			// Once the file is only left at a duplicate path, it is followed there
+			assert.NoError(t, os.Remove(c))
+			assert.NoError(t, os.Remove(a))
+			w.scan(false)
+			assert.Equal(t, b, tracker.Get(id).Path)
+			assert.Equal(t, []string{id}, added)
+		})
+	}
+}
+
+func TestWatcher_DuplicateChecksum(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	assert.NoError(t, os.WriteFile(a, []byte("copied content\n"), 0644))
+	assert.NoError(t, os.WriteFile(b, []byte("copied content\n"), 0644))
+
+	tracker := file_tracker.New()
+	var added []string
+	w, err := NewWatcher(Config{
+		Include:             []string{dir},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		FileTracker:         tracker,
+	}, func(id, path string) { added = append(added, id) }, func(id string) {})
+	assert.NoError(t, err)
+
+	w.scan(false)
+	if assert.Len(t, added, 1) {
+		assert.Equal(t, a, tracker.Get(added[0]).Path)
+	}
+}
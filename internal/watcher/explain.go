@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// evaluate decides whether the file p found by a scan passes the filters and can be
+// fingerprinted. It returns the file's id and the strategy it was computed with, or an
+// empty id and a decision giving the reason the file is excluded or skipped. A file
+// that is included gets a decision without reason, which the caller completes.
+func (w *Watcher) evaluate(p string, info fs.FileInfo, include, exclude []string, hasSpecific bool) (string, string, Decision) {
+	d := Decision{Path: p, Action: DecisionExcluded}
+	// Filters: include first, then exclude
+	if len(include) > 0 && !pathIncluded(p, include, hasSpecific) {
+		d.Reason = "matches no include pattern"
+		if entry, ok := lastMatchingInclude(p, include); ok && hasNegation(include) {
+			d.Reason = "matches negated include pattern " + strconv.Quote(entry)
+		}
+		return "", "", d
+	}
+	if pattern, ok := matchingPattern(p, exclude); ok {
+		d.Reason = "matches exclude pattern " + strconv.Quote(pattern)
+		return "", "", d
+	}
+
+	d.Action = DecisionSkipped
+	// Files failing with permission errors are retried with back-off
+	if !w.unreadable.Due(p) {
+		d.Reason = "permission denied, waiting to retry"
+		return "", "", d
+	}
+	// Compute file ID according to strategy (with size/condition checks)
+	id, strategy, reason := w.computeFileID(p, info)
+	if id == "" {
+		d.Reason = reason
+		return "", "", d
+	}
+	d.Action, d.FileID = DecisionIncluded, id
+	return id, strategy, d
+}
+
+// Explain reports whether the file at p is tracked and, if not, why: it is outside
+// the scanned directories or in one excluded by ExcludeDirs, is ignored by an ignore
+// file, does not pass the include/exclude filters or cannot be
+// fingerprinted yet. p is compared with the include patterns as given, so it must be
+// absolute if they are. The file is not tracked by explaining it.
+func (w *Watcher) Explain(p string) Decision {
+	d := Decision{Path: p, Action: DecisionExcluded}
+	w.filterMu.RLock()
+	include, exclude := w.include, w.exclude
+	w.filterMu.RUnlock()
+
+	info, err := os.Stat(p)
+	switch {
+	case err != nil:
+		d.Action, d.Reason = DecisionSkipped, err.Error()
+	case info.IsDir():
+		d.Reason = "is a directory"
+	case !underAnyRoot(p, deriveScanRoots(include)):
+		d.Reason = "outside the scanned directories " + strings.Join(deriveScanRoots(include), ", ")
+	default:
+		if dir, pattern, ok := w.excludedDir(p, deriveScanRoots(include)); ok {
+			d.Reason = "in directory " + dir + " matching exclude-dirs pattern " + strconv.Quote(pattern)
+			break
+		}
+		if d.Reason = w.ignoredByFile(p, deriveScanRoots(include)); d.Reason != "" {
+			break
+		}
+		var id string
+		if id, _, d = w.evaluate(p, info, include, exclude, hasSpecificIncludes(include)); id != "" {
+			d.Reason = "tracked"
+			f := w.fileManager.Get(id)
+			if dup := w.fileManager.Get(DuplicateID(id, p)); w.keepDuplicates && dup != nil {
+				d.FileID, f = DuplicateID(id, p), dup
+			}
+			switch {
+			case f == nil, f.Path != p && w.keepDuplicates:
+				d.Reason = "not tracked yet, the next scan will add it"
+			case f.Path != p:
+				d.Action, d.Reason = DecisionSkipped, "duplicate of "+f.Path+", read there"
+			}
+		}
+	}
+	d.Time = w.clock.Now()
+	return d
+}
+
+// ignoredByFile returns why the ignore file of the root p is in makes scans skip p,
+// or "" if they do not.
+func (w *Watcher) ignoredByFile(p string, roots []string) string {
+	for _, root := range roots {
+		if isSubPath(p, root) {
+			return ignoredReason(w.ignoreFiles([]string{root}), root, p)
+		}
+	}
+	return ""
+}
+
+// underAnyRoot reports whether p is one of roots or below one of them.
+func underAnyRoot(p string, roots []string) bool {
+	for _, root := range roots {
+		if filepath.Clean(p) == root || isSubPath(p, root) {
+			return true
+		}
+	}
+	return false
+}
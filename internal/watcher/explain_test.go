@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_TraceAndExplain(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.log":   "0123456789\n",
+		"small.log": "0123\n",
+		"skip.log":  "0123456789\n",
+		"notes.txt": "0123456789\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	cfg := DefaultConfig()
+	cfg.Include = []string{filepath.Join(dir, "*.log")}
+	cfg.Exclude = []string{"skip*"}
+	cfg.FingerprintStrategy = FingerprintStrategyChecksum
+	cfg.FingerprintSize = 8
+	cfg.Trace = NewDecisionLog(0)
+	w, err := NewWatcher(cfg, func(string, string) {}, func(string) {})
+	require.NoError(t, err)
+
+	w.Scan()
+	w.Scan()
+
+	byPath := map[string]Decision{}
+	for _, d := range cfg.Trace.List() {
+		if d.Scan == 2 {
+			byPath[filepath.Base(d.Path)] = d
+		}
+	}
+	require.Len(t, byPath, 4)
+	assert.Equal(t, DecisionIncluded, byPath["app.log"].Action)
+	assert.Equal(t, "already tracked", byPath["app.log"].Reason)
+	assert.NotEmpty(t, byPath["app.log"].FileID)
+	assert.Equal(t, DecisionSkipped, byPath["small.log"].Action)
+	assert.Contains(t, byPath["small.log"].Reason, "too small")
+	assert.Equal(t, DecisionExcluded, byPath["skip.log"].Action)
+	assert.Contains(t, byPath["skip.log"].Reason, `"skip*"`)
+	assert.Equal(t, DecisionExcluded, byPath["notes.txt"].Action)
+	assert.Equal(t, "matches no include pattern", byPath["notes.txt"].Reason)
+
+	assert.Equal(t, "tracked", w.Explain(filepath.Join(dir, "app.log")).Reason)
+	assert.Contains(t, w.Explain(filepath.Join(dir, "small.log")).Reason, "too small")
+	assert.Equal(t, "is a directory", w.Explain(dir).Reason)
+	missing := w.Explain(filepath.Join(dir, "missing.log"))
+	assert.Equal(t, DecisionSkipped, missing.Action)
+	outside := w.Explain(filepath.Join(t.TempDir(), "other.log"))
+	assert.Equal(t, DecisionSkipped, outside.Action) // does not exist either
+
+	other := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(other, "x.log"), []byte("0123456789\n"), 0644))
+	d := w.Explain(filepath.Join(other, "x.log"))
+	assert.Equal(t, DecisionExcluded, d.Action)
+	assert.Contains(t, d.Reason, "outside the scanned directories")
+}
@@ -0,0 +1,175 @@
+package watcher
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ignoreRule is one pattern line of an ignore file.
+type ignoreRule struct {
+	pattern  string // as written, for decisions
+	segments []string
+	negate   bool // "!pattern" re-includes what an earlier rule ignored
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // contains a slash other than a trailing one: matched from the root
+}
+
+// ignoreRules are the rules of the ignore file at file, for paths below its directory.
+type ignoreRules struct {
+	file  string
+	rules []ignoreRule
+}
+
+// loadIgnoreFile reads the gitignore-style ignore file at p. A missing file has no
+// rules and yields nil.
+func loadIgnoreFile(p string) (*ignoreRules, error) {
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	r := &ignoreRules{file: p}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if rule, ok := parseIgnoreRule(sc.Text()); ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return r, sc.Err()
+}
+
+// parseIgnoreRule parses one line of an ignore file as in .gitignore: blank lines and
+// lines starting with # are skipped, "!" negates, a trailing "/" only matches
+// directories, a pattern with another "/" is relative to the ignore file's directory
+// and one without matches a name at any depth, and "**" matches any number of
+// directories. A leading "\" escapes "#" or "!".
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{pattern: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.anchored = strings.Contains(line, "/")
+	rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return rule, true
+}
+
+// match reports whether the rule matches rel, a slash-separated path relative to the
+// ignore file's directory.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**" stands
+// for zero or more segments.
+func matchSegments(pattern, segs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pattern[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+			return false
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// reason returns why p, below dir, is ignored, or "" if it is not: the last rule
+// matching p decides. r may be nil.
+func (r *ignoreRules) reason(dir, p string, isDir bool) string {
+	if r == nil {
+		return ""
+	}
+	rel, err := filepath.Rel(dir, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	var last *ignoreRule
+	for i := range r.rules {
+		if r.rules[i].match(rel, isDir) {
+			last = &r.rules[i]
+		}
+	}
+	if last == nil || last.negate {
+		return ""
+	}
+	return "ignored by pattern " + strconv.Quote(last.pattern) + " in " + r.file
+}
+
+// ignoreFiles loads the ignore file of each root, skipping roots without one.
+func (w *Watcher) ignoreFiles(roots []string) map[string]*ignoreRules {
+	if w.ignoreFile == "" {
+		return nil
+	}
+	ignores := make(map[string]*ignoreRules)
+	for _, root := range roots {
+		r, err := loadIgnoreFile(filepath.Join(root, w.ignoreFile))
+		if err != nil {
+			w.logger.Warn("failed to read ignore file", "path", filepath.Join(root, w.ignoreFile), "error", err)
+			continue
+		}
+		if r != nil {
+			ignores[root] = r
+		}
+	}
+	return ignores
+}
+
+// ignoredReason returns why the ignore file of root, one of roots, makes scans skip the
+// file p, or "" if it does not: p is the ignore file itself, or p or a directory above
+// it is ignored.
+func ignoredReason(ignores map[string]*ignoreRules, root, p string) string {
+	r := ignores[root]
+	if r == nil {
+		return ""
+	}
+	if filepath.Clean(p) == r.file {
+		return "is the ignore file"
+	}
+	for dir := filepath.Dir(filepath.Clean(p)); isSubPath(dir, root); dir = filepath.Dir(dir) {
+		if reason := r.reason(root, dir, true); reason != "" {
+			return reason
+		}
+	}
+	return r.reason(root, p, false)
+}
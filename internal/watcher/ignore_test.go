@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/file_tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	var r ignoreRules
+	for _, line := range []string{
+		"# comment",
+		"",
+		"*.gz",
+		"debug/",
+		"/top.log",
+		"app/**/trace.log",
+		"keep*.log  ",
+		"*.tmp",
+		"!important.tmp",
+		`\#hash.log`,
+	} {
+		if rule, ok := parseIgnoreRule(line); ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+	r.file = "/root/.freaderignore"
+	require.Len(t, r.rules, 8)
+
+	tests := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"a.log", false, false},
+		{"old/a.log.gz", false, true},
+		{"debug", true, true},
+		{"x/debug", true, true},
+		{"debug", false, false}, // only directories
+		{"top.log", false, true},
+		{"sub/top.log", false, false}, // anchored to the root
+		{"app/trace.log", false, true},
+		{"app/a/b/trace.log", false, true},
+		{"other/trace.log", false, false},
+		{"keep-me.log", false, true},
+		{"x.tmp", false, true},
+		{"important.tmp", false, false},
+		{"#hash.log", false, true},
+	}
+	for _, tt := range tests {
+		p := filepath.Join("/root", filepath.FromSlash(tt.rel))
+		got := r.reason("/root", p, tt.isDir) != ""
+		assert.Equal(t, tt.ignored, got, tt.rel)
+	}
+	var none *ignoreRules
+	assert.Empty(t, none.reason("/root", "/root/a.gz", false))
+}
+
+func TestWatcher_IgnoreFile(t *testing.T) {
+	base := t.TempDir()
+	for _, rel := range []string{"app.log", "app.log.1", "debug/x.log", "keep/a.log", "keep/b.log"} {
+		p := filepath.Join(base, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(rel+"\n"), 0644))
+	}
+	ignore := filepath.Join(base, ".freaderignore")
+	require.NoError(t, os.WriteFile(ignore, []byte("*.1\ndebug/\nkeep/*\n!keep/a.log\n"), 0644))
+
+	tracker := file_tracker.New()
+	w, err := NewWatcher(Config{
+		Include:             []string{base},
+		IgnoreFile:          ".freaderignore",
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         tracker,
+	}, func(id, path string) {}, func(id string) {})
+	require.NoError(t, err)
+
+	tracked := func() []string {
+		var paths []string
+		for _, f := range tracker.GetAllFiles() {
+			rel, _ := filepath.Rel(base, f.Path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return paths
+	}
+	w.Scan()
+	assert.ElementsMatch(t, []string{"app.log", "keep/a.log"}, tracked())
+	d := w.Explain(filepath.Join(base, "debug", "x.log"))
+	assert.Equal(t, DecisionExcluded, d.Action)
+	assert.Contains(t, d.Reason, `"debug/"`)
+	assert.Equal(t, "is the ignore file", w.Explain(ignore).Reason)
+
+	// Changes apply on the next scan
+	require.NoError(t, os.WriteFile(ignore, []byte("app.log\n"), 0644))
+	w.Scan()
+	assert.ElementsMatch(t, []string{"app.log.1", "debug/x.log", "keep/a.log", "keep/b.log"}, tracked())
+
+	_, err = NewWatcher(Config{Include: []string{base}, IgnoreFile: "a/.freaderignore",
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode, FileTracker: tracker}, nil, nil)
+	assert.Error(t, err)
+}
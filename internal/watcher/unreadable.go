@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxUnreadableRetryInterval caps the back-off between attempts to open a file that
+// failed with a permission error.
+const MaxUnreadableRetryInterval = 5 * time.Minute
+
+// UnreadableFile describes a file or directory that could not be opened for lack of
+// permission, or a file another process holds locked.
+type UnreadableFile struct {
+	Path      string
+	Err       error
+	Since     time.Time // first failure
+	Attempts  int
+	NextRetry time.Time
+}
+
+// UnreadableFiles tracks paths failing with permission errors or locks and schedules
+// retries with exponential back-off, so an unreadable log is retried less and less
+// often and reported once instead of on every scan. It is safe for concurrent use.
+type UnreadableFiles struct {
+	// OnChange, if set, is called with the number of tracked paths whenever it changes.
+	OnChange func(count int)
+
+	mu    sync.Mutex
+	base  time.Duration
+	files map[string]*UnreadableFile
+	now   func() time.Time
+}
+
+// NewUnreadableFiles returns a tracker whose first retry happens after base; the delay
+// doubles per failure up to MaxUnreadableRetryInterval.
+func NewUnreadableFiles(base time.Duration) *UnreadableFiles {
+	if base <= 0 {
+		base = time.Second
+	}
+	return &UnreadableFiles{base: base, files: make(map[string]*UnreadableFile), now: time.Now}
+}
+
+// Due reports whether path should be tried now: it is not tracked or its retry time
+// has come.
+func (u *UnreadableFiles) Due(path string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	f, ok := u.files[path]
+	return !ok || !u.now().Before(f.NextRetry)
+}
+
+// Fail records a permission failure for path and schedules the next retry. It reports
+// whether this is the first failure since the path was last readable.
+func (u *UnreadableFiles) Fail(path string, err error) bool {
+	u.mu.Lock()
+	now := u.now()
+	f, ok := u.files[path]
+	if !ok {
+		f = &UnreadableFile{Path: path, Since: now}
+		u.files[path] = f
+	}
+	f.Err = err
+	f.Attempts++
+	delay := u.base
+	for i := 1; i < f.Attempts && delay < MaxUnreadableRetryInterval; i++ {
+		delay *= 2
+	}
+	f.NextRetry = now.Add(min(delay, MaxUnreadableRetryInterval))
+	count := len(u.files)
+	u.mu.Unlock()
+
+	if !ok {
+		u.changed(count)
+	}
+	return !ok
+}
+
+// Succeed forgets path after it was opened successfully and reports whether it had
+// been failing.
+func (u *UnreadableFiles) Succeed(path string) bool {
+	u.mu.Lock()
+	_, ok := u.files[path]
+	delete(u.files, path)
+	count := len(u.files)
+	u.mu.Unlock()
+
+	if ok {
+		u.changed(count)
+	}
+	return ok
+}
+
+// Prune forgets paths for which keep returns false, e.g. files that were deleted.
+func (u *UnreadableFiles) Prune(keep func(path string) bool) {
+	u.mu.Lock()
+	removed := false
+	for path := range u.files {
+		if !keep(path) {
+			delete(u.files, path)
+			removed = true
+		}
+	}
+	count := len(u.files)
+	u.mu.Unlock()
+
+	if removed {
+		u.changed(count)
+	}
+}
+
+// List returns the tracked paths sorted by path.
+func (u *UnreadableFiles) List() []UnreadableFile {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	out := make([]UnreadableFile, 0, len(u.files))
+	for _, f := range u.files {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+func (u *UnreadableFiles) changed(count int) {
+	if u.OnChange != nil {
+		u.OnChange(count)
+	}
+}
@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader/internal/file_tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnreadableFiles_BackOff(t *testing.T) {
+	now := time.Unix(1000, 0)
+	u := NewUnreadableFiles(time.Second)
+	u.now = func() time.Time { return now }
+	var counts []int
+	u.OnChange = func(n int) { counts = append(counts, n) }
+
+	assert.True(t, u.Due("/a"))
+	assert.True(t, u.Fail("/a", fs.ErrPermission), "first failure")
+	assert.False(t, u.Due("/a"))
+	now = now.Add(time.Second)
+	assert.True(t, u.Due("/a"))
+
+	assert.False(t, u.Fail("/a", fs.ErrPermission))
+	now = now.Add(time.Second)
+	assert.False(t, u.Due("/a"), "delay doubles")
+	now = now.Add(time.Second)
+	assert.True(t, u.Due("/a"))
+
+	for i := 0; i < 20; i++ {
+		u.Fail("/a", fs.ErrPermission)
+	}
+	assert.Equal(t, now.Add(MaxUnreadableRetryInterval), u.List()[0].NextRetry, "capped")
+	assert.Equal(t, 22, u.List()[0].Attempts)
+
+	u.Fail("/b", fs.ErrPermission)
+	u.Prune(func(p string) bool { return p != "/b" })
+	assert.True(t, u.Succeed("/a"))
+	assert.False(t, u.Succeed("/a"))
+	assert.Empty(t, u.List())
+	assert.Equal(t, []int{1, 2, 1, 0}, counts)
+}
+
+func TestWatcher_PermissionDenied(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("permission bits are not enforced for root or on Windows")
+	}
+	dir := t.TempDir()
+	p := filepath.Join(dir, "secret.log")
+	assert.NoError(t, os.WriteFile(p, []byte("0123456789\n"), 0000))
+
+	tracker := file_tracker.New()
+	w, err := NewWatcher(Config{
+		Include:             []string{dir},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		FileTracker:         tracker,
+	}, func(id, path string) {}, func(id string) {})
+	assert.NoError(t, err)
+
+	w.Scan()
+	assert.Empty(t, tracker.GetAllFiles())
+	unreadable := w.Unreadable()
+	if assert.Len(t, unreadable, 1) {
+		assert.Equal(t, p, unreadable[0].Path)
+		assert.True(t, errors.Is(unreadable[0].Err, fs.ErrPermission))
+	}
+
+	// Retried only once the back-off expires
+	assert.NoError(t, os.Chmod(p, 0644))
+	w.Scan()
+	assert.Empty(t, tracker.GetAllFiles())
+	w.unreadable.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	w.Scan()
+	assert.Len(t, tracker.GetAllFiles(), 1)
+	assert.Empty(t, w.Unreadable())
+}
@@ -64,11 +64,17 @@ func deriveGlobRoot(pattern string) string {
 //   - if path does not exist -> use its parent directory (or "." when empty)
 //
 // - Deduplicate roots; fallback to ["."] when result is empty
+//
+// Negated entries ("!pattern") add no roots. When there are any, a root nested in
+// another is dropped too, as the ordered entries re-include files below a broader one.
 func deriveScanRoots(includes []string) []string {
 	roots := make([]string, 0)
 	if len(includes) > 0 {
 		seen := map[string]struct{}{}
 		for _, pat := range includes {
+			if _, negated := negatedPattern(pat); negated {
+				continue
+			}
 			p := filepath.Clean(pat)
 			var root string
 			if hasMeta(p) {
@@ -104,12 +110,67 @@ func deriveScanRoots(includes []string) []string {
 			}
 		}
 	}
+	if hasNegation(includes) {
+		roots = outermostRoots(roots)
+	}
 	if len(roots) == 0 {
 		roots = []string{"."}
 	}
 	return roots
 }
 
+// outermostRoots returns roots without those nested in another, keeping their order.
+func outermostRoots(roots []string) []string {
+	out := roots[:0:0]
+	for _, root := range roots {
+		nested := false
+		for _, other := range roots {
+			if isSubPath(root, other) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			out = append(out, root)
+		}
+	}
+	return out
+}
+
+// negatedPattern returns the pattern of an include entry written "!pattern" and true,
+// or the entry unchanged and false.
+func negatedPattern(entry string) (string, bool) {
+	if strings.HasPrefix(entry, "!") {
+		return entry[1:], true
+	}
+	return entry, false
+}
+
+// hasNegation reports whether includes contain a "!pattern" entry, which makes them
+// evaluated in order (see pathIncluded).
+func hasNegation(includes []string) bool {
+	for _, entry := range includes {
+		if _, negated := negatedPattern(entry); negated {
+			return true
+		}
+	}
+	return false
+}
+
 func hasMeta(s string) bool {
 	return strings.ContainsAny(s, "*?[")
 }
+
+// walkOrderLess reports whether filepath.Walk visits a before b, both under the same
+// root: the entries of a directory are walked in lexical order, each followed by its
+// contents.
+func walkOrderLess(a, b string) bool {
+	as := strings.Split(filepath.Clean(a), string(filepath.Separator))
+	bs := strings.Split(filepath.Clean(b), string(filepath.Separator))
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
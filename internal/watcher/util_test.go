@@ -150,3 +150,22 @@ func TestDeriveScanRoots_RelativeNonExisting(t *testing.T) {
 		t.Fatalf("got %+v want [%q]", roots, want)
 	}
 }
+
+func TestWalkOrderLess(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"/r/a", "/r/b", true},
+		{"/r/a/z", "/r/a-c", true}, // a's contents come before its sibling a-c
+		{"/r/a-c", "/r/a/z", false},
+		{"/r/a", "/r/a/z", true},
+		{"/r/a/z", "/r/a/z", false},
+	}
+	for _, c := range cases {
+		a, b := filepath.FromSlash(c.a), filepath.FromSlash(c.b)
+		if got := walkOrderLess(a, b); got != c.want {
+			t.Fatalf("walkOrderLess(%q, %q)=%v want %v", a, b, got, c.want)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package watcher
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/loykin/freader/internal/clock"
 	"github.com/loykin/freader/internal/file_tracker"
 )
 
@@ -24,28 +29,45 @@ type Watcher struct {
 	interval             time.Duration
 	callback             func(id, path string)
 	removeCallback       func(id string)
+	replaceCallback      func(id, previous string)
+	upgradeCallback      func(id, provisional string)
+	owns                 func(id string) bool // see Config.Owns; nil owns every file
+	disownCallback       func(id string)
 	stopCh               chan struct{}
+	rescanCh             chan struct{} // scan requests from Rescan, coalesced
 	doneCh               chan struct{} // Signal when goroutine has finished
 	fileManager          *file_tracker.FileTracker
+	filterMu             sync.RWMutex // guards include and exclude
 	exclude              []string
 	include              []string
+	excludeDirs          []string // see Config.ExcludeDirs
+	ignoreFile           string   // see Config.IgnoreFile
+	fingerprintOffset    int64    // see Config.FingerprintOffset
+	provisionalIDs       bool     // see Config.ProvisionalIDs
+	keepDuplicates       bool     // see Config.KeepDuplicates
+	skipLocked           bool     // see Config.SkipLocked
+	onLocked             func(path string)
+	retiredMu            sync.Mutex
+	retired              map[string]uint64 // files scans do not track again, by the scan retiring them; see Retire
+	duplicates           map[string]string // paths the last scan skipped as duplicates, and where their file is read
+	logger               *slog.Logger
+	lastScanAt           atomic.Int64 // unix nanos of the last completed scan
+	lastScanDur          atomic.Int64
+	missedScans          int
+	missed               map[string]int // consecutive scans each tracked file was not seen; used by scan only
+	unreadable           *UnreadableFiles
+	decisions            *DecisionLog // nil disables recording
+	trace                *DecisionLog // per-scan evaluation of every file; nil disables tracing
+	scans                atomic.Uint64
+	scanBudget           time.Duration // see Config.ScanBudget
+	scanMaxFiles         int
+	cycle                *scanCycle // scan resumed on the next tick; nil between scans
+	clock                clock.Clock
 }
 
 func NewWatcher(config Config, cb func(id, path string), removeCb func(id string)) (*Watcher, error) {
-	// Derive roots from include patterns (single unified concept).
-	paths := deriveScanRoots(config.Include)
-
-	for i := 0; i < len(paths); i++ {
-		base := filepath.Clean(paths[i])
-		for j := 0; j < len(paths); j++ {
-			if i == j {
-				continue
-			}
-			other := filepath.Clean(paths[j])
-			if isSubPath(base, other) {
-				return nil, errors.New("overlapping watch paths: " + base + " is subpath of " + other)
-			}
-		}
+	if err := checkOverlappingRoots(config.Include); err != nil {
+		return nil, err
 	}
 
 	// Validate strategy-specific requirements via Config.Validate
@@ -53,6 +75,23 @@ func NewWatcher(config Config, cb func(id, path string), removeCb func(id string
 		return nil, err
 	}
 
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	unreadable := config.Unreadable
+	if unreadable == nil {
+		unreadable = NewUnreadableFiles(config.PollInterval)
+	}
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real()
+	} else {
+		unreadable.now = clk.Now
+		config.Decisions.setNow(clk.Now)
+		config.Trace.setNow(clk.Now)
+	}
+
 	return &Watcher{
 		interval:             config.PollInterval,
 		callback:             cb,
@@ -60,61 +99,254 @@ func NewWatcher(config Config, cb func(id, path string), removeCb func(id string
 		FingerprintSize:      config.FingerprintSize,
 		FingerprintSeparator: config.FingerprintSeparator,
 		removeCallback:       removeCb,
+		replaceCallback:      config.OnReplace,
+		upgradeCallback:      config.OnUpgrade,
+		owns:                 config.Owns,
+		disownCallback:       config.OnDisown,
 		stopCh:               make(chan struct{}),
+		rescanCh:             make(chan struct{}, 1),
 		doneCh:               make(chan struct{}),
 		fileManager:          config.FileTracker,
-		exclude:              config.Exclude,
-		include:              config.Include,
+		exclude:              append([]string(nil), config.Exclude...),
+		include:              append([]string(nil), config.Include...),
+		excludeDirs:          cleanDirPatterns(config.ExcludeDirs),
+		ignoreFile:           config.IgnoreFile,
+		fingerprintOffset:    config.FingerprintOffset,
+		provisionalIDs:       config.ProvisionalIDs,
+		keepDuplicates:       config.KeepDuplicates,
+		retired:              make(map[string]uint64),
+		logger:               logger,
+		missedScans:          config.MissedScans,
+		missed:               make(map[string]int),
+		unreadable:           unreadable,
+		skipLocked:           config.SkipLocked,
+		onLocked:             config.OnLocked,
+		decisions:            config.Decisions,
+		trace:                config.Trace,
+		scanBudget:           config.ScanBudget,
+		scanMaxFiles:         config.ScanMaxFiles,
+		clock:                clk,
 	}, nil
 }
 
-// computeFileID computes the file fingerprint/id according to the watcher's strategy.
-// Returns ok=false for expected skip conditions (e.g., zero-size, too small, not enough separators).
-func (w *Watcher) computeFileID(p string, info fs.FileInfo) (string, bool) {
+// checkOverlappingRoots rejects include sets whose scan roots nest inside each other,
+// which would make the same file discoverable twice.
+func checkOverlappingRoots(includes []string) error {
+	// Derive roots from include patterns (single unified concept).
+	paths := deriveScanRoots(includes)
+
+	for i := 0; i < len(paths); i++ {
+		base := filepath.Clean(paths[i])
+		for j := 0; j < len(paths); j++ {
+			if i == j {
+				continue
+			}
+			other := filepath.Clean(paths[j])
+			if isSubPath(base, other) {
+				return errors.New("overlapping watch paths: " + base + " is subpath of " + other)
+			}
+		}
+	}
+	return nil
+}
+
+// AddInclude adds an include pattern, effective from the next scan. Adding a pattern
+// that is already present is a no-op; a pattern whose scan root overlaps an existing
+// one is rejected like in NewWatcher.
+func (w *Watcher) AddInclude(pattern string) error {
+	w.filterMu.Lock()
+	defer w.filterMu.Unlock()
+
+	for _, p := range w.include {
+		if p == pattern {
+			return nil
+		}
+	}
+	next := append(append([]string(nil), w.include...), pattern)
+	if err := validateInclude(next); err != nil {
+		return err
+	}
+	if err := checkOverlappingRoots(next); err != nil {
+		return err
+	}
+	w.include = next
+	return nil
+}
+
+// RemoveInclude removes an include pattern, effective from the next scan. Files only
+// matched by the removed pattern are dropped from tracking on that scan. It reports
+// whether the pattern was present.
+func (w *Watcher) RemoveInclude(pattern string) bool {
+	w.filterMu.Lock()
+	defer w.filterMu.Unlock()
+
+	for i, p := range w.include {
+		if p == pattern {
+			w.include = append(append([]string(nil), w.include[:i]...), w.include[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetExclude replaces the exclude patterns, effective from the next scan.
+func (w *Watcher) SetExclude(patterns []string) {
+	w.filterMu.Lock()
+	defer w.filterMu.Unlock()
+
+	w.exclude = append([]string(nil), patterns...)
+}
+
+// Include returns a copy of the current include patterns.
+func (w *Watcher) Include() []string {
+	w.filterMu.RLock()
+	defer w.filterMu.RUnlock()
+
+	return append([]string(nil), w.include...)
+}
+
+// Exclude returns a copy of the current exclude patterns.
+func (w *Watcher) Exclude() []string {
+	w.filterMu.RLock()
+	defer w.filterMu.RUnlock()
+
+	return append([]string(nil), w.exclude...)
+}
+
+// computeFileID computes the file fingerprint/id according to the watcher's strategy
+// and returns it with the strategy it was computed with, which is deviceAndInode for
+// the provisional IDs of files too small for a checksum; see Config.ProvisionalIDs.
+// For expected skip conditions (e.g., zero-size, too small, not enough separators) and
+// failures it returns an empty id and the reason.
+func (w *Watcher) computeFileID(p string, info fs.FileInfo) (string, string, string) {
 	if info == nil {
-		return "", false
+		return "", "", "no file info"
 	}
 	// Skip empty files to avoid premature detection
 	if info.Size() == 0 {
-		return "", false
+		return "", "", "empty file"
 	}
 	var (
-		id  string
-		err error
+		id       string
+		err      error
+		strategy = w.FingerprintStrategy
 	)
 	switch w.FingerprintStrategy {
 	case FingerprintStrategyChecksum:
-		id, err = file_tracker.GetFileFingerprintFromPath(p, int64(w.FingerprintSize))
+		id, err = file_tracker.GetFileFingerprintRangeFromPath(p, w.fingerprintOffset, int64(w.FingerprintSize))
 		if file_tracker.IsFileSizeTooSmall(err) {
-			return "", false
+			reason := fmt.Sprintf("too small: %d bytes, fingerprint needs %d", info.Size(), w.fingerprintOffset+int64(w.FingerprintSize))
+			if !w.provisionalIDs {
+				return "", "", reason
+			}
+			if id, err = file_tracker.GetFileIDFromPath(p); err != nil {
+				return "", "", w.fingerprintFailed(p, "failed to get file inode", err)
+			}
+			strategy = FingerprintStrategyDeviceAndInode
 		} else if err != nil {
-			slog.Warn("failed to get file fingerprint", "path", p, "error", err)
-			return "", false
+			return "", "", w.fingerprintFailed(p, "failed to get file fingerprint", err)
 		}
 	case FingerprintStrategyChecksumSeparator:
 		id, err = file_tracker.GetFileFingerprintUntilNSeparatorsFromPath(p, w.FingerprintSeparator, w.FingerprintSize)
 		if file_tracker.IsNotEnoughSeparators(err) {
-			return "", false
+			return "", "", fmt.Sprintf("not enough separators: fingerprint needs %d", w.FingerprintSize)
 		} else if err != nil {
-			slog.Warn("failed to get file fingerprint (separator)", "path", p, "error", err)
-			return "", false
+			return "", "", w.fingerprintFailed(p, "failed to get file fingerprint (separator)", err)
 		}
 	case FingerprintStrategyDeviceAndInode:
 		id, err = file_tracker.GetFileIDFromPath(p)
 		if err != nil {
-			slog.Warn("failed to get file inode", "path", p, "error", err)
-			return "", false
+			return "", "", w.fingerprintFailed(p, "failed to get file inode", err)
 		}
 	default:
 		// preserve previous behavior: return an error to stop walk on unexpected strategy
-		slog.Error("unsupported fingerprint strategy", "strategy", w.FingerprintStrategy)
-		return "", false
+		w.logger.Error("unsupported fingerprint strategy", "strategy", w.FingerprintStrategy)
+		return "", "", "unsupported fingerprint strategy " + w.FingerprintStrategy
 	}
-	return id, true
+	if w.unreadable.Succeed(p) {
+		w.logger.Info("file is readable again", "path", p)
+	}
+	return id, strategy, ""
+}
+
+// provisionalID returns the provisional ID the file at p is tracked under, if it has
+// one; see Config.ProvisionalIDs.
+func (w *Watcher) provisionalID(p string) string {
+	if !w.provisionalIDs {
+		return ""
+	}
+	id, err := file_tracker.GetFileIDFromPath(p)
+	if err != nil {
+		return ""
+	}
+	if f := w.fileManager.Get(id); f != nil && f.FingerprintStrategy == FingerprintStrategyDeviceAndInode {
+		return id
+	}
+	if w.keepDuplicates {
+		if f := w.fileManager.Get(DuplicateID(id, p)); f != nil && f.FingerprintStrategy == FingerprintStrategyDeviceAndInode {
+			return DuplicateID(id, p)
+		}
+	}
+	return ""
+}
+
+// fingerprintFailed logs a fingerprint failure and returns it as a skip reason.
+// Permission errors and files locked by another process are tracked in w.unreadable
+// and retried with back-off, and only logged as warnings the first time; with
+// Config.SkipLocked, locked files are only logged at debug level.
+func (w *Watcher) fingerprintFailed(p, msg string, err error) string {
+	reason := msg + ": " + err.Error()
+	w.decisions.Record(Decision{Action: DecisionSkipped, Path: p, Reason: reason})
+	if file_tracker.IsLocked(err) {
+		if w.onLocked != nil {
+			w.onLocked(p)
+		}
+		if w.skipLocked {
+			w.logger.Debug("file locked by another process, skipping", "path", p, "error", err)
+		} else if w.unreadable.Fail(p, err) {
+			w.logger.Warn("file locked by another process, retrying with back-off", "path", p, "error", err)
+		} else {
+			w.logger.Debug("file still locked", "path", p, "error", err)
+		}
+		return reason
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		w.logger.Warn(msg, "path", p, "error", err)
+		return reason
+	}
+	if w.unreadable.Fail(p, err) {
+		w.logger.Warn("permission denied, retrying with back-off", "path", p, "error", err)
+	} else {
+		w.logger.Debug("permission still denied", "path", p, "error", err)
+	}
+	return reason
+}
+
+// Retire keeps scans from tracking the file id again after it was dropped, as long as
+// they keep finding it, e.g. a file rotated away when only the file at a path is
+// followed. The caller removes it from the tracker.
+func (w *Watcher) Retire(id string) {
+	w.retiredMu.Lock()
+	defer w.retiredMu.Unlock()
+
+	w.retired[id] = w.scans.Load()
+}
+
+func (w *Watcher) isRetired(id string) bool {
+	w.retiredMu.Lock()
+	defer w.retiredMu.Unlock()
+
+	_, ok := w.retired[id]
+	return ok
+}
+
+// Unreadable returns the files and directories currently failing with permission errors.
+func (w *Watcher) Unreadable() []UnreadableFile {
+	return w.unreadable.List()
 }
 
 func (w *Watcher) Start() {
-	ticker := time.NewTicker(w.interval)
+	ticker := w.clock.NewTicker(w.interval)
 
 	go func() {
 		defer func() {
@@ -123,19 +355,32 @@ func (w *Watcher) Start() {
 		}()
 
 		// Perform an immediate scan on start
-		w.scan()
+		w.scan(true)
 
 		for {
 			select {
 			case <-w.stopCh:
 				return
-			case <-ticker.C:
-				w.scan()
+			case <-ticker.C():
+				w.scan(true)
+			case <-w.rescanCh:
+				w.scan(true)
 			}
 		}
 	}()
 }
 
+// Rescan makes the running watcher scan now instead of on the next tick of the poll
+// interval, e.g. right after the application created files it wants read. Requests
+// made before the scan starts are served by the same scan. A request made before
+// Start is served right after the initial scan.
+func (w *Watcher) Rescan() {
+	select {
+	case w.rescanCh <- struct{}{}:
+	default:
+	}
+}
+
 func (w *Watcher) Stop() {
 	select {
 	case <-w.stopCh:
@@ -151,67 +396,350 @@ func (w *Watcher) StopAndWait() {
 	<-w.doneCh // Wait for goroutine to finish
 }
 
-func (w *Watcher) scan() {
-	existingFiles := make(map[string]bool)
+// LastScan returns when the last scan finished and how long it took.
+// The zero time is returned before the first scan completes.
+func (w *Watcher) LastScan() (time.Time, time.Duration) {
+	at := w.lastScanAt.Load()
+	if at == 0 {
+		return time.Time{}, 0
+	}
+	return time.Unix(0, at), time.Duration(w.lastScanDur.Load())
+}
+
+// Scan runs a single scan synchronously, e.g. to list matching files without Start.
+func (w *Watcher) Scan() {
+	w.scan(false)
+}
+
+// scanCycle is a scan in progress. A scan that runs out of its budget stops after the
+// file it examined last and is resumed from there on the next tick. Files are only
+// dropped, and renames followed, once the scan has walked all roots.
+type scanCycle struct {
+	id          uint64
+	spent       time.Duration // time spent on the ticks before this one
+	include     []string
+	exclude     []string
+	hasSpecific bool
+	roots       []string
+	ignores     map[string]*ignoreRules // rules of the ignore file in each root
+	root        int                     // index in roots of the root being walked
+	resume      string                  // file in roots[root] examined last; "" walks it from the top
+	// Where tracked files were when the scan started, and where this scan finds them
+	tracked   map[string]file_tracker.TrackedFile
+	trackedAt map[string]string
+	idAt      map[string]string
+	foundAt   map[string]string
+	existing  map[string]bool
+	retired   map[string]bool // retired files found again
+	disowned  map[string]bool // tracked files found but now owned by another instance
+	// Paths skipped as duplicates, and where their file is read
+	duplicates map[string]string
+	// Paths seen by this scan, for pruning the unreadable set: directories are only
+	// kept there while listing them fails
+	visited, dirs, walkDenied map[string]bool
+}
+
+// newScanCycle starts a scan of the current filters and tracked files.
+func (w *Watcher) newScanCycle() *scanCycle {
+	cy := &scanCycle{
+		id:         w.scans.Add(1),
+		tracked:    w.fileManager.GetAllFiles(),
+		idAt:       make(map[string]string),
+		foundAt:    make(map[string]string),
+		existing:   make(map[string]bool),
+		retired:    make(map[string]bool),
+		duplicates: make(map[string]string),
+		disowned:   make(map[string]bool),
+		visited:    make(map[string]bool),
+		dirs:       make(map[string]bool),
+		walkDenied: make(map[string]bool),
+	}
+	cy.trackedAt = make(map[string]string, len(cy.tracked))
+	for id, f := range cy.tracked {
+		cy.trackedAt[f.Path] = id
+	}
+
+	// Snapshot filters so runtime changes apply from the next scan
+	w.filterMu.RLock()
+	cy.include, cy.exclude = w.include, w.exclude
+	w.filterMu.RUnlock()
 
 	// Determine if there are specific include patterns (globs or exact files)
-	hasSpecific := hasSpecificIncludes(w.include)
+	cy.hasSpecific = hasSpecificIncludes(cy.include)
 
 	// Derive roots dynamically from includes each scan (no persistent roots field)
-	roots := deriveScanRoots(w.include)
+	cy.roots = deriveScanRoots(cy.include)
+	cy.ignores = w.ignoreFiles(cy.roots)
+	return cy
+}
 
-	for _, root := range roots {
+// scan walks the include roots, adding new files, and drops tracked files no longer
+// found. With budgeted set, it stops once the scan budget is used up and carries on
+// from there on the next call; otherwise it completes the scan.
+func (w *Watcher) scan(budgeted bool) {
+	started := w.clock.Now()
+	if w.cycle == nil {
+		w.cycle = w.newScanCycle()
+	}
+	cy := w.cycle
+	examined := 0
+	exhausted := func() bool {
+		if !budgeted {
+			return false
+		}
+		return (w.scanMaxFiles > 0 && examined >= w.scanMaxFiles) ||
+			(w.scanBudget > 0 && w.clock.Since(started) >= w.scanBudget)
+	}
+
+	for ; cy.root < len(cy.roots); cy.root, cy.resume = cy.root+1, "" {
+		root, resume := cy.roots[cy.root], cy.resume
+		stopped := false
 		err := filepath.Walk(root, func(p string, info fs.FileInfo, err error) error {
+			if resume != "" {
+				// Skip what the previous tick examined, down to the file it stopped after
+				switch {
+				case isSubPath(resume, p):
+					return nil
+				case !walkOrderLess(resume, p):
+					if err == nil && info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				resume = ""
+			}
+			cy.visited[p] = true
 			if err != nil {
-				slog.Warn("failed to walk", "path", p, "error", err)
+				if errors.Is(err, fs.ErrPermission) {
+					cy.walkDenied[p] = true
+					if w.unreadable.Fail(p, err) {
+						w.logger.Warn("permission denied while walking", "path", p, "error", err)
+					}
+					return nil
+				}
+				w.logger.Warn("failed to walk", "path", p, "error", err)
 				return nil
 			}
 			if info != nil && info.IsDir() {
+				if pattern, ok := matchingPattern(p, w.excludeDirs); ok && p != root {
+					w.trace.Record(Decision{Action: DecisionExcluded, Path: p, Scan: cy.id,
+						Reason: "directory matches exclude-dirs pattern " + strconv.Quote(pattern)})
+					return filepath.SkipDir
+				}
+				if reason := cy.ignores[root].reason(root, p, true); reason != "" {
+					w.trace.Record(Decision{Action: DecisionExcluded, Path: p, Scan: cy.id, Reason: reason})
+					return filepath.SkipDir
+				}
+				cy.dirs[p] = true
 				return nil
 			}
 
-			// Filters: include first, then exclude
-			if len(w.include) > 0 && !pathIncluded(p, w.include, hasSpecific) {
-				return nil
-			}
-			if len(w.exclude) > 0 && pathExcluded(p, w.exclude) {
-				return nil
-			}
-
-			// Compute file ID according to strategy (with size/condition checks)
-			fileId, ok := w.computeFileID(p, info)
-			if !ok {
-				return nil
+			if reason := ignoredReason(cy.ignores, root, p); reason != "" {
+				w.trace.Record(Decision{Action: DecisionExcluded, Path: p, Scan: cy.id, Reason: reason})
+			} else {
+				w.examine(cy, p, info)
 			}
-
-			existingFiles[fileId] = true
-
-			if w.fileManager.Get(fileId) == nil {
-				w.fileManager.Add(fileId, p, w.FingerprintStrategy, int64(w.FingerprintSize), 0)
-				w.callback(fileId, p)
+			if examined++; exhausted() {
+				cy.resume, stopped = p, true
+				return filepath.SkipAll
 			}
 			return nil
 		})
+		if stopped {
+			cy.spent += w.clock.Since(started)
+			w.logger.Debug("scan budget used up, resuming on the next tick", "examined", examined, "after", cy.resume)
+			return
+		}
 		if err != nil {
-			slog.Error("failed to walk path", "path", root, "error", err)
+			w.logger.Error("failed to walk path", "path", root, "error", err)
 			continue
 		}
 	}
+	w.cycle = nil
+	w.finishScan(cy)
+	w.lastScanDur.Store(int64(cy.spent + w.clock.Since(started)))
+	w.lastScanAt.Store(w.clock.Now().UnixNano())
+}
+
+// examine evaluates the file at p for scan cy and starts tracking it if it is new.
+func (w *Watcher) examine(cy *scanCycle, p string, info fs.FileInfo) {
+	fileId, strategy, d := w.evaluate(p, info, cy.include, cy.exclude, cy.hasSpecific)
+	d.Scan = cy.id
+	if fileId == "" {
+		w.trace.Record(d)
+		return
+	}
 
-	for fileId := range w.fileManager.GetAllFiles() {
-		if !existingFiles[fileId] {
-			if w.removeCallback != nil {
+	if first := w.duplicateOf(cy, fileId, p); first != "" {
+		if !w.keepDuplicates {
+			cy.idAt[p] = fileId
+			cy.duplicates[p] = first
+			d.Action, d.Reason = DecisionSkipped, "duplicate of "+first+", read there"
+			w.trace.Record(d)
+			return
+		}
+		fileId = DuplicateID(fileId, p)
+		d.FileID = fileId
+	}
+	if w.isRetired(fileId) {
+		cy.retired[fileId] = true
+		d.Action, d.Reason = DecisionSkipped, "retired, e.g. rotated away with follow-name"
+		w.trace.Record(d)
+		return
+	}
+	if w.owns != nil && !w.owns(fileId) {
+		if w.fileManager.Get(fileId) != nil {
+			cy.disowned[fileId] = true
+		}
+		d.Action, d.Reason = DecisionSkipped, "owned by another shard"
+		w.trace.Record(d)
+		return
+	}
+	cy.existing[fileId] = true
+	cy.idAt[p] = fileId
+	if _, ok := cy.foundAt[fileId]; !ok {
+		cy.foundAt[fileId] = p
+	}
+
+	if w.fileManager.Get(fileId) == nil {
+		var provisional string
+		if strategy != w.FingerprintStrategy {
+			d.Reason = "new file, tracked by device and inode until it can be fingerprinted"
+		} else if provisional = w.provisionalID(p); provisional != "" {
+			d.Reason = "grown enough to fingerprint, was tracked as " + provisional
+		} else {
+			d.Reason = "new file"
+		}
+		w.trace.Record(d)
+		w.fileManager.Add(fileId, p, strategy, int64(w.FingerprintSize), 0)
+		if w.fingerprintOffset > 0 && strategy == FingerprintStrategyChecksum {
+			w.fileManager.UpdateFingerprintOffset(fileId, w.fingerprintOffset)
+		}
+		if provisional != "" {
+			w.upgrade(fileId, provisional, p)
+			return
+		}
+		w.decisions.Record(Decision{Action: DecisionAdded, Path: p, FileID: fileId, Reason: "new file matched"})
+		if prev, ok := cy.trackedAt[p]; ok && prev != fileId && w.replaceCallback != nil {
+			w.replaceCallback(fileId, prev)
+		}
+		w.callback(fileId, p)
+		return
+	}
+	d.Reason = "already tracked"
+	w.trace.Record(d)
+}
+
+// upgrade tracks the file at p, tracked under its provisional ID until now, under its
+// fingerprint id: OnUpgrade is called before the added callback for id, and the
+// provisional ID is dropped right after it like a removed file.
+func (w *Watcher) upgrade(id, provisional, p string) {
+	w.logger.Debug("file grown enough to fingerprint", "file", id, "provisional", provisional, "path", p)
+	w.decisions.Record(Decision{Action: DecisionAdded, Path: p, FileID: id, Reason: "grown enough to fingerprint, was tracked as " + provisional})
+	if w.upgradeCallback != nil {
+		w.upgradeCallback(id, provisional)
+	}
+	w.callback(id, p)
+	w.decisions.Record(Decision{Action: DecisionRemoved, Path: p, FileID: provisional, Reason: "now tracked as " + id})
+	delete(w.missed, provisional)
+	if w.removeCallback != nil {
+		w.removeCallback(provisional)
+	}
+	w.fileManager.Remove(provisional)
+}
+
+// finishScan follows renamed files and drops the tracked files scan cy did not find.
+func (w *Watcher) finishScan(cy *scanCycle) {
+	// Forget retired files that are gone, unless retired since the scan started
+	w.retiredMu.Lock()
+	for fileId, scan := range w.retired {
+		if !cy.retired[fileId] && scan < cy.id {
+			delete(w.retired, fileId)
+		}
+	}
+	w.retiredMu.Unlock()
+
+	w.recordDuplicates(cy)
+
+	// Forget unreadable paths that are gone, no longer included or listable again
+	w.unreadable.Prune(func(p string) bool { return cy.visited[p] && (!cy.dirs[p] || cy.walkDenied[p]) })
+
+	// Follow renamed files, unless their old path still holds them (hard links)
+	for fileId, f := range cy.tracked {
+		if p, ok := cy.foundAt[fileId]; ok && cy.idAt[f.Path] != fileId && w.fileManager.UpdatePath(fileId, p) {
+			w.logger.Debug("tracked file renamed", "file", fileId, "from", f.Path, "to", p)
+			w.decisions.Record(Decision{Action: DecisionRenamed, Path: p, FileID: fileId, Reason: "moved from " + f.Path})
+		}
+	}
+
+	tracked := w.fileManager.GetAllFiles()
+	for fileId := range tracked {
+		if cy.existing[fileId] {
+			delete(w.missed, fileId)
+			continue
+		}
+		if cy.disowned[fileId] {
+			delete(w.missed, fileId)
+			w.decisions.Record(Decision{Action: DecisionRemoved, Path: tracked[fileId].Path, FileID: fileId, Reason: "now owned by another shard"})
+			if w.disownCallback != nil {
+				w.disownCallback(fileId)
+			} else if w.removeCallback != nil {
 				w.removeCallback(fileId)
 			}
 			w.fileManager.Remove(fileId)
+			continue
+		}
+		if w.missed[fileId]++; w.missed[fileId] < w.missedScans {
+			w.logger.Debug("tracked file not seen, keeping it", "file", fileId, "missed", w.missed[fileId])
+			continue
+		}
+		delete(w.missed, fileId)
+		w.decisions.Record(Decision{Action: DecisionRemoved, Path: tracked[fileId].Path, FileID: fileId,
+			Reason: fmt.Sprintf("not seen by %d consecutive scan(s)", max(w.missedScans, 1))})
+		if w.removeCallback != nil {
+			w.removeCallback(fileId)
+		}
+		w.fileManager.Remove(fileId)
+	}
+	// Forget files dropped elsewhere (e.g. by a reader) while they were missing
+	for fileId := range w.missed {
+		if _, ok := tracked[fileId]; !ok {
+			delete(w.missed, fileId)
+		}
+	}
+}
+
+// cleanDirPatterns returns the ExcludeDirs patterns without trailing separators.
+func cleanDirPatterns(patterns []string) []string {
+	cleaned := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern = strings.TrimRight(pattern, "/"+string(filepath.Separator)); pattern != "" {
+			cleaned = append(cleaned, pattern)
 		}
 	}
+	return cleaned
+}
+
+// excludedDir returns the directory below one of roots and above p that matches an
+// ExcludeDirs pattern, and the pattern; scans do not reach p.
+func (w *Watcher) excludedDir(p string, roots []string) (string, string, bool) {
+	for _, root := range roots {
+		for dir := filepath.Dir(filepath.Clean(p)); isSubPath(dir, root); dir = filepath.Dir(dir) {
+			if pattern, ok := matchingPattern(dir, w.excludeDirs); ok {
+				return dir, pattern, true
+			}
+		}
+	}
+	return "", "", false
 }
 
 // hasSpecificIncludes returns true if includes contain any glob, non-existent path,
 // or an explicit file (non-directory). This affects how broad directory includes are treated.
 func hasSpecificIncludes(includes []string) bool {
 	for _, pattern := range includes {
+		if _, negated := negatedPattern(pattern); negated {
+			continue
+		}
 		cp := filepath.Clean(pattern)
 		if hasMeta(cp) {
 			return true
@@ -228,7 +756,17 @@ func hasSpecificIncludes(includes []string) bool {
 
 // pathIncluded checks whether path p should be included according to include patterns.
 // When hasSpecific is true (globs or explicit files present), broad directory includes are ignored as filters.
+//
+// Includes with "!pattern" entries are evaluated in order instead: every entry,
+// directories included, is a filter, and the last entry matching p decides, so
+// ["/var/log", "!/var/log/journal", "/var/log/journal/upload.log"] takes everything
+// under /var/log except the journal directory, but upload.log in it.
 func pathIncluded(p string, includes []string, hasSpecific bool) bool {
+	if hasNegation(includes) {
+		entry, ok := lastMatchingInclude(p, includes)
+		_, negated := negatedPattern(entry)
+		return ok && !negated
+	}
 	base := filepath.Base(p)
 	for _, pattern := range includes {
 		cleanPat := filepath.Clean(pattern)
@@ -260,16 +798,48 @@ func pathIncluded(p string, includes []string, hasSpecific bool) bool {
 	return false
 }
 
-// pathExcluded checks whether path p matches any exclude pattern.
-func pathExcluded(p string, excludes []string) bool {
+// lastMatchingInclude returns the last include entry matching p, which decides whether
+// ordered includes take p.
+func lastMatchingInclude(p string, includes []string) (string, bool) {
+	for i := len(includes) - 1; i >= 0; i-- {
+		if pattern, _ := negatedPattern(includes[i]); includeMatches(p, pattern) {
+			return includes[i], true
+		}
+	}
+	return "", false
+}
+
+// includeMatches reports whether p matches one include entry: a file below it for a
+// directory, the same file for a file path, or the base name or full path for a glob.
+func includeMatches(p, pattern string) bool {
+	cleanPat := filepath.Clean(pattern)
+	if hasMeta(cleanPat) {
+		_, ok := matchingPattern(p, []string{cleanPat})
+		return ok
+	}
+	if fi, err := os.Stat(cleanPat); (err == nil && fi.IsDir()) || strings.HasSuffix(pattern, string(filepath.Separator)) {
+		return isSubPath(p, cleanPat)
+	}
+	return filepath.Clean(p) == cleanPat || filepath.Base(p) == cleanPat
+}
+
+// MatchesAny reports whether path p matches any of the glob patterns, tried against
+// both the base name and the full path. Exclude patterns use the same matching.
+func MatchesAny(p string, patterns []string) bool {
+	_, ok := matchingPattern(p, patterns)
+	return ok
+}
+
+// matchingPattern returns the first of patterns matching p, as in MatchesAny.
+func matchingPattern(p string, patterns []string) (string, bool) {
 	base := filepath.Base(p)
-	for _, pattern := range excludes {
+	for _, pattern := range patterns {
 		if ok, _ := filepath.Match(pattern, base); ok {
-			return true
+			return pattern, true
 		}
 		if ok, _ := filepath.Match(pattern, p); ok {
-			return true
+			return pattern, true
 		}
 	}
-	return false
+	return "", false
 }
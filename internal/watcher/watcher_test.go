@@ -11,6 +11,7 @@ import (
 	"github.com/loykin/freader/internal/file_tracker"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -389,3 +390,462 @@ func TestWatcher_ChecksumSeparator_ValidateMissingSeparator(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "fingerprint separator must be set for checksumSeparator strategy", err.Error())
 }
+
+func TestWatcher_RuntimeIncludeExclude(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based watcher tests on Windows")
+	}
+	base := t.TempDir()
+	tenantA := filepath.Join(base, "tenant-a")
+	tenantB := filepath.Join(base, "tenant-b")
+	for _, dir := range []string{tenantA, tenantB} {
+		assert.NoError(t, os.MkdirAll(dir, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "app.log"), []byte("x\n"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "debug.log"), []byte("y\n"), 0644))
+	}
+
+	tracker := file_tracker.New()
+	tracked := func() map[string]bool {
+		paths := map[string]bool{}
+		for _, f := range tracker.GetAllFiles() {
+			rel, _ := filepath.Rel(base, f.Path)
+			paths[filepath.ToSlash(rel)] = true
+		}
+		return paths
+	}
+
+	w, err := NewWatcher(Config{
+		Include:             []string{tenantA},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         tracker,
+	}, func(id, path string) {}, func(id string) {})
+	assert.NoError(t, err)
+
+	w.scan(false)
+	assert.Equal(t, map[string]bool{"tenant-a/app.log": true, "tenant-a/debug.log": true}, tracked())
+
+	assert.NoError(t, w.AddInclude(tenantB))
+	assert.NoError(t, w.AddInclude(tenantB), "adding an existing pattern is a no-op")
+	nested := filepath.Join(tenantA, "nested")
+	assert.NoError(t, os.MkdirAll(nested, 0755))
+	assert.Error(t, w.AddInclude(nested), "overlapping roots are rejected")
+	w.scan(false)
+	assert.Len(t, tracked(), 4)
+
+	w.SetExclude([]string{"debug.log"})
+	w.scan(false)
+	assert.Equal(t, map[string]bool{"tenant-a/app.log": true, "tenant-b/app.log": true}, tracked())
+
+	assert.True(t, w.RemoveInclude(tenantA))
+	assert.False(t, w.RemoveInclude(tenantA))
+	w.scan(false)
+	assert.Equal(t, map[string]bool{"tenant-b/app.log": true}, tracked())
+	assert.Equal(t, []string{tenantB}, w.Include())
+	assert.Equal(t, []string{"debug.log"}, w.Exclude())
+}
+
+func TestWatcher_NegatedInclude(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based watcher tests on Windows")
+	}
+	base := t.TempDir()
+	journal := filepath.Join(base, "journal")
+	assert.NoError(t, os.MkdirAll(journal, 0755))
+	for _, p := range []string{"syslog", "app.log", "app.log.gz", "journal/system.journal", "journal/upload.log"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(base, p), []byte("x\n"), 0644))
+	}
+
+	tracker := file_tracker.New()
+	tracked := func() map[string]bool {
+		paths := map[string]bool{}
+		for _, f := range tracker.GetAllFiles() {
+			rel, _ := filepath.Rel(base, f.Path)
+			paths[filepath.ToSlash(rel)] = true
+		}
+		return paths
+	}
+
+	// Everything under base except the journal directory and compressed files, but
+	// upload.log in the journal directory; the nested file is not an overlapping root
+	w, err := NewWatcher(Config{
+		Include:             []string{base, "!" + journal, "!*.gz", filepath.Join(journal, "upload.log")},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         tracker,
+	}, func(id, path string) {}, func(id string) {})
+	assert.NoError(t, err)
+	w.scan(false)
+	assert.Equal(t, map[string]bool{"syslog": true, "app.log": true, "journal/upload.log": true}, tracked())
+
+	// A later negation wins over an earlier include
+	assert.NoError(t, w.AddInclude("!syslog"))
+	w.scan(false)
+	assert.Equal(t, map[string]bool{"app.log": true, "journal/upload.log": true}, tracked())
+
+	d := w.Explain(filepath.Join(journal, "system.journal"))
+	assert.Equal(t, DecisionExcluded, d.Action)
+	assert.Equal(t, `matches negated include pattern "!`+journal+`"`, d.Reason)
+}
+
+func TestConfig_ValidateNegatedInclude(t *testing.T) {
+	cfg := Config{FingerprintStrategy: FingerprintStrategyDeviceAndInode}
+	cfg.Include = []string{"!*.gz", "/var/log"}
+	assert.ErrorContains(t, cfg.Validate(), "must follow a pattern")
+	cfg.Include = []string{"/var/log", "!"}
+	assert.ErrorContains(t, cfg.Validate(), "empty")
+	cfg.Include = []string{"/var/log", "!*.gz"}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestWatcher_MissedScans(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	assert.NoError(t, os.WriteFile(p, []byte("0123456789\n"), 0644))
+
+	tracker := file_tracker.New()
+	var removed []string
+	w, err := NewWatcher(Config{
+		Include:             []string{dir},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		FileTracker:         tracker,
+		MissedScans:         3,
+	}, func(id, path string) {}, func(id string) { removed = append(removed, id) })
+	assert.NoError(t, err)
+
+	w.scan(false)
+	assert.Len(t, tracker.GetAllFiles(), 1)
+
+	// A stale attribute cache briefly reports the file as too short to fingerprint
+	assert.NoError(t, os.WriteFile(p, []byte("0123"), 0644))
+	w.scan(false)
+	w.scan(false)
+	assert.Len(t, tracker.GetAllFiles(), 1, "kept while missed fewer than MissedScans times")
+	assert.Empty(t, removed)
+
+	assert.NoError(t, os.WriteFile(p, []byte("0123456789\n"), 0644))
+	w.scan(false)
+	w.scan(false)
+	w.scan(false)
+	assert.Empty(t, removed, "seeing the file again resets the count")
+
+	assert.NoError(t, os.Remove(p))
+	w.scan(false)
+	w.scan(false)
+	w.scan(false)
+	assert.Len(t, removed, 1)
+	assert.Empty(t, tracker.GetAllFiles())
+
+	_, err = NewWatcher(Config{FingerprintStrategy: FingerprintStrategyDeviceAndInode, MissedScans: -1}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestWatcher_Rename(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	assert.NoError(t, os.WriteFile(p, []byte("first\n"), 0644))
+
+	tracker := file_tracker.New()
+	var added, replaced []string
+	w, err := NewWatcher(Config{
+		Include:             []string{dir},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         tracker,
+		Decisions:           NewDecisionLog(0),
+		OnReplace: func(id, previous string) {
+			replaced = append(replaced, id+"<"+previous)
+		},
+	}, func(id, path string) { added = append(added, id) }, func(id string) {})
+	assert.NoError(t, err)
+
+	w.scan(false)
+	assert.Len(t, added, 1)
+	old := added[0]
+
+	// Rename rotation: the tracked file moves and a new file takes its path
+	assert.NoError(t, os.Rename(p, p+".1"))
+	assert.NoError(t, os.WriteFile(p, []byte("second\n"), 0644))
+	w.scan(false)
+	assert.Len(t, added, 2)
+	assert.Equal(t, []string{added[1] + "<" + old}, replaced)
+	assert.Equal(t, p+".1", tracker.Get(old).Path)
+	assert.Equal(t, p, tracker.Get(added[1]).Path)
+
+	var renamed []Decision
+	for _, d := range w.decisions.List() {
+		if d.Action == DecisionRenamed {
+			renamed = append(renamed, d)
+		}
+	}
+	if assert.Len(t, renamed, 1) {
+		assert.Equal(t, p+".1", renamed[0].Path)
+		assert.Equal(t, "moved from "+p, renamed[0].Reason)
+	}
+
+	// A hard link found first keeps the file at its path
+	assert.NoError(t, os.Link(p, filepath.Join(dir, "a.log")))
+	w.scan(false)
+	assert.Equal(t, p, tracker.Get(added[1]).Path)
+}
+
+func TestWatcher_ScanBudget(t *testing.T) {
+	base := t.TempDir()
+	var paths []string
+	for _, rel := range []string{"a/1.log", "a/2.log", "a-b.log", "b/c/3.log", "b/4.log", "z.log"} {
+		p := filepath.Join(base, filepath.FromSlash(rel))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, os.WriteFile(p, []byte(rel+"\n"), 0644))
+		paths = append(paths, p)
+	}
+
+	tracker := file_tracker.New()
+	var added, removed []string
+	w, err := NewWatcher(Config{
+		Include:             []string{base},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         tracker,
+		ScanMaxFiles:        4,
+	}, func(id, path string) { added = append(added, path) }, func(id string) { removed = append(removed, id) })
+	assert.NoError(t, err)
+
+	w.scan(true)
+	assert.Len(t, added, 4)
+	at, _ := w.LastScan()
+	assert.True(t, at.IsZero(), "a scan stopped by its budget is not complete")
+
+	// A file already walked goes missing, but is only dropped after a complete scan
+	assert.NoError(t, os.Remove(filepath.Join(base, "a", "1.log")))
+	w.scan(true)
+	assert.ElementsMatch(t, paths, added, "the second slice picks up where the first stopped")
+	assert.Empty(t, removed)
+	at, _ = w.LastScan()
+	assert.False(t, at.IsZero())
+
+	w.scan(true)
+	w.scan(true)
+	assert.Len(t, removed, 1)
+	assert.Len(t, added, len(paths))
+
+	// Scan completes regardless of the budget
+	w.Scan()
+	assert.Len(t, tracker.GetAllFiles(), len(paths)-1)
+}
+
+func TestWatcher_ExcludeDirs(t *testing.T) {
+	base := t.TempDir()
+	for _, rel := range []string{"app.log", "node_modules/dep/x.log", ".git/HEAD.log", "a/archived/old.log", "a/cur.log"} {
+		p := filepath.Join(base, filepath.FromSlash(rel))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, os.WriteFile(p, []byte(rel+"\n"), 0644))
+	}
+
+	tracker := file_tracker.New()
+	trace := NewDecisionLog(0)
+	w, err := NewWatcher(Config{
+		Include:             []string{base},
+		ExcludeDirs:         []string{"node_modules", ".git/", filepath.Join(base, "a", "archived")},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         tracker,
+		Trace:               trace,
+	}, func(id, path string) {}, func(id string) {})
+	assert.NoError(t, err)
+	w.Scan()
+
+	var paths []string
+	for _, f := range tracker.GetAllFiles() {
+		rel, _ := filepath.Rel(base, f.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	assert.ElementsMatch(t, []string{"app.log", "a/cur.log"}, paths)
+
+	// Pruned directories are traced, the files below them are not even looked at
+	pruned := map[string]bool{}
+	for _, d := range trace.List() {
+		rel, _ := filepath.Rel(base, d.Path)
+		if d.Action == DecisionExcluded {
+			pruned[filepath.ToSlash(rel)] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{"node_modules": true, ".git": true, "a/archived": true}, pruned)
+
+	d := w.Explain(filepath.Join(base, "node_modules", "dep", "x.log"))
+	assert.Equal(t, DecisionExcluded, d.Action)
+	assert.Contains(t, d.Reason, `exclude-dirs pattern "node_modules"`)
+
+	// An include root is walked even if it matches
+	tracker = file_tracker.New()
+	w, err = NewWatcher(Config{
+		Include:             []string{filepath.Join(base, "node_modules")},
+		ExcludeDirs:         []string{"node_modules"},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         tracker,
+	}, func(id, path string) {}, func(id string) {})
+	assert.NoError(t, err)
+	w.Scan()
+	assert.Len(t, tracker.GetAllFiles(), 1)
+}
+
+func TestWatcher_FingerprintOffset(t *testing.T) {
+	base := t.TempDir()
+	header := "time,level,message\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "a.csv"), []byte(header+"1,INFO,started\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "b.csv"), []byte(header+"2,WARN,retrying\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "c.csv"), []byte(header), 0644))
+
+	ft := file_tracker.New()
+	cfg := Config{
+		Include:             []string{base},
+		PollInterval:        time.Second,
+		FingerprintStrategy: FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		FingerprintOffset:   int64(len(header)),
+		FileTracker:         ft,
+	}
+	w, err := NewWatcher(cfg, func(id, path string) {}, func(id string) {})
+	require.NoError(t, err)
+	w.Scan()
+
+	files := ft.GetAllFiles()
+	require.Len(t, files, 2) // c.csv holds only the header
+	for _, f := range files {
+		assert.Equal(t, int64(len(header)), f.FingerprintOffset)
+	}
+	assert.Contains(t, w.Explain(filepath.Join(base, "c.csv")).Reason, "too small")
+
+	cfg.FingerprintStrategy = FingerprintStrategyDeviceAndInode
+	_, err = NewWatcher(cfg, func(id, path string) {}, func(id string) {})
+	assert.Error(t, err)
+}
+
+func TestWatcher_ProvisionalIDs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip inode-based tests on Windows")
+	}
+	base := t.TempDir()
+	p := filepath.Join(base, "app.log")
+	require.NoError(t, os.WriteFile(p, []byte("short\n"), 0644))
+	inode, err := file_tracker.GetFileIDFromPath(p)
+	require.NoError(t, err)
+
+	ft := file_tracker.New()
+	var events []string
+	cfg := Config{
+		Include:             []string{base},
+		PollInterval:        time.Second,
+		FingerprintStrategy: FingerprintStrategyChecksum,
+		FingerprintSize:     16,
+		ProvisionalIDs:      true,
+		FileTracker:         ft,
+		OnUpgrade:           func(id, provisional string) { events = append(events, "upgrade "+provisional) },
+	}
+	w, err := NewWatcher(cfg, func(id, path string) { events = append(events, "add "+id) },
+		func(id string) { events = append(events, "remove "+id) })
+	require.NoError(t, err)
+
+	w.Scan()
+	w.Scan()
+	require.NotNil(t, ft.Get(inode))
+	assert.Equal(t, FingerprintStrategyDeviceAndInode, ft.Get(inode).FingerprintStrategy)
+	assert.Equal(t, []string{"add " + inode}, events)
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("grown past the fingerprint\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	id, err := file_tracker.GetFileFingerprintFromPath(p, 16)
+	require.NoError(t, err)
+
+	w.Scan()
+	assert.Equal(t, []string{"add " + inode, "upgrade " + inode, "add " + id, "remove " + inode}, events)
+	assert.Nil(t, ft.Get(inode))
+	require.NotNil(t, ft.Get(id))
+	assert.Equal(t, FingerprintStrategyChecksum, ft.Get(id).FingerprintStrategy)
+
+	cfg.FingerprintStrategy = FingerprintStrategyDeviceAndInode
+	_, err = NewWatcher(cfg, func(id, path string) {}, func(id string) {})
+	assert.Error(t, err)
+}
+
+func TestWatcher_Rescan(t *testing.T) {
+	base := t.TempDir()
+	added := make(chan string, 1)
+	cfg := Config{
+		Include:             []string{base},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyDeviceAndInode,
+		FileTracker:         file_tracker.New(),
+	}
+	w, err := NewWatcher(cfg, func(id, path string) { added <- path }, func(id string) {})
+	require.NoError(t, err)
+	w.Start()
+	defer w.Stop()
+	require.Eventually(t, func() bool {
+		scanned, _ := w.LastScan()
+		return !scanned.IsZero()
+	}, time.Second, 10*time.Millisecond)
+
+	p := filepath.Join(base, "new.log")
+	require.NoError(t, os.WriteFile(p, []byte("x\n"), 0644))
+	w.Rescan()
+	select {
+	case got := <-added:
+		assert.Equal(t, p, got)
+	case <-time.After(2 * time.Second):
+		t.Fatal("file not found by the requested scan")
+	}
+}
+
+func TestWatcher_Owns(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.log")
+	b := filepath.Join(dir, "b.log")
+	require.NoError(t, os.WriteFile(a, []byte("aaaaaaaa\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("bbbbbbbb\n"), 0644))
+	idA, err := file_tracker.GetFileFingerprintFromPath(a, 8)
+	require.NoError(t, err)
+	idB, err := file_tracker.GetFileFingerprintFromPath(b, 8)
+	require.NoError(t, err)
+
+	tracker := file_tracker.New()
+	decisions := NewDecisionLog(0)
+	owner := idA
+	var removed, disowned []string
+	w, err := NewWatcher(Config{
+		Include:             []string{dir},
+		PollInterval:        time.Hour,
+		FingerprintStrategy: FingerprintStrategyChecksum,
+		FingerprintSize:     8,
+		FileTracker:         tracker,
+		MissedScans:         3,
+		Decisions:           decisions,
+		Owns:                func(id string) bool { return id == owner },
+		OnDisown:            func(id string) { disowned = append(disowned, id) },
+	}, func(id, path string) {}, func(id string) { removed = append(removed, id) })
+	require.NoError(t, err)
+
+	w.scan(false)
+	files := tracker.GetAllFiles()
+	require.Len(t, files, 1)
+	assert.Contains(t, files, idA)
+
+	// Ownership moves at once, without waiting for MissedScans, and is not a removal
+	owner = idB
+	w.scan(false)
+	files = tracker.GetAllFiles()
+	require.Len(t, files, 1)
+	assert.Contains(t, files, idB)
+	assert.Equal(t, []string{idA}, disowned)
+	assert.Empty(t, removed)
+	log := decisions.List()
+	last := log[len(log)-1]
+	assert.Equal(t, DecisionRemoved, last.Action)
+	assert.Equal(t, idA, last.FileID)
+	assert.Equal(t, "now owned by another shard", last.Reason)
+}
@@ -0,0 +1,282 @@
+// Package grpcsink streams collected records to a gRPC service implementing the
+// RecordSink service of sinkpb/sink.proto, with an acknowledgement per batch.
+//
+// Passing Client.OnLines to freader.WithOnLinesErr ties the acknowledgements into offset
+// commits: OnLines returns once the server has acknowledged the batch, and the collector
+// stores the offsets of a batch only when it returns nil. A batch that still fails after
+// the retries is read again from the last stored offset.
+//
+//	client, err := grpcsink.New("pipeline:9000", grpcsink.Options{Retries: 5})
+//	...
+//	c, err := freader.New(freader.WithInclude("/var/log/app"), freader.WithStore("collector.db"),
+//		freader.WithOnLinesErr(client.OnLines))
+//	...
+//	c.Start()
+//	defer client.Close()
+//	defer c.Stop()
+package grpcsink
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/loykin/freader"
+	"github.com/loykin/freader/pkg/grpcsink/sinkpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SchemaVersion is sent as Batch.schema_version; see sink.proto for the policy.
+const SchemaVersion = 1
+
+// DefaultAckTimeout is the Options.AckTimeout used when it is 0.
+const DefaultAckTimeout = 30 * time.Second
+
+// DefaultRetryBackoff is the Options.RetryBackoff used when it is 0.
+const DefaultRetryBackoff = time.Second
+
+// maxRetryBackoff caps the wait between OnLines retries.
+const maxRetryBackoff = 30 * time.Second
+
+// ErrClosed is returned by Send and OnLines after Close.
+var ErrClosed = errors.New("grpc sink client closed")
+
+// Options configures New.
+type Options struct {
+	// Host and Labels are sent with every batch; Host defaults to os.Hostname().
+	Host   string
+	Labels map[string]string
+	// TLS, if set, secures the connection; plaintext is used otherwise.
+	TLS *tls.Config
+	// AckTimeout bounds the wait for a batch's acknowledgement; DefaultAckTimeout
+	// when 0. A batch not acknowledged in time fails and the stream is reopened.
+	AckTimeout time.Duration
+	// Retries is the number of extra attempts OnLines makes for a batch, waiting
+	// RetryBackoff (DefaultRetryBackoff when 0) before the first retry and twice as
+	// long before each further one. OnLines returns the error of a batch that still
+	// fails; a negative Retries retries until Close.
+	Retries      int
+	RetryBackoff time.Duration
+	// OnError, if set, receives the error of each failed OnLines attempt.
+	OnError func(err error)
+	// DialOptions are passed to grpc.NewClient after the transport credentials.
+	DialOptions []grpc.DialOption
+	// Logger receives retry messages; slog.Default() when nil.
+	Logger *slog.Logger
+}
+
+// Client sends batches to a RecordSink over one bidirectional stream, opened on the
+// first Send and again after the stream fails. Batches may be sent concurrently; each
+// waits for its own acknowledgement.
+type Client struct {
+	conn   *grpc.ClientConn
+	client sinkpb.RecordSinkClient
+	opts   Options
+	done   chan struct{}
+
+	mu        sync.Mutex
+	stream    *ackStream
+	seq       uint64
+	closed    bool
+	sendMu    sync.Mutex // serializes stream.Send; not held while acks are received
+	closeOnce sync.Once
+}
+
+// ackStream is an open Stream call with the batches waiting for their Ack.
+type ackStream struct {
+	stream  sinkpb.RecordSink_StreamClient
+	cancel  context.CancelFunc
+	pending map[uint64]chan error // guarded by Client.mu
+}
+
+// New returns a client for the RecordSink at target, in grpc.NewClient syntax such as
+// "host:port" or "dns:///host:port". It does not connect until the first Send.
+func New(target string, opts Options) (*Client, error) {
+	if target == "" {
+		return nil, errors.New("grpc sink requires a target")
+	}
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+	if opts.AckTimeout <= 0 {
+		opts.AckTimeout = DefaultAckTimeout
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	creds := insecure.NewCredentials()
+	if opts.TLS != nil {
+		creds = credentials.NewTLS(opts.TLS)
+	}
+	conn, err := grpc.NewClient(target, append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, opts.DialOptions...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grpc client: %w", err)
+	}
+	return &Client{conn: conn, client: sinkpb.NewRecordSinkClient(conn), opts: opts, done: make(chan struct{})}, nil
+}
+
+// Send sends records as one batch and waits until the server acknowledges it, the
+// server rejects it, the stream fails, Options.AckTimeout passes or ctx is done.
+func (c *Client) Send(ctx context.Context, records []*sinkpb.Record) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.AckTimeout)
+	defer cancel()
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	s, err := c.open()
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.seq++
+	seq := c.seq
+	acked := make(chan error, 1)
+	s.pending[seq] = acked
+	c.mu.Unlock()
+
+	c.sendMu.Lock()
+	err = s.stream.Send(&sinkpb.Batch{Seq: seq, Host: c.opts.Host, Labels: c.opts.Labels, Records: records, SchemaVersion: SchemaVersion})
+	c.sendMu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		c.reset(s, err)
+		c.mu.Unlock()
+		return fmt.Errorf("failed to send batch: %w", err)
+	}
+
+	select {
+	case err := <-acked:
+		return err
+	case <-ctx.Done():
+		// The server is stuck or gone: drop the stream so later batches do not queue
+		// behind this one
+		c.mu.Lock()
+		c.reset(s, ctx.Err())
+		c.mu.Unlock()
+		return fmt.Errorf("batch %d not acknowledged: %w", seq, ctx.Err())
+	}
+}
+
+// open returns the current stream, opening one if needed. c.mu must be held.
+func (c *Client) open() (*ackStream, error) {
+	if c.stream != nil {
+		return c.stream, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.client.Stream(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	s := &ackStream{stream: stream, cancel: cancel, pending: make(map[uint64]chan error)}
+	c.stream = s
+	go c.receive(s)
+	return s, nil
+}
+
+// receive hands each Ack of s to the batch waiting for it until the stream fails.
+func (c *Client) receive(s *ackStream) {
+	for {
+		ack, err := s.stream.Recv()
+		if err != nil {
+			c.mu.Lock()
+			c.reset(s, err)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Lock()
+		acked, ok := s.pending[ack.GetSeq()]
+		delete(s.pending, ack.GetSeq())
+		c.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if ack.GetError() != "" {
+			acked <- fmt.Errorf("batch %d rejected: %s", ack.GetSeq(), ack.GetError())
+		} else {
+			acked <- nil
+		}
+	}
+}
+
+// reset closes s, failing the batches waiting on it with err; the next Send opens a
+// new stream. c.mu must be held.
+func (c *Client) reset(s *ackStream, err error) {
+	if c.stream == s {
+		c.stream = nil
+	}
+	s.cancel()
+	for seq, acked := range s.pending {
+		acked <- fmt.Errorf("stream closed before batch %d was acknowledged: %w", seq, err)
+		delete(s.pending, seq)
+	}
+}
+
+// OnLines sends recs as one batch and returns once it is acknowledged, retrying as
+// configured by Options.Retries; pass it to freader.WithOnLinesErr. It returns the
+// error of the last attempt when the retries are exhausted, and ErrClosed after Close,
+// so the offsets of the batch are not stored.
+func (c *Client) OnLines(recs []freader.Record) error {
+	records := make([]*sinkpb.Record, len(recs))
+	for i, r := range recs {
+		line := r.Line
+		if r.Repeats > 0 {
+			line = freader.RepeatSummary(line, r.Repeats)
+		}
+		records[i] = &sinkpb.Record{Line: line, File: r.File, IngestTime: timestamppb.New(r.Ts)}
+	}
+	backoff := c.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := c.Send(context.Background(), records)
+		if err == nil || errors.Is(err, ErrClosed) {
+			return err
+		}
+		if c.opts.OnError != nil {
+			c.opts.OnError(err)
+		}
+		if c.opts.Retries >= 0 && attempt >= c.opts.Retries {
+			return fmt.Errorf("grpc sink batch of %d records failed after %d attempts: %w", len(records), attempt+1, err)
+		}
+		c.opts.Logger.Warn("grpc sink batch failed; retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-c.done:
+			timer.Stop()
+			return ErrClosed
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// Close fails the batches waiting for an acknowledgement, stops OnLines retries and
+// closes the connection.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		c.closed = true
+		if c.stream != nil {
+			c.reset(c.stream, ErrClosed)
+		}
+		c.mu.Unlock()
+		err = c.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,243 @@
+package grpcsink
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader"
+	"github.com/loykin/freader/pkg/grpcsink/sinkpb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// testServer records the batches it receives. ack decides the Ack error of each
+// batch; a nil ack accepts everything, and a closed hold delays every Ack until it is.
+type testServer struct {
+	sinkpb.UnimplementedRecordSinkServer
+	mu      sync.Mutex
+	batches []*sinkpb.Batch
+	ack     func(b *sinkpb.Batch) string
+	hold    chan struct{}
+	got     chan struct{}
+}
+
+func (s *testServer) Stream(stream sinkpb.RecordSink_StreamServer) error {
+	for {
+		b, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		s.mu.Lock()
+		s.batches = append(s.batches, b)
+		s.mu.Unlock()
+		select {
+		case s.got <- struct{}{}:
+		default:
+		}
+		s.mu.Lock()
+		hold := s.hold
+		s.mu.Unlock()
+		if hold != nil {
+			select {
+			case <-hold:
+			case <-stream.Context().Done():
+				return nil
+			}
+		}
+		var reason string
+		s.mu.Lock()
+		if s.ack != nil {
+			reason = s.ack(b)
+		}
+		s.mu.Unlock()
+		if err := stream.Send(&sinkpb.Ack{Seq: b.GetSeq(), Error: reason}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *testServer) lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, b := range s.batches {
+		for _, r := range b.GetRecords() {
+			out = append(out, r.GetLine())
+		}
+	}
+	return out
+}
+
+func startServer(t *testing.T, s *testServer) string {
+	s.got = make(chan struct{}, 1)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	sinkpb.RegisterRecordSinkServer(srv, s)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestClient_Send(t *testing.T) {
+	srv := &testServer{}
+	client, err := New(startServer(t, srv), Options{Host: "node-1", Labels: map[string]string{"env": "test"}})
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	require.NoError(t, client.Send(context.Background(), []*sinkpb.Record{{Line: "a"}, {Line: "b"}}))
+	require.NoError(t, client.Send(context.Background(), []*sinkpb.Record{{Line: "c"}}))
+	assert.Equal(t, []string{"a", "b", "c"}, srv.lines())
+	srv.mu.Lock()
+	assert.Equal(t, uint64(1), srv.batches[0].GetSeq())
+	assert.Equal(t, uint64(2), srv.batches[1].GetSeq())
+	assert.Equal(t, "node-1", srv.batches[0].GetHost())
+	assert.Equal(t, uint32(SchemaVersion), srv.batches[0].GetSchemaVersion())
+	assert.Equal(t, "test", srv.batches[0].GetLabels()["env"])
+	srv.mu.Unlock()
+
+	require.NoError(t, client.Close())
+	assert.ErrorIs(t, client.Send(context.Background(), nil), ErrClosed)
+}
+
+func TestClient_RejectedAndUnacknowledged(t *testing.T) {
+	srv := &testServer{ack: func(b *sinkpb.Batch) string {
+		if b.GetRecords()[0].GetLine() == "bad" {
+			return "disk full"
+		}
+		return ""
+	}}
+	client, err := New(startServer(t, srv), Options{AckTimeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	err = client.Send(context.Background(), []*sinkpb.Record{{Line: "bad"}})
+	assert.ErrorContains(t, err, "rejected: disk full")
+	require.NoError(t, client.Send(context.Background(), []*sinkpb.Record{{Line: "good"}}), "the stream stays usable")
+
+	// A batch that is never acknowledged times out and the stream is reopened
+	srv.mu.Lock()
+	srv.hold = make(chan struct{})
+	srv.mu.Unlock()
+	err = client.Send(context.Background(), []*sinkpb.Record{{Line: "stuck"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	close(srv.hold)
+	require.NoError(t, client.Send(context.Background(), []*sinkpb.Record{{Line: "again"}}))
+}
+
+func TestClient_OnLinesRetries(t *testing.T) {
+	var rejected bool
+	srv := &testServer{ack: func(b *sinkpb.Batch) string {
+		if !rejected {
+			rejected = true
+			return "try again"
+		}
+		return ""
+	}}
+	var errs []error
+	client, err := New(startServer(t, srv), Options{Retries: 2, RetryBackoff: time.Millisecond,
+		OnError: func(err error) { errs = append(errs, err) }})
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	require.NoError(t, client.OnLines([]freader.Record{{Line: "x", File: "/var/log/a.log", Ts: time.Unix(10, 0)}, {Line: "y", Repeats: 2}}))
+	y := freader.RepeatSummary("y", 2)
+	assert.Equal(t, []string{"x", y, "x", y}, srv.lines(), "sent again after the rejection")
+	require.Len(t, errs, 1)
+	srv.mu.Lock()
+	assert.Equal(t, "/var/log/a.log", srv.batches[1].GetRecords()[0].GetFile())
+	assert.Equal(t, int64(10), srv.batches[1].GetRecords()[0].GetIngestTime().GetSeconds())
+	srv.mu.Unlock()
+
+	// Retries exhausted: the error is returned so the offsets are not stored
+	srv.mu.Lock()
+	srv.ack = func(*sinkpb.Batch) string { return "no" }
+	srv.mu.Unlock()
+	errs = nil
+	assert.ErrorContains(t, client.OnLines([]freader.Record{{Line: "z"}}), "rejected: no")
+	assert.Len(t, errs, 3)
+
+	require.NoError(t, client.Close())
+	assert.ErrorIs(t, client.OnLines([]freader.Record{{Line: "z"}}), ErrClosed)
+}
+
+func TestClient_OffsetsCommittedAfterAck(t *testing.T) {
+	srv := &testServer{hold: make(chan struct{})}
+	client, err := New(startServer(t, srv), Options{})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(p, []byte("one\ntwo\n"), 0o644))
+	c, err := freader.New(freader.WithInclude(dir), freader.WithPollInterval(20*time.Millisecond),
+		freader.WithFingerprint(freader.FingerprintStrategyDeviceAndInode, 0),
+		freader.WithStore(filepath.Join(t.TempDir(), "collector.db")),
+		freader.WithOnLinesErr(client.OnLines))
+	require.NoError(t, err)
+	c.Start()
+	defer func() { _ = client.Close() }()
+	defer c.Stop()
+
+	select {
+	case <-srv.got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch received")
+	}
+	offset := func() int64 {
+		for _, f := range c.TrackedFiles() {
+			return f.Offset
+		}
+		return -1
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(0), offset(), "not committed before the ack")
+	close(srv.hold)
+	assert.Eventually(t, func() bool { return offset() == int64(len("one\ntwo\n")) }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"one", "two"}, srv.lines())
+}
+
+func TestClient_FailedBatchReadAgain(t *testing.T) {
+	var rejected bool
+	srv := &testServer{ack: func(*sinkpb.Batch) string {
+		if !rejected {
+			rejected = true
+			return "unavailable"
+		}
+		return ""
+	}}
+	client, err := New(startServer(t, srv), Options{})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(p, []byte("one\ntwo\n"), 0o644))
+	c, err := freader.New(freader.WithInclude(dir), freader.WithPollInterval(20*time.Millisecond),
+		freader.WithFingerprint(freader.FingerprintStrategyDeviceAndInode, 0),
+		freader.WithOnLinesErr(client.OnLines))
+	require.NoError(t, err)
+	c.Start()
+	defer func() { _ = client.Close() }()
+	defer c.Stop()
+
+	offset := func() int64 {
+		for _, f := range c.TrackedFiles() {
+			return f.Offset
+		}
+		return -1
+	}
+	assert.Eventually(t, func() bool { return offset() == int64(len("one\ntwo\n")) }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"one", "two", "one", "two"}, srv.lines(), "the rejected batch is read and sent again")
+}
+
+func TestNew_RequiresTarget(t *testing.T) {
+	_, err := New("", Options{})
+	assert.True(t, err != nil && !errors.Is(err, ErrClosed))
+}
@@ -0,0 +1,6 @@
+// Package sinkpb holds the RecordSink service freader streams records to, generated
+// from sink.proto. Implement sinkpb.RecordSinkServer and register it with
+// RegisterRecordSinkServer to receive records from the grpc sink.
+package sinkpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative sink.proto
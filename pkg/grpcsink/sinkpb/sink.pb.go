@@ -0,0 +1,341 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: sink.proto
+
+// freader streams collected records to a RecordSink service implemented by the
+// receiving pipeline.
+
+package sinkpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Record is one collected record.
+type Record struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The record as formatted by freader's parser settings.
+	Line string `protobuf:"bytes,1,opt,name=line,proto3" json:"line,omitempty"`
+	// Path of the file the record was read from.
+	File string `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+	// When the event happened, if a timestamp was parsed from the record.
+	EventTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=event_time,json=eventTime,proto3" json:"event_time,omitempty"`
+	// When freader read the record.
+	IngestTime *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=ingest_time,json=ingestTime,proto3" json:"ingest_time,omitempty"`
+	// Per-record metadata rendered from the sink.headers templates, so consumers can
+	// route or filter records without parsing line. Headers that render empty are
+	// left out.
+	Headers       map[string]string `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_sink_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_sink_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_sink_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Record) GetLine() string {
+	if x != nil {
+		return x.Line
+	}
+	return ""
+}
+
+func (x *Record) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Record) GetEventTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EventTime
+	}
+	return nil
+}
+
+func (x *Record) GetIngestTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IngestTime
+	}
+	return nil
+}
+
+func (x *Record) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+// Batch is a group of records acknowledged together.
+type Batch struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the batch in its Ack; increasing per client, but batches sent
+	// concurrently may arrive out of order.
+	Seq uint64 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Host name of the sending collector.
+	Host string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	// Static labels configured on the sending collector.
+	Labels  map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Records []*Record         `protobuf:"bytes,4,rep,name=records,proto3" json:"records,omitempty"`
+	// Version of the Batch and Record fields, grpcsink.SchemaVersion. Fields are only
+	// added, with the version unchanged; renaming or removing a field, or changing its
+	// type or meaning, bumps it.
+	SchemaVersion uint32 `protobuf:"varint,5,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Batch) Reset() {
+	*x = Batch{}
+	mi := &file_sink_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Batch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Batch) ProtoMessage() {}
+
+func (x *Batch) ProtoReflect() protoreflect.Message {
+	mi := &file_sink_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Batch.ProtoReflect.Descriptor instead.
+func (*Batch) Descriptor() ([]byte, []int) {
+	return file_sink_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Batch) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Batch) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Batch) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Batch) GetRecords() []*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *Batch) GetSchemaVersion() uint32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+// Ack acknowledges the batch with the same seq.
+type Ack struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Seq   uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Non-empty if the batch was rejected; freader retries it.
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	mi := &file_sink_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_sink_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_sink_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Ack) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Ack) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_sink_proto protoreflect.FileDescriptor
+
+const file_sink_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"sink.proto\x12\x0ffreader.sink.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa4\x02\n" +
+	"\x06Record\x12\x12\n" +
+	"\x04line\x18\x01 \x01(\tR\x04line\x12\x12\n" +
+	"\x04file\x18\x02 \x01(\tR\x04file\x129\n" +
+	"\n" +
+	"event_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\teventTime\x12;\n" +
+	"\vingest_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"ingestTime\x12>\n" +
+	"\aheaders\x18\x05 \x03(\v2$.freader.sink.v1.Record.HeadersEntryR\aheaders\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xfe\x01\n" +
+	"\x05Batch\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12:\n" +
+	"\x06labels\x18\x03 \x03(\v2\".freader.sink.v1.Batch.LabelsEntryR\x06labels\x121\n" +
+	"\arecords\x18\x04 \x03(\v2\x17.freader.sink.v1.RecordR\arecords\x12%\n" +
+	"\x0eschema_version\x18\x05 \x01(\rR\rschemaVersion\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"-\n" +
+	"\x03Ack\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error2H\n" +
+	"\n" +
+	"RecordSink\x12:\n" +
+	"\x06Stream\x12\x16.freader.sink.v1.Batch\x1a\x14.freader.sink.v1.Ack(\x010\x01B/Z-github.com/loykin/freader/pkg/grpcsink/sinkpbb\x06proto3"
+
+var (
+	file_sink_proto_rawDescOnce sync.Once
+	file_sink_proto_rawDescData []byte
+)
+
+func file_sink_proto_rawDescGZIP() []byte {
+	file_sink_proto_rawDescOnce.Do(func() {
+		file_sink_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sink_proto_rawDesc), len(file_sink_proto_rawDesc)))
+	})
+	return file_sink_proto_rawDescData
+}
+
+var file_sink_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_sink_proto_goTypes = []any{
+	(*Record)(nil),                // 0: freader.sink.v1.Record
+	(*Batch)(nil),                 // 1: freader.sink.v1.Batch
+	(*Ack)(nil),                   // 2: freader.sink.v1.Ack
+	nil,                           // 3: freader.sink.v1.Record.HeadersEntry
+	nil,                           // 4: freader.sink.v1.Batch.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_sink_proto_depIdxs = []int32{
+	5, // 0: freader.sink.v1.Record.event_time:type_name -> google.protobuf.Timestamp
+	5, // 1: freader.sink.v1.Record.ingest_time:type_name -> google.protobuf.Timestamp
+	3, // 2: freader.sink.v1.Record.headers:type_name -> freader.sink.v1.Record.HeadersEntry
+	4, // 3: freader.sink.v1.Batch.labels:type_name -> freader.sink.v1.Batch.LabelsEntry
+	0, // 4: freader.sink.v1.Batch.records:type_name -> freader.sink.v1.Record
+	1, // 5: freader.sink.v1.RecordSink.Stream:input_type -> freader.sink.v1.Batch
+	2, // 6: freader.sink.v1.RecordSink.Stream:output_type -> freader.sink.v1.Ack
+	6, // [6:7] is the sub-list for method output_type
+	5, // [5:6] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_sink_proto_init() }
+func file_sink_proto_init() {
+	if File_sink_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sink_proto_rawDesc), len(file_sink_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sink_proto_goTypes,
+		DependencyIndexes: file_sink_proto_depIdxs,
+		MessageInfos:      file_sink_proto_msgTypes,
+	}.Build()
+	File_sink_proto = out.File
+	file_sink_proto_goTypes = nil
+	file_sink_proto_depIdxs = nil
+}
@@ -0,0 +1,134 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sink.proto
+
+// freader streams collected records to a RecordSink service implemented by the
+// receiving pipeline.
+
+package sinkpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RecordSink_Stream_FullMethodName = "/freader.sink.v1.RecordSink/Stream"
+)
+
+// RecordSinkClient is the client API for RecordSink service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RecordSink receives batches of records from freader.
+type RecordSinkClient interface {
+	// Stream carries batches from freader to the server, which answers each batch with
+	// an Ack carrying its seq once it has accepted the records, e.g. written them to
+	// durable storage. freader commits the read offsets of a batch only after its Ack,
+	// so records of batches that were not acknowledged are sent again after a restart.
+	// Acks may be sent in any order. An Ack with an error rejects the batch; freader
+	// retries it on the same stream.
+	Stream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Batch, Ack], error)
+}
+
+type recordSinkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRecordSinkClient(cc grpc.ClientConnInterface) RecordSinkClient {
+	return &recordSinkClient{cc}
+}
+
+func (c *recordSinkClient) Stream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Batch, Ack], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RecordSink_ServiceDesc.Streams[0], RecordSink_Stream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Batch, Ack]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RecordSink_StreamClient = grpc.BidiStreamingClient[Batch, Ack]
+
+// RecordSinkServer is the server API for RecordSink service.
+// All implementations must embed UnimplementedRecordSinkServer
+// for forward compatibility.
+//
+// RecordSink receives batches of records from freader.
+type RecordSinkServer interface {
+	// Stream carries batches from freader to the server, which answers each batch with
+	// an Ack carrying its seq once it has accepted the records, e.g. written them to
+	// durable storage. freader commits the read offsets of a batch only after its Ack,
+	// so records of batches that were not acknowledged are sent again after a restart.
+	// Acks may be sent in any order. An Ack with an error rejects the batch; freader
+	// retries it on the same stream.
+	Stream(grpc.BidiStreamingServer[Batch, Ack]) error
+	mustEmbedUnimplementedRecordSinkServer()
+}
+
+// UnimplementedRecordSinkServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRecordSinkServer struct{}
+
+func (UnimplementedRecordSinkServer) Stream(grpc.BidiStreamingServer[Batch, Ack]) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
+func (UnimplementedRecordSinkServer) mustEmbedUnimplementedRecordSinkServer() {}
+func (UnimplementedRecordSinkServer) testEmbeddedByValue()                    {}
+
+// UnsafeRecordSinkServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RecordSinkServer will
+// result in compilation errors.
+type UnsafeRecordSinkServer interface {
+	mustEmbedUnimplementedRecordSinkServer()
+}
+
+func RegisterRecordSinkServer(s grpc.ServiceRegistrar, srv RecordSinkServer) {
+	// If the following call pancis, it indicates UnimplementedRecordSinkServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RecordSink_ServiceDesc, srv)
+}
+
+func _RecordSink_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RecordSinkServer).Stream(&grpc.GenericServerStream[Batch, Ack]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RecordSink_StreamServer = grpc.BidiStreamingServer[Batch, Ack]
+
+// RecordSink_ServiceDesc is the grpc.ServiceDesc for RecordSink service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RecordSink_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "freader.sink.v1.RecordSink",
+	HandlerType: (*RecordSinkServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       _RecordSink_Stream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "sink.proto",
+}
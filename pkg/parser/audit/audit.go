@@ -3,6 +3,7 @@
 package audit
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -19,14 +20,30 @@ import (
 //
 //	type=SYSCALL msg=audit(1700000000.123:456): arch=c000003e syscall=59 success=yes ...
 //
+// Lines forwarded by audisp-remote start with node=<host>, and auditd's ENRICHED log
+// format appends the interpreted fields (UID="root" ARCH=x86_64 ...) after a 0x1D
+// separator; these end up in Node and Enriched.
+//
 // We aim to be tolerant of small variations and will parse what we can.
 type Record struct {
 	Raw       string            `json:"raw"`
+	Node      string            `json:"node,omitempty"`
 	Type      string            `json:"type"`
 	EpochSec  int64             `json:"epoch_sec,omitempty"`
 	EpochNSec int64             `json:"epoch_nsec,omitempty"`
 	Serial    int64             `json:"serial,omitempty"`
 	Fields    map[string]string `json:"fields,omitempty"`
+	Enriched  map[string]string `json:"enriched,omitempty"`
+}
+
+// enrichedSeparator precedes the interpreted fields of the ENRICHED log format.
+const enrichedSeparator = "\x1d"
+
+// hexFields are logged by the kernel as untrusted strings: quoted when printable,
+// otherwise hex encoded without quotes.
+var hexFields = map[string]struct{}{
+	"acct": {}, "cmd": {}, "comm": {}, "cwd": {}, "data": {}, "dir": {}, "exe": {},
+	"file": {}, "key": {}, "name": {}, "ocomm": {}, "path": {}, "proctitle": {},
 }
 
 var (
@@ -46,9 +63,30 @@ func Parse(line string) (Record, bool, error) {
 	if line == "" {
 		return Record{}, false, nil
 	}
+	body, node := line, ""
+	if rest, ok := strings.CutPrefix(line, "node="); ok {
+		node, body, _ = strings.Cut(rest, " ")
+		body = strings.TrimSpace(body)
+	}
+	body, enriched, hasEnriched := strings.Cut(body, enrichedSeparator)
 
+	rec, ok := parseBody(body)
+	if !ok {
+		return Record{}, false, nil
+	}
+	rec.Raw = line
+	rec.Node = node
+	if hasEnriched {
+		rec.Enriched = map[string]string{}
+		parseKeyValuesInto(rec.Enriched, enriched, nil)
+	}
+	return rec, true, nil
+}
+
+// parseBody parses a line without the node prefix and the enriched fields.
+func parseBody(line string) (Record, bool) {
 	if m := headRe.FindStringSubmatch(line); m != nil {
-		rec := Record{Raw: line, Type: m[1], Fields: map[string]string{}}
+		rec := Record{Type: m[1], Fields: map[string]string{}}
 		sec, _ := strconv.ParseInt(m[2], 10, 64)
 		nsecStr := m[3]
 		// Convert fractional seconds to nanoseconds; audit uses 3 digits (ms) commonly,
@@ -64,22 +102,42 @@ func Parse(line string) (Record, bool, error) {
 		rec.EpochSec = sec
 		rec.EpochNSec = nsec
 		rec.Serial = serial
-		parseKeyValuesInto(rec.Fields, rest)
-		return rec, true, nil
+		parseKeyValuesInto(rec.Fields, rest, hexEncoded(rec.Type))
+		return rec, true
 	}
 
 	if m := altHeadRe.FindStringSubmatch(line); m != nil {
-		rec := Record{Raw: line, Type: m[1], Fields: map[string]string{}}
-		parseKeyValuesInto(rec.Fields, m[2])
-		return rec, true, nil
+		rec := Record{Type: m[1], Fields: map[string]string{}}
+		parseKeyValuesInto(rec.Fields, m[2], hexEncoded(rec.Type))
+		return rec, true
 	}
 
-	return Record{}, false, nil
+	return Record{}, false
+}
+
+// hexEncoded reports whether unquoted values of a key are hex encoded in records of
+// type typ. EXECVE arguments (a0, a1, ...) are untrusted strings too, while the a0-a3
+// of SYSCALL records are plain hex numbers.
+func hexEncoded(typ string) func(string) bool {
+	return func(k string) bool {
+		if _, ok := hexFields[k]; ok {
+			return true
+		}
+		if typ != "EXECVE" || len(k) < 2 || k[0] != 'a' {
+			return false
+		}
+		_, err := strconv.Atoi(k[1:])
+		return err == nil
+	}
 }
 
 // parseKeyValuesInto parses key=value tokens, where value can be quoted and may contain spaces.
 // Example: key1=val1 key2="hello world" key3='x y' key4=\"quoted\"
-func parseKeyValuesInto(dst map[string]string, s string) {
+//
+// Unquoted values of keys isHex accepts are decoded when they are valid hex. The
+// single-quoted msg='op=... acct="root" res=success' of user space records is parsed
+// as well, its fields added unless the outer record already has them.
+func parseKeyValuesInto(dst map[string]string, s string, isHex func(string) bool) {
 	// Local tolerant tokenizer (we reference auparse types elsewhere to keep the dependency active).
 	tokens := tokenizeKV(s)
 	for _, t := range tokens {
@@ -87,18 +145,48 @@ func parseKeyValuesInto(dst map[string]string, s string) {
 			k := t[:eq]
 			v := t[eq+1:]
 			v = strings.TrimSpace(v)
-			if len(v) >= 2 {
-				if (v[0] == '"' && v[len(v)-1] == '"') || (v[0] == '\'' && v[len(v)-1] == '\'') {
-					v = v[1 : len(v)-1]
-				}
+			quoted := len(v) >= 2 && ((v[0] == '"' && v[len(v)-1] == '"') || (v[0] == '\'' && v[len(v)-1] == '\''))
+			if quoted {
+				v = v[1 : len(v)-1]
 			}
 			// Unescape common sequences
 			v = strings.ReplaceAll(v, `\"`, `"`)
+			if k == "msg" && quoted && strings.Contains(v, "=") {
+				inner := map[string]string{}
+				parseKeyValuesInto(inner, v, isHex)
+				for ik, iv := range inner {
+					if _, ok := dst[ik]; !ok {
+						dst[ik] = iv
+					}
+				}
+				continue
+			}
+			if !quoted && isHex != nil && isHex(k) {
+				if d, ok := decodeHex(v); ok {
+					if k == "proctitle" {
+						// Arguments are NUL separated
+						d = strings.TrimRight(strings.ReplaceAll(d, "\x00", " "), " ")
+					}
+					v = d
+				}
+			}
 			dst[k] = v
 		}
 	}
 }
 
+// decodeHex decodes the uppercase hex the kernel uses for untrusted strings.
+func decodeHex(s string) (string, bool) {
+	if s == "" || len(s)%2 != 0 || strings.ToUpper(s) != s {
+		return "", false
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
 // tokenizeKV splits a string by spaces, keeping quoted substrings intact.
 func tokenizeKV(s string) []string {
 	var out []string
@@ -6,11 +6,13 @@ package audit
 // On non-Linux platforms, this is a minimal stub to keep API compatibility.
 type Record struct {
 	Raw       string            `json:"raw"`
+	Node      string            `json:"node,omitempty"`
 	Type      string            `json:"type"`
 	EpochSec  int64             `json:"epoch_sec,omitempty"`
 	EpochNSec int64             `json:"epoch_nsec,omitempty"`
 	Serial    int64             `json:"serial,omitempty"`
 	Fields    map[string]string `json:"fields,omitempty"`
+	Enriched  map[string]string `json:"enriched,omitempty"`
 }
 
 // Parse on non-Linux platforms always reports that the line is not an audit log.
@@ -0,0 +1,97 @@
+//go:build linux
+
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Header(t *testing.T) {
+	rec, ok, err := Parse(`type=SYSCALL msg=audit(1700000000.123:456): arch=c000003e syscall=59 success=yes a0=55ac4 comm="sh" exe="/usr/bin/sh" key=(null)`)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "SYSCALL", rec.Type)
+	assert.Equal(t, int64(1700000000), rec.EpochSec)
+	assert.Equal(t, int64(123000000), rec.EpochNSec)
+	assert.Equal(t, int64(456), rec.Serial)
+	assert.Equal(t, "55ac4", rec.Fields["a0"])
+	assert.Equal(t, "sh", rec.Fields["comm"])
+	assert.Equal(t, "(null)", rec.Fields["key"])
+	assert.Empty(t, rec.Node)
+	assert.Nil(t, rec.Enriched)
+
+	_, ok, _ = Parse("not an audit line")
+	assert.False(t, ok)
+}
+
+func TestParse_Node(t *testing.T) {
+	line := `node=web-1 type=PATH msg=audit(1700000000.001:123): item=0 name="/usr/bin/sh" nametype=NORMAL`
+	rec, ok, err := Parse(line)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "web-1", rec.Node)
+	assert.Equal(t, "PATH", rec.Type)
+	assert.Equal(t, int64(123), rec.Serial)
+	assert.Equal(t, "/usr/bin/sh", rec.Fields["name"])
+	assert.Equal(t, line, rec.Raw)
+	assert.NotContains(t, rec.Fields, "node")
+}
+
+func TestParse_Enriched(t *testing.T) {
+	line := "node=db type=SYSCALL msg=audit(1700000000.5:7): arch=c000003e syscall=59 uid=0 comm=\"id\" key=(null)" +
+		"\x1dARCH=x86_64 SYSCALL=execve UID=\"root\" AUID=\"jane doe\""
+	rec, ok, err := Parse(line)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "db", rec.Node)
+	assert.Equal(t, int64(500000000), rec.EpochNSec)
+	assert.Equal(t, "(null)", rec.Fields["key"])
+	assert.NotContains(t, rec.Fields, "ARCH")
+	assert.Equal(t, map[string]string{"ARCH": "x86_64", "SYSCALL": "execve", "UID": "root", "AUID": "jane doe"}, rec.Enriched)
+}
+
+func TestParse_HexValues(t *testing.T) {
+	rec, ok, _ := Parse(`type=PROCTITLE msg=audit(1700000000.001:123): proctitle=2F62696E2F7368002D63006C73`)
+	require.True(t, ok)
+	assert.Equal(t, "/bin/sh -c ls", rec.Fields["proctitle"])
+
+	// Strings with spaces are hex encoded, printable ones quoted
+	rec, ok, _ = Parse(`type=PATH msg=audit(1700000000.001:124): item=0 name=2F746D702F6D792066696C65 inode=123 dev=08:01`)
+	require.True(t, ok)
+	assert.Equal(t, "/tmp/my file", rec.Fields["name"])
+	assert.Equal(t, "08:01", rec.Fields["dev"])
+
+	rec, ok, _ = Parse(`type=EXECVE msg=audit(1700000000.001:125): argc=3 a0="echo" a1=612062 a2="ABCD"`)
+	require.True(t, ok)
+	assert.Equal(t, "echo", rec.Fields["a0"])
+	assert.Equal(t, "a b", rec.Fields["a1"])
+	assert.Equal(t, "ABCD", rec.Fields["a2"], "quoted values are not decoded")
+	assert.Equal(t, "3", rec.Fields["argc"])
+
+	rec, ok, _ = Parse(`type=SYSCALL msg=audit(1700000000.001:126): a1=ABCD a2=7ffc`)
+	require.True(t, ok)
+	assert.Equal(t, "ABCD", rec.Fields["a1"], "syscall arguments are numbers")
+}
+
+func TestParse_UserMessage(t *testing.T) {
+	line := `type=USER_LOGIN msg=audit(1700000000.001:200): pid=812 uid=0 auid=1000 ses=3 msg='op=login acct="jane" exe="/usr/sbin/sshd" hostname=? addr=10.0.0.5 terminal=ssh res=success'`
+	rec, ok, _ := Parse(line)
+	require.True(t, ok)
+	assert.Equal(t, "USER_LOGIN", rec.Type)
+	assert.Equal(t, "812", rec.Fields["pid"])
+	assert.Equal(t, "login", rec.Fields["op"])
+	assert.Equal(t, "jane", rec.Fields["acct"])
+	assert.Equal(t, "/usr/sbin/sshd", rec.Fields["exe"])
+	assert.Equal(t, "10.0.0.5", rec.Fields["addr"])
+	assert.Equal(t, "success", rec.Fields["res"])
+	assert.NotContains(t, rec.Fields, "msg")
+
+	// Hex encoded account names are decoded inside msg too
+	rec, ok, _ = Parse(`type=USER_AUTH msg=audit(1700000000.001:201): pid=1 uid=0 msg='op=PAM:authentication acct=6A616E6520646F65 res=failed'`)
+	require.True(t, ok)
+	assert.Equal(t, "jane doe", rec.Fields["acct"])
+	assert.Equal(t, "PAM:authentication", rec.Fields["op"])
+}
@@ -0,0 +1,162 @@
+// Package container parses the log files container runtimes write on a node: the CRI
+// format kubelet exposes under /var/log/containers and Docker's json-file driver. It
+// also derives container metadata (pod, namespace, container name and ID) from their
+// paths and joins records the runtime split into partial lines.
+package container
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one container log entry.
+//
+// Example lines:
+//
+//	2024-05-01T12:00:00.123456789Z stdout F hello world            (CRI)
+//	{"log":"hello world\n","stream":"stdout","time":"2024-05-01T12:00:00.123456789Z"}  (Docker)
+type Record struct {
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"` // "stdout" or "stderr"
+	Log     string    `json:"log"`    // message without the trailing newline
+	Partial bool      `json:"-"`      // the runtime split a long line; more of it follows
+	Metadata
+}
+
+// Metadata identifies the container a log file belongs to. Name, Image and Labels are
+// not part of the log path and are filled in by Docker API discovery.
+type Metadata struct {
+	ContainerID string            `json:"container_id,omitempty"`
+	Name        string            `json:"container_name,omitempty"`
+	Image       string            `json:"image,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Kubernetes  *Kubernetes       `json:"kubernetes,omitempty"`
+}
+
+// Kubernetes holds the pod fields encoded in a /var/log/containers file name.
+type Kubernetes struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+}
+
+// JSON returns the record as compact JSON.
+func (r Record) JSON() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// ParseCRI parses a line in the CRI logging format: "<RFC3339Nano time> <stream> <P|F> <log>".
+// It returns false when the line does not look like a CRI log entry.
+func ParseCRI(line string) (Record, bool) {
+	ts, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return Record{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return Record{}, false
+	}
+	stream, rest, ok := strings.Cut(rest, " ")
+	if !ok || (stream != "stdout" && stream != "stderr") {
+		return Record{}, false
+	}
+	// The tag is a ':'-separated list whose first field is P (partial) or F (full)
+	tag, msg, _ := strings.Cut(rest, " ")
+	flag, _, _ := strings.Cut(tag, ":")
+	if flag != "P" && flag != "F" {
+		return Record{}, false
+	}
+	return Record{Time: t, Stream: stream, Log: msg, Partial: flag == "P"}, true
+}
+
+// ParseDockerJSON parses a line written by Docker's json-file logging driver. Lines
+// whose log field lacks the trailing newline are partial (Docker splits lines at 16KB).
+// It returns false when the line is not a json-file entry.
+func ParseDockerJSON(line string) (Record, bool) {
+	var raw struct {
+		Log    *string `json:"log"`
+		Stream string  `json:"stream"`
+		Time   string  `json:"time"`
+	}
+	if err := json.Unmarshal([]byte(line), &raw); err != nil || raw.Log == nil {
+		return Record{}, false
+	}
+	rec := Record{Stream: raw.Stream, Log: *raw.Log}
+	if t, err := time.Parse(time.RFC3339Nano, raw.Time); err == nil {
+		rec.Time = t
+	}
+	if msg, ok := strings.CutSuffix(rec.Log, "\n"); ok {
+		rec.Log = msg
+	} else {
+		rec.Partial = true
+	}
+	return rec, true
+}
+
+var (
+	// <pod>_<namespace>_<container>-<64 hex container id>.log
+	kubernetesFileRe = regexp.MustCompile(`^([^_]+)_([^_]+)_(.+)-([0-9a-f]{64})\.log$`)
+	containerIDRe    = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// MetadataFromPath derives container metadata from a log file path: kubelet's
+// /var/log/containers/<pod>_<namespace>_<container>-<id>.log names or Docker's
+// /var/lib/docker/containers/<id>/<id>-json.log layout. The zero Metadata is returned
+// for other paths.
+func MetadataFromPath(path string) Metadata {
+	base := filepath.Base(path)
+	if m := kubernetesFileRe.FindStringSubmatch(base); m != nil {
+		return Metadata{
+			ContainerID: m[4],
+			Kubernetes:  &Kubernetes{Pod: m[1], Namespace: m[2], Container: m[3]},
+		}
+	}
+	if id := filepath.Base(filepath.Dir(path)); containerIDRe.MatchString(id) && strings.HasPrefix(base, id) {
+		return Metadata{ContainerID: id}
+	}
+	return Metadata{}
+}
+
+// Joiner reassembles lines the runtime split into partial records. Partial pieces are
+// buffered per key (normally the file path) until the final piece arrives. It is safe
+// for concurrent use.
+type Joiner struct {
+	mu      sync.Mutex
+	pending map[string]*Record
+}
+
+// Add returns the complete record once r ends a line, merging any buffered pieces for
+// key into it; it returns false while the line is still incomplete.
+func (j *Joiner) Add(key string, r Record) (Record, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if p := j.pending[key]; p != nil {
+		p.Log += r.Log
+		if r.Partial {
+			return Record{}, false
+		}
+		delete(j.pending, key)
+		r.Log, r.Time = p.Log, p.Time
+		return r, true
+	}
+	if r.Partial {
+		if j.pending == nil {
+			j.pending = make(map[string]*Record)
+		}
+		j.pending[key] = &r
+		return Record{}, false
+	}
+	return r, true
+}
+
+// Forget drops buffered pieces for key, e.g. when its file is removed.
+func (j *Joiner) Forget(key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.pending, key)
+}
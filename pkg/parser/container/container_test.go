@@ -0,0 +1,103 @@
+package container
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testID = "8c2d6f1e4b7a9c0d3e5f7a1b2c4d6e8f0a2b4c6d8e0f1a3b5c7d9e1f3a5b7c9d"
+
+func TestParseCRI(t *testing.T) {
+	rec, ok := ParseCRI("2024-05-01T12:00:00.123456789Z stderr F something failed: code=3")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC), rec.Time)
+	assert.Equal(t, "stderr", rec.Stream)
+	assert.Equal(t, "something failed: code=3", rec.Log)
+	assert.False(t, rec.Partial)
+
+	rec, ok = ParseCRI("2024-05-01T12:00:00Z stdout P first half")
+	require.True(t, ok)
+	assert.True(t, rec.Partial)
+
+	rec, ok = ParseCRI("2024-05-01T12:00:00Z stdout F")
+	require.True(t, ok)
+	assert.Equal(t, "", rec.Log)
+
+	for _, line := range []string{
+		"",
+		"plain text line",
+		"2024-05-01T12:00:00Z stdin F x",
+		"2024-05-01T12:00:00Z stdout X x",
+		"yesterday stdout F x",
+	} {
+		_, ok := ParseCRI(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestParseDockerJSON(t *testing.T) {
+	rec, ok := ParseDockerJSON(`{"log":"hello \"world\"\n","stream":"stdout","time":"2024-05-01T12:00:00.5Z"}`)
+	require.True(t, ok)
+	assert.Equal(t, `hello "world"`, rec.Log)
+	assert.Equal(t, "stdout", rec.Stream)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 5e8, time.UTC), rec.Time)
+	assert.False(t, rec.Partial)
+
+	rec, ok = ParseDockerJSON(`{"log":"no newline","stream":"stderr","time":"2024-05-01T12:00:00Z"}`)
+	require.True(t, ok)
+	assert.True(t, rec.Partial)
+
+	_, ok = ParseDockerJSON(`{"msg":"other json"}`)
+	assert.False(t, ok)
+	_, ok = ParseDockerJSON("not json")
+	assert.False(t, ok)
+}
+
+func TestMetadataFromPath(t *testing.T) {
+	md := MetadataFromPath("/var/log/containers/web-7d4b9c-x2k8p_shop_nginx-" + testID + ".log")
+	assert.Equal(t, testID, md.ContainerID)
+	require.NotNil(t, md.Kubernetes)
+	assert.Equal(t, Kubernetes{Namespace: "shop", Pod: "web-7d4b9c-x2k8p", Container: "nginx"}, *md.Kubernetes)
+
+	md = MetadataFromPath("/var/lib/docker/containers/" + testID + "/" + testID + "-json.log")
+	assert.Equal(t, Metadata{ContainerID: testID}, md)
+
+	assert.Equal(t, Metadata{}, MetadataFromPath("/var/log/syslog"))
+}
+
+func TestJoiner(t *testing.T) {
+	var j Joiner
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	_, ok := j.Add("a", Record{Time: first, Stream: "stdout", Log: "part one, ", Partial: true})
+	assert.False(t, ok)
+	_, ok = j.Add("a", Record{Log: "part two, ", Partial: true})
+	assert.False(t, ok)
+	// Other files are not affected
+	rec, ok := j.Add("b", Record{Log: "whole"})
+	require.True(t, ok)
+	assert.Equal(t, "whole", rec.Log)
+
+	rec, ok = j.Add("a", Record{Time: first.Add(time.Second), Stream: "stdout", Log: "end"})
+	require.True(t, ok)
+	assert.Equal(t, "part one, part two, end", rec.Log)
+	assert.Equal(t, first, rec.Time, "time of the first piece")
+
+	_, _ = j.Add("a", Record{Log: "dangling", Partial: true})
+	j.Forget("a")
+	rec, ok = j.Add("a", Record{Log: "fresh"})
+	require.True(t, ok)
+	assert.Equal(t, "fresh", rec.Log)
+}
+
+func TestRecordJSON(t *testing.T) {
+	rec, _ := ParseCRI("2024-05-01T12:00:00Z stdout F hi")
+	rec.Metadata = MetadataFromPath("/var/log/containers/p_ns_c-" + testID + ".log")
+	out := rec.JSON()
+	assert.True(t, strings.HasPrefix(out, `{"time":"2024-05-01T12:00:00Z","stream":"stdout","log":"hi","container_id":"`+testID+`"`), out)
+	assert.Contains(t, out, `"kubernetes":{"namespace":"ns","pod":"p","container":"c"}`)
+}
@@ -180,25 +180,33 @@ func (p *Parser) detectType(value string) interface{} {
 	}
 
 	// Timestamp auto-detection (common formats)
-	commonTimeFormats := []string{
-		time.RFC3339,
-		time.RFC3339Nano,
-		"2006-01-02 15:04:05",
-		"2006-01-02T15:04:05",
-		"2006/01/02 15:04:05",
-		"01/02/2006 15:04:05",
-		"2006-01-02",
-		"01/02/2006",
+	if parsedTime, ok := parseCommonTime(value); ok {
+		return parsedTime
 	}
 
+	// Default to string
+	return value
+}
+
+// commonTimeFormats are the layouts recognized for timestamps without a configured format
+var commonTimeFormats = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+	"01/02/2006 15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+}
+
+func parseCommonTime(value string) (time.Time, bool) {
 	for _, format := range commonTimeFormats {
 		if parsedTime, err := time.Parse(format, value); err == nil {
-			return parsedTime
+			return parsedTime, true
 		}
 	}
-
-	// Default to string
-	return value
+	return time.Time{}, false
 }
 
 // GetHeaders returns the current headers
@@ -0,0 +1,263 @@
+package csv
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Decode maps the fields of a record into a new struct of type T.
+//
+// Struct fields are matched by their `csv:"name"` tag, or by field name when untagged;
+// names are compared case-insensitively when there is no exact match, and `csv:"-"`
+// skips a field. Fields of embedded structs are decoded as if they belonged to T.
+// Fields missing from the record keep their zero value, and an empty value leaves a
+// pointer field nil.
+//
+// Values are converted to strings, booleans, integers, floats, time.Time (RFC 3339 and
+// the other layouts recognized by AutoDetectTypes), time.Duration ("1.5s") and types
+// implementing encoding.TextUnmarshaler. Both detected types and plain strings are
+// accepted, so Decode works with and without AutoDetectTypes; note that detection turns
+// "1" and "0" into booleans, which decode as 1 and 0 into numeric fields.
+//
+//	type Access struct {
+//		Time    time.Time `csv:"timestamp"`
+//		Status  int       `csv:"status"`
+//		Latency float64   `csv:"latency_ms"`
+//		User    *string   `csv:"user"`
+//	}
+//	access, err := csv.Decode[Access](record)
+func Decode[T any](record *Record) (T, error) {
+	var out T
+	if record == nil {
+		return out, errors.New("csv: cannot decode a nil record")
+	}
+	v := reflect.ValueOf(&out).Elem()
+	if v.Kind() != reflect.Struct {
+		return out, fmt.Errorf("csv: cannot decode into %s, need a struct", v.Type())
+	}
+	if err := decodeStruct(v, record.Fields); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+func decodeStruct(v reflect.Value, fields map[string]interface{}) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+		// Exported fields of unexported embedded structs are still settable
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			if err := decodeStruct(v.Field(i), fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = sf.Name
+		}
+		value, ok := lookupField(fields, name)
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// lookupField finds name in fields, falling back to a case-insensitive match.
+func lookupField(fields map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+	for k, value := range fields {
+		if strings.EqualFold(k, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func setValue(dst reflect.Value, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	src := reflect.ValueOf(value)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	if dst.Kind() == reflect.Pointer {
+		if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+			return nil
+		}
+		p := reflect.New(dst.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	}
+
+	s, isString := value.(string)
+	switch dst.Type() {
+	case timeType:
+		if !isString {
+			return fmt.Errorf("cannot convert %T to time", value)
+		}
+		parsed, ok := parseCommonTime(strings.TrimSpace(s))
+		if !ok {
+			return fmt.Errorf("cannot parse %q as time", s)
+		}
+		dst.Set(reflect.ValueOf(parsed))
+		return nil
+	case durationType:
+		if isString {
+			d, err := time.ParseDuration(strings.TrimSpace(s))
+			if err != nil {
+				return err
+			}
+			dst.SetInt(int64(d))
+			return nil
+		}
+	}
+	if u, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(formatValue(value)))
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(formatValue(value))
+	case reflect.Bool:
+		b, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("%d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowFloat(f) {
+			return fmt.Errorf("%g overflows %s", f, dst.Type())
+		}
+		dst.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", dst.Type())
+	}
+	return nil
+}
+
+// formatValue renders a field value as text, times in RFC 3339.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case int:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "1", "on":
+			return true, nil
+		case "false", "no", "0", "off":
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot parse %q as bool", v)
+	default:
+		return false, fmt.Errorf("cannot convert %T to bool", value)
+	}
+}
+
+func toInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, fmt.Errorf("%g is not an integer", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to integer", value)
+	}
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to float", value)
+	}
+}
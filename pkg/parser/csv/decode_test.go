@@ -0,0 +1,121 @@
+package csv
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type accessLog struct {
+	Time     time.Time     `csv:"timestamp"`
+	Status   int           `csv:"status"`
+	Bytes    uint32        `csv:"bytes"`
+	Latency  float64       `csv:"latency_ms"`
+	Cached   bool          `csv:"cached"`
+	Timeout  time.Duration `csv:"timeout"`
+	Client   netip.Addr    `csv:"client"`
+	User     *string       `csv:"user"`
+	Path     string
+	Ignored  string `csv:"-"`
+	internal string
+}
+
+func decodeLine(t *testing.T, autoDetect bool, line string) *Record {
+	t.Helper()
+	parser := NewParser(Config{
+		Headers:         []string{"timestamp", "status", "bytes", "latency_ms", "cached", "timeout", "client", "user", "path", "Ignored"},
+		AutoDetectTypes: autoDetect,
+	})
+	record, err := parser.Parse(line)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	return record
+}
+
+func TestDecode(t *testing.T) {
+	line := "2024-05-01 12:00:00,404,5120,12.5,yes,1.5s,10.0.0.7,jane,/index.html,skip"
+	for _, autoDetect := range []bool{false, true} {
+		record := decodeLine(t, autoDetect, line)
+		got, err := Decode[accessLog](record)
+		require.NoError(t, err, "autoDetect=%v", autoDetect)
+
+		assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), got.Time)
+		assert.Equal(t, 404, got.Status)
+		assert.Equal(t, uint32(5120), got.Bytes)
+		assert.Equal(t, 12.5, got.Latency)
+		assert.True(t, got.Cached)
+		assert.Equal(t, 1500*time.Millisecond, got.Timeout)
+		assert.Equal(t, netip.MustParseAddr("10.0.0.7"), got.Client)
+		require.NotNil(t, got.User)
+		assert.Equal(t, "jane", *got.User)
+		assert.Equal(t, "/index.html", got.Path, "untagged fields match case-insensitively")
+		assert.Empty(t, got.Ignored)
+	}
+}
+
+func TestDecode_MissingAndEmpty(t *testing.T) {
+	record := decodeLine(t, true, "2024-05-01,200,0,1,0")
+	got, err := Decode[accessLog](record)
+	require.NoError(t, err)
+	assert.Equal(t, 200, got.Status)
+	assert.Equal(t, uint32(0), got.Bytes, "detected false decodes as 0")
+	assert.Equal(t, 1.0, got.Latency)
+	assert.False(t, got.Cached)
+	assert.Nil(t, got.User)
+	assert.Zero(t, got.Timeout)
+
+	record = &Record{Fields: map[string]interface{}{"user": ""}}
+	got, err = Decode[accessLog](record)
+	require.NoError(t, err)
+	assert.Nil(t, got.User)
+}
+
+func TestDecode_Embedded(t *testing.T) {
+	type base struct {
+		Host string `csv:"host"`
+	}
+	type event struct {
+		base
+		Level string `csv:"level"`
+	}
+	record := &Record{Fields: map[string]interface{}{"host": "web-1", "level": "warn"}}
+	got, err := Decode[event](record)
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", got.Host)
+	assert.Equal(t, "warn", got.Level)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	_, err := Decode[accessLog](nil)
+	assert.Error(t, err)
+
+	_, err = Decode[int](&Record{})
+	assert.Error(t, err)
+
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+	}{
+		{"not a number", map[string]interface{}{"status": "ok"}},
+		{"fractional integer", map[string]interface{}{"status": 1.5}},
+		{"unsigned overflow", map[string]interface{}{"bytes": -1}},
+		{"not a bool", map[string]interface{}{"cached": "maybe"}},
+		{"not a time", map[string]interface{}{"timestamp": "yesterday"}},
+		{"not a duration", map[string]interface{}{"timeout": "soon"}},
+		{"not an address", map[string]interface{}{"client": "example"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode[accessLog](&Record{Fields: tt.fields})
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = Decode[struct {
+		Tags []string `csv:"tags"`
+	}](&Record{Fields: map[string]interface{}{"tags": "a b"}})
+	assert.ErrorContains(t, err, "field tags: unsupported type")
+}
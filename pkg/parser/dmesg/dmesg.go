@@ -23,6 +23,26 @@ type Record struct {
 	Message      string     `json:"message"`                 // The actual log message
 	BootTime     *time.Time `json:"boot_time,omitempty"`     // System boot time (if known)
 	AbsoluteTime *time.Time `json:"absolute_time,omitempty"` // Calculated absolute time
+	Device       *Device    `json:"device,omitempty"`        // Device identifiers (if any)
+}
+
+// Device holds the device identifiers found in a message, so records can be filtered
+// by device without matching the message text. Only the identifiers present are set.
+//
+//	usb 1-1.2: New USB device found, idVendor=046d, idProduct=c52b  -> USBPort, USBVendor, USBProduct
+//	pci 0000:00:1f.3: [8086:a348] type 00 class 0x040300           -> PCIAddress, PCIVendor, PCIDevice
+//	e1000e 0000:00:1f.6 eth0: NIC Link is Up 1000 Mbps Full Duplex -> PCIAddress, Interface
+//	sd 2:0:0:0: [sdb] Attached SCSI removable disk                  -> SCSITarget, Disk
+type Device struct {
+	USBPort    string `json:"usb_port,omitempty"`    // Bus-port path, e.g. "1-1.2"
+	USBVendor  string `json:"usb_vendor,omitempty"`  // idVendor, e.g. "046d"
+	USBProduct string `json:"usb_product,omitempty"` // idProduct, e.g. "c52b"
+	PCIAddress string `json:"pci_address,omitempty"` // Domain:bus:device.function, e.g. "0000:00:1f.3"
+	PCIVendor  string `json:"pci_vendor,omitempty"`  // e.g. "8086"
+	PCIDevice  string `json:"pci_device,omitempty"`  // e.g. "a348"
+	Interface  string `json:"interface,omitempty"`   // Network interface, e.g. "eth0", "enp3s0"
+	SCSITarget string `json:"scsi_target,omitempty"` // Host:channel:target:lun, e.g. "2:0:0:0"
+	Disk       string `json:"disk,omitempty"`        // Block device, e.g. "sdb"
 }
 
 // Parser handles dmesg log parsing
@@ -33,6 +53,14 @@ type Parser struct {
 	subsystemRegex *regexp.Regexp
 	// bootTime for converting relative timestamps to absolute time
 	bootTime *time.Time
+
+	// Device identifier patterns, see Device
+	usbPortRegex    *regexp.Regexp
+	usbIDRegex      *regexp.Regexp
+	pciAddressRegex *regexp.Regexp
+	pciIDRegex      *regexp.Regexp
+	interfaceRegex  *regexp.Regexp
+	scsiRegex       *regexp.Regexp
 }
 
 // NewParser creates a new dmesg parser
@@ -42,6 +70,16 @@ func NewParser() *Parser {
 		dmesgRegex: regexp.MustCompile(`^(?:<(\d+)>)?\[\s*(\d+(?:\.\d+)?)]\s*(.*)$`),
 		// Extracts subsystem: "usb 1-1:" -> "usb", "net eth0:" -> "net", "kernel:" -> "kernel"
 		subsystemRegex: regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_-]*)\s*.*?:`),
+		// "usb 1-1.2:" or "usb 1-1.2:1.0:" (interface of the device)
+		usbPortRegex: regexp.MustCompile(`^usb (\d+-[\d.]+)[:\s]`),
+		usbIDRegex:   regexp.MustCompile(`idVendor=([0-9a-fA-F]{4}), idProduct=([0-9a-fA-F]{4})`),
+		// "0000:00:1f.3", optionally followed by the "[8086:a348]" vendor and device IDs
+		pciAddressRegex: regexp.MustCompile(`\b([0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7])\b`),
+		pciIDRegex:      regexp.MustCompile(`^\S+ [0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]: \[([0-9a-f]{4}):([0-9a-f]{4})]`),
+		// An interface name followed by a colon: "eth0:", "net enp3s0:", "ADDRCONF(NETDEV_CHANGE): wlp2s0:"
+		interfaceRegex: regexp.MustCompile(`(?:^|\s)((?:eth|wlan|wwan|usb|br|virbr|docker|bond|team|vlan|veth|vxlan|tun|tap|ib)[0-9][0-9a-zA-Z_.-]*|br-[0-9a-f]+|veth[0-9a-f]+|(?:en|wl|ww)[opsx][0-9a-z]+):\s`),
+		// "scsi 0:0:0:0:", "sd 2:0:0:0: [sdb]" or "sr 1:0:0:0: [sr0]"
+		scsiRegex: regexp.MustCompile(`^(?:scsi|sd|sr|st|sg|ses) (\d+:\d+:\d+:\d+):(?: \[(\w+)])?`),
 	}
 }
 
@@ -118,9 +156,38 @@ func (p *Parser) Parse(line string) (*Record, error) {
 		}
 	}
 
+	record.Device = p.extractDevice(message)
+
 	return record, nil
 }
 
+// extractDevice returns the device identifiers of message, or nil when it has none.
+func (p *Parser) extractDevice(message string) *Device {
+	var d Device
+	if m := p.usbPortRegex.FindStringSubmatch(message); m != nil {
+		d.USBPort = m[1]
+	}
+	if m := p.usbIDRegex.FindStringSubmatch(message); m != nil {
+		d.USBVendor, d.USBProduct = strings.ToLower(m[1]), strings.ToLower(m[2])
+	}
+	if m := p.pciAddressRegex.FindStringSubmatch(message); m != nil {
+		d.PCIAddress = m[1]
+	}
+	if m := p.pciIDRegex.FindStringSubmatch(message); m != nil {
+		d.PCIVendor, d.PCIDevice = m[1], m[2]
+	}
+	if m := p.interfaceRegex.FindStringSubmatch(message); m != nil {
+		d.Interface = m[1]
+	}
+	if m := p.scsiRegex.FindStringSubmatch(message); m != nil {
+		d.SCSITarget, d.Disk = m[1], m[2]
+	}
+	if d == (Device{}) {
+		return nil
+	}
+	return &d
+}
+
 // ParseJSON parses a dmesg line and returns JSON
 func (p *Parser) ParseJSON(line string) ([]byte, error) {
 	record, err := p.Parse(line)
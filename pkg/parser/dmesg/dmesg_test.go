@@ -208,3 +208,70 @@ func TestDmesgParser_RealWorldExamples(t *testing.T) {
 		})
 	}
 }
+
+func TestDmesgParser_Device(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		input    string
+		expected *Device
+	}{
+		{
+			input:    "[    2.100000] usb 1-1.2: New USB device found, idVendor=046D, idProduct=c52b, bcdDevice=12.11",
+			expected: &Device{USBPort: "1-1.2", USBVendor: "046d", USBProduct: "c52b"},
+		},
+		{
+			input:    "[    2.200000] usb 3-2: new high-speed USB device number 2 using xhci_hcd",
+			expected: &Device{USBPort: "3-2"},
+		},
+		{
+			input:    "[   10.123456] pci 0000:00:1f.3: [8086:a348] type 00 class 0x040300",
+			expected: &Device{PCIAddress: "0000:00:1f.3", PCIVendor: "8086", PCIDevice: "a348"},
+		},
+		{
+			input:    "[   12.000000] e1000e 0000:00:1f.6 eth0: NIC Link is Up 1000 Mbps Full Duplex",
+			expected: &Device{PCIAddress: "0000:00:1f.6", Interface: "eth0"},
+		},
+		{
+			input:    "[   12.500000] r8169 0000:02:00.0 enp2s0: renamed from eth0",
+			expected: &Device{PCIAddress: "0000:02:00.0", Interface: "enp2s0"},
+		},
+		{
+			input:    "[   13.000000] IPv6: ADDRCONF(NETDEV_CHANGE): wlp3s0: link becomes ready",
+			expected: &Device{Interface: "wlp3s0"},
+		},
+		{
+			input:    "[  100.500000] docker0: port 1(veth123abc) entered blocking state",
+			expected: &Device{Interface: "docker0"},
+		},
+		{
+			input:    "[   15.678901] scsi 0:0:0:0: Direct-Access     ATA      Samsung SSD 850  2B6Q PQ: 0 ANSI: 5",
+			expected: &Device{SCSITarget: "0:0:0:0"},
+		},
+		{
+			input:    "[   16.000000] sd 2:0:0:0: [sdb] Attached SCSI removable disk",
+			expected: &Device{SCSITarget: "2:0:0:0", Disk: "sdb"},
+		},
+		{
+			input:    "[    0.000000] Linux version 5.15.0-56-generic (buildd@lcy02-amd64-044)",
+			expected: nil,
+		},
+		{
+			input:    "<4>[   25.111111] thermal thermal_zone0: failed to read out thermal zone (-61)",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parser.Parse(tt.input)
+			require.NoError(t, err)
+			require.NotNil(t, result)
+			assert.Equal(t, tt.expected, result.Device)
+		})
+	}
+
+	jsonBytes, err := parser.ParseJSON("[   16.000000] sd 2:0:0:0: [sdb] Attached SCSI removable disk")
+	require.NoError(t, err)
+	assert.Contains(t, string(jsonBytes), `"device":{"scsi_target":"2:0:0:0","disk":"sdb"}`)
+}
@@ -0,0 +1,194 @@
+package testkit
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/loykin/freader/internal/clock"
+)
+
+// Clock is the time source interface implemented by FakeClock; it is the same type as
+// freader.Clock, accepted by Config.Clock and WithClock.
+type Clock = clock.Clock
+
+// FakeClock is a Clock whose time only moves when Advance is called. Timers and
+// tickers created from it fire synchronously inside Advance, so a test can step poll
+// intervals and multiline timeouts without sleeping. It is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{} // closed and replaced whenever waiters change
+}
+
+type fakeWaiter struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration // ticker period; 0 for a timer
+	ch     chan time.Time
+	active bool
+}
+
+// NewFakeClock returns a FakeClock set to start (the current time if zero).
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return &FakeClock{now: start, changed: make(chan struct{})}
+}
+
+// Now returns the fake current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the fake time elapsed since t.
+func (c *FakeClock) Since(t time.Time) time.Duration { return c.Now().Sub(t) }
+
+// After returns a channel receiving the fake time once it has advanced by d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time { return c.NewTimer(d).C() }
+
+// NewTimer returns a timer firing once the fake time has advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) clock.Timer {
+	return fakeTimer{c.add(d, 0)}
+}
+
+// NewTicker returns a ticker firing every d of fake time. Like time.Ticker, it drops
+// ticks a slow receiver has not picked up.
+func (c *FakeClock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("testkit: non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.add(d, d)}
+}
+
+// Advance moves the fake time forward by d, firing every timer and ticker that falls
+// due on the way in time order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.at
+		select {
+		case w.ch <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.remove(w)
+		}
+	}
+	c.now = end
+}
+
+// Waiters returns the number of active timers and tickers.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n timers and tickers are active, e.g. until the
+// goroutines under test have started their tickers, so that a following Advance
+// reaches them. It gives up and returns false after timeout of real time.
+func (c *FakeClock) BlockUntil(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		count, changed := len(c.waiters), c.changed
+		c.mu.Unlock()
+		if count >= n {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+func (c *FakeClock) add(d, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{clock: c, at: c.now.Add(d), period: period, ch: make(chan time.Time, 1), active: true}
+	c.waiters = append(c.waiters, w)
+	c.notify()
+	return w
+}
+
+// remove deactivates w; c.mu must be held.
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	if !w.active {
+		return false
+	}
+	w.active = false
+	for i, o := range c.waiters {
+		if o == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			break
+		}
+	}
+	c.notify()
+	return true
+}
+
+// notify wakes BlockUntil callers; c.mu must be held.
+func (c *FakeClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (w *fakeWaiter) reset(d time.Duration) bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wasActive := c.remove(w)
+	w.at, w.active = c.now.Add(d), true
+	if w.period > 0 {
+		w.period = d
+	}
+	c.waiters = append(c.waiters, w)
+	c.notify()
+	return wasActive
+}
+
+type fakeTimer struct{ w *fakeWaiter }
+
+func (t fakeTimer) C() <-chan time.Time { return t.w.ch }
+
+func (t fakeTimer) Stop() bool {
+	t.w.clock.mu.Lock()
+	defer t.w.clock.mu.Unlock()
+	return t.w.clock.remove(t.w)
+}
+
+func (t fakeTimer) Reset(d time.Duration) bool { return t.w.reset(d) }
+
+type fakeTicker struct{ w *fakeWaiter }
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.ch }
+
+func (t fakeTicker) Stop() {
+	t.w.clock.mu.Lock()
+	defer t.w.clock.mu.Unlock()
+	t.w.clock.remove(t.w)
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("testkit: non-positive interval for Ticker.Reset")
+	}
+	t.w.reset(d)
+}
@@ -0,0 +1,53 @@
+package testkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock_TimersAndTickers(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	timer := c.NewTimer(3 * time.Second)
+	ticker := c.NewTicker(time.Second)
+	assert.Equal(t, 2, c.Waiters())
+
+	c.Advance(999 * time.Millisecond)
+	assert.Len(t, ticker.C(), 0)
+
+	c.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+
+	// A tick that is not received is dropped, like with time.Ticker
+	c.Advance(2 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-ticker.C())
+	assert.Equal(t, start.Add(3*time.Second), <-timer.C())
+	assert.Equal(t, start.Add(3*time.Second), c.Now())
+	assert.Equal(t, time.Second, c.Since(start.Add(2*time.Second)))
+
+	assert.False(t, timer.Stop(), "fired timer is no longer active")
+	ticker.Stop()
+	assert.Equal(t, 0, c.Waiters())
+
+	after := c.After(time.Minute)
+	assert.False(t, timer.Reset(time.Second))
+	c.Advance(time.Minute)
+	<-after
+	<-timer.C()
+}
+
+func TestFakeClock_BlockUntil(t *testing.T) {
+	c := NewFakeClock(time.Time{})
+	assert.False(t, c.BlockUntil(1, 10*time.Millisecond))
+
+	fired := make(chan struct{})
+	go func() {
+		<-c.After(time.Hour)
+		close(fired)
+	}()
+	assert.True(t, c.BlockUntil(1, time.Second))
+	c.Advance(time.Hour)
+	<-fired
+}
@@ -0,0 +1,113 @@
+package testkit
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// LineSink collects the lines delivered by a collector. Pass Add as the collector's
+// OnLineFunc, or call it from OnEventFunc. It is safe for concurrent use.
+type LineSink struct {
+	mu      sync.Mutex
+	lines   []string
+	changed chan struct{} // closed and replaced on every Add
+}
+
+// NewLineSink returns an empty LineSink.
+func NewLineSink() *LineSink {
+	return &LineSink{changed: make(chan struct{})}
+}
+
+// Add records line.
+func (s *LineSink) Add(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, line)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Lines returns the lines collected so far, in delivery order.
+func (s *LineSink) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lines...)
+}
+
+// Len returns the number of lines collected so far.
+func (s *LineSink) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.lines)
+}
+
+// Wait blocks until at least n lines have been collected or timeout has passed, and
+// reports whether n lines arrived. It wakes up on every delivery instead of polling.
+func (s *LineSink) Wait(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		s.mu.Lock()
+		count, changed := len(s.lines), s.changed
+		s.mu.Unlock()
+		if count >= n {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// WaitForLines waits up to timeout for len(want) lines and fails t unless exactly the
+// lines in want were collected, in order.
+func (s *LineSink) WaitForLines(t testing.TB, want []string, timeout time.Duration) {
+	t.Helper()
+	if !s.Wait(len(want), timeout) {
+		t.Fatalf("timed out after %v waiting for %d lines, got %d: %q", timeout, len(want), s.Len(), s.Lines())
+	}
+	AssertLines(t, s.Lines(), want)
+}
+
+// AssertLines fails t unless got equals want, reporting the first difference.
+func AssertLines(t testing.TB, got, want []string) {
+	t.Helper()
+	for i := 0; i < min(len(got), len(want)); i++ {
+		if got[i] != want[i] {
+			t.Fatalf("line %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d\ngot:  %q\nwant: %q", len(got), len(want), got, want)
+	}
+}
+
+// AssertLinesUnordered fails t unless got and want hold the same lines with the same
+// multiplicity, in any order, e.g. for lines read from several files by concurrent
+// workers.
+func AssertLinesUnordered(t testing.TB, got, want []string) {
+	t.Helper()
+	g, w := slices.Clone(got), slices.Clone(want)
+	slices.Sort(g)
+	slices.Sort(w)
+	if !slices.Equal(g, w) {
+		t.Fatalf("lines differ (ignoring order)\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+// AssertNoDuplicates fails t if any line occurs more than once in got, e.g. after a
+// rotation or restart that must not replay records.
+func AssertNoDuplicates(t testing.TB, got []string) {
+	t.Helper()
+	seen := make(map[string]int, len(got))
+	for i, line := range got {
+		if j, ok := seen[line]; ok {
+			t.Fatalf("line %q delivered twice, at %d and %d", line, j, i)
+		}
+		seen[line] = i
+	}
+}
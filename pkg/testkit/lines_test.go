@@ -0,0 +1,55 @@
+package testkit_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/freader"
+	"github.com/loykin/freader/pkg/testkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineSink_Wait(t *testing.T) {
+	s := testkit.NewLineSink()
+	assert.False(t, s.Wait(1, 10*time.Millisecond))
+	go func() {
+		s.Add("a")
+		s.Add("b")
+	}()
+	assert.True(t, s.Wait(2, time.Second))
+	testkit.AssertLines(t, s.Lines(), []string{"a", "b"})
+	testkit.AssertLinesUnordered(t, []string{"b", "a"}, s.Lines())
+	testkit.AssertNoDuplicates(t, s.Lines())
+}
+
+// A rename rotation in the middle of writing delivers every record exactly once.
+func TestCollectorAcrossRotation(t *testing.T) {
+	dir := t.TempDir()
+	w, err := testkit.NewLogWriter(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	sink := testkit.NewLineSink()
+
+	c, err := freader.New(
+		freader.WithInclude(filepath.Join(dir, "*.log")),
+		freader.WithPollInterval(20*time.Millisecond),
+		freader.WithFingerprint(freader.FingerprintStrategyDeviceAndInode, 0),
+		freader.WithOnLine(sink.Add),
+	)
+	require.NoError(t, err)
+	c.Start()
+	defer c.Stop()
+
+	_, err = w.Write(3)
+	require.NoError(t, err)
+	require.True(t, sink.Wait(3, 3*time.Second))
+	_, err = w.Rotate()
+	require.NoError(t, err)
+	_, err = w.Write(2)
+	require.NoError(t, err)
+
+	sink.WaitForLines(t, w.Written(), 3*time.Second)
+	testkit.AssertNoDuplicates(t, sink.Lines())
+}
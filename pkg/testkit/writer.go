@@ -0,0 +1,197 @@
+// Package testkit helps write deterministic integration tests against freader: a
+// synthetic log writer covering append, rate, rotation and truncation scenarios, a
+// fake clock, and a line sink with assertion helpers.
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogWriter appends synthetic records to a log file and remembers every record it
+// wrote, so a test can compare them with what a collector delivered. It is safe for
+// concurrent use.
+type LogWriter struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	separator string
+	format    func(n int) string
+	written   []string
+	rotations int
+}
+
+// WriterOption configures a LogWriter.
+type WriterOption func(*LogWriter)
+
+// WithRecordFormat sets the text of the n-th record (counting from 1) written by
+// Write and Stream; the default is "line <n>".
+func WithRecordFormat(format func(n int) string) WriterOption {
+	return func(w *LogWriter) {
+		if format != nil {
+			w.format = format
+		}
+	}
+}
+
+// WithRecordSeparator sets the separator appended to every record (default "\n").
+func WithRecordSeparator(sep string) WriterOption {
+	return func(w *LogWriter) {
+		if sep != "" {
+			w.separator = sep
+		}
+	}
+}
+
+// NewLogWriter creates (or truncates) the file at path and returns a writer appending to it.
+func NewLogWriter(path string, opts ...WriterOption) (*LogWriter, error) {
+	w := &LogWriter{
+		path:      path,
+		separator: "\n",
+		format:    func(n int) string { return fmt.Sprintf("line %d", n) },
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if err := w.open(os.O_TRUNC); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the file being written.
+func (w *LogWriter) Path() string { return w.path }
+
+// Write appends the next n generated records and returns them.
+func (w *LogWriter) Write(n int) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	records := make([]string, n)
+	for i := range records {
+		records[i] = w.format(len(w.written) + i + 1)
+	}
+	return records, w.writeLocked(records)
+}
+
+// WriteRecords appends the given records.
+func (w *LogWriter) WriteRecords(records ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeLocked(records)
+}
+
+// WritePartial appends s without a separator, e.g. to test that an unterminated
+// record is held back until it is completed by a later write.
+func (w *LogWriter) WritePartial(s string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := io.WriteString(w.file, s)
+	return err
+}
+
+// Stream writes n generated records at about perSecond records per second, returning
+// early with ctx's error when it is cancelled.
+func (w *LogWriter) Stream(ctx context.Context, perSecond, n int) error {
+	if perSecond <= 0 {
+		return fmt.Errorf("testkit: rate must be positive, got %d", perSecond)
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(perSecond))
+	defer ticker.Stop()
+	for i := 0; i < n; i++ {
+		if _, err := w.Write(1); err != nil {
+			return err
+		}
+		if i == n-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Rotate renames the file to "<path>.<k>" (k counting rotations from 1) and starts a
+// new, empty file at path, like logrotate's default create mode. It returns the name
+// the old file was moved to.
+func (w *LogWriter) Rotate() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rotations++
+	rotated := fmt.Sprintf("%s.%d", w.path, w.rotations)
+	if err := w.file.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(w.path, rotated); err != nil {
+		return "", err
+	}
+	return rotated, w.open(os.O_EXCL)
+}
+
+// CopyTruncate copies the file to "<path>.<k>" and truncates it in place, like
+// logrotate's copytruncate mode. It returns the name of the copy.
+func (w *LogWriter) CopyTruncate() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rotations++
+	rotated := fmt.Sprintf("%s.%d", w.path, w.rotations)
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(rotated, data, 0o644); err != nil {
+		return "", err
+	}
+	return rotated, w.file.Truncate(0)
+}
+
+// Truncate empties the file in place; later records are written from offset 0.
+func (w *LogWriter) Truncate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Truncate(0)
+}
+
+// Written returns every record written so far, in order.
+func (w *LogWriter) Written() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.written...)
+}
+
+// Close closes the file. The file itself is left in place.
+func (w *LogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func (w *LogWriter) open(flag int) error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|flag, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	return nil
+}
+
+// writeLocked writes records with their separators in a single write, so a reader
+// never sees half of the batch; w.mu must be held.
+func (w *LogWriter) writeLocked(records []string) error {
+	var buf []byte
+	for _, r := range records {
+		buf = append(buf, r...)
+		buf = append(buf, w.separator...)
+	}
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	w.written = append(w.written, records...)
+	return nil
+}
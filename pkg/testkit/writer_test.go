@@ -0,0 +1,68 @@
+package testkit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogWriter_Scenarios(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewLogWriter(path)
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+
+	records, err := w.Write(2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"line 1", "line 2"}, records)
+
+	rotated, err := w.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, path+".1", rotated)
+	assertFile(t, rotated, "line 1\nline 2\n")
+	assertFile(t, path, "")
+
+	require.NoError(t, w.WriteRecords("custom"))
+	copied, err := w.CopyTruncate()
+	require.NoError(t, err)
+	assert.Equal(t, path+".2", copied)
+	assertFile(t, copied, "custom\n")
+	assertFile(t, path, "")
+
+	_, err = w.Write(1)
+	require.NoError(t, err)
+	require.NoError(t, w.WritePartial("part"))
+	assertFile(t, path, "line 4\npart")
+	require.NoError(t, w.Truncate())
+	assertFile(t, path, "")
+
+	require.NoError(t, w.Stream(context.Background(), 1000, 3))
+	assertFile(t, path, "line 5\nline 6\nline 7\n")
+	assert.Equal(t, []string{"line 1", "line 2", "custom", "line 4", "line 5", "line 6", "line 7"}, w.Written())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, w.Stream(ctx, 1, 2), context.Canceled)
+	assert.Error(t, w.Stream(context.Background(), 0, 1))
+}
+
+func TestLogWriter_Options(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewLogWriter(path, WithRecordSeparator("\x00"), WithRecordFormat(func(n int) string { return "rec-" + string(rune('a'+n-1)) }))
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	_, err = w.Write(2)
+	require.NoError(t, err)
+	assertFile(t, path, "rec-a\x00rec-b\x00")
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(data))
+}